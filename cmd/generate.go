@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/h2co32/gollama/internal/models"
+)
+
+// runGenerate implements the generate subcommand. It sends a prompt to a model
+// and streams the response to stdout as it is produced.
+//
+// Usage: gollama generate -model llama3 [flags] <prompt>
+// If no prompt is given on the command line, it is read from stdin.
+func runGenerate(args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	model := fs.String("model", "", "Model to generate with (required)")
+	host := fs.String("host", models.DefaultOllamaURL, "Address of the Ollama server")
+	system := fs.String("system", "", "System prompt")
+	temperature := fs.Float64("temperature", -1, "Sampling temperature (model default if negative)")
+	maxTokens := fs.Int("max-tokens", 0, "Maximum number of tokens to generate (model default if 0)")
+	jsonMode := fs.Bool("json", false, "Constrain the output to valid JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s generate -model <model> [flags] <prompt>\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *model == "" {
+		fs.Usage()
+		return fmt.Errorf("-model is required")
+	}
+
+	prompt := strings.Join(fs.Args(), " ")
+	if prompt == "" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read prompt from stdin: %w", err)
+		}
+		prompt = strings.TrimSpace(string(data))
+	}
+	if prompt == "" {
+		return fmt.Errorf("prompt cannot be empty")
+	}
+
+	req := models.GenerateRequest{
+		Model:  *model,
+		Prompt: prompt,
+		System: *system,
+	}
+	options := make(map[string]interface{})
+	if *temperature >= 0 {
+		options["temperature"] = *temperature
+	}
+	if *maxTokens > 0 {
+		options["num_predict"] = *maxTokens
+	}
+	if len(options) > 0 {
+		req.Options = options
+	}
+	if *jsonMode {
+		req.Format = "json"
+	}
+
+	// Stop generating on Ctrl+C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client := models.NewOllamaClientWithOptions(models.OllamaClientOptions{BaseURL: *host})
+	wrote := false
+	err := client.Generate(ctx, req, func(res models.GenerateResponse) error {
+		if res.Response == "" {
+			return nil
+		}
+		wrote = true
+		_, err := io.WriteString(os.Stdout, res.Response)
+		return err
+	})
+	if wrote {
+		fmt.Println()
+	}
+	return err
+}
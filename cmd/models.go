@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/h2co32/gollama/internal/models"
+)
+
+// runModels implements the models subcommand, which manages the models stored in a
+// local model directory.
+//
+// Usage:
+//
+//	gollama models verify [-dir ./models] [-repair] [model [version]]
+//	gollama models pin|unpin [-dir ./models] model version
+func runModels(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s models verify|pin|unpin [flags] [model [version]]", os.Args[0])
+	}
+	switch args[0] {
+	case "verify":
+		return runModelsVerify(args[1:])
+	case "pin", "unpin":
+		return runModelsPin(args[0], args[1:])
+	default:
+		return fmt.Errorf("unknown models command: %s", args[0])
+	}
+}
+
+// runModelsPin pins or unpins a stored model version, depending on command.
+func runModelsPin(command string, args []string) error {
+	fs := flag.NewFlagSet("models "+command, flag.ExitOnError)
+	dir := fs.String("dir", "./models", "Directory the models are stored in")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s models %s [flags] model version\n\n", os.Args[0], command)
+		fmt.Fprintln(fs.Output(), "Pinned versions cannot be deleted or evicted from memory.")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("a model and version are required")
+	}
+
+	mm := models.NewModelManager(*dir)
+	name, version := fs.Arg(0), fs.Arg(1)
+	if command == "pin" {
+		if err := mm.PinModel(name, version); err != nil {
+			return err
+		}
+		fmt.Printf("%s %s: pinned\n", name, version)
+		return nil
+	}
+	if err := mm.UnpinModel(name, version); err != nil {
+		return err
+	}
+	fmt.Printf("%s %s: unpinned\n", name, version)
+	return nil
+}
+
+// runModelsVerify recomputes the checksums of stored model versions, compares them
+// with the manifest, and reports every version that is missing, truncated, or corrupt.
+// With -repair, damaged versions are downloaded again. It fails if any version is
+// still damaged.
+func runModelsVerify(args []string) error {
+	fs := flag.NewFlagSet("models verify", flag.ExitOnError)
+	dir := fs.String("dir", "./models", "Directory the models are stored in")
+	repair := fs.Bool("repair", false, "Download damaged versions again")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s models verify [flags] [model [version]]\n\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "Verifies every recorded version, the versions of model, or a single version.")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 2 {
+		fs.Usage()
+		return fmt.Errorf("too many arguments")
+	}
+
+	mm := models.NewModelManager(*dir)
+	var results []models.VerifyResult
+	if fs.NArg() == 2 {
+		result, err := mm.Verify(fs.Arg(0), fs.Arg(1))
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+	} else {
+		all, err := mm.VerifyAll()
+		if err != nil {
+			return err
+		}
+		for _, result := range all {
+			if fs.NArg() == 0 || result.Name == fs.Arg(0) {
+				results = append(results, result)
+			}
+		}
+		if len(results) == 0 && fs.NArg() == 1 {
+			return fmt.Errorf("no recorded versions of model %s", fs.Arg(0))
+		}
+	}
+
+	damaged := 0
+	for _, result := range results {
+		if result.OK() {
+			fmt.Printf("%s %s: ok\n", result.Name, result.Version)
+			continue
+		}
+		switch result.Status {
+		case models.VerifyMissing:
+			fmt.Printf("%s %s: missing\n", result.Name, result.Version)
+		default:
+			fmt.Printf("%s %s: %s (%d of %d bytes, sha256 %s, expected %s)\n", result.Name, result.Version, result.Status,
+				result.ActualSize, result.ExpectedSize, result.ActualDigest, result.ExpectedDigest)
+		}
+
+		if *repair {
+			if err := mm.RepairModel(context.Background(), result.Name, result.Version); err != nil {
+				fmt.Printf("%s %s: repair failed: %v\n", result.Name, result.Version, err)
+				damaged++
+				continue
+			}
+			fmt.Printf("%s %s: repaired\n", result.Name, result.Version)
+			continue
+		}
+		damaged++
+	}
+
+	if damaged > 0 {
+		return fmt.Errorf("%d of %d model versions are damaged", damaged, len(results))
+	}
+	return nil
+}
@@ -1,43 +1,128 @@
-package main
-
-import (
-	"flag"
-	"fmt"
-	"os"
-
-	"github.com/h2co32/gollama/internal/models"
-	"github.com/h2co32/gollama/internal/utils"
-)
-
-func main() {
-	model := flag.String("model", "default", "Specify the model to load")
-	action := flag.String("action", "download", "Action to perform: download/preload/fine-tune")
-	version := flag.Bool("version", false, "Display version information")
-
-	flag.Parse()
-
-	// Display version information if requested
-	if *version {
-		fmt.Printf("gollama version %s\n", utils.Version)
-		os.Exit(0)
-	}
-
-	client := models.NewOllamaClient()
-
-	switch *action {
-	case "download":
-		if err := client.DownloadModel(models.DownloadModelRequest{Model: *model}); err != nil {
-			fmt.Printf("Error downloading model: %v\n", err)
-			os.Exit(1)
-		}
-	case "preload":
-		client.PreloadModels([]string{*model})
-	case "fine-tune":
-		if err := client.FineTuneModel(models.ModelFineTuningRequest{Dataset: "custom-dataset", ModelVersion: *model}); err != nil {
-			fmt.Printf("Error fine-tuning model: %v\n", err)
-			os.Exit(1)
-		}
-	default:
-		fmt.Println("Invalid action provided")
-	}
-}
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/h2co32/gollama/internal/models"
+	"github.com/h2co32/gollama/internal/utils"
+)
+
+func main() {
+	// Subcommands take their own flags; anything else uses the -action flags below
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "generate":
+			if err := runGenerate(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error generating: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "serve":
+			if err := runServe(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error serving: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "models":
+			if err := runModels(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	model := flag.String("model", "default", "Specify the model to load")
+	action := flag.String("action", "download", "Action to perform: download/preload/fine-tune")
+	version := flag.Bool("version", false, "Display version information")
+	trustedKeys := flag.String("trusted-keys", "", "Comma-separated PEM files of publisher keys downloads must be signed with")
+	requireSignature := flag.Bool("require-signature", false, "Refuse to download unsigned models")
+	warmup := flag.Bool("warmup", true, "Send warmup requests to preloaded models")
+	warmupPrompt := flag.String("warmup-prompt", models.DefaultWarmupOptions().Prompt, "Prompt sent in warmup requests")
+	warmupRequests := flag.Int("warmup-requests", models.DefaultWarmupOptions().Requests, "Number of warmup requests per model")
+
+	flag.Parse()
+
+	// Display version information if requested
+	if *version {
+		fmt.Printf("gollama version %s\n", utils.Version)
+		os.Exit(0)
+	}
+
+	options := models.DefaultOllamaClientOptions()
+	if *requireSignature && *trustedKeys == "" {
+		fmt.Println("Error: -require-signature needs -trusted-keys")
+		os.Exit(1)
+	}
+	if *trustedKeys != "" {
+		keys, err := loadTrustedKeys(strings.Split(*trustedKeys, ","))
+		if err != nil {
+			fmt.Printf("Error loading trusted keys: %v\n", err)
+			os.Exit(1)
+		}
+		options.Signatures = models.SignaturePolicy{TrustedKeys: keys, Strict: *requireSignature}
+	}
+	client := models.NewOllamaClientWithOptions(options)
+
+	switch *action {
+	case "download":
+		if err := client.DownloadModel(models.DownloadModelRequest{Model: *model, Progress: progressBar()}); err != nil {
+			fmt.Printf("Error downloading model: %v\n", err)
+			os.Exit(1)
+		}
+	case "preload":
+		opts := models.PreloadOptions{
+			Warmup: models.WarmupOptions{Disabled: !*warmup, Prompt: *warmupPrompt, Requests: *warmupRequests},
+		}
+		failed := false
+		for _, result := range client.Preload(context.Background(), []string{*model}, opts) {
+			switch {
+			case result.Err != nil:
+				fmt.Printf("Error preloading model %s: %v\n", result.Model, result.Err)
+				failed = true
+			case result.WarmupErr != nil:
+				fmt.Printf("Loaded model %s in %s, but warmup failed: %v\n", result.Model, result.LoadDuration.Round(time.Millisecond), result.WarmupErr)
+			case len(result.Warmup) > 0:
+				fmt.Printf("Loaded model %s in %s, warmup latency %s\n", result.Model, result.LoadDuration.Round(time.Millisecond), result.Warmup[0].Round(time.Millisecond))
+			default:
+				fmt.Printf("Loaded model %s in %s\n", result.Model, result.LoadDuration.Round(time.Millisecond))
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	case "fine-tune":
+		if err := client.FineTuneModel(models.ModelFineTuningRequest{Dataset: "custom-dataset", ModelVersion: *model}); err != nil {
+			fmt.Printf("Error fine-tuning model: %v\n", err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Println("Invalid action provided")
+	}
+}
+
+// loadTrustedKeys reads PEM-encoded Ed25519 public keys, naming each publisher after
+// its key file without the extension.
+func loadTrustedKeys(paths []string) (map[string]ed25519.PublicKey, error) {
+	keys := make(map[string]ed25519.PublicKey, len(paths))
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := models.ParsePublicKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys[strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))] = key
+	}
+	return keys, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/h2co32/gollama/internal/models"
+)
+
+// progressBarWidth is the number of characters in the bar of a progress line.
+const progressBarWidth = 30
+
+// progressBar returns a DownloadProgressFunc that draws a progress bar on stderr,
+// redrawn in place on a terminal. When stderr is redirected, only the completed
+// download is reported, to keep logs free of partial lines.
+func progressBar() models.DownloadProgressFunc {
+	terminal := false
+	if info, err := os.Stderr.Stat(); err == nil {
+		terminal = info.Mode()&os.ModeCharDevice != 0
+	}
+	return func(p models.DownloadProgress) {
+		switch {
+		case terminal && p.Done:
+			fmt.Fprintf(os.Stderr, "\r%s\n", progressLine(p))
+		case terminal:
+			fmt.Fprintf(os.Stderr, "\r%s", progressLine(p))
+		case p.Done:
+			fmt.Fprintln(os.Stderr, progressLine(p))
+		}
+	}
+}
+
+// progressLine formats a download's progress, such as:
+//
+//	llama3 v1 [=========>                    ]  33% 1.2 GB / 3.6 GB  85.0 MB/s  ETA 29s
+func progressLine(p models.DownloadProgress) string {
+	if p.Total <= 0 {
+		return fmt.Sprintf("%s %s %s  %s/s", p.Model, p.Version, formatBytes(p.Completed), formatBytes(int64(p.Rate)))
+	}
+
+	filled := int(p.Percent() / 100 * progressBarWidth)
+	filled = min(max(filled, 0), progressBarWidth)
+	bar := strings.Repeat("=", filled)
+	if filled < progressBarWidth {
+		bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+	}
+
+	line := fmt.Sprintf("%s %s [%s] %3.0f%% %s / %s  %s/s", p.Model, p.Version, bar, p.Percent(),
+		formatBytes(p.Completed), formatBytes(p.Total), formatBytes(int64(p.Rate)))
+	if p.ETA > 0 {
+		line += "  ETA " + p.ETA.Round(time.Second).String()
+	}
+	// Pad over the longer line drawn before, such as one with an ETA
+	return line + "   "
+}
+
+// formatBytes formats a byte count in decimal units, such as 1.2 GB.
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, exp := float64(n)/unit, 0
+	for value >= unit && exp < 4 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", value, "kMGTP"[exp])
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/h2co32/gollama/config"
+	"github.com/h2co32/gollama/internal/admin"
+	"github.com/h2co32/gollama/internal/gateway"
+	"github.com/h2co32/gollama/internal/loadbalancer"
+	"github.com/h2co32/gollama/internal/metrics"
+	"github.com/h2co32/gollama/internal/models"
+	"github.com/h2co32/gollama/internal/provider"
+	"github.com/h2co32/gollama/internal/quota"
+	"github.com/h2co32/gollama/internal/security"
+	"github.com/h2co32/gollama/internal/usage"
+	"github.com/h2co32/gollama/pkg/guard"
+	"github.com/h2co32/gollama/pkg/health"
+	"github.com/h2co32/gollama/pkg/middleware"
+	"github.com/h2co32/gollama/pkg/ratelimiter"
+	"github.com/h2co32/gollama/pkg/secrets"
+)
+
+// runServe implements the serve subcommand. It starts an HTTP gateway that proxies
+// inference requests to a pool of Ollama instances.
+//
+// Usage: gollama serve -backends host1:11434,host2:11434 [flags]
+// Secrets can be passed through the GOLLAMA_JWT_SECRET and GOLLAMA_HMAC_SECRET
+// environment variables to keep them out of the process list, or read from a file,
+// Vault, or AWS Secrets Manager with -jwt-secret-ref and -hmac-secret-ref.
+// Models assigned a provider in the -config file, such as an OpenAI-compatible or
+// llama.cpp server, are served by that provider instead of the backends. With
+// -usage-file, the tokens of each tenant's requests are accounted for, priced at the
+// model costs of the -config file, and reported at /usage. The quotas of the -config
+// file limit each tenant's requests and tokens, counted in Redis with -quota-redis so
+// every replica of the gateway shares them. With -admin, the subjects listed can manage
+// models, backends, the rate limit, and the config file at runtime under /admin/.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "Address to listen on")
+	backends := fs.String("backends", "localhost:11434", "Comma-separated host:port list of Ollama instances")
+	healthInterval := fs.Duration("health-interval", 10*time.Second, "Interval between backend health checks")
+	failureThreshold := fs.Int("failure-threshold", 3, "Failed health checks before a backend is marked unhealthy")
+	healthPath := fs.String("health-path", "/health", "Path requested on each backend to check its health")
+	backendScheme := fs.String("backend-scheme", "http", "Scheme used to reach the backends: http or https")
+	backendCA := fs.String("backend-ca", "", "PEM CA bundle used to verify backend certificates instead of the system roots")
+	ejectErrorRate := fs.Float64("eject-error-rate", 0, "Fraction of failed requests that ejects a backend for a backoff period (0 disables ejection)")
+	inventoryInterval := fs.Duration("inventory-interval", 30*time.Second, "Interval between polls of the models on each backend, used to route requests to backends with the model loaded (0 disables)")
+	configFile := fs.String("config", "", "Config file whose providers serve the models assigned to them instead of the backends, whose model costs price usage, and whose quotas limit each tenant")
+	usageFile := fs.String("usage-file", "", "File per-tenant token usage and cost are saved to; enables usage accounting and the /usage endpoint")
+	usageAdmins := fs.String("usage-admins", "", "Comma-separated tenants, such as JWT subjects, that can see the usage of every tenant at /usage")
+	quotaRedis := fs.String("quota-redis", "", "Comma-separated host:port list of the Redis server or cluster nodes that quota counts are shared through (counts are kept in memory if empty)")
+	redisPassword := fs.String("redis-password", os.Getenv("GOLLAMA_REDIS_PASSWORD"), "Password for -quota-redis")
+	admins := fs.String("admin", "", "Comma-separated subjects, such as JWT subjects, allowed to use the admin API under /admin/; requires -auth")
+	modelDir := fs.String("model-dir", "", "Directory of the models managed through the admin API")
+	minFreeDisk := fs.Uint64("min-free-disk-mb", 1024, "Free megabytes required on the -model-dir and -usage-file disks for /readyz to pass")
+	strategy := fs.String("lb-strategy", loadbalancer.StrategyRoundRobin, "Load balancing strategy: round-robin, weighted-round-robin, least-connections, lowest-latency, or two-choices")
+	authType := fs.String("auth", "", "Authentication type: jwt, hmac, mtls, or empty to disable")
+	jwtSecret := fs.String("jwt-secret", os.Getenv("GOLLAMA_JWT_SECRET"), "Secret for JWT authentication")
+	hmacSecret := fs.String("hmac-secret", os.Getenv("GOLLAMA_HMAC_SECRET"), "Secret for HMAC authentication")
+	jwtSecretRef := fs.String("jwt-secret-ref", "", "Reference to the JWT secret: env:NAME, file:PATH, vault:PATH#KEY, or aws:ID[#KEY]")
+	hmacSecretRef := fs.String("hmac-secret-ref", "", "Reference to the HMAC secret, in the same form as -jwt-secret-ref")
+	auditLog := fs.String("audit-log", "", "File that every authentication success and failure is appended to as JSON lines")
+	rate := fs.Float64("rate", 0, "Requests per second allowed across all clients (0 disables rate limiting)")
+	burst := fs.Float64("burst", 0, "Burst capacity of the rate limiter (defaults to -rate)")
+	maxBodyBytes := fs.Int64("max-body-bytes", middleware.DefaultLimitOptions().MaxBodyBytes, "Largest request body accepted, in bytes (-1 disables the limit)")
+	requestTimeout := fs.Duration("request-timeout", 0, "Time allowed to handle a request, including streaming the response (0 disables the timeout)")
+	enableGuard := fs.Bool("guard", false, "Reject prompts that try to override the model's instructions")
+	maxPromptLength := fs.Int("max-prompt-length", 0, "Most characters allowed in a prompt (0 disables the limit); implies -guard")
+	guardDenyFile := fs.String("guard-deny-file", "", "File of regular expressions, one per line, that prompts must not match; implies -guard")
+	allowedURLHosts := fs.String("allowed-url-hosts", "", "Comma-separated hosts that links in prompts may point to, including subdomains; implies -guard")
+	enableMetrics := fs.Bool("metrics", true, "Serve Prometheus metrics at /metrics")
+	tlsCert := fs.String("tls-cert", "", "PEM certificate file; enables HTTPS together with -tls-key")
+	tlsKey := fs.String("tls-key", "", "PEM private key file for -tls-cert")
+	tlsClientCA := fs.String("tls-client-ca", "", "PEM CA bundle used to verify client certificates")
+	requireClientCert := fs.Bool("require-client-cert", false, "Reject TLS clients without a certificate signed by -tls-client-ca")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s serve [flags]\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var servers []string
+	for _, server := range strings.Split(*backends, ",") {
+		if server = strings.TrimSpace(server); server != "" {
+			servers = append(servers, server)
+		}
+	}
+	if len(servers) == 0 {
+		return fmt.Errorf("at least one backend is required")
+	}
+
+	lbStrategy, err := loadbalancer.ParseStrategy(*strategy)
+	if err != nil {
+		return err
+	}
+	lbOptions := loadbalancer.DefaultOptions()
+	lbOptions.HealthCheckInterval = *healthInterval
+	lbOptions.FailureThreshold = *failureThreshold
+	lbOptions.Strategy = lbStrategy
+	lbOptions.HealthCheck.Path = *healthPath
+	lbOptions.CircuitBreaker.ErrorRate = *ejectErrorRate
+	lbOptions.Inventory.Interval = *inventoryInterval
+	switch *backendScheme {
+	case "http", "https":
+		lbOptions.Scheme = *backendScheme
+	default:
+		return fmt.Errorf("unsupported backend scheme: %s", *backendScheme)
+	}
+	if *backendCA != "" {
+		tlsOptions := security.DefaultTLSOptions()
+		tlsOptions.CAFile = *backendCA
+		if lbOptions.TLSConfig, err = security.NewClientTLSConfig(tlsOptions); err != nil {
+			return fmt.Errorf("failed to configure backend TLS: %w", err)
+		}
+	}
+	var metricsProvider *metrics.MetricsProvider
+	if *enableMetrics {
+		metricsProvider = metrics.NewMetricsProvider()
+		lbOptions.Metrics = metricsProvider
+	}
+	balancer := loadbalancer.NewLoadBalancerWithOptions(context.Background(), servers, lbOptions)
+	defer balancer.Close()
+	options := gateway.Options{
+		Balancer: balancer,
+		Metrics:  metricsProvider,
+		Health:   health.New(health.DefaultOptions()),
+	}
+
+	var auditSink middleware.AuditSink
+	if *auditLog != "" {
+		if *authType == "" {
+			return fmt.Errorf("-audit-log requires -auth")
+		}
+		sink, err := middleware.NewFileAuditSink(*auditLog)
+		if err != nil {
+			return err
+		}
+		defer sink.Close()
+		auditSink = sink
+	}
+
+	switch *authType {
+	case "":
+	case middleware.AuthTypeJWT, middleware.AuthTypeHMAC:
+		if (*authType == middleware.AuthTypeJWT && *jwtSecret == "" && *jwtSecretRef == "") ||
+			(*authType == middleware.AuthTypeHMAC && *hmacSecret == "" && *hmacSecretRef == "") {
+			return fmt.Errorf("%s authentication requires a secret", *authType)
+		}
+		provider := secretProvider()
+		// Fail at startup rather than on the first request if a reference is wrong
+		for _, ref := range []string{*jwtSecretRef, *hmacSecretRef} {
+			if ref == "" {
+				continue
+			}
+			if _, err := provider.GetSecret(context.Background(), ref); err != nil {
+				return fmt.Errorf("failed to read secret %s: %w", ref, err)
+			}
+		}
+		options.Auth = middleware.NewAuthMiddleware(middleware.AuthOptions{
+			AuthType:       *authType,
+			JWTSecret:      *jwtSecret,
+			HMACSecret:     *hmacSecret,
+			SecretProvider: provider,
+			JWTSecretName:  *jwtSecretRef,
+			HMACSecretName: *hmacSecretRef,
+			AuditSink:      auditSink,
+		})
+	case middleware.AuthTypeMTLS:
+		if *tlsClientCA == "" {
+			return fmt.Errorf("mtls authentication requires -tls-client-ca")
+		}
+		options.Auth = middleware.NewAuthMiddleware(middleware.AuthOptions{AuthType: *authType, AuditSink: auditSink})
+	default:
+		return fmt.Errorf("unsupported authentication type: %s", *authType)
+	}
+
+	options.Limits = middleware.NewLimitMiddleware(middleware.LimitOptions{
+		MaxBodyBytes: *maxBodyBytes,
+		Timeout:      *requestTimeout,
+	})
+
+	if *enableGuard || *maxPromptLength > 0 || *guardDenyFile != "" || *allowedURLHosts != "" {
+		guardOptions := guard.DefaultOptions()
+		guardOptions.MaxPromptLength = *maxPromptLength
+		if *guardDenyFile != "" {
+			patterns, err := readPatterns(*guardDenyFile)
+			if err != nil {
+				return err
+			}
+			guardOptions.DenyPatterns = patterns
+		}
+		if *allowedURLHosts != "" {
+			guardOptions.AllowedURLHosts = strings.Split(*allowedURLHosts, ",")
+		}
+		if metricsProvider != nil {
+			guardOptions.OnViolation = func(r *http.Request, _ guard.Result) {
+				metricsProvider.TrackError(r.URL.Path, "guard")
+			}
+		}
+		g, err := guard.New(guardOptions)
+		if err != nil {
+			return err
+		}
+		options.Guard = g
+	}
+
+	if *rate > 0 {
+		options.Limiter = ratelimiter.New(*rate, time.Second, *burst)
+	}
+
+	var cfg *config.Config
+	if *configFile != "" {
+		if cfg, err = config.Load(*configFile); err != nil {
+			return err
+		}
+		if len(cfg.Providers()) > 0 {
+			router, err := provider.FromConfig(context.Background(), cfg, secretProvider(), metricsProvider)
+			if err != nil {
+				return fmt.Errorf("failed to configure providers: %w", err)
+			}
+			options.Providers = router
+		}
+	}
+
+	if *usageFile != "" {
+		usageOptions := usage.DefaultOptions()
+		usageOptions.Store = usage.NewFileStore(*usageFile)
+		options.Health.AddReadinessCheck("usage_dir", health.DirWritable(filepath.Dir(*usageFile)))
+		options.Health.AddReadinessCheck("usage_disk", health.DiskSpace(filepath.Dir(*usageFile), *minFreeDisk<<20))
+		usageOptions.Metrics = metricsProvider
+		if cfg != nil {
+			usageOptions.Rates = usageRates(cfg)
+		}
+		tracker, err := usage.NewTracker(usageOptions)
+		if err != nil {
+			return err
+		}
+		// Save the usage of the last requests on shutdown
+		defer tracker.Close()
+		options.Usage = tracker
+		if *usageAdmins != "" {
+			options.UsageAdmins = strings.Split(*usageAdmins, ",")
+		}
+	} else if *usageAdmins != "" {
+		return fmt.Errorf("-usage-admins requires -usage-file")
+	}
+
+	if cfg != nil && len(cfg.Quotas()) > 0 {
+		quotaOptions := quota.DefaultOptions()
+		quotaOptions.Metrics = metricsProvider
+		quotaOptions.Default, quotaOptions.Tenants = quotaLimits(cfg)
+		if *quotaRedis != "" {
+			// Several addresses connect to a Redis Cluster
+			client := redis.NewUniversalClient(&redis.UniversalOptions{
+				Addrs:    strings.Split(*quotaRedis, ","),
+				Password: *redisPassword,
+			})
+			defer client.Close()
+			if err := client.Ping(context.Background()).Err(); err != nil {
+				return fmt.Errorf("failed to connect to quota redis: %w", err)
+			}
+			quotaOptions.Store = quota.NewRedisStore(client, "gollama:quota")
+			options.Health.AddReadinessCheck("quota_redis", health.Redis(client))
+		}
+		options.Quota = quota.NewEngine(quotaOptions)
+	} else if *quotaRedis != "" {
+		return fmt.Errorf("-quota-redis requires quotas in -config")
+	}
+
+	if *admins != "" {
+		if options.Auth == nil {
+			return fmt.Errorf("-admin requires -auth")
+		}
+		adminOptions := admin.Options{
+			Auth:     options.Auth,
+			Admins:   strings.Split(*admins, ","),
+			Balancer: balancer,
+			Limiter:  options.Limiter,
+		}
+		if *modelDir != "" {
+			adminOptions.Models = models.NewModelManager(*modelDir)
+			options.Health.AddReadinessCheck("model_dir", health.DirWritable(*modelDir))
+			options.Health.AddReadinessCheck("model_disk", health.DiskSpace(*modelDir, *minFreeDisk<<20))
+		}
+		if cfg != nil {
+			// Providers are created at startup, so changing them still takes a restart
+			adminOptions.Reload = func(ctx context.Context) error {
+				if err := cfg.Reload(); err != nil {
+					return err
+				}
+				if options.Usage != nil {
+					options.Usage.SetRates(usageRates(cfg))
+				}
+				if options.Quota != nil {
+					options.Quota.SetLimits(quotaLimits(cfg))
+				}
+				return nil
+			}
+		}
+		api, err := admin.New(adminOptions)
+		if err != nil {
+			return err
+		}
+		options.Admin = api
+	} else if *modelDir != "" {
+		return fmt.Errorf("-model-dir requires -admin")
+	}
+
+	gw, err := gateway.New(options)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           gw,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	useTLS := *tlsCert != "" || *tlsKey != ""
+	if useTLS {
+		tlsOptions := security.DefaultTLSOptions()
+		tlsOptions.CertFile = *tlsCert
+		tlsOptions.KeyFile = *tlsKey
+		tlsOptions.CAFile = *tlsClientCA
+		tlsOptions.RequireClientCert = *requireClientCert
+		tlsConfig, err := security.NewServerTLSConfig(tlsOptions)
+		if err != nil {
+			return fmt.Errorf("failed to configure TLS: %w", err)
+		}
+		server.TLSConfig = tlsConfig
+	} else if *tlsClientCA != "" || *requireClientCert || *authType == middleware.AuthTypeMTLS {
+		return fmt.Errorf("client certificates require -tls-cert and -tls-key")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		fmt.Printf("Gateway listening on %s, proxying to %s\n", *addr, strings.Join(servers, ", "))
+		if useTLS {
+			// The certificate comes from TLSConfig so rotated files are picked up
+			errCh <- server.ListenAndServeTLS("", "")
+			return
+		}
+		errCh <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("gateway server failed: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+	}
+
+	fmt.Println("Shutting down gateway...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down gateway: %w", err)
+	}
+	return nil
+}
+
+// readPatterns reads a file of patterns, one per line, skipping blank lines and
+// comments starting with #.
+func readPatterns(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read patterns: %w", err)
+	}
+	var patterns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns, nil
+}
+
+// usageRates returns the model costs of cfg as usage rates.
+func usageRates(cfg *config.Config) map[string]usage.Rate {
+	rates := make(map[string]usage.Rate)
+	for model, cost := range cfg.ModelCosts() {
+		rates[model] = usage.Rate{Prompt: cost.Prompt, Completion: cost.Completion}
+	}
+	return rates
+}
+
+// quotaLimits returns the default quota limits of cfg and the limits of each tenant.
+func quotaLimits(cfg *config.Config) (quota.Limits, map[string]quota.Limits) {
+	var defaultLimits quota.Limits
+	tenants := make(map[string]quota.Limits)
+	for tenant, q := range cfg.Quotas() {
+		limits := quota.Limits{
+			DailyRequests:   q.DailyRequests,
+			DailyTokens:     q.DailyTokens,
+			MonthlyRequests: q.MonthlyRequests,
+			MonthlyTokens:   q.MonthlyTokens,
+		}
+		if tenant == config.DefaultQuotaName {
+			defaultLimits = limits
+		} else {
+			tenants[tenant] = limits
+		}
+	}
+	return defaultLimits, tenants
+}
+
+// secretProvider resolves secret references by scheme. Values are cached for a minute
+// so remote stores are not queried on every request, while rotations still apply.
+func secretProvider() secrets.SecretProvider {
+	return secrets.NewCachedProvider(secrets.SchemeProvider{
+		"env":   secrets.EnvProvider{},
+		"file":  secrets.FileProvider{},
+		"vault": secrets.NewVaultProvider(secrets.VaultOptions{}),
+		"aws":   secrets.NewAWSSecretsManagerProvider(secrets.AWSOptions{}),
+	}, time.Minute)
+}
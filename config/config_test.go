@@ -22,13 +22,13 @@ func TestDefaultProfile(t *testing.T) {
 	
 	// Test model settings
 	expectedTemp := 0.7
-	if temp, ok := DefaultProfile.ModelSettings["temperature"].(float64); !ok || temp != expectedTemp {
-		t.Errorf("Expected DefaultProfile.ModelSettings[\"temperature\"] to be %v, got %v", expectedTemp, DefaultProfile.ModelSettings["temperature"])
+	if DefaultProfile.ModelSettings.Temperature != expectedTemp {
+		t.Errorf("Expected DefaultProfile.ModelSettings.Temperature to be %v, got %v", expectedTemp, DefaultProfile.ModelSettings.Temperature)
 	}
 	
 	expectedMaxTokens := 1024
-	if maxTokens, ok := DefaultProfile.ModelSettings["max_tokens"].(int); !ok || maxTokens != expectedMaxTokens {
-		t.Errorf("Expected DefaultProfile.ModelSettings[\"max_tokens\"] to be %v, got %v", expectedMaxTokens, DefaultProfile.ModelSettings["max_tokens"])
+	if DefaultProfile.ModelSettings.MaxTokens != expectedMaxTokens {
+		t.Errorf("Expected DefaultProfile.ModelSettings.MaxTokens to be %v, got %v", expectedMaxTokens, DefaultProfile.ModelSettings.MaxTokens)
 	}
 }
 
@@ -49,13 +49,13 @@ func TestProductionProfile(t *testing.T) {
 	
 	// Test model settings
 	expectedTemp := 0.5
-	if temp, ok := ProductionProfile.ModelSettings["temperature"].(float64); !ok || temp != expectedTemp {
-		t.Errorf("Expected ProductionProfile.ModelSettings[\"temperature\"] to be %v, got %v", expectedTemp, ProductionProfile.ModelSettings["temperature"])
+	if ProductionProfile.ModelSettings.Temperature != expectedTemp {
+		t.Errorf("Expected ProductionProfile.ModelSettings.Temperature to be %v, got %v", expectedTemp, ProductionProfile.ModelSettings.Temperature)
 	}
 	
 	expectedMaxTokens := 2048
-	if maxTokens, ok := ProductionProfile.ModelSettings["max_tokens"].(int); !ok || maxTokens != expectedMaxTokens {
-		t.Errorf("Expected ProductionProfile.ModelSettings[\"max_tokens\"] to be %v, got %v", expectedMaxTokens, ProductionProfile.ModelSettings["max_tokens"])
+	if ProductionProfile.ModelSettings.MaxTokens != expectedMaxTokens {
+		t.Errorf("Expected ProductionProfile.ModelSettings.MaxTokens to be %v, got %v", expectedMaxTokens, ProductionProfile.ModelSettings.MaxTokens)
 	}
 }
 
@@ -65,10 +65,10 @@ func TestConfigProfileCustomization(t *testing.T) {
 		MaxRetries: 10,
 		Timeout:    30 * time.Second,
 		RateLimit:  50,
-		ModelSettings: map[string]interface{}{
-			"temperature": 0.8,
-			"max_tokens":  4096,
-			"top_p":       0.95,
+		ModelSettings: GenerationSettings{
+			Temperature: 0.8,
+			MaxTokens:   4096,
+			TopP:        0.95,
 		},
 	}
 	
@@ -88,17 +88,17 @@ func TestConfigProfileCustomization(t *testing.T) {
 	
 	// Test custom model settings
 	expectedTemp := 0.8
-	if temp, ok := customProfile.ModelSettings["temperature"].(float64); !ok || temp != expectedTemp {
-		t.Errorf("Expected customProfile.ModelSettings[\"temperature\"] to be %v, got %v", expectedTemp, customProfile.ModelSettings["temperature"])
+	if customProfile.ModelSettings.Temperature != expectedTemp {
+		t.Errorf("Expected customProfile.ModelSettings.Temperature to be %v, got %v", expectedTemp, customProfile.ModelSettings.Temperature)
 	}
 	
 	expectedMaxTokens := 4096
-	if maxTokens, ok := customProfile.ModelSettings["max_tokens"].(int); !ok || maxTokens != expectedMaxTokens {
-		t.Errorf("Expected customProfile.ModelSettings[\"max_tokens\"] to be %v, got %v", expectedMaxTokens, customProfile.ModelSettings["max_tokens"])
+	if customProfile.ModelSettings.MaxTokens != expectedMaxTokens {
+		t.Errorf("Expected customProfile.ModelSettings.MaxTokens to be %v, got %v", expectedMaxTokens, customProfile.ModelSettings.MaxTokens)
 	}
 	
 	expectedTopP := 0.95
-	if topP, ok := customProfile.ModelSettings["top_p"].(float64); !ok || topP != expectedTopP {
-		t.Errorf("Expected customProfile.ModelSettings[\"top_p\"] to be %v, got %v", expectedTopP, customProfile.ModelSettings["top_p"])
+	if customProfile.ModelSettings.TopP != expectedTopP {
+		t.Errorf("Expected customProfile.ModelSettings.TopP to be %v, got %v", expectedTopP, customProfile.ModelSettings.TopP)
 	}
 }
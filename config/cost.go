@@ -0,0 +1,51 @@
+package config
+
+import "fmt"
+
+// ModelCost is the price of a model's tokens, per million tokens, in whatever currency
+// usage is billed in.
+type ModelCost struct {
+	Prompt     float64 // Price of a million prompt tokens
+	Completion float64 // Price of a million generated tokens
+}
+
+// ModelCosts returns the cost of each model in the per-model overrides of the active
+// profile, keyed by model name as written in the config file.
+func (c *Config) ModelCosts() map[string]ModelCost {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	costs := make(map[string]ModelCost)
+	for _, overrides := range []map[string]ModelOverride{c.models, c.profileModels[c.active]} {
+		for model, o := range overrides {
+			if o.Cost != nil {
+				costs[model] = *o.Cost
+			}
+		}
+	}
+	return costs
+}
+
+// decodeModelCost decodes a table of prompt and completion token prices.
+func decodeModelCost(value interface{}) (ModelCost, error) {
+	var cost ModelCost
+	table, ok := value.(map[string]interface{})
+	if !ok {
+		return cost, fmt.Errorf("must be a table of prompt and completion prices")
+	}
+	for key, value := range table {
+		price, ok := toFloat(value)
+		if !ok || price < 0 {
+			return cost, fmt.Errorf("%s must be a non-negative number, got %v", key, value)
+		}
+		switch key {
+		case "prompt":
+			cost.Prompt = price
+		case "completion":
+			cost.Completion = price
+		default:
+			return cost, fmt.Errorf("unknown field %s", key)
+		}
+	}
+	return cost, nil
+}
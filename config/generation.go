@@ -0,0 +1,79 @@
+package config
+
+import "fmt"
+
+// GenerationSettings holds the sampling parameters used when generating with a model.
+// Zero values leave the model's own default in place, except for Temperature.
+type GenerationSettings struct {
+	Temperature   float64  // Sampling temperature between 0 and 2
+	TopP          float64  // Nucleus sampling probability mass between 0 and 1
+	TopK          int      // Number of most likely tokens to sample from
+	MaxTokens     int      // Maximum number of tokens to generate
+	StopSequences []string // Sequences that end generation when produced
+	Seed          int      // Random seed for reproducible output
+}
+
+// Validate checks that the settings are within their allowed ranges.
+func (s GenerationSettings) Validate() error {
+	if s.Temperature < 0 || s.Temperature > 2 {
+		return fmt.Errorf("temperature must be between 0 and 2, got %v", s.Temperature)
+	}
+	if s.TopP < 0 || s.TopP > 1 {
+		return fmt.Errorf("top_p must be between 0 and 1, got %v", s.TopP)
+	}
+	if s.TopK < 0 {
+		return fmt.Errorf("top_k must not be negative, got %d", s.TopK)
+	}
+	if s.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens must not be negative, got %d", s.MaxTokens)
+	}
+	if s.Seed < 0 {
+		return fmt.Errorf("seed must not be negative, got %d", s.Seed)
+	}
+	for i, stop := range s.StopSequences {
+		if stop == "" {
+			return fmt.Errorf("stop sequence %d is empty", i)
+		}
+	}
+	return nil
+}
+
+// GenerationSettingsFromMap converts the map-based model settings used by earlier
+// versions of this package, e.g. {"temperature": 0.7, "max_tokens": 1024}, to
+// GenerationSettings. Recognized keys are temperature, top_p, top_k, max_tokens,
+// stop, and seed. The result is validated.
+func GenerationSettingsFromMap(m map[string]interface{}) (GenerationSettings, error) {
+	o, err := decodeModelOverride(m, false)
+	if err != nil {
+		return GenerationSettings{}, err
+	}
+	s := o.apply(ConfigProfile{}).ModelSettings
+	if err := s.Validate(); err != nil {
+		return GenerationSettings{}, err
+	}
+	return s, nil
+}
+
+// Map returns the settings as a map keyed like the map-based model settings used by
+// earlier versions of this package. Unset values are omitted, except for temperature.
+func (s GenerationSettings) Map() map[string]interface{} {
+	m := map[string]interface{}{
+		"temperature": s.Temperature,
+	}
+	if s.TopP != 0 {
+		m["top_p"] = s.TopP
+	}
+	if s.TopK != 0 {
+		m["top_k"] = s.TopK
+	}
+	if s.MaxTokens != 0 {
+		m["max_tokens"] = s.MaxTokens
+	}
+	if len(s.StopSequences) > 0 {
+		m["stop"] = append([]string(nil), s.StopSequences...)
+	}
+	if s.Seed != 0 {
+		m["seed"] = s.Seed
+	}
+	return m
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestGenerationSettingsValidate(t *testing.T) {
+	valid := GenerationSettings{
+		Temperature:   0.7,
+		TopP:          0.9,
+		TopK:          40,
+		MaxTokens:     256,
+		StopSequences: []string{"\n\n"},
+		Seed:          42,
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Expected valid settings, got %v", err)
+	}
+
+	invalid := map[string]GenerationSettings{
+		"temperature": {Temperature: 2.5},
+		"top_p":       {TopP: 1.5},
+		"top_k":       {TopK: -1},
+		"max_tokens":  {MaxTokens: -10},
+		"seed":        {Seed: -1},
+		"stop":        {StopSequences: []string{"ok", ""}},
+	}
+	for name, settings := range invalid {
+		if err := settings.Validate(); err == nil {
+			t.Errorf("Expected error for invalid %s, got nil", name)
+		}
+	}
+}
+
+func TestGenerationSettingsFromMap(t *testing.T) {
+	settings, err := GenerationSettingsFromMap(map[string]interface{}{
+		"temperature": 0.8,
+		"max_tokens":  4096,
+		"top_p":       0.95,
+		"top_k":       50,
+		"stop":        []interface{}{"###"},
+		"seed":        7,
+	})
+	if err != nil {
+		t.Fatalf("Failed to convert settings: %v", err)
+	}
+
+	if settings.Temperature != 0.8 || settings.MaxTokens != 4096 || settings.TopP != 0.95 || settings.TopK != 50 || settings.Seed != 7 {
+		t.Errorf("Unexpected settings: %+v", settings)
+	}
+	if len(settings.StopSequences) != 1 || settings.StopSequences[0] != "###" {
+		t.Errorf("Expected stop sequences [###], got %v", settings.StopSequences)
+	}
+
+	// Round trip back to a map
+	m := settings.Map()
+	if m["temperature"] != 0.8 || m["max_tokens"] != 4096 || m["top_k"] != 50 {
+		t.Errorf("Unexpected map: %v", m)
+	}
+
+	// Whole numbers decoded as float64, e.g. by encoding/json, are accepted for integer settings
+	if settings, err := GenerationSettingsFromMap(map[string]interface{}{"max_tokens": float64(512)}); err != nil || settings.MaxTokens != 512 {
+		t.Errorf("Expected max_tokens 512, got %d (%v)", settings.MaxTokens, err)
+	}
+
+	if _, err := GenerationSettingsFromMap(map[string]interface{}{"temperature": "hot"}); err == nil {
+		t.Error("Expected error for non-numeric temperature, got nil")
+	}
+	if _, err := GenerationSettingsFromMap(map[string]interface{}{"unknown": 1}); err == nil {
+		t.Error("Expected error for unknown key, got nil")
+	}
+	if _, err := GenerationSettingsFromMap(map[string]interface{}{"temperature": 3.0}); err == nil {
+		t.Error("Expected validation error, got nil")
+	}
+}
+
+func TestGenerationSettingsMapOmitsUnset(t *testing.T) {
+	m := DefaultProfile.ModelSettings.Map()
+	if len(m) != 2 || m["temperature"] != 0.7 || m["max_tokens"] != 1024 {
+		t.Errorf("Expected only temperature and max_tokens, got %v", m)
+	}
+}
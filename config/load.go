@@ -0,0 +1,768 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/h2co32/gollama/pkg/secrets"
+	"gopkg.in/yaml.v3"
+)
+
+// Built-in profile names. Profiles in a config file with these names start from
+// DefaultProfile and ProductionProfile; other profiles start from DefaultProfile.
+const (
+	DefaultProfileName    = "default"
+	ProductionProfileName = "production"
+)
+
+// EnvPrefix prefixes the environment variables that override config values.
+const EnvPrefix = "GOLLAMA_"
+
+// Config holds the named profiles loaded from a config file and the active profile.
+// It is safe for concurrent use.
+type Config struct {
+	path            string // File the config was loaded from
+	profiles        map[string]ConfigProfile
+	models          map[string]ModelOverride            // Per-model overrides shared by all profiles
+	profileModels   map[string]map[string]ModelOverride // Per-model overrides of each profile
+	secrets         map[string]string                   // Secret references by name
+	providers       map[string]ProviderConfig           // Model backends by name
+	defaultProvider string
+	quotas          map[string]Quota // Request and token quotas by tenant
+	active          string
+	overrides       envOverrides
+	mu              sync.RWMutex
+}
+
+// ModelOverride overrides profile values for a single model. Nil fields keep the profile value.
+type ModelOverride struct {
+	Temperature   *float64
+	TopP          *float64
+	TopK          *int
+	MaxTokens     *int
+	StopSequences []string
+	Seed          *int
+	RateLimit     *int
+	Provider      string     // Name of the provider serving the model, or empty to keep the default
+	Cost          *ModelCost // Price of the model's tokens, for usage accounting
+}
+
+// envOverrides holds values read from GOLLAMA_* environment variables.
+// Nil fields are not set in the environment.
+type envOverrides struct {
+	MaxRetries  *int
+	Timeout     *time.Duration
+	RateLimit   *int
+	Temperature *float64
+	MaxTokens   *int
+}
+
+// Load reads named profiles from a YAML (.yaml, .yml), TOML (.toml), or JSON (.json)
+// file and applies environment variable overrides. A file looks like:
+//
+//	profile: staging # active profile, overridden by GOLLAMA_PROFILE
+//	profiles:
+//	  staging:
+//	    base: production # inherit values from another profile
+//	    rate_limit: 50
+//	    model_settings:
+//	      temperature: 0.5
+//	    models:
+//	      llama3:
+//	        max_tokens: 4096
+//	models: # per-model overrides for every profile
+//	  codellama:
+//	    temperature: 0.1
+//	  gpt-4o-mini:
+//	    provider: openai
+//	    cost: {prompt: 0.15, completion: 0.6} # price per million tokens
+//	secrets: # references resolved by a secrets.SecretProvider, never the values
+//	  jwt: vault:gollama#jwt
+//	  openai: env:OPENAI_API_KEY
+//	providers: # model backends, selected per model with a models entry's provider
+//	  openai:
+//	    type: openai
+//	    url: https://api.openai.com/v1
+//	    api_key: openai # name of a secret above
+//	  local:
+//	    type: ollama
+//	  resilient: # routing policies: failover, split, or shadow
+//	    type: failover
+//	    providers: [openai, local]
+//	    timeout: 10s
+//	default_provider: openai # serves models without a provider
+//	quotas: # per tenant, such as the name of an API key, per UTC day and month
+//	  default: # tenants without their own quotas
+//	    daily_requests: 1000
+//	  acme:
+//	    daily_tokens: 200000
+//	    monthly_tokens: 5000000
+//
+// Profiles are layered: a profile starts from its base, or from the built-in profile
+// of the same name, or from DefaultProfile, and the file only sets what changes.
+// Resolve additionally applies per-model overrides.
+//
+// GOLLAMA_MAX_RETRIES, GOLLAMA_TIMEOUT, GOLLAMA_RATE_LIMIT, GOLLAMA_TEMPERATURE, and
+// GOLLAMA_MAX_TOKENS override the corresponding values of whichever profile is used,
+// after any per-model overrides. Every profile is validated after overrides are applied.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var raw map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".json":
+		raw, err = decodeJSON(data)
+	case ".toml":
+		raw, err = decodeTOML(data)
+	default:
+		return nil, fmt.Errorf("unsupported config file format: %s", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	cfg, err := newConfig(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	cfg.path = path
+	return cfg, nil
+}
+
+// Reload reads the file the config was loaded from again, along with the environment,
+// and replaces every value with the new ones, including the active profile. If the
+// file is invalid, the config is left unchanged and the error returned. Values already
+// read from the config, such as the providers a router was created from, are not
+// updated; callers apply the ones that can change at runtime themselves.
+func (c *Config) Reload() error {
+	next, err := Load(c.path)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.profiles = next.profiles
+	c.models = next.models
+	c.profileModels = next.profileModels
+	c.secrets = next.secrets
+	c.providers = next.providers
+	c.defaultProvider = next.defaultProvider
+	c.quotas = next.quotas
+	c.active = next.active
+	c.overrides = next.overrides
+	return nil
+}
+
+// Profile returns the named profile with environment overrides applied.
+func (c *Config) Profile(name string) (ConfigProfile, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	profile, ok := c.profiles[name]
+	if !ok {
+		return ConfigProfile{}, fmt.Errorf("profile %s not found", name)
+	}
+	return c.overrides.apply(profile).clone(), nil
+}
+
+// Active returns the active profile with environment overrides applied.
+func (c *Config) Active() ConfigProfile {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.overrides.apply(c.profiles[c.active]).clone()
+}
+
+// Resolve returns the active profile with the overrides for model applied, in order:
+// shared per-model overrides, the active profile's per-model overrides, then environment
+// overrides. Overrides for a model name without its tag (e.g. "llama3" for "llama3:8b")
+// apply before overrides for the exact name.
+func (c *Config) Resolve(model string) ConfigProfile {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.resolve(c.active, model)
+}
+
+// resolve layers the overrides for model over the named profile.
+// This method is not thread-safe and should be called with the lock held.
+func (c *Config) resolve(name, model string) ConfigProfile {
+	profile := c.profiles[name]
+	for _, overrides := range []map[string]ModelOverride{c.models, c.profileModels[name]} {
+		if base := modelBaseName(model); base != model {
+			if o, ok := overrides[base]; ok {
+				profile = o.apply(profile)
+			}
+		}
+		if o, ok := overrides[model]; ok {
+			profile = o.apply(profile)
+		}
+	}
+	return c.overrides.apply(profile).clone()
+}
+
+// clone returns a copy of the profile that shares no slices with it, so that callers
+// cannot change a stored profile through one they were given, or the reverse.
+func (p ConfigProfile) clone() ConfigProfile {
+	p.ModelSettings.StopSequences = slices.Clone(p.ModelSettings.StopSequences)
+	return p
+}
+
+// SecretRef returns the reference configured for the named secret, such as
+// "env:GOLLAMA_JWT_SECRET" or "vault:gollama#jwt".
+func (c *Config) SecretRef(name string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ref, ok := c.secrets[name]
+	return ref, ok
+}
+
+// Secret resolves the named secret's reference with provider, typically a
+// secrets.SchemeProvider. The config file only holds the reference.
+func (c *Config) Secret(ctx context.Context, provider secrets.SecretProvider, name string) (string, error) {
+	ref, ok := c.SecretRef(name)
+	if !ok {
+		return "", fmt.Errorf("%w: no reference configured for %s", secrets.ErrSecretNotFound, name)
+	}
+	return provider.GetSecret(ctx, ref)
+}
+
+// ActiveName returns the name of the active profile.
+func (c *Config) ActiveName() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.active
+}
+
+// Use selects the active profile at runtime.
+func (c *Config) Use(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.profiles[name]; !ok {
+		return fmt.Errorf("profile %s not found", name)
+	}
+	c.active = name
+	return nil
+}
+
+// Profiles returns the names of all profiles in sorted order.
+func (c *Config) Profiles() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	names := make([]string, 0, len(c.profiles))
+	for name := range c.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks that profile values are within their allowed ranges.
+func (p ConfigProfile) Validate() error {
+	if p.MaxRetries < 0 {
+		return fmt.Errorf("max_retries must not be negative, got %d", p.MaxRetries)
+	}
+	if p.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive, got %v", p.Timeout)
+	}
+	if p.RateLimit <= 0 {
+		return fmt.Errorf("rate_limit must be positive, got %d", p.RateLimit)
+	}
+	if err := p.ModelSettings.Validate(); err != nil {
+		return fmt.Errorf("model_settings: %w", err)
+	}
+	return nil
+}
+
+// newConfig builds a Config from a decoded config file and the environment.
+func newConfig(raw map[string]interface{}) (*Config, error) {
+	cfg := &Config{
+		profiles: map[string]ConfigProfile{
+			DefaultProfileName:    DefaultProfile.clone(),
+			ProductionProfileName: ProductionProfile.clone(),
+		},
+		models:        make(map[string]ModelOverride),
+		profileModels: make(map[string]map[string]ModelOverride),
+		secrets:       make(map[string]string),
+		providers:     make(map[string]ProviderConfig),
+		quotas:        make(map[string]Quota),
+		active:        DefaultProfileName,
+	}
+
+	tables := make(map[string]map[string]interface{})
+	for key, value := range raw {
+		switch key {
+		case "profile":
+			name, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("profile must be a string, got %v", value)
+			}
+			cfg.active = name
+		case "profiles":
+			profiles, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("profiles must be a table of named profiles")
+			}
+			for name, fields := range profiles {
+				table, ok := fields.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("profile %s must be a table", name)
+				}
+				tables[name] = table
+			}
+		case "models":
+			models, err := decodeModelOverrides(value)
+			if err != nil {
+				return nil, err
+			}
+			cfg.models = models
+		case "secrets":
+			refs, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("secrets must be a table of secret references")
+			}
+			for name, ref := range refs {
+				s, ok := ref.(string)
+				if !ok {
+					return nil, fmt.Errorf("secret %s must be a reference string, got %v", name, ref)
+				}
+				cfg.secrets[name] = s
+			}
+		case "providers":
+			providers, err := decodeProviders(value)
+			if err != nil {
+				return nil, err
+			}
+			cfg.providers = providers
+		case "default_provider":
+			name, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("default_provider must be a string, got %v", value)
+			}
+			cfg.defaultProvider = name
+		case "quotas":
+			quotas, err := decodeQuotas(value)
+			if err != nil {
+				return nil, err
+			}
+			cfg.quotas = quotas
+		default:
+			return nil, fmt.Errorf("unknown field %s", key)
+		}
+	}
+
+	// Resolve profiles after reading all of them, since a base may be defined later in the file
+	resolving := make(map[string]bool)
+	var resolve func(name string) error
+	resolve = func(name string) error {
+		table, ok := tables[name]
+		if !ok {
+			return nil
+		}
+		if resolving[name] {
+			return fmt.Errorf("profile %s has a circular base", name)
+		}
+		resolving[name] = true
+
+		base, ok := cfg.profiles[name]
+		if !ok {
+			base = DefaultProfile
+		}
+		if v, ok := table["base"]; ok {
+			baseName, isString := v.(string)
+			if !isString {
+				return fmt.Errorf("profile %s: base must be a string, got %v", name, v)
+			}
+			if err := resolve(baseName); err != nil {
+				return err
+			}
+			if base, ok = cfg.profiles[baseName]; !ok {
+				return fmt.Errorf("profile %s: base profile %s not found", name, baseName)
+			}
+		}
+
+		profile, models, err := decodeProfile(base, table)
+		if err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+		cfg.profiles[name] = profile.clone()
+		cfg.profileModels[name] = models
+		delete(tables, name)
+		return nil
+	}
+	for name := range tables {
+		if err := resolve(name); err != nil {
+			return nil, err
+		}
+	}
+
+	if name := os.Getenv(EnvPrefix + "PROFILE"); name != "" {
+		cfg.active = name
+	}
+	if _, ok := cfg.profiles[cfg.active]; !ok {
+		return nil, fmt.Errorf("active profile %s not found", cfg.active)
+	}
+	if err := cfg.validateProviders(); err != nil {
+		return nil, err
+	}
+
+	overrides, err := readEnvOverrides()
+	if err != nil {
+		return nil, err
+	}
+	cfg.overrides = overrides
+
+	for name := range cfg.profiles {
+		if err := cfg.resolve(name, "").Validate(); err != nil {
+			return nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+		for _, models := range []map[string]ModelOverride{cfg.models, cfg.profileModels[name]} {
+			for model := range models {
+				if err := cfg.resolve(name, model).Validate(); err != nil {
+					return nil, fmt.Errorf("profile %s, model %s: %w", name, model, err)
+				}
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// decodeProfile overrides the fields of base with the values set in table and
+// returns the profile's per-model overrides.
+func decodeProfile(base ConfigProfile, table map[string]interface{}) (ConfigProfile, map[string]ModelOverride, error) {
+	models := make(map[string]ModelOverride)
+	for key, value := range table {
+		switch key {
+		case "base":
+			// Handled by newConfig
+		case "max_retries":
+			n, ok := toInt(value)
+			if !ok {
+				return base, nil, fmt.Errorf("max_retries must be an integer, got %v", value)
+			}
+			base.MaxRetries = n
+		case "timeout":
+			d, err := toDuration(value)
+			if err != nil {
+				return base, nil, fmt.Errorf("timeout: %w", err)
+			}
+			base.Timeout = d
+		case "rate_limit":
+			n, ok := toInt(value)
+			if !ok {
+				return base, nil, fmt.Errorf("rate_limit must be an integer, got %v", value)
+			}
+			base.RateLimit = n
+		case "model_settings":
+			settings, ok := value.(map[string]interface{})
+			if !ok {
+				return base, nil, fmt.Errorf("model_settings must be a table")
+			}
+			o, err := decodeModelOverride(settings, false)
+			if err != nil {
+				return base, nil, fmt.Errorf("model_settings: %w", err)
+			}
+			base = o.apply(base)
+		case "models":
+			var err error
+			if models, err = decodeModelOverrides(value); err != nil {
+				return base, nil, err
+			}
+		default:
+			return base, nil, fmt.Errorf("unknown field %s", key)
+		}
+	}
+	return base, models, nil
+}
+
+// decodeModelOverrides decodes a table of per-model overrides keyed by model name.
+func decodeModelOverrides(value interface{}) (map[string]ModelOverride, error) {
+	table, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("models must be a table of model names")
+	}
+
+	models := make(map[string]ModelOverride, len(table))
+	for model, fields := range table {
+		settings, ok := fields.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("models.%s must be a table", model)
+		}
+		o, err := decodeModelOverride(settings, true)
+		if err != nil {
+			return nil, fmt.Errorf("models.%s: %w", model, err)
+		}
+		models[model] = o
+	}
+	return models, nil
+}
+
+// decodeModelOverride decodes generation settings, and the rate limit, provider, and
+// cost if perModel is set.
+func decodeModelOverride(table map[string]interface{}, perModel bool) (ModelOverride, error) {
+	var o ModelOverride
+	for key, value := range table {
+		switch key {
+		case "temperature", "top_p":
+			f, ok := toFloat(value)
+			if !ok {
+				return o, fmt.Errorf("%s must be a number, got %v", key, value)
+			}
+			if key == "temperature" {
+				o.Temperature = &f
+			} else {
+				o.TopP = &f
+			}
+		case "top_k", "max_tokens", "seed", "rate_limit":
+			if key == "rate_limit" && !perModel {
+				return o, fmt.Errorf("unknown field %s", key)
+			}
+			n, ok := toInt(value)
+			if !ok {
+				return o, fmt.Errorf("%s must be an integer, got %v", key, value)
+			}
+			switch key {
+			case "top_k":
+				o.TopK = &n
+			case "max_tokens":
+				o.MaxTokens = &n
+			case "seed":
+				o.Seed = &n
+			default:
+				o.RateLimit = &n
+			}
+		case "stop":
+			stops, err := toStrings(value)
+			if err != nil {
+				return o, fmt.Errorf("stop: %w", err)
+			}
+			o.StopSequences = stops
+		case "provider":
+			if !perModel {
+				return o, fmt.Errorf("unknown field %s", key)
+			}
+			name, ok := value.(string)
+			if !ok {
+				return o, fmt.Errorf("provider must be a string, got %v", value)
+			}
+			o.Provider = name
+		case "cost":
+			if !perModel {
+				return o, fmt.Errorf("unknown field %s", key)
+			}
+			cost, err := decodeModelCost(value)
+			if err != nil {
+				return o, fmt.Errorf("cost: %w", err)
+			}
+			o.Cost = &cost
+		default:
+			return o, fmt.Errorf("unknown field %s", key)
+		}
+	}
+	return o, nil
+}
+
+// apply returns profile with the override's non-nil fields set.
+func (o ModelOverride) apply(profile ConfigProfile) ConfigProfile {
+	if o.Temperature != nil {
+		profile.ModelSettings.Temperature = *o.Temperature
+	}
+	if o.TopP != nil {
+		profile.ModelSettings.TopP = *o.TopP
+	}
+	if o.TopK != nil {
+		profile.ModelSettings.TopK = *o.TopK
+	}
+	if o.MaxTokens != nil {
+		profile.ModelSettings.MaxTokens = *o.MaxTokens
+	}
+	if o.StopSequences != nil {
+		profile.ModelSettings.StopSequences = append([]string(nil), o.StopSequences...)
+	}
+	if o.Seed != nil {
+		profile.ModelSettings.Seed = *o.Seed
+	}
+	if o.RateLimit != nil {
+		profile.RateLimit = *o.RateLimit
+	}
+	return profile
+}
+
+// modelBaseName returns a model name without its tag, e.g. "llama3" for "llama3:8b".
+func modelBaseName(model string) string {
+	if i := strings.LastIndex(model, ":"); i >= 0 {
+		return model[:i]
+	}
+	return model
+}
+
+// readEnvOverrides parses the GOLLAMA_* override variables that are set.
+func readEnvOverrides() (envOverrides, error) {
+	var o envOverrides
+
+	parseInt := func(name string) (*int, error) {
+		s := os.Getenv(EnvPrefix + name)
+		if s == "" {
+			return nil, nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s%s: %w", EnvPrefix, name, err)
+		}
+		return &n, nil
+	}
+
+	var err error
+	if o.MaxRetries, err = parseInt("MAX_RETRIES"); err != nil {
+		return o, err
+	}
+	if o.RateLimit, err = parseInt("RATE_LIMIT"); err != nil {
+		return o, err
+	}
+	if o.MaxTokens, err = parseInt("MAX_TOKENS"); err != nil {
+		return o, err
+	}
+	if s := os.Getenv(EnvPrefix + "TIMEOUT"); s != "" {
+		d, err := toDuration(s)
+		if err != nil {
+			return o, fmt.Errorf("invalid %sTIMEOUT: %w", EnvPrefix, err)
+		}
+		o.Timeout = &d
+	}
+	if s := os.Getenv(EnvPrefix + "TEMPERATURE"); s != "" {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return o, fmt.Errorf("invalid %sTEMPERATURE: %w", EnvPrefix, err)
+		}
+		o.Temperature = &f
+	}
+	return o, nil
+}
+
+// apply returns profile with the overrides applied.
+func (o envOverrides) apply(profile ConfigProfile) ConfigProfile {
+	if o.MaxRetries != nil {
+		profile.MaxRetries = *o.MaxRetries
+	}
+	if o.Timeout != nil {
+		profile.Timeout = *o.Timeout
+	}
+	if o.RateLimit != nil {
+		profile.RateLimit = *o.RateLimit
+	}
+	if o.Temperature != nil {
+		profile.ModelSettings.Temperature = *o.Temperature
+	}
+	if o.MaxTokens != nil {
+		profile.ModelSettings.MaxTokens = *o.MaxTokens
+	}
+	return profile
+}
+
+// toDuration converts a duration string such as "5s" or a number of seconds to a time.Duration.
+func toDuration(v interface{}) (time.Duration, error) {
+	switch v := v.(type) {
+	case string:
+		return time.ParseDuration(v)
+	case int:
+		return time.Duration(v) * time.Second, nil
+	case float64:
+		return time.Duration(v * float64(time.Second)), nil
+	}
+	return 0, fmt.Errorf("expected a duration, got %v", v)
+}
+
+// toStrings converts a list of strings decoded from a config file to a []string.
+func toStrings(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case []string:
+		return v, nil
+	case []interface{}:
+		strs := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a list of strings, got %v", item)
+			}
+			strs[i] = s
+		}
+		return strs, nil
+	}
+	return nil, fmt.Errorf("expected a list of strings, got %v", v)
+}
+
+// toInt converts an integer, or a float64 with no fractional part, to an int.
+func toInt(v interface{}) (int, bool) {
+	switch v := v.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		if v == float64(int(v)) {
+			return int(v), true
+		}
+	}
+	return 0, false
+}
+
+// toFloat converts an int or float64 to a float64.
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// decodeJSON decodes a JSON object, keeping integer literals as ints so that
+// values decode the same way as from YAML and TOML.
+func decodeJSON(data []byte) (map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var raw map[string]interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	return normalizeJSON(raw).(map[string]interface{}), nil
+}
+
+// normalizeJSON replaces json.Number values with ints or float64s.
+func normalizeJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = normalizeJSON(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeJSON(item)
+		}
+	case json.Number:
+		if n, err := strconv.Atoi(v.String()); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
@@ -0,0 +1,516 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/h2co32/gollama/pkg/secrets"
+)
+
+// writeConfigFile writes content to a file with the given name in a temporary directory.
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadFormats(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": `
+profile: staging
+profiles:
+  staging:
+    max_retries: 4
+    timeout: 7s
+    rate_limit: 50
+    model_settings:
+      temperature: 0.3
+      max_tokens: 512
+      stop: ["</s>", "User:"]
+`,
+		"config.json": `{
+  "profile": "staging",
+  "profiles": {
+    "staging": {
+      "max_retries": 4,
+      "timeout": "7s",
+      "rate_limit": 50,
+      "model_settings": {"temperature": 0.3, "max_tokens": 512, "stop": ["</s>", "User:"]}
+    }
+  }
+}`,
+		"config.toml": `
+profile = "staging" # selected at startup
+
+[profiles.staging]
+max_retries = 4
+timeout = "7s"
+rate_limit = 50
+
+[profiles.staging.model_settings]
+temperature = 0.3
+max_tokens = 512
+stop = ["</s>", "User:"]
+`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := Load(writeConfigFile(t, name, content))
+			if err != nil {
+				t.Fatalf("Failed to load config: %v", err)
+			}
+
+			if cfg.ActiveName() != "staging" {
+				t.Errorf("Expected active profile 'staging', got %q", cfg.ActiveName())
+			}
+			profile := cfg.Active()
+			if profile.MaxRetries != 4 || profile.Timeout != 7*time.Second || profile.RateLimit != 50 {
+				t.Errorf("Unexpected profile values: %+v", profile)
+			}
+			settings := profile.ModelSettings
+			if settings.Temperature != 0.3 || settings.MaxTokens != 512 || len(settings.StopSequences) != 2 || settings.StopSequences[1] != "User:" {
+				t.Errorf("Unexpected model settings: %+v", settings)
+			}
+		})
+	}
+}
+
+func TestLoadProfilesAndUse(t *testing.T) {
+	cfg, err := Load(writeConfigFile(t, "config.yaml", `
+profiles:
+  production:
+    rate_limit: 200
+`))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if names := strings.Join(cfg.Profiles(), ","); names != "default,production" {
+		t.Errorf("Expected profiles default,production, got %s", names)
+	}
+	if cfg.ActiveName() != DefaultProfileName {
+		t.Errorf("Expected active profile %q, got %q", DefaultProfileName, cfg.ActiveName())
+	}
+
+	// Built-in profiles keep the values the file does not override
+	if err := cfg.Use(ProductionProfileName); err != nil {
+		t.Fatalf("Failed to select profile: %v", err)
+	}
+	profile := cfg.Active()
+	if profile.RateLimit != 200 || profile.MaxRetries != ProductionProfile.MaxRetries {
+		t.Errorf("Unexpected production profile: %+v", profile)
+	}
+	if ProductionProfile.RateLimit != 100 {
+		t.Errorf("Expected ProductionProfile to be unchanged, got rate limit %d", ProductionProfile.RateLimit)
+	}
+
+	if err := cfg.Use("missing"); err == nil {
+		t.Error("Expected error selecting a missing profile, got nil")
+	}
+}
+
+func TestLoadEnvOverrides(t *testing.T) {
+	t.Setenv("GOLLAMA_PROFILE", "production")
+	t.Setenv("GOLLAMA_MAX_RETRIES", "9")
+	t.Setenv("GOLLAMA_TIMEOUT", "30s")
+	t.Setenv("GOLLAMA_TEMPERATURE", "1.2")
+
+	cfg, err := Load(writeConfigFile(t, "config.json", `{}`))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if cfg.ActiveName() != ProductionProfileName {
+		t.Errorf("Expected GOLLAMA_PROFILE to select production, got %q", cfg.ActiveName())
+	}
+	profile := cfg.Active()
+	if profile.MaxRetries != 9 || profile.Timeout != 30*time.Second {
+		t.Errorf("Expected overrides to apply, got %+v", profile)
+	}
+	if profile.ModelSettings.Temperature != 1.2 {
+		t.Errorf("Expected temperature 1.2, got %v", profile.ModelSettings.Temperature)
+	}
+	if profile.RateLimit != ProductionProfile.RateLimit {
+		t.Errorf("Expected rate limit %d, got %d", ProductionProfile.RateLimit, profile.RateLimit)
+	}
+
+	t.Setenv("GOLLAMA_MAX_RETRIES", "many")
+	if _, err := Load(writeConfigFile(t, "config.json", `{}`)); err == nil {
+		t.Error("Expected error for invalid GOLLAMA_MAX_RETRIES, got nil")
+	}
+}
+
+func TestLoadLayeredProfiles(t *testing.T) {
+	cfg, err := Load(writeConfigFile(t, "config.json", `{
+  "profile": "canary",
+  "profiles": {
+    "canary": {"base": "staging", "max_retries": 1},
+    "staging": {
+      "base": "production",
+      "rate_limit": 50,
+      "models": {"llama3:70b": {"max_tokens": 8192, "rate_limit": 5}}
+    }
+  },
+  "models": {
+    "llama3": {"temperature": 0.2, "rate_limit": 20},
+    "llama3:70b": {"max_tokens": 4096}
+  }
+}`))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	// canary inherits from staging, which inherits from production
+	profile := cfg.Active()
+	if profile.MaxRetries != 1 || profile.RateLimit != 50 || profile.Timeout != ProductionProfile.Timeout {
+		t.Errorf("Unexpected layered profile: %+v", profile)
+	}
+
+	// Shared overrides apply to every tag of a model
+	profile = cfg.Resolve("llama3:8b")
+	if profile.ModelSettings.Temperature != 0.2 || profile.RateLimit != 20 {
+		t.Errorf("Unexpected profile for llama3:8b: %+v", profile)
+	}
+	if profile.ModelSettings.MaxTokens != ProductionProfile.ModelSettings.MaxTokens {
+		t.Errorf("Expected max tokens %d, got %d", ProductionProfile.ModelSettings.MaxTokens, profile.ModelSettings.MaxTokens)
+	}
+
+	// Only staging has per-profile model overrides, so canary sees the shared ones
+	profile = cfg.Resolve("llama3:70b")
+	if profile.ModelSettings.MaxTokens != 4096 || profile.RateLimit != 20 {
+		t.Errorf("Unexpected canary profile for llama3:70b: %+v", profile)
+	}
+
+	if err := cfg.Use("staging"); err != nil {
+		t.Fatalf("Failed to select profile: %v", err)
+	}
+	profile = cfg.Resolve("llama3:70b")
+	if profile.ModelSettings.MaxTokens != 8192 || profile.RateLimit != 5 || profile.ModelSettings.Temperature != 0.2 {
+		t.Errorf("Unexpected staging profile for llama3:70b: %+v", profile)
+	}
+
+	// Environment overrides apply after model overrides
+	t.Setenv("GOLLAMA_TEMPERATURE", "0.9")
+	cfg, err = Load(writeConfigFile(t, "config.json", `{"models": {"llama3": {"temperature": 0.2}}}`))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if temp := cfg.Resolve("llama3").ModelSettings.Temperature; temp != 0.9 {
+		t.Errorf("Expected environment temperature 0.9, got %v", temp)
+	}
+}
+
+func TestLoadTOMLQuotedDottedKeys(t *testing.T) {
+	cfg, err := Load(writeConfigFile(t, "config.toml", `
+profile = "staging"
+
+[models."llama3.1:8b"]
+temperature = 0.4
+
+[profiles.staging.models.'qwen2.5']
+max_tokens = 256
+`))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if temp := cfg.Resolve("llama3.1:8b").ModelSettings.Temperature; temp != 0.4 {
+		t.Errorf("Expected temperature 0.4 for llama3.1:8b, got %v", temp)
+	}
+	if maxTokens := cfg.Resolve("qwen2.5").ModelSettings.MaxTokens; maxTokens != 256 {
+		t.Errorf("Expected max tokens 256 for qwen2.5, got %d", maxTokens)
+	}
+
+	for _, header := range []string{`[models."llama3.1]`, `[models."llama3"x]`, `[models.llama 3]`} {
+		if _, err := Load(writeConfigFile(t, "config.toml", header+"\ntemperature = 0.4\n")); err == nil {
+			t.Errorf("Expected error for table %s, got nil", header)
+		}
+	}
+}
+
+func TestProfilesDoNotShareStopSequences(t *testing.T) {
+	cfg, err := Load(writeConfigFile(t, "config.json", `{
+  "profiles": {
+    "base": {"model_settings": {"stop": ["User:", "###"]}},
+    "child": {"base": "base"}
+  }
+}`))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Changing a returned profile's stop sequences leaves the stored profiles alone
+	profile, err := cfg.Profile("base")
+	if err != nil {
+		t.Fatalf("Failed to get profile: %v", err)
+	}
+	profile.ModelSettings.StopSequences[0] = "changed"
+	if err := cfg.Use("child"); err != nil {
+		t.Fatalf("Failed to select profile: %v", err)
+	}
+	resolved := cfg.Resolve("llama3")
+	resolved.ModelSettings.StopSequences[1] = "changed"
+
+	for _, name := range []string{"base", "child"} {
+		profile, _ := cfg.Profile(name)
+		if stops := profile.ModelSettings.StopSequences; len(stops) != 2 || stops[0] != "User:" || stops[1] != "###" {
+			t.Errorf("Expected profile %s to keep its stop sequences, got %v", name, stops)
+		}
+	}
+}
+
+func TestLoadValidation(t *testing.T) {
+	invalid := map[string]string{
+		"negative retries":   `{"profiles": {"default": {"max_retries": -1}}}`,
+		"zero rate limit":    `{"profiles": {"default": {"rate_limit": 0}}}`,
+		"bad timeout":        `{"profiles": {"default": {"timeout": "soon"}}}`,
+		"temperature range":  `{"profiles": {"default": {"model_settings": {"temperature": 3}}}}`,
+		"fractional tokens":  `{"profiles": {"default": {"model_settings": {"max_tokens": 1.5}}}}`,
+		"model override":     `{"models": {"llama3": {"temperature": 5}}}`,
+		"empty stop":         `{"models": {"llama3": {"stop": [""]}}}`,
+		"negative top_k":     `{"profiles": {"default": {"model_settings": {"top_k": -1}}}}`,
+		"circular base":      `{"profiles": {"a": {"base": "b"}, "b": {"base": "a"}}}`,
+		"missing base":       `{"profiles": {"a": {"base": "b"}}}`,
+		"unknown field":      `{"profiles": {"default": {"retries": 1}}}`,
+		"missing profile":    `{"profile": "staging"}`,
+		"non-string profile": `{"profile": 1}`,
+		"provider type":      `{"providers": {"a": {"url": "http://a"}}}`,
+		"provider secret":    `{"providers": {"a": {"type": "openai", "api_key": "missing"}}}`,
+		"unknown provider":   `{"models": {"llama3": {"provider": "missing"}}}`,
+		"default provider":   `{"default_provider": "missing"}`,
+		"settings provider":  `{"providers": {"a": {"type": "ollama"}}, "profiles": {"default": {"model_settings": {"provider": "a"}}}}`,
+		"failover providers": `{"providers": {"a": {"type": "failover"}}}`,
+		"policy reference":   `{"providers": {"a": {"type": "failover", "providers": ["missing"]}}}`,
+		"circular policy":    `{"providers": {"a": {"type": "failover", "providers": ["b"]}, "b": {"type": "split", "weights": {"a": 1}}}}`,
+		"split weights":      `{"providers": {"a": {"type": "ollama"}, "b": {"type": "split", "weights": {"a": 0}}}}`,
+		"negative weight":    `{"providers": {"a": {"type": "ollama"}, "b": {"type": "split", "weights": {"a": -1}}}}`,
+		"shadow primary":     `{"providers": {"a": {"type": "ollama"}, "b": {"type": "shadow", "shadow": "a"}}}`,
+		"negative cost":      `{"models": {"llama3": {"cost": {"prompt": -1}}}}`,
+		"cost field":         `{"models": {"llama3": {"cost": {"input": 1}}}}`,
+		"profile cost":       `{"profiles": {"default": {"model_settings": {"cost": {"prompt": 1}}}}}`,
+		"sample rate":        `{"providers": {"a": {"type": "ollama"}, "b": {"type": "shadow", "primary": "a", "shadow": "a", "sample_rate": 2}}}`,
+		"negative quota":     `{"quotas": {"default": {"daily_tokens": -1}}}`,
+		"quota field":        `{"quotas": {"acme": {"hourly_tokens": 1}}}`,
+		"quota table":        `{"quotas": {"acme": 100}}`,
+	}
+	for name, content := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(writeConfigFile(t, "config.json", content)); err == nil {
+				t.Error("Expected validation error, got nil")
+			}
+		})
+	}
+
+	if _, err := Load(writeConfigFile(t, "config.ini", "")); err == nil {
+		t.Error("Expected error for unsupported format, got nil")
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Expected error for missing file, got nil")
+	}
+}
+
+func TestLoadSecretReferences(t *testing.T) {
+	cfg, err := Load(writeConfigFile(t, "config.json", `{"secrets": {"jwt": "static:jwt-key"}}`))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if ref, ok := cfg.SecretRef("jwt"); !ok || ref != "static:jwt-key" {
+		t.Errorf("Expected reference static:jwt-key, got %q", ref)
+	}
+
+	provider := secrets.SchemeProvider{"static": secrets.StaticProvider{"jwt-key": "resolved"}}
+	value, err := cfg.Secret(context.Background(), provider, "jwt")
+	if err != nil {
+		t.Fatalf("Failed to resolve secret: %v", err)
+	}
+	if value != "resolved" {
+		t.Errorf("Expected resolved, got %q", value)
+	}
+
+	if _, err := cfg.Secret(context.Background(), provider, "hmac"); !errors.Is(err, secrets.ErrSecretNotFound) {
+		t.Errorf("Expected ErrSecretNotFound for an unconfigured secret, got %v", err)
+	}
+
+	if _, err := Load(writeConfigFile(t, "config.json", `{"secrets": {"jwt": 42}}`)); err == nil {
+		t.Error("Expected error for a non-string secret reference")
+	}
+}
+
+func TestLoadProviders(t *testing.T) {
+	cfg, err := Load(writeConfigFile(t, "config.yaml", `
+secrets:
+  openai: env:OPENAI_API_KEY
+providers:
+  local:
+    type: ollama
+    url: http://localhost:11434
+  openai:
+    type: OpenAI
+    url: https://api.openai.com/v1
+    api_key: openai
+  gpu:
+    type: llamacpp
+    url: http://gpu1:8080
+default_provider: local
+models:
+  gpt-4o-mini:
+    provider: openai
+  mistral:
+    provider: gpu
+    cost: {prompt: 0.1, completion: 0.3}
+profiles:
+  staging:
+    models:
+      mistral:
+        provider: openai
+        cost:
+          prompt: 0.15
+          completion: 0.6
+`))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	providers := cfg.Providers()
+	if len(providers) != 3 || providers["openai"].Type != "openai" || providers["openai"].APIKey != "openai" {
+		t.Errorf("Unexpected providers %+v", providers)
+	}
+	if cfg.DefaultProvider() != "local" {
+		t.Errorf("Expected default provider local, got %q", cfg.DefaultProvider())
+	}
+
+	tests := map[string]string{
+		"gpt-4o-mini": "openai",
+		"mistral:7b":  "gpu",
+		"llama3":      "local",
+	}
+	for model, expected := range tests {
+		if got := cfg.ModelProvider(model); got != expected {
+			t.Errorf("Expected provider %s for %s, got %s", expected, model, got)
+		}
+	}
+
+	if err := cfg.Use("staging"); err != nil {
+		t.Fatalf("Failed to use profile: %v", err)
+	}
+	if got := cfg.ModelProvider("mistral"); got != "openai" {
+		t.Errorf("Expected the staging profile to move mistral to openai, got %s", got)
+	}
+	if assigned := cfg.ModelProviders(); assigned["mistral"] != "openai" || assigned["gpt-4o-mini"] != "openai" {
+		t.Errorf("Unexpected model providers %v", assigned)
+	}
+	if costs := cfg.ModelCosts(); len(costs) != 1 || costs["mistral"] != (ModelCost{Prompt: 0.15, Completion: 0.6}) {
+		t.Errorf("Expected the staging cost of mistral, got %v", costs)
+	}
+}
+
+func TestLoadProviderPolicies(t *testing.T) {
+	cfg, err := Load(writeConfigFile(t, "config.yaml", `
+providers:
+  a:
+    type: ollama
+  b:
+    type: llamacpp
+  resilient:
+    type: failover
+    providers: [a, b]
+    timeout: 10s
+  compare:
+    type: split
+    weights:
+      a: 90
+      b: 10
+  mirrored:
+    type: shadow
+    primary: resilient
+    shadow: compare
+    sample_rate: 0.25
+`))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	providers := cfg.Providers()
+	if failover := providers["resilient"]; len(failover.Providers) != 2 || failover.Providers[1] != "b" || failover.Timeout != 10*time.Second {
+		t.Errorf("Unexpected failover policy %+v", failover)
+	}
+	if split := providers["compare"]; split.Weights["a"] != 90 || split.Weights["b"] != 10 {
+		t.Errorf("Unexpected split policy %+v", split)
+	}
+	if shadow := providers["mirrored"]; shadow.Primary != "resilient" || shadow.Shadow != "compare" || shadow.SampleRate != 0.25 {
+		t.Errorf("Unexpected shadow policy %+v", shadow)
+	}
+}
+
+func TestLoadQuotas(t *testing.T) {
+	cfg, err := Load(writeConfigFile(t, "config.yaml", `
+quotas:
+  default:
+    daily_requests: 1000
+  acme:
+    daily_tokens: 200000
+    monthly_tokens: 5000000
+`))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	quotas := cfg.Quotas()
+	if len(quotas) != 2 || quotas[DefaultQuotaName] != (Quota{DailyRequests: 1000}) {
+		t.Errorf("Unexpected quotas %+v", quotas)
+	}
+	if expected := (Quota{DailyTokens: 200000, MonthlyTokens: 5000000}); quotas["acme"] != expected {
+		t.Errorf("Expected %+v, got %+v", expected, quotas["acme"])
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+profiles:
+  default:
+    rate_limit: 50
+quotas:
+  acme:
+    daily_requests: 10
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	os.WriteFile(path, []byte(`
+profiles:
+  default:
+    rate_limit: 80
+quotas:
+  acme:
+    daily_requests: 20
+`), 0644)
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+	if limit := cfg.Active().RateLimit; limit != 80 {
+		t.Errorf("Expected the reloaded rate limit 80, got %d", limit)
+	}
+	if quota := cfg.Quotas()["acme"]; quota.DailyRequests != 20 {
+		t.Errorf("Expected the reloaded quota, got %+v", quota)
+	}
+
+	// An invalid file leaves the config unchanged
+	os.WriteFile(path, []byte("profiles:\n  default:\n    rate_limit: 0\n"), 0644)
+	if err := cfg.Reload(); err == nil {
+		t.Error("Expected error for an invalid file")
+	}
+	if limit := cfg.Active().RateLimit; limit != 80 {
+		t.Errorf("Expected the rate limit to stay 80, got %d", limit)
+	}
+}
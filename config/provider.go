@@ -0,0 +1,257 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Routing policy types, which serve requests from other providers instead of a backend.
+const (
+	ProviderTypeFailover = "failover" // Tries Providers in order until one succeeds
+	ProviderTypeSplit    = "split"    // Sends each request to one of Weights' providers at random
+	ProviderTypeShadow   = "shadow"   // Serves requests from Primary and mirrors them to Shadow
+)
+
+// ProviderConfig configures a model backend, or a routing policy between other
+// providers, that requests can be routed to.
+type ProviderConfig struct {
+	Type   string // Backend type, such as "ollama", "openai", or "llamacpp", or policy type
+	URL    string // Base URL of the backend's API
+	APIKey string // Name of the secret holding the API key, from the secrets table
+
+	Providers  []string       // Providers a failover policy tries, in order
+	Timeout    time.Duration  // Time a failover policy waits for a provider's first response
+	Weights    map[string]int // Relative share of requests a split policy sends to each provider
+	Primary    string         // Provider serving the requests of a shadow policy
+	Shadow     string         // Provider a shadow policy mirrors requests to
+	SampleRate float64        // Fraction of requests a shadow policy mirrors, between 0 and 1
+}
+
+// references returns the names of the providers a routing policy serves requests from.
+func (p ProviderConfig) references() []string {
+	names := append([]string(nil), p.Providers...)
+	for name := range p.Weights {
+		names = append(names, name)
+	}
+	for _, name := range []string{p.Primary, p.Shadow} {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Providers returns the configured providers by name.
+func (c *Config) Providers() map[string]ProviderConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	providers := make(map[string]ProviderConfig, len(c.providers))
+	for name, provider := range c.providers {
+		providers[name] = provider
+	}
+	return providers
+}
+
+// DefaultProvider returns the name of the provider serving models that are not assigned
+// one, or an empty string if there is none.
+func (c *Config) DefaultProvider() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.defaultProvider
+}
+
+// ModelProviders returns the provider assigned to each model in the per-model overrides
+// of the active profile, keyed by model name as written in the config file.
+func (c *Config) ModelProviders() map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	assigned := make(map[string]string)
+	for _, overrides := range []map[string]ModelOverride{c.models, c.profileModels[c.active]} {
+		for model, o := range overrides {
+			if o.Provider != "" {
+				assigned[model] = o.Provider
+			}
+		}
+	}
+	return assigned
+}
+
+// ModelProvider returns the name of the provider serving model with the active profile,
+// layering per-model overrides like Resolve, or the default provider if none assigns
+// one.
+func (c *Config) ModelProvider(model string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	provider := c.defaultProvider
+	for _, overrides := range []map[string]ModelOverride{c.models, c.profileModels[c.active]} {
+		if base := modelBaseName(model); base != model {
+			if o, ok := overrides[base]; ok && o.Provider != "" {
+				provider = o.Provider
+			}
+		}
+		if o, ok := overrides[model]; ok && o.Provider != "" {
+			provider = o.Provider
+		}
+	}
+	return provider
+}
+
+// decodeProviders decodes the providers table, keyed by provider name.
+func decodeProviders(value interface{}) (map[string]ProviderConfig, error) {
+	table, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("providers must be a table of named providers")
+	}
+
+	providers := make(map[string]ProviderConfig, len(table))
+	for name, fields := range table {
+		settings, ok := fields.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("providers.%s must be a table", name)
+		}
+		var p ProviderConfig
+		for key, value := range settings {
+			switch key {
+			case "type", "url", "api_key", "primary", "shadow":
+				s, ok := value.(string)
+				if !ok {
+					return nil, fmt.Errorf("providers.%s: %s must be a string, got %v", name, key, value)
+				}
+				switch key {
+				case "type":
+					p.Type = strings.ToLower(s)
+				case "url":
+					p.URL = s
+				case "api_key":
+					p.APIKey = s
+				case "primary":
+					p.Primary = s
+				case "shadow":
+					p.Shadow = s
+				}
+			case "providers":
+				names, err := toStrings(value)
+				if err != nil {
+					return nil, fmt.Errorf("providers.%s: providers: %w", name, err)
+				}
+				p.Providers = names
+			case "timeout":
+				d, err := toDuration(value)
+				if err != nil {
+					return nil, fmt.Errorf("providers.%s: timeout: %w", name, err)
+				}
+				p.Timeout = d
+			case "weights":
+				weights, ok := value.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("providers.%s: weights must be a table of provider weights", name)
+				}
+				p.Weights = make(map[string]int, len(weights))
+				for provider, weight := range weights {
+					w, ok := toInt(weight)
+					if !ok || w < 0 {
+						return nil, fmt.Errorf("providers.%s: weight of %s must be a non-negative integer, got %v", name, provider, weight)
+					}
+					p.Weights[provider] = w
+				}
+			case "sample_rate":
+				rate, ok := toFloat(value)
+				if !ok || rate < 0 || rate > 1 {
+					return nil, fmt.Errorf("providers.%s: sample_rate must be a number between 0 and 1, got %v", name, value)
+				}
+				p.SampleRate = rate
+			default:
+				return nil, fmt.Errorf("providers.%s: unknown field %s", name, key)
+			}
+		}
+		if p.Type == "" {
+			return nil, fmt.Errorf("providers.%s: type is required", name)
+		}
+		providers[name] = p
+	}
+	return providers, nil
+}
+
+// validateProviders checks that the providers, and the secrets and providers they and
+// the per-model overrides refer to, are configured, and that routing policies do not
+// refer to themselves.
+func (c *Config) validateProviders() error {
+	for name, p := range c.providers {
+		if _, ok := c.secrets[p.APIKey]; p.APIKey != "" && !ok {
+			return fmt.Errorf("providers.%s: api_key secret %s not found", name, p.APIKey)
+		}
+		switch p.Type {
+		case ProviderTypeFailover:
+			if len(p.Providers) == 0 {
+				return fmt.Errorf("providers.%s: failover requires providers", name)
+			}
+		case ProviderTypeSplit:
+			total := 0
+			for _, weight := range p.Weights {
+				total += weight
+			}
+			if total == 0 {
+				return fmt.Errorf("providers.%s: split requires weights", name)
+			}
+		case ProviderTypeShadow:
+			if p.Primary == "" || p.Shadow == "" {
+				return fmt.Errorf("providers.%s: shadow requires primary and shadow", name)
+			}
+		}
+		for _, ref := range p.references() {
+			if _, ok := c.providers[ref]; !ok {
+				return fmt.Errorf("providers.%s: provider %s not found", name, ref)
+			}
+		}
+	}
+
+	// Policies are built from the providers they refer to, so those must not lead back
+	state := make(map[string]int) // 1 while visiting a provider's references, 2 once done
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("providers.%s: circular provider reference", name)
+		case 2:
+			return nil
+		}
+		state[name] = 1
+		for _, ref := range c.providers[name].references() {
+			if err := visit(ref); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		return nil
+	}
+	for name := range c.providers {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+	if _, ok := c.providers[c.defaultProvider]; c.defaultProvider != "" && !ok {
+		return fmt.Errorf("default_provider %s not found", c.defaultProvider)
+	}
+
+	check := func(models map[string]ModelOverride) error {
+		for model, o := range models {
+			if _, ok := c.providers[o.Provider]; o.Provider != "" && !ok {
+				return fmt.Errorf("models.%s: provider %s not found", model, o.Provider)
+			}
+		}
+		return nil
+	}
+	if err := check(c.models); err != nil {
+		return err
+	}
+	for name, models := range c.profileModels {
+		if err := check(models); err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+	}
+	return nil
+}
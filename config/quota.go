@@ -0,0 +1,65 @@
+package config
+
+import "fmt"
+
+// DefaultQuotaName is the quotas entry applied to tenants without one of their own.
+const DefaultQuotaName = "default"
+
+// Quota limits a tenant's requests and tokens per UTC day and per UTC calendar month.
+// Zero fields are unlimited.
+type Quota struct {
+	DailyRequests   int64
+	DailyTokens     int64
+	MonthlyRequests int64
+	MonthlyTokens   int64
+}
+
+// Quotas returns the quotas keyed by tenant, such as the name of an API key, including
+// the DefaultQuotaName entry if there is one.
+func (c *Config) Quotas() map[string]Quota {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	quotas := make(map[string]Quota, len(c.quotas))
+	for tenant, quota := range c.quotas {
+		quotas[tenant] = quota
+	}
+	return quotas
+}
+
+// decodeQuotas decodes the quotas table, keyed by tenant.
+func decodeQuotas(value interface{}) (map[string]Quota, error) {
+	table, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("quotas must be a table of tenant quotas")
+	}
+
+	quotas := make(map[string]Quota, len(table))
+	for tenant, fields := range table {
+		limits, ok := fields.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("quotas.%s must be a table", tenant)
+		}
+		var q Quota
+		for key, value := range limits {
+			n, ok := toInt(value)
+			if !ok || n < 0 {
+				return nil, fmt.Errorf("quotas.%s: %s must be a non-negative integer, got %v", tenant, key, value)
+			}
+			switch key {
+			case "daily_requests":
+				q.DailyRequests = int64(n)
+			case "daily_tokens":
+				q.DailyTokens = int64(n)
+			case "monthly_requests":
+				q.MonthlyRequests = int64(n)
+			case "monthly_tokens":
+				q.MonthlyTokens = int64(n)
+			default:
+				return nil, fmt.Errorf("quotas.%s: unknown field %s", tenant, key)
+			}
+		}
+		quotas[tenant] = q
+	}
+	return quotas, nil
+}
@@ -0,0 +1,239 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// decodeTOML decodes the subset of TOML used by config files: [table] and
+// [dotted.table] headers and key = value pairs whose values are strings, integers,
+// floats, booleans, or single-line arrays of those. Inline tables and multi-line
+// strings are not supported.
+func decodeTOML(data []byte) (map[string]interface{}, error) {
+	root := make(map[string]interface{})
+	current := root
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(stripTOMLComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") || strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("line %d: invalid table header %q", lineNum, line)
+			}
+			keys, err := splitTOMLKey(line[1 : len(line)-1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			current, err = tomlTable(root, keys)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			continue
+		}
+
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value, got %q", lineNum, line)
+		}
+		keys, err := splitTOMLKey(line[:eq])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		value, err := parseTOMLValue(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+
+		table, err := tomlTable(current, keys[:len(keys)-1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		key := keys[len(keys)-1]
+		if _, exists := table[key]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key %s", lineNum, key)
+		}
+		table[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// tomlTable returns the nested table at keys below parent, creating missing tables.
+func tomlTable(parent map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	for _, key := range keys {
+		next, exists := parent[key]
+		if !exists {
+			table := make(map[string]interface{})
+			parent[key] = table
+			parent = table
+			continue
+		}
+		table, ok := next.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("key %s is not a table", key)
+		}
+		parent = table
+	}
+	return parent, nil
+}
+
+// splitTOMLKey splits a dotted key into its parts, unquoting quoted parts. Dots in a
+// quoted part belong to it, so models."llama3.1:8b" has two parts.
+func splitTOMLKey(s string) ([]string, error) {
+	var keys []string
+	rest := s
+	for {
+		rest = strings.TrimLeft(rest, " \t")
+		var part string
+		switch {
+		case strings.HasPrefix(rest, `"`):
+			end := closingTOMLQuote(rest)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated key %q", s)
+			}
+			unquoted, err := strconv.Unquote(rest[:end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid key %q: %w", s, err)
+			}
+			part, rest = unquoted, rest[end+1:]
+		case strings.HasPrefix(rest, "'"):
+			end := strings.IndexByte(rest[1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated key %q", s)
+			}
+			part, rest = rest[1:end+1], rest[end+2:]
+		default:
+			end := strings.IndexByte(rest, '.')
+			if end < 0 {
+				end = len(rest)
+			}
+			part, rest = strings.TrimRight(rest[:end], " \t"), rest[end:]
+			if part == "" || strings.ContainsAny(part, "\"' \t") {
+				return nil, fmt.Errorf("invalid key %q", s)
+			}
+		}
+		keys = append(keys, part)
+
+		rest = strings.TrimLeft(rest, " \t")
+		if rest == "" {
+			return keys, nil
+		}
+		if rest[0] != '.' {
+			return nil, fmt.Errorf("invalid key %q", s)
+		}
+		rest = rest[1:]
+	}
+}
+
+// closingTOMLQuote returns the index of the quote closing the basic string at the
+// start of s, skipping escaped quotes, or -1 if it is unterminated.
+func closingTOMLQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// parseTOMLValue parses a string, integer, float, or boolean value.
+func parseTOMLValue(s string) (interface{}, error) {
+	switch {
+	case s == "":
+		return nil, fmt.Errorf("missing value")
+	case s == "true":
+		return true, nil
+	case s == "false":
+		return false, nil
+	case s[0] == '"':
+		return strconv.Unquote(s)
+	case s[0] == '\'':
+		if len(s) < 2 || s[len(s)-1] != '\'' {
+			return nil, fmt.Errorf("unterminated string %s", s)
+		}
+		return s[1 : len(s)-1], nil
+	case s[0] == '[':
+		return parseTOMLArray(s)
+	case s[0] == '{':
+		return nil, fmt.Errorf("inline tables are not supported")
+	}
+
+	number := strings.ReplaceAll(s, "_", "")
+	if n, err := strconv.Atoi(number); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(number, 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("invalid value %s", s)
+}
+
+// parseTOMLArray parses a single-line array of scalar values.
+func parseTOMLArray(s string) ([]interface{}, error) {
+	if !strings.HasSuffix(s, "]") {
+		return nil, fmt.Errorf("unterminated array %s", s)
+	}
+
+	items := []interface{}{}
+	var quote byte
+	start := 1
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			return nil, fmt.Errorf("nested arrays are not supported")
+		case c == ',' || i == len(s)-1:
+			item := strings.TrimSpace(s[start:i])
+			start = i + 1
+			if item == "" && i == len(s)-1 {
+				continue // Empty array or trailing comma
+			}
+			value, err := parseTOMLValue(item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+	}
+	return items, nil
+}
+
+// stripTOMLComment removes a trailing # comment that is not inside a string.
+func stripTOMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,433 @@
+// Package admin provides an authenticated HTTP API for operating a running gateway
+// without shell access to its hosts: managing models, inspecting the load balancer's
+// backends, adjusting the rate limit, purging caches, and reloading the config file.
+//
+// Each endpoint is only served when the component it manages is configured:
+//
+//	GET    /admin/models                            models in storage; ?name= and ?loaded=true filter them
+//	POST   /admin/models/{name}/download            download {"version": "v1"} and make it current
+//	POST   /admin/models/{name}/rollback            make a stored {"version": "v1"} current again
+//	DELETE /admin/models/{name}/versions/{version}  delete a stored version
+//	GET    /admin/backends                          health, ejection, load, and models of each backend
+//	GET    /admin/rate-limit                        the rate limit
+//	PUT    /admin/rate-limit                        change {"rate": 20, "capacity": 40}
+//	GET    /admin/caches                            names of the caches
+//	POST   /admin/caches/{name}/purge               clear a cache, or only {"keys": ["a", "b"]}
+//	POST   /admin/config/reload                     reload the config file
+//
+// Errors are reported as {"error": "..."}, with 404 Not Found for missing models and
+// caches.
+//
+// Example usage:
+//
+//	api, err := admin.New(admin.Options{
+//		Auth:     middleware.NewAuthMiddleware(middleware.AuthOptions{AuthType: middleware.AuthTypeJWT, JWTSecret: secret}),
+//		Admins:   []string{"ops"},
+//		Models:   models.NewModelManager("./models"),
+//		Balancer: balancer,
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	mux.Handle("/admin/", api)
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/h2co32/gollama/internal/cache"
+	"github.com/h2co32/gollama/internal/loadbalancer"
+	"github.com/h2co32/gollama/internal/models"
+	"github.com/h2co32/gollama/pkg/logging"
+	"github.com/h2co32/gollama/pkg/middleware"
+	"github.com/h2co32/gollama/pkg/ratelimiter"
+)
+
+// maxBodyBytes bounds the request bodies of the API, which are small JSON objects.
+const maxBodyBytes = 1 << 20
+
+// Options configures an API. Auth is required; the endpoints of the other components
+// are not served when they are nil.
+type Options struct {
+	// Auth authenticates every request.
+	Auth *middleware.AuthMiddleware
+
+	// Admins are the subjects, the "sub" claims of authenticated clients, allowed to use
+	// the API. Other clients get 403 Forbidden. Optional. By default every client Auth
+	// authenticates is allowed, so Auth must only accept operators.
+	Admins []string
+
+	// Models manages the models in local storage.
+	Models *models.ModelManager
+
+	// Balancer is the load balancer whose backends are reported.
+	Balancer *loadbalancer.LoadBalancer
+
+	// Limiter is the rate limiter whose rate and capacity can be changed.
+	Limiter *ratelimiter.RateLimiter
+
+	// Caches are the caches that can be purged, by name.
+	Caches map[string]cache.Cache
+
+	// Reload reloads the config file and applies the values that can change at runtime.
+	Reload func(ctx context.Context) error
+}
+
+// API serves the admin endpoints.
+type API struct {
+	options Options
+	handler http.Handler
+}
+
+// New creates an API with the given options.
+func New(options Options) (*API, error) {
+	if options.Auth == nil {
+		return nil, fmt.Errorf("admin API requires authentication")
+	}
+
+	a := &API{options: options}
+	mux := http.NewServeMux()
+	if options.Models != nil {
+		mux.HandleFunc("GET /admin/models", a.listModels)
+		mux.HandleFunc("POST /admin/models/{name}/download", a.downloadModel)
+		mux.HandleFunc("POST /admin/models/{name}/rollback", a.rollbackModel)
+		mux.HandleFunc("DELETE /admin/models/{name}/versions/{version}", a.deleteModel)
+		mux.HandleFunc("PUT /admin/models/{name}/versions/{version}/pin", a.pinModel)
+		mux.HandleFunc("DELETE /admin/models/{name}/versions/{version}/pin", a.pinModel)
+	}
+	if options.Balancer != nil {
+		mux.HandleFunc("GET /admin/backends", a.backends)
+	}
+	if options.Limiter != nil {
+		mux.HandleFunc("GET /admin/rate-limit", a.rateLimit)
+		mux.HandleFunc("PUT /admin/rate-limit", a.setRateLimit)
+	}
+	if len(options.Caches) > 0 {
+		mux.HandleFunc("GET /admin/caches", a.caches)
+		mux.HandleFunc("POST /admin/caches/{name}/purge", a.purgeCache)
+	}
+	if options.Reload != nil {
+		mux.HandleFunc("POST /admin/config/reload", a.reload)
+	}
+	a.handler = options.Auth.Middleware(a.authorize(mux))
+	return a, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.handler.ServeHTTP(w, r)
+}
+
+// authorize rejects clients other than Admins, and logs the changes admins make.
+func (a *API) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject := ""
+		if claims, ok := middleware.GetUserFromContext(r.Context()); ok {
+			subject, _ = claims["sub"].(string)
+		}
+		if len(a.options.Admins) > 0 && !slices.Contains(a.options.Admins, subject) {
+			writeError(w, http.StatusForbidden, "admin access required")
+			return
+		}
+		if r.Method != http.MethodGet {
+			logging.Default().InfoContext(r.Context(), "admin request", "subject", subject, "method", r.Method, "path", r.URL.Path)
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeError reports an error as {"error": message}.
+func writeError(w http.ResponseWriter, status int, message string) {
+	middleware.JSONResponse(w, status, map[string]string{"error": message})
+}
+
+// decode reads the JSON request body into v, reporting a 400 Bad Request and returning
+// false if it is invalid.
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return false
+	}
+	return true
+}
+
+// validName reports whether s can be used as a model name or version, which are part of
+// file names in the model directory.
+func validName(s string) bool {
+	return s != "" && s != "." && s != ".." && !strings.ContainsAny(s, `/\`)
+}
+
+// Model is a model version in storage.
+type Model struct {
+	Name     string    `json:"name"`
+	Version  string    `json:"version"`
+	Size     int64     `json:"size"`
+	Checksum string    `json:"checksum,omitempty"`
+	Current  bool      `json:"current"`
+	Loaded   bool      `json:"loaded"`
+	Pinned   bool      `json:"pinned"`
+	LastUsed time.Time `json:"last_used,omitempty"`
+
+	Requests     int64   `json:"requests"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+}
+
+// listModels serves GET /admin/models.
+func (a *API) listModels(w http.ResponseWriter, r *http.Request) {
+	infos, err := a.options.Models.ListModels(models.ListOptions{
+		Name:       r.URL.Query().Get("name"),
+		LoadedOnly: r.URL.Query().Get("loaded") == "true",
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	result := make([]Model, len(infos))
+	for i, info := range infos {
+		result[i] = Model{
+			Name:     info.Name,
+			Version:  info.Version,
+			Size:     info.Size,
+			Checksum: info.Checksum,
+			Current:  info.Current,
+			Loaded:   info.Loaded,
+			Pinned:   info.Pinned,
+			LastUsed: info.LastUsed,
+
+			Requests:     info.Requests,
+			AvgLatencyMs: float64(info.AvgLatency) / float64(time.Millisecond),
+		}
+	}
+	middleware.JSONResponse(w, http.StatusOK, result)
+}
+
+// modelVersion reads the model name from the path and the version from the request
+// body, reporting a 400 Bad Request and returning false if either is invalid.
+func modelVersion(w http.ResponseWriter, r *http.Request) (name, version string, ok bool) {
+	var body struct {
+		Version string `json:"version"`
+	}
+	if !decode(w, r, &body) {
+		return "", "", false
+	}
+	name = r.PathValue("name")
+	if !validName(name) || !validName(body.Version) {
+		writeError(w, http.StatusBadRequest, "a valid model name and version are required")
+		return "", "", false
+	}
+	return name, body.Version, true
+}
+
+// downloadModel serves POST /admin/models/{name}/download.
+func (a *API) downloadModel(w http.ResponseWriter, r *http.Request) {
+	name, version, ok := modelVersion(w, r)
+	if !ok {
+		return
+	}
+	if err := a.options.Models.DownloadModelContext(r.Context(), name, version); err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	middleware.JSONResponse(w, http.StatusOK, map[string]string{"model": name, "version": version})
+}
+
+// rollbackModel serves POST /admin/models/{name}/rollback.
+func (a *API) rollbackModel(w http.ResponseWriter, r *http.Request) {
+	name, version, ok := modelVersion(w, r)
+	if !ok {
+		return
+	}
+	if err := a.options.Models.RollbackModel(name, version); err != nil {
+		writeModelError(w, err)
+		return
+	}
+	middleware.JSONResponse(w, http.StatusOK, map[string]string{"model": name, "version": version})
+}
+
+// deleteModel serves DELETE /admin/models/{name}/versions/{version}.
+func (a *API) deleteModel(w http.ResponseWriter, r *http.Request) {
+	name, version := r.PathValue("name"), r.PathValue("version")
+	if !validName(name) || !validName(version) {
+		writeError(w, http.StatusBadRequest, "a valid model name and version are required")
+		return
+	}
+	if err := a.options.Models.DeleteModel(name, version); err != nil {
+		writeModelError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pinModel serves PUT and DELETE /admin/models/{name}/versions/{version}/pin, which
+// pin and unpin a stored version.
+func (a *API) pinModel(w http.ResponseWriter, r *http.Request) {
+	name, version := r.PathValue("name"), r.PathValue("version")
+	if !validName(name) || !validName(version) {
+		writeError(w, http.StatusBadRequest, "a valid model name and version are required")
+		return
+	}
+	pin := r.Method == http.MethodPut
+	var err error
+	if pin {
+		err = a.options.Models.PinModel(name, version)
+	} else {
+		err = a.options.Models.UnpinModel(name, version)
+	}
+	if err != nil {
+		writeModelError(w, err)
+		return
+	}
+	middleware.JSONResponse(w, http.StatusOK, map[string]interface{}{"model": name, "version": version, "pinned": pin})
+}
+
+// writeModelError reports a failed model operation, as 404 Not Found if the version is
+// not in storage and 409 Conflict if it is pinned.
+func writeModelError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, os.ErrNotExist), errors.Is(err, models.ErrNotRecorded):
+		status = http.StatusNotFound
+	case errors.Is(err, models.ErrPinned):
+		status = http.StatusConflict
+	}
+	writeError(w, status, err.Error())
+}
+
+// Backend is the state of a load balancer backend.
+type Backend struct {
+	Server         string     `json:"server"`
+	Healthy        bool       `json:"healthy"`
+	Ejected        bool       `json:"ejected"`
+	EjectedUntil   *time.Time `json:"ejected_until,omitempty"`
+	Weight         int        `json:"weight"`
+	Active         int        `json:"active"`
+	LatencySeconds float64    `json:"latency_seconds"`
+	LoadedModels   []string   `json:"loaded_models"`
+	Models         []string   `json:"models"`
+}
+
+// backends serves GET /admin/backends.
+func (a *API) backends(w http.ResponseWriter, r *http.Request) {
+	statuses := a.options.Balancer.Status()
+	result := make([]Backend, len(statuses))
+	for i, status := range statuses {
+		result[i] = Backend{
+			Server:         status.Server,
+			Healthy:        status.Healthy,
+			Ejected:        status.Ejected,
+			Weight:         status.Weight,
+			Active:         status.Active,
+			LatencySeconds: status.Latency.Seconds(),
+			LoadedModels:   append([]string{}, status.Inventory.Loaded...),
+			Models:         append([]string{}, status.Inventory.Available...),
+		}
+		if !status.EjectedUntil.IsZero() {
+			result[i].EjectedUntil = &status.EjectedUntil
+		}
+	}
+	middleware.JSONResponse(w, http.StatusOK, result)
+}
+
+// RateLimit is the state of the rate limiter. Rate is the number of requests allowed
+// per second, and Capacity the burst capacity.
+type RateLimit struct {
+	Rate      float64 `json:"rate"`
+	Capacity  float64 `json:"capacity"`
+	Available float64 `json:"available"`
+}
+
+// rateLimit serves GET /admin/rate-limit.
+func (a *API) rateLimit(w http.ResponseWriter, r *http.Request) {
+	limiter := a.options.Limiter
+	middleware.JSONResponse(w, http.StatusOK, RateLimit{
+		Rate:      limiter.Rate() / limiter.Interval().Seconds(),
+		Capacity:  limiter.Capacity(),
+		Available: limiter.Available(),
+	})
+}
+
+// setRateLimit serves PUT /admin/rate-limit. Fields left out keep their values.
+func (a *API) setRateLimit(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Rate     *float64 `json:"rate"`
+		Capacity *float64 `json:"capacity"`
+	}
+	if !decode(w, r, &body) {
+		return
+	}
+	if (body.Rate != nil && *body.Rate <= 0) || (body.Capacity != nil && *body.Capacity <= 0) {
+		writeError(w, http.StatusBadRequest, "rate and capacity must be positive")
+		return
+	}
+
+	limiter := a.options.Limiter
+	if body.Rate != nil {
+		limiter.SetRate(*body.Rate * limiter.Interval().Seconds())
+	}
+	if body.Capacity != nil {
+		limiter.SetCapacity(*body.Capacity)
+	}
+	a.rateLimit(w, r)
+}
+
+// caches serves GET /admin/caches.
+func (a *API) caches(w http.ResponseWriter, r *http.Request) {
+	names := make([]string, 0, len(a.options.Caches))
+	for name := range a.options.Caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	middleware.JSONResponse(w, http.StatusOK, names)
+}
+
+// purgeCache serves POST /admin/caches/{name}/purge. Without a body, or without keys
+// in it, the whole cache is cleared.
+func (a *API) purgeCache(w http.ResponseWriter, r *http.Request) {
+	c, ok := a.options.Caches[r.PathValue("name")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "cache not found")
+		return
+	}
+	// An empty body purges every entry, whether or not the request declared its length
+	var body struct {
+		Keys []string `json:"keys"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+
+	var err error
+	if len(body.Keys) == 0 {
+		err = c.Clear()
+	} else {
+		for _, key := range body.Keys {
+			err = errors.Join(err, c.Delete(key))
+		}
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// reload serves POST /admin/config/reload.
+func (a *API) reload(w http.ResponseWriter, r *http.Request) {
+	if err := a.options.Reload(r.Context()); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to reload config: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
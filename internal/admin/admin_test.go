@@ -0,0 +1,211 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/h2co32/gollama/internal/cache"
+	"github.com/h2co32/gollama/internal/loadbalancer"
+	"github.com/h2co32/gollama/internal/models"
+	"github.com/h2co32/gollama/pkg/auth"
+	"github.com/h2co32/gollama/pkg/middleware"
+	"github.com/h2co32/gollama/pkg/ratelimiter"
+)
+
+const secret = "test-secret"
+
+// do sends a request to api as subject, or unauthenticated if subject is empty.
+func do(t *testing.T, api http.Handler, subject, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if subject != "" {
+		token, err := auth.GenerateJWT(secret, map[string]interface{}{"sub": subject})
+		if err != nil {
+			t.Fatalf("Failed to generate token: %v", err)
+		}
+		req.Header.Set(middleware.AuthHeaderKey, "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	return rec
+}
+
+func newAuth() *middleware.AuthMiddleware {
+	return middleware.NewAuthMiddleware(middleware.AuthOptions{AuthType: middleware.AuthTypeJWT, JWTSecret: secret})
+}
+
+func TestAuthorization(t *testing.T) {
+	if _, err := New(Options{}); err == nil {
+		t.Error("Expected error without authentication")
+	}
+
+	api, err := New(Options{Auth: newAuth(), Admins: []string{"ops"}, Limiter: ratelimiter.New(10, time.Second, 10)})
+	if err != nil {
+		t.Fatalf("Failed to create admin API: %v", err)
+	}
+	for subject, expected := range map[string]int{"": http.StatusUnauthorized, "dev": http.StatusForbidden, "ops": http.StatusOK} {
+		if rec := do(t, api, subject, http.MethodGet, "/admin/rate-limit", ""); rec.Code != expected {
+			t.Errorf("Subject %q: expected status %d, got %d", subject, expected, rec.Code)
+		}
+	}
+
+	// Endpoints of components that are not configured are not served
+	if rec := do(t, api, "ops", http.MethodGet, "/admin/backends", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", rec.Code)
+	}
+}
+
+func TestModels(t *testing.T) {
+	dir := t.TempDir()
+	for _, version := range []string{"v1", "v2"} {
+		if err := os.WriteFile(filepath.Join(dir, "llama-"+version+".bin"), []byte("model"), 0644); err != nil {
+			t.Fatalf("Failed to write model: %v", err)
+		}
+	}
+	api, err := New(Options{Auth: newAuth(), Models: models.NewModelManager(dir)})
+	if err != nil {
+		t.Fatalf("Failed to create admin API: %v", err)
+	}
+
+	rec := do(t, api, "ops", http.MethodPost, "/admin/models/llama/rollback", `{"version": "v1"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = do(t, api, "ops", http.MethodGet, "/admin/models?name=llama", "")
+	var list []Model
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode models: %v", err)
+	}
+	if len(list) != 2 || !list[0].Current || list[1].Current || list[0].Size != 5 {
+		t.Errorf("Unexpected models %+v", list)
+	}
+
+	if rec := do(t, api, "ops", http.MethodDelete, "/admin/models/llama/versions/v2", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d: %s", rec.Code, rec.Body)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "llama-v2.bin")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Expected the version to be deleted")
+	}
+
+	tests := []struct {
+		method, path, body string
+		expected           int
+	}{
+		{http.MethodDelete, "/admin/models/llama/versions/v2", "", http.StatusNotFound},
+		{http.MethodPost, "/admin/models/llama/rollback", `{"version": "v3"}`, http.StatusNotFound},
+		{http.MethodPost, "/admin/models/llama/rollback", `{"version": ".."}`, http.StatusBadRequest},
+		{http.MethodPost, "/admin/models/llama/download", `{}`, http.StatusBadRequest},
+		{http.MethodPost, "/admin/models/llama/download", `not json`, http.StatusBadRequest},
+		{http.MethodPut, "/admin/models/llama/versions/v1/pin", "", http.StatusNotFound}, // Not recorded in the manifest
+	}
+	for _, tt := range tests {
+		if rec := do(t, api, "ops", tt.method, tt.path, tt.body); rec.Code != tt.expected {
+			t.Errorf("%s %s %s: expected status %d, got %d", tt.method, tt.path, tt.body, tt.expected, rec.Code)
+		}
+	}
+}
+
+func TestBackendsAndRateLimit(t *testing.T) {
+	lb := loadbalancer.NewLoadBalancer(context.Background(), []string{"a:11434", "b:11434"}, time.Hour, 1)
+	defer lb.Close()
+	limiter := ratelimiter.New(10, time.Second, 10)
+	api, err := New(Options{Auth: newAuth(), Balancer: lb, Limiter: limiter})
+	if err != nil {
+		t.Fatalf("Failed to create admin API: %v", err)
+	}
+
+	var backends []Backend
+	if err := json.NewDecoder(do(t, api, "ops", http.MethodGet, "/admin/backends", "").Body).Decode(&backends); err != nil {
+		t.Fatalf("Failed to decode backends: %v", err)
+	}
+	if len(backends) != 2 || backends[0].Server != "a:11434" || !backends[0].Healthy {
+		t.Errorf("Unexpected backends %+v", backends)
+	}
+
+	rec := do(t, api, "ops", http.MethodPut, "/admin/rate-limit", `{"rate": 20}`)
+	var limit RateLimit
+	if err := json.NewDecoder(rec.Body).Decode(&limit); err != nil {
+		t.Fatalf("Failed to decode rate limit: %v", err)
+	}
+	if limit.Rate != 20 || limit.Capacity != 10 || limiter.Rate() != 20 {
+		t.Errorf("Expected the rate to change to 20, got %+v", limit)
+	}
+	if rec := do(t, api, "ops", http.MethodPut, "/admin/rate-limit", `{"capacity": -1}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestCachesAndReload(t *testing.T) {
+	c := cache.NewMemoryCache(cache.DefaultMemoryCacheOptions())
+	c.Set("a", []byte("1"), time.Hour)
+	c.Set("b", []byte("2"), time.Hour)
+	reloads := 0
+	api, err := New(Options{
+		Auth:   newAuth(),
+		Caches: map[string]cache.Cache{"responses": c},
+		Reload: func(ctx context.Context) error {
+			reloads++
+			if reloads > 1 {
+				return errors.New("invalid config")
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create admin API: %v", err)
+	}
+
+	if rec := do(t, api, "ops", http.MethodPost, "/admin/caches/responses/purge", `{"keys": ["a"]}`); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", rec.Code)
+	}
+	if data, _ := c.Get("a"); data != nil {
+		t.Error("Expected key a to be purged")
+	}
+	if data, _ := c.Get("b"); data == nil {
+		t.Error("Expected key b to be kept")
+	}
+	do(t, api, "ops", http.MethodPost, "/admin/caches/responses/purge", "")
+	if data, _ := c.Get("b"); data != nil {
+		t.Error("Expected the cache to be cleared")
+	}
+
+	// A chunked request with an empty body has an unknown length, and clears the cache too
+	c.Set("c", []byte("3"), time.Hour)
+	token, err := auth.GenerateJWT(secret, map[string]interface{}{"sub": "ops"})
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/admin/caches/responses/purge", strings.NewReader(""))
+	req.ContentLength = -1
+	req.TransferEncoding = []string{"chunked"}
+	req.Header.Set(middleware.AuthHeaderKey, "Bearer "+token)
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204 for a chunked empty body, got %d: %s", rec.Code, rec.Body)
+	}
+	if data, _ := c.Get("c"); data != nil {
+		t.Error("Expected the cache to be cleared by a chunked request")
+	}
+	if rec := do(t, api, "ops", http.MethodPost, "/admin/caches/responses/purge", "not json"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid body, got %d", rec.Code)
+	}
+
+	if rec := do(t, api, "ops", http.MethodPost, "/admin/caches/missing/purge", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", rec.Code)
+	}
+
+	for i, expected := range []int{http.StatusNoContent, http.StatusInternalServerError} {
+		if rec := do(t, api, "ops", http.MethodPost, "/admin/config/reload", ""); rec.Code != expected {
+			t.Errorf("Reload %d: expected status %d, got %d", i, expected, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+// Package batch coalesces concurrent requests for the same model into batched backend
+// calls, so GPUs process several inputs per forward pass instead of one.
+//
+// A Batcher collects the requests made with Do for each model until it holds
+// MaxBatchSize of them or the oldest has waited MaxWait, then hands them to the batch
+// function in one call. Batches can run as jobs of a queue.JobQueue, sharing its
+// workers, priorities, retries, and metrics, and can be throttled by a rate limiter.
+//
+// Example usage:
+//
+//	embedder, err := batch.NewEmbedBatcher(client, batch.Options{
+//		MaxBatchSize: 32,
+//		MaxWait:      5 * time.Millisecond,
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer embedder.Close()
+//
+//	// Concurrent calls for the same model share one request to Ollama
+//	vector, err := embedder.Do(ctx, "nomic-embed-text", text)
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/h2co32/gollama/internal/queue"
+	"github.com/h2co32/gollama/pkg/ratelimiter"
+)
+
+// tracerName is the instrumentation name of the default tracer.
+const tracerName = "github.com/h2co32/gollama/internal/batch"
+
+// ErrClosed is returned by Do once the Batcher has been closed.
+var ErrClosed = errors.New("batcher closed")
+
+// Func handles a batch of requests for a model. It returns one result per request, in
+// the order of the requests.
+type Func[Req, Res any] func(ctx context.Context, model string, requests []Req) ([]Res, error)
+
+// Options configures a Batcher.
+type Options struct {
+	// MaxBatchSize is the most requests in a batch. A batch runs as soon as it is full.
+	// Default: 16
+	MaxBatchSize int
+
+	// MaxWait is the longest a request waits for others to join its batch.
+	// Default: 10ms
+	MaxWait time.Duration
+
+	// Queue runs batches as jobs, so they share the queue's workers, priorities,
+	// retries, and metrics. Its workers must be started. Optional. By default each batch
+	// runs on its own goroutine.
+	Queue *queue.JobQueue
+
+	// JobOptions configures the jobs of batches run on Queue. OnComplete is ignored.
+	// Default: Type "batch"
+	JobOptions queue.JobOptions
+
+	// RateLimiter throttles batches, which take one token per request they hold so the
+	// limit counts requests as if they were not batched. Its capacity must be at least
+	// MaxBatchSize. Optional.
+	RateLimiter *ratelimiter.RateLimiter
+
+	// Tracer starts a span for each batch, linked to the spans of its requests.
+	// Default: a tracer from the global OpenTelemetry tracer provider
+	Tracer trace.Tracer
+}
+
+// DefaultOptions returns the default batcher options.
+func DefaultOptions() Options {
+	return Options{
+		MaxBatchSize: 16,
+		MaxWait:      10 * time.Millisecond,
+		JobOptions:   queue.JobOptions{Type: "batch"},
+		Tracer:       otel.Tracer(tracerName),
+	}
+}
+
+// Batcher coalesces concurrent requests for the same model into batches. It is safe for
+// concurrent use.
+type Batcher[Req, Res any] struct {
+	fn      Func[Req, Res]
+	options Options
+
+	mu      sync.Mutex
+	pending map[string]*pendingBatch[Req, Res]
+	closed  bool
+	running sync.WaitGroup
+	jobID   atomic.Int64
+}
+
+// pendingBatch is a batch collecting requests.
+type pendingBatch[Req, Res any] struct {
+	model    string
+	requests []*request[Req, Res]
+	timer    *time.Timer
+}
+
+// request is a request waiting for its result.
+type request[Req, Res any] struct {
+	ctx   context.Context
+	req   Req
+	res   Res
+	err   error
+	done  chan struct{}
+	links trace.SpanContext
+}
+
+// New creates a Batcher that runs batches with fn. Zero option values fall back to the
+// defaults. It returns an error if the rate limiter cannot grant a full batch.
+func New[Req, Res any](fn Func[Req, Res], options Options) (*Batcher[Req, Res], error) {
+	defaults := DefaultOptions()
+	if options.MaxBatchSize <= 0 {
+		options.MaxBatchSize = defaults.MaxBatchSize
+	}
+	if options.MaxWait <= 0 {
+		options.MaxWait = defaults.MaxWait
+	}
+	if options.JobOptions.Type == "" {
+		options.JobOptions.Type = defaults.JobOptions.Type
+	}
+	options.JobOptions.OnComplete = nil
+	if options.Tracer == nil {
+		options.Tracer = defaults.Tracer
+	}
+	if options.RateLimiter != nil && options.RateLimiter.Capacity() < float64(options.MaxBatchSize) {
+		return nil, fmt.Errorf("rate limiter capacity of %v is less than the max batch size of %d", options.RateLimiter.Capacity(), options.MaxBatchSize)
+	}
+	return &Batcher[Req, Res]{
+		fn:      fn,
+		options: options,
+		pending: make(map[string]*pendingBatch[Req, Res]),
+	}, nil
+}
+
+// Do adds req to the next batch for model and returns its result once the batch has
+// run. If ctx is done first, Do returns ctx's error; the request is left out of its
+// batch if the batch has not started, and the batch is canceled if every request in it
+// has given up.
+func (b *Batcher[Req, Res]) Do(ctx context.Context, model string, req Req) (Res, error) {
+	r := &request[Req, Res]{
+		ctx:   ctx,
+		req:   req,
+		done:  make(chan struct{}),
+		links: trace.SpanContextFromContext(ctx),
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		var zero Res
+		return zero, ErrClosed
+	}
+	batch, ok := b.pending[model]
+	if !ok {
+		batch = &pendingBatch[Req, Res]{model: model}
+		b.pending[model] = batch
+		batch.timer = time.AfterFunc(b.options.MaxWait, func() { b.flush(batch) })
+	}
+	batch.requests = append(batch.requests, r)
+	if len(batch.requests) >= b.options.MaxBatchSize {
+		b.detach(batch)
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return r.res, r.err
+	case <-ctx.Done():
+		var zero Res
+		return zero, ctx.Err()
+	}
+}
+
+// Flush runs the pending batches of every model without waiting for them to fill.
+func (b *Batcher[Req, Res]) Flush() {
+	b.mu.Lock()
+	batches := make([]*pendingBatch[Req, Res], 0, len(b.pending))
+	for _, batch := range b.pending {
+		batches = append(batches, batch)
+	}
+	b.mu.Unlock()
+
+	for _, batch := range batches {
+		b.flush(batch)
+	}
+}
+
+// Close runs the pending batches and waits for every batch to finish. Later calls to Do
+// return ErrClosed.
+func (b *Batcher[Req, Res]) Close() {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+
+	b.Flush()
+	b.running.Wait()
+}
+
+// flush starts a batch, unless it has already started.
+func (b *Batcher[Req, Res]) flush(batch *pendingBatch[Req, Res]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.detach(batch)
+}
+
+// detach stops a batch from collecting requests and starts it, unless it has already
+// started. The caller must hold the lock.
+func (b *Batcher[Req, Res]) detach(batch *pendingBatch[Req, Res]) {
+	if b.pending[batch.model] != batch {
+		return
+	}
+	delete(b.pending, batch.model)
+	batch.timer.Stop()
+	b.running.Add(1)
+	go b.start(batch)
+}
+
+// start runs a batch, on the queue if there is one.
+func (b *Batcher[Req, Res]) start(batch *pendingBatch[Req, Res]) {
+	defer b.running.Done()
+
+	// Leave out requests that gave up while the batch was collecting
+	requests := batch.requests[:0]
+	for _, r := range batch.requests {
+		if r.ctx.Err() != nil {
+			r.finish(*new(Res), r.ctx.Err())
+			continue
+		}
+		requests = append(requests, r)
+	}
+	if len(requests) == 0 {
+		return
+	}
+
+	// The batch is canceled once every request in it has given up
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	remaining := atomic.Int64{}
+	remaining.Store(int64(len(requests)))
+	for _, r := range requests {
+		stop := context.AfterFunc(r.ctx, func() {
+			if remaining.Add(-1) == 0 {
+				cancel()
+			}
+		})
+		defer stop()
+	}
+
+	if b.options.RateLimiter != nil {
+		if err := b.options.RateLimiter.WaitN(ctx, float64(len(requests))); err != nil {
+			finishAll(requests, err)
+			return
+		}
+	}
+
+	if b.options.Queue == nil {
+		b.run(ctx, batch.model, requests)
+		return
+	}
+
+	task := func(jobCtx context.Context) error {
+		// Stop the job if every request gives up, as well as when the queue cancels it
+		jobCtx, cancelJob := context.WithCancel(jobCtx)
+		defer cancelJob()
+		stop := context.AfterFunc(ctx, cancelJob)
+		defer stop()
+		return b.run(jobCtx, batch.model, requests)
+	}
+	handle, err := b.options.Queue.AddJobWithContext(trace.ContextWithSpanContext(ctx, requests[0].links), int(b.jobID.Add(1)), task, b.options.JobOptions)
+	if err != nil {
+		finishAll(requests, fmt.Errorf("failed to queue batch: %w", err))
+		return
+	}
+	if err := handle.Wait(context.Background()); err != nil {
+		finishAll(requests, err)
+	}
+}
+
+// run calls the batch function and delivers each request's result. On a queue, a
+// failure is delivered by start once the job's last attempt has failed, so a retry can
+// still succeed.
+func (b *Batcher[Req, Res]) run(ctx context.Context, model string, requests []*request[Req, Res]) (err error) {
+	links := make([]trace.Link, 0, len(requests))
+	for _, r := range requests {
+		if r.links.IsValid() {
+			links = append(links, trace.Link{SpanContext: r.links})
+		}
+	}
+	ctx, span := b.options.Tracer.Start(ctx, "batch.run",
+		trace.WithLinks(links...),
+		trace.WithAttributes(
+			attribute.String("batch.model", model),
+			attribute.Int("batch.size", len(requests)),
+		),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	reqs := make([]Req, len(requests))
+	for i, r := range requests {
+		reqs[i] = r.req
+	}
+	results, err := b.fn(ctx, model, reqs)
+	if err == nil && len(results) != len(requests) {
+		err = fmt.Errorf("batch function returned %d results for %d requests", len(results), len(requests))
+	}
+	if err != nil {
+		if b.options.Queue == nil {
+			finishAll(requests, err)
+		}
+		return err
+	}
+	for i, r := range requests {
+		r.finish(results[i], nil)
+	}
+	return nil
+}
+
+// finish delivers a request's result.
+func (r *request[Req, Res]) finish(res Res, err error) {
+	select {
+	case <-r.done:
+	default:
+		r.res, r.err = res, err
+		close(r.done)
+	}
+}
+
+// finishAll fails every request with err.
+func finishAll[Req, Res any](requests []*request[Req, Res], err error) {
+	for _, r := range requests {
+		r.finish(*new(Res), err)
+	}
+}
@@ -0,0 +1,355 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/h2co32/gollama/internal/models"
+	"github.com/h2co32/gollama/internal/queue"
+	"github.com/h2co32/gollama/pkg/ratelimiter"
+)
+
+// recorder is a batch function that upper-cases requests and records each batch.
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]string
+	models  []string
+}
+
+func (rec *recorder) fn(ctx context.Context, model string, requests []string) ([]string, error) {
+	rec.mu.Lock()
+	rec.batches = append(rec.batches, append([]string(nil), requests...))
+	rec.models = append(rec.models, model)
+	rec.mu.Unlock()
+
+	results := make([]string, len(requests))
+	for i, req := range requests {
+		results[i] = strings.ToUpper(req)
+	}
+	return results, nil
+}
+
+func (rec *recorder) count() int {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return len(rec.batches)
+}
+
+// doAll calls Do concurrently for each request and returns the results in order.
+func doAll(b *Batcher[string, string], model string, requests []string) ([]string, []error) {
+	results := make([]string, len(requests))
+	errs := make([]error, len(requests))
+	var wg sync.WaitGroup
+	for i, req := range requests {
+		wg.Add(1)
+		go func(i int, req string) {
+			defer wg.Done()
+			results[i], errs[i] = b.Do(context.Background(), model, req)
+		}(i, req)
+	}
+	wg.Wait()
+	return results, errs
+}
+
+func TestBatcherCoalescesFullBatch(t *testing.T) {
+	rec := &recorder{}
+	b, err := New(rec.fn, Options{MaxBatchSize: 4, MaxWait: time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to create batcher: %v", err)
+	}
+	defer b.Close()
+
+	results, errs := doAll(b, "m", []string{"a", "b", "c", "d"})
+	for i, want := range []string{"A", "B", "C", "D"} {
+		if errs[i] != nil || results[i] != want {
+			t.Errorf("Expected %s, got %q, %v", want, results[i], errs[i])
+		}
+	}
+	if rec.count() != 1 || len(rec.batches[0]) != 4 {
+		t.Errorf("Expected one batch of 4, got %v", rec.batches)
+	}
+}
+
+func TestBatcherMaxWait(t *testing.T) {
+	rec := &recorder{}
+	b, _ := New(rec.fn, Options{MaxBatchSize: 100, MaxWait: 20 * time.Millisecond})
+	defer b.Close()
+
+	start := time.Now()
+	results, _ := doAll(b, "m", []string{"a", "b"})
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected the batch to wait 20ms, took %v", elapsed)
+	}
+	if results[0] != "A" || results[1] != "B" {
+		t.Errorf("Unexpected results %v", results)
+	}
+	if rec.count() != 1 {
+		t.Errorf("Expected one batch, got %v", rec.batches)
+	}
+}
+
+func TestBatcherSeparatesModels(t *testing.T) {
+	rec := &recorder{}
+	b, _ := New(rec.fn, Options{MaxBatchSize: 100, MaxWait: 10 * time.Millisecond})
+	defer b.Close()
+
+	var wg sync.WaitGroup
+	for _, model := range []string{"m1", "m2", "m1", "m2"} {
+		wg.Add(1)
+		go func(model string) {
+			defer wg.Done()
+			b.Do(context.Background(), model, "x")
+		}(model)
+	}
+	wg.Wait()
+
+	if rec.count() != 2 {
+		t.Fatalf("Expected a batch per model, got %v for %v", rec.batches, rec.models)
+	}
+	for _, batch := range rec.batches {
+		if len(batch) != 2 {
+			t.Errorf("Expected batches of 2, got %v", rec.batches)
+		}
+	}
+}
+
+func TestBatcherErrors(t *testing.T) {
+	failing := func(ctx context.Context, model string, requests []string) ([]string, error) {
+		return nil, errors.New("backend down")
+	}
+	b, _ := New(failing, Options{MaxBatchSize: 2})
+	defer b.Close()
+	_, errs := doAll(b, "m", []string{"a", "b"})
+	for _, err := range errs {
+		if err == nil || err.Error() != "backend down" {
+			t.Errorf("Expected backend error, got %v", err)
+		}
+	}
+
+	short := func(ctx context.Context, model string, requests []string) ([]string, error) {
+		return []string{"only one"}, nil
+	}
+	b2, _ := New(short, Options{MaxBatchSize: 2})
+	defer b2.Close()
+	_, errs = doAll(b2, "m", []string{"a", "b"})
+	if errs[0] == nil || !strings.Contains(errs[0].Error(), "1 results for 2 requests") {
+		t.Errorf("Expected result count error, got %v", errs[0])
+	}
+}
+
+func TestBatcherLeavesOutCanceledRequests(t *testing.T) {
+	rec := &recorder{}
+	b, _ := New(rec.fn, Options{MaxBatchSize: 100, MaxWait: 30 * time.Millisecond})
+	defer b.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	var canceledErr error
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, canceledErr = b.Do(ctx, "m", "gone")
+	}()
+	result, err := b.Do(context.Background(), "m", "kept")
+	wg.Wait()
+
+	if !errors.Is(canceledErr, context.DeadlineExceeded) {
+		t.Errorf("Expected DeadlineExceeded, got %v", canceledErr)
+	}
+	if err != nil || result != "KEPT" {
+		t.Errorf("Expected KEPT, got %q, %v", result, err)
+	}
+	if rec.count() != 1 || len(rec.batches[0]) != 1 || rec.batches[0][0] != "kept" {
+		t.Errorf("Expected only the kept request in the batch, got %v", rec.batches)
+	}
+}
+
+func TestBatcherClose(t *testing.T) {
+	rec := &recorder{}
+	b, _ := New(rec.fn, Options{MaxBatchSize: 100, MaxWait: time.Hour})
+
+	done := make(chan string)
+	go func() {
+		result, _ := b.Do(context.Background(), "m", "a")
+		done <- result
+	}()
+	// Wait for the request to join a batch
+	for {
+		b.mu.Lock()
+		pending := len(b.pending)
+		b.mu.Unlock()
+		if pending == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	b.Close()
+	if result := <-done; result != "A" {
+		t.Errorf("Expected Close to run the pending batch, got %q", result)
+	}
+	if _, err := b.Do(context.Background(), "m", "b"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestBatcherQueue(t *testing.T) {
+	jq := queue.NewJobQueueWithOptions(queue.Options{Workers: 1})
+	jq.StartWorkers()
+	defer jq.Stop(context.Background())
+
+	var attempts atomic.Int32
+	flaky := func(ctx context.Context, model string, requests []string) ([]string, error) {
+		if attempts.Add(1) == 1 {
+			return nil, errors.New("transient")
+		}
+		return requests, nil
+	}
+	b, _ := New(flaky, Options{MaxBatchSize: 2, Queue: jq, JobOptions: queue.JobOptions{Retries: 2}})
+	defer b.Close()
+
+	results, errs := doAll(b, "m", []string{"a", "b"})
+	if errs[0] != nil || errs[1] != nil || results[0] != "a" || results[1] != "b" {
+		t.Errorf("Expected the retry to succeed, got %v, %v", results, errs)
+	}
+	if attempts.Load() != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts.Load())
+	}
+	if stats := jq.Stats(); stats[queue.PriorityNormal].Succeeded != 1 {
+		t.Errorf("Expected one completed job, got %+v", stats)
+	}
+}
+
+func TestBatcherRateLimiter(t *testing.T) {
+	limiter := ratelimiter.New(2, 50*time.Millisecond, 2)
+	if _, err := New((&recorder{}).fn, Options{MaxBatchSize: 4, RateLimiter: limiter}); err == nil {
+		t.Error("Expected error for a limiter smaller than a batch")
+	}
+
+	rec := &recorder{}
+	b, _ := New(rec.fn, Options{MaxBatchSize: 2, RateLimiter: limiter})
+	defer b.Close()
+
+	start := time.Now()
+	doAll(b, "m", []string{"a", "b"})
+	doAll(b, "m", []string{"c", "d"})
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected the second batch to wait for tokens, took %v", elapsed)
+	}
+}
+
+func TestBatcherTracing(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	defer provider.Shutdown(context.Background())
+
+	b, _ := New((&recorder{}).fn, Options{MaxBatchSize: 2, Tracer: provider.Tracer("test")})
+	defer b.Close()
+
+	var wg sync.WaitGroup
+	for _, req := range []string{"a", "b"} {
+		wg.Add(1)
+		go func(req string) {
+			defer wg.Done()
+			ctx, span := provider.Tracer("test").Start(context.Background(), "request")
+			defer span.End()
+			b.Do(ctx, "m", req)
+		}(req)
+	}
+	wg.Wait()
+
+	for _, span := range spans.Ended() {
+		if span.Name() == "batch.run" {
+			if len(span.Links()) != 2 {
+				t.Errorf("Expected links to both requests, got %d", len(span.Links()))
+			}
+			return
+		}
+	}
+	t.Error("Expected a batch.run span")
+}
+
+func TestEmbedBatcher(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var req models.EmbedRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		res := models.EmbedResponse{Model: req.Model}
+		for _, input := range req.Input {
+			res.Embeddings = append(res.Embeddings, []float32{float32(len(input))})
+		}
+		json.NewEncoder(w).Encode(res)
+	}))
+	defer server.Close()
+
+	client := models.NewOllamaClientWithOptions(models.OllamaClientOptions{BaseURL: server.URL, ModelDir: t.TempDir()})
+	b, err := NewEmbedBatcher(client, Options{MaxBatchSize: 3})
+	if err != nil {
+		t.Fatalf("Failed to create batcher: %v", err)
+	}
+	defer b.Close()
+
+	var wg sync.WaitGroup
+	vectors := make([][]float32, 3)
+	for i, text := range []string{"a", "bb", "ccc"} {
+		wg.Add(1)
+		go func(i int, text string) {
+			defer wg.Done()
+			vectors[i], _ = b.Do(context.Background(), "nomic-embed-text", text)
+		}(i, text)
+	}
+	wg.Wait()
+
+	for i, vector := range vectors {
+		if len(vector) != 1 || vector[0] != float32(i+1) {
+			t.Errorf("Unexpected embedding %d: %v", i, vector)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected one request to Ollama, got %d", calls.Load())
+	}
+}
+
+func TestGenerateBatcher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req models.GenerateRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Stream == nil || *req.Stream {
+			t.Error("Expected a non-streaming request")
+		}
+		json.NewEncoder(w).Encode(models.GenerateResponse{Model: req.Model, Response: "re: " + req.Prompt, Done: true})
+	}))
+	defer server.Close()
+
+	client := models.NewOllamaClientWithOptions(models.OllamaClientOptions{BaseURL: server.URL, ModelDir: t.TempDir()})
+	b, _ := NewGenerateBatcher(client, Options{MaxBatchSize: 2})
+	defer b.Close()
+
+	var wg sync.WaitGroup
+	responses := make([]models.GenerateResponse, 2)
+	for i, p := range []string{"one", "two"} {
+		wg.Add(1)
+		go func(i int, p string) {
+			defer wg.Done()
+			responses[i], _ = b.Do(context.Background(), "llama3", models.GenerateRequest{Prompt: p})
+		}(i, p)
+	}
+	wg.Wait()
+
+	if responses[0].Response != "re: one" || responses[1].Response != "re: two" || responses[0].Model != "llama3" {
+		t.Errorf("Unexpected responses %+v", responses)
+	}
+}
@@ -0,0 +1,53 @@
+package batch
+
+import (
+	"context"
+	"sync"
+
+	"github.com/h2co32/gollama/internal/models"
+)
+
+// NewEmbedBatcher creates a Batcher that embeds texts with the Ollama Embed API, sending
+// each batch as the inputs of one request.
+func NewEmbedBatcher(client *models.OllamaClient, options Options) (*Batcher[string, []float32], error) {
+	return New(func(ctx context.Context, model string, texts []string) ([][]float32, error) {
+		res, err := client.Embed(ctx, models.EmbedRequest{Model: model, Input: texts})
+		if err != nil {
+			return nil, err
+		}
+		return res.Embeddings, nil
+	}, options)
+}
+
+// NewGenerateBatcher creates a Batcher that runs generate requests without streaming.
+// The Generate API takes one prompt per request, so each batch is sent as concurrent
+// requests that arrive together, letting an Ollama server with OLLAMA_NUM_PARALLEL
+// slots evaluate them in the same batch. The model of each request is set to the
+// batch's model.
+func NewGenerateBatcher(client *models.OllamaClient, options Options) (*Batcher[models.GenerateRequest, models.GenerateResponse], error) {
+	return New(func(ctx context.Context, model string, reqs []models.GenerateRequest) ([]models.GenerateResponse, error) {
+		results := make([]models.GenerateResponse, len(reqs))
+		errs := make([]error, len(reqs))
+		var wg sync.WaitGroup
+		for i, req := range reqs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				req.Model = model
+				req.Stream = new(bool)
+				errs[i] = client.Generate(ctx, req, func(res models.GenerateResponse) error {
+					results[i] = res
+					return nil
+				})
+			}()
+		}
+		wg.Wait()
+
+		for _, err := range errs {
+			if err != nil {
+				return nil, err
+			}
+		}
+		return results, nil
+	}, options)
+}
@@ -0,0 +1,54 @@
+// Package cache provides in-memory, disk-based, and Redis-based caches behind a
+// common Cache interface, and a TieredCache that combines them.
+//
+// Example usage:
+//
+//	memory := cache.NewMemoryCache(cache.MemoryCacheOptions{})
+//	disk, err := cache.NewDiskCache("./cache")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	redis := cache.NewDistributedCache("localhost:6379")
+//
+//	// Look up memory first, then disk, then Redis
+//	tiered, err := cache.NewTieredCache(cache.TieredCacheOptions{}, memory, disk, redis.Bytes())
+//	err = tiered.Set("key", []byte("value"), time.Hour)
+//	data, err := tiered.Get("key")
+package cache
+
+import "time"
+
+// Cache is the common interface of the byte-oriented caches in this package.
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	// Set stores data under key for ttl.
+	Set(key string, data []byte, ttl time.Duration) error
+
+	// Get returns the data stored under key, or nil with a nil error if the key is
+	// missing or expired.
+	Get(key string) ([]byte, error)
+
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(key string) error
+
+	// Clear removes every key.
+	Clear() error
+
+	// MGet returns the data stored under each of keys. Missing and expired keys are
+	// left out of the result.
+	MGet(keys []string) (map[string][]byte, error)
+
+	// MSet stores each item for ttl.
+	MSet(items map[string][]byte, ttl time.Duration) error
+
+	// MDelete removes keys. Deleting missing keys is not an error.
+	MDelete(keys []string) error
+}
+
+var (
+	_ Cache = (*MemoryCache)(nil)
+	_ Cache = (*DiskCache)(nil)
+	_ Cache = (*TieredCache)(nil)
+	_ Cache = (*ComputeCache)(nil)
+	_ Cache = distributedBytesCache{}
+)
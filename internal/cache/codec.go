@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec converts values to and from the bytes stored in a cache.
+// Implementations must be safe for concurrent use.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec encodes values as JSON. It is the default codec.
+type JSONCodec struct{}
+
+// Marshal encodes v as JSON.
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes JSON data into v.
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// GobCodec encodes values with encoding/gob, which is considerably smaller and faster
+// than JSON for numeric data such as embedding vectors.
+type GobCodec struct{}
+
+// Marshal encodes v with gob.
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes gob data into v.
+func (GobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// RawCodec stores []byte and string values as is, for blobs that are already encoded.
+// Marshal accepts []byte, *[]byte, string, and *string; Unmarshal accepts *[]byte and *string.
+type RawCodec struct{}
+
+// Marshal returns the bytes of v.
+func (RawCodec) Marshal(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case []byte:
+		return v, nil
+	case *[]byte:
+		return *v, nil
+	case string:
+		return []byte(v), nil
+	case *string:
+		return []byte(*v), nil
+	default:
+		return nil, fmt.Errorf("raw codec cannot encode %T", v)
+	}
+}
+
+// Unmarshal copies data into v.
+func (RawCodec) Unmarshal(data []byte, v interface{}) error {
+	switch v := v.(type) {
+	case *[]byte:
+		*v = append([]byte(nil), data...)
+	case *string:
+		*v = string(data)
+	default:
+		return fmt.Errorf("raw codec cannot decode into %T", v)
+	}
+	return nil
+}
+
+// Compression is a compression algorithm applied to cached bytes.
+type Compression string
+
+const (
+	// CompressionNone stores bytes uncompressed.
+	CompressionNone Compression = ""
+
+	// CompressionGzip compresses with gzip.
+	CompressionGzip Compression = "gzip"
+
+	// CompressionZstd compresses with Zstandard, which is faster than gzip at a
+	// similar ratio.
+	CompressionZstd Compression = "zstd"
+)
+
+var (
+	// zstdEncoder and zstdDecoder are shared; their EncodeAll and DecodeAll methods
+	// are safe for concurrent use.
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+
+	gzipWriters = sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }}
+)
+
+// compress compresses data with the given algorithm.
+func compress(compression Compression, data []byte) ([]byte, error) {
+	switch compression {
+	case CompressionNone:
+		return data, nil
+	case CompressionGzip:
+		var buf bytes.Buffer
+		w := gzipWriters.Get().(*gzip.Writer)
+		defer gzipWriters.Put(w)
+		w.Reset(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, fmt.Errorf("failed to compress data: %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress data: %w", err)
+		}
+		return buf.Bytes(), nil
+	case CompressionZstd:
+		return zstdEncoder.EncodeAll(data, nil), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", compression)
+	}
+}
+
+// decompress reverses compress.
+func decompress(compression Compression, data []byte) ([]byte, error) {
+	switch compression {
+	case CompressionNone:
+		return data, nil
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress data: %w", err)
+		}
+		defer r.Close()
+		out, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress data: %w", err)
+		}
+		return out, nil
+	case CompressionZstd:
+		out, err := zstdDecoder.DecodeAll(data, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress data: %w", err)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", compression)
+	}
+}
+
+// SetValue encodes v with codec and stores it in c under key, so any Cache can hold
+// typed values with a codec chosen per call.
+func SetValue(c Cache, codec Codec, key string, v interface{}, ttl time.Duration) error {
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data: %w", err)
+	}
+	return c.Set(key, data, ttl)
+}
+
+// GetValue reads key from c and decodes it into target with codec. It reports whether
+// the key was found.
+func GetValue(c Cache, codec Codec, key string, target interface{}) (bool, error) {
+	data, err := c.Get(key)
+	if err != nil || data == nil {
+		return false, err
+	}
+	if err := codec.Unmarshal(data, target); err != nil {
+		return false, fmt.Errorf("failed to unmarshal cache data: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,219 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type embedding struct {
+	Model  string
+	Vector []float64
+}
+
+func TestCodecs(t *testing.T) {
+	value := embedding{Model: "llama", Vector: []float64{0.1, -2.5, 3}}
+
+	for name, codec := range map[string]Codec{"json": JSONCodec{}, "gob": GobCodec{}, "msgpack": MsgpackCodec{}} {
+		data, err := codec.Marshal(value)
+		if err != nil {
+			t.Fatalf("%s: failed to marshal: %v", name, err)
+		}
+		var decoded embedding
+		if err := codec.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("%s: failed to unmarshal: %v", name, err)
+		}
+		if !reflect.DeepEqual(decoded, value) {
+			t.Errorf("%s: expected %v, got %v", name, value, decoded)
+		}
+	}
+
+	data, err := RawCodec{}.Marshal("blob")
+	if err != nil || string(data) != "blob" {
+		t.Errorf("Expected raw codec to return 'blob', got '%s', %v", data, err)
+	}
+	var decoded []byte
+	if err := (RawCodec{}).Unmarshal([]byte("blob"), &decoded); err != nil || string(decoded) != "blob" {
+		t.Errorf("Expected raw codec to decode 'blob', got '%s', %v", decoded, err)
+	}
+	if _, err := (RawCodec{}).Marshal(value); err == nil {
+		t.Error("Expected raw codec to reject a struct")
+	}
+}
+
+type msgpackBase struct {
+	ID      int64
+	Created time.Time
+}
+
+type msgpackValue struct {
+	msgpackBase
+	Name     string `msgpack:"name"`
+	Note     string `msgpack:",omitempty"`
+	Extra    string `msgpack:"extra,omitempty,noinline"`
+	Secret   string `msgpack:"-"`
+	Small    int8
+	Count    uint32
+	Big      uint64
+	Ratio    float32
+	Scores   []float64
+	Raw      []byte
+	Hash     [4]byte
+	Labels   map[string]int
+	Next     *msgpackValue
+	Nil      *msgpackValue
+	Flag     bool
+	Anything interface{}
+}
+
+func TestMsgpackCodec(t *testing.T) {
+	value := msgpackValue{
+		msgpackBase: msgpackBase{ID: -70000, Created: time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.UTC)},
+		Name:        strings.Repeat("long name ", 10),
+		Small:       -5,
+		Count:       70000,
+		Big:         1 << 63,
+		Ratio:       0.5,
+		Scores:      []float64{0.1, -2.5, 3},
+		Raw:         []byte{0, 1, 2},
+		Hash:        [4]byte{1, 2, 3, 4},
+		Labels:      map[string]int{"a": 1, "b": -200},
+		Next:        &msgpackValue{Name: "next", Flag: true},
+		Anything:    map[string]interface{}{"list": []interface{}{int64(1), "two", 3.5, nil}},
+	}
+
+	codec := MsgpackCodec{}
+	data, err := codec.Marshal(value)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	var decoded msgpackValue
+	if err := codec.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, value) {
+		t.Errorf("Expected %+v, got %+v", value, decoded)
+	}
+
+	// Tags rename, omit, and skip fields, and embedded fields are flattened
+	var fields map[string]interface{}
+	if err := codec.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("Failed to unmarshal into a map: %v", err)
+	}
+	for _, name := range []string{"name", "ID", "Created"} {
+		if _, ok := fields[name]; !ok {
+			t.Errorf("Expected field %s to be encoded", name)
+		}
+	}
+	for _, name := range []string{"Name", "Note", "extra", "Secret", "msgpackBase"} {
+		if _, ok := fields[name]; ok {
+			t.Errorf("Expected field %s not to be encoded", name)
+		}
+	}
+
+	// Equal maps encode to equal bytes
+	first, _ := codec.Marshal(map[string]int{"a": 1, "b": 2, "c": 3})
+	second, _ := codec.Marshal(map[string]int{"c": 3, "b": 2, "a": 1})
+	if !bytes.Equal(first, second) {
+		t.Error("Expected equal maps to encode to equal bytes")
+	}
+
+	// Timestamps use the smallest format that holds them
+	for _, ts := range []time.Time{time.Unix(1, 0).UTC(), time.Unix(1, 5).UTC(), time.Unix(-1, 5).UTC()} {
+		data, err := codec.Marshal(ts)
+		if err != nil {
+			t.Fatalf("Failed to marshal %v: %v", ts, err)
+		}
+		var decoded time.Time
+		if err := codec.Unmarshal(data, &decoded); err != nil || !decoded.Equal(ts) {
+			t.Errorf("Expected %v, got %v, %v", ts, decoded, err)
+		}
+	}
+}
+
+func TestMsgpackCodecErrors(t *testing.T) {
+	codec := MsgpackCodec{}
+
+	if _, err := codec.Marshal(make(chan int)); err == nil {
+		t.Error("Expected an error encoding a channel")
+	}
+	var value embedding
+	if err := codec.Unmarshal([]byte{0x80}, value); err == nil {
+		t.Error("Expected an error decoding into a non-pointer")
+	}
+
+	data, _ := codec.Marshal(embedding{Model: "llama", Vector: []float64{1}})
+	if err := codec.Unmarshal(data[:len(data)-1], &value); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected io.ErrUnexpectedEOF for truncated data, got %v", err)
+	}
+	if err := codec.Unmarshal(append(data, 0xc0), &value); err == nil {
+		t.Error("Expected an error for trailing data")
+	}
+
+	// A corrupt length cannot allocate more than the data holds
+	var values []int
+	if err := codec.Unmarshal([]byte{0xdd, 0xff, 0xff, 0xff, 0xff}, &values); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected io.ErrUnexpectedEOF for a corrupt length, got %v", err)
+	}
+
+	var small int8
+	data, _ = codec.Marshal(1000)
+	if err := codec.Unmarshal(data, &small); err == nil {
+		t.Error("Expected an error decoding 1000 into an int8")
+	}
+	var name string
+	if err := codec.Unmarshal(data, &name); err == nil {
+		t.Error("Expected an error decoding an integer into a string")
+	}
+}
+
+func TestCompression(t *testing.T) {
+	data := []byte(strings.Repeat("compressible ", 100))
+
+	for _, compression := range []Compression{CompressionNone, CompressionGzip, CompressionZstd} {
+		compressed, err := compress(compression, data)
+		if err != nil {
+			t.Fatalf("%q: failed to compress: %v", compression, err)
+		}
+		if compression != CompressionNone && len(compressed) >= len(data) {
+			t.Errorf("%q: expected compressed size below %d, got %d", compression, len(data), len(compressed))
+		}
+		decompressed, err := decompress(compression, compressed)
+		if err != nil {
+			t.Fatalf("%q: failed to decompress: %v", compression, err)
+		}
+		if !bytes.Equal(decompressed, data) {
+			t.Errorf("%q: decompressed data does not match", compression)
+		}
+	}
+
+	if _, err := compress("lz4", data); err == nil {
+		t.Error("Expected an error for an unsupported compression")
+	}
+}
+
+func TestSetGetValue(t *testing.T) {
+	cache := NewMemoryCache(MemoryCacheOptions{})
+	value := embedding{Model: "llama", Vector: []float64{1, 2, 3}}
+
+	if err := SetValue(cache, GobCodec{}, "key", value, time.Hour); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+
+	var decoded embedding
+	found, err := GetValue(cache, GobCodec{}, "key", &decoded)
+	if err != nil || !found {
+		t.Fatalf("Expected value to be found, got %v, %v", found, err)
+	}
+	if !reflect.DeepEqual(decoded, value) {
+		t.Errorf("Expected %v, got %v", value, decoded)
+	}
+
+	if found, err := GetValue(cache, GobCodec{}, "missing", &decoded); found || err != nil {
+		t.Errorf("Expected a miss, got %v, %v", found, err)
+	}
+}
@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ComputeCacheOptions configures a ComputeCache.
+type ComputeCacheOptions struct {
+	// StaleTTL is how long past its TTL a value may still be served by GetOrCompute
+	// while it is recomputed in the background. Zero disables stale-while-revalidate,
+	// so expired values are recomputed before GetOrCompute returns.
+	// Default: 0
+	StaleTTL time.Duration
+
+	// OnRefreshError is called when a background recomputation fails. The stale value
+	// keeps being served until it expires. Optional.
+	OnRefreshError func(key string, err error)
+}
+
+// ComputeCache wraps a Cache with GetOrCompute, which computes missing values at most
+// once per key at a time no matter how many callers miss concurrently, so hot keys
+// expiring do not send a thundering herd to the model backend.
+//
+// With StaleTTL set, GetOrCompute stores values with a small header recording when
+// they become stale, so keys it manages should only be read through GetOrCompute.
+type ComputeCache struct {
+	Cache
+	options ComputeCacheOptions
+
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// flight is an in-progress computation that concurrent callers wait on.
+type flight struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// staleHeaderSize is the size of the fresh-until timestamp stored before values when
+// stale-while-revalidate is enabled.
+const staleHeaderSize = 8
+
+// NewComputeCache wraps cache with GetOrCompute.
+func NewComputeCache(cache Cache, options ComputeCacheOptions) *ComputeCache {
+	return &ComputeCache{
+		Cache:   cache,
+		options: options,
+		flights: make(map[string]*flight),
+	}
+}
+
+// GetOrCompute returns the value cached under key, calling compute and caching its
+// result for ttl if there is none. Concurrent calls for the same key share a single
+// call to compute. Errors from compute, including panics, are returned and not cached. Errors from the
+// underlying cache do not fail the call; the value is computed instead.
+func (cc *ComputeCache) GetOrCompute(key string, ttl time.Duration, compute func() ([]byte, error)) ([]byte, error) {
+	data, _ := cc.Cache.Get(key)
+	if data != nil {
+		if cc.options.StaleTTL <= 0 {
+			return data, nil
+		}
+		if len(data) >= staleHeaderSize {
+			freshUntil := time.Unix(0, int64(binary.BigEndian.Uint64(data)))
+			value := data[staleHeaderSize:]
+			if time.Now().After(freshUntil) {
+				cc.refresh(key, ttl, compute)
+			}
+			return value, nil
+		}
+		// Not written by GetOrCompute; recompute it below
+	}
+
+	f, _ := cc.do(key, ttl, compute)
+	<-f.done
+	return f.data, f.err
+}
+
+// refresh recomputes key in the background unless a computation is already running.
+func (cc *ComputeCache) refresh(key string, ttl time.Duration, compute func() ([]byte, error)) {
+	f, started := cc.do(key, ttl, compute)
+	if !started || cc.options.OnRefreshError == nil {
+		return
+	}
+	go func() {
+		<-f.done
+		if f.err != nil {
+			cc.options.OnRefreshError(key, f.err)
+		}
+	}()
+}
+
+// do starts computing key unless a computation is already running, and returns the
+// flight to wait on and whether it was started by this call.
+func (cc *ComputeCache) do(key string, ttl time.Duration, compute func() ([]byte, error)) (*flight, bool) {
+	cc.mu.Lock()
+	if f, ok := cc.flights[key]; ok {
+		cc.mu.Unlock()
+		return f, false
+	}
+	f := &flight{done: make(chan struct{})}
+	cc.flights[key] = f
+	cc.mu.Unlock()
+
+	go func() {
+		defer func() {
+			cc.mu.Lock()
+			delete(cc.flights, key)
+			cc.mu.Unlock()
+			close(f.done)
+		}()
+
+		f.data, f.err = callCompute(compute)
+		if f.err == nil && f.data != nil {
+			cc.store(key, f.data, ttl)
+		}
+	}()
+	return f, true
+}
+
+// callCompute calls compute, turning a panic into an error so that it fails the
+// waiting callers instead of crashing the process.
+func callCompute(compute func() ([]byte, error)) (data []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			data, err = nil, fmt.Errorf("compute panicked: %v", r)
+		}
+	}()
+	return compute()
+}
+
+// store caches a computed value, adding the stale header when needed. Cache errors
+// are ignored because the value is still returned to the callers.
+func (cc *ComputeCache) store(key string, data []byte, ttl time.Duration) {
+	if cc.options.StaleTTL <= 0 {
+		_ = cc.Cache.Set(key, data, ttl)
+		return
+	}
+
+	stored := make([]byte, staleHeaderSize+len(data))
+	binary.BigEndian.PutUint64(stored, uint64(time.Now().Add(ttl).UnixNano()))
+	copy(stored[staleHeaderSize:], data)
+	_ = cc.Cache.Set(key, stored, ttl+cc.options.StaleTTL)
+}
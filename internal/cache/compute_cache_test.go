@@ -0,0 +1,165 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestComputeCacheSingleflight(t *testing.T) {
+	cc := NewComputeCache(NewMemoryCache(MemoryCacheOptions{}), ComputeCacheOptions{})
+
+	var calls int32
+	release := make(chan struct{})
+	compute := func() ([]byte, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return []byte("computed"), nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([][]byte, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = cc.GetOrCompute("key", time.Hour, compute)
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("Expected 1 computation, got %d", calls)
+	}
+	for i, result := range results {
+		if !bytes.Equal(result, []byte("computed")) {
+			t.Errorf("Expected caller %d to get 'computed', got '%s'", i, result)
+		}
+	}
+
+	// Later calls are served from the cache
+	cc.GetOrCompute("key", time.Hour, compute)
+	if calls != 1 {
+		t.Errorf("Expected the cached value to be used, got %d computations", calls)
+	}
+}
+
+func TestComputeCacheErrors(t *testing.T) {
+	cc := NewComputeCache(NewMemoryCache(MemoryCacheOptions{}), ComputeCacheOptions{})
+
+	_, err := cc.GetOrCompute("key", time.Hour, func() ([]byte, error) {
+		return nil, errors.New("backend unavailable")
+	})
+	if err == nil {
+		t.Fatal("Expected the compute error to be returned")
+	}
+
+	// Errors are not cached
+	data, err := cc.GetOrCompute("key", time.Hour, func() ([]byte, error) {
+		return []byte("value"), nil
+	})
+	if err != nil || !bytes.Equal(data, []byte("value")) {
+		t.Errorf("Expected value 'value', got '%s', %v", data, err)
+	}
+
+	// Cache errors do not fail the call
+	cc = NewComputeCache(failingCache{}, ComputeCacheOptions{})
+	data, err = cc.GetOrCompute("key", time.Hour, func() ([]byte, error) {
+		return []byte("value"), nil
+	})
+	if err != nil || !bytes.Equal(data, []byte("value")) {
+		t.Errorf("Expected value 'value' despite the failing cache, got '%s', %v", data, err)
+	}
+}
+
+func TestComputeCachePanic(t *testing.T) {
+	cc := NewComputeCache(NewMemoryCache(MemoryCacheOptions{}), ComputeCacheOptions{})
+
+	_, err := cc.GetOrCompute("key", time.Hour, func() ([]byte, error) {
+		panic("tokenizer exploded")
+	})
+	if err == nil || !strings.Contains(err.Error(), "tokenizer exploded") {
+		t.Fatalf("Expected the panic to be returned as an error, got %v", err)
+	}
+
+	// The failed flight does not block later computations
+	data, err := cc.GetOrCompute("key", time.Hour, func() ([]byte, error) {
+		return []byte("value"), nil
+	})
+	if err != nil || !bytes.Equal(data, []byte("value")) {
+		t.Errorf("Expected value 'value', got '%s', %v", data, err)
+	}
+}
+
+func TestComputeCacheStaleWhileRevalidate(t *testing.T) {
+	refreshErrors := make(chan error, 1)
+	cc := NewComputeCache(NewMemoryCache(MemoryCacheOptions{}), ComputeCacheOptions{
+		StaleTTL: time.Hour,
+		OnRefreshError: func(key string, err error) {
+			refreshErrors <- err
+		},
+	})
+
+	var version int32
+	refreshed := make(chan struct{}, 1)
+	compute := func() ([]byte, error) {
+		if atomic.AddInt32(&version, 1) > 1 {
+			defer func() { refreshed <- struct{}{} }()
+		}
+		return []byte{byte('0' + atomic.LoadInt32(&version))}, nil
+	}
+
+	data, _ := cc.GetOrCompute("key", 10*time.Millisecond, compute)
+	if string(data) != "1" {
+		t.Fatalf("Expected value '1', got '%s'", data)
+	}
+
+	// Once stale, the old value is served while it is recomputed
+	time.Sleep(20 * time.Millisecond)
+	data, _ = cc.GetOrCompute("key", time.Hour, compute)
+	if string(data) != "1" {
+		t.Errorf("Expected the stale value '1', got '%s'", data)
+	}
+
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("Expected a background refresh")
+	}
+
+	// The refreshed value is stored once compute returns
+	deadline := time.Now().Add(time.Second)
+	for {
+		data, _ = cc.GetOrCompute("key", time.Hour, compute)
+		if string(data) == "2" || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if string(data) != "2" {
+		t.Errorf("Expected the refreshed value '2', got '%s'", data)
+	}
+
+	// Failed refreshes are reported and the stale value is kept
+	cc.Cache.Set("failing", append(make([]byte, staleHeaderSize), "stale"...), time.Hour)
+	data, _ = cc.GetOrCompute("failing", time.Hour, func() ([]byte, error) {
+		return nil, errors.New("backend unavailable")
+	})
+	if string(data) != "stale" {
+		t.Errorf("Expected the stale value 'stale', got '%s'", data)
+	}
+	select {
+	case err := <-refreshErrors:
+		if err == nil {
+			t.Error("Expected a refresh error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected OnRefreshError to be called")
+	}
+}
@@ -1,109 +1,399 @@
-package cache
-
-import (
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
-	"os"
-	"path/filepath"
-	"sync"
-	"time"
-)
-
-// DiskCache manages data caching on the local filesystem
-type DiskCache struct {
-	directory string
-	mu        sync.RWMutex
-}
-
-// CacheItem represents a single cached item with data and expiration
-type CacheItem struct {
-	Data      []byte    `json:"data"`
-	ExpiresAt time.Time `json:"expires_at"`
-}
-
-// NewDiskCache initializes a new DiskCache with the specified directory
-func NewDiskCache(directory string) (*DiskCache, error) {
-	if err := os.MkdirAll(directory, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create cache directory: %w", err)
-	}
-	return &DiskCache{directory: directory}, nil
-}
-
-// Set stores a key-value pair in the cache with an expiration duration
-func (dc *DiskCache) Set(key string, data []byte, ttl time.Duration) error {
-	dc.mu.Lock()
-	defer dc.mu.Unlock()
-
-	item := CacheItem{
-		Data:      data,
-		ExpiresAt: time.Now().Add(ttl),
-	}
-
-	filePath := filepath.Join(dc.directory, key+".json")
-	fileData, err := json.Marshal(item)
-	if err != nil {
-		return fmt.Errorf("failed to marshal cache item: %w", err)
-	}
-
-	if err := ioutil.WriteFile(filePath, fileData, 0644); err != nil {
-		return fmt.Errorf("failed to write cache file: %w", err)
-	}
-	return nil
-}
-
-// Get retrieves a value from the cache by key, returning nil if expired or not found
-func (dc *DiskCache) Get(key string) ([]byte, error) {
-	dc.mu.RLock()
-	defer dc.mu.RUnlock()
-
-	filePath := filepath.Join(dc.directory, key+".json")
-	fileData, err := ioutil.ReadFile(filePath)
-	if os.IsNotExist(err) {
-		return nil, nil
-	} else if err != nil {
-		return nil, fmt.Errorf("failed to read cache file: %w", err)
-	}
-
-	var item CacheItem
-	if err := json.Unmarshal(fileData, &item); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal cache item: %w", err)
-	}
-
-	if time.Now().After(item.ExpiresAt) {
-		_ = os.Remove(filePath) // Remove expired item
-		return nil, nil
-	}
-
-	return item.Data, nil
-}
-
-// Delete removes a cached item by key
-func (dc *DiskCache) Delete(key string) error {
-	dc.mu.Lock()
-	defer dc.mu.Unlock()
-
-	filePath := filepath.Join(dc.directory, key+".json")
-	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to delete cache file: %w", err)
-	}
-	return nil
-}
-
-// Clear removes all cached items
-func (dc *DiskCache) Clear() error {
-	dc.mu.Lock()
-	defer dc.mu.Unlock()
-
-	files, err := ioutil.ReadDir(dc.directory)
-	if err != nil {
-		return fmt.Errorf("failed to read cache directory: %w", err)
-	}
-
-	for _, file := range files {
-		if err := os.Remove(filepath.Join(dc.directory, file.Name())); err != nil {
-			return fmt.Errorf("failed to clear cache file: %w", err)
-		}
-	}
-	return nil
-}
+package cache
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/h2co32/gollama/internal/metrics"
+)
+
+// tempFilePrefix marks files being written by Set. They are renamed into place when
+// complete, so a crash mid-write never leaves a partial cache entry behind.
+const tempFilePrefix = ".tmp-"
+
+// DiskCacheOptions configures a DiskCache.
+type DiskCacheOptions struct {
+	// Sync flushes each entry to stable storage before it is renamed into place, so
+	// entries survive power loss as well as process crashes, at the cost of slower writes.
+	// Default: false
+	Sync bool
+
+	// Codec encodes the values stored with SetValue and read with GetValue.
+	// Default: JSONCodec
+	Codec Codec
+
+	// Compression compresses entry data on disk. Each entry records its compression,
+	// so changing this does not invalidate existing entries.
+	// Default: CompressionNone
+	Compression Compression
+
+	// Metrics records hits, misses, evictions, size, and operation latency. Optional.
+	// The size of existing entries is counted when the cache is opened.
+	Metrics *metrics.MetricsProvider
+
+	// Name labels the cache's metrics.
+	// Default: "disk"
+	Name string
+}
+
+// DiskCache manages data caching on the local filesystem
+type DiskCache struct {
+	directory string
+	options   DiskCacheOptions
+	metrics   *cacheMetrics
+	entries   atomic.Int64 // Only tracked when metrics are enabled
+	bytes     atomic.Int64
+	mu        sync.RWMutex
+}
+
+// CacheItem represents a single cached item with data and expiration
+type CacheItem struct {
+	Data        []byte      `json:"data"`
+	ExpiresAt   time.Time   `json:"expires_at"`
+	Compression Compression `json:"compression,omitempty"`
+}
+
+// NewDiskCache initializes a new DiskCache with the specified directory
+func NewDiskCache(directory string) (*DiskCache, error) {
+	return NewDiskCacheWithOptions(directory, DiskCacheOptions{})
+}
+
+// NewDiskCacheWithOptions initializes a new DiskCache with the specified directory and
+// options. Temporary files left behind by writes that were interrupted are removed.
+func NewDiskCacheWithOptions(directory string, options DiskCacheOptions) (*DiskCache, error) {
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	files, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+	if options.Codec == nil {
+		options.Codec = JSONCodec{}
+	}
+	if options.Name == "" {
+		options.Name = "disk"
+	}
+	if _, err := compress(options.Compression, nil); err != nil {
+		return nil, err
+	}
+
+	dc := &DiskCache{
+		directory: directory,
+		options:   options,
+		metrics:   newCacheMetrics(options.Metrics, options.Name),
+	}
+	for _, file := range files {
+		if strings.HasPrefix(file.Name(), tempFilePrefix) {
+			_ = os.Remove(filepath.Join(directory, file.Name()))
+		} else if dc.metrics != nil && strings.HasSuffix(file.Name(), ".json") {
+			if info, err := file.Info(); err == nil {
+				dc.entries.Add(1)
+				dc.bytes.Add(info.Size())
+			}
+		}
+	}
+	dc.reportSize()
+	return dc, nil
+}
+
+// Set stores a key-value pair in the cache with an expiration duration
+func (dc *DiskCache) Set(key string, data []byte, ttl time.Duration) error {
+	defer dc.metrics.observe("set", time.Now())
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	if err := dc.set(key, data, ttl); err != nil {
+		return err
+	}
+	dc.syncDir()
+	return nil
+}
+
+// MSet stores several key-value pairs with the same expiration duration, holding the
+// lock once and, with Sync enabled, syncing the directory once for the whole batch
+func (dc *DiskCache) MSet(items map[string][]byte, ttl time.Duration) error {
+	defer dc.metrics.observe("mset", time.Now())
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	defer dc.syncDir()
+
+	for key, data := range items {
+		if err := dc.set(key, data, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// set writes the entry for key.
+// This method is not thread-safe and should be called with the lock held.
+func (dc *DiskCache) set(key string, data []byte, ttl time.Duration) error {
+	data, err := compress(dc.options.Compression, data)
+	if err != nil {
+		return err
+	}
+
+	item := CacheItem{
+		Data:        data,
+		ExpiresAt:   time.Now().Add(ttl),
+		Compression: dc.options.Compression,
+	}
+
+	filePath := filepath.Join(dc.directory, key+".json")
+	var previous os.FileInfo
+	if dc.metrics != nil {
+		previous, _ = os.Stat(filePath)
+	}
+	size, err := dc.writeFile(filePath, func(w io.Writer) error {
+		if err := json.NewEncoder(w).Encode(item); err != nil {
+			return fmt.Errorf("failed to marshal cache item: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if dc.metrics != nil {
+		if previous != nil {
+			dc.bytes.Add(-previous.Size())
+		} else {
+			dc.entries.Add(1)
+		}
+		dc.bytes.Add(size)
+		dc.reportSize()
+	}
+	return nil
+}
+
+// writeFile atomically replaces filePath with what write streams into a temporary file
+// in the cache directory, renaming it over filePath, and returns the size of the file.
+func (dc *DiskCache) writeFile(filePath string, write func(w io.Writer) error) (int64, error) {
+	file, err := os.CreateTemp(dc.directory, tempFilePrefix+"*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create cache file: %w", err)
+	}
+	tempPath := file.Name()
+
+	buf := bufio.NewWriter(file)
+	err = write(buf)
+	if err == nil {
+		err = buf.Flush()
+	}
+	var size int64
+	if err == nil {
+		size, err = file.Seek(0, io.SeekCurrent)
+	}
+	if err == nil && dc.options.Sync {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tempPath, 0644)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return 0, fmt.Errorf("failed to write cache file: %w", err)
+	}
+
+	if err := os.Rename(tempPath, filePath); err != nil {
+		os.Remove(tempPath)
+		return 0, fmt.Errorf("failed to write cache file: %w", err)
+	}
+	return size, nil
+}
+
+// syncDir persists renames in the cache directory when Sync is enabled. Not every
+// platform supports syncing a directory, so failures are ignored.
+func (dc *DiskCache) syncDir() {
+	if !dc.options.Sync {
+		return
+	}
+	if dir, err := os.Open(dc.directory); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+}
+
+// Get retrieves a value from the cache by key, returning nil if expired or not found
+func (dc *DiskCache) Get(key string) ([]byte, error) {
+	defer dc.metrics.observe("get", time.Now())
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
+	return dc.get(key)
+}
+
+// MGet retrieves the values of several keys under a single lock, leaving out keys
+// that are expired or not found
+func (dc *DiskCache) MGet(keys []string) (map[string][]byte, error) {
+	defer dc.metrics.observe("mget", time.Now())
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
+
+	result := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		data, err := dc.get(key)
+		if err != nil {
+			return nil, err
+		}
+		if data != nil {
+			result[key] = data
+		}
+	}
+	return result, nil
+}
+
+// get reads the entry for key.
+// This method is not thread-safe and should be called with the lock held.
+func (dc *DiskCache) get(key string) ([]byte, error) {
+	filePath := filepath.Join(dc.directory, key+".json")
+	file, err := os.Open(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		dc.metrics.lookup(false)
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read cache file: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache file: %w", err)
+	}
+
+	// Decode from the file rather than reading it whole first, so large entries are
+	// only held in memory once
+	var item CacheItem
+	if err := json.NewDecoder(bufio.NewReader(file)).Decode(&item); err != nil {
+		dc.evict(filePath, info.Size()) // Remove unreadable item, e.g. one written before a crash
+		return nil, nil
+	}
+
+	if time.Now().After(item.ExpiresAt) {
+		dc.evict(filePath, info.Size()) // Remove expired item
+		return nil, nil
+	}
+
+	data, err := decompress(item.Compression, item.Data)
+	if err != nil {
+		dc.evict(filePath, info.Size()) // Remove unreadable item
+		return nil, nil
+	}
+	dc.metrics.lookup(true)
+	return data, nil
+}
+
+// evict removes an expired or unreadable entry found by Get, counting it as a miss.
+func (dc *DiskCache) evict(filePath string, size int64) {
+	dc.metrics.lookup(false)
+
+	// Concurrent readers may find the same entry; only the one that removes it counts it
+	if err := os.Remove(filePath); err == nil && dc.metrics != nil {
+		dc.metrics.evicted(1)
+		dc.entries.Add(-1)
+		dc.bytes.Add(-size)
+		dc.reportSize()
+	}
+}
+
+// reportSize records the number of entries and bytes in the cache.
+func (dc *DiskCache) reportSize() {
+	dc.metrics.size(int(dc.entries.Load()), dc.bytes.Load())
+}
+
+// SetValue encodes v with the cache's codec and stores it under key
+func (dc *DiskCache) SetValue(key string, v interface{}, ttl time.Duration) error {
+	return SetValue(dc, dc.options.Codec, key, v, ttl)
+}
+
+// GetValue decodes the value stored under key into target with the cache's codec,
+// reporting whether the key was found
+func (dc *DiskCache) GetValue(key string, target interface{}) (bool, error) {
+	return GetValue(dc, dc.options.Codec, key, target)
+}
+
+// Delete removes a cached item by key
+func (dc *DiskCache) Delete(key string) error {
+	defer dc.metrics.observe("delete", time.Now())
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	return dc.delete(key)
+}
+
+// MDelete removes several cached items under a single lock. Every key is attempted;
+// the failures are joined into the returned error
+func (dc *DiskCache) MDelete(keys []string) error {
+	defer dc.metrics.observe("mdelete", time.Now())
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	var errs []error
+	for _, key := range keys {
+		errs = append(errs, dc.delete(key))
+	}
+	return errors.Join(errs...)
+}
+
+// delete removes the entry for key.
+// This method is not thread-safe and should be called with the lock held.
+func (dc *DiskCache) delete(key string) error {
+	filePath := filepath.Join(dc.directory, key+".json")
+	var previous os.FileInfo
+	if dc.metrics != nil {
+		previous, _ = os.Stat(filePath)
+	}
+	if err := os.Remove(filePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete cache file: %w", err)
+	}
+
+	if previous != nil {
+		dc.entries.Add(-1)
+		dc.bytes.Add(-previous.Size())
+		dc.reportSize()
+	}
+	return nil
+}
+
+// Clear removes all cached items. Files that cannot be removed do not stop the others
+// from being removed; the failures are joined into the returned error
+func (dc *DiskCache) Clear() error {
+	defer dc.metrics.observe("clear", time.Now())
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	defer func() {
+		if dc.metrics != nil {
+			dc.entries.Store(0)
+			dc.bytes.Store(0)
+			dc.reportSize()
+		}
+	}()
+
+	files, err := os.ReadDir(dc.directory)
+	if err != nil {
+		return fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	var errs []error
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dc.directory, file.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("failed to clear cache file: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sync"
@@ -14,7 +13,7 @@ import (
 
 func TestNewDiskCache(t *testing.T) {
 	// Create a temporary directory for testing
-	tempDir, err := ioutil.TempDir("", "disk-cache-test")
+	tempDir, err := os.MkdirTemp("", "disk-cache-test")
 	if err != nil {
 		t.Fatalf("Failed to create temp directory: %v", err)
 	}
@@ -49,7 +48,7 @@ func TestNewDiskCache(t *testing.T) {
 
 func TestDiskCacheSetGet(t *testing.T) {
 	// Create a temporary directory for testing
-	tempDir, err := ioutil.TempDir("", "disk-cache-test")
+	tempDir, err := os.MkdirTemp("", "disk-cache-test")
 	if err != nil {
 		t.Fatalf("Failed to create temp directory: %v", err)
 	}
@@ -77,7 +76,7 @@ func TestDiskCacheSetGet(t *testing.T) {
 	}
 	
 	// Read the file directly to verify its contents
-	fileData, err := ioutil.ReadFile(filePath)
+	fileData, err := os.ReadFile(filePath)
 	if err != nil {
 		t.Fatalf("Failed to read cache file: %v", err)
 	}
@@ -120,7 +119,7 @@ func TestDiskCacheSetGet(t *testing.T) {
 
 func TestDiskCacheExpiration(t *testing.T) {
 	// Create a temporary directory for testing
-	tempDir, err := ioutil.TempDir("", "disk-cache-test")
+	tempDir, err := os.MkdirTemp("", "disk-cache-test")
 	if err != nil {
 		t.Fatalf("Failed to create temp directory: %v", err)
 	}
@@ -163,7 +162,7 @@ func TestDiskCacheExpiration(t *testing.T) {
 
 func TestDiskCacheDelete(t *testing.T) {
 	// Create a temporary directory for testing
-	tempDir, err := ioutil.TempDir("", "disk-cache-test")
+	tempDir, err := os.MkdirTemp("", "disk-cache-test")
 	if err != nil {
 		t.Fatalf("Failed to create temp directory: %v", err)
 	}
@@ -220,7 +219,7 @@ func TestDiskCacheDelete(t *testing.T) {
 
 func TestDiskCacheClear(t *testing.T) {
 	// Create a temporary directory for testing
-	tempDir, err := ioutil.TempDir("", "disk-cache-test")
+	tempDir, err := os.MkdirTemp("", "disk-cache-test")
 	if err != nil {
 		t.Fatalf("Failed to create temp directory: %v", err)
 	}
@@ -273,7 +272,7 @@ func TestDiskCacheClear(t *testing.T) {
 
 func TestDiskCacheConcurrency(t *testing.T) {
 	// Create a temporary directory for testing
-	tempDir, err := ioutil.TempDir("", "disk-cache-test")
+	tempDir, err := os.MkdirTemp("", "disk-cache-test")
 	if err != nil {
 		t.Fatalf("Failed to create temp directory: %v", err)
 	}
@@ -329,3 +328,137 @@ func TestDiskCacheConcurrency(t *testing.T) {
 	
 	wg.Wait()
 }
+
+func TestDiskCacheCorruptEntry(t *testing.T) {
+	tempDir := t.TempDir()
+	cache, err := NewDiskCache(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create disk cache: %v", err)
+	}
+
+	filePath := filepath.Join(tempDir, "corrupt.json")
+	if err := os.WriteFile(filePath, []byte(`{"data":"dHJ1bmNh`), 0644); err != nil {
+		t.Fatalf("Failed to write corrupt cache file: %v", err)
+	}
+
+	value, err := cache.Get("corrupt")
+	if err != nil {
+		t.Fatalf("Expected a corrupt entry to be a miss, got error: %v", err)
+	}
+	if value != nil {
+		t.Errorf("Expected nil value for a corrupt entry, got '%s'", value)
+	}
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		t.Error("Expected the corrupt cache file to be removed")
+	}
+}
+
+func TestDiskCacheAtomicWrites(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Temporary files from an interrupted write are cleaned up on open
+	stale := filepath.Join(tempDir, tempFilePrefix+"123")
+	if err := os.WriteFile(stale, []byte("partial"), 0644); err != nil {
+		t.Fatalf("Failed to write temporary file: %v", err)
+	}
+
+	cache, err := NewDiskCacheWithOptions(tempDir, DiskCacheOptions{Sync: true})
+	if err != nil {
+		t.Fatalf("Failed to create disk cache: %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("Expected the stale temporary file to be removed")
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := cache.Set("key", []byte(fmt.Sprintf("value%d", i)), time.Hour); err != nil {
+			t.Fatalf("Failed to set cache value: %v", err)
+		}
+	}
+	value, _ := cache.Get("key")
+	if !bytes.Equal(value, []byte("value2")) {
+		t.Errorf("Expected value 'value2', got '%s'", value)
+	}
+
+	files, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to read cache directory: %v", err)
+	}
+	if len(files) != 1 || files[0].Name() != "key.json" {
+		names := make([]string, len(files))
+		for i, file := range files {
+			names[i] = file.Name()
+		}
+		t.Errorf("Expected only key.json in the cache directory, got %v", names)
+	}
+}
+
+func TestDiskCacheCompressionAndCodec(t *testing.T) {
+	tempDir := t.TempDir()
+	cache, err := NewDiskCacheWithOptions(tempDir, DiskCacheOptions{
+		Codec:       GobCodec{},
+		Compression: CompressionZstd,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create disk cache: %v", err)
+	}
+
+	value := []float64{0.25, 0.5, 0.75}
+	if err := cache.SetValue("vector", value, time.Hour); err != nil {
+		t.Fatalf("Failed to set cache value: %v", err)
+	}
+
+	var decoded []float64
+	found, err := cache.GetValue("vector", &decoded)
+	if err != nil || !found {
+		t.Fatalf("Expected value to be found, got %v, %v", found, err)
+	}
+	if len(decoded) != 3 || decoded[2] != 0.75 {
+		t.Errorf("Expected %v, got %v", value, decoded)
+	}
+
+	// Entries record their compression, so they stay readable after it changes
+	if err := cache.Set("text", []byte("compressed text"), time.Hour); err != nil {
+		t.Fatalf("Failed to set cache value: %v", err)
+	}
+	uncompressed, err := NewDiskCache(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create disk cache: %v", err)
+	}
+	data, err := uncompressed.Get("text")
+	if err != nil || !bytes.Equal(data, []byte("compressed text")) {
+		t.Errorf("Expected value 'compressed text', got '%s', %v", data, err)
+	}
+
+	if _, err := NewDiskCacheWithOptions(tempDir, DiskCacheOptions{Compression: "lz4"}); err == nil {
+		t.Error("Expected an error for an unsupported compression")
+	}
+}
+
+func TestDiskCacheBatch(t *testing.T) {
+	cache, err := NewDiskCacheWithOptions(t.TempDir(), DiskCacheOptions{Sync: true})
+	if err != nil {
+		t.Fatalf("Failed to create disk cache: %v", err)
+	}
+
+	err = cache.MSet(map[string][]byte{"a": []byte("1"), "b": []byte("2"), "c": []byte("3")}, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to set values: %v", err)
+	}
+
+	values, err := cache.MGet([]string{"a", "b", "missing"})
+	if err != nil {
+		t.Fatalf("Failed to get values: %v", err)
+	}
+	if len(values) != 2 || string(values["a"]) != "1" || string(values["b"]) != "2" {
+		t.Errorf("Expected values for a and b only, got %v", values)
+	}
+
+	if err := cache.MDelete([]string{"a", "b", "missing"}); err != nil {
+		t.Fatalf("Failed to delete values: %v", err)
+	}
+	values, _ = cache.MGet([]string{"a", "b", "c"})
+	if len(values) != 1 || string(values["c"]) != "3" {
+		t.Errorf("Expected only c to remain, got %v", values)
+	}
+}
@@ -1,71 +1,579 @@
-package cache
-
-import (
-	"context"
-	"encoding/json"
-	"fmt"
-	"time"
-
-	"github.com/go-redis/redis/v8"
-)
-
-// DistributedCache provides a Redis-based distributed caching mechanism
-type DistributedCache struct {
-	client *redis.Client
-	ctx    context.Context
-}
-
-// NewDistributedCache initializes a new DistributedCache with the given Redis address
-func NewDistributedCache(redisAddr string) *DistributedCache {
-	client := redis.NewClient(&redis.Options{
-		Addr: redisAddr,
-	})
-	return &DistributedCache{
-		client: client,
-		ctx:    context.Background(),
-	}
-}
-
-// Set stores a key-value pair in the cache with an expiration duration
-func (dc *DistributedCache) Set(key string, data interface{}, ttl time.Duration) error {
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return fmt.Errorf("failed to marshal data: %w", err)
-	}
-
-	if err := dc.client.Set(dc.ctx, key, jsonData, ttl).Err(); err != nil {
-		return fmt.Errorf("failed to set cache data: %w", err)
-	}
-	return nil
-}
-
-// Get retrieves a value from the cache by key, returning nil if not found or expired
-func (dc *DistributedCache) Get(key string, target interface{}) error {
-	jsonData, err := dc.client.Get(dc.ctx, key).Bytes()
-	if err == redis.Nil {
-		return fmt.Errorf("key not found in cache")
-	} else if err != nil {
-		return fmt.Errorf("failed to get cache data: %w", err)
-	}
-
-	if err := json.Unmarshal(jsonData, target); err != nil {
-		return fmt.Errorf("failed to unmarshal cache data: %w", err)
-	}
-	return nil
-}
-
-// Delete removes a cached item by key
-func (dc *DistributedCache) Delete(key string) error {
-	if err := dc.client.Del(dc.ctx, key).Err(); err != nil {
-		return fmt.Errorf("failed to delete cache data: %w", err)
-	}
-	return nil
-}
-
-// Clear flushes all data from the cache
-func (dc *DistributedCache) Clear() error {
-	if err := dc.client.FlushDB(dc.ctx).Err(); err != nil {
-		return fmt.Errorf("failed to clear cache: %w", err)
-	}
-	return nil
-}
+package cache
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/h2co32/gollama/internal/metrics"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation name of the default tracer.
+const tracerName = "github.com/h2co32/gollama/internal/cache"
+
+// DistributedCacheOptions configures a DistributedCache.
+type DistributedCacheOptions struct {
+	// Addr is the Redis server address. Ignored when Addrs is set.
+	// Default: "localhost:6379"
+	Addr string
+
+	// Addrs are the seed addresses of a Redis Cluster when Cluster is set, or the
+	// Sentinel addresses when MasterName is set.
+	Addrs []string
+
+	// Cluster connects to a Redis Cluster through the nodes in Addrs.
+	// Default: false
+	Cluster bool
+
+	// MasterName is the name of the master monitored by the Sentinels in Addrs. When
+	// set, the cache follows Sentinel failover to the current master.
+	MasterName string
+
+	// Username and Password authenticate to Redis with AUTH. Username requires Redis 6
+	// ACLs; leave it empty to authenticate with the password only.
+	Username string
+	Password string
+
+	// SentinelUsername and SentinelPassword authenticate to the Sentinels, when they
+	// require different credentials from the master.
+	SentinelUsername string
+	SentinelPassword string
+
+	// TLSConfig enables TLS for connections to Redis. Optional.
+	TLSConfig *tls.Config
+
+	// DB is the database selected after connecting. Not supported by Redis Cluster.
+	// Default: 0
+	DB int
+
+	// PoolSize is the maximum number of connections per node.
+	// Default: 10 per CPU, as chosen by the Redis client
+	PoolSize int
+
+	// MinIdleConns is the number of idle connections kept open per node.
+	// Default: 0
+	MinIdleConns int
+
+	// DialTimeout, ReadTimeout, and WriteTimeout bound connecting to Redis and each
+	// network read and write.
+	// Default: 5 seconds, 3 seconds, and 3 seconds, as chosen by the Redis client
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// OperationTimeout bounds each cache operation, including retries, on top of any
+	// deadline of the context passed to it. Zero means no additional limit.
+	// Default: 0
+	OperationTimeout time.Duration
+
+	// Namespace is prepended, followed by a colon, to every key, so several caches can
+	// share a Redis database and Clear only removes this cache's keys. Without a
+	// namespace, Clear removes every key in the database.
+	// Default: ""
+	Namespace string
+
+	// Codec encodes the values passed to Set and Get.
+	// Default: JSONCodec
+	Codec Codec
+
+	// Compression compresses stored values. Values are read back with the same
+	// setting, so the cache must be cleared when it changes.
+	// Default: CompressionNone
+	Compression Compression
+
+	// Metrics records hits, misses, and operation latency. Entry counts and sizes are
+	// not reported, as Redis exporters already provide them. Optional.
+	Metrics *metrics.MetricsProvider
+
+	// Name labels the cache's metrics and spans.
+	// Default: "redis"
+	Name string
+
+	// Tracer starts a "cache.<operation>" span, such as "cache.get", for each operation
+	// given a context with a span, as a child of that span. Operations without one,
+	// including those of Bytes, are not traced, so they do not each start a trace.
+	// Default: a tracer from the global OpenTelemetry tracer provider
+	Tracer trace.Tracer
+}
+
+// DefaultDistributedCacheOptions returns the default options for a DistributedCache.
+func DefaultDistributedCacheOptions() DistributedCacheOptions {
+	return DistributedCacheOptions{
+		Addr:   "localhost:6379",
+		Codec:  JSONCodec{},
+		Name:   "redis",
+		Tracer: otel.Tracer(tracerName),
+	}
+}
+
+// scanBatchSize is the number of keys requested from each SCAN call.
+const scanBatchSize = 1000
+
+// DistributedCache provides a Redis-based distributed caching mechanism
+type DistributedCache struct {
+	client  redis.UniversalClient
+	options DistributedCacheOptions
+	prefix  string
+	metrics *cacheMetrics
+}
+
+// NewDistributedCache initializes a new DistributedCache with the given Redis address
+func NewDistributedCache(redisAddr string) *DistributedCache {
+	cache, _ := NewDistributedCacheWithOptions(DistributedCacheOptions{Addr: redisAddr})
+	return cache
+}
+
+// NewDistributedCacheWithOptions initializes a new DistributedCache with the given
+// options, connecting to a single Redis server, a Redis Cluster, or the master of a
+// Sentinel deployment. Zero values fall back to the defaults.
+func NewDistributedCacheWithOptions(options DistributedCacheOptions) (*DistributedCache, error) {
+	defaults := DefaultDistributedCacheOptions()
+	if options.Addr == "" {
+		options.Addr = defaults.Addr
+	}
+	if options.Codec == nil {
+		options.Codec = defaults.Codec
+	}
+	if options.Name == "" {
+		options.Name = defaults.Name
+	}
+	if options.Tracer == nil {
+		options.Tracer = defaults.Tracer
+	}
+	if _, err := compress(options.Compression, nil); err != nil {
+		return nil, err
+	}
+	if options.Cluster && options.MasterName != "" {
+		return nil, fmt.Errorf("cluster and sentinel modes cannot be combined")
+	}
+	if options.Cluster && options.DB != 0 {
+		return nil, fmt.Errorf("redis cluster does not support database selection")
+	}
+
+	addrs := options.Addrs
+	if len(addrs) == 0 {
+		addrs = []string{options.Addr}
+	}
+	universal := &redis.UniversalOptions{
+		Addrs:            addrs,
+		DB:               options.DB,
+		Username:         options.Username,
+		Password:         options.Password,
+		SentinelUsername: options.SentinelUsername,
+		SentinelPassword: options.SentinelPassword,
+		TLSConfig:        options.TLSConfig,
+		PoolSize:         options.PoolSize,
+		MinIdleConns:     options.MinIdleConns,
+		DialTimeout:      options.DialTimeout,
+		ReadTimeout:      options.ReadTimeout,
+		WriteTimeout:     options.WriteTimeout,
+		MasterName:       options.MasterName,
+	}
+
+	var client redis.UniversalClient
+	switch {
+	case options.MasterName != "":
+		client = redis.NewFailoverClient(universal.Failover())
+	case options.Cluster:
+		client = redis.NewClusterClient(universal.Cluster())
+	default:
+		client = redis.NewClient(universal.Simple())
+	}
+
+	cache := &DistributedCache{
+		client:  client,
+		options: options,
+		metrics: newCacheMetrics(options.Metrics, options.Name),
+	}
+	if options.Namespace != "" {
+		cache.prefix = options.Namespace + ":"
+	}
+	return cache, nil
+}
+
+// Ping checks that Redis is reachable.
+func (dc *DistributedCache) Ping(ctx context.Context) error {
+	ctx, cancel := dc.operationContext(ctx)
+	defer cancel()
+
+	if err := dc.client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("failed to ping redis: %w", err)
+	}
+	return nil
+}
+
+// Close closes the connections to Redis.
+func (dc *DistributedCache) Close() error {
+	return dc.client.Close()
+}
+
+// startSpan starts the span of an operation as a child of the span in ctx, or returns
+// the non-recording span in ctx if there is none.
+func (dc *DistributedCache) startSpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	return dc.options.Tracer.Start(ctx, "cache."+operation, trace.WithAttributes(
+		attribute.String("cache.name", dc.options.Name),
+		attribute.String("db.system", "redis"),
+	))
+}
+
+// endSpan records err, if any, on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// operationContext applies OperationTimeout to ctx.
+func (dc *DistributedCache) operationContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if dc.options.OperationTimeout > 0 {
+		return context.WithTimeout(ctx, dc.options.OperationTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// Set stores a key-value pair in the cache with an expiration duration
+func (dc *DistributedCache) Set(key string, data interface{}, ttl time.Duration) error {
+	return dc.SetContext(context.Background(), key, data, ttl)
+}
+
+// SetContext is like Set but bounds the operation by ctx
+func (dc *DistributedCache) SetContext(ctx context.Context, key string, data interface{}, ttl time.Duration) error {
+	return dc.setWithCodec(ctx, key, data, ttl, dc.options.Codec)
+}
+
+// SetWithCodec stores a key-value pair in the cache, encoding it with codec instead
+// of the cache's codec
+func (dc *DistributedCache) SetWithCodec(key string, data interface{}, ttl time.Duration, codec Codec) error {
+	return dc.setWithCodec(context.Background(), key, data, ttl, codec)
+}
+
+// setWithCodec encodes data with codec and stores it under key.
+func (dc *DistributedCache) setWithCodec(ctx context.Context, key string, data interface{}, ttl time.Duration, codec Codec) error {
+	encoded, err := codec.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data: %w", err)
+	}
+	return dc.setBytes(ctx, key, encoded, ttl)
+}
+
+// Get retrieves a value from the cache by key, returning nil if not found or expired
+func (dc *DistributedCache) Get(key string, target interface{}) error {
+	return dc.GetContext(context.Background(), key, target)
+}
+
+// GetContext is like Get but bounds the operation by ctx
+func (dc *DistributedCache) GetContext(ctx context.Context, key string, target interface{}) error {
+	return dc.getWithCodec(ctx, key, target, dc.options.Codec)
+}
+
+// GetWithCodec retrieves a value from the cache by key, decoding it with codec instead
+// of the cache's codec
+func (dc *DistributedCache) GetWithCodec(key string, target interface{}, codec Codec) error {
+	return dc.getWithCodec(context.Background(), key, target, codec)
+}
+
+// getWithCodec reads the value stored under key and decodes it into target with codec.
+func (dc *DistributedCache) getWithCodec(ctx context.Context, key string, target interface{}, codec Codec) error {
+	data, err := dc.getBytes(ctx, key)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		return fmt.Errorf("key not found in cache")
+	}
+
+	if err := codec.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("failed to unmarshal cache data: %w", err)
+	}
+	return nil
+}
+
+// setBytes compresses data and stores it under key.
+func (dc *DistributedCache) setBytes(ctx context.Context, key string, data []byte, ttl time.Duration) (err error) {
+	ctx, span := dc.startSpan(ctx, "set")
+	defer func() { endSpan(span, err) }()
+	defer dc.metrics.observe("set", time.Now())
+	data, err = compress(dc.options.Compression, data)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := dc.operationContext(ctx)
+	defer cancel()
+	if err := dc.client.Set(ctx, dc.prefix+key, data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set cache data: %w", err)
+	}
+	return nil
+}
+
+// getBytes reads and decompresses the data stored under key, returning nil if not
+// found or expired.
+func (dc *DistributedCache) getBytes(ctx context.Context, key string) (_ []byte, err error) {
+	ctx, span := dc.startSpan(ctx, "get")
+	defer func() { endSpan(span, err) }()
+	defer dc.metrics.observe("get", time.Now())
+	ctx, cancel := dc.operationContext(ctx)
+	defer cancel()
+
+	data, err := dc.client.Get(ctx, dc.prefix+key).Bytes()
+	if err == redis.Nil {
+		dc.metrics.lookup(false)
+		span.SetAttributes(attribute.Bool("cache.hit", false))
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get cache data: %w", err)
+	}
+	dc.metrics.lookup(true)
+	span.SetAttributes(attribute.Bool("cache.hit", true))
+	return decompress(dc.options.Compression, data)
+}
+
+// Delete removes a cached item by key
+func (dc *DistributedCache) Delete(key string) error {
+	return dc.DeleteContext(context.Background(), key)
+}
+
+// DeleteContext is like Delete but bounds the operation by ctx
+func (dc *DistributedCache) DeleteContext(ctx context.Context, key string) (err error) {
+	ctx, span := dc.startSpan(ctx, "delete")
+	defer func() { endSpan(span, err) }()
+	defer dc.metrics.observe("delete", time.Now())
+	ctx, cancel := dc.operationContext(ctx)
+	defer cancel()
+
+	if err := dc.client.Del(ctx, dc.prefix+key).Err(); err != nil {
+		return fmt.Errorf("failed to delete cache data: %w", err)
+	}
+	return nil
+}
+
+// MDelete removes several cached items in a single pipelined round trip
+func (dc *DistributedCache) MDelete(keys []string) error {
+	return dc.MDeleteContext(context.Background(), keys)
+}
+
+// MDeleteContext is like MDelete but bounds the operation by ctx
+func (dc *DistributedCache) MDeleteContext(ctx context.Context, keys []string) (err error) {
+	ctx, span := dc.startSpan(ctx, "mdelete")
+	defer func() { endSpan(span, err) }()
+	defer dc.metrics.observe("mdelete", time.Now())
+	ctx, cancel := dc.operationContext(ctx)
+	defer cancel()
+
+	// Delete keys individually, as a cluster rejects multi-key commands across slots
+	pipe := dc.client.Pipeline()
+	for _, key := range keys {
+		pipe.Del(ctx, dc.prefix+key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to delete cache data: %w", err)
+	}
+	return nil
+}
+
+// msetBytes compresses and stores several items in a single pipelined round trip.
+func (dc *DistributedCache) msetBytes(ctx context.Context, items map[string][]byte, ttl time.Duration) (err error) {
+	ctx, span := dc.startSpan(ctx, "mset")
+	defer func() { endSpan(span, err) }()
+	defer dc.metrics.observe("mset", time.Now())
+	ctx, cancel := dc.operationContext(ctx)
+	defer cancel()
+
+	pipe := dc.client.Pipeline()
+	for key, data := range items {
+		data, err := compress(dc.options.Compression, data)
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, dc.prefix+key, data, ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to set cache data: %w", err)
+	}
+	return nil
+}
+
+// mgetBytes reads and decompresses several keys in a single pipelined round trip,
+// leaving out keys that are not found or expired.
+func (dc *DistributedCache) mgetBytes(ctx context.Context, keys []string) (_ map[string][]byte, err error) {
+	ctx, span := dc.startSpan(ctx, "mget")
+	defer func() { endSpan(span, err) }()
+	defer dc.metrics.observe("mget", time.Now())
+	ctx, cancel := dc.operationContext(ctx)
+	defer cancel()
+
+	// Pipelined GETs rather than MGET, which a cluster rejects across slots
+	pipe := dc.client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Get(ctx, dc.prefix+key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get cache data: %w", err)
+	}
+
+	result := make(map[string][]byte, len(keys))
+	for i, cmd := range cmds {
+		data, err := cmd.Bytes()
+		if err == redis.Nil {
+			dc.metrics.lookup(false)
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get cache data: %w", err)
+		}
+		dc.metrics.lookup(true)
+
+		if data, err = decompress(dc.options.Compression, data); err != nil {
+			return nil, err
+		}
+		result[keys[i]] = data
+	}
+	return result, nil
+}
+
+// Clear removes every key in the cache's namespace, scanning for them rather than
+// flushing the database so other data in it is left alone
+func (dc *DistributedCache) Clear() error {
+	return dc.ClearContext(context.Background())
+}
+
+// ClearContext is like Clear but bounds the operation by ctx
+func (dc *DistributedCache) ClearContext(ctx context.Context) (err error) {
+	ctx, span := dc.startSpan(ctx, "clear")
+	defer func() { endSpan(span, err) }()
+	defer dc.metrics.observe("clear", time.Now())
+	ctx, cancel := dc.operationContext(ctx)
+	defer cancel()
+
+	err = dc.scan(ctx, escapePattern(dc.prefix)+"*", func(ctx context.Context, client *redis.Client, keys []string) error {
+		// Delete keys individually, as a cluster rejects multi-key commands across slots
+		pipe := client.Pipeline()
+		for _, key := range keys {
+			pipe.Del(ctx, key)
+		}
+		_, err := pipe.Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to clear cache: %w", err)
+	}
+	return nil
+}
+
+// Keys returns the keys in the cache's namespace matching a glob-style pattern, such
+// as "user:*", without the namespace prefix
+func (dc *DistributedCache) Keys(pattern string) ([]string, error) {
+	return dc.KeysContext(context.Background(), pattern)
+}
+
+// KeysContext is like Keys but bounds the operation by ctx
+func (dc *DistributedCache) KeysContext(ctx context.Context, pattern string) ([]string, error) {
+	ctx, cancel := dc.operationContext(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	err := dc.scan(ctx, escapePattern(dc.prefix)+pattern, func(ctx context.Context, client *redis.Client, keys []string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, key := range keys {
+			seen[strings.TrimPrefix(key, dc.prefix)] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cache keys: %w", err)
+	}
+
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// scan calls fn with each batch of keys matching match, on every master node of a
+// cluster or on the single server otherwise. A key may be passed more than once.
+func (dc *DistributedCache) scan(ctx context.Context, match string, fn func(ctx context.Context, client *redis.Client, keys []string) error) error {
+	scanNode := func(ctx context.Context, client *redis.Client) error {
+		var cursor uint64
+		for {
+			keys, next, err := client.Scan(ctx, cursor, match, scanBatchSize).Result()
+			if err != nil {
+				return err
+			}
+			if len(keys) > 0 {
+				if err := fn(ctx, client, keys); err != nil {
+					return err
+				}
+			}
+			if next == 0 {
+				return nil
+			}
+			cursor = next
+		}
+	}
+
+	if cluster, ok := dc.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, scanNode)
+	}
+	return scanNode(ctx, dc.client.(*redis.Client))
+}
+
+// escapePattern escapes the glob characters in s for use in a SCAN pattern.
+func escapePattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Bytes returns a Cache view of the distributed cache that stores byte slices without
+// encoding them with the codec, so it can be used as a level of a TieredCache.
+func (dc *DistributedCache) Bytes() Cache {
+	return distributedBytesCache{dc}
+}
+
+// distributedBytesCache adapts a DistributedCache to the Cache interface.
+type distributedBytesCache struct {
+	*DistributedCache
+}
+
+// Set stores data under key with an expiration duration
+func (c distributedBytesCache) Set(key string, data []byte, ttl time.Duration) error {
+	return c.setBytes(context.Background(), key, data, ttl)
+}
+
+// Get retrieves the data stored under key, returning nil if not found or expired
+func (c distributedBytesCache) Get(key string) ([]byte, error) {
+	return c.getBytes(context.Background(), key)
+}
+
+// MGet retrieves several keys in a single pipelined round trip
+func (c distributedBytesCache) MGet(keys []string) (map[string][]byte, error) {
+	return c.mgetBytes(context.Background(), keys)
+}
+
+// MSet stores several items in a single pipelined round trip
+func (c distributedBytesCache) MSet(items map[string][]byte, ttl time.Duration) error {
+	return c.msetBytes(context.Background(), items, ttl)
+}
@@ -9,6 +9,9 @@ import (
 	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 // TestDistributedCacheSetGet tests the Set and Get methods of DistributedCache
@@ -224,6 +227,206 @@ func TestDistributedCacheRedisError(t *testing.T) {
 	assert.Error(t, err, "Expected error for invalid Redis address")
 }
 
+// TestDistributedCacheBytes tests the Cache view returned by Bytes
+func TestDistributedCacheBytes(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	defer s.Close()
+
+	cache := NewDistributedCache(s.Addr()).Bytes()
+
+	err = cache.Set("key", []byte("raw value"), 1*time.Hour)
+	require.NoError(t, err, "Failed to set cache value")
+
+	// Values are stored without JSON encoding
+	stored, err := s.Get("key")
+	require.NoError(t, err)
+	assert.Equal(t, "raw value", stored)
+
+	data, err := cache.Get("key")
+	require.NoError(t, err, "Failed to get cache value")
+	assert.Equal(t, []byte("raw value"), data)
+
+	// Missing keys are a nil result rather than an error
+	data, err = cache.Get("non-existent-key")
+	assert.NoError(t, err)
+	assert.Nil(t, data)
+}
+
+// TestDistributedCacheCodecAndCompression tests custom codecs and compression
+func TestDistributedCacheCodecAndCompression(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	defer s.Close()
+
+	cache, err := NewDistributedCacheWithOptions(DistributedCacheOptions{
+		Addr:        s.Addr(),
+		Codec:       GobCodec{},
+		Compression: CompressionGzip,
+	})
+	require.NoError(t, err)
+
+	value := []float64{0.25, 0.5, 0.75}
+	require.NoError(t, cache.Set("vector", value, 1*time.Hour))
+
+	var retrieved []float64
+	require.NoError(t, cache.Get("vector", &retrieved))
+	assert.Equal(t, value, retrieved)
+
+	// The codec can be chosen per call
+	require.NoError(t, cache.SetWithCodec("blob", []byte("raw"), 1*time.Hour, RawCodec{}))
+	var blob []byte
+	require.NoError(t, cache.GetWithCodec("blob", &blob, RawCodec{}))
+	assert.Equal(t, []byte("raw"), blob)
+
+	// Stored values are compressed
+	stored, err := s.Get("blob")
+	require.NoError(t, err)
+	assert.NotEqual(t, "raw", stored)
+}
+
+// TestDistributedCacheOptions tests authentication, database selection, and contexts
+func TestDistributedCacheOptions(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	defer s.Close()
+	s.RequireAuth("secret")
+
+	// Authentication is required
+	cache := NewDistributedCache(s.Addr())
+	assert.Error(t, cache.Ping(context.Background()), "Expected error without credentials")
+
+	cache, err = NewDistributedCacheWithOptions(DistributedCacheOptions{
+		Addr:             s.Addr(),
+		Password:         "secret",
+		DB:               2,
+		OperationTimeout: 1 * time.Second,
+	})
+	require.NoError(t, err)
+	defer cache.Close()
+	require.NoError(t, cache.Ping(context.Background()))
+
+	require.NoError(t, cache.SetContext(context.Background(), "key", "value", 1*time.Hour))
+	s.Select(2)
+	assert.True(t, s.Exists("key"), "Expected key to be stored in database 2")
+
+	var value string
+	require.NoError(t, cache.GetContext(context.Background(), "key", &value))
+	assert.Equal(t, "value", value)
+
+	// Canceled contexts stop the operation
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, cache.GetContext(ctx, "key", &value), "Expected error for a canceled context")
+
+	require.NoError(t, cache.DeleteContext(context.Background(), "key"))
+	assert.False(t, s.Exists("key"))
+
+	// Invalid combinations are rejected
+	_, err = NewDistributedCacheWithOptions(DistributedCacheOptions{Cluster: true, MasterName: "mymaster"})
+	assert.Error(t, err)
+	_, err = NewDistributedCacheWithOptions(DistributedCacheOptions{Cluster: true, DB: 1})
+	assert.Error(t, err)
+}
+
+// TestDistributedCacheNamespace tests that namespaced caches only see their own keys
+func TestDistributedCacheNamespace(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	defer s.Close()
+
+	models, err := NewDistributedCacheWithOptions(DistributedCacheOptions{Addr: s.Addr(), Namespace: "models"})
+	require.NoError(t, err)
+	users, err := NewDistributedCacheWithOptions(DistributedCacheOptions{Addr: s.Addr(), Namespace: "users*"})
+	require.NoError(t, err)
+	require.NoError(t, s.Set("unrelated", "value"))
+
+	require.NoError(t, models.Set("llama:7b", "a", 1*time.Hour))
+	require.NoError(t, models.Set("llama:13b", "b", 1*time.Hour))
+	require.NoError(t, models.Set("mistral", "c", 1*time.Hour))
+	require.NoError(t, users.Set("1", "d", 1*time.Hour))
+	assert.True(t, s.Exists("models:llama:7b"), "Expected keys to be prefixed with the namespace")
+
+	keys, err := models.Keys("llama:*")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"llama:13b", "llama:7b"}, keys)
+
+	// Glob characters in the namespace are matched literally
+	keys, err = users.Keys("*")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1"}, keys)
+
+	require.NoError(t, models.Clear())
+	keys, err = models.Keys("*")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+	assert.True(t, s.Exists("users*:1"), "Expected other namespaces to be kept")
+	assert.True(t, s.Exists("unrelated"), "Expected keys outside the namespace to be kept")
+}
+
+// TestDistributedCacheMetrics tests hit and miss metrics
+func TestDistributedCacheMetrics(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	defer s.Close()
+
+	cache, err := NewDistributedCacheWithOptions(DistributedCacheOptions{
+		Addr:    s.Addr(),
+		Metrics: testMetrics,
+		Name:    "redis-test",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, cache.Set("key", "value", 1*time.Hour))
+	var value string
+	require.NoError(t, cache.Get("key", &value))
+	assert.Error(t, cache.Get("missing", &value))
+
+	assert.Equal(t, 1.0, metricValue(t, "cache_hits_total", "redis-test"))
+	assert.Equal(t, 1.0, metricValue(t, "cache_misses_total", "redis-test"))
+}
+
+// TestDistributedCacheBatch tests pipelined batch operations
+func TestDistributedCacheBatch(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	defer s.Close()
+
+	dc, err := NewDistributedCacheWithOptions(DistributedCacheOptions{
+		Addr:        s.Addr(),
+		Namespace:   "batch",
+		Compression: CompressionZstd,
+	})
+	require.NoError(t, err)
+	cache := dc.Bytes()
+
+	err = cache.MSet(map[string][]byte{"a": []byte("1"), "b": []byte("2"), "c": []byte("3")}, 1*time.Hour)
+	require.NoError(t, err)
+	assert.True(t, s.Exists("batch:a"))
+	assert.True(t, s.TTL("batch:a") > 0, "Expected TTL to be set")
+
+	values, err := cache.MGet([]string{"a", "b", "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("1"), "b": []byte("2")}, values)
+
+	require.NoError(t, cache.MDelete([]string{"a", "b", "missing"}))
+	values, err = cache.MGet([]string{"a", "b", "c"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"c": []byte("3")}, values)
+}
+
 // Mock implementation for testing Redis errors
 type mockRedisClient struct {
 	redis.Client
@@ -245,3 +448,39 @@ func (m *mockRedisClient) Del(ctx context.Context, keys ...string) *redis.IntCmd
 func (m *mockRedisClient) FlushDB(ctx context.Context) *redis.StatusCmd {
 	return redis.NewStatusCmd(ctx, "")
 }
+
+// TestDistributedCacheTracing tests that operations given a span are traced under it
+func TestDistributedCacheTracing(t *testing.T) {
+	s, err := miniredis.Run()
+	require.NoError(t, err, "Failed to start miniredis")
+	defer s.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	cache, err := NewDistributedCacheWithOptions(DistributedCacheOptions{
+		Addr:   s.Addr(),
+		Name:   "responses",
+		Tracer: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test"),
+	})
+	require.NoError(t, err)
+
+	ctx, parent := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "request")
+	require.NoError(t, cache.SetContext(ctx, "key", "value", time.Minute))
+	var value string
+	require.NoError(t, cache.GetContext(ctx, "key", &value))
+	assert.Error(t, cache.GetContext(ctx, "missing", &value))
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	for i, name := range []string{"cache.set", "cache.get", "cache.get"} {
+		assert.Equal(t, name, spans[i].Name())
+		assert.Equal(t, parent.SpanContext().SpanID(), spans[i].Parent().SpanID(), "Expected %s to be a child of the request span", name)
+		assert.Contains(t, spans[i].Attributes(), attribute.String("cache.name", "responses"))
+	}
+	assert.Contains(t, spans[1].Attributes(), attribute.Bool("cache.hit", true))
+	assert.Contains(t, spans[2].Attributes(), attribute.Bool("cache.hit", false))
+
+	// Operations without a span are not traced
+	require.NoError(t, cache.Get("key", &value))
+	assert.Len(t, recorder.Ended(), 3)
+}
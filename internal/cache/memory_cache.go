@@ -0,0 +1,259 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/h2co32/gollama/internal/metrics"
+)
+
+// MemoryCacheOptions configures a MemoryCache.
+type MemoryCacheOptions struct {
+	// MaxEntries is the maximum number of items held at once.
+	// Default: 10000
+	MaxEntries int
+
+	// MaxBytes is the maximum total size of the keys and values held at once.
+	// Default: 64 MiB
+	MaxBytes int64
+
+	// Metrics records hits, misses, evictions, size, and operation latency. Optional.
+	Metrics *metrics.MetricsProvider
+
+	// Name labels the cache's metrics.
+	// Default: "memory"
+	Name string
+}
+
+// DefaultMemoryCacheOptions returns the default options for a MemoryCache.
+func DefaultMemoryCacheOptions() MemoryCacheOptions {
+	return MemoryCacheOptions{
+		MaxEntries: 10000,
+		MaxBytes:   64 << 20,
+		Name:       "memory",
+	}
+}
+
+// MemoryCache is an in-process cache with least recently used eviction, meant as a
+// fast first level in front of the disk and Redis caches. When adding an item would
+// exceed MaxEntries or MaxBytes, the least recently used items are evicted.
+type MemoryCache struct {
+	options MemoryCacheOptions
+	items   map[string]*list.Element
+	lru     *list.List // Front is most recently used
+	bytes   int64
+	metrics *cacheMetrics
+	mu      sync.Mutex
+}
+
+// memoryItem is a cached value and its position in the LRU list.
+type memoryItem struct {
+	key       string
+	data      []byte
+	expiresAt time.Time // Zero if the item does not expire
+}
+
+// size returns the number of bytes the item counts against MaxBytes.
+func (item *memoryItem) size() int64 {
+	return int64(len(item.key) + len(item.data))
+}
+
+// NewMemoryCache creates a MemoryCache with the given options.
+// Zero values fall back to the defaults.
+func NewMemoryCache(options MemoryCacheOptions) *MemoryCache {
+	defaults := DefaultMemoryCacheOptions()
+	if options.MaxEntries <= 0 {
+		options.MaxEntries = defaults.MaxEntries
+	}
+	if options.MaxBytes <= 0 {
+		options.MaxBytes = defaults.MaxBytes
+	}
+	if options.Name == "" {
+		options.Name = defaults.Name
+	}
+
+	return &MemoryCache{
+		options: options,
+		items:   make(map[string]*list.Element),
+		lru:     list.New(),
+		metrics: newCacheMetrics(options.Metrics, options.Name),
+	}
+}
+
+// Set stores a key-value pair in the cache with an expiration duration.
+// A non-positive ttl means the item does not expire. Items larger than MaxBytes
+// are not stored.
+func (mc *MemoryCache) Set(key string, data []byte, ttl time.Duration) error {
+	defer mc.metrics.observe("set", time.Now())
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	defer mc.reportSize()
+
+	mc.set(key, data, ttl)
+	return nil
+}
+
+// set stores a copy of data under key, evicting least recently used items to make room.
+// This method is not thread-safe and should be called with the lock held.
+func (mc *MemoryCache) set(key string, data []byte, ttl time.Duration) {
+	item := &memoryItem{key: key, data: append([]byte(nil), data...)}
+	if ttl > 0 {
+		item.expiresAt = time.Now().Add(ttl)
+	}
+
+	if element, ok := mc.items[key]; ok {
+		mc.remove(element)
+	}
+	if item.size() > mc.options.MaxBytes {
+		return
+	}
+
+	for len(mc.items) >= mc.options.MaxEntries || mc.bytes+item.size() > mc.options.MaxBytes {
+		mc.remove(mc.lru.Back())
+		mc.metrics.evicted(1)
+	}
+
+	mc.items[key] = mc.lru.PushFront(item)
+	mc.bytes += item.size()
+}
+
+// Get retrieves a value from the cache by key, returning nil if expired or not found
+func (mc *MemoryCache) Get(key string) ([]byte, error) {
+	defer mc.metrics.observe("get", time.Now())
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	element, ok := mc.items[key]
+	if !ok {
+		mc.metrics.lookup(false)
+		return nil, nil
+	}
+
+	item := element.Value.(*memoryItem)
+	if !item.expiresAt.IsZero() && time.Now().After(item.expiresAt) {
+		mc.remove(element)
+		mc.metrics.evicted(1)
+		mc.metrics.lookup(false)
+		mc.reportSize()
+		return nil, nil
+	}
+
+	mc.lru.MoveToFront(element)
+	mc.metrics.lookup(true)
+	return append([]byte(nil), item.data...), nil
+}
+
+// MGet retrieves the values of several keys under a single lock, leaving out keys
+// that are expired or not found
+func (mc *MemoryCache) MGet(keys []string) (map[string][]byte, error) {
+	defer mc.metrics.observe("mget", time.Now())
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	defer mc.reportSize()
+
+	now := time.Now()
+	result := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		element, ok := mc.items[key]
+		if !ok {
+			mc.metrics.lookup(false)
+			continue
+		}
+		item := element.Value.(*memoryItem)
+		if !item.expiresAt.IsZero() && now.After(item.expiresAt) {
+			mc.remove(element)
+			mc.metrics.evicted(1)
+			mc.metrics.lookup(false)
+			continue
+		}
+		mc.lru.MoveToFront(element)
+		mc.metrics.lookup(true)
+		result[key] = append([]byte(nil), item.data...)
+	}
+	return result, nil
+}
+
+// MSet stores several key-value pairs with the same expiration duration under a
+// single lock
+func (mc *MemoryCache) MSet(items map[string][]byte, ttl time.Duration) error {
+	defer mc.metrics.observe("mset", time.Now())
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	defer mc.reportSize()
+
+	for key, data := range items {
+		mc.set(key, data, ttl)
+	}
+	return nil
+}
+
+// MDelete removes several cached items under a single lock
+func (mc *MemoryCache) MDelete(keys []string) error {
+	defer mc.metrics.observe("mdelete", time.Now())
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	defer mc.reportSize()
+
+	for _, key := range keys {
+		if element, ok := mc.items[key]; ok {
+			mc.remove(element)
+		}
+	}
+	return nil
+}
+
+// Delete removes a cached item by key
+func (mc *MemoryCache) Delete(key string) error {
+	defer mc.metrics.observe("delete", time.Now())
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	defer mc.reportSize()
+
+	if element, ok := mc.items[key]; ok {
+		mc.remove(element)
+	}
+	return nil
+}
+
+// Clear removes all cached items
+func (mc *MemoryCache) Clear() error {
+	defer mc.metrics.observe("clear", time.Now())
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	defer mc.reportSize()
+
+	mc.items = make(map[string]*list.Element)
+	mc.lru.Init()
+	mc.bytes = 0
+	return nil
+}
+
+// Len returns the number of items in the cache, including expired items that have
+// not been removed yet.
+func (mc *MemoryCache) Len() int {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return len(mc.items)
+}
+
+// Bytes returns the total size of the keys and values in the cache.
+func (mc *MemoryCache) Bytes() int64 {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return mc.bytes
+}
+
+// reportSize records the number of items and bytes in the cache.
+// This method is not thread-safe and should be called with the lock held.
+func (mc *MemoryCache) reportSize() {
+	mc.metrics.size(len(mc.items), mc.bytes)
+}
+
+// remove deletes element from the cache.
+// This method is not thread-safe and should be called with the lock held.
+func (mc *MemoryCache) remove(element *list.Element) {
+	item := mc.lru.Remove(element).(*memoryItem)
+	delete(mc.items, item.key)
+	mc.bytes -= item.size()
+}
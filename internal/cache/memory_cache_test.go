@@ -0,0 +1,151 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheSetGet(t *testing.T) {
+	cache := NewMemoryCache(MemoryCacheOptions{})
+
+	if err := cache.Set("key", []byte("value"), time.Hour); err != nil {
+		t.Fatalf("Failed to set cache value: %v", err)
+	}
+
+	value, err := cache.Get("key")
+	if err != nil {
+		t.Fatalf("Failed to get cache value: %v", err)
+	}
+	if !bytes.Equal(value, []byte("value")) {
+		t.Errorf("Expected value 'value', got '%s'", value)
+	}
+
+	// Returned values are copies
+	value[0] = 'X'
+	if value, _ := cache.Get("key"); !bytes.Equal(value, []byte("value")) {
+		t.Errorf("Expected the cached value to be unaffected, got '%s'", value)
+	}
+
+	if value, err := cache.Get("missing"); value != nil || err != nil {
+		t.Errorf("Expected nil value and error for a missing key, got '%s', %v", value, err)
+	}
+
+	cache.Delete("key")
+	if value, _ := cache.Get("key"); value != nil {
+		t.Errorf("Expected nil value after delete, got '%s'", value)
+	}
+}
+
+func TestMemoryCacheExpiration(t *testing.T) {
+	cache := NewMemoryCache(MemoryCacheOptions{})
+	cache.Set("expiring", []byte("value"), 10*time.Millisecond)
+	cache.Set("forever", []byte("value"), 0)
+
+	time.Sleep(20 * time.Millisecond)
+
+	if value, _ := cache.Get("expiring"); value != nil {
+		t.Errorf("Expected expired value to be nil, got '%s'", value)
+	}
+	if value, _ := cache.Get("forever"); value == nil {
+		t.Error("Expected an item without a TTL not to expire")
+	}
+	if cache.Len() != 1 {
+		t.Errorf("Expected the expired item to be removed, got %d items", cache.Len())
+	}
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	cache := NewMemoryCache(MemoryCacheOptions{MaxEntries: 3})
+	for i := 0; i < 3; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), []byte("v"), time.Hour)
+	}
+
+	// Using key0 makes key1 the least recently used
+	cache.Get("key0")
+	cache.Set("key3", []byte("v"), time.Hour)
+
+	if value, _ := cache.Get("key1"); value != nil {
+		t.Error("Expected the least recently used key to be evicted")
+	}
+	for _, key := range []string{"key0", "key2", "key3"} {
+		if value, _ := cache.Get(key); value == nil {
+			t.Errorf("Expected %s to remain in the cache", key)
+		}
+	}
+
+	// The byte limit counts keys and values
+	cache = NewMemoryCache(MemoryCacheOptions{MaxBytes: 20})
+	cache.Set("a", make([]byte, 9), time.Hour)
+	cache.Set("b", make([]byte, 9), time.Hour)
+	if cache.Bytes() != 20 {
+		t.Errorf("Expected 20 bytes, got %d", cache.Bytes())
+	}
+	cache.Set("c", make([]byte, 9), time.Hour)
+	if value, _ := cache.Get("a"); value != nil {
+		t.Error("Expected the oldest item to be evicted to stay within MaxBytes")
+	}
+	if cache.Bytes() != 20 {
+		t.Errorf("Expected 20 bytes after eviction, got %d", cache.Bytes())
+	}
+
+	// Items larger than the cache are not stored
+	cache.Set("huge", make([]byte, 100), time.Hour)
+	if value, _ := cache.Get("huge"); value != nil {
+		t.Error("Expected an item larger than MaxBytes not to be stored")
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Expected the existing items to remain, got %d items", cache.Len())
+	}
+
+	cache.Clear()
+	if cache.Len() != 0 || cache.Bytes() != 0 {
+		t.Errorf("Expected an empty cache after Clear, got %d items and %d bytes", cache.Len(), cache.Bytes())
+	}
+}
+
+func TestMemoryCacheConcurrency(t *testing.T) {
+	cache := NewMemoryCache(MemoryCacheOptions{MaxEntries: 50})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := fmt.Sprintf("key-%d-%d", id, j%20)
+				cache.Set(key, []byte(key), time.Hour)
+				cache.Get(key)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if cache.Len() > 50 {
+		t.Errorf("Expected at most 50 items, got %d", cache.Len())
+	}
+}
+
+func TestMemoryCacheBatch(t *testing.T) {
+	cache := NewMemoryCache(MemoryCacheOptions{})
+
+	err := cache.MSet(map[string][]byte{"a": []byte("1"), "b": []byte("2"), "c": []byte("3")}, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to set values: %v", err)
+	}
+
+	values, err := cache.MGet([]string{"a", "b", "missing"})
+	if err != nil {
+		t.Fatalf("Failed to get values: %v", err)
+	}
+	if len(values) != 2 || string(values["a"]) != "1" || string(values["b"]) != "2" {
+		t.Errorf("Expected values for a and b only, got %v", values)
+	}
+
+	cache.MDelete([]string{"a", "c", "missing"})
+	if cache.Len() != 1 {
+		t.Errorf("Expected 1 item after MDelete, got %d", cache.Len())
+	}
+}
@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/h2co32/gollama/internal/metrics"
+)
+
+// cacheMetrics reports the metrics of one cache to a MetricsProvider. Its methods do
+// nothing on a nil receiver, so caches without a provider can call them unconditionally.
+type cacheMetrics struct {
+	provider *metrics.MetricsProvider
+	name     string
+}
+
+// newCacheMetrics returns the metrics of the cache called name, or nil if provider is nil.
+func newCacheMetrics(provider *metrics.MetricsProvider, name string) *cacheMetrics {
+	if provider == nil {
+		return nil
+	}
+	return &cacheMetrics{provider: provider, name: name}
+}
+
+// lookup records a cache hit or miss.
+func (m *cacheMetrics) lookup(hit bool) {
+	if m != nil {
+		m.provider.TrackCacheLookup(m.name, hit)
+	}
+}
+
+// evicted records entries removed by the cache itself.
+func (m *cacheMetrics) evicted(count int) {
+	if m != nil && count > 0 {
+		m.provider.TrackCacheEvictions(m.name, count)
+	}
+}
+
+// size records the number of entries and bytes held by the cache.
+func (m *cacheMetrics) size(entries int, bytes int64) {
+	if m != nil {
+		m.provider.SetCacheSize(m.name, entries, bytes)
+	}
+}
+
+// observe records the latency of an operation that started at start. It is meant to
+// be deferred: defer m.observe("get", time.Now()).
+func (m *cacheMetrics) observe(operation string, start time.Time) {
+	if m != nil {
+		m.provider.TrackCacheOperation(m.name, operation, time.Since(start))
+	}
+}
@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/h2co32/gollama/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// testMetrics is shared by the tests because NewMetricsProvider registers its
+// collectors with the default registry, which only allows it once.
+var testMetrics = metrics.NewMetricsProvider()
+
+// metricValue returns the value of the counter or gauge name for the named cache.
+func metricValue(t *testing.T, name, cache string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "cache" && label.GetValue() == cache {
+					if metric.GetCounter() != nil {
+						return metric.GetCounter().GetValue()
+					}
+					return metric.GetGauge().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestMemoryCacheMetrics(t *testing.T) {
+	cache := NewMemoryCache(MemoryCacheOptions{MaxEntries: 2, Metrics: testMetrics, Name: "memory-test"})
+
+	cache.Set("a", []byte("1"), time.Hour)
+	cache.Set("b", []byte("2"), time.Hour)
+	cache.Set("c", []byte("3"), time.Hour) // Evicts a
+	cache.Get("b")
+	cache.Get("a")
+
+	expected := map[string]float64{
+		"cache_hits_total":      1,
+		"cache_misses_total":    1,
+		"cache_evictions_total": 1,
+		"cache_entries":         2,
+		"cache_size_bytes":      4,
+	}
+	for name, value := range expected {
+		if got := metricValue(t, name, "memory-test"); got != value {
+			t.Errorf("Expected %s to be %v, got %v", name, value, got)
+		}
+	}
+}
+
+func TestDiskCacheMetrics(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Existing entries are counted when the cache is opened
+	cache, _ := NewDiskCache(tempDir)
+	cache.Set("existing", []byte("value"), time.Hour)
+
+	cache, err := NewDiskCacheWithOptions(tempDir, DiskCacheOptions{Metrics: testMetrics, Name: "disk-test"})
+	if err != nil {
+		t.Fatalf("Failed to create disk cache: %v", err)
+	}
+	if got := metricValue(t, "cache_entries", "disk-test"); got != 1 {
+		t.Errorf("Expected 1 entry after opening, got %v", got)
+	}
+
+	cache.Set("expiring", []byte("value"), time.Millisecond)
+	cache.Set("existing", []byte("replaced"), time.Hour)
+	if got := metricValue(t, "cache_entries", "disk-test"); got != 2 {
+		t.Errorf("Expected 2 entries, got %v", got)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	cache.Get("expiring")
+	cache.Get("existing")
+
+	if got := metricValue(t, "cache_hits_total", "disk-test"); got != 1 {
+		t.Errorf("Expected 1 hit, got %v", got)
+	}
+	if got := metricValue(t, "cache_misses_total", "disk-test"); got != 1 {
+		t.Errorf("Expected 1 miss, got %v", got)
+	}
+	if got := metricValue(t, "cache_evictions_total", "disk-test"); got != 1 {
+		t.Errorf("Expected 1 eviction, got %v", got)
+	}
+	if got := metricValue(t, "cache_entries", "disk-test"); got != 1 {
+		t.Errorf("Expected 1 entry after eviction, got %v", got)
+	}
+
+	cache.Delete("existing")
+	if entries, bytes := metricValue(t, "cache_entries", "disk-test"), metricValue(t, "cache_size_bytes", "disk-test"); entries != 0 || bytes != 0 {
+		t.Errorf("Expected an empty cache after delete, got %v entries and %v bytes", entries, bytes)
+	}
+}
+
+func TestTieredCacheMetrics(t *testing.T) {
+	tc, _ := NewTieredCache(TieredCacheOptions{Metrics: testMetrics, Name: "tiered-test"},
+		NewMemoryCache(MemoryCacheOptions{}), NewMemoryCache(MemoryCacheOptions{}))
+
+	tc.Set("key", []byte("value"), time.Hour)
+	tc.Get("key")
+	tc.Get("missing")
+
+	if got := metricValue(t, "cache_hits_total", "tiered-test"); got != 1 {
+		t.Errorf("Expected 1 hit, got %v", got)
+	}
+	if got := metricValue(t, "cache_misses_total", "tiered-test"); got != 1 {
+		t.Errorf("Expected 1 miss, got %v", got)
+	}
+}
@@ -0,0 +1,925 @@
+package cache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MsgpackCodec encodes values as MessagePack, which is more compact than JSON and,
+// unlike gob, readable by other languages sharing the cache.
+//
+// Structs are encoded as maps keyed by field name. A `msgpack:"name,omitempty"` tag
+// renames a field or omits it when empty, and `msgpack:"-"` skips it. Embedded structs
+// are flattened into their parent. time.Time values use the MessagePack timestamp
+// extension and decode in UTC. When decoding into an interface{}, integers become
+// int64 (or uint64 if too large), floats become float64, arrays become []interface{},
+// and maps become map[string]interface{}, or map[interface{}]interface{} if a key is
+// not a string.
+type MsgpackCodec struct{}
+
+// Marshal encodes v as MessagePack.
+func (MsgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var e msgpackEncoder
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// Unmarshal decodes MessagePack data into v, which must be a non-nil pointer.
+func (MsgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("msgpack codec cannot decode into %T", v)
+	}
+	d := msgpackDecoder{data: data}
+	if err := d.decode(rv.Elem()); err != nil {
+		return err
+	}
+	if d.pos != len(d.data) {
+		return fmt.Errorf("msgpack codec found %d unexpected bytes after the value", len(d.data)-d.pos)
+	}
+	return nil
+}
+
+// MessagePack format codes
+const (
+	msgpackNil      = 0xc0
+	msgpackFalse    = 0xc2
+	msgpackTrue     = 0xc3
+	msgpackBin8     = 0xc4
+	msgpackBin16    = 0xc5
+	msgpackBin32    = 0xc6
+	msgpackExt8     = 0xc7
+	msgpackExt16    = 0xc8
+	msgpackExt32    = 0xc9
+	msgpackFloat32  = 0xca
+	msgpackFloat64  = 0xcb
+	msgpackUint8    = 0xcc
+	msgpackUint16   = 0xcd
+	msgpackUint32   = 0xce
+	msgpackUint64   = 0xcf
+	msgpackInt8     = 0xd0
+	msgpackInt16    = 0xd1
+	msgpackInt32    = 0xd2
+	msgpackInt64    = 0xd3
+	msgpackFixExt1  = 0xd4
+	msgpackFixExt2  = 0xd5
+	msgpackFixExt4  = 0xd6
+	msgpackFixExt8  = 0xd7
+	msgpackFixExt16 = 0xd8
+	msgpackStr8     = 0xd9
+	msgpackStr16    = 0xda
+	msgpackStr32    = 0xdb
+	msgpackArray16  = 0xdc
+	msgpackArray32  = 0xdd
+	msgpackMap16    = 0xde
+	msgpackMap32    = 0xdf
+
+	msgpackFixMap   = 0x80
+	msgpackFixArray = 0x90
+	msgpackFixStr   = 0xa0
+
+	// msgpackTimestamp is the extension type of timestamps.
+	msgpackTimestamp = -1
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// msgpackEncoder appends the MessagePack encoding of values to a buffer.
+type msgpackEncoder struct {
+	buf []byte
+}
+
+func (e *msgpackEncoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.buf = append(e.buf, msgpackNil)
+		return nil
+	}
+	if v.Type() == timeType {
+		e.encodeTime(v.Interface().(time.Time))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			e.buf = append(e.buf, msgpackNil)
+			return nil
+		}
+		return e.encode(v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, msgpackTrue)
+		} else {
+			e.buf = append(e.buf, msgpackFalse)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.encodeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.encodeUint(v.Uint())
+	case reflect.Float32:
+		e.buf = append(e.buf, msgpackFloat32)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		e.buf = append(e.buf, msgpackFloat64)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v.Float()))
+	case reflect.String:
+		e.encodeString(v.String())
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf = append(e.buf, msgpackNil)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.encodeHeader(v.Len(), 0, 0, msgpackBin8, msgpackBin16, msgpackBin32)
+			e.buf = append(e.buf, v.Bytes()...)
+			return nil
+		}
+		return e.encodeArray(v)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.encodeHeader(v.Len(), 0, 0, msgpackBin8, msgpackBin16, msgpackBin32)
+			for i := 0; i < v.Len(); i++ {
+				e.buf = append(e.buf, byte(v.Index(i).Uint()))
+			}
+			return nil
+		}
+		return e.encodeArray(v)
+	case reflect.Map:
+		if v.IsNil() {
+			e.buf = append(e.buf, msgpackNil)
+			return nil
+		}
+		return e.encodeMap(v)
+	case reflect.Struct:
+		return e.encodeStruct(v)
+	default:
+		return fmt.Errorf("msgpack codec cannot encode %s", v.Type())
+	}
+	return nil
+}
+
+// encodeHeader appends the header of a string, binary, array, or map of length n.
+// Formats without a fixed-size form pass a zero fixLimit.
+func (e *msgpackEncoder) encodeHeader(n int, fixCode byte, fixLimit int, code8, code16, code32 byte) {
+	switch {
+	case n < fixLimit:
+		e.buf = append(e.buf, fixCode|byte(n))
+	case code8 != 0 && n <= math.MaxUint8:
+		e.buf = append(e.buf, code8, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, code16)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, code32)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+}
+
+func (e *msgpackEncoder) encodeString(s string) {
+	e.encodeHeader(len(s), msgpackFixStr, 32, msgpackStr8, msgpackStr16, msgpackStr32)
+	e.buf = append(e.buf, s...)
+}
+
+// encodeInt appends n in the smallest integer format that holds it.
+func (e *msgpackEncoder) encodeInt(n int64) {
+	switch {
+	case n >= 0:
+		e.encodeUint(uint64(n))
+	case n >= -32:
+		e.buf = append(e.buf, byte(n))
+	case n >= math.MinInt8:
+		e.buf = append(e.buf, msgpackInt8, byte(n))
+	case n >= math.MinInt16:
+		e.buf = append(e.buf, msgpackInt16)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	case n >= math.MinInt32:
+		e.buf = append(e.buf, msgpackInt32)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	default:
+		e.buf = append(e.buf, msgpackInt64)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(n))
+	}
+}
+
+// encodeUint appends n in the smallest integer format that holds it.
+func (e *msgpackEncoder) encodeUint(n uint64) {
+	switch {
+	case n <= math.MaxInt8:
+		e.buf = append(e.buf, byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, msgpackUint8, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, msgpackUint16)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	case n <= math.MaxUint32:
+		e.buf = append(e.buf, msgpackUint32)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	default:
+		e.buf = append(e.buf, msgpackUint64)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, n)
+	}
+}
+
+// encodeTime appends t with the timestamp extension, in the smallest of its three
+// formats that holds t.
+func (e *msgpackEncoder) encodeTime(t time.Time) {
+	sec, nsec := uint64(t.Unix()), uint64(t.Nanosecond())
+	if sec>>34 == 0 {
+		data := nsec<<34 | sec
+		if data>>32 == 0 {
+			e.buf = append(e.buf, msgpackFixExt4, byte(msgpackTimestamp&0xff))
+			e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(data))
+			return
+		}
+		e.buf = append(e.buf, msgpackFixExt8, byte(msgpackTimestamp&0xff))
+		e.buf = binary.BigEndian.AppendUint64(e.buf, data)
+		return
+	}
+	e.buf = append(e.buf, msgpackExt8, 12, byte(msgpackTimestamp&0xff))
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(nsec))
+	e.buf = binary.BigEndian.AppendUint64(e.buf, sec)
+}
+
+func (e *msgpackEncoder) encodeArray(v reflect.Value) error {
+	e.encodeHeader(v.Len(), msgpackFixArray, 16, 0, msgpackArray16, msgpackArray32)
+	for i := 0; i < v.Len(); i++ {
+		if err := e.encode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) encodeMap(v reflect.Value) error {
+	e.encodeHeader(v.Len(), msgpackFixMap, 16, 0, msgpackMap16, msgpackMap32)
+	keys := v.MapKeys()
+	// Sort string keys so equal maps encode to equal bytes
+	if v.Type().Key().Kind() == reflect.String {
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	}
+	for _, key := range keys {
+		if err := e.encode(key); err != nil {
+			return err
+		}
+		if err := e.encode(v.MapIndex(key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) encodeStruct(v reflect.Value) error {
+	fields := msgpackFields(v.Type()).fields
+	values := make([]reflect.Value, 0, len(fields))
+	names := make([]string, 0, len(fields))
+	for _, field := range fields {
+		value := v.FieldByIndex(field.index)
+		if field.omitEmpty && value.IsZero() {
+			continue
+		}
+		values = append(values, value)
+		names = append(names, field.name)
+	}
+
+	e.encodeHeader(len(values), msgpackFixMap, 16, 0, msgpackMap16, msgpackMap32)
+	for i, value := range values {
+		e.encodeString(names[i])
+		if err := e.encode(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// msgpackField is a struct field encoded by MsgpackCodec.
+type msgpackField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// msgpackStruct lists the encoded fields of a struct type.
+type msgpackStruct struct {
+	fields []msgpackField
+	byName map[string]int
+}
+
+var msgpackStructs sync.Map // reflect.Type -> *msgpackStruct
+
+// msgpackFields returns the encoded fields of a struct type.
+func msgpackFields(t reflect.Type) *msgpackStruct {
+	if info, ok := msgpackStructs.Load(t); ok {
+		return info.(*msgpackStruct)
+	}
+	info := &msgpackStruct{byName: make(map[string]int)}
+	collectMsgpackFields(t, nil, info)
+	actual, _ := msgpackStructs.LoadOrStore(t, info)
+	return actual.(*msgpackStruct)
+}
+
+// collectMsgpackFields adds the fields of struct type t, found at index in the
+// outermost struct, to info. Fields of embedded structs are added after those of t,
+// so t's own fields take precedence.
+func collectMsgpackFields(t reflect.Type, index []int, info *msgpackStruct) {
+	var embedded []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("msgpack")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			embedded = append(embedded, f)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if _, ok := info.byName[name]; ok {
+			continue
+		}
+		info.byName[name] = len(info.fields)
+		info.fields = append(info.fields, msgpackField{
+			name:      name,
+			index:     append(append([]int(nil), index...), i),
+			omitEmpty: slices.Contains(strings.Split(options, ","), "omitempty"),
+		})
+	}
+	for _, f := range embedded {
+		collectMsgpackFields(f.Type, append(append([]int(nil), index...), f.Index...), info)
+	}
+}
+
+// msgpackDecoder decodes MessagePack values from data.
+type msgpackDecoder struct {
+	data []byte
+	pos  int
+}
+
+// next returns the next n bytes of data.
+func (d *msgpackDecoder) next(n int) ([]byte, error) {
+	if n < 0 || n > len(d.data)-d.pos {
+		return nil, fmt.Errorf("msgpack codec failed to decode: %w", io.ErrUnexpectedEOF)
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *msgpackDecoder) readByte() (byte, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// readUint reads a big-endian unsigned integer of size bytes.
+func (d *msgpackDecoder) readUint(size int) (uint64, error) {
+	b, err := d.next(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+// readLength reads a length of size bytes, checking that at least minBytes bytes per
+// element remain so a corrupt length cannot cause a huge allocation.
+func (d *msgpackDecoder) readLength(size, minBytes int) (int, error) {
+	n, err := d.readUint(size)
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(len(d.data)-d.pos)/uint64(minBytes) {
+		return 0, fmt.Errorf("msgpack codec failed to decode: %w", io.ErrUnexpectedEOF)
+	}
+	return int(n), nil
+}
+
+// msgpackNumber is a decoded integer or float.
+type msgpackNumber struct {
+	kind reflect.Kind // Int64, Uint64, or Float64
+	i    int64
+	u    uint64
+	f    float64
+}
+
+// readNumber reads the integer or float that starts with code, reporting false if
+// code does not start a number.
+func (d *msgpackDecoder) readNumber(code byte) (msgpackNumber, bool, error) {
+	var n msgpackNumber
+	var err error
+	switch {
+	case code <= 0x7f:
+		n = msgpackNumber{kind: reflect.Int64, i: int64(code)}
+	case code >= 0xe0:
+		n = msgpackNumber{kind: reflect.Int64, i: int64(int8(code))}
+	case code >= msgpackUint8 && code <= msgpackUint64:
+		n.kind = reflect.Uint64
+		n.u, err = d.readUint(1 << (code - msgpackUint8))
+		if err == nil && n.u <= math.MaxInt64 {
+			n = msgpackNumber{kind: reflect.Int64, i: int64(n.u)}
+		}
+	case code >= msgpackInt8 && code <= msgpackInt64:
+		var u uint64
+		size := 1 << (code - msgpackInt8)
+		u, err = d.readUint(size)
+		// Sign-extend from the encoded size
+		shift := 64 - 8*size
+		n = msgpackNumber{kind: reflect.Int64, i: int64(u<<shift) >> shift}
+	case code == msgpackFloat32:
+		var u uint64
+		u, err = d.readUint(4)
+		n = msgpackNumber{kind: reflect.Float64, f: float64(math.Float32frombits(uint32(u)))}
+	case code == msgpackFloat64:
+		var u uint64
+		u, err = d.readUint(8)
+		n = msgpackNumber{kind: reflect.Float64, f: math.Float64frombits(u)}
+	default:
+		return n, false, nil
+	}
+	return n, true, err
+}
+
+// readStringLength reads the length of the string or binary that starts with code,
+// reporting false if code does not start one.
+func (d *msgpackDecoder) readStringLength(code byte) (int, bool, error) {
+	var n int
+	var err error
+	switch {
+	case code&0xe0 == msgpackFixStr:
+		n = int(code & 0x1f)
+	case code == msgpackStr8 || code == msgpackBin8:
+		n, err = d.readLength(1, 1)
+	case code == msgpackStr16 || code == msgpackBin16:
+		n, err = d.readLength(2, 1)
+	case code == msgpackStr32 || code == msgpackBin32:
+		n, err = d.readLength(4, 1)
+	default:
+		return 0, false, nil
+	}
+	return n, true, err
+}
+
+// readArrayLength reads the length of the array that starts with code, reporting
+// false if code does not start an array.
+func (d *msgpackDecoder) readArrayLength(code byte) (int, bool, error) {
+	var n int
+	var err error
+	switch {
+	case code&0xf0 == msgpackFixArray:
+		n = int(code & 0x0f)
+	case code == msgpackArray16:
+		n, err = d.readLength(2, 1)
+	case code == msgpackArray32:
+		n, err = d.readLength(4, 1)
+	default:
+		return 0, false, nil
+	}
+	return n, true, err
+}
+
+// readMapLength reads the number of entries in the map that starts with code,
+// reporting false if code does not start a map.
+func (d *msgpackDecoder) readMapLength(code byte) (int, bool, error) {
+	var n int
+	var err error
+	switch {
+	case code&0xf0 == msgpackFixMap:
+		n = int(code & 0x0f)
+	case code == msgpackMap16:
+		n, err = d.readLength(2, 2)
+	case code == msgpackMap32:
+		n, err = d.readLength(4, 2)
+	default:
+		return 0, false, nil
+	}
+	return n, true, err
+}
+
+// readExt reads the type and data of the extension that starts with code, reporting
+// false if code does not start an extension.
+func (d *msgpackDecoder) readExt(code byte) (int8, []byte, bool, error) {
+	var n int
+	var err error
+	switch code {
+	case msgpackFixExt1, msgpackFixExt2, msgpackFixExt4, msgpackFixExt8, msgpackFixExt16:
+		n = 1 << (code - msgpackFixExt1)
+	case msgpackExt8:
+		n, err = d.readLength(1, 1)
+	case msgpackExt16:
+		n, err = d.readLength(2, 1)
+	case msgpackExt32:
+		n, err = d.readLength(4, 1)
+	default:
+		return 0, nil, false, nil
+	}
+	if err != nil {
+		return 0, nil, true, err
+	}
+	typ, err := d.readByte()
+	if err != nil {
+		return 0, nil, true, err
+	}
+	data, err := d.next(n)
+	return int8(typ), data, true, err
+}
+
+// readTime reads the timestamp extension that starts with code.
+func (d *msgpackDecoder) readTime(code byte) (time.Time, error) {
+	typ, data, ok, err := d.readExt(code)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !ok || typ != msgpackTimestamp {
+		return time.Time{}, fmt.Errorf("msgpack codec cannot decode 0x%02x into time.Time", code)
+	}
+	return parseTimestamp(data)
+}
+
+// parseTimestamp parses the data of a timestamp extension in any of its formats.
+func parseTimestamp(data []byte) (time.Time, error) {
+	switch len(data) {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0).UTC(), nil
+	case 8:
+		v := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(v&(1<<34-1)), int64(v>>34)).UTC(), nil
+	case 12:
+		nsec := binary.BigEndian.Uint32(data)
+		sec := binary.BigEndian.Uint64(data[4:])
+		return time.Unix(int64(sec), int64(nsec)).UTC(), nil
+	default:
+		return time.Time{}, fmt.Errorf("msgpack codec cannot decode a %d-byte timestamp", len(data))
+	}
+}
+
+// decode decodes the next value into v, which must be settable.
+func (d *msgpackDecoder) decode(v reflect.Value) error {
+	code, err := d.readByte()
+	if err != nil {
+		return err
+	}
+	return d.decodeValue(code, v)
+}
+
+// decodeValue decodes the value that starts with code into v.
+func (d *msgpackDecoder) decodeValue(code byte, v reflect.Value) error {
+	if code == msgpackNil {
+		v.SetZero()
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decodeValue(code, v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return fmt.Errorf("msgpack codec cannot decode into %s", v.Type())
+		}
+		value, err := d.decodeInterface(code)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(&value).Elem())
+		return nil
+	}
+	if v.Type() == timeType {
+		t, err := d.readTime(code)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if code != msgpackTrue && code != msgpackFalse {
+			return msgpackMismatch(code, v)
+		}
+		v.SetBool(code == msgpackTrue)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		n, ok, err := d.readNumber(code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return msgpackMismatch(code, v)
+		}
+		return setMsgpackNumber(v, n)
+	case reflect.String:
+		n, ok, err := d.readStringLength(code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return msgpackMismatch(code, v)
+		}
+		b, err := d.next(n)
+		if err != nil {
+			return err
+		}
+		v.SetString(string(b))
+		return nil
+	case reflect.Slice, reflect.Array:
+		return d.decodeArray(code, v)
+	case reflect.Map:
+		return d.decodeMap(code, v)
+	case reflect.Struct:
+		return d.decodeStruct(code, v)
+	default:
+		return fmt.Errorf("msgpack codec cannot decode into %s", v.Type())
+	}
+}
+
+// msgpackMismatch returns the error for a value that starts with code and cannot be
+// decoded into v.
+func msgpackMismatch(code byte, v reflect.Value) error {
+	return fmt.Errorf("msgpack codec cannot decode 0x%02x into %s", code, v.Type())
+}
+
+// setMsgpackNumber stores n in v, which has a numeric kind.
+func setMsgpackNumber(v reflect.Value, n msgpackNumber) error {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		switch n.kind {
+		case reflect.Int64:
+			v.SetFloat(float64(n.i))
+		case reflect.Uint64:
+			v.SetFloat(float64(n.u))
+		default:
+			v.SetFloat(n.f)
+		}
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n.kind == reflect.Int64 && !v.OverflowInt(n.i) {
+			v.SetInt(n.i)
+			return nil
+		}
+	default:
+		if n.kind == reflect.Int64 && n.i >= 0 && !v.OverflowUint(uint64(n.i)) {
+			v.SetUint(uint64(n.i))
+			return nil
+		}
+		if n.kind == reflect.Uint64 && !v.OverflowUint(n.u) {
+			v.SetUint(n.u)
+			return nil
+		}
+	}
+	return fmt.Errorf("msgpack codec cannot decode %v into %s", n.value(), v.Type())
+}
+
+// value returns n as an int64, uint64, or float64.
+func (n msgpackNumber) value() interface{} {
+	switch n.kind {
+	case reflect.Int64:
+		return n.i
+	case reflect.Uint64:
+		return n.u
+	default:
+		return n.f
+	}
+}
+
+func (d *msgpackDecoder) decodeArray(code byte, v reflect.Value) error {
+	if v.Type().Elem().Kind() == reflect.Uint8 {
+		if n, ok, err := d.readStringLength(code); ok {
+			if err != nil {
+				return err
+			}
+			b, err := d.next(n)
+			if err != nil {
+				return err
+			}
+			if v.Kind() == reflect.Slice {
+				v.SetBytes(append([]byte(nil), b...))
+				return nil
+			}
+			v.SetZero()
+			reflect.Copy(v, reflect.ValueOf(b))
+			return nil
+		}
+	}
+
+	n, ok, err := d.readArrayLength(code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return msgpackMismatch(code, v)
+	}
+	if v.Kind() == reflect.Slice {
+		v.Set(reflect.MakeSlice(v.Type(), n, n))
+	} else {
+		v.SetZero()
+	}
+	for i := 0; i < n; i++ {
+		if i >= v.Len() {
+			// Skip the elements that do not fit an array
+			if err := d.skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.decode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *msgpackDecoder) decodeMap(code byte, v reflect.Value) error {
+	n, ok, err := d.readMapLength(code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return msgpackMismatch(code, v)
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(v.Type(), n))
+	}
+	for i := 0; i < n; i++ {
+		key := reflect.New(v.Type().Key()).Elem()
+		if err := d.decode(key); err != nil {
+			return err
+		}
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err := d.decode(elem); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+	}
+	return nil
+}
+
+func (d *msgpackDecoder) decodeStruct(code byte, v reflect.Value) error {
+	n, ok, err := d.readMapLength(code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return msgpackMismatch(code, v)
+	}
+	info := msgpackFields(v.Type())
+	for i := 0; i < n; i++ {
+		var name string
+		if err := d.decode(reflect.ValueOf(&name).Elem()); err != nil {
+			return err
+		}
+		field, ok := info.byName[name]
+		if !ok {
+			// Skip fields the struct does not have
+			if err := d.skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.decode(v.FieldByIndex(info.fields[field].index)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skip discards the next value.
+func (d *msgpackDecoder) skip() error {
+	code, err := d.readByte()
+	if err != nil {
+		return err
+	}
+	_, err = d.decodeInterface(code)
+	return err
+}
+
+// decodeInterface decodes the value that starts with code into the types documented
+// on MsgpackCodec.
+func (d *msgpackDecoder) decodeInterface(code byte) (interface{}, error) {
+	switch code {
+	case msgpackNil:
+		return nil, nil
+	case msgpackTrue, msgpackFalse:
+		return code == msgpackTrue, nil
+	case msgpackBin8, msgpackBin16, msgpackBin32:
+		n, _, err := d.readStringLength(code)
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.next(n)
+		return append([]byte(nil), b...), err
+	}
+
+	if n, ok, err := d.readNumber(code); ok {
+		return n.value(), err
+	}
+	if n, ok, err := d.readStringLength(code); ok {
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.next(n)
+		return string(b), err
+	}
+	if n, ok, err := d.readArrayLength(code); ok {
+		if err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			if values[i], err = d.decodeNext(); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+	if n, ok, err := d.readMapLength(code); ok {
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeInterfaceMap(n)
+	}
+	if typ, data, ok, err := d.readExt(code); ok {
+		if err != nil {
+			return nil, err
+		}
+		if typ != msgpackTimestamp {
+			return nil, fmt.Errorf("msgpack codec cannot decode extension type %d", typ)
+		}
+		return parseTimestamp(data)
+	}
+	return nil, fmt.Errorf("msgpack codec cannot decode 0x%02x", code)
+}
+
+// decodeNext decodes the next value into an interface{}.
+func (d *msgpackDecoder) decodeNext() (interface{}, error) {
+	code, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	return d.decodeInterface(code)
+}
+
+// decodeInterfaceMap decodes a map with n entries into a map[string]interface{}, or a
+// map[interface{}]interface{} if a key is not a string.
+func (d *msgpackDecoder) decodeInterfaceMap(n int) (interface{}, error) {
+	keys := make([]interface{}, n)
+	values := make([]interface{}, n)
+	stringKeys := true
+	for i := 0; i < n; i++ {
+		var err error
+		if keys[i], err = d.decodeNext(); err != nil {
+			return nil, err
+		}
+		if values[i], err = d.decodeNext(); err != nil {
+			return nil, err
+		}
+		if _, ok := keys[i].(string); !ok {
+			stringKeys = false
+		}
+	}
+
+	if stringKeys {
+		m := make(map[string]interface{}, n)
+		for i, key := range keys {
+			m[key.(string)] = values[i]
+		}
+		return m, nil
+	}
+	m := make(map[interface{}]interface{}, n)
+	for i, key := range keys {
+		if key != nil && !reflect.TypeOf(key).Comparable() {
+			return nil, fmt.Errorf("msgpack codec cannot decode a map key of type %T", key)
+		}
+		m[key] = values[i]
+	}
+	return m, nil
+}
@@ -0,0 +1,355 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/h2co32/gollama/internal/metrics"
+)
+
+// TieredCacheOptions configures a TieredCache.
+type TieredCacheOptions struct {
+	// Loader reads a value from its source when every level misses. The result is
+	// stored in every level for LoadTTL. A nil value with a nil error means the key
+	// does not exist. Optional; without a loader, a miss in every level is a miss.
+	Loader func(key string) ([]byte, error)
+
+	// LoadTTL is how long values returned by Loader are cached.
+	// Default: 1 hour
+	LoadTTL time.Duration
+
+	// PromoteTTL is how long a value found in a slower level is cached in the faster
+	// levels above it. The remaining TTL in the slower level is not known, so this
+	// should be short enough to tolerate serving a value that has since expired there.
+	// Default: 5 minutes
+	PromoteTTL time.Duration
+
+	// WriteAround makes Set write only to the slowest level and delete the key from
+	// the faster levels, which are then filled on the next read. By default Set
+	// writes through to every level.
+	// Default: false
+	WriteAround bool
+
+	// NegativeTTL is how long a key that missed in every level, and in Loader if set,
+	// is remembered as missing so repeated lookups do not reach the slower levels.
+	// Zero disables negative caching.
+	// Default: 0
+	NegativeTTL time.Duration
+
+	// NegativeMaxEntries is the maximum number of missing keys remembered.
+	// Default: 10000
+	NegativeMaxEntries int
+
+	// Metrics records hits and misses across all levels, and operation latency. Each
+	// level reports its own metrics separately if configured with them. Optional.
+	Metrics *metrics.MetricsProvider
+
+	// Name labels the cache's metrics.
+	// Default: "tiered"
+	Name string
+}
+
+// DefaultTieredCacheOptions returns the default options for a TieredCache.
+func DefaultTieredCacheOptions() TieredCacheOptions {
+	return TieredCacheOptions{
+		LoadTTL:            time.Hour,
+		PromoteTTL:         5 * time.Minute,
+		NegativeMaxEntries: 10000,
+		Name:               "tiered",
+	}
+}
+
+// TieredCache composes several caches into one, ordered from fastest to slowest,
+// for example memory, then disk, then Redis. Get checks each level in turn and copies
+// a hit into the faster levels above it; Set, Delete, and Clear apply to every level.
+type TieredCache struct {
+	options  TieredCacheOptions
+	levels   []Cache
+	negative *MemoryCache // Keys known to be missing, when negative caching is enabled
+	metrics  *cacheMetrics
+}
+
+// negativeMarker is stored in the negative cache, which cannot hold empty values.
+var negativeMarker = []byte{1}
+
+// NewTieredCache creates a TieredCache over levels, fastest first.
+// Zero option values fall back to the defaults.
+func NewTieredCache(options TieredCacheOptions, levels ...Cache) (*TieredCache, error) {
+	if len(levels) == 0 {
+		return nil, fmt.Errorf("tiered cache needs at least one level")
+	}
+
+	defaults := DefaultTieredCacheOptions()
+	if options.LoadTTL <= 0 {
+		options.LoadTTL = defaults.LoadTTL
+	}
+	if options.PromoteTTL <= 0 {
+		options.PromoteTTL = defaults.PromoteTTL
+	}
+	if options.NegativeMaxEntries <= 0 {
+		options.NegativeMaxEntries = defaults.NegativeMaxEntries
+	}
+	if options.Name == "" {
+		options.Name = defaults.Name
+	}
+
+	tc := &TieredCache{
+		options: options,
+		levels:  levels,
+		metrics: newCacheMetrics(options.Metrics, options.Name),
+	}
+	if options.NegativeTTL > 0 {
+		tc.negative = NewMemoryCache(MemoryCacheOptions{MaxEntries: options.NegativeMaxEntries})
+	}
+	return tc, nil
+}
+
+// Set stores data in the cache. Levels are written slowest first, so a value is never
+// visible in a faster level without being in the slower ones.
+func (tc *TieredCache) Set(key string, data []byte, ttl time.Duration) error {
+	defer tc.metrics.observe("set", time.Now())
+	tc.forgetMissing(key)
+
+	last := len(tc.levels) - 1
+	if err := tc.levels[last].Set(key, data, ttl); err != nil {
+		return fmt.Errorf("failed to set cache level %d: %w", last, err)
+	}
+	for i := last - 1; i >= 0; i-- {
+		var err error
+		if tc.options.WriteAround {
+			err = tc.levels[i].Delete(key)
+		} else {
+			err = tc.levels[i].Set(key, data, ttl)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update cache level %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Get returns the value from the fastest level that has it, copying it into the
+// faster levels. If every level misses, Loader is called if set. Errors from a level
+// are skipped over and only returned if no level or loader produces the value.
+func (tc *TieredCache) Get(key string) ([]byte, error) {
+	defer tc.metrics.observe("get", time.Now())
+	if tc.negative != nil {
+		if marker, _ := tc.negative.Get(key); marker != nil {
+			tc.metrics.lookup(false)
+			return nil, nil
+		}
+	}
+
+	var firstErr error
+	for i, level := range tc.levels {
+		data, err := level.Get(key)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to get cache level %d: %w", i, err)
+			}
+			continue
+		}
+		if data == nil {
+			continue
+		}
+
+		// Promotion is best effort; the value is still returned if it fails
+		for j := 0; j < i; j++ {
+			_ = tc.levels[j].Set(key, data, tc.options.PromoteTTL)
+		}
+		tc.metrics.lookup(true)
+		return data, nil
+	}
+	tc.metrics.lookup(false)
+
+	if tc.options.Loader != nil {
+		data, err := tc.options.Loader(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load cache value: %w", err)
+		}
+		if data != nil {
+			if err := tc.Set(key, data, tc.options.LoadTTL); err != nil {
+				return data, err
+			}
+			return data, nil
+		}
+	} else if firstErr != nil {
+		return nil, firstErr
+	}
+
+	if tc.negative != nil {
+		tc.negative.Set(key, negativeMarker, tc.options.NegativeTTL)
+	}
+	return nil, nil
+}
+
+// MSet stores several items in the cache, batching the writes to each level. Levels
+// are written slowest first, as in Set.
+func (tc *TieredCache) MSet(items map[string][]byte, ttl time.Duration) error {
+	defer tc.metrics.observe("mset", time.Now())
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	if tc.negative != nil {
+		tc.negative.MDelete(keys)
+	}
+
+	last := len(tc.levels) - 1
+	if err := tc.levels[last].MSet(items, ttl); err != nil {
+		return fmt.Errorf("failed to set cache level %d: %w", last, err)
+	}
+	for i := last - 1; i >= 0; i-- {
+		var err error
+		if tc.options.WriteAround {
+			err = tc.levels[i].MDelete(keys)
+		} else {
+			err = tc.levels[i].MSet(items, ttl)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update cache level %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// MGet returns the values of several keys, asking each level only for the keys the
+// faster levels did not have and batching promotions and loads. Keys that are missing
+// everywhere are left out of the result. Errors are handled as in Get.
+func (tc *TieredCache) MGet(keys []string) (map[string][]byte, error) {
+	defer tc.metrics.observe("mget", time.Now())
+	result := make(map[string][]byte, len(keys))
+
+	remaining := keys
+	if tc.negative != nil {
+		missing, _ := tc.negative.MGet(keys)
+		remaining = withoutKeys(keys, missing)
+	}
+
+	var firstErr error
+	for i, level := range tc.levels {
+		if len(remaining) == 0 {
+			break
+		}
+		found, err := level.MGet(remaining)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to get cache level %d: %w", i, err)
+			}
+			continue
+		}
+		if len(found) == 0 {
+			continue
+		}
+
+		// Promotion is best effort; the values are still returned if it fails
+		for j := 0; j < i; j++ {
+			_ = tc.levels[j].MSet(found, tc.options.PromoteTTL)
+		}
+		for key, data := range found {
+			result[key] = data
+		}
+		remaining = withoutKeys(remaining, found)
+	}
+	for i := 0; i < len(keys); i++ {
+		tc.metrics.lookup(i < len(result))
+	}
+
+	if len(remaining) == 0 {
+		return result, nil
+	}
+	if tc.options.Loader != nil {
+		loaded := make(map[string][]byte)
+		for _, key := range remaining {
+			data, err := tc.options.Loader(key)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load cache value: %w", err)
+			}
+			if data != nil {
+				loaded[key] = data
+				result[key] = data
+			}
+		}
+		if len(loaded) > 0 {
+			if err := tc.MSet(loaded, tc.options.LoadTTL); err != nil {
+				return result, err
+			}
+			remaining = withoutKeys(remaining, loaded)
+		}
+	} else if firstErr != nil {
+		return nil, firstErr
+	}
+
+	if tc.negative != nil {
+		missing := make(map[string][]byte, len(remaining))
+		for _, key := range remaining {
+			missing[key] = negativeMarker
+		}
+		tc.negative.MSet(missing, tc.options.NegativeTTL)
+	}
+	return result, nil
+}
+
+// MDelete removes keys from every level, continuing past errors and returning the first.
+func (tc *TieredCache) MDelete(keys []string) error {
+	defer tc.metrics.observe("mdelete", time.Now())
+	if tc.negative != nil {
+		tc.negative.MDelete(keys)
+	}
+
+	var firstErr error
+	for i, level := range tc.levels {
+		if err := level.MDelete(keys); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to delete from cache level %d: %w", i, err)
+		}
+	}
+	return firstErr
+}
+
+// Delete removes key from every level, continuing past errors and returning the first.
+func (tc *TieredCache) Delete(key string) error {
+	defer tc.metrics.observe("delete", time.Now())
+	tc.forgetMissing(key)
+
+	var firstErr error
+	for i, level := range tc.levels {
+		if err := level.Delete(key); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to delete from cache level %d: %w", i, err)
+		}
+	}
+	return firstErr
+}
+
+// Clear removes every key from every level, continuing past errors and returning the first.
+func (tc *TieredCache) Clear() error {
+	defer tc.metrics.observe("clear", time.Now())
+	if tc.negative != nil {
+		tc.negative.Clear()
+	}
+
+	var firstErr error
+	for i, level := range tc.levels {
+		if err := level.Clear(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to clear cache level %d: %w", i, err)
+		}
+	}
+	return firstErr
+}
+
+// forgetMissing drops key from the negative cache.
+func (tc *TieredCache) forgetMissing(key string) {
+	if tc.negative != nil {
+		tc.negative.Delete(key)
+	}
+}
+
+// withoutKeys returns the keys that are not in found.
+func withoutKeys(keys []string, found map[string][]byte) []string {
+	remaining := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, ok := found[key]; !ok {
+			remaining = append(remaining, key)
+		}
+	}
+	return remaining
+}
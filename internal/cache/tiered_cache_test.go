@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+// failingCache is a Cache whose operations all fail.
+type failingCache struct{}
+
+func (failingCache) Set(string, []byte, time.Duration) error     { return errors.New("unavailable") }
+func (failingCache) Get(string) ([]byte, error)                  { return nil, errors.New("unavailable") }
+func (failingCache) Delete(string) error                         { return errors.New("unavailable") }
+func (failingCache) Clear() error                                { return errors.New("unavailable") }
+func (failingCache) MGet([]string) (map[string][]byte, error)    { return nil, errors.New("unavailable") }
+func (failingCache) MSet(map[string][]byte, time.Duration) error { return errors.New("unavailable") }
+func (failingCache) MDelete([]string) error                      { return errors.New("unavailable") }
+
+func TestTieredCacheReadThrough(t *testing.T) {
+	l1 := NewMemoryCache(MemoryCacheOptions{})
+	l2 := NewMemoryCache(MemoryCacheOptions{})
+	tc, err := NewTieredCache(TieredCacheOptions{}, l1, l2)
+	if err != nil {
+		t.Fatalf("Failed to create tiered cache: %v", err)
+	}
+
+	// A value only in the slower level is promoted on read
+	l2.Set("key", []byte("value"), time.Hour)
+	value, err := tc.Get("key")
+	if err != nil {
+		t.Fatalf("Failed to get value: %v", err)
+	}
+	if !bytes.Equal(value, []byte("value")) {
+		t.Errorf("Expected value 'value', got '%s'", value)
+	}
+	if value, _ := l1.Get("key"); !bytes.Equal(value, []byte("value")) {
+		t.Errorf("Expected value to be promoted to the first level, got '%s'", value)
+	}
+
+	if value, err := tc.Get("missing"); value != nil || err != nil {
+		t.Errorf("Expected a miss, got '%s', %v", value, err)
+	}
+
+	if _, err := NewTieredCache(TieredCacheOptions{}); err == nil {
+		t.Error("Expected an error for a tiered cache without levels")
+	}
+}
+
+func TestTieredCacheWrites(t *testing.T) {
+	l1 := NewMemoryCache(MemoryCacheOptions{})
+	l2 := NewMemoryCache(MemoryCacheOptions{})
+	tc, _ := NewTieredCache(TieredCacheOptions{}, l1, l2)
+
+	tc.Set("key", []byte("value"), time.Hour)
+	for i, level := range []*MemoryCache{l1, l2} {
+		if value, _ := level.Get("key"); value == nil {
+			t.Errorf("Expected write-through to level %d", i)
+		}
+	}
+
+	tc.Delete("key")
+	for i, level := range []*MemoryCache{l1, l2} {
+		if value, _ := level.Get("key"); value != nil {
+			t.Errorf("Expected key to be deleted from level %d", i)
+		}
+	}
+
+	// Write-around only writes the slowest level and invalidates the others
+	tc, _ = NewTieredCache(TieredCacheOptions{WriteAround: true}, l1, l2)
+	l1.Set("key", []byte("old"), time.Hour)
+	tc.Set("key", []byte("new"), time.Hour)
+	if value, _ := l1.Get("key"); value != nil {
+		t.Errorf("Expected the first level to be invalidated, got '%s'", value)
+	}
+	if value, _ := l2.Get("key"); !bytes.Equal(value, []byte("new")) {
+		t.Errorf("Expected value 'new' in the last level, got '%s'", value)
+	}
+
+	tc.Set("other", []byte("value"), time.Hour)
+	tc.Clear()
+	if l1.Len() != 0 || l2.Len() != 0 {
+		t.Errorf("Expected every level to be cleared, got %d and %d items", l1.Len(), l2.Len())
+	}
+}
+
+func TestTieredCacheLoaderAndNegativeCaching(t *testing.T) {
+	loads := 0
+	l1 := NewMemoryCache(MemoryCacheOptions{})
+	tc, _ := NewTieredCache(TieredCacheOptions{
+		Loader: func(key string) ([]byte, error) {
+			loads++
+			if key == "missing" {
+				return nil, nil
+			}
+			return []byte("loaded " + key), nil
+		},
+		NegativeTTL: time.Hour,
+	}, l1)
+
+	for i := 0; i < 2; i++ {
+		value, err := tc.Get("key")
+		if err != nil {
+			t.Fatalf("Failed to get value: %v", err)
+		}
+		if !bytes.Equal(value, []byte("loaded key")) {
+			t.Errorf("Expected value 'loaded key', got '%s'", value)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("Expected 1 load, got %d", loads)
+	}
+
+	for i := 0; i < 2; i++ {
+		if value, _ := tc.Get("missing"); value != nil {
+			t.Errorf("Expected a miss, got '%s'", value)
+		}
+	}
+	if loads != 2 {
+		t.Errorf("Expected the miss to be cached after 1 load, got %d loads", loads-1)
+	}
+
+	// Setting a key drops its negative entry
+	tc.Set("missing", []byte("now present"), time.Hour)
+	if value, _ := tc.Get("missing"); !bytes.Equal(value, []byte("now present")) {
+		t.Errorf("Expected value 'now present', got '%s'", value)
+	}
+}
+
+func TestTieredCacheLevelErrors(t *testing.T) {
+	l2 := NewMemoryCache(MemoryCacheOptions{})
+	tc, _ := NewTieredCache(TieredCacheOptions{}, failingCache{}, l2)
+
+	// A failing level is skipped on reads
+	l2.Set("key", []byte("value"), time.Hour)
+	if value, err := tc.Get("key"); err != nil || !bytes.Equal(value, []byte("value")) {
+		t.Errorf("Expected value 'value' despite the failing level, got '%s', %v", value, err)
+	}
+
+	// Errors are returned when no level has the value
+	if _, err := tc.Get("missing"); err == nil {
+		t.Error("Expected the level error to be returned on a miss")
+	}
+	if err := tc.Set("key", []byte("value"), time.Hour); err == nil {
+		t.Error("Expected Set to fail when a level fails")
+	}
+	if err := tc.Delete("key"); err == nil {
+		t.Error("Expected Delete to fail when a level fails")
+	}
+	if value, _ := l2.Get("key"); value != nil {
+		t.Error("Expected Delete to continue past the failing level")
+	}
+}
+
+func TestTieredCacheBatch(t *testing.T) {
+	l1 := NewMemoryCache(MemoryCacheOptions{})
+	l2 := NewMemoryCache(MemoryCacheOptions{})
+	var loaded []string
+	tc, _ := NewTieredCache(TieredCacheOptions{
+		Loader: func(key string) ([]byte, error) {
+			loaded = append(loaded, key)
+			if key == "absent" {
+				return nil, nil
+			}
+			return []byte("loaded"), nil
+		},
+		NegativeTTL: time.Hour,
+	}, l1, l2)
+
+	tc.MSet(map[string][]byte{"a": []byte("1")}, time.Hour)
+	l2.Set("b", []byte("2"), time.Hour)
+
+	values, err := tc.MGet([]string{"a", "b", "c", "absent"})
+	if err != nil {
+		t.Fatalf("Failed to get values: %v", err)
+	}
+	expected := map[string]string{"a": "1", "b": "2", "c": "loaded"}
+	if len(values) != len(expected) {
+		t.Errorf("Expected %d values, got %v", len(expected), values)
+	}
+	for key, value := range expected {
+		if string(values[key]) != value {
+			t.Errorf("Expected %s to be '%s', got '%s'", key, value, values[key])
+		}
+	}
+	if value, _ := l1.Get("b"); value == nil {
+		t.Error("Expected b to be promoted to the first level")
+	}
+	if len(loaded) != 2 {
+		t.Errorf("Expected only c and absent to be loaded, got %v", loaded)
+	}
+
+	// Loaded and missing keys are cached
+	tc.MGet([]string{"c", "absent"})
+	if len(loaded) != 2 {
+		t.Errorf("Expected no further loads, got %v", loaded)
+	}
+
+	tc.MDelete([]string{"a", "b", "c"})
+	if l1.Len() != 0 || l2.Len() != 0 {
+		t.Errorf("Expected every level to be empty, got %d and %d items", l1.Len(), l2.Len())
+	}
+}
@@ -0,0 +1,213 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/h2co32/gollama/internal/admin"
+	"github.com/h2co32/gollama/internal/loadbalancer"
+	"github.com/h2co32/gollama/internal/metrics"
+	"github.com/h2co32/gollama/internal/provider"
+	"github.com/h2co32/gollama/internal/quota"
+	"github.com/h2co32/gollama/internal/usage"
+	"github.com/h2co32/gollama/pkg/guard"
+	"github.com/h2co32/gollama/pkg/health"
+	"github.com/h2co32/gollama/pkg/middleware"
+	"github.com/h2co32/gollama/pkg/ratelimiter"
+)
+
+// Options configures a Gateway. Only Balancer is required; the other components
+// are skipped when nil.
+type Options struct {
+	// Balancer picks the backend Ollama instance for each request.
+	Balancer *loadbalancer.LoadBalancer
+
+	// Auth authenticates proxied requests.
+	Auth *middleware.AuthMiddleware
+
+	// Limiter limits the rate of proxied requests across all clients.
+	Limiter *ratelimiter.RateLimiter
+
+	// Limits bounds the body size and handling time of proxied requests.
+	Limits *middleware.LimitMiddleware
+
+	// Guard rejects proxied requests whose prompts fail its checks.
+	Guard *guard.Guard
+
+	// Metrics records request counts and latencies and is served at /metrics.
+	Metrics *metrics.MetricsProvider
+
+	// Providers serves the Ollama chat, generate, and embed requests for models it
+	// assigns a provider, such as an OpenAI-compatible or llama.cpp server. Requests for
+	// other models are proxied to the Balancer's Ollama instances.
+	Providers *provider.Router
+
+	// Usage accounts for the tokens and cost of successful model requests by tenant,
+	// and is served at /usage, authenticated with Auth. Tenants other than UsageAdmins
+	// only see their own usage there.
+	Usage *usage.Tracker
+
+	// Quota enforces the request and token quotas of each tenant on model requests,
+	// rejecting requests over quota with 429 Too Many Requests and reporting the
+	// remaining quota in X-Quota-* headers.
+	Quota *quota.Engine
+
+	// Tenant returns the tenant a request's usage and quotas are accounted to.
+	// Default: the "sub" claim of the authenticated client, such as the name of its API
+	// key, or "anonymous"
+	Tenant func(r *http.Request) string
+
+	// UsageAdmins are the tenants that can see the usage of every tenant at /usage.
+	// Optional.
+	UsageAdmins []string
+
+	// Admin is the admin API, served under /admin/ with its own authentication.
+	Admin *admin.API
+
+	// Health runs the checks served at /healthz and /readyz. The gateway adds a
+	// "backends" readiness check that passes while any backend is available.
+	// Default: a new Health with the default options
+	Health *health.Health
+}
+
+// Gateway is an HTTP server that proxies inference requests to a pool of Ollama
+// instances, or to other model providers, applying authentication, prompt checks, rate
+// limiting, and metrics on the way.
+type Gateway struct {
+	options Options
+	handler http.Handler
+}
+
+// New creates a Gateway with the given options.
+func New(options Options) (*Gateway, error) {
+	if options.Balancer == nil {
+		return nil, fmt.Errorf("gateway requires a load balancer")
+	}
+
+	gw := &Gateway{options: options}
+
+	proxyOptions := loadbalancer.DefaultProxyOptions()
+	proxyOptions.ErrorHandler = gw.proxyError
+	proxyOptions.RouteByModel = true
+	proxy := options.Balancer.HandlerWithOptions(proxyOptions)
+	if options.Providers != nil {
+		proxy = gw.routeProviders(proxy)
+	}
+	if options.Usage != nil || options.Quota != nil {
+		proxy = gw.account(proxy)
+	}
+	if options.Limiter != nil {
+		proxy = gw.rateLimit(proxy)
+	}
+	// Outside the rate limiter so rejected prompts do not use up its tokens
+	if options.Guard != nil {
+		proxy = options.Guard.Middleware(proxy)
+	}
+	if options.Auth != nil {
+		proxy = options.Auth.Middleware(proxy)
+	}
+	// Outside auth so HMAC verification reads at most the body limit
+	if options.Limits != nil {
+		proxy = options.Limits.Middleware(proxy)
+	}
+
+	mux := http.NewServeMux()
+	if options.Health == nil {
+		options.Health = health.New(health.DefaultOptions())
+	}
+	options.Health.AddReadinessCheck("backends", health.CheckerFunc(func(ctx context.Context) error {
+		_, err := options.Balancer.GetHealthyServer()
+		return err
+	}))
+	mux.HandleFunc("/health", gw.health)
+	mux.Handle("/healthz", options.Health.LivenessHandler())
+	mux.Handle("/readyz", options.Health.ReadinessHandler())
+	if options.Metrics != nil {
+		mux.Handle("/metrics", options.Metrics.Handler())
+	}
+	if options.Usage != nil {
+		var usageHandler http.Handler = http.HandlerFunc(gw.serveUsage)
+		if options.Auth != nil {
+			usageHandler = options.Auth.Middleware(usageHandler)
+		}
+		mux.Handle("/usage", usageHandler)
+	}
+	if options.Admin != nil {
+		mux.Handle("/admin/", options.Admin)
+	}
+	mux.Handle("/", proxy)
+
+	// Recover inside the metrics middleware so panics are counted as 500s
+	recovery := middleware.RecoverOptions{}
+	if options.Metrics != nil {
+		recovery.Metrics = options.Metrics
+	}
+	gw.handler = middleware.NewRecoverMiddleware(recovery).Middleware(mux)
+	if options.Metrics != nil {
+		gw.handler = options.Metrics.MiddlewareWithOptions(gw.handler, metrics.HTTPOptions{Route: route})
+	}
+	return gw, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (gw *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	gw.handler.ServeHTTP(w, r)
+}
+
+// proxyError records a failed proxied request and reports it to the client.
+func (gw *Gateway) proxyError(w http.ResponseWriter, r *http.Request, err error) {
+	if middleware.WriteLimitError(w, err) {
+		gw.trackError(r, "limit")
+		return
+	}
+	status, reason := http.StatusBadGateway, "backend"
+	if errors.Is(err, loadbalancer.ErrNoHealthyServers) || errors.Is(err, loadbalancer.ErrServersBusy) {
+		status, reason = http.StatusServiceUnavailable, "no_backend"
+	}
+	gw.trackError(r, reason)
+	middleware.JSONResponse(w, status, map[string]string{"error": err.Error()})
+}
+
+// rateLimit rejects requests once the limiter runs out of tokens.
+func (gw *Gateway) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !gw.options.Limiter.Allow() {
+			gw.trackError(r, "rate_limited")
+			w.Header().Set("Retry-After", "1")
+			middleware.JSONResponse(w, http.StatusTooManyRequests, map[string]string{"error": "rate limit exceeded"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// health reports whether at least one backend is available.
+func (gw *Gateway) health(w http.ResponseWriter, r *http.Request) {
+	if _, err := gw.options.Balancer.GetHealthyServer(); err != nil {
+		middleware.JSONResponse(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable"})
+		return
+	}
+	middleware.JSONResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// route returns the metrics route of a request: the Ollama or OpenAI-compatible API
+// path of a proxied request, and the mux pattern of the gateway's own endpoints.
+func route(r *http.Request) string {
+	if r.Pattern != "/" {
+		return r.Pattern
+	}
+	if strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/v1/") {
+		return r.URL.Path
+	}
+	return "other"
+}
+
+// trackError records a gateway error for the request's endpoint.
+func (gw *Gateway) trackError(r *http.Request, errorType string) {
+	if gw.options.Metrics != nil {
+		gw.options.Metrics.TrackError(r.URL.Path, errorType)
+	}
+}
@@ -0,0 +1,150 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/h2co32/gollama/internal/models"
+	"github.com/h2co32/gollama/pkg/middleware"
+)
+
+// routeProviders serves Ollama chat, generate, and embed requests for models the
+// provider router assigns a provider, and passes other requests to next.
+func (gw *Gateway) routeProviders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/chat", "/api/generate", "/api/embed":
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			gw.proxyError(w, r, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		var peek struct {
+			Model string `json:"model"`
+		}
+		json.Unmarshal(body, &peek)
+		p, _, err := gw.options.Providers.Route(peek.Model)
+		if err != nil {
+			// Models without a provider are served by the Ollama instances
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/api/chat":
+			var req models.ChatRequest
+			if !gw.decodeRequest(w, r, body, &req) {
+				return
+			}
+			stream := &providerStream{gw: gw, w: w, r: r, streaming: req.Stream == nil || *req.Stream}
+			stream.finish(p.Chat(r.Context(), req, func(res models.ChatResponse) error {
+				return stream.write(res)
+			}))
+		case "/api/generate":
+			var req models.GenerateRequest
+			if !gw.decodeRequest(w, r, body, &req) {
+				return
+			}
+			stream := &providerStream{gw: gw, w: w, r: r, streaming: req.Stream == nil || *req.Stream}
+			stream.finish(p.Generate(r.Context(), req, func(res models.GenerateResponse) error {
+				return stream.write(res)
+			}))
+		case "/api/embed":
+			var req models.EmbedRequest
+			if !gw.decodeRequest(w, r, body, &req) {
+				return
+			}
+			res, err := p.Embed(r.Context(), req)
+			if err != nil {
+				gw.providerError(w, r, err)
+				return
+			}
+			middleware.JSONResponse(w, http.StatusOK, res)
+		}
+	})
+}
+
+// decodeRequest decodes a JSON request body into req, responding with 400 Bad Request
+// and returning false if it is not valid.
+func (gw *Gateway) decodeRequest(w http.ResponseWriter, r *http.Request, body []byte, req interface{}) bool {
+	if err := json.Unmarshal(body, req); err != nil {
+		gw.trackError(r, "bad_request")
+		middleware.JSONResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid request body: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+// providerError records a failed provider request and reports it to the client. Client
+// errors and rate limiting by the provider keep their status; other failures are
+// reported as 502 Bad Gateway.
+func (gw *Gateway) providerError(w http.ResponseWriter, r *http.Request, err error) {
+	if middleware.WriteLimitError(w, err) {
+		gw.trackError(r, "limit")
+		return
+	}
+	status := http.StatusBadGateway
+	var apiErr *models.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode >= 400 && apiErr.StatusCode < 500 {
+		status = apiErr.StatusCode
+	}
+	gw.trackError(r, "provider")
+	middleware.JSONResponse(w, status, map[string]string{"error": err.Error()})
+}
+
+// providerStream writes provider responses to the client like Ollama does: one JSON
+// object per line when streaming, flushed as they arrive, or a single JSON object.
+type providerStream struct {
+	gw        *Gateway
+	w         http.ResponseWriter
+	r         *http.Request
+	streaming bool
+	started   bool
+}
+
+// write sends a response, or a chunk of a streamed response.
+func (s *providerStream) write(res interface{}) error {
+	if !s.started {
+		s.started = true
+		if s.streaming {
+			s.w.Header().Set("Content-Type", "application/x-ndjson")
+		} else {
+			s.w.Header().Set("Content-Type", "application/json")
+		}
+		s.w.WriteHeader(http.StatusOK)
+	}
+	if err := json.NewEncoder(s.w).Encode(res); err != nil {
+		return err
+	}
+	if s.streaming {
+		http.NewResponseController(s.w).Flush()
+	}
+	return nil
+}
+
+// finish reports err, as an error response if nothing was sent yet, or else as an
+// error object ending the stream.
+func (s *providerStream) finish(err error) {
+	if err == nil {
+		return
+	}
+	if !s.started {
+		s.gw.providerError(s.w, s.r, err)
+		return
+	}
+	s.gw.trackError(s.r, "provider")
+	json.NewEncoder(s.w).Encode(map[string]string{"error": err.Error()})
+}
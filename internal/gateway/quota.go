@@ -0,0 +1,25 @@
+package gateway
+
+import (
+	"net/http"
+
+	"github.com/h2co32/gollama/pkg/logging"
+)
+
+// admit checks the tenant's quotas for a request, reports them in the response headers,
+// and rejects the request if it is over quota. Requests are admitted if the quota store
+// fails, so an outage of Redis does not take the gateway down with it.
+func (gw *Gateway) admit(w http.ResponseWriter, r *http.Request, tenant string) bool {
+	decision, err := gw.options.Quota.Allow(r.Context(), tenant)
+	if err != nil {
+		logging.Default().WarnContext(r.Context(), "failed to check quota", "tenant", tenant, "error", err)
+		return true
+	}
+	decision.SetHeaders(w.Header())
+	if !decision.Allowed {
+		gw.trackError(r, "quota")
+		decision.WriteRejection(w)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,196 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/h2co32/gollama/internal/usage"
+	"github.com/h2co32/gollama/pkg/logging"
+	"github.com/h2co32/gollama/pkg/middleware"
+)
+
+// usagePaths are the Ollama and OpenAI-compatible API paths of model requests, which
+// are accounted for and subject to quotas.
+var usagePaths = map[string]bool{
+	"/api/generate":        true,
+	"/api/chat":            true,
+	"/api/embed":           true,
+	"/api/embeddings":      true,
+	"/v1/chat/completions": true,
+	"/v1/completions":      true,
+	"/v1/embeddings":       true,
+}
+
+// maxUsageLine is the longest response line searched for token counts. Longer lines,
+// such as those of large embedding responses, are passed on without being searched.
+const maxUsageLine = 4 << 20
+
+// anonymousTenant is the tenant of requests without an authenticated client.
+const anonymousTenant = "anonymous"
+
+// account enforces the quotas of model requests, and records successful ones with the
+// usage tracker and counts their tokens against the quotas. The tokens are read from
+// the responses as they are written: the prompt_eval_count and eval_count of Ollama's
+// final chunk, or the usage of an OpenAI-compatible response. The model is the one
+// requested, so it matches the configured rates.
+func (gw *Gateway) account(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !usagePaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tenant := gw.tenant(r)
+		if gw.options.Quota != nil && !gw.admit(w, r, tenant) {
+			return
+		}
+
+		var model string
+		if r.Body != nil {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				gw.proxyError(w, r, err)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			var peek struct {
+				Model string `json:"model"`
+			}
+			json.Unmarshal(body, &peek)
+			model = peek.Model
+		}
+
+		start := time.Now()
+		uw := &usageWriter{StatusRecorder: middleware.NewStatusRecorder(w)}
+		next.ServeHTTP(uw, r)
+		uw.parse()
+		if !uw.Started() || uw.Status() >= http.StatusBadRequest {
+			return
+		}
+		if model == "" {
+			model = uw.model
+		}
+		if gw.options.Usage != nil {
+			gw.options.Usage.Record(usage.Record{
+				Tenant:           tenant,
+				Model:            model,
+				PromptTokens:     uw.promptTokens,
+				CompletionTokens: uw.completionTokens,
+				Latency:          time.Since(start),
+			})
+		}
+		if gw.options.Quota != nil {
+			// Count the tokens even if the client went away before the response ended
+			ctx := context.WithoutCancel(r.Context())
+			if err := gw.options.Quota.AddTokens(ctx, tenant, uw.promptTokens+uw.completionTokens); err != nil {
+				logging.Default().WarnContext(ctx, "failed to count quota tokens", "tenant", tenant, "error", err)
+			}
+		}
+	})
+}
+
+// tenant returns the tenant a request's usage and quotas are accounted to.
+func (gw *Gateway) tenant(r *http.Request) string {
+	if gw.options.Tenant != nil {
+		return gw.options.Tenant(r)
+	}
+	if claims, ok := middleware.GetUserFromContext(r.Context()); ok {
+		if subject, ok := claims["sub"].(string); ok && subject != "" {
+			return subject
+		}
+	}
+	return anonymousTenant
+}
+
+// serveUsage reports usage. Tenants other than UsageAdmins only see their own.
+func (gw *Gateway) serveUsage(w http.ResponseWriter, r *http.Request) {
+	if tenant := gw.tenant(r); !slices.Contains(gw.options.UsageAdmins, tenant) {
+		query := r.URL.Query()
+		query.Set("tenant", tenant)
+		r.URL.RawQuery = query.Encode()
+	}
+	gw.options.Usage.Handler().ServeHTTP(w, r)
+}
+
+// usageWriter reads the model and token counts from a response as it is written, one
+// line at a time, so streamed responses are read chunk by chunk. The last counts
+// written win, since streams report them on their final chunk.
+type usageWriter struct {
+	*middleware.StatusRecorder
+	line     []byte // The current line, up to maxUsageLine
+	overflow bool   // Whether the current line is longer than maxUsageLine
+
+	model            string
+	promptTokens     int
+	completionTokens int
+}
+
+// Write searches the written lines for token counts.
+func (uw *usageWriter) Write(b []byte) (int, error) {
+	n, err := uw.StatusRecorder.Write(b)
+	for written := b[:n]; len(written) > 0; {
+		i := bytes.IndexByte(written, '\n')
+		if i < 0 {
+			uw.buffer(written)
+			break
+		}
+		uw.buffer(written[:i])
+		uw.parse()
+		written = written[i+1:]
+	}
+	return n, err
+}
+
+// buffer adds b to the current line, unless that makes it too long.
+func (uw *usageWriter) buffer(b []byte) {
+	if uw.overflow {
+		return
+	}
+	if len(uw.line)+len(b) > maxUsageLine {
+		uw.line, uw.overflow = nil, true
+		return
+	}
+	uw.line = append(uw.line, b...)
+}
+
+// parse reads the model and token counts of the current line, a JSON object or a
+// server-sent event holding one, and starts a new line.
+func (uw *usageWriter) parse() {
+	line := bytes.TrimSpace(uw.line)
+	uw.line, uw.overflow = uw.line[:0], false
+
+	line = bytes.TrimSpace(bytes.TrimPrefix(line, []byte("data:")))
+	// Both eval_count and prompt_eval_count contain "eval_count"
+	if len(line) == 0 || line[0] != '{' ||
+		!(bytes.Contains(line, []byte(`eval_count"`)) || bytes.Contains(line, []byte(`"usage"`))) {
+		return
+	}
+	var res struct {
+		Model           string `json:"model"`
+		PromptEvalCount *int   `json:"prompt_eval_count"`
+		EvalCount       *int   `json:"eval_count"`
+		Usage           *struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(line, &res); err != nil {
+		return
+	}
+	if res.Model != "" {
+		uw.model = res.Model
+	}
+	if res.Usage != nil {
+		uw.promptTokens, uw.completionTokens = res.Usage.PromptTokens, res.Usage.CompletionTokens
+	}
+	if res.PromptEvalCount != nil {
+		uw.promptTokens = *res.PromptEvalCount
+	}
+	if res.EvalCount != nil {
+		uw.completionTokens = *res.EvalCount
+	}
+}
@@ -0,0 +1,211 @@
+package loadbalancer
+
+import (
+	"time"
+)
+
+// CircuitBreakerOptions configures the ejection of servers that fail too many requests.
+// Results are reported with ObserveResult, which the proxy handler does for every
+// request. An ejected server receives no requests until its ejection time has passed,
+// then a single probe request decides whether it is readmitted or ejected again for
+// twice as long.
+type CircuitBreakerOptions struct {
+	// ErrorRate is the fraction of a server's requests within Window that must fail
+	// for it to be ejected, between 0 and 1. Zero disables circuit breaking.
+	// Default: 0
+	ErrorRate float64
+
+	// MinRequests is the number of results a server must have within Window before
+	// its error rate is considered, so a single failure on a quiet server does not
+	// eject it.
+	// Default: 10
+	MinRequests int
+
+	// Window is the period over which results are counted.
+	// Default: 30 seconds
+	Window time.Duration
+
+	// EjectionTime is how long a server is ejected the first time. It doubles with
+	// each consecutive ejection, up to MaxEjectionTime.
+	// Default: 30 seconds
+	EjectionTime time.Duration
+
+	// MaxEjectionTime is the longest a server is ejected for.
+	// Default: 5 minutes
+	MaxEjectionTime time.Duration
+
+	// MaxEjectedFraction is the largest fraction of the servers ejected at once, so a
+	// problem affecting every server, such as a malformed client request, does not
+	// eject them all. One server can always be ejected.
+	// Default: 0.5
+	MaxEjectedFraction float64
+}
+
+// DefaultCircuitBreakerOptions returns the default circuit breaker options.
+func DefaultCircuitBreakerOptions() CircuitBreakerOptions {
+	return CircuitBreakerOptions{
+		MinRequests:        10,
+		Window:             30 * time.Second,
+		EjectionTime:       30 * time.Second,
+		MaxEjectionTime:    5 * time.Minute,
+		MaxEjectedFraction: 0.5,
+	}
+}
+
+// circuitState is the state of a server's circuit breaker.
+type circuitState int
+
+const (
+	circuitClosed   circuitState = iota // Receiving requests
+	circuitOpen                         // Ejected until the ejection time passes
+	circuitHalfOpen                     // Waiting for a probe request to succeed
+)
+
+// circuitSlots is the number of slots the result window is divided into.
+const circuitSlots = 10
+
+// circuit tracks the recent results of a server and whether it is ejected.
+type circuit struct {
+	state     circuitState
+	slots     [circuitSlots]circuitSlot
+	until     time.Time // End of the ejection, or of the probe when half-open
+	probing   bool      // Whether a probe request has been sent while half-open
+	ejections int       // Consecutive ejections, for the backoff
+}
+
+// circuitSlot counts the results reported in one slice of the window.
+type circuitSlot struct {
+	start    time.Time
+	requests int
+	failures int
+}
+
+// ObserveResult reports whether a request to server succeeded, for circuit breaking.
+// Callers should count connection errors and 5xx responses as failures, and not
+// client errors such as 4xx responses.
+func (lb *LoadBalancer) ObserveResult(server string, ok bool) {
+	if lb.breaker.ErrorRate <= 0 {
+		return
+	}
+
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+
+	stats, exists := lb.stats[server]
+	if !exists {
+		return
+	}
+	c := &stats.circuit
+	now := time.Now()
+
+	switch c.state {
+	case circuitOpen:
+		// Results of requests started before the ejection
+		return
+	case circuitHalfOpen:
+		if ok {
+			*c = circuit{}
+			if lb.metrics != nil {
+				lb.metrics.TrackBackendReadmission(server)
+			}
+		} else {
+			lb.eject(server, now)
+		}
+		return
+	}
+
+	slot := lb.circuitSlot(c, now)
+	slot.requests++
+	if !ok {
+		slot.failures++
+	}
+
+	var requests, failures int
+	for _, s := range c.slots {
+		if now.Sub(s.start) < lb.breaker.Window {
+			requests += s.requests
+			failures += s.failures
+		}
+	}
+	if requests >= lb.breaker.MinRequests && float64(failures) >= lb.breaker.ErrorRate*float64(requests) && lb.canEject() {
+		lb.eject(server, now)
+	}
+}
+
+// circuitSlot returns the slot of c for now, resetting it if it last held an earlier
+// slice of time.
+// This method is not thread-safe and should be called with the lock held.
+func (lb *LoadBalancer) circuitSlot(c *circuit, now time.Time) *circuitSlot {
+	width := max(lb.breaker.Window/circuitSlots, 1)
+	start := now.Truncate(width)
+	s := &c.slots[(start.UnixNano()/int64(width))%circuitSlots]
+	if !s.start.Equal(start) {
+		*s = circuitSlot{start: start}
+	}
+	return s
+}
+
+// canEject reports whether another server can be ejected without exceeding
+// MaxEjectedFraction.
+// This method is not thread-safe and should be called with the lock held.
+func (lb *LoadBalancer) canEject() bool {
+	ejected := 0
+	for _, stats := range lb.stats {
+		if stats.circuit.state != circuitClosed {
+			ejected++
+		}
+	}
+	return ejected < max(1, int(lb.breaker.MaxEjectedFraction*float64(len(lb.stats))))
+}
+
+// eject stops sending requests to server for its backoff period.
+// This method is not thread-safe and should be called with the lock held.
+func (lb *LoadBalancer) eject(server string, now time.Time) {
+	c := &lb.stats[server].circuit
+	c.ejections++
+	ejection := lb.breaker.EjectionTime
+	for i := 1; i < c.ejections && ejection < lb.breaker.MaxEjectionTime; i++ {
+		ejection *= 2
+	}
+	ejection = min(ejection, lb.breaker.MaxEjectionTime)
+
+	c.state = circuitOpen
+	c.until = now.Add(ejection)
+	c.probing = false
+	c.slots = [circuitSlots]circuitSlot{}
+	if lb.metrics != nil {
+		lb.metrics.TrackBackendEjection(server)
+	}
+}
+
+// circuitAllows reports whether server's circuit breaker lets a request through,
+// moving it to half-open once its ejection time has passed.
+// This method is not thread-safe and should be called with the lock held.
+func (lb *LoadBalancer) circuitAllows(server string, now time.Time) bool {
+	c := &lb.stats[server].circuit
+	switch c.state {
+	case circuitOpen:
+		if now.Before(c.until) {
+			return false
+		}
+		c.state = circuitHalfOpen
+		c.probing = false
+		return true
+	case circuitHalfOpen:
+		// A probe whose result never arrived is replaced after an ejection time
+		return !c.probing || !now.Before(c.until)
+	default:
+		return true
+	}
+}
+
+// chose records that server was chosen for a request, making it the probe if its
+// circuit breaker is half-open.
+// This method is not thread-safe and should be called with the lock held.
+func (lb *LoadBalancer) chose(server string) {
+	c := &lb.stats[server].circuit
+	if c.state == circuitHalfOpen {
+		c.probing = true
+		c.until = time.Now().Add(lb.breaker.EjectionTime)
+	}
+}
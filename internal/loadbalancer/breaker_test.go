@@ -0,0 +1,176 @@
+package loadbalancer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/h2co32/gollama/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// newBreakerTestBalancer creates a load balancer that ejects servers after 5 results
+// with at least half failing.
+func newBreakerTestBalancer(servers []string, ejectionTime time.Duration, m *metrics.MetricsProvider) *LoadBalancer {
+	options := DefaultOptions()
+	options.HealthCheckInterval = time.Hour
+	options.CircuitBreaker.ErrorRate = 0.5
+	options.CircuitBreaker.MinRequests = 5
+	options.CircuitBreaker.EjectionTime = ejectionTime
+	options.CircuitBreaker.MaxEjectedFraction = 1
+	options.Metrics = m
+	return NewLoadBalancerWithOptions(context.Background(), servers, options)
+}
+
+// backendMetric returns the value of a backend-labeled metric, or 0 if it has not been recorded.
+func backendMetric(t *testing.T, name, backend string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "backend" && label.GetValue() == backend {
+					if metric.GetCounter() != nil {
+						return metric.GetCounter().GetValue()
+					}
+					return metric.GetGauge().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestCircuitBreakerEjectsAndReadmits(t *testing.T) {
+	servers := []string{"server1:8080", "server2:8080"}
+	lb := newBreakerTestBalancer(servers, 50*time.Millisecond, metrics.NewMetricsProvider())
+
+	// Below MinRequests nothing is ejected
+	for i := 0; i < 4; i++ {
+		lb.ObserveResult("server1:8080", false)
+	}
+	if lb.stats["server1:8080"].circuit.state != circuitClosed {
+		t.Fatal("Expected server1:8080 not to be ejected before MinRequests results")
+	}
+	lb.ObserveResult("server1:8080", false)
+	if lb.stats["server1:8080"].circuit.state != circuitOpen {
+		t.Fatal("Expected server1:8080 to be ejected")
+	}
+	if got := backendMetric(t, "backend_ejections_total", "server1:8080"); got != 1 {
+		t.Errorf("Expected 1 ejection, got %v", got)
+	}
+	if got := backendMetric(t, "backend_ejected", "server1:8080"); got != 1 {
+		t.Errorf("Expected server1:8080 to be reported as ejected, got %v", got)
+	}
+
+	for i := 0; i < 4; i++ {
+		if server, _ := lb.GetHealthyServer(); server != "server2:8080" {
+			t.Errorf("Expected server2:8080 while server1:8080 is ejected, got %s", server)
+		}
+	}
+
+	// After the ejection time, one probe request is let through
+	time.Sleep(60 * time.Millisecond)
+	probes := 0
+	for i := 0; i < 4; i++ {
+		if server, _ := lb.GetHealthyServer(); server == "server1:8080" {
+			probes++
+		}
+	}
+	if probes != 1 {
+		t.Errorf("Expected exactly 1 probe request to server1:8080, got %d", probes)
+	}
+
+	// A successful probe readmits the server
+	lb.ObserveResult("server1:8080", true)
+	if lb.stats["server1:8080"].circuit.state != circuitClosed {
+		t.Error("Expected server1:8080 to be readmitted after a successful probe")
+	}
+	if got := backendMetric(t, "backend_ejected", "server1:8080"); got != 0 {
+		t.Errorf("Expected server1:8080 to be reported as readmitted, got %v", got)
+	}
+}
+
+func TestCircuitBreakerBackoff(t *testing.T) {
+	lb := newBreakerTestBalancer([]string{"server1:8080", "server2:8080"}, time.Minute, nil)
+	for i := 0; i < 5; i++ {
+		lb.ObserveResult("server1:8080", false)
+	}
+	c := &lb.stats["server1:8080"].circuit
+	first := time.Until(c.until)
+
+	// A failed probe ejects the server again for twice as long
+	c.until = time.Now()
+	lb.GetHealthyServer()
+	lb.GetHealthyServer()
+	if c.state != circuitHalfOpen || !c.probing {
+		t.Fatal("Expected server1:8080 to be probing")
+	}
+	lb.ObserveResult("server1:8080", false)
+	if c.state != circuitOpen {
+		t.Fatal("Expected server1:8080 to be ejected again after a failed probe")
+	}
+	if second := time.Until(c.until); second < first*2-time.Second {
+		t.Errorf("Expected the second ejection to last about %v, got %v", first*2, second)
+	}
+}
+
+func TestCircuitBreakerMaxEjectedFraction(t *testing.T) {
+	options := DefaultOptions()
+	options.HealthCheckInterval = time.Hour
+	options.CircuitBreaker.ErrorRate = 0.5
+	options.CircuitBreaker.MinRequests = 1
+	lb := NewLoadBalancerWithOptions(context.Background(), []string{"server1:8080", "server2:8080"}, options)
+
+	lb.ObserveResult("server1:8080", false)
+	lb.ObserveResult("server2:8080", false)
+	if lb.stats["server2:8080"].circuit.state != circuitClosed {
+		t.Error("Expected server2:8080 to stay in rotation with half the servers ejected")
+	}
+	if _, err := lb.GetHealthyServer(); err != nil {
+		t.Errorf("Expected a server to remain available, got %v", err)
+	}
+}
+
+func TestCircuitBreakerDisabledByDefault(t *testing.T) {
+	lb := NewLoadBalancer(context.Background(), []string{"server1:8080"}, time.Hour, 1)
+	for i := 0; i < 100; i++ {
+		lb.ObserveResult("server1:8080", false)
+	}
+	if _, err := lb.GetHealthyServer(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestHandlerReportsFailures(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	healthy := newProxyBackend(t, "b")
+
+	lb := newBreakerTestBalancer([]string{strings.TrimPrefix(failing.URL, "http://"), healthy}, time.Minute, nil)
+	proxy := httptest.NewServer(lb.Handler())
+	defer proxy.Close()
+
+	// Every request eventually goes to the healthy server once the failing one is ejected
+	for i := 0; i < 20; i++ {
+		res, err := http.Post(proxy.URL+"/api/generate", "application/json", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		res.Body.Close()
+	}
+	if lb.stats[strings.TrimPrefix(failing.URL, "http://")].circuit.state != circuitOpen {
+		t.Error("Expected the server returning 500s to be ejected")
+	}
+}
@@ -0,0 +1,113 @@
+package loadbalancer
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// hashRing maps keys to servers with consistent hashing. Each server is placed on the
+// ring at several points, in proportion to its weight, so adding or removing a server
+// only moves the keys between it and its neighbors.
+type hashRing struct {
+	points  []uint64 // Sorted positions on the ring
+	servers []string // servers[i] owns points[i]
+}
+
+// newHashRing places each server on the ring replicas times per unit of weight.
+func newHashRing(servers []string, weight func(server string) int, replicas int) *hashRing {
+	type point struct {
+		hash   uint64
+		server string
+	}
+	var points []point
+	for _, server := range servers {
+		for i := 0; i < replicas*weight(server); i++ {
+			points = append(points, point{hashKey(server + "#" + strconv.Itoa(i)), server})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].hash == points[j].hash {
+			return points[i].server < points[j].server
+		}
+		return points[i].hash < points[j].hash
+	})
+
+	ring := &hashRing{
+		points:  make([]uint64, len(points)),
+		servers: make([]string, len(points)),
+	}
+	for i, p := range points {
+		ring.points[i] = p.hash
+		ring.servers[i] = p.server
+	}
+	return ring
+}
+
+// lookup returns the first server clockwise from key for which accept returns true,
+// asking about each server at most once.
+func (r *hashRing) lookup(key string, accept func(server string) bool) (string, bool) {
+	if len(r.points) == 0 {
+		return "", false
+	}
+
+	hash := hashKey(key)
+	start := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= hash })
+	seen := make(map[string]bool)
+	for n := 0; n < len(r.points); n++ {
+		server := r.servers[(start+n)%len(r.points)]
+		if seen[server] {
+			continue
+		}
+		seen[server] = true
+		if accept(server) {
+			return server, true
+		}
+	}
+	return "", false
+}
+
+// hashKey hashes s with FNV-1a and mixes the result, since FNV alone spreads similar
+// strings such as "server#1" and "server#2" unevenly around the ring.
+func hashKey(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// GetServerForKey returns the healthy server that key maps to with consistent
+// hashing, so requests for the same model or conversation go to the same server and
+// find its model already loaded. If that server is unhealthy or at its connection
+// limit, the next server on the ring is used, and the key returns to its server once
+// it recovers. The configured strategy is not consulted.
+func (lb *LoadBalancer) GetServerForKey(key string) (string, error) {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	return lb.nextForKey(key, nil)
+}
+
+// nextForKey returns the server key maps to, skipping servers that are excluded or
+// unavailable.
+// This method is not thread-safe and should be called with the lock held.
+func (lb *LoadBalancer) nextForKey(key string, exclude map[string]bool) (string, error) {
+	sel := &selection{lb: lb, exclude: exclude}
+	if server, ok := lb.ring.lookup(key, sel.available); ok {
+		lb.chose(server)
+		return server, nil
+	}
+	return "", sel.err()
+}
+
+// buildRing places the servers on the hash ring with their current weights.
+// This method is not thread-safe and should be called with the lock held.
+func (lb *LoadBalancer) buildRing() {
+	lb.ring = newHashRing(lb.servers, func(server string) int {
+		return lb.stats[server].weight
+	}, lb.replicas)
+}
@@ -0,0 +1,127 @@
+package loadbalancer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetServerForKey(t *testing.T) {
+	servers := []string{"server1:8080", "server2:8080", "server3:8080"}
+	lb := NewLoadBalancer(context.Background(), servers, time.Hour, 1)
+
+	// The same key always maps to the same server
+	assigned := make(map[string]string)
+	counts := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprintf("conversation-%d", i)
+		server, err := lb.GetServerForKey(key)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if again, _ := lb.GetServerForKey(key); again != server {
+			t.Fatalf("Expected key %s to map to %s again, got %s", key, server, again)
+		}
+		assigned[key] = server
+		counts[server]++
+	}
+	for _, server := range servers {
+		if counts[server] < 700 || counts[server] > 1300 {
+			t.Errorf("Expected about 1000 keys on %s, got %d", server, counts[server])
+		}
+	}
+
+	// Only the keys of an unhealthy server move, and they move back when it recovers
+	lb.healthChecks["server2:8080"] = false
+	for key, server := range assigned {
+		moved, err := lb.GetServerForKey(key)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if server == "server2:8080" && moved == server {
+			t.Errorf("Expected key %s to move off the unhealthy server", key)
+		}
+		if server != "server2:8080" && moved != server {
+			t.Errorf("Expected key %s to stay on %s, got %s", key, server, moved)
+		}
+	}
+	lb.healthChecks["server2:8080"] = true
+	for key, server := range assigned {
+		if back, _ := lb.GetServerForKey(key); back != server {
+			t.Errorf("Expected key %s to return to %s, got %s", key, server, back)
+		}
+	}
+
+	for _, server := range servers {
+		lb.healthChecks[server] = false
+	}
+	if _, err := lb.GetServerForKey("conversation-1"); err != ErrNoHealthyServers {
+		t.Errorf("Expected ErrNoHealthyServers, got %v", err)
+	}
+}
+
+func TestGetServerForKeyWeights(t *testing.T) {
+	servers := []string{"server1:8080", "server2:8080"}
+	lb := NewLoadBalancer(context.Background(), servers, time.Hour, 1)
+	if err := lb.SetWeight("server1:8080", 3); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 4000; i++ {
+		server, _ := lb.GetServerForKey(fmt.Sprintf("model-%d", i))
+		counts[server]++
+	}
+	if counts["server1:8080"] < 2600 || counts["server1:8080"] > 3400 {
+		t.Errorf("Expected about 3000 keys on the heavier server, got %d", counts["server1:8080"])
+	}
+}
+
+func TestHashRingRemovingServer(t *testing.T) {
+	weight := func(string) int { return 1 }
+	all := newHashRing([]string{"a", "b", "c", "d"}, weight, 100)
+	fewer := newHashRing([]string{"a", "b", "c"}, weight, 100)
+	accept := func(string) bool { return true }
+
+	// Keys that were not on the removed server keep their server
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		before, _ := all.lookup(key, accept)
+		after, _ := fewer.lookup(key, accept)
+		if before != "d" && before != after {
+			t.Errorf("Expected key %s to stay on %s, got %s", key, before, after)
+		}
+	}
+}
+
+func TestHandlerAffinityKey(t *testing.T) {
+	servers := []string{newProxyBackend(t, "a"), newProxyBackend(t, "b"), newProxyBackend(t, "c")}
+	lb := NewLoadBalancer(context.Background(), servers, time.Hour, 1)
+	options := DefaultProxyOptions()
+	options.AffinityKey = func(r *http.Request) string { return r.Header.Get("X-Session-ID") }
+	proxy := httptest.NewServer(lb.HandlerWithOptions(options))
+	defer proxy.Close()
+
+	for _, session := range []string{"alice", "bob"} {
+		var first string
+		for i := 0; i < 5; i++ {
+			req, _ := http.NewRequest(http.MethodGet, proxy.URL+"/api/tags", nil)
+			req.Header.Set("X-Session-ID", session)
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			body, _ := io.ReadAll(res.Body)
+			res.Body.Close()
+			if i == 0 {
+				first = string(body)
+			} else if string(body) != first {
+				t.Errorf("Expected session %s to stay on '%s', got '%s'", session, first, body)
+			}
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package loadbalancer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InventoryOptions configures polling of the models each server has.
+type InventoryOptions struct {
+	// Interval is how often each server's /api/ps and /api/tags endpoints are polled.
+	// Zero disables polling, and GetServerForModel falls back to the strategy.
+	// Default: 0
+	Interval time.Duration
+
+	// Timeout bounds the requests to each server.
+	// Default: 5 seconds
+	Timeout time.Duration
+}
+
+// DefaultInventoryOptions returns the default inventory options.
+func DefaultInventoryOptions() InventoryOptions {
+	return InventoryOptions{
+		Timeout: 5 * time.Second,
+	}
+}
+
+// Inventory is the set of models a server had when it was last polled.
+type Inventory struct {
+	// Loaded are the models in the server's memory, from /api/ps.
+	Loaded []string
+
+	// Available are the models installed on the server, from /api/tags.
+	Available []string
+
+	// UpdatedAt is when the inventory was polled, or zero if it never was.
+	UpdatedAt time.Time
+}
+
+// modelList is the response of /api/ps and /api/tags.
+type modelList struct {
+	Models []struct {
+		Name  string `json:"name"`
+		Model string `json:"model"`
+	} `json:"models"`
+}
+
+// normalizeModel adds the "latest" tag Ollama assumes for models named without one.
+func normalizeModel(model string) string {
+	if model != "" && !strings.Contains(model, ":") {
+		return model + ":latest"
+	}
+	return model
+}
+
+// contains reports whether the sorted list of models includes model.
+func contains(models []string, model string) bool {
+	i := sort.SearchStrings(models, model)
+	return i < len(models) && models[i] == model
+}
+
+// GetServerForModel returns a healthy server for a request for model, preferring
+// servers that already have it loaded, then servers that have it installed, so the
+// request does not wait for the model to be loaded or pulled. Servers within the
+// preferred group are chosen by the configured strategy. Servers that have not been
+// polled are treated as not having the model.
+func (lb *LoadBalancer) GetServerForModel(model string) (string, error) {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	return lb.nextForModel(model, nil)
+}
+
+// nextForModel returns the server to use for model, skipping the excluded ones.
+// This method is not thread-safe and should be called with the lock held.
+func (lb *LoadBalancer) nextForModel(model string, exclude map[string]bool) (string, error) {
+	model = normalizeModel(model)
+	for _, has := range []func(Inventory) []string{
+		func(inv Inventory) []string { return inv.Loaded },
+		func(inv Inventory) []string { return inv.Available },
+	} {
+		// Exclude the servers without the model, as well as the ones already excluded
+		without := make(map[string]bool, len(lb.servers))
+		found := false
+		for _, server := range lb.servers {
+			if exclude[server] || !contains(has(lb.inventory[server]), model) {
+				without[server] = true
+			} else {
+				found = true
+			}
+		}
+		if !found {
+			continue
+		}
+		if server, err := lb.next(without); err == nil {
+			return server, nil
+		}
+	}
+	return lb.next(exclude)
+}
+
+// Inventory returns the models server had when it was last polled.
+func (lb *LoadBalancer) Inventory(server string) Inventory {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	return lb.inventory[server]
+}
+
+// RefreshInventory polls every server's /api/ps and /api/tags endpoints concurrently.
+// A server that cannot be polled keeps its previous inventory.
+func (lb *LoadBalancer) RefreshInventory() {
+	var wg sync.WaitGroup
+	wg.Add(len(lb.servers))
+
+	for _, server := range lb.servers {
+		go func() {
+			defer wg.Done()
+			inventory, err := lb.fetchInventory(server)
+			if err != nil {
+				return
+			}
+
+			lb.lock.Lock()
+			lb.inventory[server] = inventory
+			lb.lock.Unlock()
+		}()
+	}
+
+	wg.Wait()
+}
+
+// pollInventory refreshes the inventory right away and then every Interval until the
+// load balancer is stopped.
+func (lb *LoadBalancer) pollInventory() {
+	lb.RefreshInventory()
+	ticker := time.NewTicker(lb.inventoryOptions.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-lb.ctx.Done():
+			return
+		case <-ticker.C:
+			lb.RefreshInventory()
+		}
+	}
+}
+
+// fetchInventory polls the models loaded on and installed on server.
+func (lb *LoadBalancer) fetchInventory(server string) (Inventory, error) {
+	ctx, cancel := context.WithTimeout(lb.ctx, lb.inventoryOptions.Timeout)
+	defer cancel()
+
+	loaded, err := lb.fetchModels(ctx, server, "/api/ps")
+	if err != nil {
+		return Inventory{}, err
+	}
+	available, err := lb.fetchModels(ctx, server, "/api/tags")
+	if err != nil {
+		return Inventory{}, err
+	}
+	return Inventory{Loaded: loaded, Available: available, UpdatedAt: time.Now()}, nil
+}
+
+// fetchModels returns the sorted, normalized model names listed at path on server.
+func (lb *LoadBalancer) fetchModels(ctx context.Context, server, path string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s://%s%s", lb.scheme, server, path), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	res, err := lb.transport.RoundTrip(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s from %s: %w", path, server, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s from %s: status %d", path, server, res.StatusCode)
+	}
+
+	var list modelList
+	if err := json.NewDecoder(res.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to decode %s from %s: %w", path, server, err)
+	}
+	models := make([]string, 0, len(list.Models))
+	for _, m := range list.Models {
+		name := m.Name
+		if name == "" {
+			name = m.Model
+		}
+		models = append(models, normalizeModel(name))
+	}
+	sort.Strings(models)
+	return models, nil
+}
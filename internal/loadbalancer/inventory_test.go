@@ -0,0 +1,144 @@
+package loadbalancer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newInventoryBackend starts a fake Ollama instance with the given loaded and
+// installed models, and returns its host:port.
+func newInventoryBackend(t *testing.T, name string, loaded, installed []string) string {
+	list := func(models []string) string {
+		entries := make([]string, len(models))
+		for i, model := range models {
+			entries[i] = fmt.Sprintf(`{"name":%q,"model":%q}`, model, model)
+		}
+		return `{"models":[` + strings.Join(entries, ",") + `]}`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/ps":
+			io.WriteString(w, list(loaded))
+		case "/api/tags":
+			io.WriteString(w, list(installed))
+		default:
+			fmt.Fprintf(w, "%s %s", name, r.URL.Path)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+func TestGetServerForModel(t *testing.T) {
+	a := newInventoryBackend(t, "a", nil, []string{"llama3:latest", "mistral:7b"})
+	b := newInventoryBackend(t, "b", []string{"llama3:latest"}, []string{"llama3:latest"})
+	c := newInventoryBackend(t, "c", nil, nil)
+	lb := NewLoadBalancer(context.Background(), []string{a, b, c}, time.Hour, 1)
+
+	// Before the first poll every server is treated alike
+	if _, err := lb.GetServerForModel("llama3"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	lb.RefreshInventory()
+	if inv := lb.Inventory(b); len(inv.Loaded) != 1 || inv.Loaded[0] != "llama3:latest" || inv.UpdatedAt.IsZero() {
+		t.Errorf("Expected b to have llama3:latest loaded, got %+v", inv)
+	}
+
+	// A server with the model loaded is preferred, and names without a tag mean latest
+	for i := 0; i < 3; i++ {
+		if server, _ := lb.GetServerForModel("llama3"); server != b {
+			t.Errorf("Expected the server with llama3 loaded, got %s", server)
+		}
+	}
+
+	// Then a server with the model installed
+	if server, _ := lb.GetServerForModel("mistral:7b"); server != a {
+		t.Errorf("Expected the server with mistral:7b installed, got %s", server)
+	}
+
+	// Then any healthy server
+	lb.healthChecks[b] = false
+	if server, _ := lb.GetServerForModel("llama3"); server != a {
+		t.Errorf("Expected the other server with llama3 installed, got %s", server)
+	}
+	seen := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		server, err := lb.GetServerForModel("phi3")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		seen[server] = true
+	}
+	if len(seen) != 2 {
+		t.Errorf("Expected requests for an unknown model to use every healthy server, got %v", seen)
+	}
+}
+
+func TestRefreshInventoryKeepsPreviousOnError(t *testing.T) {
+	var failing bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		io.WriteString(w, `{"models":[{"name":"llama3:latest"}]}`)
+	}))
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+
+	lb := NewLoadBalancer(context.Background(), []string{addr}, time.Hour, 1)
+	lb.RefreshInventory()
+	failing = true
+	lb.RefreshInventory()
+	if inv := lb.Inventory(addr); len(inv.Available) != 1 {
+		t.Errorf("Expected the previous inventory to be kept, got %+v", inv)
+	}
+}
+
+func TestInventoryPolling(t *testing.T) {
+	addr := newInventoryBackend(t, "a", []string{"llama3:latest"}, nil)
+	options := DefaultOptions()
+	options.HealthCheckInterval = time.Hour
+	options.Inventory.Interval = time.Hour
+	lb := NewLoadBalancerWithOptions(context.Background(), []string{addr}, options)
+	defer lb.Stop()
+
+	// The first poll happens right away
+	for i := 0; i < 100 && lb.Inventory(addr).UpdatedAt.IsZero(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if inv := lb.Inventory(addr); len(inv.Loaded) != 1 {
+		t.Errorf("Expected the inventory to be polled on start, got %+v", inv)
+	}
+}
+
+func TestHandlerRouteByModel(t *testing.T) {
+	a := newInventoryBackend(t, "a", nil, nil)
+	b := newInventoryBackend(t, "b", []string{"llama3:latest"}, nil)
+	lb := NewLoadBalancer(context.Background(), []string{a, b}, time.Hour, 1)
+	lb.RefreshInventory()
+
+	options := DefaultProxyOptions()
+	options.RouteByModel = true
+	proxy := httptest.NewServer(lb.HandlerWithOptions(options))
+	defer proxy.Close()
+
+	for i := 0; i < 3; i++ {
+		res, err := http.Post(proxy.URL+"/api/generate", "application/json", strings.NewReader(`{"model":"llama3","prompt":"hi"}`))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if string(body) != "b /api/generate" {
+			t.Errorf("Expected the request to reach b, got '%s'", body)
+		}
+	}
+}
@@ -1,108 +1,617 @@
-package loadbalancer
-
-import (
-	"fmt"
-	"net/http"
-	"sync"
-	"time"
-)
-
-// LoadBalancer manages a set of servers, routing requests to healthy ones
-type LoadBalancer struct {
-	servers          []string        // List of server URLs
-	currentIndex     int             // Round-robin index
-	healthChecks     map[string]bool // Server health status
-	lock             sync.Mutex      // Mutex for concurrent access
-	healthCheckFreq  time.Duration   // Frequency of health checks
-	failureThreshold int             // Number of consecutive failures before marking a server as unhealthy
-}
-
-// NewLoadBalancer initializes a LoadBalancer with a list of servers and health check settings
-func NewLoadBalancer(servers []string, healthCheckFreq time.Duration, failureThreshold int) *LoadBalancer {
-	lb := &LoadBalancer{
-		servers:          servers,
-		currentIndex:     0,
-		healthChecks:     make(map[string]bool),
-		healthCheckFreq:  healthCheckFreq,
-		failureThreshold: failureThreshold,
-	}
-
-	for _, server := range servers {
-		lb.healthChecks[server] = true // Initialize all servers as healthy
-	}
-
-	go lb.startHealthChecks()
-
-	return lb
-}
-
-// GetHealthyServer returns the next available healthy server in a round-robin fashion
-func (lb *LoadBalancer) GetHealthyServer() (string, error) {
-	lb.lock.Lock()
-	defer lb.lock.Unlock()
-
-	// Try each server in the list once, using round-robin
-	for i := 0; i < len(lb.servers); i++ {
-		server := lb.servers[lb.currentIndex]
-		lb.currentIndex = (lb.currentIndex + 1) % len(lb.servers)
-
-		if lb.healthChecks[server] {
-			return server, nil
-		}
-	}
-
-	return "", fmt.Errorf("no healthy servers available")
-}
-
-// startHealthChecks initiates periodic health checks on all servers
-func (lb *LoadBalancer) startHealthChecks() {
-	ticker := time.NewTicker(lb.healthCheckFreq)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		lb.HealthCheckServers()
-	}
-}
-
-// HealthCheckServers performs concurrent health checks on all servers
-func (lb *LoadBalancer) HealthCheckServers() {
-	var wg sync.WaitGroup
-	wg.Add(len(lb.servers))
-
-	for _, server := range lb.servers {
-		go func(server string) {
-			defer wg.Done()
-			isHealthy := lb.pingServerWithRetries(server, lb.failureThreshold)
-
-			lb.lock.Lock()
-			lb.healthChecks[server] = isHealthy
-			lb.lock.Unlock()
-		}(server)
-	}
-
-	wg.Wait()
-}
-
-// pingServerWithRetries checks server health with retries up to a failure threshold
-func (lb *LoadBalancer) pingServerWithRetries(server string, maxRetries int) bool {
-	for i := 0; i < maxRetries; i++ {
-		if lb.pingServer(server) {
-			return true
-		}
-		time.Sleep(100 * time.Millisecond) // Optional backoff between retries
-	}
-	return false
-}
-
-// pingServer checks if a server is reachable and returns true if healthy
-func (lb *LoadBalancer) pingServer(server string) bool {
-	client := http.Client{
-		Timeout: 2 * time.Second, // Timeout for each ping attempt
-	}
-
-	res, err := client.Get(fmt.Sprintf("http://%s/health", server))
-	if err != nil || res.StatusCode != http.StatusOK {
-		return false
-	}
-	return true
-}
+package loadbalancer
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/h2co32/gollama/internal/metrics"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation name of the default tracer.
+const tracerName = "github.com/h2co32/gollama/internal/loadbalancer"
+
+// Options configures a LoadBalancer.
+type Options struct {
+	// HealthCheckInterval is how often every server is health checked.
+	// Default: 10 seconds
+	HealthCheckInterval time.Duration
+
+	// FailureThreshold is the number of consecutive failed pings before a server is
+	// marked unhealthy.
+	// Default: 3
+	FailureThreshold int
+
+	// Strategy chooses among the healthy servers. Nil means round-robin.
+	// Default: nil
+	Strategy Strategy
+
+	// Weights sets the initial weight of each server, used by the weighted strategies.
+	// Servers not listed have a weight of 1. Weights can be changed with SetWeight.
+	Weights map[string]int
+
+	// MaxConnsPerServer limits the number of requests started with StartRequest that
+	// each server handles at once. Servers at the limit are skipped until a request
+	// finishes. Zero means no limit.
+	// Default: 0
+	MaxConnsPerServer int
+
+	// LatencySmoothing is the weight given to each new latency in the moving average
+	// kept for every server, between 0 and 1. Higher values react faster to changes.
+	// Default: 0.3
+	LatencySmoothing float64
+
+	// HashReplicas is the number of points each unit of a server's weight places on
+	// the hash ring used by GetServerForKey. More points spread keys more evenly at
+	// the cost of memory.
+	// Default: 100
+	HashReplicas int
+
+	// Scheme is the scheme used to reach the servers, for health checks and proxied
+	// requests: "http" or "https".
+	// Default: "http"
+	Scheme string
+
+	// TLSConfig configures connections to servers using https, for example to trust a
+	// private CA. security.NewClientTLSConfig builds one from PEM files. Optional; the
+	// system roots are trusted by default.
+	TLSConfig *tls.Config
+
+	// HealthCheck configures the request sent to check each server.
+	HealthCheck HealthCheckOptions
+
+	// CircuitBreaker configures the ejection of servers that fail too many requests.
+	// Disabled unless ErrorRate is set.
+	CircuitBreaker CircuitBreakerOptions
+
+	// Inventory configures polling of the models each server has loaded and
+	// installed, for GetServerForModel. Disabled unless Interval is set.
+	Inventory InventoryOptions
+
+	// Metrics records server ejections. Optional.
+	Metrics *metrics.MetricsProvider
+
+	// Tracer starts a "loadbalancer.route" span for each attempt to proxy a request,
+	// recording the server chosen, as a child of the span in the request's context.
+	// Default: a tracer from the global OpenTelemetry tracer provider
+	Tracer trace.Tracer
+}
+
+// HealthCheckOptions configures the request a LoadBalancer sends to check whether a
+// server is healthy.
+type HealthCheckOptions struct {
+	// Path is the path requested on each server.
+	// Default: "/health"
+	Path string
+
+	// Method is the HTTP method of the request.
+	// Default: "GET"
+	Method string
+
+	// Headers are added to the request. Optional.
+	Headers http.Header
+
+	// Body is sent with the request, for example a small generation request to check
+	// that the server can load a model. Optional.
+	Body []byte
+
+	// ExpectedStatuses are the response status codes that mean the server is healthy.
+	// Default: [200]
+	ExpectedStatuses []int
+
+	// Timeout bounds each attempt, including reading the response.
+	// Default: 2 seconds
+	Timeout time.Duration
+}
+
+// DefaultHealthCheckOptions returns the default health check options.
+func DefaultHealthCheckOptions() HealthCheckOptions {
+	return HealthCheckOptions{
+		Path:             "/health",
+		Method:           http.MethodGet,
+		ExpectedStatuses: []int{http.StatusOK},
+		Timeout:          2 * time.Second,
+	}
+}
+
+// DefaultOptions returns the default load balancer options.
+func DefaultOptions() Options {
+	return Options{
+		HealthCheckInterval: 10 * time.Second,
+		FailureThreshold:    3,
+		LatencySmoothing:    0.3,
+		HashReplicas:        100,
+		Scheme:              "http",
+		HealthCheck:         DefaultHealthCheckOptions(),
+		CircuitBreaker:      DefaultCircuitBreakerOptions(),
+		Inventory:           DefaultInventoryOptions(),
+		Tracer:              otel.Tracer(tracerName),
+	}
+}
+
+var (
+	// ErrNoHealthyServers is returned when every server is unhealthy.
+	ErrNoHealthyServers = errors.New("no healthy servers available")
+
+	// ErrServersBusy is returned when every healthy server is at its connection limit.
+	ErrServersBusy = errors.New("all healthy servers are at their connection limit")
+)
+
+// LoadBalancer manages a set of servers, routing requests to healthy ones
+type LoadBalancer struct {
+	servers          []string                // List of server URLs
+	currentIndex     int                     // Round-robin index
+	healthChecks     map[string]bool         // Server health status
+	stats            map[string]*serverStats // Weight and load of each server
+	strategy         Strategy                // Nil for round-robin
+	smoothing        float64                 // Weight of new samples in the latency average
+	maxConns         int                     // Concurrent requests per server, or 0 for no limit
+	ring             *hashRing               // Consistent hash ring for GetServerForKey
+	replicas         int                     // Ring points per unit of weight
+	scheme           string                  // Scheme used to reach the servers
+	transport        http.RoundTripper       // Transport used to reach the servers
+	healthCheck      HealthCheckOptions      // Health check request settings
+	client           *http.Client            // Client for health checks
+	breaker          CircuitBreakerOptions   // Ejection of failing servers
+	inventory        map[string]Inventory    // Models on each server, when polled
+	inventoryOptions InventoryOptions        // Inventory polling settings
+	metrics          *metrics.MetricsProvider
+	tracer           trace.Tracer       // Starts a span for each proxied attempt
+	lock             sync.Mutex         // Mutex for concurrent access
+	ctx              context.Context    // Canceled when the load balancer stops
+	cancel           context.CancelFunc // Stops the health checks
+	stopped          chan struct{}      // Closed when the health checks have stopped
+	healthCheckFreq  time.Duration      // Frequency of health checks
+	failureThreshold int                // Number of consecutive failures before marking a server as unhealthy
+}
+
+// serverStats holds the state strategies use to compare servers, and the server's
+// circuit breaker.
+type serverStats struct {
+	weight  int
+	active  int
+	latency time.Duration
+	circuit circuit
+}
+
+// NewLoadBalancer initializes a LoadBalancer with a list of servers and health check settings.
+// Health checks run until ctx is canceled or Stop is called.
+func NewLoadBalancer(ctx context.Context, servers []string, healthCheckFreq time.Duration, failureThreshold int) *LoadBalancer {
+	options := DefaultOptions()
+	options.HealthCheckInterval = healthCheckFreq
+	options.FailureThreshold = failureThreshold
+	return NewLoadBalancerWithOptions(ctx, servers, options)
+}
+
+// NewLoadBalancerWithOptions initializes a LoadBalancer with a list of servers and the
+// given options. Zero values fall back to the defaults. Health checks run until ctx
+// is canceled or Stop is called.
+func NewLoadBalancerWithOptions(ctx context.Context, servers []string, options Options) *LoadBalancer {
+	defaults := DefaultOptions()
+	if options.HealthCheckInterval <= 0 {
+		options.HealthCheckInterval = defaults.HealthCheckInterval
+	}
+	if options.FailureThreshold <= 0 {
+		options.FailureThreshold = defaults.FailureThreshold
+	}
+	if options.LatencySmoothing <= 0 || options.LatencySmoothing > 1 {
+		options.LatencySmoothing = defaults.LatencySmoothing
+	}
+	if options.HashReplicas <= 0 {
+		options.HashReplicas = defaults.HashReplicas
+	}
+	if options.Scheme == "" {
+		options.Scheme = defaults.Scheme
+	}
+	if options.HealthCheck.Path == "" {
+		options.HealthCheck.Path = defaults.HealthCheck.Path
+	}
+	if options.HealthCheck.Method == "" {
+		options.HealthCheck.Method = defaults.HealthCheck.Method
+	}
+	if len(options.HealthCheck.ExpectedStatuses) == 0 {
+		options.HealthCheck.ExpectedStatuses = defaults.HealthCheck.ExpectedStatuses
+	}
+	if options.HealthCheck.Timeout <= 0 {
+		options.HealthCheck.Timeout = defaults.HealthCheck.Timeout
+	}
+
+	if options.Inventory.Timeout <= 0 {
+		options.Inventory.Timeout = defaults.Inventory.Timeout
+	}
+	if options.CircuitBreaker.MinRequests <= 0 {
+		options.CircuitBreaker.MinRequests = defaults.CircuitBreaker.MinRequests
+	}
+	if options.CircuitBreaker.Window <= 0 {
+		options.CircuitBreaker.Window = defaults.CircuitBreaker.Window
+	}
+	if options.CircuitBreaker.EjectionTime <= 0 {
+		options.CircuitBreaker.EjectionTime = defaults.CircuitBreaker.EjectionTime
+	}
+	if options.CircuitBreaker.MaxEjectionTime <= 0 {
+		options.CircuitBreaker.MaxEjectionTime = defaults.CircuitBreaker.MaxEjectionTime
+	}
+	if options.CircuitBreaker.MaxEjectedFraction <= 0 || options.CircuitBreaker.MaxEjectedFraction > 1 {
+		options.CircuitBreaker.MaxEjectedFraction = defaults.CircuitBreaker.MaxEjectedFraction
+	}
+	if options.Tracer == nil {
+		options.Tracer = defaults.Tracer
+	}
+
+	transport := http.DefaultTransport
+	if options.TLSConfig != nil {
+		custom := http.DefaultTransport.(*http.Transport).Clone()
+		custom.TLSClientConfig = options.TLSConfig
+		transport = custom
+	}
+
+	lb := &LoadBalancer{
+		servers:          servers,
+		currentIndex:     0,
+		healthChecks:     make(map[string]bool),
+		stats:            make(map[string]*serverStats),
+		strategy:         options.Strategy,
+		smoothing:        options.LatencySmoothing,
+		maxConns:         options.MaxConnsPerServer,
+		replicas:         options.HashReplicas,
+		scheme:           options.Scheme,
+		transport:        transport,
+		healthCheck:      options.HealthCheck,
+		breaker:          options.CircuitBreaker,
+		inventory:        make(map[string]Inventory),
+		inventoryOptions: options.Inventory,
+		metrics:          options.Metrics,
+		tracer:           options.Tracer,
+		client:           &http.Client{Transport: transport, Timeout: options.HealthCheck.Timeout},
+		healthCheckFreq:  options.HealthCheckInterval,
+		failureThreshold: options.FailureThreshold,
+		stopped:          make(chan struct{}),
+	}
+	lb.ctx, lb.cancel = context.WithCancel(ctx)
+
+	for _, server := range servers {
+		lb.healthChecks[server] = true // Initialize all servers as healthy
+		lb.stats[server] = &serverStats{weight: 1}
+		if weight := options.Weights[server]; weight > 0 {
+			lb.stats[server].weight = weight
+		}
+	}
+	lb.buildRing()
+
+	go lb.startHealthChecks()
+
+	return lb
+}
+
+// GetHealthyServer returns the next available healthy server, chosen by the
+// configured strategy or in a round-robin fashion if there is none
+func (lb *LoadBalancer) GetHealthyServer() (string, error) {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	return lb.next(nil)
+}
+
+// next returns the next healthy server that is not excluded and is below its
+// connection limit.
+// This method is not thread-safe and should be called with the lock held.
+func (lb *LoadBalancer) next(exclude map[string]bool) (string, error) {
+	sel := &selection{lb: lb, exclude: exclude}
+
+	if lb.strategy == nil {
+		// Try each server in the list once, using round-robin
+		for i := 0; i < len(lb.servers); i++ {
+			server := lb.servers[lb.currentIndex]
+			lb.currentIndex = (lb.currentIndex + 1) % len(lb.servers)
+
+			if sel.available(server) {
+				lb.chose(server)
+				return server, nil
+			}
+		}
+		return "", sel.err()
+	}
+
+	backends := make([]Backend, 0, len(lb.servers))
+	for _, server := range lb.servers {
+		if !sel.available(server) {
+			continue
+		}
+		stats := lb.stats[server]
+		backends = append(backends, Backend{
+			Server:         server,
+			Weight:         stats.weight,
+			ActiveRequests: stats.active,
+			Latency:        stats.latency,
+		})
+	}
+	if len(backends) == 0 {
+		return "", sel.err()
+	}
+
+	i := lb.strategy.Next(backends)
+	if i < 0 || i >= len(backends) {
+		return "", fmt.Errorf("strategy chose invalid server index %d", i)
+	}
+	lb.chose(backends[i].Server)
+	return backends[i].Server, nil
+}
+
+// selection checks which servers can take a request while one is being chosen, and
+// remembers why servers were passed over.
+type selection struct {
+	lb      *LoadBalancer
+	exclude map[string]bool
+	busy    bool
+}
+
+// available reports whether server is healthy, not excluded, below its connection
+// limit, and not ejected by its circuit breaker.
+func (s *selection) available(server string) bool {
+	if !s.lb.healthChecks[server] || s.exclude[server] {
+		return false
+	}
+	if s.lb.maxConns > 0 && s.lb.stats[server].active >= s.lb.maxConns {
+		s.busy = true
+		return false
+	}
+	return s.lb.circuitAllows(server, time.Now())
+}
+
+// err returns the error explaining why no server was available.
+func (s *selection) err() error {
+	if s.busy {
+		return ErrServersBusy
+	}
+	return ErrNoHealthyServers
+}
+
+// SetWeight changes the weight of a server, used by the weighted strategies and the
+// hash ring. The weight must be positive.
+func (lb *LoadBalancer) SetWeight(server string, weight int) error {
+	if weight <= 0 {
+		return fmt.Errorf("weight must be positive, got %d", weight)
+	}
+
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+
+	stats, ok := lb.stats[server]
+	if !ok {
+		return fmt.Errorf("unknown server: %s", server)
+	}
+	stats.weight = weight
+	lb.buildRing()
+	return nil
+}
+
+// ServerStatus is the state of a server, as reported by Status.
+type ServerStatus struct {
+	Server  string
+	Healthy bool // Whether the server passes its health checks
+
+	// Ejected reports whether the circuit breaker has ejected the server, including
+	// while it waits for a probe request to readmit it. EjectedUntil is the end of the
+	// current ejection, or zero once it has passed.
+	Ejected      bool
+	EjectedUntil time.Time
+
+	Weight    int
+	Active    int           // Requests in flight
+	Latency   time.Duration // Moving average of recent request latencies
+	Inventory Inventory     // Models the server had when it was last polled
+}
+
+// Status returns the state of each server, in the order the servers were given.
+func (lb *LoadBalancer) Status() []ServerStatus {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+
+	now := time.Now()
+	statuses := make([]ServerStatus, len(lb.servers))
+	for i, server := range lb.servers {
+		stats := lb.stats[server]
+		statuses[i] = ServerStatus{
+			Server:    server,
+			Healthy:   lb.healthChecks[server],
+			Ejected:   stats.circuit.state != circuitClosed,
+			Weight:    stats.weight,
+			Active:    stats.active,
+			Latency:   stats.latency,
+			Inventory: lb.inventory[server],
+		}
+		if stats.circuit.state == circuitOpen && now.Before(stats.circuit.until) {
+			statuses[i].EjectedUntil = stats.circuit.until
+		}
+	}
+	return statuses
+}
+
+// StartRequest records that a request to server has started, for connection limits
+// and the strategies that prefer less loaded servers. The returned function must be
+// called when the request finishes.
+func (lb *LoadBalancer) StartRequest(server string) (done func()) {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	return lb.start(server)
+}
+
+// acquire chooses a server as GetServerForKey does if key is not empty, as
+// GetServerForModel does if model is not empty, and otherwise as GetHealthyServer
+// does, skipping the excluded ones. It starts a request to the server in the same
+// critical section so connection limits hold.
+func (lb *LoadBalancer) acquire(key, model string, exclude map[string]bool) (server string, done func(), err error) {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+
+	switch {
+	case key != "":
+		server, err = lb.nextForKey(key, exclude)
+	case model != "":
+		server, err = lb.nextForModel(model, exclude)
+	default:
+		server, err = lb.next(exclude)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return server, lb.start(server), nil
+}
+
+// start increments the active requests of server and returns the function that
+// decrements them.
+// This method is not thread-safe and should be called with the lock held.
+func (lb *LoadBalancer) start(server string) func() {
+	stats, ok := lb.stats[server]
+	if !ok {
+		return func() {}
+	}
+	stats.active++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			lb.lock.Lock()
+			stats.active--
+			lb.lock.Unlock()
+		})
+	}
+}
+
+// ObserveLatency adds a request latency to the moving average kept for server, for
+// the strategies that prefer faster servers. For streamed responses the time to the
+// response headers is a better measure of the server's speed than the full duration.
+func (lb *LoadBalancer) ObserveLatency(server string, latency time.Duration) {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+
+	stats, ok := lb.stats[server]
+	if !ok {
+		return
+	}
+	if stats.latency == 0 {
+		stats.latency = latency
+		return
+	}
+	stats.latency = time.Duration(lb.smoothing*float64(latency) + (1-lb.smoothing)*float64(stats.latency))
+}
+
+// Stop stops the health checks, canceling any in progress, and waits for them to
+// finish. Servers keep the health they had when it was called. Stop is safe to call
+// more than once.
+func (lb *LoadBalancer) Stop() {
+	lb.cancel()
+	<-lb.stopped
+}
+
+// Close stops the load balancer like Stop. It implements io.Closer and always
+// returns nil.
+func (lb *LoadBalancer) Close() error {
+	lb.Stop()
+	return nil
+}
+
+// Done returns a channel that is closed once the health checks have stopped, either
+// through Stop or because the context passed to the constructor was canceled.
+func (lb *LoadBalancer) Done() <-chan struct{} {
+	return lb.stopped
+}
+
+// startHealthChecks initiates periodic health checks on all servers, and inventory
+// polling if enabled, until the load balancer is stopped
+func (lb *LoadBalancer) startHealthChecks() {
+	defer close(lb.stopped)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	if lb.inventoryOptions.Interval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lb.pollInventory()
+		}()
+	}
+
+	ticker := time.NewTicker(lb.healthCheckFreq)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-lb.ctx.Done():
+			return
+		case <-ticker.C:
+			lb.HealthCheckServers()
+		}
+	}
+}
+
+// HealthCheckServers performs concurrent health checks on all servers
+func (lb *LoadBalancer) HealthCheckServers() {
+	var wg sync.WaitGroup
+	wg.Add(len(lb.servers))
+
+	for _, server := range lb.servers {
+		go func() {
+			defer wg.Done()
+			isHealthy := lb.pingServerWithRetries(server, lb.failureThreshold)
+			if lb.ctx.Err() != nil {
+				return // Checks canceled by Stop say nothing about the server
+			}
+
+			lb.lock.Lock()
+			lb.healthChecks[server] = isHealthy
+			lb.lock.Unlock()
+		}()
+	}
+
+	wg.Wait()
+}
+
+// pingServerWithRetries checks server health with retries up to a failure threshold
+func (lb *LoadBalancer) pingServerWithRetries(server string, maxRetries int) bool {
+	for i := 0; i < maxRetries; i++ {
+		if lb.pingServer(server) {
+			return true
+		}
+		// Optional backoff between retries
+		select {
+		case <-lb.ctx.Done():
+			return false
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	return false
+}
+
+// pingServer checks if a server is reachable and returns true if healthy
+func (lb *LoadBalancer) pingServer(server string) bool {
+	var body io.Reader
+	if lb.healthCheck.Body != nil {
+		body = bytes.NewReader(lb.healthCheck.Body)
+	}
+	req, err := http.NewRequestWithContext(lb.ctx, lb.healthCheck.Method, fmt.Sprintf("%s://%s%s", lb.scheme, server, lb.healthCheck.Path), body)
+	if err != nil {
+		return false
+	}
+	for key, values := range lb.healthCheck.Headers {
+		req.Header[key] = values
+	}
+
+	res, err := lb.client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer res.Body.Close()
+	// Drain the body so the connection can be reused by the next check
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	return slices.Contains(lb.healthCheck.ExpectedStatuses, res.StatusCode)
+}
@@ -1,8 +1,14 @@
 package loadbalancer
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -12,7 +18,7 @@ func TestNewLoadBalancer(t *testing.T) {
 	healthCheckFreq := 5 * time.Second
 	failureThreshold := 3
 
-	lb := NewLoadBalancer(servers, healthCheckFreq, failureThreshold)
+	lb := NewLoadBalancer(context.Background(), servers, healthCheckFreq, failureThreshold)
 
 	if lb == nil {
 		t.Fatal("Expected NewLoadBalancer to return a non-nil value")
@@ -50,7 +56,7 @@ func TestNewLoadBalancer(t *testing.T) {
 
 func TestGetHealthyServer(t *testing.T) {
 	servers := []string{"server1:8080", "server2:8080", "server3:8080"}
-	lb := NewLoadBalancer(servers, 5*time.Second, 3)
+	lb := NewLoadBalancer(context.Background(), servers, 5*time.Second, 3)
 
 	// Test round-robin behavior with all servers healthy
 	for i := 0; i < len(servers)*2; i++ {
@@ -113,7 +119,7 @@ func TestHealthCheckServers(t *testing.T) {
 
 	// Create a load balancer with the test servers
 	servers := []string{healthyServerAddr, unhealthyServerAddr}
-	lb := NewLoadBalancer(servers, 5*time.Second, 1)
+	lb := NewLoadBalancer(context.Background(), servers, 5*time.Second, 1)
 
 	// Run health checks
 	lb.HealthCheckServers()
@@ -153,7 +159,7 @@ func TestPingServer(t *testing.T) {
 	unhealthyServerAddr := unhealthyServer.URL[7:]
 
 	// Create a load balancer
-	lb := NewLoadBalancer([]string{healthyServerAddr, unhealthyServerAddr}, 5*time.Second, 3)
+	lb := NewLoadBalancer(context.Background(), []string{healthyServerAddr, unhealthyServerAddr}, 5*time.Second, 3)
 
 	// Test pingServer with healthy server
 	result := lb.pingServer(healthyServerAddr)
@@ -174,6 +180,87 @@ func TestPingServer(t *testing.T) {
 	}
 }
 
+// TestPingServerCustomHealthCheck tests a health check with a custom request and expected statuses
+func TestPingServerCustomHealthCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || r.URL.Path != "/api/show" || r.Header.Get("X-Probe") != "1" || string(body) != `{"model":"llama3"}` {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	serverAddr := strings.TrimPrefix(server.URL, "http://")
+
+	options := DefaultOptions()
+	options.HealthCheck = HealthCheckOptions{
+		Path:             "/api/show",
+		Method:           http.MethodPost,
+		Headers:          http.Header{"X-Probe": []string{"1"}},
+		Body:             []byte(`{"model":"llama3"}`),
+		ExpectedStatuses: []int{http.StatusOK, http.StatusNoContent},
+	}
+	lb := NewLoadBalancerWithOptions(context.Background(), []string{serverAddr}, options)
+	if !lb.pingServer(serverAddr) {
+		t.Error("Expected pingServer to return true for the custom health check")
+	}
+
+	// The default health check expects 200 from /health
+	lb = NewLoadBalancer(context.Background(), []string{serverAddr}, 5*time.Second, 1)
+	if lb.pingServer(serverAddr) {
+		t.Error("Expected pingServer to return false for the default health check")
+	}
+}
+
+// TestPingServerTimeout tests that slow servers fail the health check
+func TestPingServerTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+	serverAddr := strings.TrimPrefix(server.URL, "http://")
+
+	options := DefaultOptions()
+	options.HealthCheck.Timeout = 50 * time.Millisecond
+	lb := NewLoadBalancerWithOptions(context.Background(), []string{serverAddr}, options)
+	if lb.pingServer(serverAddr) {
+		t.Error("Expected pingServer to return false when the server is slower than the timeout")
+	}
+}
+
+// TestPingServerHTTPS tests health checks against a server with a private CA
+func TestPingServerHTTPS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	serverAddr := strings.TrimPrefix(server.URL, "https://")
+
+	// Without the CA the certificate is not trusted
+	options := DefaultOptions()
+	options.Scheme = "https"
+	lb := NewLoadBalancerWithOptions(context.Background(), []string{serverAddr}, options)
+	if lb.pingServer(serverAddr) {
+		t.Error("Expected pingServer to return false for an untrusted certificate")
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	options.TLSConfig = &tls.Config{RootCAs: pool}
+	lb = NewLoadBalancerWithOptions(context.Background(), []string{serverAddr}, options)
+	if !lb.pingServer(serverAddr) {
+		t.Error("Expected pingServer to return true with the server's CA trusted")
+	}
+
+	// Proxied requests use the same scheme and TLS configuration
+	rec := httptest.NewRecorder()
+	lb.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/tags", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 from the proxy, got %d", rec.Code)
+	}
+}
+
 // TestPingServerWithRetries tests the pingServerWithRetries method
 func TestPingServerWithRetries(t *testing.T) {
 	// Create a test server that fails the first two requests then succeeds
@@ -196,7 +283,7 @@ func TestPingServerWithRetries(t *testing.T) {
 	serverAddr := server.URL[7:] // Remove "http://"
 
 	// Create a load balancer
-	lb := NewLoadBalancer([]string{serverAddr}, 5*time.Second, 3)
+	lb := NewLoadBalancer(context.Background(), []string{serverAddr}, 5*time.Second, 3)
 
 	// Test with max retries = 1 (should fail)
 	requestCount = 0
@@ -219,7 +306,7 @@ func TestPingServerWithRetries(t *testing.T) {
 // TestConcurrentAccess tests that the load balancer handles concurrent access correctly
 func TestConcurrentAccess(t *testing.T) {
 	servers := []string{"server1:8080", "server2:8080", "server3:8080"}
-	lb := NewLoadBalancer(servers, 5*time.Second, 3)
+	lb := NewLoadBalancer(context.Background(), servers, 5*time.Second, 3)
 
 	// Run multiple goroutines to access the load balancer concurrently
 	const numGoroutines = 10
@@ -249,3 +336,80 @@ func TestConcurrentAccess(t *testing.T) {
 
 	// If we got here without panicking, the test passes
 }
+
+// TestStop tests that Stop ends the health checks and is safe to call twice
+func TestStop(t *testing.T) {
+	var pings atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pings.Add(1)
+	}))
+	defer server.Close()
+
+	lb := NewLoadBalancer(context.Background(), []string{strings.TrimPrefix(server.URL, "http://")}, 10*time.Millisecond, 1)
+	time.Sleep(50 * time.Millisecond)
+	lb.Stop()
+	lb.Stop()
+
+	select {
+	case <-lb.Done():
+	default:
+		t.Fatal("Expected Done to be closed after Stop")
+	}
+
+	// A canceled ping may still reach the server just after Stop returns
+	time.Sleep(20 * time.Millisecond)
+	count := pings.Load()
+	time.Sleep(50 * time.Millisecond)
+	if pings.Load() != count {
+		t.Errorf("Expected no health checks after Stop, got %d more", pings.Load()-count)
+	}
+}
+
+// TestContextCancelStopsHealthChecks tests that canceling the parent context stops the health checks
+func TestContextCancelStopsHealthChecks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	lb := NewLoadBalancer(ctx, []string{"server1:8080"}, time.Hour, 1)
+	cancel()
+
+	select {
+	case <-lb.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expected health checks to stop when the context is canceled")
+	}
+
+	// Canceled checks leave the server's health unchanged
+	lb.HealthCheckServers()
+	if !lb.healthChecks["server1:8080"] {
+		t.Error("Expected server1:8080 to stay healthy after a canceled check")
+	}
+	if err := lb.Close(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	servers := []string{"server1:8080", "server2:8080"}
+	lb := NewLoadBalancerWithOptions(context.Background(), servers, Options{
+		HealthCheckInterval: time.Hour,
+		Weights:             map[string]int{"server2:8080": 3},
+	})
+	defer lb.Close()
+
+	done := lb.StartRequest("server1:8080")
+	defer done()
+	lb.lock.Lock()
+	lb.healthChecks["server2:8080"] = false
+	lb.eject("server2:8080", time.Now())
+	lb.lock.Unlock()
+
+	statuses := lb.Status()
+	if len(statuses) != 2 || statuses[0].Server != "server1:8080" {
+		t.Fatalf("Expected the status of each server in order, got %+v", statuses)
+	}
+	if s := statuses[0]; !s.Healthy || s.Ejected || s.Active != 1 || s.Weight != 1 {
+		t.Errorf("Unexpected status %+v", s)
+	}
+	if s := statuses[1]; s.Healthy || !s.Ejected || s.EjectedUntil.IsZero() || s.Weight != 3 {
+		t.Errorf("Expected server2 to be unhealthy and ejected, got %+v", s)
+	}
+}
@@ -0,0 +1,272 @@
+package loadbalancer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"time"
+
+	"github.com/h2co32/gollama/pkg/middleware"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ProxyOptions configures the handler returned by HandlerWithOptions.
+type ProxyOptions struct {
+	// MaxRetries is how many other servers an idempotent request is retried on when a
+	// server cannot be reached or fails before sending response headers. Requests with
+	// other methods, such as POST, are never retried since the server may have acted
+	// on them. A negative value disables retries.
+	// Default: 2
+	MaxRetries int
+
+	// MaxRetryBodyBytes is the largest request body buffered so the request can be
+	// retried. Requests with larger bodies are sent once.
+	// Default: 1 MiB
+	MaxRetryBodyBytes int64
+
+	// AffinityKey returns the key used to route a request with GetServerForKey, for
+	// example a session or conversation ID header, so related requests reach the same
+	// server. Requests for which it returns an empty string, and all requests if it is
+	// nil, are routed with the load balancer's strategy. Optional.
+	AffinityKey func(r *http.Request) string
+
+	// RouteByModel routes requests whose JSON body names a model, such as Ollama's
+	// /api/generate and /api/chat, with GetServerForModel, so they reach a server that
+	// already has the model loaded. Bodies larger than MaxRetryBodyBytes are routed
+	// with the strategy. AffinityKey takes precedence.
+	// Default: false
+	RouteByModel bool
+
+	// Transport sends the proxied requests.
+	// Default: the load balancer's transport, which uses Options.TLSConfig
+	Transport http.RoundTripper
+
+	// ErrorHandler writes the response when no server could handle a request. err
+	// wraps ErrNoHealthyServers or ErrServersBusy if no server was available.
+	// Default: a JSON error with status 503 if no server was available and 502 otherwise,
+	// or the error of middleware.WriteLimitError if the request exceeded its body limit
+	// or deadline
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// DefaultProxyOptions returns the default proxy options.
+func DefaultProxyOptions() ProxyOptions {
+	return ProxyOptions{
+		MaxRetries:        2,
+		MaxRetryBodyBytes: 1 << 20,
+		ErrorHandler:      proxyError,
+	}
+}
+
+// Handler returns an http.Handler that proxies each request to a healthy server with
+// the default proxy options.
+func (lb *LoadBalancer) Handler() http.Handler {
+	return lb.HandlerWithOptions(DefaultProxyOptions())
+}
+
+// HandlerWithOptions returns an http.Handler that proxies each request to a healthy
+// server. Requests are counted against the server's connection limit and load until
+// the response body has been copied, the time to the response headers is reported
+// with ObserveLatency, and connection errors and 5xx responses are reported to the
+// circuit breaker with ObserveResult. Responses are flushed as they arrive, so streamed
+// tokens and server-sent events reach the client as they are generated.
+// Zero option values fall back to the defaults.
+func (lb *LoadBalancer) HandlerWithOptions(options ProxyOptions) http.Handler {
+	defaults := DefaultProxyOptions()
+	if options.MaxRetries < 0 {
+		options.MaxRetries = 0
+	} else if options.MaxRetries == 0 {
+		options.MaxRetries = defaults.MaxRetries
+	}
+	if options.MaxRetryBodyBytes <= 0 {
+		options.MaxRetryBodyBytes = defaults.MaxRetryBodyBytes
+	}
+	if options.Transport == nil {
+		options.Transport = lb.transport
+	}
+	if options.ErrorHandler == nil {
+		options.ErrorHandler = defaults.ErrorHandler
+	}
+
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			// The server is chosen by the transport, which may try several
+			pr.Out.URL.Scheme = lb.scheme
+			pr.Out.Host = ""
+			pr.SetXForwarded()
+		},
+		Transport:     &proxyTransport{lb: lb, options: options},
+		FlushInterval: -1,
+		ErrorHandler:  options.ErrorHandler,
+	}
+}
+
+// proxyTransport sends each request to a server chosen by the load balancer, moving
+// on to the next server when a retryable request fails.
+type proxyTransport struct {
+	lb      *LoadBalancer
+	options ProxyOptions
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *proxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	retries := 0
+	if isIdempotent(req.Method) {
+		retries = t.options.MaxRetries
+	}
+	var body []byte
+	if (retries > 0 || t.options.RouteByModel) && req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if body, err = t.bufferBody(req); err != nil {
+			return nil, err
+		}
+		if body == nil {
+			retries = 0
+		}
+	}
+
+	key, model := "", ""
+	if t.options.AffinityKey != nil {
+		key = t.options.AffinityKey(req)
+	}
+	if t.options.RouteByModel && body != nil {
+		model = requestModel(body)
+	}
+
+	tried := make(map[string]bool)
+	var lastErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		ctx, span := t.lb.tracer.Start(req.Context(), "loadbalancer.route", trace.WithAttributes(
+			attribute.Int("loadbalancer.attempt", attempt+1),
+		))
+		if model != "" {
+			span.SetAttributes(attribute.String("model.name", model))
+		}
+
+		server, done, err := t.lb.acquire(key, model, tried)
+		if err != nil {
+			endSpan(span, err)
+			if lastErr != nil {
+				return nil, lastErr
+			}
+			return nil, err
+		}
+		tried[server] = true
+		span.SetAttributes(attribute.String("server.address", server))
+
+		out := req.Clone(ctx)
+		out.URL.Host = server
+		if body != nil {
+			out.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		start := time.Now()
+		res, err := t.options.Transport.RoundTrip(out)
+		if err != nil {
+			done()
+			t.lb.ObserveResult(server, false)
+			lastErr = fmt.Errorf("backend %s failed: %w", server, err)
+			endSpan(span, lastErr)
+			if req.Context().Err() != nil {
+				break
+			}
+			continue
+		}
+
+		t.lb.ObserveLatency(server, time.Since(start))
+		t.lb.ObserveResult(server, res.StatusCode < http.StatusInternalServerError)
+		span.SetAttributes(attribute.Int("http.response.status_code", res.StatusCode))
+		if res.StatusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(res.StatusCode))
+		}
+		// The span covers the whole response, including streamed bodies
+		res.Body = &releasingBody{ReadCloser: res.Body, done: func() {
+			done()
+			span.End()
+		}}
+		return res, nil
+	}
+	return nil, lastErr
+}
+
+// bufferBody reads the request body so it can be sent more than once. If the body is
+// larger than MaxRetryBodyBytes, it is left readable in full on req and nil is returned.
+func (t *proxyTransport) bufferBody(req *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(req.Body, t.options.MaxRetryBodyBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if int64(len(body)) > t.options.MaxRetryBodyBytes {
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+		return nil, nil
+	}
+	req.Body.Close()
+	return body, nil
+}
+
+// requestModel returns the model named in a JSON request body, or an empty string if
+// there is none.
+func requestModel(body []byte) string {
+	var request struct {
+		Model string `json:"model"`
+		Name  string `json:"name"` // Used by older Ollama versions
+	}
+	if json.Unmarshal(body, &request) != nil {
+		return ""
+	}
+	if request.Model != "" {
+		return request.Model
+	}
+	return request.Name
+}
+
+// releasingBody ends the request to a server when the response body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	done func()
+}
+
+// Close closes the body and ends the request.
+func (b *releasingBody) Close() error {
+	defer b.done()
+	return b.ReadCloser.Close()
+}
+
+// endSpan records err on span and ends it.
+func endSpan(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	span.End()
+}
+
+// isIdempotent reports whether requests with the given method can safely be sent
+// more than once.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// proxyError is the default ProxyOptions.ErrorHandler.
+func proxyError(w http.ResponseWriter, r *http.Request, err error) {
+	if middleware.WriteLimitError(w, err) {
+		return
+	}
+	status := http.StatusBadGateway
+	if errors.Is(err, ErrNoHealthyServers) || errors.Is(err, ErrServersBusy) {
+		status = http.StatusServiceUnavailable
+	}
+	middleware.JSONResponse(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,208 @@
+package loadbalancer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newProxyBackend starts a backend that replies with its name and the request body.
+func newProxyBackend(t *testing.T, name string) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s %s", name, r.URL.Path, body)
+	}))
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+// deadBackend returns the address of a server that is no longer listening.
+func deadBackend() string {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+func TestHandlerProxies(t *testing.T) {
+	lb := NewLoadBalancer(context.Background(), []string{newProxyBackend(t, "a"), newProxyBackend(t, "b")}, time.Hour, 1)
+	proxy := httptest.NewServer(lb.Handler())
+	defer proxy.Close()
+
+	for _, expected := range []string{"a /api/generate {}", "b /api/generate {}"} {
+		res, err := http.Post(proxy.URL+"/api/generate", "application/json", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if string(body) != expected {
+			t.Errorf("Expected body '%s', got '%s'", expected, body)
+		}
+	}
+}
+
+func TestHandlerRetriesIdempotentRequests(t *testing.T) {
+	lb := NewLoadBalancer(context.Background(), []string{deadBackend(), newProxyBackend(t, "b")}, time.Hour, 1)
+	proxy := httptest.NewServer(lb.Handler())
+	defer proxy.Close()
+
+	// The DELETE fails on the first server and is retried on the second
+	req, _ := http.NewRequest(http.MethodDelete, proxy.URL+"/api/delete", strings.NewReader(`{"model":"llama3"}`))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || string(body) != `b /api/delete {"model":"llama3"}` {
+		t.Errorf("Expected the request to be retried on b, got status %d and body '%s'", res.StatusCode, body)
+	}
+
+	// Round-robin sends the POST to the first server, and it is not retried
+	res, err = http.Post(proxy.URL+"/api/generate", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected status 502, got %d", res.StatusCode)
+	}
+}
+
+func TestHandlerTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	options := DefaultOptions()
+	options.HealthCheckInterval = time.Hour
+	options.Tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+	dead, live := deadBackend(), newProxyBackend(t, "b")
+	lb := NewLoadBalancerWithOptions(context.Background(), []string{dead, live}, options)
+
+	// The request fails on the first server and is retried on the second, each in a
+	// span of its own under the request's
+	ctx, parent := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "request")
+	req := httptest.NewRequest(http.MethodGet, "/api/tags", nil).WithContext(ctx)
+	res := httptest.NewRecorder()
+	lb.Handler().ServeHTTP(res, req)
+	parent.End()
+	if res.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", res.Code)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	for i, server := range []string{dead, live} {
+		span := spans[i]
+		if span.Name() != "loadbalancer.route" || span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("Expected span %d to be a loadbalancer.route child of the request, got %s", i, span.Name())
+		}
+		for _, attr := range span.Attributes() {
+			if attr.Key == "server.address" && attr.Value.AsString() != server {
+				t.Errorf("Expected span %d to route to %s, got %s", i, server, attr.Value.AsString())
+			}
+		}
+	}
+	if spans[0].Status().Code != codes.Error || spans[1].Status().Code == codes.Error {
+		t.Error("Expected only the attempt on the dead server to fail")
+	}
+}
+
+func TestHandlerNoHealthyServers(t *testing.T) {
+	lb := NewLoadBalancer(context.Background(), []string{"server1:8080"}, time.Hour, 1)
+	lb.healthChecks["server1:8080"] = false
+
+	rec := httptest.NewRecorder()
+	lb.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/tags", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", rec.Code)
+	}
+}
+
+func TestHandlerConnectionLimit(t *testing.T) {
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer backend.Close()
+
+	lb := NewLoadBalancerWithOptions(context.Background(), []string{strings.TrimPrefix(backend.URL, "http://")}, Options{
+		HealthCheckInterval: time.Hour,
+		MaxConnsPerServer:   1,
+	})
+	proxy := httptest.NewServer(lb.Handler())
+	defer proxy.Close()
+	defer close(release) // Before the servers wait for the blocked request
+
+	go http.Get(proxy.URL + "/api/generate")
+	for i := 0; i < 100; i++ {
+		if _, err := lb.GetHealthyServer(); err != nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	res, err := http.Get(proxy.URL + "/api/generate")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 while the server is at its limit, got %d", res.StatusCode)
+	}
+}
+
+func TestHandlerStreams(t *testing.T) {
+	next := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 2; i++ {
+			fmt.Fprintf(w, "data: token%d\n\n", i)
+			w.(http.Flusher).Flush()
+			<-next
+		}
+	}))
+	defer backend.Close()
+
+	server := strings.TrimPrefix(backend.URL, "http://")
+	lb := NewLoadBalancer(context.Background(), []string{server}, time.Hour, 1)
+	proxy := httptest.NewServer(lb.Handler())
+	defer proxy.Close()
+
+	res, err := http.Get(proxy.URL + "/api/generate")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer res.Body.Close()
+
+	// Each event arrives before the backend writes the next one
+	reader := bufio.NewReader(res.Body)
+	for i := 0; i < 2; i++ {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if expected := fmt.Sprintf("data: token%d\n", i); line != expected {
+			t.Errorf("Expected '%s', got '%s'", expected, line)
+		}
+		reader.ReadString('\n')
+
+		lb.lock.Lock()
+		active := lb.stats[server].active
+		lb.lock.Unlock()
+		if active != 1 {
+			t.Errorf("Expected 1 active request while streaming, got %d", active)
+		}
+		next <- struct{}{}
+	}
+}
@@ -0,0 +1,175 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Backend describes a healthy server offered to a Strategy.
+type Backend struct {
+	// Server is the server's host:port.
+	Server string
+
+	// Weight is the server's relative capacity, set with Options.Weights or SetWeight.
+	Weight int
+
+	// ActiveRequests is the number of requests started with StartRequest that have
+	// not finished.
+	ActiveRequests int
+
+	// Latency is the moving average of the latencies reported with ObserveLatency,
+	// or zero if none have been reported.
+	Latency time.Duration
+}
+
+// Strategy chooses the server for the next request. Next is given the healthy servers,
+// in the order they were passed to the load balancer, and returns the index of the
+// chosen one. It is called with the load balancer's lock held, so implementations may
+// keep state without locking of their own.
+type Strategy interface {
+	Next(backends []Backend) int
+}
+
+// Strategy names accepted by ParseStrategy.
+const (
+	StrategyRoundRobin         = "round-robin"
+	StrategyWeightedRoundRobin = "weighted-round-robin"
+	StrategyLeastConnections   = "least-connections"
+	StrategyLowestLatency      = "lowest-latency"
+	StrategyTwoChoices         = "two-choices"
+)
+
+// ParseStrategy returns the built-in strategy with the given name. Round-robin is the
+// load balancer's default and is returned as nil.
+func ParseStrategy(name string) (Strategy, error) {
+	switch name {
+	case "", StrategyRoundRobin:
+		return nil, nil
+	case StrategyWeightedRoundRobin:
+		return NewWeightedRoundRobin(), nil
+	case StrategyLeastConnections:
+		return NewLeastConnections(), nil
+	case StrategyLowestLatency:
+		return NewLowestLatency(), nil
+	case StrategyTwoChoices:
+		return NewTwoChoices(), nil
+	default:
+		return nil, fmt.Errorf("unknown load balancing strategy: %s", name)
+	}
+}
+
+// WeightedRoundRobin cycles through the servers, choosing each in proportion to its
+// weight. It uses the smooth algorithm from nginx, which interleaves the choices
+// rather than sending a heavy server several requests in a row.
+type WeightedRoundRobin struct {
+	current map[string]int
+}
+
+// NewWeightedRoundRobin creates a WeightedRoundRobin strategy.
+func NewWeightedRoundRobin() *WeightedRoundRobin {
+	return &WeightedRoundRobin{current: make(map[string]int)}
+}
+
+// Next implements Strategy.
+func (s *WeightedRoundRobin) Next(backends []Backend) int {
+	best, total := 0, 0
+	for i, backend := range backends {
+		s.current[backend.Server] += backend.Weight
+		total += backend.Weight
+		if s.current[backend.Server] > s.current[backends[best].Server] {
+			best = i
+		}
+	}
+	s.current[backends[best].Server] -= total
+	return best
+}
+
+// LeastConnections chooses the server with the fewest active requests relative to
+// its weight. Ties go to the servers in turn so idle servers share the load.
+type LeastConnections struct {
+	offset int
+}
+
+// NewLeastConnections creates a LeastConnections strategy.
+func NewLeastConnections() *LeastConnections {
+	return &LeastConnections{}
+}
+
+// Next implements Strategy.
+func (s *LeastConnections) Next(backends []Backend) int {
+	s.offset++
+	best := s.offset % len(backends)
+	for n := 1; n < len(backends); n++ {
+		i := (s.offset + n) % len(backends)
+		if lessLoaded(backends[i], backends[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+// LowestLatency chooses the server with the lowest average latency relative to its
+// weight. Servers without a latency yet are chosen first so every server is measured.
+type LowestLatency struct {
+	offset int
+}
+
+// NewLowestLatency creates a LowestLatency strategy.
+func NewLowestLatency() *LowestLatency {
+	return &LowestLatency{}
+}
+
+// Next implements Strategy.
+func (s *LowestLatency) Next(backends []Backend) int {
+	s.offset++
+	best := s.offset % len(backends)
+	for n := 1; n < len(backends); n++ {
+		i := (s.offset + n) % len(backends)
+		if fasterThan(backends[i], backends[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+// TwoChoices picks two servers at random and chooses the one with fewer active
+// requests relative to its weight. It spreads load nearly as well as LeastConnections
+// while avoiding every load balancer instance sending its next request to the same
+// idle server.
+type TwoChoices struct{}
+
+// NewTwoChoices creates a TwoChoices strategy.
+func NewTwoChoices() *TwoChoices {
+	return &TwoChoices{}
+}
+
+// Next implements Strategy.
+func (s *TwoChoices) Next(backends []Backend) int {
+	if len(backends) == 1 {
+		return 0
+	}
+	a := rand.Intn(len(backends))
+	b := rand.Intn(len(backends) - 1)
+	if b >= a {
+		b++
+	}
+	if lessLoaded(backends[b], backends[a]) {
+		return b
+	}
+	return a
+}
+
+// lessLoaded reports whether a has fewer active requests per unit of weight than b.
+func lessLoaded(a, b Backend) bool {
+	return a.ActiveRequests*b.Weight < b.ActiveRequests*a.Weight
+}
+
+// fasterThan reports whether a has a lower latency per unit of weight than b,
+// treating an unmeasured server as the fastest.
+func fasterThan(a, b Backend) bool {
+	if a.Latency == 0 || b.Latency == 0 {
+		return a.Latency == 0 && b.Latency != 0
+	}
+	return int64(a.Latency)*int64(b.Weight) < int64(b.Latency)*int64(a.Weight)
+}
@@ -0,0 +1,201 @@
+package loadbalancer
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWeightedRoundRobin(t *testing.T) {
+	servers := []string{"server1:8080", "server2:8080", "server3:8080"}
+	lb := NewLoadBalancerWithOptions(context.Background(), servers, Options{
+		HealthCheckInterval: time.Hour,
+		Strategy:            NewWeightedRoundRobin(),
+		Weights:             map[string]int{"server1:8080": 3},
+	})
+
+	counts := make(map[string]int)
+	for i := 0; i < 50; i++ {
+		server, err := lb.GetHealthyServer()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		counts[server]++
+	}
+	if counts["server1:8080"] != 30 || counts["server2:8080"] != 10 || counts["server3:8080"] != 10 {
+		t.Errorf("Expected counts 30/10/10, got %v", counts)
+	}
+
+	// Changing a weight at runtime takes effect for the next requests
+	if err := lb.SetWeight("server1:8080", 1); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	counts = make(map[string]int)
+	for i := 0; i < 30; i++ {
+		server, _ := lb.GetHealthyServer()
+		counts[server]++
+	}
+	for _, server := range servers {
+		if counts[server] < 9 || counts[server] > 11 {
+			t.Errorf("Expected about 10 requests to %s, got %d", server, counts[server])
+		}
+	}
+}
+
+func TestWeightedRoundRobinSkipsUnhealthy(t *testing.T) {
+	servers := []string{"server1:8080", "server2:8080"}
+	lb := NewLoadBalancerWithOptions(context.Background(), servers, Options{
+		HealthCheckInterval: time.Hour,
+		Strategy:            NewWeightedRoundRobin(),
+		Weights:             map[string]int{"server1:8080": 5},
+	})
+	lb.healthChecks["server1:8080"] = false
+
+	for i := 0; i < 5; i++ {
+		server, err := lb.GetHealthyServer()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if server != "server2:8080" {
+			t.Errorf("Expected server2:8080, got %s", server)
+		}
+	}
+
+	lb.healthChecks["server2:8080"] = false
+	if _, err := lb.GetHealthyServer(); err == nil {
+		t.Error("Expected error when all servers are unhealthy, got nil")
+	}
+}
+
+func TestSetWeight(t *testing.T) {
+	lb := NewLoadBalancer(context.Background(), []string{"server1:8080"}, time.Hour, 1)
+
+	if err := lb.SetWeight("server1:8080", 0); err == nil {
+		t.Error("Expected error for a zero weight, got nil")
+	}
+	if err := lb.SetWeight("unknown:8080", 2); err == nil {
+		t.Error("Expected error for an unknown server, got nil")
+	}
+	if err := lb.SetWeight("server1:8080", 2); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if lb.stats["server1:8080"].weight != 2 {
+		t.Errorf("Expected weight 2, got %d", lb.stats["server1:8080"].weight)
+	}
+}
+
+func TestLeastConnections(t *testing.T) {
+	servers := []string{"server1:8080", "server2:8080", "server3:8080"}
+	lb := NewLoadBalancerWithOptions(context.Background(), servers, Options{
+		HealthCheckInterval: time.Hour,
+		Strategy:            NewLeastConnections(),
+	})
+
+	// Each new request goes to an idle server until every server is busy
+	dones := make(map[string]func())
+	for i := 0; i < 3; i++ {
+		server, err := lb.GetHealthyServer()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if _, ok := dones[server]; ok {
+			t.Errorf("Expected %s to be chosen once while others were idle", server)
+		}
+		dones[server] = lb.StartRequest(server)
+	}
+
+	// Finishing the request to server2 makes it the least loaded
+	dones["server2:8080"]()
+	dones["server2:8080"]() // Calling done twice has no further effect
+	if active := lb.stats["server2:8080"].active; active != 0 {
+		t.Errorf("Expected 0 active requests to server2:8080, got %d", active)
+	}
+	server, _ := lb.GetHealthyServer()
+	if server != "server2:8080" {
+		t.Errorf("Expected server2:8080, got %s", server)
+	}
+}
+
+func TestLeastConnectionsUsesWeights(t *testing.T) {
+	backends := []Backend{
+		{Server: "a", Weight: 1, ActiveRequests: 2},
+		{Server: "b", Weight: 4, ActiveRequests: 4},
+	}
+	if i := NewLeastConnections().Next(backends); i != 1 {
+		t.Errorf("Expected index 1, got %d", i)
+	}
+}
+
+func TestLowestLatency(t *testing.T) {
+	servers := []string{"server1:8080", "server2:8080", "server3:8080"}
+	lb := NewLoadBalancerWithOptions(context.Background(), servers, Options{
+		HealthCheckInterval: time.Hour,
+		Strategy:            NewLowestLatency(),
+	})
+
+	lb.ObserveLatency("server1:8080", 300*time.Millisecond)
+	lb.ObserveLatency("server2:8080", 100*time.Millisecond)
+
+	// server3 has not been measured yet, so it is tried first
+	if server, _ := lb.GetHealthyServer(); server != "server3:8080" {
+		t.Errorf("Expected server3:8080, got %s", server)
+	}
+
+	lb.ObserveLatency("server3:8080", 200*time.Millisecond)
+	if server, _ := lb.GetHealthyServer(); server != "server2:8080" {
+		t.Errorf("Expected server2:8080, got %s", server)
+	}
+
+	// The average moves towards new observations
+	for i := 0; i < 10; i++ {
+		lb.ObserveLatency("server2:8080", time.Second)
+	}
+	if server, _ := lb.GetHealthyServer(); server != "server3:8080" {
+		t.Errorf("Expected server3:8080, got %s", server)
+	}
+}
+
+func TestTwoChoices(t *testing.T) {
+	backends := []Backend{
+		{Server: "a", Weight: 1, ActiveRequests: 5},
+		{Server: "b", Weight: 1, ActiveRequests: 0},
+	}
+	strategy := NewTwoChoices()
+	for i := 0; i < 20; i++ {
+		if got := strategy.Next(backends); got != 1 {
+			t.Fatalf("Expected index 1, got %d", got)
+		}
+	}
+
+	if got := strategy.Next(backends[:1]); got != 0 {
+		t.Errorf("Expected index 0 with one backend, got %d", got)
+	}
+
+	// Every server is eventually chosen when the load is even
+	backends = []Backend{{Server: "a", Weight: 1}, {Server: "b", Weight: 1}, {Server: "c", Weight: 1}}
+	seen := make(map[int]bool)
+	for i := 0; i < 200; i++ {
+		seen[strategy.Next(backends)] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("Expected all 3 backends to be chosen, got %v", seen)
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	for _, name := range []string{"", StrategyRoundRobin} {
+		strategy, err := ParseStrategy(name)
+		if err != nil || strategy != nil {
+			t.Errorf("Expected nil strategy for %q, got %v, %v", name, strategy, err)
+		}
+	}
+	for _, name := range []string{StrategyWeightedRoundRobin, StrategyLeastConnections, StrategyLowestLatency, StrategyTwoChoices} {
+		strategy, err := ParseStrategy(name)
+		if err != nil || strategy == nil {
+			t.Errorf("Expected a strategy for %q, got %v, %v", name, strategy, err)
+		}
+	}
+	if _, err := ParseStrategy("fastest"); err == nil {
+		t.Error("Expected error for an unknown strategy, got nil")
+	}
+}
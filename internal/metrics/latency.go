@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// latencyWindow keeps the latencies of the most recent requests, so quantiles can be
+// read back for decisions such as autoscaling. Prometheus histograms only hold totals
+// since the process started, which react too slowly to a change in load.
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples []latencySample // Ring buffer of the most recent samples
+	next    int             // Index the next sample is written to
+	maxAge  time.Duration   // Samples older than this are ignored
+}
+
+// latencySample is a single request latency and when it was recorded.
+type latencySample struct {
+	at       time.Time
+	duration time.Duration
+}
+
+// newLatencyWindow creates a window holding up to size samples no older than maxAge.
+func newLatencyWindow(size int, maxAge time.Duration) *latencyWindow {
+	return &latencyWindow{
+		samples: make([]latencySample, 0, size),
+		maxAge:  maxAge,
+	}
+}
+
+// add records a latency.
+func (w *latencyWindow) add(duration time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	sample := latencySample{at: time.Now(), duration: duration}
+	if len(w.samples) < cap(w.samples) {
+		w.samples = append(w.samples, sample)
+		return
+	}
+	w.samples[w.next] = sample
+	w.next = (w.next + 1) % len(w.samples)
+}
+
+// quantile returns the q-quantile of the recent latencies, or zero if there are none.
+func (w *latencyWindow) quantile(q float64) time.Duration {
+	w.mu.Lock()
+	cutoff := time.Now().Add(-w.maxAge)
+	durations := make([]time.Duration, 0, len(w.samples))
+	for _, s := range w.samples {
+		if s.at.After(cutoff) {
+			durations = append(durations, s.duration)
+		}
+	}
+	w.mu.Unlock()
+
+	if len(durations) == 0 {
+		return 0
+	}
+	slices.Sort(durations)
+	// Nearest-rank quantile
+	rank := int(math.Ceil(q * float64(len(durations))))
+	return durations[max(0, min(rank, len(durations))-1)]
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyWindowQuantile(t *testing.T) {
+	w := newLatencyWindow(4, time.Minute)
+	if got := w.quantile(0.95); got != 0 {
+		t.Errorf("Expected 0 with no samples, got %v", got)
+	}
+
+	for _, ms := range []int{50, 10, 40, 20, 30} {
+		w.add(time.Duration(ms) * time.Millisecond)
+	}
+
+	// Only the 4 most recent samples are kept: 10, 20, 30, 40
+	tests := map[float64]time.Duration{
+		0:    10 * time.Millisecond,
+		0.5:  20 * time.Millisecond,
+		0.95: 40 * time.Millisecond,
+		1:    40 * time.Millisecond,
+	}
+	for q, expected := range tests {
+		if got := w.quantile(q); got != expected {
+			t.Errorf("Expected quantile %v to be %v, got %v", q, expected, got)
+		}
+	}
+
+	// Old samples are ignored
+	w.maxAge = 0
+	if got := w.quantile(0.5); got != 0 {
+		t.Errorf("Expected 0 once samples expire, got %v", got)
+	}
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/h2co32/gollama/pkg/middleware"
+)
+
+// HTTPOptions configures the handler returned by MiddlewareWithOptions.
+type HTTPOptions struct {
+	// Route returns the route label of a request. It is called after the request has
+	// been served, so it can read the pattern set by an http.ServeMux. Keep the number
+	// of routes small, since each is a metric label.
+	// Default: the pattern of the http.ServeMux the middleware wraps that matched the
+	// request, such as "GET /health", or "unmatched"
+	Route func(r *http.Request) string
+}
+
+// DefaultHTTPOptions returns the default HTTP middleware options.
+func DefaultHTTPOptions() HTTPOptions {
+	return HTTPOptions{
+		Route: patternRoute,
+	}
+}
+
+// patternRoute is the default HTTPOptions.Route.
+func patternRoute(r *http.Request) string {
+	if r.Pattern == "" {
+		return "unmatched"
+	}
+	return r.Pattern
+}
+
+// Middleware records the count, latency, and response size of every request served by
+// next, by route, method, and status, and counts it as in flight while it is served,
+// with the default options.
+func (mp *MetricsProvider) Middleware(next http.Handler) http.Handler {
+	return mp.MiddlewareWithOptions(next, DefaultHTTPOptions())
+}
+
+// MiddlewareWithOptions records the count, latency, and response size of every request
+// served by next, by route, method, and status, and counts it as in flight while it is
+// served. The latency covers the whole response, including streamed bodies.
+// Zero option values fall back to the defaults.
+func (mp *MetricsProvider) MiddlewareWithOptions(next http.Handler, options HTTPOptions) http.Handler {
+	if options.Route == nil {
+		options.Route = DefaultHTTPOptions().Route
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer mp.TrackInFlight()()
+		start := time.Now()
+		rw := middleware.NewStatusRecorder(w)
+		next.ServeHTTP(rw, r)
+		mp.TrackHTTPRequest(options.Route(r), r.Method, rw.Status(), time.Since(start), rw.Size())
+	})
+}
+
+// TrackHTTPRequest increments the HTTP request counter and records the latency and
+// response size of a request
+func (mp *MetricsProvider) TrackHTTPRequest(route, method string, status int, duration time.Duration, size int64) {
+	method = methodLabel(method)
+	mp.httpRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	mp.httpDuration.WithLabelValues(route, method).Observe(duration.Seconds())
+	mp.httpResponseSize.WithLabelValues(route, method).Observe(float64(size))
+	mp.latencies.add(duration)
+}
+
+// methodLabel returns the method as a label, mapping nonstandard methods to "OTHER"
+// so clients cannot create labels at will.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "OTHER"
+	}
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// findMetric returns the metric in registry with the given name and labels, or nil if
+// there is none.
+func findMetric(t *testing.T, registry prometheus.Gatherer, name string, labels map[string]string) *dto.Metric {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if value, ok := labels[label.GetName()]; ok && value != label.GetValue() {
+					continue metrics
+				}
+			}
+			return metric
+		}
+	}
+	return nil
+}
+
+func TestMiddleware(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	mp, err := NewMetricsProviderWithOptions(MetricsOptions{Registry: registry})
+	if err != nil {
+		t.Fatalf("Failed to create metrics provider: %v", err)
+	}
+
+	inFlight := -1
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /models/{name}", func(w http.ResponseWriter, r *http.Request) {
+		inFlight = mp.InFlight()
+		io.WriteString(w, "llama3")
+	})
+	mux.HandleFunc("POST /generate", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.WriteHeader(http.StatusInternalServerError) // Superfluous, and ignored
+	})
+	handler := mp.Middleware(mux)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/models/a", nil),
+		httptest.NewRequest(http.MethodGet, "/models/b", nil),
+		httptest.NewRequest(http.MethodPost, "/generate", strings.NewReader("{}")),
+		httptest.NewRequest(http.MethodGet, "/missing", nil),
+		httptest.NewRequest("PROPFIND", "/generate", nil),
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if inFlight != 1 {
+		t.Errorf("Expected 1 request in flight while serving, got %d", inFlight)
+	}
+	if mp.InFlight() != 0 {
+		t.Errorf("Expected no requests in flight afterwards, got %d", mp.InFlight())
+	}
+
+	for _, c := range []struct {
+		route, method, status string
+		count                 float64
+	}{
+		{"GET /models/{name}", "GET", "200", 2},
+		{"POST /generate", "POST", "418", 1},
+		{"unmatched", "GET", "404", 1},
+		{"unmatched", "OTHER", "405", 1},
+	} {
+		metric := findMetric(t, registry, "http_requests_total", map[string]string{"route": c.route, "method": c.method, "status": c.status})
+		if metric == nil || metric.GetCounter().GetValue() != c.count {
+			t.Errorf("Expected %v requests to %s %s with status %s, got %v", c.count, c.method, c.route, c.status, metric)
+		}
+	}
+
+	size := findMetric(t, registry, "http_response_size_bytes", map[string]string{"route": "GET /models/{name}"})
+	if size == nil || size.GetHistogram().GetSampleSum() != 12 {
+		t.Errorf("Expected 12 response bytes, got %v", size)
+	}
+	latency := findMetric(t, registry, "http_request_duration_seconds", map[string]string{"route": "POST /generate"})
+	if latency == nil || latency.GetHistogram().GetSampleCount() != 1 {
+		t.Errorf("Expected 1 latency sample, got %v", latency)
+	}
+
+	// A custom route overrides the pattern
+	handler = mp.MiddlewareWithOptions(mux, HTTPOptions{Route: func(r *http.Request) string { return "custom" }})
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/models/a", nil))
+	if findMetric(t, registry, "http_requests_total", map[string]string{"route": "custom"}) == nil {
+		t.Error("Expected the request to be recorded under the custom route")
+	}
+}
@@ -1,75 +1,708 @@
-package metrics
-
-import (
-	"fmt"
-	"net/http"
-	"time"
-
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
-)
-
-// MetricsProvider holds Prometheus metrics collectors for tracking request metrics
-type MetricsProvider struct {
-	requestCount   *prometheus.CounterVec
-	requestLatency *prometheus.HistogramVec
-	errorCount     *prometheus.CounterVec
-}
-
-// NewMetricsProvider initializes and registers Prometheus metrics
-func NewMetricsProvider() *MetricsProvider {
-	mp := &MetricsProvider{
-		requestCount: prometheus.NewCounterVec(
-			prometheus.CounterOpts{
-				Name: "requests_total",
-				Help: "Total number of requests processed, labeled by endpoint and status.",
-			},
-			[]string{"endpoint", "status"},
-		),
-		requestLatency: prometheus.NewHistogramVec(
-			prometheus.HistogramOpts{
-				Name:    "request_latency_seconds",
-				Help:    "Request latency in seconds, labeled by endpoint.",
-				Buckets: prometheus.DefBuckets,
-			},
-			[]string{"endpoint"},
-		),
-		errorCount: prometheus.NewCounterVec(
-			prometheus.CounterOpts{
-				Name: "errors_total",
-				Help: "Total number of errors encountered, labeled by endpoint and error type.",
-			},
-			[]string{"endpoint", "error_type"},
-		),
-	}
-
-	// Register metrics with Prometheus
-	prometheus.MustRegister(mp.requestCount)
-	prometheus.MustRegister(mp.requestLatency)
-	prometheus.MustRegister(mp.errorCount)
-
-	return mp
-}
-
-// TrackRequest increments the request counter and records latency
-func (mp *MetricsProvider) TrackRequest(endpoint, status string, duration time.Duration) {
-	mp.requestCount.WithLabelValues(endpoint, status).Inc()
-	mp.requestLatency.WithLabelValues(endpoint).Observe(duration.Seconds())
-}
-
-// TrackError increments the error counter for the specified error type
-func (mp *MetricsProvider) TrackError(endpoint, errorType string) {
-	mp.errorCount.WithLabelValues(endpoint, errorType).Inc()
-}
-
-// ServeMetrics provides an HTTP endpoint for Prometheus to scrape metrics
-func (mp *MetricsProvider) ServeMetrics(port int) {
-	http.Handle("/metrics", promhttp.Handler())
-	go func() {
-		addr := fmt.Sprintf(":%d", port)
-		fmt.Printf("Serving Prometheus metrics on %s/metrics\n", addr)
-		if err := http.ListenAndServe(addr, nil); err != nil {
-			fmt.Printf("Error starting Prometheus metrics server: %v\n", err)
-		}
-	}()
-}
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/h2co32/gollama/pkg/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// MetricsOptions configures a MetricsProvider.
+type MetricsOptions struct {
+	// Registry is where the metrics are registered and gathered from for Handler. A
+	// separate registry lets several providers coexist, for example one per test.
+	// Default: the global registry, prometheus.DefaultRegisterer and
+	// prometheus.DefaultGatherer
+	Registry *prometheus.Registry
+}
+
+// DefaultMetricsOptions returns the default metrics options.
+func DefaultMetricsOptions() MetricsOptions {
+	return MetricsOptions{}
+}
+
+// MetricsProvider holds Prometheus metrics collectors for tracking request metrics
+type MetricsProvider struct {
+	registerer prometheus.Registerer
+	gatherer   prometheus.Gatherer
+
+	requestCount   *prometheus.CounterVec
+	requestLatency *prometheus.HistogramVec
+	errorCount     *prometheus.CounterVec
+
+	httpRequests     *prometheus.CounterVec
+	httpDuration     *prometheus.HistogramVec
+	httpResponseSize *prometheus.HistogramVec
+
+	cacheHits      *prometheus.CounterVec
+	cacheMisses    *prometheus.CounterVec
+	cacheEvictions *prometheus.CounterVec
+	cacheEntries   *prometheus.GaugeVec
+	cacheBytes     *prometheus.GaugeVec
+	cacheLatency   *prometheus.HistogramVec
+
+	backendEjections *prometheus.CounterVec
+	backendEjected   *prometheus.GaugeVec
+
+	autoscalerWorkers  *prometheus.GaugeVec
+	autoscalerDesired  *prometheus.GaugeVec
+	autoscalerDecision *prometheus.GaugeVec
+	decisionMu         sync.Mutex
+	decisions          map[string]string // Last reason reported by each autoscaler
+
+	jobQueueDepth *prometheus.GaugeVec
+	jobWait       *prometheus.HistogramVec
+	jobDuration   *prometheus.HistogramVec
+	jobRetries    *prometheus.CounterVec
+	jobFailures   *prometheus.CounterVec
+
+	promptTokens     *prometheus.CounterVec
+	completionTokens *prometheus.CounterVec
+	tokensPerSecond  *prometheus.HistogramVec
+	timeToFirstToken *prometheus.HistogramVec
+	inferenceQueue   *prometheus.HistogramVec
+	cpuUtilization   *prometheus.GaugeVec
+	gpuUtilization   *prometheus.GaugeVec
+
+	providerRequests  *prometheus.CounterVec
+	providerDuration  *prometheus.HistogramVec
+	providerFailovers *prometheus.CounterVec
+	shadowDropped     *prometheus.CounterVec
+
+	usageRequests *prometheus.CounterVec
+	usageTokens   *prometheus.CounterVec
+	usageCost     *prometheus.CounterVec
+
+	quotaRejections *prometheus.CounterVec
+
+	modelRequests *prometheus.CounterVec
+	modelLatency  *prometheus.HistogramVec
+	modelLastUsed *prometheus.GaugeVec
+
+	requestsInFlight prometheus.GaugeFunc
+	inFlight         atomic.Int64
+	latencies        *latencyWindow // Recent request latencies, for LatencyQuantile
+}
+
+// NewMetricsProvider initializes and registers Prometheus metrics in the global
+// registry. It panics if they cannot be registered, for example because a provider was
+// already created; use NewMetricsProviderWithOptions to get an error instead.
+func NewMetricsProvider() *MetricsProvider {
+	mp, err := NewMetricsProviderWithOptions(DefaultMetricsOptions())
+	if err != nil {
+		panic(err)
+	}
+	return mp
+}
+
+// NewMetricsProviderWithOptions initializes Prometheus metrics and registers them in
+// options.Registry. It returns an error, and registers none of them, if any cannot be
+// registered, for example because the registry already has metrics of the same names.
+func NewMetricsProviderWithOptions(options MetricsOptions) (*MetricsProvider, error) {
+	mp := &MetricsProvider{
+		registerer: prometheus.DefaultRegisterer,
+		gatherer:   prometheus.DefaultGatherer,
+
+		requestCount: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "requests_total",
+				Help: "Total number of requests processed, labeled by endpoint and status.",
+			},
+			[]string{"endpoint", "status"},
+		),
+		requestLatency: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "request_latency_seconds",
+				Help:    "Request latency in seconds, labeled by endpoint.",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"endpoint"},
+		),
+		errorCount: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "errors_total",
+				Help: "Total number of errors encountered, labeled by endpoint and error type.",
+			},
+			[]string{"endpoint", "error_type"},
+		),
+		httpRequests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "http_requests_total",
+				Help: "Total number of HTTP requests served, labeled by route, method, and status.",
+			},
+			[]string{"route", "method", "status"},
+		),
+		httpDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "http_request_duration_seconds",
+				Help:    "Time to serve HTTP requests in seconds, including streamed response bodies, labeled by route and method.",
+				Buckets: []float64{.005, .01, .05, .1, .5, 1, 2.5, 5, 10, 30, 60, 120},
+			},
+			[]string{"route", "method"},
+		),
+		httpResponseSize: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "http_response_size_bytes",
+				Help:    "Size of HTTP response bodies in bytes, labeled by route and method.",
+				Buckets: prometheus.ExponentialBuckets(64, 4, 10),
+			},
+			[]string{"route", "method"},
+		),
+		cacheHits: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cache_hits_total",
+				Help: "Total number of cache lookups that found a value, labeled by cache.",
+			},
+			[]string{"cache"},
+		),
+		cacheMisses: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cache_misses_total",
+				Help: "Total number of cache lookups that found no value, labeled by cache.",
+			},
+			[]string{"cache"},
+		),
+		cacheEvictions: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cache_evictions_total",
+				Help: "Total number of entries removed by a cache because of size limits, expiry, or corruption, labeled by cache.",
+			},
+			[]string{"cache"},
+		),
+		cacheEntries: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "cache_entries",
+				Help: "Number of entries held by a cache, labeled by cache.",
+			},
+			[]string{"cache"},
+		),
+		cacheBytes: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "cache_size_bytes",
+				Help: "Size of the entries held by a cache in bytes, labeled by cache.",
+			},
+			[]string{"cache"},
+		),
+		cacheLatency: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "cache_operation_duration_seconds",
+				Help:    "Cache operation latency in seconds, labeled by cache and operation.",
+				Buckets: []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1},
+			},
+			[]string{"cache", "operation"},
+		),
+		backendEjections: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "backend_ejections_total",
+				Help: "Total number of times a backend was ejected from load balancing for failing too many requests, labeled by backend.",
+			},
+			[]string{"backend"},
+		),
+		backendEjected: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "backend_ejected",
+				Help: "Whether a backend is currently ejected from load balancing (1) or not (0), labeled by backend.",
+			},
+			[]string{"backend"},
+		),
+		autoscalerWorkers: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "autoscaler_workers",
+				Help: "Current size of an autoscaler's worker pool, labeled by autoscaler.",
+			},
+			[]string{"autoscaler"},
+		),
+		autoscalerDesired: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "autoscaler_desired_workers",
+				Help: "Number of workers an autoscaler's policy last asked for, before clamping to the pool limits, labeled by autoscaler.",
+			},
+			[]string{"autoscaler"},
+		),
+		autoscalerDecision: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "autoscaler_decision",
+				Help: "Reason for an autoscaler's last decision (1) among those it has made (0), labeled by autoscaler and reason.",
+			},
+			[]string{"autoscaler", "reason"},
+		),
+		jobQueueDepth: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "job_queue_depth",
+				Help: "Number of jobs waiting for a worker, labeled by queue and job type.",
+			},
+			[]string{"queue", "type"},
+		),
+		jobWait: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "job_wait_seconds",
+				Help:    "Time jobs spent queued before a worker took them in seconds, labeled by queue and job type.",
+				Buckets: []float64{.001, .01, .1, .5, 1, 5, 10, 30, 60, 300},
+			},
+			[]string{"queue", "type"},
+		),
+		jobDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "job_duration_seconds",
+				Help:    "Time spent running jobs, including retries, in seconds, labeled by queue and job type.",
+				Buckets: []float64{.01, .1, .5, 1, 5, 10, 30, 60, 300, 900},
+			},
+			[]string{"queue", "type"},
+		),
+		jobRetries: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "job_retries_total",
+				Help: "Total number of job attempts retried after failing, labeled by queue and job type.",
+			},
+			[]string{"queue", "type"},
+		),
+		jobFailures: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "job_failures_total",
+				Help: "Total number of jobs that failed after their last attempt, labeled by queue and job type.",
+			},
+			[]string{"queue", "type"},
+		),
+		promptTokens: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "llm_prompt_tokens_total",
+				Help: "Total number of prompt tokens evaluated, labeled by model and backend.",
+			},
+			[]string{"model", "backend"},
+		),
+		completionTokens: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "llm_completion_tokens_total",
+				Help: "Total number of tokens generated, labeled by model and backend.",
+			},
+			[]string{"model", "backend"},
+		),
+		tokensPerSecond: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "llm_tokens_per_second",
+				Help:    "Generation speed of completed requests in tokens per second, labeled by model and backend.",
+				Buckets: []float64{1, 5, 10, 20, 30, 50, 75, 100, 150, 200, 500},
+			},
+			[]string{"model", "backend"},
+		),
+		timeToFirstToken: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "llm_time_to_first_token_seconds",
+				Help:    "Time from sending a request to receiving its first generated token in seconds, labeled by model and backend.",
+				Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+			},
+			[]string{"model", "backend"},
+		),
+		inferenceQueue: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "llm_queue_time_seconds",
+				Help:    "Time requests waited before their model started evaluating them in seconds, labeled by model and backend.",
+				Buckets: []float64{.001, .01, .05, .1, .5, 1, 5, 10, 30, 60},
+			},
+			[]string{"model", "backend"},
+		),
+		cpuUtilization: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "backend_cpu_utilization",
+				Help: "CPU utilization of a backend, between 0 and 1, labeled by backend.",
+			},
+			[]string{"backend"},
+		),
+		gpuUtilization: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "backend_gpu_utilization",
+				Help: "Utilization of a backend's GPU, between 0 and 1, labeled by backend and GPU.",
+			},
+			[]string{"backend", "gpu"},
+		),
+		providerRequests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "provider_requests_total",
+				Help: "Total number of requests routing policies sent to providers, labeled by policy, provider, and status.",
+			},
+			[]string{"policy", "provider", "status"},
+		),
+		providerDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "provider_request_duration_seconds",
+				Help:    "Time providers took to serve the requests of routing policies in seconds, including streamed responses, labeled by policy and provider.",
+				Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+			},
+			[]string{"policy", "provider"},
+		),
+		providerFailovers: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "provider_failovers_total",
+				Help: "Total number of requests a failover policy moved to its next provider, labeled by policy and the provider that failed.",
+			},
+			[]string{"policy", "provider"},
+		),
+		shadowDropped: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "provider_shadow_dropped_total",
+				Help: "Total number of sampled requests a shadow policy did not mirror because too many mirrored requests were in flight, labeled by policy.",
+			},
+			[]string{"policy"},
+		),
+		usageRequests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "usage_requests_total",
+				Help: "Total number of model requests accounted for, labeled by tenant and model.",
+			},
+			[]string{"tenant", "model"},
+		),
+		usageTokens: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "usage_tokens_total",
+				Help: "Total number of tokens accounted for, labeled by tenant, model, and type (prompt or completion).",
+			},
+			[]string{"tenant", "model", "type"},
+		),
+		usageCost: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "usage_cost_total",
+				Help: "Total cost of the tokens accounted for, at the model's configured rates, labeled by tenant and model.",
+			},
+			[]string{"tenant", "model"},
+		),
+		quotaRejections: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "quota_rejections_total",
+				Help: "Total number of requests rejected for exceeding a quota, labeled by tenant and quota, such as daily_tokens.",
+			},
+			[]string{"tenant", "quota"},
+		),
+		modelRequests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "model_requests_total",
+				Help: "Total number of requests served by a model managed by the model manager, labeled by model.",
+			},
+			[]string{"model"},
+		),
+		modelLatency: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "model_request_duration_seconds",
+				Help:    "Latency of requests served by a model managed by the model manager in seconds, labeled by model.",
+				Buckets: []float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+			},
+			[]string{"model"},
+		),
+		modelLastUsed: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "model_last_used_timestamp_seconds",
+				Help: "Unix time of the last request served by a model managed by the model manager, labeled by model.",
+			},
+			[]string{"model"},
+		),
+		decisions: make(map[string]string),
+		latencies: newLatencyWindow(1024, time.Minute),
+	}
+	mp.requestsInFlight = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "requests_in_flight",
+			Help: "Number of requests currently being processed.",
+		},
+		func() float64 { return float64(mp.inFlight.Load()) },
+	)
+
+	if options.Registry != nil {
+		mp.registerer = options.Registry
+		mp.gatherer = options.Registry
+	}
+
+	// Register metrics with Prometheus
+	collectors := []prometheus.Collector{
+		mp.requestCount,
+		mp.requestLatency,
+		mp.errorCount,
+		mp.httpRequests,
+		mp.httpDuration,
+		mp.httpResponseSize,
+		mp.cacheHits,
+		mp.cacheMisses,
+		mp.cacheEvictions,
+		mp.cacheEntries,
+		mp.cacheBytes,
+		mp.cacheLatency,
+		mp.backendEjections,
+		mp.backendEjected,
+		mp.autoscalerWorkers,
+		mp.autoscalerDesired,
+		mp.autoscalerDecision,
+		mp.jobQueueDepth,
+		mp.jobWait,
+		mp.jobDuration,
+		mp.jobRetries,
+		mp.jobFailures,
+		mp.promptTokens,
+		mp.completionTokens,
+		mp.tokensPerSecond,
+		mp.timeToFirstToken,
+		mp.inferenceQueue,
+		mp.cpuUtilization,
+		mp.gpuUtilization,
+		mp.providerRequests,
+		mp.providerDuration,
+		mp.providerFailovers,
+		mp.shadowDropped,
+		mp.usageRequests,
+		mp.usageTokens,
+		mp.usageCost,
+		mp.quotaRejections,
+		mp.modelRequests,
+		mp.modelLatency,
+		mp.modelLastUsed,
+		mp.requestsInFlight,
+	}
+	for i, collector := range collectors {
+		if err := mp.registerer.Register(collector); err != nil {
+			for _, registered := range collectors[:i] {
+				mp.registerer.Unregister(registered)
+			}
+			return nil, fmt.Errorf("failed to register metrics: %w", err)
+		}
+	}
+
+	return mp, nil
+}
+
+// TrackRequest increments the request counter and records latency
+func (mp *MetricsProvider) TrackRequest(endpoint, status string, duration time.Duration) {
+	mp.requestCount.WithLabelValues(endpoint, status).Inc()
+	mp.requestLatency.WithLabelValues(endpoint).Observe(duration.Seconds())
+	mp.latencies.add(duration)
+}
+
+// TrackInFlight counts a request as in flight until the returned function is called
+func (mp *MetricsProvider) TrackInFlight() (done func()) {
+	mp.inFlight.Add(1)
+	return func() { mp.inFlight.Add(-1) }
+}
+
+// InFlight returns the number of requests currently in flight
+func (mp *MetricsProvider) InFlight() int {
+	return int(mp.inFlight.Load())
+}
+
+// LatencyQuantile returns the q-quantile, between 0 and 1, of the latencies of the
+// requests tracked in the last minute, up to the 1024 most recent, or zero if there
+// were none. For example, LatencyQuantile(0.95) is the recent p95 latency.
+func (mp *MetricsProvider) LatencyQuantile(q float64) time.Duration {
+	return mp.latencies.quantile(q)
+}
+
+// TrackError increments the error counter for the specified error type
+func (mp *MetricsProvider) TrackError(endpoint, errorType string) {
+	mp.errorCount.WithLabelValues(endpoint, errorType).Inc()
+}
+
+// TrackCacheLookup increments the hit or miss counter of the named cache
+func (mp *MetricsProvider) TrackCacheLookup(cache string, hit bool) {
+	if hit {
+		mp.cacheHits.WithLabelValues(cache).Inc()
+	} else {
+		mp.cacheMisses.WithLabelValues(cache).Inc()
+	}
+}
+
+// TrackCacheEvictions adds count to the eviction counter of the named cache
+func (mp *MetricsProvider) TrackCacheEvictions(cache string, count int) {
+	mp.cacheEvictions.WithLabelValues(cache).Add(float64(count))
+}
+
+// SetCacheSize records the number of entries and total bytes held by the named cache
+func (mp *MetricsProvider) SetCacheSize(cache string, entries int, bytes int64) {
+	mp.cacheEntries.WithLabelValues(cache).Set(float64(entries))
+	mp.cacheBytes.WithLabelValues(cache).Set(float64(bytes))
+}
+
+// TrackCacheOperation records the latency of a cache operation such as "get" or "set"
+func (mp *MetricsProvider) TrackCacheOperation(cache, operation string, duration time.Duration) {
+	mp.cacheLatency.WithLabelValues(cache, operation).Observe(duration.Seconds())
+}
+
+// TrackBackendEjection increments the ejection counter of a backend and marks it ejected
+func (mp *MetricsProvider) TrackBackendEjection(backend string) {
+	mp.backendEjections.WithLabelValues(backend).Inc()
+	mp.backendEjected.WithLabelValues(backend).Set(1)
+}
+
+// TrackBackendReadmission marks a previously ejected backend as back in rotation
+func (mp *MetricsProvider) TrackBackendReadmission(backend string) {
+	mp.backendEjected.WithLabelValues(backend).Set(0)
+}
+
+// SetAutoscalerState records the pool size, the desired size, and the reason for the
+// last decision of the named autoscaler
+func (mp *MetricsProvider) SetAutoscalerState(autoscaler string, workers, desired int, reason string) {
+	mp.autoscalerWorkers.WithLabelValues(autoscaler).Set(float64(workers))
+	mp.autoscalerDesired.WithLabelValues(autoscaler).Set(float64(desired))
+
+	mp.decisionMu.Lock()
+	defer mp.decisionMu.Unlock()
+	if previous, ok := mp.decisions[autoscaler]; ok && previous != reason {
+		mp.autoscalerDecision.WithLabelValues(autoscaler, previous).Set(0)
+	}
+	mp.decisions[autoscaler] = reason
+	mp.autoscalerDecision.WithLabelValues(autoscaler, reason).Set(1)
+}
+
+// AddJobQueueDepth adds delta to the number of queued jobs of a type in the named queue
+func (mp *MetricsProvider) AddJobQueueDepth(queue, jobType string, delta int) {
+	mp.jobQueueDepth.WithLabelValues(queue, jobType).Add(float64(delta))
+}
+
+// TrackJobStart records how long a job of a type waited in the named queue before a
+// worker took it
+func (mp *MetricsProvider) TrackJobStart(queue, jobType string, wait time.Duration) {
+	mp.jobWait.WithLabelValues(queue, jobType).Observe(wait.Seconds())
+}
+
+// TrackJobRetry increments the retry counter of a job type in the named queue
+func (mp *MetricsProvider) TrackJobRetry(queue, jobType string) {
+	mp.jobRetries.WithLabelValues(queue, jobType).Inc()
+}
+
+// TrackJobFinish records how long a job of a type ran in the named queue and whether
+// it failed
+func (mp *MetricsProvider) TrackJobFinish(queue, jobType string, duration time.Duration, failed bool) {
+	mp.jobDuration.WithLabelValues(queue, jobType).Observe(duration.Seconds())
+	if failed {
+		mp.jobFailures.WithLabelValues(queue, jobType).Inc()
+	}
+}
+
+// Inference describes a completed generation for TrackInference. Zero durations are
+// not recorded.
+type Inference struct {
+	// Model is the model that generated the response.
+	Model string
+
+	// Backend is the server that ran the model.
+	Backend string
+
+	// PromptTokens and CompletionTokens count the tokens evaluated and generated.
+	PromptTokens     int
+	CompletionTokens int
+
+	// TimeToFirstToken is the time from sending the request to receiving the first
+	// generated token.
+	TimeToFirstToken time.Duration
+
+	// GenerationTime is the time spent generating the completion tokens, from which
+	// the tokens per second are computed.
+	GenerationTime time.Duration
+
+	// QueueTime is the time the request waited before the model started evaluating
+	// it, for example for the backend to schedule it.
+	QueueTime time.Duration
+}
+
+// TrackInference records the token counts, generation speed, time to first token, and
+// queue time of a completed generation
+func (mp *MetricsProvider) TrackInference(inference Inference) {
+	labels := []string{inference.Model, inference.Backend}
+	mp.promptTokens.WithLabelValues(labels...).Add(float64(inference.PromptTokens))
+	mp.completionTokens.WithLabelValues(labels...).Add(float64(inference.CompletionTokens))
+	if inference.GenerationTime > 0 && inference.CompletionTokens > 0 {
+		mp.tokensPerSecond.WithLabelValues(labels...).Observe(float64(inference.CompletionTokens) / inference.GenerationTime.Seconds())
+	}
+	if inference.TimeToFirstToken > 0 {
+		mp.timeToFirstToken.WithLabelValues(labels...).Observe(inference.TimeToFirstToken.Seconds())
+	}
+	if inference.QueueTime > 0 {
+		mp.inferenceQueue.WithLabelValues(labels...).Observe(inference.QueueTime.Seconds())
+	}
+}
+
+// SetCPUUtilization records the CPU utilization of a backend, between 0 and 1
+func (mp *MetricsProvider) SetCPUUtilization(backend string, utilization float64) {
+	mp.cpuUtilization.WithLabelValues(backend).Set(utilization)
+}
+
+// SetGPUUtilization records the utilization of one of a backend's GPUs, between 0 and 1
+func (mp *MetricsProvider) SetGPUUtilization(backend, gpu string, utilization float64) {
+	mp.gpuUtilization.WithLabelValues(backend, gpu).Set(utilization)
+}
+
+// TrackProviderRequest records a request a routing policy sent to a provider, how long
+// the provider took to serve it, and whether it failed
+func (mp *MetricsProvider) TrackProviderRequest(policy, provider string, duration time.Duration, failed bool) {
+	status := "success"
+	if failed {
+		status = "error"
+	}
+	mp.providerRequests.WithLabelValues(policy, provider, status).Inc()
+	mp.providerDuration.WithLabelValues(policy, provider).Observe(duration.Seconds())
+}
+
+// TrackProviderFailover increments the failover counter of a provider whose failed
+// request a failover policy sent to its next provider
+func (mp *MetricsProvider) TrackProviderFailover(policy, provider string) {
+	mp.providerFailovers.WithLabelValues(policy, provider).Inc()
+}
+
+// TrackShadowDrop increments the counter of requests a shadow policy did not mirror
+func (mp *MetricsProvider) TrackShadowDrop(policy string) {
+	mp.shadowDropped.WithLabelValues(policy).Inc()
+}
+
+// TrackUsage records a model request of a tenant, its prompt and completion tokens, and
+// their cost
+func (mp *MetricsProvider) TrackUsage(tenant, model string, promptTokens, completionTokens int, cost float64) {
+	mp.usageRequests.WithLabelValues(tenant, model).Inc()
+	mp.usageTokens.WithLabelValues(tenant, model, "prompt").Add(float64(promptTokens))
+	mp.usageTokens.WithLabelValues(tenant, model, "completion").Add(float64(completionTokens))
+	mp.usageCost.WithLabelValues(tenant, model).Add(cost)
+}
+
+// TrackQuotaRejection increments the counter of a tenant's requests rejected for
+// exceeding quota
+func (mp *MetricsProvider) TrackQuotaRejection(tenant, quota string) {
+	mp.quotaRejections.WithLabelValues(tenant, quota).Inc()
+}
+
+// TrackModelRequest records a request served by a model, its latency, and the time
+// the model was last used
+func (mp *MetricsProvider) TrackModelRequest(model string, latency time.Duration) {
+	mp.modelRequests.WithLabelValues(model).Inc()
+	mp.modelLatency.WithLabelValues(model).Observe(latency.Seconds())
+	mp.modelLastUsed.WithLabelValues(model).SetToCurrentTime()
+}
+
+// Handler returns an HTTP handler that serves metrics for Prometheus to scrape
+func (mp *MetricsProvider) Handler() http.Handler {
+	return promhttp.InstrumentMetricHandler(mp.registerer, promhttp.HandlerFor(mp.gatherer, promhttp.HandlerOpts{}))
+}
+
+// ServeMetrics serves the metrics at /metrics on port in the background, on a server
+// of its own. It returns once the port is bound, with the server, whose Addr is the
+// address listened on and whose Shutdown method stops it. Port 0 picks a free port.
+func (mp *MetricsProvider) ServeMetrics(port int) (*http.Server, error) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", mp.Handler())
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for metrics requests: %w", err)
+	}
+	server := &http.Server{
+		Addr:              listener.Addr().String(),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		logging.Default().Info("serving Prometheus metrics", "addr", server.Addr, "path", "/metrics")
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Default().Error("failed to serve Prometheus metrics", "addr", server.Addr, "error", err)
+		}
+	}()
+	return server, nil
+}
@@ -0,0 +1,219 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestNewMetricsProviderWithOptions(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	mp, err := NewMetricsProviderWithOptions(MetricsOptions{Registry: registry})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// A second provider cannot register the same metrics in the same registry
+	if _, err := NewMetricsProviderWithOptions(MetricsOptions{Registry: registry}); err == nil {
+		t.Error("Expected an error for metrics already registered, got nil")
+	}
+
+	// But it can in a registry of its own, without affecting the first
+	other, err := NewMetricsProviderWithOptions(MetricsOptions{Registry: prometheus.NewRegistry()})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	other.TrackCacheLookup("l1", true)
+	mp.TrackCacheLookup("l1", false)
+	if metric := findMetric(t, registry, "cache_hits_total", nil); metric != nil {
+		t.Errorf("Expected no hits in the first registry, got %v", metric)
+	}
+	if metric := findMetric(t, registry, "cache_misses_total", nil); metric.GetCounter().GetValue() != 1 {
+		t.Errorf("Expected 1 miss in the first registry, got %v", metric)
+	}
+}
+
+func TestServeMetrics(t *testing.T) {
+	mp, err := NewMetricsProviderWithOptions(MetricsOptions{Registry: prometheus.NewRegistry()})
+	if err != nil {
+		t.Fatalf("Failed to create metrics provider: %v", err)
+	}
+	mp.TrackError("/api/generate", "backend")
+
+	server, err := mp.ServeMetrics(0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	_, port, err := net.SplitHostPort(server.Addr)
+	if err != nil {
+		t.Fatalf("Expected the server's address to include the port, got %q", server.Addr)
+	}
+	url := "http://127.0.0.1:" + port + "/metrics"
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Failed to fetch metrics: %v", err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if !strings.Contains(string(body), `errors_total{endpoint="/api/generate",error_type="backend"} 1`) {
+		t.Errorf("Expected the served metrics to include the error, got %s", body)
+	}
+
+	// The port is taken while the server runs
+	portNumber, _ := strconv.Atoi(port)
+	if _, err := mp.ServeMetrics(portNumber); err == nil {
+		t.Error("Expected an error serving on a port in use, got nil")
+	}
+
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Errorf("Expected no error shutting down, got %v", err)
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Error("Expected the server to be stopped")
+	}
+}
+
+func TestTrackInference(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	mp, err := NewMetricsProviderWithOptions(MetricsOptions{Registry: registry})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	labels := map[string]string{"model": "llama3", "backend": "gpu-1:11434"}
+	for i := 0; i < 2; i++ {
+		mp.TrackInference(Inference{
+			Model:            "llama3",
+			Backend:          "gpu-1:11434",
+			PromptTokens:     30,
+			CompletionTokens: 100,
+			TimeToFirstToken: 200 * time.Millisecond,
+			GenerationTime:   2 * time.Second,
+		})
+	}
+	if metric := findMetric(t, registry, "llm_prompt_tokens_total", labels); metric == nil || metric.GetCounter().GetValue() != 60 {
+		t.Errorf("Expected 60 prompt tokens, got %v", metric)
+	}
+	if metric := findMetric(t, registry, "llm_completion_tokens_total", labels); metric == nil || metric.GetCounter().GetValue() != 200 {
+		t.Errorf("Expected 200 completion tokens, got %v", metric)
+	}
+	if metric := findMetric(t, registry, "llm_tokens_per_second", labels); metric == nil || metric.GetHistogram().GetSampleSum() != 100 {
+		t.Errorf("Expected 2 samples of 50 tokens per second, got %v", metric)
+	}
+	if metric := findMetric(t, registry, "llm_time_to_first_token_seconds", labels); metric == nil || metric.GetHistogram().GetSampleCount() != 2 {
+		t.Errorf("Expected 2 time to first token samples, got %v", metric)
+	}
+	if metric := findMetric(t, registry, "llm_queue_time_seconds", labels); metric != nil {
+		t.Errorf("Expected no queue time samples without a queue time, got %v", metric)
+	}
+
+	mp.SetCPUUtilization("gpu-1:11434", 0.25)
+	mp.SetGPUUtilization("gpu-1:11434", "0", 0.9)
+	if metric := findMetric(t, registry, "backend_cpu_utilization", labels); metric == nil || metric.GetGauge().GetValue() != 0.25 {
+		t.Errorf("Expected a CPU utilization of 0.25, got %v", metric)
+	}
+	if metric := findMetric(t, registry, "backend_gpu_utilization", map[string]string{"gpu": "0"}); metric == nil || metric.GetGauge().GetValue() != 0.9 {
+		t.Errorf("Expected a GPU utilization of 0.9, got %v", metric)
+	}
+}
+
+func TestTrackProviderRequest(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	mp, err := NewMetricsProviderWithOptions(MetricsOptions{Registry: registry})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	mp.TrackProviderRequest("resilient", "openai", time.Second, true)
+	mp.TrackProviderFailover("resilient", "openai")
+	mp.TrackProviderRequest("resilient", "local", 2*time.Second, false)
+	mp.TrackShadowDrop("mirrored")
+
+	failed := map[string]string{"policy": "resilient", "provider": "openai", "status": "error"}
+	if metric := findMetric(t, registry, "provider_requests_total", failed); metric == nil || metric.GetCounter().GetValue() != 1 {
+		t.Errorf("Expected 1 failed request, got %v", metric)
+	}
+	succeeded := map[string]string{"provider": "local", "status": "success"}
+	if metric := findMetric(t, registry, "provider_requests_total", succeeded); metric == nil || metric.GetCounter().GetValue() != 1 {
+		t.Errorf("Expected 1 successful request, got %v", metric)
+	}
+	if metric := findMetric(t, registry, "provider_request_duration_seconds", map[string]string{"provider": "local"}); metric == nil || metric.GetHistogram().GetSampleSum() != 2 {
+		t.Errorf("Expected a 2 second request, got %v", metric)
+	}
+	if metric := findMetric(t, registry, "provider_failovers_total", map[string]string{"provider": "openai"}); metric == nil || metric.GetCounter().GetValue() != 1 {
+		t.Errorf("Expected 1 failover, got %v", metric)
+	}
+	if metric := findMetric(t, registry, "provider_shadow_dropped_total", map[string]string{"policy": "mirrored"}); metric == nil || metric.GetCounter().GetValue() != 1 {
+		t.Errorf("Expected 1 dropped shadow request, got %v", metric)
+	}
+}
+
+func TestTrackUsage(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	mp, err := NewMetricsProviderWithOptions(MetricsOptions{Registry: registry})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	mp.TrackUsage("acme", "llama3", 1000, 500, 0.25)
+	mp.TrackUsage("acme", "llama3", 1000, 0, 0.1)
+
+	labels := map[string]string{"tenant": "acme", "model": "llama3"}
+	if metric := findMetric(t, registry, "usage_requests_total", labels); metric == nil || metric.GetCounter().GetValue() != 2 {
+		t.Errorf("Expected 2 requests, got %v", metric)
+	}
+	if metric := findMetric(t, registry, "usage_tokens_total", map[string]string{"type": "prompt"}); metric == nil || metric.GetCounter().GetValue() != 2000 {
+		t.Errorf("Expected 2000 prompt tokens, got %v", metric)
+	}
+	if metric := findMetric(t, registry, "usage_tokens_total", map[string]string{"type": "completion"}); metric == nil || metric.GetCounter().GetValue() != 500 {
+		t.Errorf("Expected 500 completion tokens, got %v", metric)
+	}
+	if metric := findMetric(t, registry, "usage_cost_total", labels); metric == nil || metric.GetCounter().GetValue() != 0.35 {
+		t.Errorf("Expected a cost of 0.35, got %v", metric)
+	}
+}
+
+func TestTrackQuotaRejection(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	mp, err := NewMetricsProviderWithOptions(MetricsOptions{Registry: registry})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	mp.TrackQuotaRejection("acme", "daily_tokens")
+	mp.TrackQuotaRejection("acme", "daily_tokens")
+	mp.TrackQuotaRejection("acme", "monthly_requests")
+
+	if metric := findMetric(t, registry, "quota_rejections_total", map[string]string{"tenant": "acme", "quota": "daily_tokens"}); metric == nil || metric.GetCounter().GetValue() != 2 {
+		t.Errorf("Expected 2 rejections, got %v", metric)
+	}
+}
+
+func TestTrackModelRequest(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	mp, err := NewMetricsProviderWithOptions(MetricsOptions{Registry: registry})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	mp.TrackModelRequest("llama3", 100*time.Millisecond)
+	mp.TrackModelRequest("llama3", 300*time.Millisecond)
+
+	labels := map[string]string{"model": "llama3"}
+	if metric := findMetric(t, registry, "model_requests_total", labels); metric == nil || metric.GetCounter().GetValue() != 2 {
+		t.Errorf("Expected 2 requests, got %v", metric)
+	}
+	if metric := findMetric(t, registry, "model_request_duration_seconds", labels); metric == nil || metric.GetHistogram().GetSampleSum() < 0.39 {
+		t.Errorf("Expected 0.4 seconds of latency, got %v", metric)
+	}
+	if metric := findMetric(t, registry, "model_last_used_timestamp_seconds", labels); metric == nil || metric.GetGauge().GetValue() < float64(time.Now().Add(-time.Minute).Unix()) {
+		t.Errorf("Expected a recent last used time, got %v", metric)
+	}
+}
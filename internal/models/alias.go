@@ -0,0 +1,142 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/h2co32/gollama/pkg/logging"
+)
+
+// AliasTarget is the model version an alias points to.
+type AliasTarget struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// SetAlias points alias at target, given in the "<name>-<version>" form used for model
+// files (e.g. SetAlias("prod", "llama3-v2.1")). The target version must be stored locally.
+// Use SwapAlias to make sure the new version is loaded before the alias moves.
+func (mm *ModelManager) SetAlias(alias, target string) error {
+	resolved, err := mm.resolveAliasTarget(target)
+	if err != nil {
+		return err
+	}
+
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+	return mm.setAlias(alias, resolved)
+}
+
+// SwapAlias repoints alias at target without a window in which the alias resolves to
+// a version that is not loaded. The target version is loaded next to the version of
+// the model in use, which keeps serving until the load succeeds. Then the alias and
+// the model's current version move to the target together, and the previous version
+// is unloaded last. If the load fails, nothing changes.
+func (mm *ModelManager) SwapAlias(alias, target string) error {
+	if alias == "" {
+		return fmt.Errorf("alias cannot be empty")
+	}
+	resolved, err := mm.resolveAliasTarget(target)
+	if err != nil {
+		return err
+	}
+
+	if err := mm.swapAlias(alias, resolved); err != nil {
+		return err
+	}
+	mm.enforceMemoryBudget(resolved.Name)
+
+	logging.Default().Info("alias updated", "alias", alias, "model", resolved.Name, "version", resolved.Version)
+	return nil
+}
+
+// swapAlias moves the model to the target version with the alias, while holding the
+// model's lock.
+func (mm *ModelManager) swapAlias(alias string, target AliasTarget) error {
+	defer mm.lockModel(target.Name)()
+
+	err := mm.switchVersion(target.Name, target.Version, true, func() {
+		mm.aliases[alias] = target
+		mm.lastUsed[target.Name] = time.Now().UTC()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prepare alias %s: %w", alias, err)
+	}
+	return nil
+}
+
+// ResolveAlias returns the model name and version alias points to.
+func (mm *ModelManager) ResolveAlias(alias string) (string, string, error) {
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+
+	target, ok := mm.aliases[alias]
+	if !ok {
+		return "", "", fmt.Errorf("alias %s not found", alias)
+	}
+	return target.Name, target.Version, nil
+}
+
+// RemoveAlias deletes an alias.
+func (mm *ModelManager) RemoveAlias(alias string) error {
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+
+	if _, ok := mm.aliases[alias]; !ok {
+		return fmt.Errorf("alias %s not found", alias)
+	}
+	delete(mm.aliases, alias)
+	return mm.saveManifest()
+}
+
+// Aliases returns a copy of all aliases and their targets.
+func (mm *ModelManager) Aliases() map[string]AliasTarget {
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+
+	aliases := make(map[string]AliasTarget, len(mm.aliases))
+	for alias, target := range mm.aliases {
+		aliases[alias] = target
+	}
+	return aliases
+}
+
+// setAlias records an alias and persists the manifest.
+// This method is not thread-safe and should be called with the lock held.
+func (mm *ModelManager) setAlias(alias string, target AliasTarget) error {
+	if alias == "" {
+		return fmt.Errorf("alias cannot be empty")
+	}
+	mm.aliases[alias] = target
+	return mm.saveManifest()
+}
+
+// resolveAliasTarget splits a "<name>-<version>" target and checks the version is stored locally.
+func (mm *ModelManager) resolveAliasTarget(target string) (AliasTarget, error) {
+	mm.lock.Lock()
+	name, version := mm.parseModelFile(target + ".bin")
+	mm.lock.Unlock()
+
+	if name == "" || version == "" {
+		return AliasTarget{}, fmt.Errorf("invalid alias target %s", target)
+	}
+	modelPath := filepath.Join(mm.modelDir, name+"-"+version+".bin")
+	if _, err := os.Stat(modelPath); err != nil {
+		return AliasTarget{}, fmt.Errorf("alias target %s not found", target)
+	}
+	return AliasTarget{Name: name, Version: version}, nil
+}
+
+// aliasesFor returns the aliases pointing at a model version.
+// This method is not thread-safe and should be called with the lock held.
+func (mm *ModelManager) aliasesFor(modelName, version string) []string {
+	var aliases []string
+	for alias, target := range mm.aliases {
+		if target.Name == modelName && target.Version == version {
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases
+}
@@ -0,0 +1,193 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetAndResolveAlias(t *testing.T) {
+	mm := newTestManager(t, "llama3")
+
+	if err := mm.SetAlias("prod", "llama3-v1.0"); err != nil {
+		t.Fatalf("Failed to set alias: %v", err)
+	}
+
+	name, version, err := mm.ResolveAlias("prod")
+	if err != nil {
+		t.Fatalf("Failed to resolve alias: %v", err)
+	}
+	if name != "llama3" || version != "v1.0" {
+		t.Errorf("Expected alias to resolve to llama3 v1.0, got %s %s", name, version)
+	}
+
+	// Aliases survive a restart
+	restored := NewModelManager(mm.modelDir)
+	if target := restored.Aliases()["prod"]; target.Name != "llama3" || target.Version != "v1.0" {
+		t.Errorf("Expected restored alias to point at llama3 v1.0, got %+v", target)
+	}
+
+	// Aliases cannot point at versions that are not stored
+	if err := mm.SetAlias("prod", "llama3-v9.9"); err == nil {
+		t.Error("Expected error when aliasing a missing version, got nil")
+	}
+
+	if err := mm.RemoveAlias("prod"); err != nil {
+		t.Fatalf("Failed to remove alias: %v", err)
+	}
+	if _, _, err := mm.ResolveAlias("prod"); err == nil {
+		t.Error("Expected error when resolving a removed alias, got nil")
+	}
+}
+
+func TestSwapAlias(t *testing.T) {
+	mm := newTestManager(t, "llama3")
+	backend := &fakeBackend{size: 10}
+	mm.SetBackend(backend)
+
+	// Add a second version of the model
+	if err := os.WriteFile(filepath.Join(mm.modelDir, "llama3-v2.1.bin"), []byte("new model data"), 0644); err != nil {
+		t.Fatalf("Failed to create mock model file: %v", err)
+	}
+
+	if err := mm.SwapAlias("prod", "llama3-v1.0"); err != nil {
+		t.Fatalf("Failed to swap alias: %v", err)
+	}
+	if mm.loadedModels["llama3"] == "" {
+		t.Error("Expected alias target to be loaded before the alias is set")
+	}
+
+	if err := mm.SwapAlias("prod", "llama3-v2.1"); err != nil {
+		t.Fatalf("Failed to swap alias: %v", err)
+	}
+
+	name, version, err := mm.ResolveAlias("prod")
+	if err != nil {
+		t.Fatalf("Failed to resolve alias: %v", err)
+	}
+	if name != "llama3" || version != "v2.1" {
+		t.Errorf("Expected alias to resolve to llama3 v2.1, got %s %s", name, version)
+	}
+	if mm.currentVersion["llama3"] != "v2.1" || mm.loadedModels["llama3"] == "" {
+		t.Error("Expected v2.1 to be the current, loaded version after the swap")
+	}
+	if len(backend.unloaded) != 1 || len(backend.loaded) != 2 {
+		t.Errorf("Expected the old version to be unloaded and the new one loaded, got loads %v and unloads %v", backend.loaded, backend.unloaded)
+	}
+
+	// A failed preparation leaves the alias and the previous version untouched
+	backend.failOn = "v3.0"
+	if err := os.WriteFile(filepath.Join(mm.modelDir, "llama3-v3.0.bin"), []byte("bad model"), 0644); err != nil {
+		t.Fatalf("Failed to create mock model file: %v", err)
+	}
+	if err := mm.SwapAlias("prod", "llama3-v3.0"); err == nil {
+		t.Fatal("Expected error when the new version fails to load, got nil")
+	}
+	if _, version, _ := mm.ResolveAlias("prod"); version != "v2.1" {
+		t.Errorf("Expected alias to still point at v2.1, got %s", version)
+	}
+	if mm.currentVersion["llama3"] != "v2.1" || mm.loadedModels["llama3"] == "" {
+		t.Error("Expected v2.1 to remain the current, loaded version")
+	}
+}
+
+// blockingBackend blocks the load of one version until release is closed, recording
+// loads and unloads in order.
+type blockingBackend struct {
+	mu      sync.Mutex
+	version string
+	started chan struct{}
+	release chan struct{}
+	events  []string
+}
+
+func (b *blockingBackend) Load(modelName, version, path string) (int64, error) {
+	if version == b.version {
+		close(b.started)
+		<-b.release
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.events = append(b.events, "load "+version)
+	return 10, nil
+}
+
+func (b *blockingBackend) Unload(modelName, version string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.events = append(b.events, "unload "+version)
+	return nil
+}
+
+func (b *blockingBackend) history() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return slices.Clone(b.events)
+}
+
+func TestSwapAliasServesPreviousVersionWhileLoading(t *testing.T) {
+	mm := newTestManager(t, "llama3")
+	if err := os.WriteFile(filepath.Join(mm.modelDir, "llama3-v2.1.bin"), []byte("new model data"), 0644); err != nil {
+		t.Fatalf("Failed to create mock model file: %v", err)
+	}
+	backend := &blockingBackend{version: "v2.1", started: make(chan struct{}), release: make(chan struct{})}
+	mm.SetBackend(backend)
+
+	if err := mm.SwapAlias("prod", "llama3-v1.0"); err != nil {
+		t.Fatalf("Failed to swap alias: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- mm.SwapAlias("prod", "llama3-v2.1") }()
+	select {
+	case <-backend.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the new version to start loading")
+	}
+
+	// While the new version loads, the alias still resolves to the loaded old version
+	if _, version, err := mm.ResolveAlias("prod"); err != nil || version != "v1.0" {
+		t.Errorf("Expected alias to resolve to v1.0 during the load, got %s, %v", version, err)
+	}
+	mm.lock.Lock()
+	current, loaded := mm.currentVersion["llama3"], mm.loadedModels["llama3"]
+	mm.lock.Unlock()
+	if current != "v1.0" || loaded == "" {
+		t.Errorf("Expected v1.0 to stay the current, loaded version during the load, got %s, loaded %q", current, loaded)
+	}
+	if events := backend.history(); !slices.Equal(events, []string{"load v1.0"}) {
+		t.Errorf("Expected only v1.0 to be loaded during the load, got %v", events)
+	}
+
+	close(backend.release)
+	if err := <-done; err != nil {
+		t.Fatalf("Failed to swap alias: %v", err)
+	}
+	if _, version, _ := mm.ResolveAlias("prod"); version != "v2.1" {
+		t.Errorf("Expected alias to resolve to v2.1 after the swap, got %s", version)
+	}
+	// The old version is unloaded only after the new one is loaded
+	if events := backend.history(); !slices.Equal(events, []string{"load v1.0", "load v2.1", "unload v1.0"}) {
+		t.Errorf("Expected v2.1 to be loaded before v1.0 is unloaded, got %v", events)
+	}
+}
+
+func TestDeleteAliasedModel(t *testing.T) {
+	mm := newTestManager(t, "llama3")
+
+	if err := mm.SetAlias("prod", "llama3-v1.0"); err != nil {
+		t.Fatalf("Failed to set alias: %v", err)
+	}
+
+	err := mm.DeleteModel("llama3", "v1.0")
+	if err == nil {
+		t.Fatal("Expected error when deleting an aliased model, got nil")
+	}
+	if !strings.Contains(err.Error(), "referenced by aliases") {
+		t.Errorf("Expected error to contain 'referenced by aliases', got '%s'", err.Error())
+	}
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"os"
+	"sort"
+
+	"github.com/h2co32/gollama/pkg/logging"
+)
+
+// ModelBackend performs the work of bringing models in and out of memory.
+// ModelManager tracks which models are loaded and delegates the loading itself to a backend.
+type ModelBackend interface {
+	// Load maps the model file at path into memory and returns the resident size in bytes.
+	// A version may be loaded while another version of the same model is loaded, such
+	// as when SwapAlias moves an alias between versions.
+	Load(modelName, version, path string) (int64, error)
+
+	// Unload releases the memory held by a model version it loaded.
+	Unload(modelName, version string) error
+}
+
+// fileBackend is the default backend. It does not map anything into memory and
+// reports the size of the model file as its resident size.
+type fileBackend struct{}
+
+// Load reports the model file size as the resident size.
+func (fileBackend) Load(modelName, version, path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// Unload is a no-op for the default backend.
+func (fileBackend) Unload(modelName, version string) error {
+	return nil
+}
+
+// MemoryStats reports resident memory used by loaded models.
+type MemoryStats struct {
+	Budget   int64            // Configured budget in bytes; 0 means unlimited
+	Resident int64            // Total resident bytes across loaded models
+	Models   map[string]int64 // Resident bytes per loaded model
+}
+
+// SetBackend replaces the backend used to load and unload models.
+func (mm *ModelManager) SetBackend(backend ModelBackend) {
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+	mm.backend = backend
+}
+
+// SetMemoryBudget sets the maximum resident memory in bytes for loaded models.
+// When loading a model takes usage over the budget, the least recently used
+// models, by their last load or request recorded with RecordRequest, are unloaded
+// until usage fits. A budget of 0 disables the limit.
+func (mm *ModelManager) SetMemoryBudget(bytes int64) {
+	mm.lock.Lock()
+	mm.memoryBudget = bytes
+	mm.lock.Unlock()
+
+	mm.enforceMemoryBudget("")
+}
+
+// MemoryStats returns the current resident memory usage and budget.
+func (mm *ModelManager) MemoryStats() MemoryStats {
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+
+	stats := MemoryStats{
+		Budget: mm.memoryBudget,
+		Models: make(map[string]int64, len(mm.residentMemory)),
+	}
+	for name, size := range mm.residentMemory {
+		stats.Models[name] = size
+		stats.Resident += size
+	}
+	return stats
+}
+
+// enforceMemoryBudget unloads least recently used models, other than keep, until
+// resident memory fits within the budget. It must be called without holding any locks.
+func (mm *ModelManager) enforceMemoryBudget(keep string) {
+	for _, victim := range mm.evictionCandidates(keep) {
+		if err := mm.UnloadModel(victim); err != nil {
+			logging.Default().Warn("failed to evict model", "model", victim, "error", err)
+		}
+	}
+}
+
+// evictionCandidates returns the least recently used loaded models whose
+// unloading brings resident memory back within the budget. Models whose current
+// version is pinned are never candidates.
+func (mm *ModelManager) evictionCandidates(keep string) []string {
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+
+	if mm.memoryBudget <= 0 {
+		return nil
+	}
+
+	var resident int64
+	var loaded []string
+	for name, size := range mm.residentMemory {
+		resident += size
+		if name != keep && !mm.pinned(name, mm.currentVersion[name]) {
+			loaded = append(loaded, name)
+		}
+	}
+	if resident <= mm.memoryBudget {
+		return nil
+	}
+
+	sort.Slice(loaded, func(i, j int) bool {
+		return mm.lastUsed[loaded[i]].Before(mm.lastUsed[loaded[j]])
+	})
+
+	var victims []string
+	for _, name := range loaded {
+		if resident <= mm.memoryBudget {
+			break
+		}
+		victims = append(victims, name)
+		resident -= mm.residentMemory[name]
+	}
+	return victims
+}
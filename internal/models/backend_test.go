@@ -0,0 +1,231 @@
+package models
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// errTestLoad is returned by test backends that fail to load.
+var errTestLoad = errors.New("out of memory")
+
+// fakeBackend records calls and reports a fixed resident size for every model.
+type fakeBackend struct {
+	mu       sync.Mutex
+	size     int64
+	loadErr  error
+	failOn   string // Version that fails to load with errTestLoad
+	loaded   []string
+	unloaded []string
+}
+
+func (b *fakeBackend) Load(modelName, version, path string) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.loadErr != nil {
+		return 0, b.loadErr
+	}
+	if b.failOn != "" && version == b.failOn {
+		return 0, errTestLoad
+	}
+	b.loaded = append(b.loaded, modelName)
+	return b.size, nil
+}
+
+func (b *fakeBackend) Unload(modelName, version string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.unloaded = append(b.unloaded, modelName)
+	return nil
+}
+
+// backendFunc adapts a load function to a ModelBackend with a no-op Unload.
+type backendFunc func(modelName string) (int64, error)
+
+func (f backendFunc) Load(modelName, version, path string) (int64, error) {
+	return f(modelName)
+}
+
+func (f backendFunc) Unload(modelName, version string) error {
+	return nil
+}
+
+// newTestManager creates a ModelManager with mock model files for each name.
+func newTestManager(t *testing.T, names ...string) *ModelManager {
+	tempDir, err := os.MkdirTemp("", "model-backend-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tempDir) })
+
+	mm := NewModelManager(tempDir)
+	for _, name := range names {
+		modelPath := filepath.Join(tempDir, name+"-v1.0.bin")
+		if err := os.WriteFile(modelPath, []byte("mock model data"), 0644); err != nil {
+			t.Fatalf("Failed to create mock model file: %v", err)
+		}
+		mm.currentVersion[name] = "v1.0"
+	}
+	return mm
+}
+
+func TestBackendLoadUnload(t *testing.T) {
+	mm := newTestManager(t, "model1")
+	backend := &fakeBackend{size: 100}
+	mm.SetBackend(backend)
+
+	if err := mm.LoadModel("model1"); err != nil {
+		t.Fatalf("Failed to load model: %v", err)
+	}
+	if len(backend.loaded) != 1 || backend.loaded[0] != "model1" {
+		t.Errorf("Expected backend to load model1, got %v", backend.loaded)
+	}
+
+	stats := mm.MemoryStats()
+	if stats.Resident != 100 || stats.Models["model1"] != 100 {
+		t.Errorf("Expected 100 resident bytes for model1, got %+v", stats)
+	}
+
+	if err := mm.UnloadModel("model1"); err != nil {
+		t.Fatalf("Failed to unload model: %v", err)
+	}
+	if len(backend.unloaded) != 1 || backend.unloaded[0] != "model1" {
+		t.Errorf("Expected backend to unload model1, got %v", backend.unloaded)
+	}
+	if stats := mm.MemoryStats(); stats.Resident != 0 {
+		t.Errorf("Expected no resident memory after unload, got %d", stats.Resident)
+	}
+}
+
+func TestBackendLoadError(t *testing.T) {
+	mm := newTestManager(t, "model1")
+	mm.SetBackend(&fakeBackend{loadErr: errTestLoad})
+
+	err := mm.LoadModel("model1")
+	if err == nil {
+		t.Fatal("Expected error when the backend fails to load, got nil")
+	}
+	if !strings.Contains(err.Error(), "out of memory") {
+		t.Errorf("Expected error to contain 'out of memory', got '%s'", err.Error())
+	}
+	if mm.loadedModels["model1"] != "" {
+		t.Error("Expected model not to be marked as loaded after a backend failure")
+	}
+}
+
+func TestMemoryBudgetEvictsLeastRecentlyUsed(t *testing.T) {
+	mm := newTestManager(t, "model1", "model2", "model3")
+	backend := &fakeBackend{size: 100}
+	mm.SetBackend(backend)
+	mm.SetMemoryBudget(250)
+
+	for _, name := range []string{"model1", "model2"} {
+		if err := mm.LoadModel(name); err != nil {
+			t.Fatalf("Failed to load model %s: %v", name, err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Loading a third model exceeds the budget, so model1 should be evicted
+	if err := mm.LoadModel("model3"); err != nil {
+		t.Fatalf("Failed to load model3: %v", err)
+	}
+
+	if mm.loadedModels["model1"] != "" {
+		t.Error("Expected least recently used model1 to be evicted")
+	}
+	if mm.loadedModels["model2"] == "" || mm.loadedModels["model3"] == "" {
+		t.Error("Expected model2 and model3 to remain loaded")
+	}
+	if stats := mm.MemoryStats(); stats.Resident != 200 || stats.Budget != 250 {
+		t.Errorf("Expected 200 of 250 resident bytes, got %+v", stats)
+	}
+
+	// Lowering the budget evicts least recently used models immediately
+	mm.SetMemoryBudget(100)
+	if mm.loadedModels["model2"] != "" {
+		t.Error("Expected model2 to be evicted after lowering the budget")
+	}
+	if mm.loadedModels["model3"] == "" {
+		t.Error("Expected model3 to remain loaded after lowering the budget")
+	}
+}
+
+func TestLoadingLoadedModelRefreshesLastUsed(t *testing.T) {
+	mm := newTestManager(t, "model1", "model2", "model3")
+	mm.SetBackend(&fakeBackend{size: 100})
+	mm.SetMemoryBudget(250)
+
+	for _, name := range []string{"model1", "model2", "model1"} {
+		if err := mm.LoadModel(name); err != nil {
+			t.Fatalf("Failed to load model %s: %v", name, err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// model1 was requested again after model2, so model2 is the least recently used
+	if err := mm.LoadModel("model3"); err != nil {
+		t.Fatalf("Failed to load model3: %v", err)
+	}
+	if mm.loadedModels["model1"] == "" || mm.loadedModels["model2"] != "" {
+		t.Error("Expected model2 to be evicted rather than the recently requested model1")
+	}
+}
+
+func TestVersionChangesReloadThroughBackend(t *testing.T) {
+	mm := newTestManager(t, "model1")
+	if err := os.WriteFile(filepath.Join(mm.modelDir, "model1-v0.9.bin"), []byte("old model data"), 0644); err != nil {
+		t.Fatalf("Failed to create mock model file: %v", err)
+	}
+	datasetPath := filepath.Join(mm.modelDir, "dataset.txt")
+	if err := os.WriteFile(datasetPath, []byte("mock dataset data"), 0644); err != nil {
+		t.Fatalf("Failed to create mock dataset file: %v", err)
+	}
+	backend := &blockingBackend{}
+	mm.SetBackend(backend)
+
+	if err := mm.LoadModel("model1"); err != nil {
+		t.Fatalf("Failed to load model: %v", err)
+	}
+
+	// Rolling back a loaded model loads the old version before unloading the new one
+	if err := mm.RollbackModel("model1", "v0.9"); err != nil {
+		t.Fatalf("Failed to roll back model: %v", err)
+	}
+	if err := mm.LoadModel("model1"); err != nil {
+		t.Fatalf("Failed to load model: %v", err)
+	}
+	expected := []string{"load v1.0", "load v0.9", "unload v1.0"}
+	if events := backend.history(); !slices.Equal(events, expected) {
+		t.Errorf("Expected %v after the rollback, got %v", expected, events)
+	}
+	if mm.loadedModels["model1"] != "v0.9" {
+		t.Errorf("Expected v0.9 to be loaded, got %q", mm.loadedModels["model1"])
+	}
+
+	// So does fine-tuning, and unloading then releases the version that is loaded
+	if err := mm.FineTuneModel("model1", datasetPath); err != nil {
+		t.Fatalf("Failed to fine-tune model: %v", err)
+	}
+	fineTuned := mm.currentVersion["model1"]
+	if err := mm.UnloadModel("model1"); err != nil {
+		t.Fatalf("Failed to unload model: %v", err)
+	}
+	expected = append(expected, "load "+fineTuned, "unload v0.9", "unload "+fineTuned)
+	if events := backend.history(); !slices.Equal(events, expected) {
+		t.Errorf("Expected %v after fine-tuning and unloading, got %v", expected, events)
+	}
+
+	// Versions that are not loaded stay unloaded when they become current
+	if err := mm.RollbackModel("model1", "v1.0"); err != nil {
+		t.Fatalf("Failed to roll back model: %v", err)
+	}
+	if events := backend.history(); !slices.Equal(events, expected) {
+		t.Errorf("Expected no backend calls rolling back an unloaded model, got %v", events)
+	}
+}
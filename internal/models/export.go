@@ -0,0 +1,225 @@
+package models
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/h2co32/gollama/pkg/logging"
+)
+
+// Entry names used inside export archives.
+const (
+	exportMetadataEntry = "metadata.json"
+	exportModelEntry    = "model.bin"
+)
+
+// exportMetadata is stored alongside the model binary in an export archive.
+type exportMetadata struct {
+	Name    string         `json:"name"`
+	Version string         `json:"version"`
+	Record  *VersionRecord `json:"record"`
+}
+
+// ExportModel writes a version of a model and its manifest metadata to w as a tar.gz
+// archive that can be restored with ImportModel, e.g. in an air-gapped environment.
+func (mm *ModelManager) ExportModel(modelName, version string, w io.Writer) error {
+	defer mm.lockModel(modelName)()
+
+	modelPath := filepath.Join(mm.modelDir, modelName+"-"+version+".bin")
+	file, err := os.Open(modelPath)
+	if err != nil {
+		return fmt.Errorf("failed to open model file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat model file: %w", err)
+	}
+
+	mm.lock.Lock()
+	var record VersionRecord
+	if r := mm.versions[modelName][version]; r != nil {
+		record = *r
+	}
+	mm.lock.Unlock()
+
+	// Versions that predate the manifest have no digest yet
+	if record.Digest == "" {
+		digest, size, err := fileDigest(modelPath)
+		if err != nil {
+			return fmt.Errorf("failed to checksum model file: %w", err)
+		}
+		record.Digest = digest
+		record.Size = size
+	}
+
+	metadata, err := json.MarshalIndent(exportMetadata{Name: modelName, Version: version, Record: &record}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal export metadata: %w", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    exportMetadataEntry,
+		Mode:    0644,
+		Size:    int64(len(metadata)),
+		ModTime: now,
+	}); err != nil {
+		return fmt.Errorf("failed to write export archive: %w", err)
+	}
+	if _, err := tw.Write(metadata); err != nil {
+		return fmt.Errorf("failed to write export archive: %w", err)
+	}
+
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    exportModelEntry,
+		Mode:    0644,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}); err != nil {
+		return fmt.Errorf("failed to write export archive: %w", err)
+	}
+	if _, err := io.Copy(tw, file); err != nil {
+		return fmt.Errorf("failed to write export archive: %w", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write export archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to write export archive: %w", err)
+	}
+
+	logging.Default().Info("exported model", "model", modelName, "version", version)
+	return nil
+}
+
+// ImportModel restores a model exported with ExportModel. The model binary is verified
+// against the digest in the archive before it is stored. The imported version becomes
+// the model's current version only if the model has no current version yet. A pinned
+// version cannot be replaced by an import; pins are not carried over from the archive.
+// The signature in the archive is verified against the SignaturePolicy, and a strict
+// policy refuses archives without one.
+func (mm *ModelManager) ImportModel(r io.Reader) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to read export archive: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	// The metadata entry is written first so the destination is known before the binary
+	header, err := tr.Next()
+	if err != nil {
+		return fmt.Errorf("failed to read export archive: %w", err)
+	}
+	if header.Name != exportMetadataEntry {
+		return fmt.Errorf("invalid export archive: expected %s, got %s", exportMetadataEntry, header.Name)
+	}
+
+	var metadata exportMetadata
+	if err := json.NewDecoder(tr).Decode(&metadata); err != nil {
+		return fmt.Errorf("failed to unmarshal export metadata: %w", err)
+	}
+	if !validModelComponent(metadata.Name) || !validModelComponent(metadata.Version) {
+		return fmt.Errorf("invalid export archive: bad model name or version %q/%q", metadata.Name, metadata.Version)
+	}
+	if metadata.Record == nil {
+		metadata.Record = &VersionRecord{}
+	}
+
+	header, err = tr.Next()
+	if err != nil {
+		return fmt.Errorf("failed to read export archive: %w", err)
+	}
+	if header.Name != exportModelEntry {
+		return fmt.Errorf("invalid export archive: expected %s, got %s", exportModelEntry, header.Name)
+	}
+
+	defer mm.lockModel(metadata.Name)()
+
+	mm.lock.Lock()
+	pinned := mm.pinned(metadata.Name, metadata.Version)
+	mm.lock.Unlock()
+	if pinned {
+		return fmt.Errorf("failed to import model: %w: %s version %s", ErrPinned, metadata.Name, metadata.Version)
+	}
+
+	modelPath := filepath.Join(mm.modelDir, metadata.Name+"-"+metadata.Version+".bin")
+	tmpPath := modelPath + ".part"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to save model file: %w", err)
+	}
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, h), tr)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to save model file: %w", err)
+	}
+
+	digest := hex.EncodeToString(h.Sum(nil))
+	if metadata.Record.Digest != "" && metadata.Record.Digest != digest {
+		os.Remove(tmpPath)
+		return fmt.Errorf("digest mismatch for model %s (version %s): expected %s, got %s",
+			metadata.Name, metadata.Version, metadata.Record.Digest, digest)
+	}
+
+	mm.lock.Lock()
+	policy := mm.signaturePolicy
+	mm.lock.Unlock()
+	publisher, err := policy.verify(digest, metadata.Record.Signature)
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to import model %s version %s: %w", metadata.Name, metadata.Version, err)
+	}
+
+	if err := os.Rename(tmpPath, modelPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to save model file: %w", err)
+	}
+
+	record := *metadata.Record
+	record.Digest = digest
+	record.Size = size
+	record.Publisher = publisher
+	record.Pinned = false
+	if record.DownloadedAt.IsZero() {
+		record.DownloadedAt = time.Now().UTC()
+	}
+
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+	mm.recordVersion(metadata.Name, metadata.Version, &record)
+	if _, ok := mm.currentVersion[metadata.Name]; !ok {
+		mm.currentVersion[metadata.Name] = metadata.Version
+	}
+	if err := mm.saveManifest(); err != nil {
+		return err
+	}
+
+	logging.Default().Info("imported model", "model", metadata.Name, "version", metadata.Version, "publisher", publisher)
+	return nil
+}
+
+// validModelComponent reports whether s is safe to use as a model name or version in a file name.
+func validModelComponent(s string) bool {
+	return s != "" && s != "." && s != ".." && !strings.ContainsAny(s, `/\`)
+}
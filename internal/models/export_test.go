@@ -0,0 +1,118 @@
+package models
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportImportModel(t *testing.T) {
+	source := newTestManager(t, "test-model")
+	source.recordVersion("test-model", "v1.0", &VersionRecord{
+		FineTune: &FineTuneRecord{Dataset: "dataset.txt"},
+	})
+
+	var archive bytes.Buffer
+	if err := source.ExportModel("test-model", "v1.0", &archive); err != nil {
+		t.Fatalf("Failed to export model: %v", err)
+	}
+
+	target := newTestManager(t)
+	if err := target.ImportModel(bytes.NewReader(archive.Bytes())); err != nil {
+		t.Fatalf("Failed to import model: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(target.modelDir, "test-model-v1.0.bin"))
+	if err != nil {
+		t.Fatalf("Failed to read imported model: %v", err)
+	}
+	if string(data) != "mock model data" {
+		t.Errorf("Expected imported model data 'mock model data', got '%s'", data)
+	}
+
+	if target.currentVersion["test-model"] != "v1.0" {
+		t.Errorf("Expected imported version to become current, got '%s'", target.currentVersion["test-model"])
+	}
+
+	record := target.Manifest().Models["test-model"].Versions["v1.0"]
+	if record == nil || record.Digest == "" || record.Size != int64(len(data)) {
+		t.Fatalf("Expected digest and size to be recorded, got %+v", record)
+	}
+	if record.FineTune == nil || record.FineTune.Dataset != "dataset.txt" {
+		t.Errorf("Expected fine-tune provenance to be carried over, got %+v", record.FineTune)
+	}
+}
+
+func TestExportMissingModel(t *testing.T) {
+	mm := newTestManager(t)
+
+	var archive bytes.Buffer
+	err := mm.ExportModel("missing-model", "v1.0", &archive)
+	if err == nil {
+		t.Fatal("Expected error when exporting a missing model, got nil")
+	}
+	if !strings.Contains(err.Error(), "failed to open model file") {
+		t.Errorf("Expected error to contain 'failed to open model file', got '%s'", err.Error())
+	}
+}
+
+// buildArchive creates an export archive with the given metadata and model data.
+func buildArchive(t *testing.T, metadata exportMetadata, model []byte) []byte {
+	meta, err := json.Marshal(metadata)
+	if err != nil {
+		t.Fatalf("Failed to marshal metadata: %v", err)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, entry := range []struct {
+		name string
+		data []byte
+	}{{exportMetadataEntry, meta}, {exportModelEntry, model}} {
+		if err := tw.WriteHeader(&tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.data))}); err != nil {
+			t.Fatalf("Failed to write header: %v", err)
+		}
+		if _, err := tw.Write(entry.data); err != nil {
+			t.Fatalf("Failed to write entry: %v", err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestImportModelRejectsBadArchives(t *testing.T) {
+	mm := newTestManager(t)
+
+	// A tampered binary fails digest verification
+	tampered := buildArchive(t, exportMetadata{
+		Name:    "test-model",
+		Version: "v1.0",
+		Record:  &VersionRecord{Digest: "0000"},
+	}, []byte("mock model data"))
+	err := mm.ImportModel(bytes.NewReader(tampered))
+	if err == nil || !strings.Contains(err.Error(), "digest mismatch") {
+		t.Errorf("Expected digest mismatch error, got %v", err)
+	}
+
+	// Names that would escape the model directory are rejected
+	escaping := buildArchive(t, exportMetadata{Name: "../evil", Version: "v1.0"}, []byte("data"))
+	err = mm.ImportModel(bytes.NewReader(escaping))
+	if err == nil || !strings.Contains(err.Error(), "bad model name") {
+		t.Errorf("Expected bad model name error, got %v", err)
+	}
+
+	models, err := mm.ListModels(ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed to list models: %v", err)
+	}
+	if len(models) != 0 {
+		t.Errorf("Expected no models after rejected imports, got %v", models)
+	}
+}
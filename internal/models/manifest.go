@@ -0,0 +1,228 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// manifestFileName is the name of the manifest file stored in the model directory.
+const manifestFileName = "manifest.json"
+
+// Manifest is the persisted record of every model a ModelManager knows about.
+type Manifest struct {
+	Models  map[string]*ModelRecord `json:"models"`
+	Aliases map[string]AliasTarget  `json:"aliases,omitempty"`
+}
+
+// ModelRecord describes a single model, its active version, and its stored versions.
+type ModelRecord struct {
+	CurrentVersion  string                    `json:"current_version,omitempty"`
+	Loaded          bool                      `json:"loaded"`
+	FineTuneDataset string                    `json:"fine_tune_dataset,omitempty"`
+	LastUsed        time.Time                 `json:"last_used"`
+	Requests        int64                     `json:"requests,omitempty"`
+	RequestTime     time.Duration             `json:"request_time,omitempty"` // Total latency of the requests in nanoseconds
+	Versions        map[string]*VersionRecord `json:"versions,omitempty"`
+}
+
+// VersionRecord describes one stored version of a model.
+type VersionRecord struct {
+	Digest       string          `json:"digest"` // Hex-encoded SHA-256 of the model file
+	Size         int64           `json:"size"`
+	DownloadedAt time.Time       `json:"downloaded_at"`
+	FineTune     *FineTuneRecord `json:"fine_tune,omitempty"`
+	Signature    string          `json:"signature,omitempty"` // Detached publisher signature; see SignaturePolicy
+	Publisher    string          `json:"publisher,omitempty"` // Trusted publisher whose key verified Signature
+	Pinned       bool            `json:"pinned,omitempty"`    // Protected from deletion and eviction; see PinModel
+}
+
+// FineTuneRecord captures the provenance of a fine-tuned model version.
+type FineTuneRecord struct {
+	BaseVersion   string    `json:"base_version,omitempty"`
+	Dataset       string    `json:"dataset"`
+	DatasetDigest string    `json:"dataset_digest"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Manifest returns a snapshot of the manager's current manifest.
+func (mm *ModelManager) Manifest() Manifest {
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+	return mm.buildManifest()
+}
+
+// buildManifest assembles a Manifest from the in-memory state.
+// This method is not thread-safe and should be called with the lock held.
+func (mm *ModelManager) buildManifest() Manifest {
+	manifest := Manifest{Models: make(map[string]*ModelRecord)}
+	record := func(name string) *ModelRecord {
+		if r, ok := manifest.Models[name]; ok {
+			return r
+		}
+		r := &ModelRecord{}
+		manifest.Models[name] = r
+		return r
+	}
+
+	for name, version := range mm.currentVersion {
+		record(name).CurrentVersion = version
+	}
+	for name := range mm.loadedModels {
+		record(name).Loaded = true
+	}
+	for name, dataset := range mm.fineTuningData {
+		record(name).FineTuneDataset = dataset
+	}
+	for name, lastUsed := range mm.lastUsed {
+		record(name).LastUsed = lastUsed
+	}
+	for name, usage := range mm.usage {
+		r := record(name)
+		r.Requests, r.RequestTime = usage.requests, usage.requestTime
+	}
+	for name, versions := range mm.versions {
+		if len(versions) == 0 {
+			continue
+		}
+		r := record(name)
+		r.Versions = make(map[string]*VersionRecord, len(versions))
+		for version, info := range versions {
+			copied := *info
+			r.Versions[version] = &copied
+		}
+	}
+	if len(mm.aliases) > 0 {
+		manifest.Aliases = make(map[string]AliasTarget, len(mm.aliases))
+		for alias, target := range mm.aliases {
+			manifest.Aliases[alias] = target
+		}
+	}
+	return manifest
+}
+
+// loadManifest restores the in-memory state from the manifest file, if one exists.
+func (mm *ModelManager) loadManifest() error {
+	data, err := os.ReadFile(filepath.Join(mm.modelDir, manifestFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("failed to unmarshal manifest: %w", err)
+	}
+
+	for alias, target := range manifest.Aliases {
+		mm.aliases[alias] = target
+	}
+
+	for name, r := range manifest.Models {
+		if r == nil {
+			continue
+		}
+		if r.CurrentVersion != "" {
+			mm.currentVersion[name] = r.CurrentVersion
+		}
+		// Nothing is in memory in a new process, so models recorded as loaded are
+		// only candidates for preloading
+		if r.Loaded && r.CurrentVersion != "" {
+			mm.previousLoaded = append(mm.previousLoaded, name)
+		}
+		if r.FineTuneDataset != "" {
+			mm.fineTuningData[name] = r.FineTuneDataset
+		}
+		if !r.LastUsed.IsZero() {
+			mm.lastUsed[name] = r.LastUsed
+		}
+		if r.Requests > 0 {
+			mm.usage[name] = &modelUsage{requests: r.Requests, requestTime: r.RequestTime}
+		}
+		if len(r.Versions) > 0 {
+			mm.versions[name] = r.Versions
+		}
+	}
+	sort.Strings(mm.previousLoaded)
+	return nil
+}
+
+// PreviouslyLoaded returns the models that were loaded when the manifest restored by
+// NewModelManager was last saved. They are not loaded in this ModelManager; pass them
+// to Preload once the backend is set to load them again.
+func (mm *ModelManager) PreviouslyLoaded() []string {
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+	return append([]string(nil), mm.previousLoaded...)
+}
+
+// saveManifest atomically writes the manifest to the model directory by writing
+// a temporary file and renaming it over the previous manifest.
+// This method is not thread-safe and should be called with the lock held.
+func (mm *ModelManager) saveManifest() error {
+	data, err := json.MarshalIndent(mm.buildManifest(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	manifestPath := filepath.Join(mm.modelDir, manifestFileName)
+	tmp, err := os.CreateTemp(mm.modelDir, manifestFileName+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to persist manifest: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to persist manifest: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to persist manifest: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to persist manifest: %w", err)
+	}
+	if err := os.Rename(tmpPath, manifestPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to persist manifest: %w", err)
+	}
+	return nil
+}
+
+// recordVersion stores metadata for a model version.
+// This method is not thread-safe and should be called with the lock held.
+func (mm *ModelManager) recordVersion(modelName, version string, info *VersionRecord) {
+	if mm.versions[modelName] == nil {
+		mm.versions[modelName] = make(map[string]*VersionRecord)
+	}
+	mm.versions[modelName][version] = info
+}
+
+// fileDigest returns the hex-encoded SHA-256 digest and size of the file at path.
+func fileDigest(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, file)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManifestPersistence(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "model-manifest-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	mm := NewModelManager(tempDir)
+
+	// Create a mock model file and load it
+	modelName := "test-model"
+	version := "v1.0"
+	modelPath := filepath.Join(tempDir, modelName+"-"+version+".bin")
+	if err := os.WriteFile(modelPath, []byte("mock model data"), 0644); err != nil {
+		t.Fatalf("Failed to create mock model file: %v", err)
+	}
+	if err := mm.RollbackModel(modelName, version); err != nil {
+		t.Fatalf("Failed to set model version: %v", err)
+	}
+	if err := mm.LoadModel(modelName); err != nil {
+		t.Fatalf("Failed to load model: %v", err)
+	}
+
+	// Fine-tune the model so the manifest records provenance
+	datasetPath := filepath.Join(tempDir, "dataset.txt")
+	if err := os.WriteFile(datasetPath, []byte("mock dataset data"), 0644); err != nil {
+		t.Fatalf("Failed to create mock dataset file: %v", err)
+	}
+	if err := mm.FineTuneModel(modelName, datasetPath); err != nil {
+		t.Fatalf("Failed to fine-tune model: %v", err)
+	}
+	fineTunedVersion := mm.currentVersion[modelName]
+
+	// The manifest file should be valid JSON
+	data, err := os.ReadFile(filepath.Join(tempDir, manifestFileName))
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	var onDisk Manifest
+	if err := json.Unmarshal(data, &onDisk); err != nil {
+		t.Fatalf("Failed to unmarshal manifest: %v", err)
+	}
+
+	// A new manager over the same directory should restore the state
+	restored := NewModelManager(tempDir)
+
+	if restored.currentVersion[modelName] != fineTunedVersion {
+		t.Errorf("Expected restored version '%s', got '%s'", fineTunedVersion, restored.currentVersion[modelName])
+	}
+	// Nothing is in memory after a restart, so loaded models are only preload candidates
+	if len(restored.loadedModels) != 0 || len(restored.residentMemory) != 0 {
+		t.Errorf("Expected no models to be restored as loaded, got %v", restored.loadedModels)
+	}
+	if previous := restored.PreviouslyLoaded(); len(previous) != 1 || previous[0] != modelName {
+		t.Errorf("Expected model '%s' to be a preload candidate, got %v", modelName, previous)
+	}
+	backend := &fakeBackend{size: 10}
+	restored.SetBackend(backend)
+	if err := restored.LoadModel(modelName); err != nil {
+		t.Fatalf("Failed to load restored model: %v", err)
+	}
+	if len(backend.loaded) != 1 {
+		t.Errorf("Expected the restored model to be loaded through the backend, got loads %v", backend.loaded)
+	}
+	if restored.fineTuningData[modelName] != datasetPath {
+		t.Errorf("Expected restored fine-tuning dataset '%s', got '%s'", datasetPath, restored.fineTuningData[modelName])
+	}
+
+	record := restored.Manifest().Models[modelName]
+	if record == nil {
+		t.Fatalf("Expected manifest record for model '%s'", modelName)
+	}
+	info := record.Versions[fineTunedVersion]
+	if info == nil || info.FineTune == nil {
+		t.Fatalf("Expected fine-tune provenance for version '%s'", fineTunedVersion)
+	}
+	if info.FineTune.BaseVersion != version {
+		t.Errorf("Expected base version '%s', got '%s'", version, info.FineTune.BaseVersion)
+	}
+	if info.Digest == "" || info.Size != int64(len("mock dataset data")) {
+		t.Errorf("Expected digest and size to be recorded, got digest '%s' and size %d", info.Digest, info.Size)
+	}
+}
+
+func TestManifestDeleteModel(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "model-manifest-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	mm := NewModelManager(tempDir)
+
+	modelName := "test-model"
+	version := "v1.0"
+	modelPath := filepath.Join(tempDir, modelName+"-"+version+".bin")
+	if err := os.WriteFile(modelPath, []byte("mock model data"), 0644); err != nil {
+		t.Fatalf("Failed to create mock model file: %v", err)
+	}
+	mm.currentVersion[modelName] = version
+	mm.recordVersion(modelName, version, &VersionRecord{Size: 15})
+
+	if err := mm.DeleteModel(modelName, version); err != nil {
+		t.Fatalf("Failed to delete model: %v", err)
+	}
+
+	// The deleted model should not come back after a restart
+	restored := NewModelManager(tempDir)
+	if _, ok := restored.currentVersion[modelName]; ok {
+		t.Errorf("Expected model '%s' to be absent after restart", modelName)
+	}
+	if _, ok := restored.Manifest().Models[modelName]; ok {
+		t.Errorf("Expected no manifest record for deleted model '%s'", modelName)
+	}
+}
+
+func TestManifestCorrupt(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "model-manifest-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := os.WriteFile(filepath.Join(tempDir, manifestFileName), []byte("{not json"), 0644); err != nil {
+		t.Fatalf("Failed to write corrupt manifest: %v", err)
+	}
+
+	// A corrupt manifest should not prevent the manager from starting
+	mm := NewModelManager(tempDir)
+	if len(mm.currentVersion) != 0 {
+		t.Errorf("Expected empty state from a corrupt manifest, got %v", mm.currentVersion)
+	}
+}
@@ -1,210 +1,979 @@
-package models
-
-import (
-	"fmt"
-	"io/ioutil"
-	"net/http"
-	"os"
-	"path/filepath"
-	"sync"
-	"time"
-)
-
-// ModelManager handles downloading, loading, unloading, versioning, and fine-tuning models.
-type ModelManager struct {
-	modelDir       string            // Directory to store downloaded models
-	currentVersion map[string]string // Map of model names to their current versions
-	loadedModels   map[string]bool   // Tracks which models are currently loaded
-	fineTuningData map[string]string // Maps models to fine-tuning datasets
-	preloadQueue   []string          // Queue for preloading models
-	lock           sync.Mutex        // Mutex for concurrent access
-}
-
-// NewModelManager initializes a new ModelManager with the specified model storage directory.
-func NewModelManager(modelDir string) *ModelManager {
-	if err := os.MkdirAll(modelDir, 0755); err != nil {
-		fmt.Printf("Warning: failed to create model directory: %v\n", err)
-	}
-	return &ModelManager{
-		modelDir:       modelDir,
-		currentVersion: make(map[string]string),
-		loadedModels:   make(map[string]bool),
-		fineTuningData: make(map[string]string),
-	}
-}
-
-// DownloadModel downloads a specific version of the model and saves it locally.
-func (mm *ModelManager) DownloadModel(modelName, version string) error {
-	mm.lock.Lock()
-	defer mm.lock.Unlock()
-
-	modelPath := filepath.Join(mm.modelDir, modelName+"-"+version+".bin")
-
-	// Check if model already exists
-	if _, err := os.Stat(modelPath); err == nil {
-		fmt.Printf("Model %s (version %s) already downloaded.\n", modelName, version)
-		return nil
-	}
-
-	// Mock URL for model download
-	modelURL := fmt.Sprintf("https://models.example.com/%s/%s.bin", modelName, version)
-	fmt.Printf("Downloading model from %s\n", modelURL)
-
-	// Simulate downloading model
-	res, err := http.Get(modelURL)
-	if err != nil {
-		return fmt.Errorf("failed to download model: %w", err)
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download model: server returned %d", res.StatusCode)
-	}
-
-	// Save model to file
-	data, _ := ioutil.ReadAll(res.Body)
-	if err := ioutil.WriteFile(modelPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to save model file: %w", err)
-	}
-
-	mm.currentVersion[modelName] = version
-	fmt.Printf("Downloaded model %s (version %s).\n", modelName, version)
-	return nil
-}
-
-// LoadModel loads a model into memory for faster inference.
-func (mm *ModelManager) LoadModel(modelName string) error {
-	mm.lock.Lock()
-	defer mm.lock.Unlock()
-
-	if mm.loadedModels[modelName] {
-		fmt.Printf("Model %s is already loaded.\n", modelName)
-		return nil
-	}
-
-	version, ok := mm.currentVersion[modelName]
-	if !ok {
-		return fmt.Errorf("model %s not found", modelName)
-	}
-
-	modelPath := filepath.Join(mm.modelDir, modelName+"-"+version+".bin")
-	if _, err := os.Stat(modelPath); err != nil {
-		return fmt.Errorf("model file not found: %s", modelPath)
-	}
-
-	// Simulate loading the model
-	fmt.Printf("Loading model %s (version %s) into memory.\n", modelName, version)
-	mm.loadedModels[modelName] = true
-	return nil
-}
-
-// UnloadModel removes a model from memory to free resources.
-func (mm *ModelManager) UnloadModel(modelName string) error {
-	mm.lock.Lock()
-	defer mm.lock.Unlock()
-
-	if !mm.loadedModels[modelName] {
-		return fmt.Errorf("model %s is not loaded", modelName)
-	}
-
-	// Simulate unloading the model
-	fmt.Printf("Unloading model %s from memory.\n", modelName)
-	delete(mm.loadedModels, modelName)
-	return nil
-}
-
-// FineTuneModel fine-tunes a model with a specific dataset and stores the fine-tuned model version.
-func (mm *ModelManager) FineTuneModel(modelName, datasetPath string) error {
-	mm.lock.Lock()
-	defer mm.lock.Unlock()
-
-	fmt.Printf("Fine-tuning model %s with dataset at %s.\n", modelName, datasetPath)
-	fineTunedVersion := modelName + "-ft-" + time.Now().Format("20060102150405")
-	fineTunedModelPath := filepath.Join(mm.modelDir, fineTunedVersion+".bin")
-
-	// Simulate fine-tuning and saving the new model version
-	data, err := ioutil.ReadFile(datasetPath)
-	if err != nil {
-		return fmt.Errorf("failed to read fine-tuning dataset: %w", err)
-	}
-
-	if err := ioutil.WriteFile(fineTunedModelPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to save fine-tuned model: %w", err)
-	}
-
-	mm.currentVersion[modelName] = fineTunedVersion
-	mm.fineTuningData[modelName] = datasetPath
-	fmt.Printf("Fine-tuned model saved as %s.\n", fineTunedVersion)
-	return nil
-}
-
-// PreloadModels preloads multiple models asynchronously.
-func (mm *ModelManager) PreloadModels(models []string) {
-	mm.lock.Lock()
-	mm.preloadQueue = models
-	mm.lock.Unlock()
-
-	fmt.Println("Starting model preload...")
-	var wg sync.WaitGroup
-	for _, modelName := range models {
-		wg.Add(1)
-		go func(model string) {
-			defer wg.Done()
-			if err := mm.LoadModel(model); err != nil {
-				fmt.Printf("Failed to preload model %s: %v\n", model, err)
-			}
-		}(modelName)
-	}
-	wg.Wait()
-	fmt.Println("Model preloading complete.")
-}
-
-// RollbackModel reverts a model to a previous version if available.
-func (mm *ModelManager) RollbackModel(modelName, previousVersion string) error {
-	mm.lock.Lock()
-	defer mm.lock.Unlock()
-
-	modelPath := filepath.Join(mm.modelDir, modelName+"-"+previousVersion+".bin")
-	if _, err := os.Stat(modelPath); os.IsNotExist(err) {
-		return fmt.Errorf("previous version %s for model %s not found", previousVersion, modelName)
-	}
-
-	mm.currentVersion[modelName] = previousVersion
-	fmt.Printf("Rolled back model %s to version %s.\n", modelName, previousVersion)
-	return nil
-}
-
-// DeleteModel removes a model file from storage.
-func (mm *ModelManager) DeleteModel(modelName, version string) error {
-	mm.lock.Lock()
-	defer mm.lock.Unlock()
-
-	modelPath := filepath.Join(mm.modelDir, modelName+"-"+version+".bin")
-	if err := os.Remove(modelPath); err != nil {
-		return fmt.Errorf("failed to delete model: %w", err)
-	}
-
-	if mm.currentVersion[modelName] == version {
-		delete(mm.currentVersion, modelName)
-		delete(mm.loadedModels, modelName)
-	}
-
-	fmt.Printf("Deleted model %s (version %s) from storage.\n", modelName, version)
-	return nil
-}
-
-// ListModels returns a list of all models currently available in storage.
-func (mm *ModelManager) ListModels() ([]string, error) {
-	files, err := ioutil.ReadDir(mm.modelDir)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list models: %w", err)
-	}
-
-	var models []string
-	for _, file := range files {
-		if filepath.Ext(file.Name()) == ".bin" {
-			models = append(models, file.Name())
-		}
-	}
-	return models, nil
-}
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/h2co32/gollama/internal/metrics"
+	"github.com/h2co32/gollama/pkg/logging"
+	"github.com/h2co32/gollama/pkg/retry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// defaultRegistryURL is the base URL models are downloaded from.
+const defaultRegistryURL = "https://models.example.com"
+
+// tracerName is the instrumentation name of the default tracer.
+const tracerName = "github.com/h2co32/gollama/internal/models"
+
+// DownloadOptions configures how model files are fetched from the registry.
+type DownloadOptions struct {
+	// PartSize is the size in bytes of each chunk downloaded in parallel.
+	// Files no larger than PartSize are fetched in a single stream.
+	// Default: 64MB
+	PartSize int64
+
+	// Concurrency is the maximum number of parts downloaded at once.
+	// A value of 1 disables multi-part downloads.
+	// Default: 4
+	Concurrency int
+
+	// PartRetries is the number of attempts made for each part before the download fails.
+	// Default: 3
+	PartRetries int
+}
+
+// DefaultDownloadOptions returns the default download options.
+func DefaultDownloadOptions() DownloadOptions {
+	return DownloadOptions{
+		PartSize:    64 << 20,
+		Concurrency: 4,
+		PartRetries: 3,
+	}
+}
+
+// ModelManager handles downloading, loading, unloading, versioning, and fine-tuning models.
+type ModelManager struct {
+	modelDir        string                               // Directory to store downloaded models
+	registryURL     string                               // Base URL of the model registry
+	httpClient      *http.Client                         // Client used for registry requests
+	downloadOptions DownloadOptions                      // Multi-part download settings
+	currentVersion  map[string]string                    // Map of model names to their current versions
+	loadedModels    map[string]string                    // Version of each model loaded in the backend
+	fineTuningData  map[string]string                    // Maps models to fine-tuning datasets
+	versions        map[string]map[string]*VersionRecord // Metadata for each stored model version
+	lastUsed        map[string]time.Time                 // When each model was last loaded or served a request
+	usage           map[string]*modelUsage               // Requests served by each model and their latency
+	preloadQueue    []string                             // Queue for preloading models
+	previousLoaded  []string                             // Models the restored manifest recorded as loaded; see PreviouslyLoaded
+	modelLocks      map[string]*modelLock                // Per-model locks serializing operations on one model
+	backend         ModelBackend                         // Loads and unloads models
+	memoryBudget    int64                                // Maximum resident bytes for loaded models; 0 is unlimited
+	residentMemory  map[string]int64                     // Resident bytes reported for each loaded model
+	aliases         map[string]AliasTarget               // Stable names pointing at model versions
+	signaturePolicy SignaturePolicy                      // Publisher keys downloads and imports are verified against
+	tracer          trace.Tracer                         // Starts spans for downloads and loads
+	metrics         *metrics.MetricsProvider             // Receives model usage; optional
+	lock            sync.Mutex                           // Guards the shared maps and the manifest file
+}
+
+// modelLock is a reference-counted mutex serializing operations on a single model.
+type modelLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// NewModelManager initializes a new ModelManager with the specified model storage directory.
+// State recorded in the directory's manifest by a previous ModelManager is restored.
+func NewModelManager(modelDir string) *ModelManager {
+	if err := os.MkdirAll(modelDir, 0755); err != nil {
+		logging.Default().Warn("failed to create model directory", "dir", modelDir, "error", err)
+	}
+	mm := &ModelManager{
+		modelDir:        modelDir,
+		registryURL:     defaultRegistryURL,
+		httpClient:      http.DefaultClient,
+		downloadOptions: DefaultDownloadOptions(),
+		currentVersion:  make(map[string]string),
+		loadedModels:    make(map[string]string),
+		fineTuningData:  make(map[string]string),
+		versions:        make(map[string]map[string]*VersionRecord),
+		lastUsed:        make(map[string]time.Time),
+		usage:           make(map[string]*modelUsage),
+		modelLocks:      make(map[string]*modelLock),
+		backend:         fileBackend{},
+		residentMemory:  make(map[string]int64),
+		aliases:         make(map[string]AliasTarget),
+		tracer:          otel.Tracer(tracerName),
+	}
+	if err := mm.loadManifest(); err != nil {
+		logging.Default().Warn("failed to load model manifest", "dir", modelDir, "error", err)
+	}
+	return mm
+}
+
+// SetDownloadOptions configures multi-part downloading for subsequent calls to DownloadModel.
+// Zero values fall back to the defaults.
+func (mm *ModelManager) SetDownloadOptions(opts DownloadOptions) {
+	defaults := DefaultDownloadOptions()
+	if opts.PartSize <= 0 {
+		opts.PartSize = defaults.PartSize
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaults.Concurrency
+	}
+	if opts.PartRetries <= 0 {
+		opts.PartRetries = defaults.PartRetries
+	}
+
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+	mm.downloadOptions = opts
+}
+
+// SetTracer replaces the tracer that starts a span for each download and load, which
+// defaults to the global trace provider's. A nil tracer disables the spans.
+func (mm *ModelManager) SetTracer(tracer trace.Tracer) {
+	if tracer == nil {
+		tracer = noop.NewTracerProvider().Tracer(tracerName)
+	}
+
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+	mm.tracer = tracer
+}
+
+// startSpan starts a span named name for an operation on modelName.
+func (mm *ModelManager) startSpan(ctx context.Context, name, modelName string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	mm.lock.Lock()
+	tracer := mm.tracer
+	mm.lock.Unlock()
+
+	attrs = append([]attribute.KeyValue{attribute.String("model.name", modelName)}, attrs...)
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records err, if any, on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// lockModel acquires the lock for modelName and returns a function that releases it.
+// Operations on different models hold different locks and so do not contend; the
+// shared lock is only held briefly while reading or updating the manager's state.
+func (mm *ModelManager) lockModel(modelName string) func() {
+	mm.lock.Lock()
+	ml, ok := mm.modelLocks[modelName]
+	if !ok {
+		ml = &modelLock{}
+		mm.modelLocks[modelName] = ml
+	}
+	ml.refs++
+	mm.lock.Unlock()
+
+	ml.mu.Lock()
+	return func() {
+		ml.mu.Unlock()
+
+		mm.lock.Lock()
+		ml.refs--
+		if ml.refs == 0 {
+			delete(mm.modelLocks, modelName)
+		}
+		mm.lock.Unlock()
+	}
+}
+
+// DownloadModel downloads a specific version of the model and saves it locally.
+func (mm *ModelManager) DownloadModel(modelName, version string) error {
+	return mm.DownloadModelContext(context.Background(), modelName, version)
+}
+
+// DownloadModelContext is like DownloadModel but cancels the download when ctx is
+// done, and records it in a "model.download" span that is a child of the span in ctx.
+func (mm *ModelManager) DownloadModelContext(ctx context.Context, modelName, version string) error {
+	return mm.DownloadModelProgress(ctx, modelName, version, nil)
+}
+
+// DownloadModelProgress is like DownloadModelContext but reports the progress of the
+// download to progress, if not nil, twice a second and once more when it completes. A
+// version that is already downloaded is reported as done right away.
+//
+// When a SignaturePolicy with trusted keys is set, the model's detached signature is
+// fetched from the registry and verified before the file is installed.
+func (mm *ModelManager) DownloadModelProgress(ctx context.Context, modelName, version string, progress DownloadProgressFunc) (err error) {
+	ctx, span := mm.startSpan(ctx, "model.download", modelName, attribute.String("model.version", version))
+	defer func() { endSpan(span, err) }()
+
+	defer mm.lockModel(modelName)()
+
+	modelPath := filepath.Join(mm.modelDir, modelName+"-"+version+".bin")
+
+	mm.lock.Lock()
+	modelURL := fmt.Sprintf("%s/%s/%s.bin", mm.registryURL, modelName, version)
+	opts := mm.downloadOptions
+	policy := mm.signaturePolicy
+	mm.lock.Unlock()
+
+	// Check if model already exists
+	if info, err := os.Stat(modelPath); err == nil {
+		span.SetAttributes(attribute.Bool("model.cached", true))
+		// An existing file is held to the signature policy like a downloaded one
+		if len(policy.TrustedKeys) > 0 {
+			if _, err := mm.verifyStored(ctx, modelName, version, modelPath, modelURL, policy); err != nil {
+				return fmt.Errorf("failed to verify model %s version %s: %w", modelName, version, err)
+			}
+		}
+		logging.Default().InfoContext(ctx, "model already downloaded", "model", modelName, "version", version)
+		if progress != nil {
+			progress(DownloadProgress{Model: modelName, Version: version, Completed: info.Size(), Total: info.Size(), Done: true})
+		}
+		return nil
+	}
+
+	// The signature is fetched first, so an unsigned model is refused before downloading it
+	var signature string
+	if len(policy.TrustedKeys) > 0 {
+		if signature, err = mm.fetchSignature(ctx, modelURL); err != nil {
+			return err
+		}
+		if signature == "" && policy.Strict {
+			return fmt.Errorf("failed to download model %s version %s: %w", modelName, version, ErrUnsigned)
+		}
+	}
+
+	// The file is downloaded next to its destination and only moved there once verified
+	stagingPath := modelPath + ".unverified"
+	logging.Default().InfoContext(ctx, "downloading model", "model", modelName, "version", version, "url", modelURL)
+	tracker := newProgressTracker(modelName, version, progress)
+	err = mm.fetchModel(ctx, modelURL, stagingPath, opts, tracker)
+	tracker.finish(err == nil)
+	if err != nil {
+		return err
+	}
+
+	digest, size, err := fileDigest(stagingPath)
+	if err != nil {
+		os.Remove(stagingPath)
+		return fmt.Errorf("failed to checksum model file: %w", err)
+	}
+	span.SetAttributes(attribute.Int64("model.size", size))
+
+	publisher, err := policy.verify(digest, signature)
+	if err != nil {
+		os.Remove(stagingPath)
+		return fmt.Errorf("failed to verify model %s version %s: %w", modelName, version, err)
+	}
+	if publisher != "" {
+		span.SetAttributes(attribute.String("model.publisher", publisher))
+	}
+	if err := os.Rename(stagingPath, modelPath); err != nil {
+		os.Remove(stagingPath)
+		return fmt.Errorf("failed to save model file: %w", err)
+	}
+
+	mm.lock.Lock()
+	mm.recordVersion(modelName, version, &VersionRecord{
+		Digest:       digest,
+		Size:         size,
+		DownloadedAt: time.Now().UTC(),
+		Signature:    signature,
+		Publisher:    publisher,
+	})
+	err = mm.saveManifest()
+	mm.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := mm.switchVersion(modelName, version, false, nil); err != nil {
+		return err
+	}
+
+	logging.Default().InfoContext(ctx, "downloaded model", "model", modelName, "version", version, "publisher", publisher)
+	return nil
+}
+
+// LoadModel loads a model into memory for faster inference using the configured backend.
+// If the memory budget is exceeded afterwards, least recently used models are unloaded.
+func (mm *ModelManager) LoadModel(modelName string) error {
+	return mm.LoadModelContext(context.Background(), modelName)
+}
+
+// LoadModelContext is like LoadModel but records the load in a "model.load" span that
+// is a child of the span in ctx.
+func (mm *ModelManager) LoadModelContext(ctx context.Context, modelName string) (err error) {
+	ctx, span := mm.startSpan(ctx, "model.load", modelName)
+	defer func() { endSpan(span, err) }()
+
+	if err := mm.loadModel(ctx, modelName); err != nil {
+		return err
+	}
+	mm.enforceMemoryBudget(modelName)
+	return nil
+}
+
+// loadModel loads a model while holding its lock, recording its version and resident
+// size on the span in ctx.
+func (mm *ModelManager) loadModel(ctx context.Context, modelName string) error {
+	defer mm.lockModel(modelName)()
+	span := trace.SpanFromContext(ctx)
+
+	mm.lock.Lock()
+	loaded := mm.loadedModels[modelName] != ""
+	version, ok := mm.currentVersion[modelName]
+	backend := mm.backend
+	if loaded {
+		// A request for a loaded model is a use, so it is not evicted as idle
+		mm.lastUsed[modelName] = time.Now().UTC()
+	}
+	mm.lock.Unlock()
+
+	if loaded {
+		span.SetAttributes(attribute.Bool("model.cached", true))
+		logging.Default().DebugContext(ctx, "model already loaded", "model", modelName)
+		return nil
+	}
+	if !ok {
+		return fmt.Errorf("model %s not found", modelName)
+	}
+	span.SetAttributes(attribute.String("model.version", version))
+
+	modelPath := filepath.Join(mm.modelDir, modelName+"-"+version+".bin")
+	if _, err := os.Stat(modelPath); err != nil {
+		return fmt.Errorf("model file not found: %s", modelPath)
+	}
+
+	logging.Default().InfoContext(ctx, "loading model", "model", modelName, "version", version)
+	resident, err := backend.Load(modelName, version, modelPath)
+	if err != nil {
+		return fmt.Errorf("failed to load model %s: %w", modelName, err)
+	}
+	span.SetAttributes(attribute.Int64("model.resident_bytes", resident))
+
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+	mm.loadedModels[modelName] = version
+	mm.residentMemory[modelName] = resident
+	mm.lastUsed[modelName] = time.Now().UTC()
+	return mm.saveManifest()
+}
+
+// switchVersion makes version the current version of a model. If another version is
+// loaded, version is loaded next to it, which keeps serving until that succeeds; then
+// the current and loaded versions move to version together, and the previously loaded
+// version is unloaded last. If load is true, version is loaded even when no version of
+// the model is. update, if not nil, is called with the lock held as the version moves,
+// so callers can move other state, such as an alias, with it.
+// The caller must hold the model's lock from lockModel, and not mm.lock.
+func (mm *ModelManager) switchVersion(modelName, version string, load bool, update func()) error {
+	mm.lock.Lock()
+	loaded := mm.loadedModels[modelName]
+	backend := mm.backend
+	mm.lock.Unlock()
+
+	// Phase 1: load the new version next to the loaded one
+	swapping := loaded != version && (loaded != "" || load)
+	var resident int64
+	if swapping {
+		modelPath := filepath.Join(mm.modelDir, modelName+"-"+version+".bin")
+		logging.Default().Info("loading model", "model", modelName, "version", version)
+		var err error
+		if resident, err = backend.Load(modelName, version, modelPath); err != nil {
+			return fmt.Errorf("failed to load model %s: %w", modelName, err)
+		}
+	}
+
+	// Phase 2: move the current and loaded versions together
+	mm.lock.Lock()
+	mm.currentVersion[modelName] = version
+	if swapping {
+		mm.loadedModels[modelName] = version
+		mm.residentMemory[modelName] = resident
+		mm.lastUsed[modelName] = time.Now().UTC()
+	}
+	if update != nil {
+		update()
+	}
+	err := mm.saveManifest()
+	mm.lock.Unlock()
+
+	// Phase 3: release the previously loaded version, which nothing uses any more
+	if loaded != "" && loaded != version {
+		logging.Default().Info("unloading model", "model", modelName, "version", loaded)
+		if err := backend.Unload(modelName, loaded); err != nil {
+			logging.Default().Warn("failed to unload previous model version", "model", modelName, "version", loaded, "error", err)
+		}
+	}
+	return err
+}
+
+// UnloadModel removes a model from memory to free resources.
+func (mm *ModelManager) UnloadModel(modelName string) error {
+	defer mm.lockModel(modelName)()
+
+	mm.lock.Lock()
+	version := mm.loadedModels[modelName]
+	backend := mm.backend
+	mm.lock.Unlock()
+
+	if version == "" {
+		return fmt.Errorf("model %s is not loaded", modelName)
+	}
+
+	logging.Default().Info("unloading model", "model", modelName, "version", version)
+	if err := backend.Unload(modelName, version); err != nil {
+		return fmt.Errorf("failed to unload model %s: %w", modelName, err)
+	}
+
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+	delete(mm.loadedModels, modelName)
+	delete(mm.residentMemory, modelName)
+	return mm.saveManifest()
+}
+
+// FineTuneModel fine-tunes a model with a specific dataset and stores the fine-tuned model version.
+func (mm *ModelManager) FineTuneModel(modelName, datasetPath string) error {
+	defer mm.lockModel(modelName)()
+
+	logging.Default().Info("fine-tuning model", "model", modelName, "dataset", datasetPath)
+	fineTunedVersion := "ft-" + time.Now().Format("20060102150405")
+	fineTunedModelPath := filepath.Join(mm.modelDir, modelName+"-"+fineTunedVersion+".bin")
+
+	// Simulate fine-tuning and saving the new model version, streaming the dataset so
+	// large ones are not held in memory
+	dataset, err := os.Open(datasetPath)
+	if err != nil {
+		return fmt.Errorf("failed to read fine-tuning dataset: %w", err)
+	}
+	defer dataset.Close()
+
+	file, err := os.Create(fineTunedModelPath)
+	if err != nil {
+		return fmt.Errorf("failed to save fine-tuned model: %w", err)
+	}
+	// The simulated fine-tuned model is a copy of the dataset, so both share a digest
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, h), dataset)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(fineTunedModelPath)
+		return fmt.Errorf("failed to save fine-tuned model: %w", err)
+	}
+	digest := hex.EncodeToString(h.Sum(nil))
+	now := time.Now().UTC()
+
+	mm.lock.Lock()
+	mm.recordVersion(modelName, fineTunedVersion, &VersionRecord{
+		Digest:       digest,
+		Size:         size,
+		DownloadedAt: now,
+		FineTune: &FineTuneRecord{
+			BaseVersion:   mm.currentVersion[modelName],
+			Dataset:       datasetPath,
+			DatasetDigest: digest,
+			CreatedAt:     now,
+		},
+	})
+	err = mm.saveManifest()
+	mm.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := mm.switchVersion(modelName, fineTunedVersion, false, func() {
+		mm.fineTuningData[modelName] = datasetPath
+	}); err != nil {
+		return err
+	}
+
+	logging.Default().Info("fine-tuned model saved", "model", modelName, "version", fineTunedVersion)
+	return nil
+}
+
+// PreloadOptions configures Preload and PreloadModelsWithOptions.
+type PreloadOptions struct {
+	// MaxParallel is the maximum number of models loaded at once.
+	// Default: 0 (no limit)
+	MaxParallel int
+
+	// Warmer sends warmup requests to each model once it is loaded.
+	// Optional. Without a Warmer, models are loaded but not warmed up.
+	Warmer Warmer
+
+	// Warmup configures the warmup requests sent to models without an entry in
+	// ModelWarmup. Zero fields are filled from DefaultWarmupOptions.
+	// Default: DefaultWarmupOptions()
+	Warmup WarmupOptions
+
+	// ModelWarmup overrides Warmup for individual models, keyed by model name.
+	// Zero fields are filled from DefaultWarmupOptions.
+	// Optional.
+	ModelWarmup map[string]WarmupOptions
+}
+
+// warmupFor returns the warmup options for a model.
+func (o PreloadOptions) warmupFor(modelName string) WarmupOptions {
+	if w, ok := o.ModelWarmup[modelName]; ok {
+		return w.withDefaults()
+	}
+	return o.Warmup.withDefaults()
+}
+
+// PreloadResult is the outcome of preloading a model.
+type PreloadResult struct {
+	Model        string
+	Err          error           // Error loading the model, or nil if it loaded
+	LoadDuration time.Duration   // Time taken to load the model
+	Warmup       []time.Duration // Latency of each successful warmup request, in order
+	WarmupErr    error           // Error warming up the model; the model stays loaded
+}
+
+// PreloadModels loads multiple models concurrently and waits for them to finish.
+// The returned map contains an entry for every model that failed to load.
+func (mm *ModelManager) PreloadModels(models []string) map[string]error {
+	return mm.PreloadModelsWithOptions(models, PreloadOptions{})
+}
+
+// PreloadModelsWithOptions loads multiple models concurrently, at most opts.MaxParallel
+// at a time, and waits for them to finish. The returned map contains an entry for
+// every model that failed to load. Warmup failures are logged but not returned; use
+// Preload to inspect them.
+func (mm *ModelManager) PreloadModelsWithOptions(models []string, opts PreloadOptions) map[string]error {
+	errs := make(map[string]error)
+	for _, result := range mm.Preload(context.Background(), models, opts) {
+		if result.Err != nil {
+			errs[result.Model] = result.Err
+		}
+	}
+	return errs
+}
+
+// Preload loads multiple models concurrently, at most opts.MaxParallel at a time, and
+// warms each one up with opts.Warmer once it is loaded. It waits for every model to
+// finish and returns their results in the order of models.
+func (mm *ModelManager) Preload(ctx context.Context, models []string, opts PreloadOptions) []PreloadResult {
+	mm.lock.Lock()
+	mm.preloadQueue = models
+	mm.lock.Unlock()
+
+	parallel := opts.MaxParallel
+	if parallel <= 0 || parallel > len(models) {
+		parallel = len(models)
+	}
+
+	logging.Default().InfoContext(ctx, "preloading models", "models", len(models), "parallel", parallel, "warmup", opts.Warmer != nil)
+	var wg sync.WaitGroup
+	results := make([]PreloadResult, len(models))
+	sem := make(chan struct{}, parallel)
+	for i, modelName := range models {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = mm.preload(ctx, modelName, opts)
+		}()
+	}
+	wg.Wait()
+	logging.Default().InfoContext(ctx, "model preloading complete", "models", len(models))
+	return results
+}
+
+// preload loads and warms up a single model.
+func (mm *ModelManager) preload(ctx context.Context, modelName string, opts PreloadOptions) PreloadResult {
+	result := PreloadResult{Model: modelName}
+	start := time.Now()
+	if err := mm.LoadModelContext(ctx, modelName); err != nil {
+		logging.Default().WarnContext(ctx, "failed to preload model", "model", modelName, "error", err)
+		result.Err = err
+		return result
+	}
+	result.LoadDuration = time.Since(start)
+
+	warmup := opts.warmupFor(modelName)
+	if opts.Warmer == nil || warmup.Disabled {
+		return result
+	}
+	result.Warmup, result.WarmupErr = mm.warmup(ctx, opts.Warmer, modelName, warmup)
+	if result.WarmupErr != nil {
+		logging.Default().WarnContext(ctx, "failed to warm up model", "model", modelName, "error", result.WarmupErr)
+	}
+	return result
+}
+
+// RollbackModel reverts a model to a previous version if available.
+func (mm *ModelManager) RollbackModel(modelName, previousVersion string) error {
+	defer mm.lockModel(modelName)()
+
+	modelPath := filepath.Join(mm.modelDir, modelName+"-"+previousVersion+".bin")
+	if _, err := os.Stat(modelPath); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("previous version %s for model %s not found: %w", previousVersion, modelName, err)
+	}
+
+	if err := mm.switchVersion(modelName, previousVersion, false, nil); err != nil {
+		return err
+	}
+
+	logging.Default().Info("rolled back model", "model", modelName, "version", previousVersion)
+	return nil
+}
+
+// DeleteModel removes a model file from storage. Pinned versions cannot be deleted;
+// they fail with ErrPinned until UnpinModel is called.
+func (mm *ModelManager) DeleteModel(modelName, version string) error {
+	defer mm.lockModel(modelName)()
+
+	mm.lock.Lock()
+	aliases := mm.aliasesFor(modelName, version)
+	pinned := mm.pinned(modelName, version)
+	mm.lock.Unlock()
+	if len(aliases) > 0 {
+		return fmt.Errorf("failed to delete model: version %s of %s is referenced by aliases %v", version, modelName, aliases)
+	}
+	if pinned {
+		return fmt.Errorf("failed to delete model: %w: %s version %s", ErrPinned, modelName, version)
+	}
+
+	modelPath := filepath.Join(mm.modelDir, modelName+"-"+version+".bin")
+	if err := os.Remove(modelPath); err != nil {
+		return fmt.Errorf("failed to delete model: %w", err)
+	}
+
+	mm.lock.Lock()
+	current := mm.currentVersion[modelName] == version
+	loaded := mm.loadedModels[modelName] == version
+	backend := mm.backend
+	mm.lock.Unlock()
+
+	// The deleted file was the one in memory, so release it
+	if loaded {
+		if err := backend.Unload(modelName, version); err != nil {
+			logging.Default().Warn("failed to unload deleted model", "model", modelName, "error", err)
+		}
+	}
+
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+
+	if current {
+		delete(mm.currentVersion, modelName)
+	}
+	if loaded {
+		delete(mm.loadedModels, modelName)
+		delete(mm.residentMemory, modelName)
+	}
+	if versions := mm.versions[modelName]; versions != nil {
+		delete(versions, version)
+		if len(versions) == 0 {
+			delete(mm.versions, modelName)
+		}
+	}
+	if err := mm.saveManifest(); err != nil {
+		return err
+	}
+
+	logging.Default().Info("deleted model", "model", modelName, "version", version)
+	return nil
+}
+
+// ModelInfo describes a model version available in storage.
+type ModelInfo struct {
+	Name     string
+	Version  string
+	Path     string
+	Size     int64
+	Checksum string    // Hex-encoded SHA-256 recorded in the manifest; empty if unknown
+	Current  bool      // Whether this is the model's active version
+	Loaded   bool      // Whether this version is currently loaded
+	Pinned   bool      // Whether this version is pinned; see PinModel
+	LastUsed time.Time // When the model was last loaded or served a request; zero if never
+
+	// Usage of the model across its versions; see RecordRequest
+	Requests   int64         // Number of requests served
+	AvgLatency time.Duration // Average latency of the requests, or zero if none
+}
+
+// ListOptions filters the results of ListModels.
+type ListOptions struct {
+	// Name restricts results to versions of the named model.
+	// Optional.
+	Name string
+
+	// LoadedOnly restricts results to loaded models.
+	LoadedOnly bool
+}
+
+// ListModels returns the model versions currently available in storage, sorted by name and version.
+func (mm *ModelManager) ListModels(opts ListOptions) ([]ModelInfo, error) {
+	files, err := os.ReadDir(mm.modelDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list models: %w", err)
+	}
+
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+
+	var models []ModelInfo
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".bin" {
+			continue
+		}
+
+		name, version := mm.parseModelFile(file.Name())
+		if opts.Name != "" && name != opts.Name {
+			continue
+		}
+
+		current := mm.currentVersion[name] == version
+		loaded := mm.loadedModels[name] == version
+		if opts.LoadedOnly && !loaded {
+			continue
+		}
+
+		usage := mm.modelUsage(name)
+		info := ModelInfo{
+			Name:       name,
+			Version:    version,
+			Path:       filepath.Join(mm.modelDir, file.Name()),
+			Current:    current,
+			Loaded:     loaded,
+			LastUsed:   usage.LastUsed,
+			Requests:   usage.Requests,
+			AvgLatency: usage.AvgLatency,
+		}
+		if fi, err := file.Info(); err == nil {
+			info.Size = fi.Size()
+		}
+		if record := mm.versions[name][version]; record != nil {
+			info.Checksum = record.Digest
+			info.Pinned = record.Pinned
+		}
+		models = append(models, info)
+	}
+
+	sort.Slice(models, func(i, j int) bool {
+		if models[i].Name != models[j].Name {
+			return models[i].Name < models[j].Name
+		}
+		return models[i].Version < models[j].Version
+	})
+	return models, nil
+}
+
+// parseModelFile splits a "<name>-<version>.bin" file name into its model name and version.
+// Versions known to the manager take precedence, since both names and versions may contain dashes.
+// This method is not thread-safe and should be called with the lock held.
+func (mm *ModelManager) parseModelFile(fileName string) (string, string) {
+	base := strings.TrimSuffix(fileName, ".bin")
+
+	for name, version := range mm.currentVersion {
+		if base == name+"-"+version {
+			return name, version
+		}
+	}
+	for name, versions := range mm.versions {
+		for version := range versions {
+			if base == name+"-"+version {
+				return name, version
+			}
+		}
+	}
+
+	if i := strings.LastIndex(base, "-"); i > 0 {
+		return base[:i], base[i+1:]
+	}
+	return base, ""
+}
+
+// fetchModel downloads modelURL to modelPath. When the registry advertises range support
+// and the file is larger than the configured part size, the file is fetched in parallel
+// parts; otherwise it is streamed in a single request. Data is written to a temporary
+// file that is renamed into place only once the download completes. Downloaded bytes are
+// counted by progress, which may be nil.
+func (mm *ModelManager) fetchModel(ctx context.Context, modelURL, modelPath string, opts DownloadOptions, progress *progressTracker) error {
+	size, ranged, err := mm.probeModel(ctx, modelURL)
+	if err != nil {
+		return fmt.Errorf("failed to download model: %w", err)
+	}
+	progress.setTotal(size)
+
+	tmpPath := modelPath + ".part"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to save model file: %w", err)
+	}
+
+	if ranged && opts.Concurrency > 1 && size > opts.PartSize {
+		err = mm.downloadParts(ctx, modelURL, file, size, opts, progress)
+	} else {
+		err = mm.downloadSingle(ctx, modelURL, file, progress)
+	}
+
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to save model file: %w", closeErr)
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, modelPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to save model file: %w", err)
+	}
+	return nil
+}
+
+// probeModel issues a HEAD request to learn the model size and whether byte ranges are supported.
+// A registry that rejects HEAD is treated as not supporting ranges.
+func (mm *ModelManager) probeModel(ctx context.Context, modelURL string) (int64, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, modelURL, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	res, err := mm.httpClient.Do(req)
+	if err != nil {
+		return 0, false, err
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return 0, false, nil
+	}
+	return res.ContentLength, res.Header.Get("Accept-Ranges") == "bytes" && res.ContentLength > 0, nil
+}
+
+// downloadSingle streams the whole model in one request.
+func (mm *ModelManager) downloadSingle(ctx context.Context, modelURL string, file *os.File, progress *progressTracker) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, modelURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to download model: %w", err)
+	}
+	res, err := mm.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download model: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download model: server returned %d", res.StatusCode)
+	}
+	progress.setTotal(res.ContentLength)
+
+	var dst io.Writer = file
+	if progress != nil {
+		dst = io.MultiWriter(file, progress)
+	}
+	if _, err := io.Copy(dst, res.Body); err != nil {
+		return fmt.Errorf("failed to download model: %w", err)
+	}
+	return nil
+}
+
+// downloadParts fetches the model as concurrent byte ranges, retrying each part independently,
+// and writes every part at its offset in file. The first part to fail cancels the others and
+// no further parts are started.
+func (mm *ModelManager) downloadParts(ctx context.Context, modelURL string, file *os.File, size int64, opts DownloadOptions, progress *progressTracker) error {
+	if err := file.Truncate(size); err != nil {
+		return fmt.Errorf("failed to save model file: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	sem := make(chan struct{}, opts.Concurrency)
+	retryOpts := retry.DefaultOptions()
+	retryOpts.MaxAttempts = opts.PartRetries
+
+parts:
+	for start := int64(0); start < size; start += opts.PartSize {
+		end := start + opts.PartSize - 1
+		if end >= size {
+			end = size - 1
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break parts
+		}
+		if ctx.Err() != nil {
+			<-sem
+			break
+		}
+
+		wg.Add(1)
+		go func(start, end int64) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := retry.DoWithContext(ctx, retryOpts, func(ctx context.Context) error {
+				return mm.downloadPart(ctx, modelURL, file, start, end, progress)
+			})
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = fmt.Errorf("failed to download model: part %d-%d: %w", start, end, err)
+					cancel()
+				})
+			}
+		}(start, end)
+	}
+	wg.Wait()
+
+	if firstErr == nil && ctx.Err() != nil {
+		return fmt.Errorf("failed to download model: %w", ctx.Err())
+	}
+	return firstErr
+}
+
+// downloadPart fetches the inclusive byte range [start, end] and writes it at offset start.
+// If it fails, the bytes it counted in progress are uncounted, as the part is retried.
+func (mm *ModelManager) downloadPart(ctx context.Context, modelURL string, file *os.File, start, end int64, progress *progressTracker) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, modelURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+
+	res, err := mm.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("server returned %d for ranged request", res.StatusCode)
+	}
+
+	length := end - start + 1
+	var dst io.Writer = io.NewOffsetWriter(file, start)
+	if progress != nil {
+		dst = io.MultiWriter(dst, progress)
+	}
+	n, err := io.Copy(dst, io.LimitReader(res.Body, length))
+	if err == nil && n != length {
+		err = fmt.Errorf("short read: got %d of %d bytes", n, length)
+	}
+	if err != nil {
+		progress.add(-n)
+		return err
+	}
+	return nil
+}
@@ -1,17 +1,25 @@
 package models
 
 import (
-	"io/ioutil"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestNewModelManager(t *testing.T) {
 	// Create a temporary directory for testing
-	tempDir, err := ioutil.TempDir("", "model-manager-test")
+	tempDir, err := os.MkdirTemp("", "model-manager-test")
 	if err != nil {
 		t.Fatalf("Failed to create temp directory: %v", err)
 	}
@@ -49,7 +57,7 @@ func TestNewModelManager(t *testing.T) {
 
 func TestDownloadModel(t *testing.T) {
 	// Create a temporary directory for testing
-	tempDir, err := ioutil.TempDir("", "model-manager-test")
+	tempDir, err := os.MkdirTemp("", "model-manager-test")
 	if err != nil {
 		t.Fatalf("Failed to create temp directory: %v", err)
 	}
@@ -89,9 +97,223 @@ func TestDownloadModel(t *testing.T) {
 	}
 }
 
+func TestDownloadModelMultipart(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "model-manager-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Serve a model that is several parts long; ServeContent handles HEAD and Range requests
+	modelData := bytes.Repeat([]byte("0123456789abcdef"), 1000)
+	var rangedRequests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			atomic.AddInt32(&rangedRequests, 1)
+		}
+		http.ServeContent(w, r, "model.bin", time.Time{}, bytes.NewReader(modelData))
+	}))
+	defer server.Close()
+
+	mm := NewModelManager(tempDir)
+	mm.registryURL = server.URL
+	mm.SetDownloadOptions(DownloadOptions{PartSize: 1000, Concurrency: 3, PartRetries: 2})
+
+	if err := mm.DownloadModel("test-model", "v1.0"); err != nil {
+		t.Fatalf("Failed to download model: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tempDir, "test-model-v1.0.bin"))
+	if err != nil {
+		t.Fatalf("Failed to read downloaded model: %v", err)
+	}
+	if !bytes.Equal(data, modelData) {
+		t.Errorf("Downloaded model does not match the served data (got %d bytes, want %d)", len(data), len(modelData))
+	}
+
+	expectedParts := int32((len(modelData) + 999) / 1000)
+	if got := atomic.LoadInt32(&rangedRequests); got != expectedParts {
+		t.Errorf("Expected %d ranged requests, got %d", expectedParts, got)
+	}
+
+	// The temporary part file should not be left behind
+	if _, err := os.Stat(filepath.Join(tempDir, "test-model-v1.0.bin.part")); !os.IsNotExist(err) {
+		t.Error("Expected temporary download file to be removed")
+	}
+}
+
+func TestDownloadModelPartRetry(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "model-manager-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Fail the first ranged request so one part has to be retried
+	modelData := bytes.Repeat([]byte("x"), 5000)
+	var failed int32
+	var alwaysFail atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" && (alwaysFail.Load() || atomic.CompareAndSwapInt32(&failed, 0, 1)) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		http.ServeContent(w, r, "model.bin", time.Time{}, bytes.NewReader(modelData))
+	}))
+	defer server.Close()
+
+	mm := NewModelManager(tempDir)
+	mm.registryURL = server.URL
+	mm.SetDownloadOptions(DownloadOptions{PartSize: 1000, Concurrency: 2, PartRetries: 3})
+
+	if err := mm.DownloadModel("test-model", "v1.0"); err != nil {
+		t.Fatalf("Expected download to succeed after retrying a part, got %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tempDir, "test-model-v1.0.bin"))
+	if err != nil {
+		t.Fatalf("Failed to read downloaded model: %v", err)
+	}
+	if !bytes.Equal(data, modelData) {
+		t.Error("Downloaded model does not match the served data")
+	}
+
+	// A part that keeps failing should fail the whole download and leave no model file
+	alwaysFail.Store(true)
+
+	err = mm.DownloadModel("test-model", "v2.0")
+	if err == nil {
+		t.Fatal("Expected error when a part cannot be downloaded, got nil")
+	}
+	if !strings.Contains(err.Error(), "failed to download model") {
+		t.Errorf("Expected error to contain 'failed to download model', got '%s'", err.Error())
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "test-model-v2.0.bin")); !os.IsNotExist(err) {
+		t.Error("Expected no model file after a failed download")
+	}
+}
+
+func TestDownloadModelPartFailureCancelsOtherParts(t *testing.T) {
+	// Every part fails, so without cancellation each of the ten parts would be
+	// attempted PartRetries times
+	modelData := bytes.Repeat([]byte("x"), 10000)
+	var rangedRequests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			atomic.AddInt32(&rangedRequests, 1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		http.ServeContent(w, r, "model.bin", time.Time{}, bytes.NewReader(modelData))
+	}))
+	defer server.Close()
+
+	mm := NewModelManager(t.TempDir())
+	mm.registryURL = server.URL
+	mm.SetDownloadOptions(DownloadOptions{PartSize: 1000, Concurrency: 2, PartRetries: 3})
+
+	err := mm.DownloadModel("test-model", "v1.0")
+	if err == nil || !strings.Contains(err.Error(), "part ") {
+		t.Fatalf("Expected a part error, got %v", err)
+	}
+
+	// Only the parts running when the first one gave up should have been attempted
+	if got := atomic.LoadInt32(&rangedRequests); got > 2*3 {
+		t.Errorf("Expected at most 6 ranged requests, got %d", got)
+	}
+}
+
+func TestDownloadModelProgress(t *testing.T) {
+	// Serve parts slowly so progress is reported before the download completes, and
+	// fail a part after sending half of it so its bytes have to be uncounted
+	modelData := bytes.Repeat([]byte("x"), 4000)
+	var failed int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			time.Sleep(400 * time.Millisecond)
+			if atomic.CompareAndSwapInt32(&failed, 0, 1) {
+				w.Header().Set("Content-Range", "bytes 0-999/4000")
+				w.Header().Set("Content-Length", "1000")
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write(modelData[:500])
+				return
+			}
+		}
+		http.ServeContent(w, r, "model.bin", time.Time{}, bytes.NewReader(modelData))
+	}))
+	defer server.Close()
+
+	mm := NewModelManager(t.TempDir())
+	mm.registryURL = server.URL
+	mm.SetDownloadOptions(DownloadOptions{PartSize: 1000, Concurrency: 2, PartRetries: 2})
+
+	var reports []DownloadProgress
+	err := mm.DownloadModelProgress(context.Background(), "test-model", "v1.0", func(p DownloadProgress) {
+		reports = append(reports, p)
+	})
+	if err != nil {
+		t.Fatalf("Failed to download model: %v", err)
+	}
+	if len(reports) < 2 {
+		t.Fatalf("Expected progress before the download completed, got %+v", reports)
+	}
+	for _, p := range reports[:len(reports)-1] {
+		if p.Done || p.Total != 4000 || p.Completed > 4000 {
+			t.Errorf("Unexpected progress %+v", p)
+		}
+	}
+	last := reports[len(reports)-1]
+	if !last.Done || last.Completed != 4000 || last.Percent() != 100 || last.Rate <= 0 || last.ETA != 0 {
+		t.Errorf("Expected the download to be reported as done, got %+v", last)
+	}
+
+	// A version already downloaded is reported as done right away
+	reports = nil
+	mm.DownloadModelProgress(context.Background(), "test-model", "v1.0", func(p DownloadProgress) {
+		reports = append(reports, p)
+	})
+	if len(reports) != 1 || !reports[0].Done || reports[0].Completed != 4000 {
+		t.Errorf("Expected a single done report, got %+v", reports)
+	}
+}
+
+func TestDownloadModelSingleStream(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "model-manager-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// A registry without range support is downloaded in a single request
+	modelData := []byte("mock model data")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			t.Errorf("Unexpected ranged request to a registry without range support")
+		}
+		w.Write(modelData)
+	}))
+	defer server.Close()
+
+	mm := NewModelManager(tempDir)
+	mm.registryURL = server.URL
+	mm.SetDownloadOptions(DownloadOptions{PartSize: 4, Concurrency: 4})
+
+	if err := mm.DownloadModel("test-model", "v1.0"); err != nil {
+		t.Fatalf("Failed to download model: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tempDir, "test-model-v1.0.bin"))
+	if err != nil {
+		t.Fatalf("Failed to read downloaded model: %v", err)
+	}
+	if !bytes.Equal(data, modelData) {
+		t.Errorf("Expected downloaded data %q, got %q", modelData, data)
+	}
+}
+
 func TestLoadModel(t *testing.T) {
 	// Create a temporary directory for testing
-	tempDir, err := ioutil.TempDir("", "model-manager-test")
+	tempDir, err := os.MkdirTemp("", "model-manager-test")
 	if err != nil {
 		t.Fatalf("Failed to create temp directory: %v", err)
 	}
@@ -104,7 +326,7 @@ func TestLoadModel(t *testing.T) {
 	modelName := "test-model"
 	version := "v1.0"
 	modelPath := filepath.Join(tempDir, modelName+"-"+version+".bin")
-	if err := ioutil.WriteFile(modelPath, []byte("mock model data"), 0644); err != nil {
+	if err := os.WriteFile(modelPath, []byte("mock model data"), 0644); err != nil {
 		t.Fatalf("Failed to create mock model file: %v", err)
 	}
 
@@ -118,7 +340,7 @@ func TestLoadModel(t *testing.T) {
 	}
 
 	// Verify the model was marked as loaded
-	if !mm.loadedModels[modelName] {
+	if mm.loadedModels[modelName] == "" {
 		t.Errorf("Expected model '%s' to be marked as loaded", modelName)
 	}
 
@@ -138,9 +360,126 @@ func TestLoadModel(t *testing.T) {
 	}
 }
 
+func TestModelManagerTracing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("mock model data"))
+	}))
+	defer server.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	mm := NewModelManager(t.TempDir())
+	mm.registryURL = server.URL
+	mm.SetTracer(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test"))
+
+	ctx, parent := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "request")
+	if err := mm.DownloadModelContext(ctx, "test-model", "v1.0"); err != nil {
+		t.Fatalf("Failed to download model: %v", err)
+	}
+	if err := mm.LoadModelContext(ctx, "test-model"); err != nil {
+		t.Fatalf("Failed to load model: %v", err)
+	}
+	if err := mm.LoadModelContext(ctx, "missing-model"); err == nil {
+		t.Fatal("Expected an error loading a missing model")
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("Expected 3 spans, got %d", len(spans))
+	}
+	for i, name := range []string{"model.download", "model.load", "model.load"} {
+		if spans[i].Name() != name {
+			t.Errorf("Expected span %d to be %s, got %s", i, name, spans[i].Name())
+		}
+		if spans[i].Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("Expected %s to be a child of the request span", spans[i].Name())
+		}
+	}
+	if spans[1].Status().Code == codes.Error || spans[2].Status().Code != codes.Error {
+		t.Error("Expected only the load of the missing model to fail")
+	}
+
+	// A nil tracer disables the spans
+	mm.SetTracer(nil)
+	if err := mm.UnloadModel("test-model"); err != nil {
+		t.Fatalf("Failed to unload model: %v", err)
+	}
+	if err := mm.LoadModelContext(ctx, "test-model"); err != nil {
+		t.Fatalf("Failed to load model: %v", err)
+	}
+	if len(recorder.Ended()) != 3 {
+		t.Errorf("Expected no spans without a tracer, got %d", len(recorder.Ended())-3)
+	}
+}
+
+func TestPerModelLocking(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "model-manager-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// The registry blocks until released so the download stays in flight
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			started <- struct{}{}
+			<-release
+		}
+		w.Write([]byte("mock model data"))
+	}))
+	defer server.Close()
+
+	mm := NewModelManager(tempDir)
+	mm.registryURL = server.URL
+
+	// Prepare a second, unrelated model on disk
+	modelPath := filepath.Join(tempDir, "other-model-v1.0.bin")
+	if err := os.WriteFile(modelPath, []byte("mock model data"), 0644); err != nil {
+		t.Fatalf("Failed to create mock model file: %v", err)
+	}
+	mm.currentVersion["other-model"] = "v1.0"
+
+	downloadErr := make(chan error, 1)
+	go func() {
+		downloadErr <- mm.DownloadModel("slow-model", "v1.0")
+	}()
+	<-started
+
+	// Loading an unrelated model must not wait for the download
+	loaded := make(chan error, 1)
+	go func() {
+		loaded <- mm.LoadModel("other-model")
+	}()
+
+	select {
+	case err := <-loaded:
+		if err != nil {
+			t.Fatalf("Failed to load model: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("LoadModel of an unrelated model blocked on an in-flight download")
+	}
+
+	close(release)
+	if err := <-downloadErr; err != nil {
+		t.Fatalf("Failed to download model: %v", err)
+	}
+
+	// Locks are released once no operation holds them
+	mm.lock.Lock()
+	remaining := len(mm.modelLocks)
+	mm.lock.Unlock()
+	if remaining != 0 {
+		t.Errorf("Expected no per-model locks to remain, got %d", remaining)
+	}
+}
+
 func TestUnloadModel(t *testing.T) {
 	// Create a temporary directory for testing
-	tempDir, err := ioutil.TempDir("", "model-manager-test")
+	tempDir, err := os.MkdirTemp("", "model-manager-test")
 	if err != nil {
 		t.Fatalf("Failed to create temp directory: %v", err)
 	}
@@ -151,7 +490,7 @@ func TestUnloadModel(t *testing.T) {
 
 	// Set up a loaded model
 	modelName := "test-model"
-	mm.loadedModels[modelName] = true
+	mm.loadedModels[modelName] = "v1.0"
 
 	// Test unloading the model
 	err = mm.UnloadModel(modelName)
@@ -160,7 +499,7 @@ func TestUnloadModel(t *testing.T) {
 	}
 
 	// Verify the model was marked as unloaded
-	if mm.loadedModels[modelName] {
+	if mm.loadedModels[modelName] != "" {
 		t.Errorf("Expected model '%s' to be marked as unloaded", modelName)
 	}
 
@@ -176,7 +515,7 @@ func TestUnloadModel(t *testing.T) {
 
 func TestFineTuneModel(t *testing.T) {
 	// Create a temporary directory for testing
-	tempDir, err := ioutil.TempDir("", "model-manager-test")
+	tempDir, err := os.MkdirTemp("", "model-manager-test")
 	if err != nil {
 		t.Fatalf("Failed to create temp directory: %v", err)
 	}
@@ -187,7 +526,7 @@ func TestFineTuneModel(t *testing.T) {
 
 	// Create a mock dataset file
 	datasetPath := filepath.Join(tempDir, "test-dataset.txt")
-	if err := ioutil.WriteFile(datasetPath, []byte("mock dataset data"), 0644); err != nil {
+	if err := os.WriteFile(datasetPath, []byte("mock dataset data"), 0644); err != nil {
 		t.Fatalf("Failed to create mock dataset file: %v", err)
 	}
 
@@ -200,7 +539,7 @@ func TestFineTuneModel(t *testing.T) {
 
 	// Verify the fine-tuned model file was created
 	// The file name should start with the model name and include "ft-" followed by a timestamp
-	files, err := ioutil.ReadDir(tempDir)
+	files, err := os.ReadDir(tempDir)
 	if err != nil {
 		t.Fatalf("Failed to read model directory: %v", err)
 	}
@@ -210,7 +549,7 @@ func TestFineTuneModel(t *testing.T) {
 	for _, file := range files {
 		if strings.HasPrefix(file.Name(), modelName+"-ft-") && strings.HasSuffix(file.Name(), ".bin") {
 			fineTunedModelFound = true
-			fineTunedVersion = strings.TrimSuffix(file.Name(), ".bin")
+			fineTunedVersion = strings.TrimSuffix(strings.TrimPrefix(file.Name(), modelName+"-"), ".bin")
 			break
 		}
 	}
@@ -241,7 +580,7 @@ func TestFineTuneModel(t *testing.T) {
 
 func TestPreloadModels(t *testing.T) {
 	// Create a temporary directory for testing
-	tempDir, err := ioutil.TempDir("", "model-manager-test")
+	tempDir, err := os.MkdirTemp("", "model-manager-test")
 	if err != nil {
 		t.Fatalf("Failed to create temp directory: %v", err)
 	}
@@ -255,30 +594,62 @@ func TestPreloadModels(t *testing.T) {
 	for _, modelName := range models {
 		version := "v1.0"
 		modelPath := filepath.Join(tempDir, modelName+"-"+version+".bin")
-		if err := ioutil.WriteFile(modelPath, []byte("mock model data"), 0644); err != nil {
+		if err := os.WriteFile(modelPath, []byte("mock model data"), 0644); err != nil {
 			t.Fatalf("Failed to create mock model file: %v", err)
 		}
 		mm.currentVersion[modelName] = version
 	}
 
-	// Test preloading models
-	mm.PreloadModels(models)
-
-	// Allow some time for the goroutines to complete
-	time.Sleep(100 * time.Millisecond)
+	// Test preloading models, including one that does not exist
+	errs := mm.PreloadModels(append(models, "missing-model"))
 
 	// Verify the models were added to the preload queue
-	if len(mm.preloadQueue) != len(models) {
-		t.Errorf("Expected preload queue to have length %d, got %d", len(models), len(mm.preloadQueue))
+	if len(mm.preloadQueue) != len(models)+1 {
+		t.Errorf("Expected preload queue to have length %d, got %d", len(models)+1, len(mm.preloadQueue))
 	}
 
-	// Since the actual loading is done in goroutines and we can't easily mock the LoadModel method,
-	// we can't reliably test that the models were actually loaded
+	// Only the missing model should be reported as failed
+	if len(errs) != 1 || errs["missing-model"] == nil {
+		t.Errorf("Expected only missing-model to fail, got %v", errs)
+	}
+	for _, modelName := range models {
+		if mm.loadedModels[modelName] == "" {
+			t.Errorf("Expected model '%s' to be loaded", modelName)
+		}
+	}
+}
+
+func TestPreloadModelsMaxParallel(t *testing.T) {
+	models := []string{"model1", "model2", "model3", "model4", "model5"}
+	mm := newTestManager(t, models...)
+
+	// Track how many loads run at once
+	var inFlight, peak int32
+	mm.SetBackend(backendFunc(func(modelName string) (int64, error) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return 1, nil
+	}))
+
+	errs := mm.PreloadModelsWithOptions(models, PreloadOptions{MaxParallel: 2})
+	if len(errs) != 0 {
+		t.Fatalf("Expected no preload errors, got %v", errs)
+	}
+	if got := atomic.LoadInt32(&peak); got > 2 {
+		t.Errorf("Expected at most 2 concurrent loads, got %d", got)
+	}
 }
 
 func TestRollbackModel(t *testing.T) {
 	// Create a temporary directory for testing
-	tempDir, err := ioutil.TempDir("", "model-manager-test")
+	tempDir, err := os.MkdirTemp("", "model-manager-test")
 	if err != nil {
 		t.Fatalf("Failed to create temp directory: %v", err)
 	}
@@ -292,7 +663,7 @@ func TestRollbackModel(t *testing.T) {
 	versions := []string{"v1.0", "v2.0"}
 	for _, version := range versions {
 		modelPath := filepath.Join(tempDir, modelName+"-"+version+".bin")
-		if err := ioutil.WriteFile(modelPath, []byte("mock model data"), 0644); err != nil {
+		if err := os.WriteFile(modelPath, []byte("mock model data"), 0644); err != nil {
 			t.Fatalf("Failed to create mock model file: %v", err)
 		}
 	}
@@ -323,7 +694,7 @@ func TestRollbackModel(t *testing.T) {
 
 func TestDeleteModel(t *testing.T) {
 	// Create a temporary directory for testing
-	tempDir, err := ioutil.TempDir("", "model-manager-test")
+	tempDir, err := os.MkdirTemp("", "model-manager-test")
 	if err != nil {
 		t.Fatalf("Failed to create temp directory: %v", err)
 	}
@@ -336,13 +707,13 @@ func TestDeleteModel(t *testing.T) {
 	modelName := "test-model"
 	version := "v1.0"
 	modelPath := filepath.Join(tempDir, modelName+"-"+version+".bin")
-	if err := ioutil.WriteFile(modelPath, []byte("mock model data"), 0644); err != nil {
+	if err := os.WriteFile(modelPath, []byte("mock model data"), 0644); err != nil {
 		t.Fatalf("Failed to create mock model file: %v", err)
 	}
 
 	// Set the current version
 	mm.currentVersion[modelName] = version
-	mm.loadedModels[modelName] = true
+	mm.loadedModels[modelName] = "v1.0"
 
 	// Test deleting the model
 	err = mm.DeleteModel(modelName, version)
@@ -376,7 +747,7 @@ func TestDeleteModel(t *testing.T) {
 
 func TestListModels(t *testing.T) {
 	// Create a temporary directory for testing
-	tempDir, err := ioutil.TempDir("", "model-manager-test")
+	tempDir, err := os.MkdirTemp("", "model-manager-test")
 	if err != nil {
 		t.Fatalf("Failed to create temp directory: %v", err)
 	}
@@ -393,45 +764,106 @@ func TestListModels(t *testing.T) {
 	}
 	for _, modelFile := range expectedModels {
 		modelPath := filepath.Join(tempDir, modelFile)
-		if err := ioutil.WriteFile(modelPath, []byte("mock model data"), 0644); err != nil {
+		if err := os.WriteFile(modelPath, []byte("mock model data"), 0644); err != nil {
 			t.Fatalf("Failed to create mock model file: %v", err)
 		}
 	}
 
 	// Create a non-model file
 	nonModelPath := filepath.Join(tempDir, "not-a-model.txt")
-	if err := ioutil.WriteFile(nonModelPath, []byte("not a model"), 0644); err != nil {
+	if err := os.WriteFile(nonModelPath, []byte("not a model"), 0644); err != nil {
 		t.Fatalf("Failed to create non-model file: %v", err)
 	}
 
+	// Mark one model as loaded so filtering can be checked
+	mm.currentVersion["model1"] = "v1.0"
+	mm.loadedModels["model1"] = "v1.0"
+
 	// Test listing models
-	models, err := mm.ListModels()
+	models, err := mm.ListModels(ListOptions{})
 	if err != nil {
 		t.Fatalf("Failed to list models: %v", err)
 	}
 
-	// Verify the correct models were listed
+	// Verify the correct models were listed, in name order
 	if len(models) != len(expectedModels) {
-		t.Errorf("Expected %d models, got %d", len(expectedModels), len(models))
+		t.Fatalf("Expected %d models, got %d", len(expectedModels), len(models))
 	}
 
-	for _, expectedModel := range expectedModels {
-		var found bool
-		for _, model := range models {
-			if model == expectedModel {
-				found = true
-				break
-			}
+	expected := []struct{ name, version string }{
+		{"model1", "v1.0"},
+		{"model2", "v1.0"},
+		{"model3", "v2.0"},
+	}
+	for i, want := range expected {
+		if models[i].Name != want.name || models[i].Version != want.version {
+			t.Errorf("Expected model %d to be %s %s, got %s %s", i, want.name, want.version, models[i].Name, models[i].Version)
 		}
-		if !found {
-			t.Errorf("Expected model '%s' to be listed", expectedModel)
+		if models[i].Size != int64(len("mock model data")) {
+			t.Errorf("Expected model %s size %d, got %d", models[i].Name, len("mock model data"), models[i].Size)
 		}
 	}
 
+	if !models[0].Loaded || !models[0].Current {
+		t.Errorf("Expected model1 to be reported as current and loaded")
+	}
+	if models[1].Loaded {
+		t.Errorf("Expected model2 to be reported as not loaded")
+	}
+
 	// Verify the non-model file was not listed
 	for _, model := range models {
-		if model == "not-a-model.txt" {
+		if strings.HasSuffix(model.Path, "not-a-model.txt") {
 			t.Errorf("Expected non-model file 'not-a-model.txt' to not be listed")
 		}
 	}
+
+	// Test filtering by name
+	models, err = mm.ListModels(ListOptions{Name: "model3"})
+	if err != nil {
+		t.Fatalf("Failed to list models: %v", err)
+	}
+	if len(models) != 1 || models[0].Name != "model3" {
+		t.Errorf("Expected only model3 when filtering by name, got %v", models)
+	}
+
+	// Test filtering loaded models
+	models, err = mm.ListModels(ListOptions{LoadedOnly: true})
+	if err != nil {
+		t.Fatalf("Failed to list models: %v", err)
+	}
+	if len(models) != 1 || models[0].Name != "model1" {
+		t.Errorf("Expected only model1 when listing loaded models, got %v", models)
+	}
+}
+
+func TestListModelsDashedNames(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "model-manager-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	mm := NewModelManager(tempDir)
+
+	// Versions recorded by the manager are used to split dashed file names
+	if err := os.WriteFile(filepath.Join(tempDir, "llama-2-chat-q4-0.bin"), []byte("mock model data"), 0644); err != nil {
+		t.Fatalf("Failed to create mock model file: %v", err)
+	}
+	mm.recordVersion("llama-2", "chat-q4-0", &VersionRecord{Digest: "abc123"})
+
+	models, err := mm.ListModels(ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed to list models: %v", err)
+	}
+	if len(models) != 1 {
+		t.Fatalf("Expected 1 model, got %d", len(models))
+	}
+	if models[0].Name != "llama-2" || models[0].Version != "chat-q4-0" {
+		t.Errorf("Expected llama-2 chat-q4-0, got %s %s", models[0].Name, models[0].Version)
+	}
+	if models[0].Checksum != "abc123" {
+		t.Errorf("Expected checksum from the manifest, got '%s'", models[0].Checksum)
+	}
 }
@@ -0,0 +1,550 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/h2co32/gollama/internal/metrics"
+	"github.com/h2co32/gollama/pkg/retry"
+)
+
+// APIError is returned when the Ollama server responds with a non-success status.
+type APIError struct {
+	StatusCode int
+	Message    string
+
+	// RetryAfter is the delay requested by the response's Retry-After header, or zero if it had none.
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	return fmt.Sprintf("ollama API error (status %d): %s", e.StatusCode, e.Message)
+}
+
+// HTTPStatus returns the status code of the failed response, so retry.RetryIfHTTPStatus
+// can tell client errors from transient ones.
+func (e *APIError) HTTPStatus() int {
+	return e.StatusCode
+}
+
+// RetryDelay returns the delay requested by the server, so retry.RetryAfterHint can
+// wait as long as the server asked instead of following the backoff schedule.
+func (e *APIError) RetryDelay() (time.Duration, bool) {
+	return e.RetryAfter, e.RetryAfter > 0
+}
+
+// ModelDetails describes the format and size of an Ollama model.
+type ModelDetails struct {
+	ParentModel       string   `json:"parent_model,omitempty"`
+	Format            string   `json:"format,omitempty"`
+	Family            string   `json:"family,omitempty"`
+	Families          []string `json:"families,omitempty"`
+	ParameterSize     string   `json:"parameter_size,omitempty"`
+	QuantizationLevel string   `json:"quantization_level,omitempty"`
+}
+
+// ListModelResponse describes a model available on the Ollama server.
+type ListModelResponse struct {
+	Name       string       `json:"name"`
+	Model      string       `json:"model"`
+	ModifiedAt time.Time    `json:"modified_at"`
+	Size       int64        `json:"size"`
+	Digest     string       `json:"digest"`
+	Details    ModelDetails `json:"details"`
+}
+
+// ListResponse is the response of the List API.
+type ListResponse struct {
+	Models []ListModelResponse `json:"models"`
+}
+
+// ProcessModelResponse describes a model currently loaded by the Ollama server.
+type ProcessModelResponse struct {
+	Name      string       `json:"name"`
+	Model     string       `json:"model"`
+	Size      int64        `json:"size"`
+	Digest    string       `json:"digest"`
+	Details   ModelDetails `json:"details"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	SizeVRAM  int64        `json:"size_vram"`
+}
+
+// ProcessResponse is the response of the Ps API.
+type ProcessResponse struct {
+	Models []ProcessModelResponse `json:"models"`
+}
+
+// ShowRequest is the request body of the Show API.
+type ShowRequest struct {
+	Model   string `json:"model"`
+	Verbose bool   `json:"verbose,omitempty"`
+}
+
+// ShowResponse is the response of the Show API.
+type ShowResponse struct {
+	License    string                 `json:"license,omitempty"`
+	Modelfile  string                 `json:"modelfile,omitempty"`
+	Parameters string                 `json:"parameters,omitempty"`
+	Template   string                 `json:"template,omitempty"`
+	System     string                 `json:"system,omitempty"`
+	Details    ModelDetails           `json:"details"`
+	ModelInfo  map[string]interface{} `json:"model_info,omitempty"`
+	ModifiedAt time.Time              `json:"modified_at"`
+}
+
+// PullRequest is the request body of the Pull API.
+type PullRequest struct {
+	Model    string `json:"model"`
+	Insecure bool   `json:"insecure,omitempty"`
+}
+
+// PushRequest is the request body of the Push API.
+type PushRequest struct {
+	Model    string `json:"model"`
+	Insecure bool   `json:"insecure,omitempty"`
+}
+
+// ProgressResponse reports progress of a Pull or Push.
+type ProgressResponse struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+}
+
+// ProgressFunc is called for every progress update of a Pull or Push.
+type ProgressFunc func(ProgressResponse)
+
+// CopyRequest is the request body of the Copy API.
+type CopyRequest struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
+// DeleteRequest is the request body of the Delete API.
+type DeleteRequest struct {
+	Model string `json:"model"`
+}
+
+// EmbeddingRequest is the request body of the Embeddings API.
+type EmbeddingRequest struct {
+	Model     string                 `json:"model"`
+	Prompt    string                 `json:"prompt"`
+	KeepAlive string                 `json:"keep_alive,omitempty"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+}
+
+// EmbeddingResponse is the response of the Embeddings API.
+type EmbeddingResponse struct {
+	Embedding []float64 `json:"embedding"`
+}
+
+// EmbedRequest is the request body of the Embed API, which embeds several inputs in one
+// call.
+type EmbedRequest struct {
+	Model     string                 `json:"model"`
+	Input     []string               `json:"input"`
+	Truncate  *bool                  `json:"truncate,omitempty"` // Default: true
+	KeepAlive string                 `json:"keep_alive,omitempty"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+}
+
+// EmbedResponse is the response of the Embed API, with one embedding per input.
+type EmbedResponse struct {
+	Model           string      `json:"model"`
+	Embeddings      [][]float32 `json:"embeddings"`
+	TotalDuration   int64       `json:"total_duration,omitempty"`
+	LoadDuration    int64       `json:"load_duration,omitempty"`
+	PromptEvalCount int         `json:"prompt_eval_count,omitempty"`
+}
+
+// Message is a single chat message.
+type Message struct {
+	Role      string     `json:"role"` // "system", "user", "assistant", or "tool"
+	Content   string     `json:"content"`
+	Images    []string   `json:"images,omitempty"`     // Base64-encoded images for multimodal models
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"` // Tools the assistant asks to call
+	ToolName  string     `json:"tool_name,omitempty"`  // Tool whose result a "tool" message holds
+}
+
+// Tool describes a function the model may call. Type is "function".
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction describes the name, purpose, and arguments of a tool.
+type ToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"` // JSON schema of the arguments object
+}
+
+// ToolCall is a call to a tool requested by the model.
+type ToolCall struct {
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction is the tool and arguments of a tool call.
+type ToolCallFunction struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"` // JSON object matching the tool's parameters
+}
+
+// DecodeArguments unmarshals the call's arguments into v. Arguments encoded as a JSON
+// string, as OpenAI-compatible servers send them, are unquoted first.
+func (c ToolCall) DecodeArguments(v interface{}) error {
+	arguments := c.Function.Arguments
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+	if arguments[0] == '"' {
+		var encoded string
+		if err := json.Unmarshal(arguments, &encoded); err == nil {
+			arguments = json.RawMessage(encoded)
+		}
+	}
+	if err := json.Unmarshal(arguments, v); err != nil {
+		return fmt.Errorf("failed to decode arguments of tool %s: %w", c.Function.Name, err)
+	}
+	return nil
+}
+
+// ChatRequest is the request body of the Chat API.
+type ChatRequest struct {
+	Model     string                 `json:"model"`
+	Messages  []Message              `json:"messages"`
+	Tools     []Tool                 `json:"tools,omitempty"`  // Tools the model may call
+	Stream    *bool                  `json:"stream,omitempty"` // Default: true
+	Format    string                 `json:"format,omitempty"` // "json" for JSON mode
+	KeepAlive string                 `json:"keep_alive,omitempty"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+}
+
+// Metrics reports token counts and timings for a completed generation.
+type Metrics struct {
+	TotalDuration      time.Duration `json:"total_duration,omitempty"`
+	LoadDuration       time.Duration `json:"load_duration,omitempty"`
+	PromptEvalCount    int           `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration time.Duration `json:"prompt_eval_duration,omitempty"`
+	EvalCount          int           `json:"eval_count,omitempty"`
+	EvalDuration       time.Duration `json:"eval_duration,omitempty"`
+}
+
+// QueueDuration returns the part of TotalDuration not spent loading the model or
+// evaluating tokens, which is mostly time the request waited to be scheduled.
+func (m Metrics) QueueDuration() time.Duration {
+	return max(m.TotalDuration-m.LoadDuration-m.PromptEvalDuration-m.EvalDuration, 0)
+}
+
+// inferenceTracker records the metrics of a generation as its chunks arrive, and the
+// request in the usage of the model manager once it completes.
+type inferenceTracker struct {
+	client    *OllamaClient
+	model     string
+	streaming bool
+	start     time.Time
+	first     time.Duration // Time to the first generated token, or zero before it
+}
+
+// trackInference starts tracking a generation of model.
+func (c *OllamaClient) trackInference(model string, stream *bool) *inferenceTracker {
+	return &inferenceTracker{
+		client:    c,
+		model:     model,
+		streaming: stream == nil || *stream,
+		start:     time.Now(),
+	}
+}
+
+// chunk records a chunk with the given generated content, and the whole generation
+// once the chunk is the last one.
+func (t *inferenceTracker) chunk(model, content string, done bool, m Metrics) {
+	if t.first == 0 && content != "" && t.streaming {
+		t.first = time.Since(t.start)
+	}
+	if !done {
+		return
+	}
+	t.client.modelManager.RecordRequest(t.model, time.Since(t.start))
+	if t.client.metrics == nil {
+		return
+	}
+	if model == "" {
+		model = t.model
+	}
+	if !t.streaming {
+		// The whole response arrives at once, so use the server's timings
+		t.first = m.QueueDuration() + m.LoadDuration + m.PromptEvalDuration
+	}
+	t.client.metrics.TrackInference(metrics.Inference{
+		Model:            model,
+		Backend:          t.client.backend,
+		PromptTokens:     m.PromptEvalCount,
+		CompletionTokens: m.EvalCount,
+		TimeToFirstToken: t.first,
+		GenerationTime:   m.EvalDuration,
+		QueueTime:        m.QueueDuration(),
+	})
+}
+
+// ChatResponse is a response, or a chunk of a streamed response, from the Chat API.
+type ChatResponse struct {
+	Model      string    `json:"model"`
+	CreatedAt  time.Time `json:"created_at"`
+	Message    Message   `json:"message"`
+	Done       bool      `json:"done"`
+	DoneReason string    `json:"done_reason,omitempty"`
+	Metrics
+}
+
+// ChatResponseFunc is called for every chunk of a chat response.
+type ChatResponseFunc func(ChatResponse) error
+
+// Chat sends a chat conversation to a model. fn is called for every streamed chunk,
+// or once with the whole response when req.Stream is false. Returning an error from
+// fn stops the stream and is returned by Chat.
+func (c *OllamaClient) Chat(ctx context.Context, req ChatRequest, fn ChatResponseFunc) error {
+	tracker := c.trackInference(req.Model, req.Stream)
+	return c.stream(ctx, "/api/chat", req, func(line []byte) error {
+		var res ChatResponse
+		if err := json.Unmarshal(line, &res); err != nil {
+			return fmt.Errorf("failed to unmarshal chat response: %w", err)
+		}
+		tracker.chunk(res.Model, res.Message.Content, res.Done, res.Metrics)
+		return fn(res)
+	})
+}
+
+// GenerateRequest is the request body of the Generate API.
+type GenerateRequest struct {
+	Model     string                 `json:"model"`
+	Prompt    string                 `json:"prompt"`
+	System    string                 `json:"system,omitempty"`
+	Template  string                 `json:"template,omitempty"`
+	Context   []int                  `json:"context,omitempty"` // Context from a previous response, for a short conversational memory
+	Stream    *bool                  `json:"stream,omitempty"`  // Default: true
+	Raw       bool                   `json:"raw,omitempty"`     // Send the prompt without applying the model's template
+	Format    string                 `json:"format,omitempty"`  // "json" for JSON mode
+	KeepAlive string                 `json:"keep_alive,omitempty"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+}
+
+// GenerateResponse is a response, or a chunk of a streamed response, from the Generate API.
+type GenerateResponse struct {
+	Model      string    `json:"model"`
+	CreatedAt  time.Time `json:"created_at"`
+	Response   string    `json:"response"`
+	Done       bool      `json:"done"`
+	DoneReason string    `json:"done_reason,omitempty"`
+	Context    []int     `json:"context,omitempty"`
+	Metrics
+}
+
+// GenerateResponseFunc is called for every chunk of a generate response.
+type GenerateResponseFunc func(GenerateResponse) error
+
+// Generate sends a prompt to a model. fn is called for every streamed chunk, or once
+// with the whole response when req.Stream is false. Returning an error from fn stops
+// the stream and is returned by Generate.
+func (c *OllamaClient) Generate(ctx context.Context, req GenerateRequest, fn GenerateResponseFunc) error {
+	tracker := c.trackInference(req.Model, req.Stream)
+	return c.stream(ctx, "/api/generate", req, func(line []byte) error {
+		var res GenerateResponse
+		if err := json.Unmarshal(line, &res); err != nil {
+			return fmt.Errorf("failed to unmarshal generate response: %w", err)
+		}
+		tracker.chunk(res.Model, res.Response, res.Done, res.Metrics)
+		return fn(res)
+	})
+}
+
+// List returns the models available on the Ollama server.
+func (c *OllamaClient) List(ctx context.Context) (*ListResponse, error) {
+	var res ListResponse
+	if err := c.do(ctx, http.MethodGet, "/api/tags", nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Ps returns the models currently loaded into memory by the Ollama server.
+func (c *OllamaClient) Ps(ctx context.Context) (*ProcessResponse, error) {
+	var res ProcessResponse
+	if err := c.do(ctx, http.MethodGet, "/api/ps", nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Show returns details about a model, including its Modelfile, template, and parameters.
+func (c *OllamaClient) Show(ctx context.Context, req ShowRequest) (*ShowResponse, error) {
+	var res ShowResponse
+	if err := c.do(ctx, http.MethodPost, "/api/show", req, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Pull downloads a model from the Ollama library to the server.
+// fn, if not nil, is called for every progress update.
+func (c *OllamaClient) Pull(ctx context.Context, req PullRequest, fn ProgressFunc) error {
+	return c.progress(ctx, "/api/pull", req, fn)
+}
+
+// Push uploads a model from the server to a model library.
+// fn, if not nil, is called for every progress update.
+func (c *OllamaClient) Push(ctx context.Context, req PushRequest, fn ProgressFunc) error {
+	return c.progress(ctx, "/api/push", req, fn)
+}
+
+// Copy creates a model with another name from an existing model.
+func (c *OllamaClient) Copy(ctx context.Context, req CopyRequest) error {
+	return c.do(ctx, http.MethodPost, "/api/copy", req, nil)
+}
+
+// Delete removes a model and its data from the Ollama server.
+func (c *OllamaClient) Delete(ctx context.Context, req DeleteRequest) error {
+	return c.do(ctx, http.MethodDelete, "/api/delete", req, nil)
+}
+
+// Embeddings generates an embedding vector for a prompt.
+func (c *OllamaClient) Embeddings(ctx context.Context, req EmbeddingRequest) (*EmbeddingResponse, error) {
+	var res EmbeddingResponse
+	if err := c.do(ctx, http.MethodPost, "/api/embeddings", req, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Embed generates an embedding vector for each input in one call. Ollama normalizes
+// these vectors to unit length.
+func (c *OllamaClient) Embed(ctx context.Context, req EmbedRequest) (*EmbedResponse, error) {
+	var res EmbedResponse
+	if err := c.do(ctx, http.MethodPost, "/api/embed", req, &res); err != nil {
+		return nil, err
+	}
+	if len(res.Embeddings) != len(req.Input) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(req.Input), len(res.Embeddings))
+	}
+	return &res, nil
+}
+
+// progress sends a streaming request and reports each progress update to fn.
+func (c *OllamaClient) progress(ctx context.Context, path string, req interface{}, fn ProgressFunc) error {
+	return c.stream(ctx, path, req, func(line []byte) error {
+		var update ProgressResponse
+		if err := json.Unmarshal(line, &update); err != nil {
+			return fmt.Errorf("failed to unmarshal progress: %w", err)
+		}
+		if fn != nil {
+			fn(update)
+		}
+		return nil
+	})
+}
+
+// do sends a request with an optional JSON body and decodes the JSON response into out, if not nil.
+func (c *OllamaClient) do(ctx context.Context, method, path string, body, out interface{}) error {
+	res, err := c.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if out == nil {
+		_, _ = io.Copy(io.Discard, res.Body)
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// stream sends a POST request and calls fn with each newline-delimited JSON object in the response.
+// An object carrying an "error" field ends the stream with that error.
+func (c *OllamaClient) stream(ctx context.Context, path string, body interface{}, fn func([]byte) error) error {
+	res, err := c.send(ctx, http.MethodPost, path, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var streamErr struct {
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(line, &streamErr); err == nil && streamErr.Error != "" {
+			return &APIError{StatusCode: res.StatusCode, Message: streamErr.Error}
+		}
+
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read stream: %w", err)
+	}
+	return nil
+}
+
+// send issues a request to the Ollama server and returns the response if its status is successful.
+func (c *OllamaClient) send(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to %s failed: %w", path, err)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		defer res.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(res.Body, 64*1024))
+
+		apiErr := &APIError{StatusCode: res.StatusCode, Message: http.StatusText(res.StatusCode)}
+		if delay, ok := retry.ParseRetryAfter(res.Header.Get("Retry-After")); ok {
+			apiErr.RetryAfter = delay
+		}
+		var errBody struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &errBody) == nil && errBody.Error != "" {
+			apiErr.Message = errBody.Error
+		} else if len(bytes.TrimSpace(data)) > 0 {
+			apiErr.Message = string(bytes.TrimSpace(data))
+		}
+		return nil, apiErr
+	}
+	return res, nil
+}
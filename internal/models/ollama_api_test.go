@@ -0,0 +1,399 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/h2co32/gollama/internal/metrics"
+	"github.com/h2co32/gollama/pkg/auth"
+	"github.com/h2co32/gollama/pkg/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// newTestOllamaClient creates a client pointed at a test server using handler.
+func newTestOllamaClient(t *testing.T, handler http.HandlerFunc) *OllamaClient {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return NewOllamaClientWithOptions(OllamaClientOptions{
+		BaseURL:  server.URL,
+		ModelDir: t.TempDir(),
+	})
+}
+
+func TestOllamaClientList(t *testing.T) {
+	client := newTestOllamaClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/api/tags" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		fmt.Fprint(w, `{"models":[{"name":"llama3:latest","size":4661224676,"digest":"abc","details":{"family":"llama","parameter_size":"8.0B"}}]}`)
+	})
+
+	res, err := client.List(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to list models: %v", err)
+	}
+	if len(res.Models) != 1 {
+		t.Fatalf("Expected 1 model, got %d", len(res.Models))
+	}
+	if res.Models[0].Name != "llama3:latest" || res.Models[0].Details.ParameterSize != "8.0B" {
+		t.Errorf("Unexpected model in response: %+v", res.Models[0])
+	}
+}
+
+func TestOllamaClientShowAndEmbeddings(t *testing.T) {
+	client := newTestOllamaClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		switch r.URL.Path {
+		case "/api/show":
+			if body["model"] != "llama3" {
+				t.Errorf("Expected model 'llama3', got %v", body["model"])
+			}
+			fmt.Fprint(w, `{"template":"{{ .Prompt }}","details":{"format":"gguf"}}`)
+		case "/api/embeddings":
+			if body["prompt"] != "hello" {
+				t.Errorf("Expected prompt 'hello', got %v", body["prompt"])
+			}
+			fmt.Fprint(w, `{"embedding":[0.1,0.2,0.3]}`)
+		default:
+			t.Errorf("Unexpected request path %s", r.URL.Path)
+		}
+	})
+
+	show, err := client.Show(context.Background(), ShowRequest{Model: "llama3"})
+	if err != nil {
+		t.Fatalf("Failed to show model: %v", err)
+	}
+	if show.Template != "{{ .Prompt }}" || show.Details.Format != "gguf" {
+		t.Errorf("Unexpected show response: %+v", show)
+	}
+
+	emb, err := client.Embeddings(context.Background(), EmbeddingRequest{Model: "llama3", Prompt: "hello"})
+	if err != nil {
+		t.Fatalf("Failed to create embeddings: %v", err)
+	}
+	if len(emb.Embedding) != 3 {
+		t.Errorf("Expected 3 embedding dimensions, got %d", len(emb.Embedding))
+	}
+}
+
+func TestOllamaClientEmbed(t *testing.T) {
+	client := newTestOllamaClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/embed" {
+			t.Errorf("Unexpected request path %s", r.URL.Path)
+		}
+		var req EmbedRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		if req.Model != "nomic-embed-text" || len(req.Input) == 0 {
+			t.Errorf("Unexpected request %+v", req)
+		}
+		fmt.Fprint(w, `{"model":"nomic-embed-text","embeddings":[[0.6,0.8],[1,0]],"prompt_eval_count":2}`)
+	})
+
+	res, err := client.Embed(context.Background(), EmbedRequest{Model: "nomic-embed-text", Input: []string{"hello", "world"}})
+	if err != nil {
+		t.Fatalf("Failed to embed: %v", err)
+	}
+	if len(res.Embeddings) != 2 || res.Embeddings[0][1] != 0.8 || res.PromptEvalCount != 2 {
+		t.Errorf("Unexpected embed response: %+v", res)
+	}
+
+	if _, err := client.Embed(context.Background(), EmbedRequest{Model: "nomic-embed-text", Input: []string{"one"}}); err == nil {
+		t.Error("Expected error when the number of embeddings does not match the inputs")
+	}
+}
+
+func TestOllamaClientPullProgress(t *testing.T) {
+	client := newTestOllamaClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/pull" {
+			t.Errorf("Unexpected request path %s", r.URL.Path)
+		}
+		fmt.Fprintln(w, `{"status":"pulling manifest"}`)
+		fmt.Fprintln(w, `{"status":"downloading","digest":"sha256:abc","total":100,"completed":50}`)
+		fmt.Fprintln(w, `{"status":"success"}`)
+	})
+
+	var updates []ProgressResponse
+	err := client.Pull(context.Background(), PullRequest{Model: "llama3"}, func(p ProgressResponse) {
+		updates = append(updates, p)
+	})
+	if err != nil {
+		t.Fatalf("Failed to pull model: %v", err)
+	}
+	if len(updates) != 3 {
+		t.Fatalf("Expected 3 progress updates, got %d", len(updates))
+	}
+	if updates[1].Completed != 50 || updates[2].Status != "success" {
+		t.Errorf("Unexpected progress updates: %+v", updates)
+	}
+}
+
+func TestOllamaClientStreamError(t *testing.T) {
+	client := newTestOllamaClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"status":"pulling manifest"}`)
+		fmt.Fprintln(w, `{"error":"pull model manifest: file does not exist"}`)
+	})
+
+	err := client.Push(context.Background(), PushRequest{Model: "missing"}, nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected APIError, got %v", err)
+	}
+	if apiErr.Message != "pull model manifest: file does not exist" {
+		t.Errorf("Unexpected error message: %s", apiErr.Message)
+	}
+}
+
+func TestOllamaClientCopyDeleteAndErrors(t *testing.T) {
+	client := newTestOllamaClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/copy":
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodDelete && r.URL.Path == "/api/delete":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":"model 'missing' not found"}`)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	})
+
+	if err := client.Copy(context.Background(), CopyRequest{Source: "llama3", Destination: "llama3-backup"}); err != nil {
+		t.Fatalf("Failed to copy model: %v", err)
+	}
+
+	err := client.Delete(context.Background(), DeleteRequest{Model: "missing"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected APIError, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound || apiErr.Message != "model 'missing' not found" {
+		t.Errorf("Unexpected API error: %+v", apiErr)
+	}
+}
+
+func TestOllamaClientRetryAfter(t *testing.T) {
+	client := newTestOllamaClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	_, err := client.List(context.Background())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected APIError, got %v", err)
+	}
+	if delay, ok := apiErr.RetryDelay(); !ok || delay != 3*time.Second {
+		t.Errorf("Expected a retry delay of 3s, got %v (ok=%v)", delay, ok)
+	}
+	if apiErr.HTTPStatus() != http.StatusTooManyRequests {
+		t.Errorf("Expected status %d, got %d", http.StatusTooManyRequests, apiErr.HTTPStatus())
+	}
+}
+
+func TestOllamaClientPs(t *testing.T) {
+	client := newTestOllamaClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"models":[{"name":"llama3:latest","size":5137025024,"size_vram":5137025024}]}`)
+	})
+
+	res, err := client.Ps(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to list running models: %v", err)
+	}
+	if len(res.Models) != 1 || res.Models[0].SizeVRAM != 5137025024 {
+		t.Errorf("Unexpected ps response: %+v", res)
+	}
+}
+
+func TestOllamaClientChat(t *testing.T) {
+	client := newTestOllamaClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		if len(req.Messages) != 1 || req.Messages[0].Content != "Hi" {
+			t.Errorf("Unexpected messages: %+v", req.Messages)
+		}
+		fmt.Fprintln(w, `{"model":"llama3","message":{"role":"assistant","content":"Hel"},"done":false}`)
+		fmt.Fprintln(w, `{"model":"llama3","message":{"role":"assistant","content":"lo"},"done":false}`)
+		fmt.Fprintln(w, `{"model":"llama3","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","eval_count":2}`)
+	})
+
+	var content string
+	var final ChatResponse
+	err := client.Chat(context.Background(), ChatRequest{
+		Model:    "llama3",
+		Messages: []Message{{Role: "user", Content: "Hi"}},
+	}, func(res ChatResponse) error {
+		content += res.Message.Content
+		if res.Done {
+			final = res
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to chat: %v", err)
+	}
+	if content != "Hello" {
+		t.Errorf("Expected streamed content 'Hello', got '%s'", content)
+	}
+	if final.DoneReason != "stop" || final.EvalCount != 2 {
+		t.Errorf("Unexpected final chunk: %+v", final)
+	}
+}
+
+func TestOllamaClientGenerate(t *testing.T) {
+	client := newTestOllamaClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/generate" {
+			t.Errorf("Expected path /api/generate, got %s", r.URL.Path)
+		}
+		var req GenerateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		if req.Prompt != "Why?" || req.System != "Be brief." {
+			t.Errorf("Unexpected request: %+v", req)
+		}
+		fmt.Fprintln(w, `{"model":"llama3","response":"Be","done":false}`)
+		fmt.Fprintln(w, `{"model":"llama3","response":"cause","done":false}`)
+		fmt.Fprintln(w, `{"model":"llama3","response":"","done":true,"context":[1,2,3],"eval_count":2}`)
+	})
+
+	var response string
+	var final GenerateResponse
+	err := client.Generate(context.Background(), GenerateRequest{
+		Model:  "llama3",
+		Prompt: "Why?",
+		System: "Be brief.",
+	}, func(res GenerateResponse) error {
+		response += res.Response
+		if res.Done {
+			final = res
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate: %v", err)
+	}
+	if response != "Because" {
+		t.Errorf("Expected streamed response 'Because', got '%s'", response)
+	}
+	if len(final.Context) != 3 || final.EvalCount != 2 {
+		t.Errorf("Unexpected final chunk: %+v", final)
+	}
+}
+
+func TestOllamaClientMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/chat" {
+			// Not streamed: 4s in total, of which 1s loading, 0.5s on the prompt, and 2s generating
+			fmt.Fprintln(w, `{"model":"mistral","message":{"role":"assistant","content":"Hi"},"done":true,"total_duration":4000000000,"load_duration":1000000000,"prompt_eval_count":5,"prompt_eval_duration":500000000,"eval_count":20,"eval_duration":2000000000}`)
+			return
+		}
+		fmt.Fprintln(w, `{"model":"llama3","response":"","done":false}`)
+		w.(http.Flusher).Flush()
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprintln(w, `{"model":"llama3","response":"Hi","done":false}`)
+		fmt.Fprintln(w, `{"model":"llama3","response":"","done":true,"prompt_eval_count":12,"eval_count":10,"eval_duration":500000000}`)
+	}))
+	t.Cleanup(server.Close)
+
+	registry := prometheus.NewRegistry()
+	provider, err := metrics.NewMetricsProviderWithOptions(metrics.MetricsOptions{Registry: registry})
+	if err != nil {
+		t.Fatalf("Failed to create metrics provider: %v", err)
+	}
+	client := NewOllamaClientWithOptions(OllamaClientOptions{BaseURL: server.URL, ModelDir: t.TempDir(), Metrics: provider})
+
+	if err := client.Generate(context.Background(), GenerateRequest{Model: "llama3"}, func(GenerateResponse) error { return nil }); err != nil {
+		t.Fatalf("Failed to generate: %v", err)
+	}
+	stream := false
+	if err := client.Chat(context.Background(), ChatRequest{Model: "mistral", Stream: &stream}, func(ChatResponse) error { return nil }); err != nil {
+		t.Fatalf("Failed to chat: %v", err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			key := family.GetName()
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "backend" && label.GetValue() != strings.TrimPrefix(server.URL, "http://") {
+					t.Errorf("Expected the backend label to be the server's host, got %s", label.GetValue())
+				}
+				if label.GetName() == "model" {
+					key += " " + label.GetValue()
+				}
+			}
+			switch {
+			case metric.GetCounter() != nil:
+				values[key] = metric.GetCounter().GetValue()
+			case metric.GetHistogram() != nil:
+				values[key] = metric.GetHistogram().GetSampleSum()
+			}
+		}
+	}
+
+	for key, expected := range map[string]float64{
+		"llm_prompt_tokens_total llama3":          12,
+		"llm_completion_tokens_total llama3":      10,
+		"llm_tokens_per_second llama3":            20,
+		"llm_prompt_tokens_total mistral":         5,
+		"llm_completion_tokens_total mistral":     20,
+		"llm_tokens_per_second mistral":           10,
+		"llm_queue_time_seconds mistral":          0.5,
+		"llm_time_to_first_token_seconds mistral": 2,
+	} {
+		if values[key] != expected {
+			t.Errorf("Expected %s to be %v, got %v", key, expected, values[key])
+		}
+	}
+	// Streamed responses are timed by the client, from the first chunk with content
+	if ttft := values["llm_time_to_first_token_seconds llama3"]; ttft < 0.02 || ttft > 5 {
+		t.Errorf("Expected a time to first token of at least 20ms, got %vs", ttft)
+	}
+	if _, ok := values["llm_queue_time_seconds llama3"]; ok {
+		t.Error("Expected no queue time without the server's total duration")
+	}
+}
+
+func TestOllamaClientMiddleware(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := auth.ValidateJWT("jwt-secret", strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")); err != nil {
+			http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"models":[]}`)
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewOllamaClientWithOptions(OllamaClientOptions{
+		BaseURL:    server.URL,
+		ModelDir:   t.TempDir(),
+		Middleware: []middleware.Middleware{middleware.NewJWTClientAuth("jwt-secret", nil, auth.DefaultJWTOptions())},
+	})
+	if _, err := client.List(context.Background()); err != nil {
+		t.Errorf("Expected authenticated request to succeed, got %v", err)
+	}
+
+	// Requests without the middleware are rejected by the same server
+	client = NewOllamaClientWithOptions(OllamaClientOptions{BaseURL: server.URL, ModelDir: t.TempDir()})
+	if _, err := client.List(context.Background()); err == nil {
+		t.Error("Expected unauthenticated request to fail")
+	}
+}
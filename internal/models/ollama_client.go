@@ -1,18 +1,72 @@
 package models
 
 import (
-	"fmt"
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/h2co32/gollama/internal/metrics"
+	"github.com/h2co32/gollama/pkg/middleware"
 )
 
+// DefaultOllamaURL is the address of a local Ollama server.
+const DefaultOllamaURL = "http://localhost:11434"
+
 // OllamaClient provides a client for interacting with Ollama models
 type OllamaClient struct {
 	modelManager *ModelManager
+	baseURL      string
+	httpClient   *http.Client
+	metrics      *metrics.MetricsProvider
+	backend      string // Backend label in the metrics
+}
+
+// OllamaClientOptions configures an OllamaClient.
+type OllamaClientOptions struct {
+	// BaseURL is the address of the Ollama server.
+	// Default: DefaultOllamaURL
+	BaseURL string
+
+	// HTTPClient is the client used for requests to the Ollama server.
+	// Default: a client with a 5 minute timeout
+	HTTPClient *http.Client
+
+	// ModelDir is the directory used by the local model manager.
+	// Default: "./models"
+	ModelDir string
+
+	// Middleware is applied to every outbound request, both to the Ollama server and
+	// to the model registry, for example to authenticate with middleware.JWTClientAuth,
+	// middleware.HMACClientAuth, or middleware.OAuthClientAuth.
+	// Optional.
+	Middleware []middleware.Middleware
+
+	// Metrics records the token counts, generation speed, time to first token, and
+	// queue time of Generate and Chat requests, labeled by model and by the server's
+	// host, and the usage of each model. Optional.
+	Metrics *metrics.MetricsProvider
+
+	// Signatures is the policy downloaded models are verified against.
+	// Optional. Without trusted keys, signatures are not checked.
+	Signatures SignaturePolicy
+}
+
+// DefaultOllamaClientOptions returns the default client options.
+func DefaultOllamaClientOptions() OllamaClientOptions {
+	return OllamaClientOptions{
+		BaseURL:    DefaultOllamaURL,
+		HTTPClient: &http.Client{Timeout: 5 * time.Minute},
+		ModelDir:   "./models",
+	}
 }
 
 // DownloadModelRequest represents a request to download a model
 type DownloadModelRequest struct {
-	Model   string
-	Version string
+	Model    string
+	Version  string
+	Progress DownloadProgressFunc // Receives the progress of the download; optional
 }
 
 // ModelFineTuningRequest represents a request to fine-tune a model
@@ -23,8 +77,48 @@ type ModelFineTuningRequest struct {
 
 // NewOllamaClient creates a new client for interacting with Ollama models
 func NewOllamaClient() *OllamaClient {
+	return NewOllamaClientWithOptions(DefaultOllamaClientOptions())
+}
+
+// NewOllamaClientWithOptions creates a new client with custom options.
+// Zero values fall back to the defaults.
+func NewOllamaClientWithOptions(options OllamaClientOptions) *OllamaClient {
+	defaults := DefaultOllamaClientOptions()
+	if options.BaseURL == "" {
+		options.BaseURL = defaults.BaseURL
+	}
+	if options.HTTPClient == nil {
+		options.HTTPClient = defaults.HTTPClient
+	}
+	if options.ModelDir == "" {
+		options.ModelDir = defaults.ModelDir
+	}
+
+	modelManager := NewModelManager(options.ModelDir)
+	modelManager.SetSignaturePolicy(options.Signatures)
+	modelManager.SetMetrics(options.Metrics)
+	if len(options.Middleware) > 0 {
+		// Copy the client so the caller's client is not modified
+		httpClient := *options.HTTPClient
+		httpClient.Transport = &middleware.Transport{Base: httpClient.Transport, Middleware: options.Middleware}
+		options.HTTPClient = &httpClient
+
+		registryClient := *modelManager.httpClient
+		registryClient.Transport = &middleware.Transport{Base: registryClient.Transport, Middleware: options.Middleware}
+		modelManager.httpClient = &registryClient
+	}
+
+	backend := options.BaseURL
+	if u, err := url.Parse(options.BaseURL); err == nil && u.Host != "" {
+		backend = u.Host
+	}
+
 	return &OllamaClient{
-		modelManager: NewModelManager("./models"),
+		modelManager: modelManager,
+		baseURL:      strings.TrimRight(options.BaseURL, "/"),
+		httpClient:   options.HTTPClient,
+		metrics:      options.Metrics,
+		backend:      backend,
 	}
 }
 
@@ -34,19 +128,26 @@ func (c *OllamaClient) DownloadModel(req DownloadModelRequest) error {
 	if req.Version != "" {
 		version = req.Version
 	}
-	
-	fmt.Printf("Downloading model %s (version %s)\n", req.Model, version)
-	return c.modelManager.DownloadModel(req.Model, version)
+
+	return c.modelManager.DownloadModelProgress(context.Background(), req.Model, version, req.Progress)
 }
 
-// PreloadModels preloads multiple models for faster inference
-func (c *OllamaClient) PreloadModels(models []string) {
-	fmt.Printf("Preloading models: %v\n", models)
-	c.modelManager.PreloadModels(models)
+// PreloadModels preloads multiple models for faster inference.
+// The returned map contains an entry for every model that failed to load.
+func (c *OllamaClient) PreloadModels(models []string) map[string]error {
+	return c.modelManager.PreloadModels(models)
+}
+
+// Preload loads multiple models and warms each one up with a generate request to the
+// Ollama server, unless opts sets another Warmer. See ModelManager.Preload.
+func (c *OllamaClient) Preload(ctx context.Context, models []string, opts PreloadOptions) []PreloadResult {
+	if opts.Warmer == nil {
+		opts.Warmer = c
+	}
+	return c.modelManager.Preload(ctx, models, opts)
 }
 
 // FineTuneModel fine-tunes a model with a specific dataset
 func (c *OllamaClient) FineTuneModel(req ModelFineTuningRequest) error {
-	fmt.Printf("Fine-tuning model %s with dataset %s\n", req.ModelVersion, req.Dataset)
 	return c.modelManager.FineTuneModel(req.ModelVersion, req.Dataset)
 }
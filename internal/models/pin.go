@@ -0,0 +1,59 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/h2co32/gollama/pkg/logging"
+)
+
+// ErrPinned is returned when an operation would remove or replace a pinned model version.
+var ErrPinned = errors.New("model version is pinned")
+
+// PinModel pins a stored model version. A pinned version cannot be deleted or replaced
+// by an import, and while it is the model's current version the model is never evicted
+// to fit the memory budget. Pins are recorded in the manifest, so they survive restarts.
+func (mm *ModelManager) PinModel(modelName, version string) error {
+	return mm.setPinned(modelName, version, true)
+}
+
+// UnpinModel removes the pin from a model version. Unpinning a version that is not
+// pinned does nothing.
+func (mm *ModelManager) UnpinModel(modelName, version string) error {
+	return mm.setPinned(modelName, version, false)
+}
+
+// setPinned records whether a model version is pinned and saves the manifest.
+func (mm *ModelManager) setPinned(modelName, version string, pinned bool) error {
+	defer mm.lockModel(modelName)()
+
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+
+	record := mm.versions[modelName][version]
+	if record == nil {
+		return fmt.Errorf("%w: %s version %s", ErrNotRecorded, modelName, version)
+	}
+	if record.Pinned == pinned {
+		return nil
+	}
+	record.Pinned = pinned
+	if err := mm.saveManifest(); err != nil {
+		record.Pinned = !pinned
+		return err
+	}
+
+	if pinned {
+		logging.Default().Info("pinned model", "model", modelName, "version", version)
+	} else {
+		logging.Default().Info("unpinned model", "model", modelName, "version", version)
+	}
+	return nil
+}
+
+// pinned reports whether a model version is pinned.
+// This method is not thread-safe and should be called with the lock held.
+func (mm *ModelManager) pinned(modelName, version string) bool {
+	record := mm.versions[modelName][version]
+	return record != nil && record.Pinned
+}
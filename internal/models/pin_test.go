@@ -0,0 +1,94 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPinModel(t *testing.T) {
+	modelData := []byte("weights")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "model.bin", time.Time{}, bytes.NewReader(modelData))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	mm := NewModelManager(dir)
+	mm.registryURL = server.URL
+	for _, version := range []string{"v1", "v2"} {
+		if err := mm.DownloadModel("llama", version); err != nil {
+			t.Fatalf("Failed to download model: %v", err)
+		}
+	}
+
+	if err := mm.PinModel("llama", "v9"); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("Expected ErrNotRecorded, got %v", err)
+	}
+	if err := mm.PinModel("llama", "v1"); err != nil {
+		t.Fatalf("Failed to pin model: %v", err)
+	}
+
+	// Pinned versions cannot be deleted
+	if err := mm.DeleteModel("llama", "v1"); !errors.Is(err, ErrPinned) {
+		t.Errorf("Expected ErrPinned, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "llama-v1.bin")); err != nil {
+		t.Errorf("Expected the pinned version to remain: %v", err)
+	}
+
+	// The pin is recorded in the manifest and reported by ListModels
+	infos, err := NewModelManager(dir).ListModels(ListOptions{Name: "llama"})
+	if err != nil {
+		t.Fatalf("Failed to list models: %v", err)
+	}
+	if len(infos) != 2 || !infos[0].Pinned || infos[1].Pinned {
+		t.Errorf("Expected only v1 to be pinned, got %+v", infos)
+	}
+
+	// Unpinning allows the version to be deleted
+	if err := mm.UnpinModel("llama", "v1"); err != nil {
+		t.Fatalf("Failed to unpin model: %v", err)
+	}
+	if err := mm.UnpinModel("llama", "v1"); err != nil {
+		t.Errorf("Expected unpinning twice to succeed, got %v", err)
+	}
+	if err := mm.DeleteModel("llama", "v1"); err != nil {
+		t.Errorf("Failed to delete unpinned model: %v", err)
+	}
+}
+
+func TestPinnedModelIsNotEvicted(t *testing.T) {
+	mm := newTestManager(t, "model1", "model2")
+	mm.recordVersion("model1", "v1.0", &VersionRecord{})
+	if err := mm.PinModel("model1", "v1.0"); err != nil {
+		t.Fatalf("Failed to pin model: %v", err)
+	}
+	mm.SetBackend(&fakeBackend{size: 100})
+	mm.SetMemoryBudget(150)
+
+	for _, name := range []string{"model1", "model2"} {
+		if err := mm.LoadModel(name); err != nil {
+			t.Fatalf("Failed to load model %s: %v", name, err)
+		}
+	}
+
+	// model1 is least recently used, but pinned, so it stays loaded over the budget
+	if mm.loadedModels["model1"] == "" || mm.loadedModels["model2"] == "" {
+		t.Error("Expected the pinned model to stay loaded")
+	}
+
+	// Once unpinned, it is evicted the next time the budget is enforced
+	if err := mm.UnpinModel("model1", "v1.0"); err != nil {
+		t.Fatalf("Failed to unpin model: %v", err)
+	}
+	mm.SetMemoryBudget(150)
+	if mm.loadedModels["model1"] != "" {
+		t.Error("Expected the unpinned model to be evicted")
+	}
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progressInterval is how often a download in progress is reported.
+const progressInterval = 500 * time.Millisecond
+
+// DownloadProgress is a snapshot of a model download.
+type DownloadProgress struct {
+	Model     string
+	Version   string
+	Completed int64         // Bytes downloaded so far
+	Total     int64         // Size of the model file, or zero if the registry did not report it
+	Rate      float64       // Bytes per second, averaged since the download started
+	ETA       time.Duration // Estimated time until the download completes, or zero if unknown
+	Done      bool          // Whether the download completed
+}
+
+// Percent returns the percentage of the model downloaded, or zero if the size is unknown.
+func (p DownloadProgress) Percent() float64 {
+	if p.Total <= 0 {
+		return 0
+	}
+	return float64(p.Completed) / float64(p.Total) * 100
+}
+
+// DownloadProgressFunc receives the progress of a ModelManager download. It is called
+// from a single goroutine at a time, so it need not be safe for concurrent use, but it
+// should return quickly.
+type DownloadProgressFunc func(DownloadProgress)
+
+// progressTracker counts the bytes of a download and reports them every
+// progressInterval. A nil *progressTracker counts nothing, so downloads without a
+// DownloadProgressFunc skip the bookkeeping.
+type progressTracker struct {
+	model     string
+	version   string
+	fn        DownloadProgressFunc
+	start     time.Time
+	total     atomic.Int64
+	completed atomic.Int64
+	stop      chan struct{}
+	stopped   sync.WaitGroup
+}
+
+// newProgressTracker starts reporting the progress of a download to fn, returning nil
+// if fn is nil.
+func newProgressTracker(model, version string, fn DownloadProgressFunc) *progressTracker {
+	if fn == nil {
+		return nil
+	}
+	t := &progressTracker{model: model, version: version, fn: fn, start: time.Now(), stop: make(chan struct{})}
+	t.stopped.Add(1)
+	go t.run()
+	return t
+}
+
+// run reports progress until the tracker is stopped.
+func (t *progressTracker) run() {
+	defer t.stopped.Done()
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.fn(t.snapshot(false))
+		case <-t.stop:
+			return
+		}
+	}
+}
+
+// setTotal records the size of the model file once it is known.
+func (t *progressTracker) setTotal(total int64) {
+	if t != nil && total > 0 {
+		t.total.Store(total)
+	}
+}
+
+// add counts n downloaded bytes. Negative n uncounts the bytes of a failed attempt
+// that will be downloaded again.
+func (t *progressTracker) add(n int64) {
+	if t != nil {
+		t.completed.Add(n)
+	}
+}
+
+// Write counts the bytes written, so the tracker can be combined with the destination
+// of a download in an io.MultiWriter.
+func (t *progressTracker) Write(p []byte) (int, error) {
+	t.add(int64(len(p)))
+	return len(p), nil
+}
+
+// snapshot returns the progress so far.
+func (t *progressTracker) snapshot(done bool) DownloadProgress {
+	p := DownloadProgress{
+		Model:     t.model,
+		Version:   t.version,
+		Completed: t.completed.Load(),
+		Total:     t.total.Load(),
+		Done:      done,
+	}
+	if elapsed := time.Since(t.start).Seconds(); elapsed > 0 {
+		p.Rate = float64(p.Completed) / elapsed
+	}
+	if p.Rate > 0 && p.Total > p.Completed && !done {
+		p.ETA = time.Duration(float64(p.Total-p.Completed) / p.Rate * float64(time.Second))
+	}
+	return p
+}
+
+// finish stops reporting, and reports the download as done if it succeeded.
+func (t *progressTracker) finish(succeeded bool) {
+	if t == nil {
+		return
+	}
+	close(t.stop)
+	t.stopped.Wait()
+	if succeeded {
+		if t.total.Load() == 0 {
+			t.total.Store(t.completed.Load())
+		}
+		t.fn(t.snapshot(true))
+	}
+}
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// signatureSuffix is appended to a model's registry URL to fetch its detached signature.
+const signatureSuffix = ".sig"
+
+// maxSignatureSize bounds the size of a detached signature read from the registry.
+const maxSignatureSize = 4 << 10
+
+var (
+	// ErrUnsigned is returned when a strict SignaturePolicy refuses a model artifact
+	// that has no signature.
+	ErrUnsigned = errors.New("model artifact is not signed")
+
+	// ErrBadSignature is returned when a model artifact's signature does not verify
+	// against any trusted key.
+	ErrBadSignature = errors.New("model signature does not match any trusted key")
+)
+
+// SignaturePolicy configures the verification of model artifacts against the
+// signatures of their publishers.
+//
+// A signature is a detached, base64-encoded Ed25519 signature over the raw SHA-256
+// digest of the model file, published next to the file in the registry with a ".sig"
+// suffix, in the style of cosign and minisign. Export archives carry the signature of
+// the version they contain.
+type SignaturePolicy struct {
+	// TrustedKeys maps publisher names to their public keys. Without trusted keys,
+	// signatures are not checked.
+	// Optional.
+	TrustedKeys map[string]ed25519.PublicKey
+
+	// Strict refuses artifacts without a signature. Signatures that do not verify
+	// are refused regardless.
+	// Default: false
+	Strict bool
+}
+
+// SetSignaturePolicy configures signature verification for subsequent calls to
+// DownloadModel and ImportModel.
+func (mm *ModelManager) SetSignaturePolicy(policy SignaturePolicy) {
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+	mm.signaturePolicy = policy
+}
+
+// ParsePublicKey parses a PEM-encoded Ed25519 public key, such as one written by
+// "openssl pkey -pubout".
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("failed to parse public key: no PEM public key block")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse public key: expected an Ed25519 key, got %T", key)
+	}
+	return publicKey, nil
+}
+
+// SignDigest signs the hex-encoded SHA-256 digest of a model file, returning a
+// signature in the form verified by SignaturePolicy. Publishers use it to sign the
+// artifacts they upload to the registry.
+func SignDigest(privateKey ed25519.PrivateKey, digest string) (string, error) {
+	sum, err := hex.DecodeString(digest)
+	if err != nil {
+		return "", fmt.Errorf("invalid digest: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, sum)), nil
+}
+
+// verify checks the signature of a model file with the given digest, returning the
+// name of the publisher whose key verified it. It returns an empty publisher and no
+// error if the policy has no trusted keys, or if the file is unsigned and the policy
+// is not strict.
+func (p SignaturePolicy) verify(digest, signature string) (string, error) {
+	if len(p.TrustedKeys) == 0 {
+		return "", nil
+	}
+	if signature == "" {
+		if p.Strict {
+			return "", ErrUnsigned
+		}
+		return "", nil
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid signature encoding: %v", ErrBadSignature, err)
+	}
+	sum, err := hex.DecodeString(digest)
+	if err != nil {
+		return "", fmt.Errorf("invalid digest: %w", err)
+	}
+
+	// Check publishers in a stable order in case two share a key
+	publishers := make([]string, 0, len(p.TrustedKeys))
+	for name := range p.TrustedKeys {
+		publishers = append(publishers, name)
+	}
+	sort.Strings(publishers)
+	for _, name := range publishers {
+		if ed25519.Verify(p.TrustedKeys[name], sum, sig) {
+			return name, nil
+		}
+	}
+	return "", ErrBadSignature
+}
+
+// verifyStored checks the signature of a model file that is already on disk, so an
+// unsigned or modified file placed in the model directory is refused like a download.
+// The signature recorded for the version is used if there is one; otherwise it is
+// fetched from the registry. It returns the name of the publisher whose key verified
+// the signature.
+func (mm *ModelManager) verifyStored(ctx context.Context, modelName, version, modelPath, modelURL string, policy SignaturePolicy) (string, error) {
+	mm.lock.Lock()
+	var signature string
+	if record := mm.versions[modelName][version]; record != nil {
+		signature = record.Signature
+	}
+	mm.lock.Unlock()
+
+	if signature == "" {
+		var err error
+		if signature, err = mm.fetchSignature(ctx, modelURL); err != nil {
+			return "", err
+		}
+	}
+
+	// The file is hashed rather than trusting the recorded digest, which a modified
+	// file no longer matches
+	digest, _, err := fileDigest(modelPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to checksum model file: %w", err)
+	}
+	return policy.verify(digest, signature)
+}
+
+// fetchSignature downloads the detached signature of the model at modelURL, returning
+// an empty signature if the registry has none.
+func (mm *ModelManager) fetchSignature(ctx context.Context, modelURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, modelURL+signatureSuffix, nil)
+	if err != nil {
+		return "", err
+	}
+	res, err := mm.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch model signature: %w", err)
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", nil
+	default:
+		return "", fmt.Errorf("failed to fetch model signature: unexpected status %s", res.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxSignatureSize))
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch model signature: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,179 @@
+package models
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestKey generates an Ed25519 key pair for a test publisher.
+func newTestKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	return publicKey, privateKey
+}
+
+func TestDownloadModelVerifiesSignature(t *testing.T) {
+	modelData := []byte("signed weights")
+	sum := sha256.Sum256(modelData)
+	publicKey, privateKey := newTestKey(t)
+	_, otherKey := newTestKey(t)
+
+	signatures := map[string]ed25519.PrivateKey{"/llama/signed.bin.sig": privateKey, "/llama/forged.bin.sig": otherKey}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".sig") {
+			key, ok := signatures[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			signature, _ := SignDigest(key, hex.EncodeToString(sum[:]))
+			w.Write([]byte(signature + "\n"))
+			return
+		}
+		http.ServeContent(w, r, "model.bin", time.Time{}, bytes.NewReader(modelData))
+	}))
+	defer server.Close()
+
+	mm := NewModelManager(t.TempDir())
+	mm.registryURL = server.URL
+	mm.SetSignaturePolicy(SignaturePolicy{TrustedKeys: map[string]ed25519.PublicKey{"acme": publicKey}})
+
+	if err := mm.DownloadModel("llama", "signed"); err != nil {
+		t.Fatalf("Failed to download signed model: %v", err)
+	}
+	if record := mm.versions["llama"]["signed"]; record.Publisher != "acme" || record.Signature == "" {
+		t.Errorf("Expected the signature and publisher to be recorded, got %+v", record)
+	}
+
+	// A signature by an untrusted key is refused and nothing is installed
+	if err := mm.DownloadModel("llama", "forged"); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(mm.modelDir, "llama-forged.bin")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Expected the forged model not to be installed")
+	}
+
+	// Unsigned models are accepted unless the policy is strict
+	if err := mm.DownloadModel("llama", "unsigned"); err != nil {
+		t.Errorf("Expected an unsigned model to download, got %v", err)
+	}
+	mm.SetSignaturePolicy(SignaturePolicy{TrustedKeys: map[string]ed25519.PublicKey{"acme": publicKey}, Strict: true})
+	if err := mm.DownloadModel("llama", "unsigned2"); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("Expected ErrUnsigned, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(mm.modelDir, "llama-unsigned2.bin")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Expected the unsigned model not to be installed")
+	}
+}
+
+func TestDownloadModelVerifiesExistingFile(t *testing.T) {
+	publicKey, privateKey := newTestKey(t)
+	sum := sha256.Sum256([]byte("mock model data"))
+	signature, err := SignDigest(privateKey, hex.EncodeToString(sum[:]))
+	if err != nil {
+		t.Fatalf("Failed to sign digest: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/signed/v1.0.bin.sig":
+			w.Write([]byte(signature))
+		case "/unsigned/v1.0.bin.sig", "/tampered/v1.0.bin.sig":
+			http.NotFound(w, r)
+		default:
+			t.Errorf("Expected existing models not to be downloaded again, got a request for %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	mm := newTestManager(t, "unsigned", "signed", "tampered")
+	mm.registryURL = server.URL
+	mm.SetSignaturePolicy(SignaturePolicy{TrustedKeys: map[string]ed25519.PublicKey{"acme": publicKey}, Strict: true})
+
+	if err := mm.DownloadModel("unsigned", "v1.0"); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("Expected ErrUnsigned for an existing unsigned file, got %v", err)
+	}
+	if err := mm.DownloadModel("signed", "v1.0"); err != nil {
+		t.Errorf("Expected an existing signed file to verify, got %v", err)
+	}
+
+	// A file modified after its signature was recorded no longer verifies
+	mm.recordVersion("tampered", "v1.0", &VersionRecord{Digest: hex.EncodeToString(sum[:]), Signature: signature, Publisher: "acme"})
+	if err := os.WriteFile(filepath.Join(mm.modelDir, "tampered-v1.0.bin"), []byte("tampered data"), 0644); err != nil {
+		t.Fatalf("Failed to modify model file: %v", err)
+	}
+	if err := mm.DownloadModel("tampered", "v1.0"); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature for a modified file, got %v", err)
+	}
+}
+
+func TestImportModelVerifiesSignature(t *testing.T) {
+	publicKey, privateKey := newTestKey(t)
+	sum := sha256.Sum256([]byte("mock model data"))
+	signature, err := SignDigest(privateKey, hex.EncodeToString(sum[:]))
+	if err != nil {
+		t.Fatalf("Failed to sign digest: %v", err)
+	}
+
+	source := newTestManager(t, "signed", "unsigned")
+	source.recordVersion("signed", "v1.0", &VersionRecord{Signature: signature, Publisher: "acme"})
+	export := func(name string) []byte {
+		var archive bytes.Buffer
+		if err := source.ExportModel(name, "v1.0", &archive); err != nil {
+			t.Fatalf("Failed to export model: %v", err)
+		}
+		return archive.Bytes()
+	}
+
+	target := newTestManager(t)
+	target.SetSignaturePolicy(SignaturePolicy{TrustedKeys: map[string]ed25519.PublicKey{"internal": publicKey}, Strict: true})
+	if err := target.ImportModel(bytes.NewReader(export("unsigned"))); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("Expected ErrUnsigned, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(target.modelDir, "unsigned-v1.0.bin")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Expected the unsigned model not to be imported")
+	}
+
+	// The publisher is the one whose key verified the signature, not the one in the archive
+	if err := target.ImportModel(bytes.NewReader(export("signed"))); err != nil {
+		t.Fatalf("Failed to import signed model: %v", err)
+	}
+	if record := target.versions["signed"]["v1.0"]; record.Publisher != "internal" || record.Signature != signature {
+		t.Errorf("Expected the signature to be verified by internal, got %+v", record)
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	publicKey, _ := newTestKey(t)
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	parsed, err := ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		t.Fatalf("Failed to parse key: %v", err)
+	}
+	if !parsed.Equal(publicKey) {
+		t.Error("Expected the parsed key to equal the original")
+	}
+	if _, err := ParsePublicKey([]byte("not a key")); err == nil {
+		t.Error("Expected error for data without a PEM block")
+	}
+}
@@ -0,0 +1,187 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrToolNotFound is returned by ToolRegistry.Call for tools that are not registered.
+var ErrToolNotFound = errors.New("tool not found")
+
+// ErrToolIterations is returned by ChatWithTools when the model still calls tools after
+// the last iteration.
+var ErrToolIterations = errors.New("model did not finish within the tool iteration limit")
+
+// ToolHandler executes a tool call and returns the result to show the model.
+type ToolHandler func(ctx context.Context, call ToolCall) (string, error)
+
+// ToolRegistry holds the tools a model may call and the Go functions executing them. It
+// is safe for concurrent use.
+type ToolRegistry struct {
+	mu    sync.RWMutex
+	tools map[string]registeredTool
+}
+
+// registeredTool is a tool and its handler.
+type registeredTool struct {
+	function ToolFunction
+	handler  ToolHandler
+}
+
+// NewToolRegistry creates an empty ToolRegistry.
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{tools: make(map[string]registeredTool)}
+}
+
+// Register adds a tool executed by handler. It returns an error if the name is empty
+// or already registered.
+func (r *ToolRegistry) Register(function ToolFunction, handler ToolHandler) error {
+	if function.Name == "" {
+		return errors.New("tool name is required")
+	}
+	if handler == nil {
+		return fmt.Errorf("handler of tool %s is required", function.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tools[function.Name]; ok {
+		return fmt.Errorf("tool already registered: %s", function.Name)
+	}
+	r.tools[function.Name] = registeredTool{function: function, handler: handler}
+	return nil
+}
+
+// RegisterToolFunc adds a tool executed by fn. The call's arguments are decoded into
+// Args with json.Unmarshal, and the result is shown to the model as is if it is a
+// string, or else as JSON.
+func RegisterToolFunc[Args, Result any](r *ToolRegistry, function ToolFunction, fn func(context.Context, Args) (Result, error)) error {
+	return r.Register(function, func(ctx context.Context, call ToolCall) (string, error) {
+		var args Args
+		if err := call.DecodeArguments(&args); err != nil {
+			return "", err
+		}
+		result, err := fn(ctx, args)
+		if err != nil {
+			return "", err
+		}
+		if s, ok := any(result).(string); ok {
+			return s, nil
+		}
+		data, err := json.Marshal(result)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal result of tool %s: %w", function.Name, err)
+		}
+		return string(data), nil
+	})
+}
+
+// Tools returns the definitions of the registered tools, sorted by name, for
+// ChatRequest.Tools.
+func (r *ToolRegistry) Tools() []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tools := make([]Tool, 0, len(r.tools))
+	for _, tool := range r.tools {
+		tools = append(tools, Tool{Type: "function", Function: tool.function})
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Function.Name < tools[j].Function.Name })
+	return tools
+}
+
+// Call executes a tool call with the handler of its tool.
+func (r *ToolRegistry) Call(ctx context.Context, call ToolCall) (string, error) {
+	r.mu.RLock()
+	tool, ok := r.tools[call.Function.Name]
+	r.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrToolNotFound, call.Function.Name)
+	}
+	return tool.handler(ctx, call)
+}
+
+// ToolLoopOptions configures ChatWithTools.
+type ToolLoopOptions struct {
+	// MaxIterations is the most chat requests sent before giving up on a model that
+	// keeps calling tools.
+	// Default: 10
+	MaxIterations int
+
+	// OnToolCall is called after each tool call with its result or error. Optional.
+	OnToolCall func(call ToolCall, result string, err error)
+}
+
+// DefaultToolLoopOptions returns the default tool loop options.
+func DefaultToolLoopOptions() ToolLoopOptions {
+	return ToolLoopOptions{
+		MaxIterations: 10,
+	}
+}
+
+// ToolChatResult is the outcome of ChatWithTools.
+type ToolChatResult struct {
+	// Response is the model's final response, which calls no tools.
+	Response ChatResponse
+
+	// Messages is the whole conversation: the request's messages, the tool calls and
+	// their results, and the final reply.
+	Messages []Message
+
+	// ToolCalls is the number of tool calls executed.
+	ToolCalls int
+}
+
+// ChatWithTools sends a chat conversation offering the registered tools, executes the
+// tool calls in the model's responses in order, and sends their results back as "tool"
+// messages until the model replies without calling a tool. The registered tools are
+// added to req.Tools, and the requests are not streamed.
+//
+// A tool that fails or is not registered does not stop the loop: its error is sent to
+// the model as the result, so it can retry or answer without it. ChatWithTools returns
+// early if ctx is done, and fails with ErrToolIterations once it has sent
+// MaxIterations requests.
+func (c *OllamaClient) ChatWithTools(ctx context.Context, req ChatRequest, tools *ToolRegistry, options ToolLoopOptions) (*ToolChatResult, error) {
+	if options.MaxIterations <= 0 {
+		options.MaxIterations = DefaultToolLoopOptions().MaxIterations
+	}
+	req.Tools = append(req.Tools[:len(req.Tools):len(req.Tools)], tools.Tools()...)
+	req.Stream = new(bool)
+	req.Messages = append([]Message(nil), req.Messages...)
+
+	result := &ToolChatResult{}
+	for i := 0; i < options.MaxIterations; i++ {
+		var res ChatResponse
+		if err := c.Chat(ctx, req, func(chunk ChatResponse) error {
+			res = chunk
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		req.Messages = append(req.Messages, res.Message)
+		if len(res.Message.ToolCalls) == 0 {
+			result.Response = res
+			result.Messages = req.Messages
+			return result, nil
+		}
+
+		for _, call := range res.Message.ToolCalls {
+			content, err := tools.Call(ctx, call)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			result.ToolCalls++
+			if options.OnToolCall != nil {
+				options.OnToolCall(call, content, err)
+			}
+			if err != nil {
+				content = "Error: " + err.Error()
+			}
+			req.Messages = append(req.Messages, Message{Role: "tool", Content: content, ToolName: call.Function.Name})
+		}
+	}
+	return nil, fmt.Errorf("%w of %d", ErrToolIterations, options.MaxIterations)
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type weatherArgs struct {
+	City string `json:"city"`
+}
+
+type weather struct {
+	City        string  `json:"city"`
+	Temperature float64 `json:"temperature"`
+}
+
+func newWeatherTools(t *testing.T) *ToolRegistry {
+	tools := NewToolRegistry()
+	err := RegisterToolFunc(tools, ToolFunction{
+		Name:        "get_weather",
+		Description: "Get the current weather in a city",
+		Parameters:  json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}`),
+	}, func(ctx context.Context, args weatherArgs) (weather, error) {
+		if args.City == "" {
+			return weather{}, errors.New("city is required")
+		}
+		return weather{City: args.City, Temperature: 21.5}, nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+	return tools
+}
+
+func TestToolRegistry(t *testing.T) {
+	tools := newWeatherTools(t)
+	tools.Register(ToolFunction{Name: "echo"}, func(ctx context.Context, call ToolCall) (string, error) {
+		return string(call.Function.Arguments), nil
+	})
+
+	if err := tools.Register(ToolFunction{Name: "echo"}, func(ctx context.Context, call ToolCall) (string, error) { return "", nil }); err == nil {
+		t.Error("Expected error for a duplicate tool")
+	}
+	if err := tools.Register(ToolFunction{}, nil); err == nil {
+		t.Error("Expected error for a tool without a name")
+	}
+
+	definitions := tools.Tools()
+	if len(definitions) != 2 || definitions[0].Function.Name != "echo" || definitions[1].Type != "function" {
+		t.Errorf("Unexpected tool definitions %+v", definitions)
+	}
+
+	ctx := context.Background()
+	result, err := tools.Call(ctx, ToolCall{Function: ToolCallFunction{Name: "get_weather", Arguments: json.RawMessage(`{"city":"Paris"}`)}})
+	if err != nil || result != `{"city":"Paris","temperature":21.5}` {
+		t.Errorf("Unexpected result %q, %v", result, err)
+	}
+	// OpenAI-compatible servers send the arguments as a string
+	result, err = tools.Call(ctx, ToolCall{Function: ToolCallFunction{Name: "get_weather", Arguments: json.RawMessage(`"{\"city\":\"Oslo\"}"`)}})
+	if err != nil || !strings.Contains(result, "Oslo") {
+		t.Errorf("Unexpected result %q, %v", result, err)
+	}
+	if _, err := tools.Call(ctx, ToolCall{Function: ToolCallFunction{Name: "missing"}}); !errors.Is(err, ErrToolNotFound) {
+		t.Errorf("Expected ErrToolNotFound, got %v", err)
+	}
+	if _, err := tools.Call(ctx, ToolCall{Function: ToolCallFunction{Name: "get_weather", Arguments: json.RawMessage(`[1]`)}}); err == nil {
+		t.Error("Expected error for arguments that do not decode")
+	}
+}
+
+func TestOllamaClientChatWithTools(t *testing.T) {
+	var requests []ChatRequest
+	client := newTestOllamaClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		requests = append(requests, req)
+		switch len(requests) {
+		case 1:
+			fmt.Fprintln(w, `{"model":"llama3","message":{"role":"assistant","content":"","tool_calls":[`+
+				`{"function":{"name":"get_weather","arguments":{"city":"Paris"}}},`+
+				`{"function":{"name":"get_time","arguments":{}}}]},"done":true}`)
+		default:
+			fmt.Fprintln(w, `{"model":"llama3","message":{"role":"assistant","content":"It is 21.5 degrees in Paris."},"done":true}`)
+		}
+	})
+
+	var calls []string
+	result, err := client.ChatWithTools(context.Background(), ChatRequest{
+		Model:    "llama3",
+		Messages: []Message{{Role: "user", Content: "What's the weather in Paris?"}},
+	}, newWeatherTools(t), ToolLoopOptions{
+		OnToolCall: func(call ToolCall, result string, err error) {
+			calls = append(calls, call.Function.Name)
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to chat with tools: %v", err)
+	}
+
+	if result.Response.Message.Content != "It is 21.5 degrees in Paris." {
+		t.Errorf("Unexpected final reply %q", result.Response.Message.Content)
+	}
+	if result.ToolCalls != 2 || fmt.Sprint(calls) != "[get_weather get_time]" {
+		t.Errorf("Expected 2 tool calls, got %d %v", result.ToolCalls, calls)
+	}
+	if len(requests) != 2 || len(requests[0].Tools) != 1 || requests[0].Stream == nil || *requests[0].Stream {
+		t.Fatalf("Expected 2 non-streamed requests offering the tool, got %+v", requests)
+	}
+
+	messages := requests[1].Messages
+	if len(messages) != 4 {
+		t.Fatalf("Expected the tool results to be sent back, got %+v", messages)
+	}
+	if messages[1].Role != "assistant" || len(messages[1].ToolCalls) != 2 {
+		t.Errorf("Expected the assistant's tool calls, got %+v", messages[1])
+	}
+	if messages[2].Role != "tool" || messages[2].ToolName != "get_weather" || !strings.Contains(messages[2].Content, "21.5") {
+		t.Errorf("Unexpected tool result %+v", messages[2])
+	}
+	if !strings.HasPrefix(messages[3].Content, "Error: tool not found") {
+		t.Errorf("Expected the unknown tool's error, got %q", messages[3].Content)
+	}
+	if len(result.Messages) != 5 {
+		t.Errorf("Expected 5 messages in the conversation, got %d", len(result.Messages))
+	}
+}
+
+func TestOllamaClientChatWithToolsIterationLimit(t *testing.T) {
+	client := newTestOllamaClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"message":{"role":"assistant","tool_calls":[{"function":{"name":"get_weather","arguments":{"city":"Paris"}}}]},"done":true}`)
+	})
+
+	_, err := client.ChatWithTools(context.Background(), ChatRequest{Model: "llama3"}, newWeatherTools(t), ToolLoopOptions{MaxIterations: 3})
+	if !errors.Is(err, ErrToolIterations) {
+		t.Errorf("Expected ErrToolIterations, got %v", err)
+	}
+}
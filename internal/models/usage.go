@@ -0,0 +1,106 @@
+package models
+
+import (
+	"sort"
+	"time"
+
+	"github.com/h2co32/gollama/internal/metrics"
+)
+
+// ModelUsage summarizes the requests served by a model, across its versions.
+type ModelUsage struct {
+	Requests   int64         // Number of requests served
+	AvgLatency time.Duration // Average latency of the requests, or zero if none
+	LastUsed   time.Time     // When the model was last loaded or served a request; zero if never
+}
+
+// modelUsage accumulates the requests served by a model.
+type modelUsage struct {
+	requests    int64
+	requestTime time.Duration // Total latency of the requests
+}
+
+// SetMetrics sets the provider that RecordRequest reports model usage to.
+// A nil provider stops reporting.
+func (mm *ModelManager) SetMetrics(provider *metrics.MetricsProvider) {
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+	mm.metrics = provider
+}
+
+// RecordRequest records a request served by a model and its latency. The model
+// becomes the most recently used, so it is the last to be evicted to fit the memory
+// budget. Usage is persisted in the manifest whenever it is next saved.
+func (mm *ModelManager) RecordRequest(modelName string, latency time.Duration) {
+	mm.lock.Lock()
+	usage := mm.usage[modelName]
+	if usage == nil {
+		usage = &modelUsage{}
+		mm.usage[modelName] = usage
+	}
+	usage.requests++
+	usage.requestTime += latency
+	mm.lastUsed[modelName] = time.Now().UTC()
+	provider := mm.metrics
+	mm.lock.Unlock()
+
+	if provider != nil {
+		provider.TrackModelRequest(modelName, latency)
+	}
+}
+
+// Usage returns the usage of every model that has been loaded or served a request.
+func (mm *ModelManager) Usage() map[string]ModelUsage {
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+
+	result := make(map[string]ModelUsage, len(mm.lastUsed))
+	for name := range mm.lastUsed {
+		result[name] = mm.modelUsage(name)
+	}
+	for name := range mm.usage {
+		result[name] = mm.modelUsage(name)
+	}
+	return result
+}
+
+// modelUsage returns the usage of a model.
+// This method is not thread-safe and should be called with the lock held.
+func (mm *ModelManager) modelUsage(modelName string) ModelUsage {
+	result := ModelUsage{LastUsed: mm.lastUsed[modelName]}
+	if usage := mm.usage[modelName]; usage != nil && usage.requests > 0 {
+		result.Requests = usage.requests
+		result.AvgLatency = usage.requestTime / time.Duration(usage.requests)
+	}
+	return result
+}
+
+// RecommendPreload returns up to n stored models worth preloading, most requested
+// first, with ties going to the most recently used. Models that have never served a
+// request are not recommended. A non-positive n returns every candidate. The result
+// can be passed to Preload at startup.
+func (mm *ModelManager) RecommendPreload(n int) []string {
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+
+	var candidates []string
+	for name, usage := range mm.usage {
+		if _, stored := mm.currentVersion[name]; stored && usage.requests > 0 {
+			candidates = append(candidates, name)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := mm.usage[candidates[i]], mm.usage[candidates[j]]
+		if a.requests != b.requests {
+			return a.requests > b.requests
+		}
+		if !mm.lastUsed[candidates[i]].Equal(mm.lastUsed[candidates[j]]) {
+			return mm.lastUsed[candidates[i]].After(mm.lastUsed[candidates[j]])
+		}
+		return candidates[i] < candidates[j]
+	})
+	if n > 0 && len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	return candidates
+}
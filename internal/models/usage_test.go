@@ -0,0 +1,81 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecordRequest(t *testing.T) {
+	mm := newTestManager(t, "model1", "model2", "model3")
+	mm.SetBackend(&fakeBackend{size: 100})
+	mm.SetMemoryBudget(250)
+
+	for _, name := range []string{"model1", "model2"} {
+		if err := mm.LoadModel(name); err != nil {
+			t.Fatalf("Failed to load model %s: %v", name, err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// A request makes model1 the most recently used, so model2 is evicted instead
+	mm.RecordRequest("model1", 100*time.Millisecond)
+	mm.RecordRequest("model1", 300*time.Millisecond)
+	time.Sleep(time.Millisecond)
+	if err := mm.LoadModel("model3"); err != nil {
+		t.Fatalf("Failed to load model3: %v", err)
+	}
+	if mm.loadedModels["model1"] == "" || mm.loadedModels["model2"] != "" {
+		t.Error("Expected model2 to be evicted rather than the recently requested model1")
+	}
+
+	usage := mm.Usage()
+	if u := usage["model1"]; u.Requests != 2 || u.AvgLatency != 200*time.Millisecond || u.LastUsed.IsZero() {
+		t.Errorf("Expected 2 requests averaging 200ms, got %+v", u)
+	}
+	if u := usage["model2"]; u.Requests != 0 || u.AvgLatency != 0 || u.LastUsed.IsZero() {
+		t.Errorf("Expected model2 to be used without requests, got %+v", u)
+	}
+
+	infos, err := mm.ListModels(ListOptions{Name: "model1"})
+	if err != nil {
+		t.Fatalf("Failed to list models: %v", err)
+	}
+	if len(infos) != 1 || infos[0].Requests != 2 || infos[0].AvgLatency != 200*time.Millisecond {
+		t.Errorf("Expected ListModels to report the usage, got %+v", infos)
+	}
+
+	// Usage is saved with the manifest and restored
+	if err := mm.UnloadModel("model3"); err != nil {
+		t.Fatalf("Failed to unload model3: %v", err)
+	}
+	restored := NewModelManager(mm.modelDir)
+	if u := restored.Usage()["model1"]; u.Requests != 2 || u.AvgLatency != 200*time.Millisecond {
+		t.Errorf("Expected usage to be restored, got %+v", u)
+	}
+}
+
+func TestRecommendPreload(t *testing.T) {
+	mm := newTestManager(t, "model1", "model2", "model3", "model4")
+	mm.RecordRequest("model1", time.Second)
+	mm.RecordRequest("model2", time.Second)
+	mm.RecordRequest("model2", time.Second)
+	time.Sleep(time.Millisecond)
+	mm.RecordRequest("model3", time.Second)
+	mm.RecordRequest("deleted-model", time.Second)
+
+	// Most requested first, then most recently used; unused and deleted models are left out
+	expected := []string{"model2", "model3", "model1"}
+	recommended := mm.RecommendPreload(0)
+	if len(recommended) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, recommended)
+	}
+	for i := range expected {
+		if recommended[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, recommended)
+			break
+		}
+	}
+	if recommended := mm.RecommendPreload(1); len(recommended) != 1 || recommended[0] != "model2" {
+		t.Errorf("Expected [model2], got %v", recommended)
+	}
+}
@@ -0,0 +1,184 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/h2co32/gollama/pkg/logging"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrNotRecorded is returned when a model version has no manifest record to verify
+// against or repair from.
+var ErrNotRecorded = errors.New("model version not recorded in manifest")
+
+// Statuses of a verified model version.
+const (
+	// VerifyOK means the file matches the size and digest in the manifest.
+	VerifyOK = "ok"
+
+	// VerifyMissing means the file does not exist.
+	VerifyMissing = "missing"
+
+	// VerifyTruncated means the file is smaller than recorded, such as after an
+	// interrupted copy or a full disk.
+	VerifyTruncated = "truncated"
+
+	// VerifyCorrupt means the file's size or digest differs from the manifest.
+	VerifyCorrupt = "corrupt"
+)
+
+// VerifyResult is the outcome of verifying a model version against the manifest.
+type VerifyResult struct {
+	Name           string
+	Version        string
+	Status         string // One of VerifyOK, VerifyMissing, VerifyTruncated, or VerifyCorrupt
+	ExpectedSize   int64
+	ActualSize     int64
+	ExpectedDigest string
+	ActualDigest   string // Empty if the file is missing
+}
+
+// OK reports whether the file matches the manifest.
+func (r VerifyResult) OK() bool {
+	return r.Status == VerifyOK
+}
+
+// Verify recomputes the checksum of a stored model version and compares it and the
+// file size with the manifest. A damaged file is reported in the result rather than as
+// an error; errors are only returned if the version is not recorded or the file cannot
+// be read.
+func (mm *ModelManager) Verify(modelName, version string) (VerifyResult, error) {
+	defer mm.lockModel(modelName)()
+	return mm.verify(modelName, version)
+}
+
+// verify is Verify with the model's lock held.
+func (mm *ModelManager) verify(modelName, version string) (VerifyResult, error) {
+	mm.lock.Lock()
+	record := mm.versions[modelName][version]
+	mm.lock.Unlock()
+	if record == nil {
+		return VerifyResult{}, fmt.Errorf("%w: %s version %s", ErrNotRecorded, modelName, version)
+	}
+
+	result := VerifyResult{
+		Name:           modelName,
+		Version:        version,
+		ExpectedSize:   record.Size,
+		ExpectedDigest: record.Digest,
+	}
+	modelPath := filepath.Join(mm.modelDir, modelName+"-"+version+".bin")
+	digest, size, err := fileDigest(modelPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		result.Status = VerifyMissing
+		return result, nil
+	} else if err != nil {
+		return VerifyResult{}, fmt.Errorf("failed to checksum model file: %w", err)
+	}
+
+	result.ActualSize, result.ActualDigest = size, digest
+	switch {
+	case size < record.Size:
+		result.Status = VerifyTruncated
+	case size != record.Size || digest != record.Digest:
+		result.Status = VerifyCorrupt
+	default:
+		result.Status = VerifyOK
+	}
+	return result, nil
+}
+
+// VerifyAll verifies every model version recorded in the manifest, sorted by name and
+// version.
+func (mm *ModelManager) VerifyAll() ([]VerifyResult, error) {
+	type key struct{ name, version string }
+	mm.lock.Lock()
+	var keys []key
+	for name, versions := range mm.versions {
+		for version := range versions {
+			keys = append(keys, key{name, version})
+		}
+	}
+	mm.lock.Unlock()
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name != keys[j].name {
+			return keys[i].name < keys[j].name
+		}
+		return keys[i].version < keys[j].version
+	})
+
+	results := make([]VerifyResult, 0, len(keys))
+	for _, k := range keys {
+		result, err := mm.Verify(k.name, k.version)
+		if errors.Is(err, ErrNotRecorded) {
+			continue // Deleted since the keys were collected
+		} else if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// RepairModel downloads a damaged model version again, replacing the stored file, and
+// checks the new file against the digest in the manifest. The model's current version
+// is left unchanged. Fine-tuned versions were not downloaded, so they cannot be
+// repaired.
+func (mm *ModelManager) RepairModel(ctx context.Context, modelName, version string) (err error) {
+	ctx, span := mm.startSpan(ctx, "model.repair", modelName, attribute.String("model.version", version))
+	defer func() { endSpan(span, err) }()
+
+	defer mm.lockModel(modelName)()
+
+	mm.lock.Lock()
+	record := mm.versions[modelName][version]
+	modelURL := fmt.Sprintf("%s/%s/%s.bin", mm.registryURL, modelName, version)
+	opts := mm.downloadOptions
+	mm.lock.Unlock()
+	if record == nil {
+		return fmt.Errorf("%w: %s version %s", ErrNotRecorded, modelName, version)
+	}
+	if record.FineTune != nil {
+		return fmt.Errorf("cannot repair fine-tuned model %s version %s", modelName, version)
+	}
+
+	modelPath := filepath.Join(mm.modelDir, modelName+"-"+version+".bin")
+	if err := os.Remove(modelPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove damaged model file: %w", err)
+	}
+
+	logging.Default().InfoContext(ctx, "repairing model", "model", modelName, "version", version, "url", modelURL)
+	if err := mm.fetchModel(ctx, modelURL, modelPath, opts, nil); err != nil {
+		return err
+	}
+	digest, size, err := fileDigest(modelPath)
+	if err != nil {
+		return fmt.Errorf("failed to checksum model file: %w", err)
+	}
+	// A registry serving different content for the version is not a repair
+	if record.Digest != "" && digest != record.Digest {
+		os.Remove(modelPath)
+		return fmt.Errorf("downloaded model %s version %s has digest %s, expected %s", modelName, version, digest, record.Digest)
+	}
+
+	// The signature and pin still apply, since the digest is unchanged
+	repaired := *record
+	repaired.Digest, repaired.Size, repaired.DownloadedAt = digest, size, time.Now().UTC()
+
+	mm.lock.Lock()
+	defer mm.lock.Unlock()
+	mm.recordVersion(modelName, version, &repaired)
+	if err := mm.saveManifest(); err != nil {
+		return err
+	}
+
+	logging.Default().InfoContext(ctx, "repaired model", "model", modelName, "version", version)
+	return nil
+}
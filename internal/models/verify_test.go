@@ -0,0 +1,84 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestVerifyAndRepair(t *testing.T) {
+	modelData := bytes.Repeat([]byte("weights"), 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "model.bin", time.Time{}, bytes.NewReader(modelData))
+	}))
+	defer server.Close()
+
+	mm := NewModelManager(t.TempDir())
+	mm.registryURL = server.URL
+	for _, version := range []string{"v1", "v2", "v3", "v4"} {
+		if err := mm.DownloadModel("llama", version); err != nil {
+			t.Fatalf("Failed to download model: %v", err)
+		}
+	}
+
+	// Damage every version but v1 in a different way
+	path := func(version string) string { return filepath.Join(mm.modelDir, "llama-"+version+".bin") }
+	if err := os.WriteFile(path("v2"), bytes.Repeat([]byte("x"), len(modelData)), 0644); err != nil {
+		t.Fatalf("Failed to corrupt model: %v", err)
+	}
+	if err := os.Truncate(path("v3"), 10); err != nil {
+		t.Fatalf("Failed to truncate model: %v", err)
+	}
+	if err := os.Remove(path("v4")); err != nil {
+		t.Fatalf("Failed to remove model: %v", err)
+	}
+
+	results, err := mm.VerifyAll()
+	if err != nil {
+		t.Fatalf("Failed to verify models: %v", err)
+	}
+	expected := []string{VerifyOK, VerifyCorrupt, VerifyTruncated, VerifyMissing}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %+v", len(expected), results)
+	}
+	for i, status := range expected {
+		if results[i].Status != status {
+			t.Errorf("Expected %s to be %s, got %+v", results[i].Version, status, results[i])
+		}
+	}
+	if !results[0].OK() || results[1].OK() || results[2].ActualSize != 10 {
+		t.Errorf("Unexpected results %+v", results)
+	}
+
+	if _, err := mm.Verify("llama", "v9"); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("Expected ErrNotRecorded, got %v", err)
+	}
+
+	// Repairing downloads the version again without changing the current version
+	for _, version := range []string{"v2", "v3", "v4"} {
+		if err := mm.RepairModel(context.Background(), "llama", version); err != nil {
+			t.Fatalf("Failed to repair %s: %v", version, err)
+		}
+		if result, _ := mm.Verify("llama", version); !result.OK() {
+			t.Errorf("Expected %s to be repaired, got %+v", version, result)
+		}
+	}
+	if mm.currentVersion["llama"] != "v4" {
+		t.Errorf("Expected the current version to stay v4, got %s", mm.currentVersion["llama"])
+	}
+
+	// A registry serving different content cannot repair the version
+	modelData = []byte("other weights")
+	if err := mm.RepairModel(context.Background(), "llama", "v1"); err == nil {
+		t.Error("Expected error when the downloaded digest differs")
+	}
+	if _, err := os.Stat(path("v1")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Expected the mismatched download to be removed")
+	}
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/h2co32/gollama/pkg/logging"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Warmer sends warmup requests to the server that runs inference for a loaded model.
+// A warmup request forces the server to page in the model's weights and compile its
+// kernels before the first real request pays for it.
+type Warmer interface {
+	// Warmup generates at most maxTokens tokens for prompt with the model and
+	// discards the result.
+	Warmup(ctx context.Context, modelName, prompt string, maxTokens int) error
+}
+
+// WarmupOptions configures the warmup requests sent to a model after it is preloaded.
+type WarmupOptions struct {
+	// Disabled skips warmup for the model.
+	// Default: false
+	Disabled bool
+
+	// Prompt is the prompt sent in each warmup request.
+	// Default: "Hello"
+	Prompt string
+
+	// Requests is the number of warmup requests, sent one after another. The first
+	// request pages in the weights; later ones run with kernels compiled by the first.
+	// Default: 1
+	Requests int
+
+	// MaxTokens is the maximum number of tokens generated by each warmup request.
+	// Default: 1
+	MaxTokens int
+
+	// Timeout bounds all the warmup requests to a model.
+	// Default: 2m
+	Timeout time.Duration
+}
+
+// DefaultWarmupOptions returns the default warmup options.
+func DefaultWarmupOptions() WarmupOptions {
+	return WarmupOptions{
+		Prompt:    "Hello",
+		Requests:  1,
+		MaxTokens: 1,
+		Timeout:   2 * time.Minute,
+	}
+}
+
+// withDefaults fills the zero fields of o from DefaultWarmupOptions.
+func (o WarmupOptions) withDefaults() WarmupOptions {
+	defaults := DefaultWarmupOptions()
+	if o.Prompt == "" {
+		o.Prompt = defaults.Prompt
+	}
+	if o.Requests <= 0 {
+		o.Requests = defaults.Requests
+	}
+	if o.MaxTokens <= 0 {
+		o.MaxTokens = defaults.MaxTokens
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = defaults.Timeout
+	}
+	return o
+}
+
+// warmup sends the warmup requests in opts to a loaded model, returning the latency
+// of each request that succeeded.
+func (mm *ModelManager) warmup(ctx context.Context, warmer Warmer, modelName string, opts WarmupOptions) (latencies []time.Duration, err error) {
+	ctx, span := mm.startSpan(ctx, "model.warmup", modelName, attribute.Int("model.warmup.requests", opts.Requests))
+	defer func() {
+		if len(latencies) > 0 {
+			span.SetAttributes(attribute.Int64("model.warmup.first_ms", latencies[0].Milliseconds()))
+		}
+		endSpan(span, err)
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	for i := 0; i < opts.Requests; i++ {
+		start := time.Now()
+		if err := warmer.Warmup(ctx, modelName, opts.Prompt, opts.MaxTokens); err != nil {
+			return latencies, fmt.Errorf("warmup request %d of %d failed: %w", i+1, opts.Requests, err)
+		}
+		latencies = append(latencies, time.Since(start))
+	}
+
+	logging.Default().InfoContext(ctx, "model warmed up", "model", modelName, "requests", opts.Requests,
+		"first_latency", latencies[0], "last_latency", latencies[len(latencies)-1])
+	return latencies, nil
+}
+
+// Warmup sends a non-streaming generate request for prompt to the Ollama server,
+// generating at most maxTokens tokens, so the server loads and warms up the model.
+// Unlike Generate, the request is not recorded in the metrics or the model's usage.
+func (c *OllamaClient) Warmup(ctx context.Context, modelName, prompt string, maxTokens int) error {
+	stream := false
+	req := GenerateRequest{
+		Model:   modelName,
+		Prompt:  prompt,
+		Stream:  &stream,
+		Options: map[string]interface{}{"num_predict": maxTokens},
+	}
+	return c.stream(ctx, "/api/generate", req, func([]byte) error { return nil })
+}
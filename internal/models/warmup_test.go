@@ -0,0 +1,117 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// warmerFunc adapts a function to the Warmer interface.
+type warmerFunc func(modelName, prompt string, maxTokens int) error
+
+func (f warmerFunc) Warmup(ctx context.Context, modelName, prompt string, maxTokens int) error {
+	return f(modelName, prompt, maxTokens)
+}
+
+func TestPreloadWarmup(t *testing.T) {
+	mm := newTestManager(t, "model1", "model2", "model3", "model4")
+
+	var mu sync.Mutex
+	calls := make(map[string][]string)
+	warmer := warmerFunc(func(modelName, prompt string, maxTokens int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[modelName] = append(calls[modelName], prompt)
+		if maxTokens != 1 {
+			t.Errorf("Expected 1 max token for %s, got %d", modelName, maxTokens)
+		}
+		if modelName == "model3" {
+			return errors.New("backend unavailable")
+		}
+		return nil
+	})
+
+	results := mm.Preload(context.Background(), []string{"model1", "model2", "model3", "model4", "missing-model"}, PreloadOptions{
+		Warmer: warmer,
+		ModelWarmup: map[string]WarmupOptions{
+			"model2": {Prompt: "Warm up", Requests: 2},
+			"model4": {Disabled: true},
+		},
+	})
+	if len(results) != 5 {
+		t.Fatalf("Expected 5 results, got %d", len(results))
+	}
+
+	// Results are in the order of the requested models
+	for i, name := range []string{"model1", "model2", "model3", "model4", "missing-model"} {
+		if results[i].Model != name {
+			t.Errorf("Expected result %d to be for %s, got %s", i, name, results[i].Model)
+		}
+	}
+
+	if results[0].Err != nil || len(results[0].Warmup) != 1 || results[0].WarmupErr != nil {
+		t.Errorf("Expected model1 to load and warm up once, got %+v", results[0])
+	}
+	if len(results[1].Warmup) != 2 || len(calls["model2"]) != 2 || calls["model2"][0] != "Warm up" {
+		t.Errorf("Expected model2 to warm up twice with its own prompt, got %+v and calls %v", results[1], calls["model2"])
+	}
+	if calls["model1"][0] != DefaultWarmupOptions().Prompt {
+		t.Errorf("Expected model1 to use the default prompt, got %q", calls["model1"][0])
+	}
+
+	// A failed warmup leaves the model loaded
+	if results[2].Err != nil || results[2].WarmupErr == nil || mm.loadedModels["model3"] == "" {
+		t.Errorf("Expected model3 to load but fail warmup, got %+v", results[2])
+	}
+	if len(results[3].Warmup) != 0 || len(calls["model4"]) != 0 {
+		t.Errorf("Expected model4 not to be warmed up, got %+v", results[3])
+	}
+	if results[4].Err == nil || len(calls["missing-model"]) != 0 {
+		t.Errorf("Expected missing-model to fail to load without warmup, got %+v", results[4])
+	}
+
+	// PreloadModelsWithOptions reports load errors only
+	errs := mm.PreloadModelsWithOptions([]string{"model3", "missing-model"}, PreloadOptions{Warmer: warmer})
+	if len(errs) != 1 || errs["missing-model"] == nil {
+		t.Errorf("Expected only missing-model to fail, got %v", errs)
+	}
+}
+
+func TestOllamaClientWarmup(t *testing.T) {
+	client := newTestOllamaClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/generate" {
+			t.Errorf("Unexpected request path %s", r.URL.Path)
+		}
+		var req GenerateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		if req.Model != "llama2" || req.Prompt != "Hello" || req.Stream == nil || *req.Stream {
+			t.Errorf("Unexpected warmup request %+v", req)
+		}
+		if req.Options["num_predict"] != float64(1) {
+			t.Errorf("Expected num_predict 1, got %v", req.Options["num_predict"])
+		}
+		w.Write([]byte(`{"model":"llama2","response":"Hi","done":true}`))
+	})
+
+	if err := client.Warmup(context.Background(), "llama2", "Hello", 1); err != nil {
+		t.Fatalf("Failed to warm up: %v", err)
+	}
+
+	// Warmups are not usage, but generations are
+	if requests := client.modelManager.Usage()["llama2"].Requests; requests != 0 {
+		t.Errorf("Expected the warmup not to be recorded, got %d requests", requests)
+	}
+	stream := false
+	req := GenerateRequest{Model: "llama2", Prompt: "Hello", Stream: &stream, Options: map[string]interface{}{"num_predict": 1}}
+	if err := client.Generate(context.Background(), req, func(GenerateResponse) error { return nil }); err != nil {
+		t.Fatalf("Failed to generate: %v", err)
+	}
+	if requests := client.modelManager.Usage()["llama2"].Requests; requests != 1 {
+		t.Errorf("Expected the generation to be recorded, got %d requests", requests)
+	}
+}
@@ -0,0 +1,261 @@
+package preprocessing
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ChunkStrategy selects where a Chunker splits text.
+type ChunkStrategy string
+
+const (
+	// ChunkFixed splits text into windows of up to MaxTokens tokens, cut between words.
+	ChunkFixed ChunkStrategy = "fixed"
+
+	// ChunkSentences packs whole sentences into chunks of up to MaxTokens tokens.
+	// Sentences longer than that are split between words.
+	ChunkSentences ChunkStrategy = "sentences"
+
+	// ChunkMarkdown splits Markdown into sections at its headings, and sections longer
+	// than MaxTokens tokens as ChunkSentences does. Chunks record the headings they
+	// fall under.
+	ChunkMarkdown ChunkStrategy = "markdown"
+)
+
+// ChunkOptions configures a Chunker.
+type ChunkOptions struct {
+	// Strategy selects where text is split.
+	// Default: ChunkSentences
+	Strategy ChunkStrategy
+
+	// MaxTokens is the most tokens in a chunk. A single word longer than this becomes
+	// a chunk of its own.
+	// Default: 512
+	MaxTokens int
+
+	// Overlap is the most tokens a chunk repeats from the end of the one before it, so
+	// context is not lost at the boundaries. It must be less than MaxTokens. Chunks
+	// overlap by whole words, or by whole sentences with ChunkSentences and ChunkMarkdown.
+	// Default: 0
+	Overlap int
+
+	// Counter counts tokens with a model's vocabulary. Optional. By default each word
+	// and punctuation mark counts as one token.
+	Counter *TokenCounter
+}
+
+// DefaultChunkOptions returns the default chunking options.
+func DefaultChunkOptions() ChunkOptions {
+	return ChunkOptions{
+		Strategy:  ChunkSentences,
+		MaxTokens: 512,
+	}
+}
+
+// Chunk is a piece of a text, such as a passage to embed for retrieval.
+type Chunk struct {
+	// Index is the position of the chunk among the text's chunks, from 0.
+	Index int `json:"index"`
+
+	// Text is the chunk's text, equal to the source text from Start to End.
+	Text string `json:"text"`
+
+	// Start and End are the byte offsets of the chunk in the source text.
+	Start int `json:"start"`
+	End   int `json:"end"`
+
+	// Tokens is the number of tokens in the chunk.
+	Tokens int `json:"tokens"`
+
+	// Headings are the Markdown headings the chunk falls under, outermost first.
+	// Only set by ChunkMarkdown.
+	Headings []string `json:"headings,omitempty"`
+}
+
+// Chunker splits text into chunks that fit a token budget.
+type Chunker struct {
+	options ChunkOptions
+}
+
+// NewChunker creates a Chunker with the given options. Zero values fall back to the
+// defaults. It returns an error for an unknown strategy, or an Overlap that is not less
+// than MaxTokens.
+func NewChunker(options ChunkOptions) (*Chunker, error) {
+	defaults := DefaultChunkOptions()
+	if options.Strategy == "" {
+		options.Strategy = defaults.Strategy
+	}
+	if options.MaxTokens <= 0 {
+		options.MaxTokens = defaults.MaxTokens
+	}
+	switch options.Strategy {
+	case ChunkFixed, ChunkSentences, ChunkMarkdown:
+	default:
+		return nil, fmt.Errorf("unsupported chunk strategy: %s", options.Strategy)
+	}
+	if options.Overlap < 0 || options.Overlap >= options.MaxTokens {
+		return nil, fmt.Errorf("overlap must be between 0 and %d tokens, got %d", options.MaxTokens-1, options.Overlap)
+	}
+	return &Chunker{options: options}, nil
+}
+
+// Chunk splits text into chunks.
+func (c *Chunker) Chunk(text string) []Chunk {
+	var chunks []Chunk
+	switch c.options.Strategy {
+	case ChunkFixed:
+		chunks = c.pack(text, wordSpans(text, 0, len(text)), nil, chunks)
+	case ChunkSentences:
+		chunks = c.pack(text, sentenceSpans(text), nil, chunks)
+	case ChunkMarkdown:
+		for _, section := range markdownSections(text) {
+			spans := sentenceSpans(text[section.start:section.end])
+			for i := range spans {
+				spans[i][0] += section.start
+				spans[i][1] += section.start
+			}
+			chunks = c.pack(text, spans, section.headings, chunks)
+		}
+	}
+	return chunks
+}
+
+// count returns the number of tokens in text.
+func (c *Chunker) count(text string) int {
+	if c.options.Counter != nil {
+		return c.options.Counter.Count(text)
+	}
+	return len(wordPattern.FindAllStringIndex(text, -1))
+}
+
+// pack appends chunks of consecutive spans of text, each holding as many spans as fit
+// in MaxTokens. Spans that do not fit on their own are split between words.
+func (c *Chunker) pack(text string, spans [][2]int, headings []string, chunks []Chunk) []Chunk {
+	fits := func(from, to int) bool {
+		return c.count(text[spans[from][0]:spans[to-1][1]]) <= c.options.MaxTokens
+	}
+
+	for i := 0; i < len(spans); {
+		if !fits(i, i+1) {
+			words := wordSpans(text, spans[i][0], spans[i][1])
+			if len(words) > 1 {
+				chunks = c.pack(text, words, headings, chunks)
+				i++
+				continue
+			}
+		}
+
+		// The most spans from i that fit, with a single span always taken
+		end := i + 1
+		for low, high := i+2, len(spans); low <= high; {
+			mid := (low + high) / 2
+			if fits(i, mid) {
+				end, low = mid, mid+1
+			} else {
+				high = mid - 1
+			}
+		}
+
+		chunk := Chunk{
+			Index:    len(chunks),
+			Start:    spans[i][0],
+			End:      spans[end-1][1],
+			Headings: headings,
+		}
+		chunk.Text = text[chunk.Start:chunk.End]
+		chunk.Tokens = c.count(chunk.Text)
+		chunks = append(chunks, chunk)
+
+		// Start the next chunk with the trailing spans that fit in the overlap, as long
+		// as it can still take the span after them
+		next := end
+		if c.options.Overlap > 0 && end < len(spans) {
+			for k := i + 1; k < end; k++ {
+				if c.count(text[spans[k][0]:spans[end-1][1]]) <= c.options.Overlap {
+					if fits(k, end+1) {
+						next = k
+					}
+					break
+				}
+			}
+		}
+		i = next
+	}
+	return chunks
+}
+
+// wordSpans returns the byte offsets of the words and punctuation marks in text
+// between start and end.
+func wordSpans(text string, start, end int) [][2]int {
+	var spans [][2]int
+	for _, match := range wordPattern.FindAllStringIndex(text[start:end], -1) {
+		spans = append(spans, [2]int{start + match[0], start + match[1]})
+	}
+	return spans
+}
+
+// markdownSection is a part of a Markdown document under one heading.
+type markdownSection struct {
+	start, end int
+	headings   []string
+}
+
+// markdownHeading matches an ATX heading line, such as "## Setup".
+var markdownHeading = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+
+// markdownSections splits a Markdown document at its headings, outside fenced code
+// blocks. Each section starts with its heading line.
+func markdownSections(text string) []markdownSection {
+	var sections []markdownSection
+	var path []string // Headings of the current section, outermost first
+	var levels []int  // Levels of the headings in path
+	var fence string  // Marker of the open code fence, if any
+	current := markdownSection{}
+
+	for offset := 0; offset < len(text); {
+		lineEnd := strings.IndexByte(text[offset:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(text)
+		} else {
+			lineEnd += offset
+		}
+		line := strings.TrimRight(text[offset:lineEnd], "\r")
+		trimmed := strings.TrimLeft(line, " ")
+
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+		default:
+			match := markdownHeading.FindStringSubmatch(line)
+			if match == nil {
+				break
+			}
+			current.end = offset
+			if strings.TrimSpace(text[current.start:current.end]) != "" {
+				sections = append(sections, current)
+			}
+
+			level := len(match[1])
+			for len(levels) > 0 && levels[len(levels)-1] >= level {
+				levels = levels[:len(levels)-1]
+				path = path[:len(path)-1]
+			}
+			levels = append(levels, level)
+			path = append(path, match[2])
+			current = markdownSection{start: offset, headings: append([]string(nil), path...)}
+		}
+
+		offset = lineEnd + 1
+	}
+
+	current.end = len(text)
+	if strings.TrimSpace(text[current.start:current.end]) != "" {
+		sections = append(sections, current)
+	}
+	return sections
+}
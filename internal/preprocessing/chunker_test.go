@@ -0,0 +1,117 @@
+package preprocessing
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// chunkTexts returns the text of each chunk, checking it matches its offsets.
+func chunkTexts(t *testing.T, text string, chunks []Chunk) []string {
+	t.Helper()
+	var texts []string
+	for i, chunk := range chunks {
+		if chunk.Index != i {
+			t.Errorf("Expected index %d, got %d", i, chunk.Index)
+		}
+		if chunk.Text != text[chunk.Start:chunk.End] {
+			t.Errorf("Expected chunk text %q to match its offsets, got %q", text[chunk.Start:chunk.End], chunk.Text)
+		}
+		texts = append(texts, chunk.Text)
+	}
+	return texts
+}
+
+func TestChunkFixed(t *testing.T) {
+	chunker, err := NewChunker(ChunkOptions{Strategy: ChunkFixed, MaxTokens: 4, Overlap: 1})
+	if err != nil {
+		t.Fatalf("Failed to create chunker: %v", err)
+	}
+	text := "one two three four five six seven eight nine"
+	chunks := chunker.Chunk(text)
+	got := chunkTexts(t, text, chunks)
+	want := []string{"one two three four", "four five six seven", "seven eight nine"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if chunks[0].Tokens != 4 || chunks[1].Start != strings.Index(text, "four") {
+		t.Errorf("Expected 4 tokens starting at the overlap, got %+v", chunks[1])
+	}
+}
+
+func TestChunkSentences(t *testing.T) {
+	chunker, err := NewChunker(ChunkOptions{MaxTokens: 8})
+	if err != nil {
+		t.Fatalf("Failed to create chunker: %v", err)
+	}
+	text := "Llamas hum. They are social animals. A single sentence that is much too long to fit. Bye."
+	got := chunkTexts(t, text, chunker.Chunk(text))
+	want := []string{
+		"Llamas hum. They are social animals.",
+		"A single sentence that is much too long",
+		"to fit.",
+		"Bye.",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	// Whole sentences are repeated as overlap
+	chunker, _ = NewChunker(ChunkOptions{MaxTokens: 10, Overlap: 5})
+	text = "One two. Three four. Five six. Seven eight."
+	got = chunkTexts(t, text, chunker.Chunk(text))
+	want = []string{"One two. Three four. Five six.", "Five six. Seven eight."}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestChunkMarkdown(t *testing.T) {
+	chunker, err := NewChunker(ChunkOptions{Strategy: ChunkMarkdown, MaxTokens: 50})
+	if err != nil {
+		t.Fatalf("Failed to create chunker: %v", err)
+	}
+	text := "Preamble.\n\n# Guide\n\nIntro text.\n\n## Setup\n\nInstall it.\n\n```sh\n# not a heading\n```\n\n## Usage ##\n\nRun it.\n\n# Appendix\n\nMore.\n"
+	chunks := chunker.Chunk(text)
+	got := chunkTexts(t, text, chunks)
+	want := []string{
+		"Preamble.",
+		"# Guide\n\nIntro text.",
+		"## Setup\n\nInstall it.\n\n```sh\n# not a heading\n```",
+		"## Usage ##\n\nRun it.",
+		"# Appendix\n\nMore.",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %q, got %q", want, got)
+	}
+
+	headings := [][]string{nil, {"Guide"}, {"Guide", "Setup"}, {"Guide", "Usage"}, {"Appendix"}}
+	for i, chunk := range chunks {
+		if !reflect.DeepEqual(chunk.Headings, headings[i]) {
+			t.Errorf("Expected headings %q for chunk %d, got %q", headings[i], i, chunk.Headings)
+		}
+	}
+}
+
+func TestChunkTokenCounter(t *testing.T) {
+	model, err := LoadTokenizerJSON(strings.NewReader(sentencePieceJSON))
+	if err != nil {
+		t.Fatalf("Failed to load tokenizer: %v", err)
+	}
+	chunker, _ := NewChunker(ChunkOptions{Strategy: ChunkFixed, MaxTokens: 2, Counter: NewTokenCounter(model)})
+	text := "hello world hello world"
+	got := chunkTexts(t, text, chunker.Chunk(text))
+	want := []string{"hello world", "hello world"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestNewChunkerErrors(t *testing.T) {
+	if _, err := NewChunker(ChunkOptions{Strategy: "pages"}); err == nil {
+		t.Error("Expected an error for an unknown strategy")
+	}
+	if _, err := NewChunker(ChunkOptions{MaxTokens: 10, Overlap: 10}); err == nil {
+		t.Error("Expected an error for an overlap as large as a chunk")
+	}
+}
@@ -0,0 +1,102 @@
+package preprocessing
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalization is a Unicode normalization form.
+type Normalization string
+
+const (
+	// NormalizeNone leaves text as it is.
+	NormalizeNone Normalization = ""
+
+	// NormalizeNFC composes characters, so "e" followed by a combining accent becomes "é".
+	NormalizeNFC Normalization = "nfc"
+
+	// NormalizeNFKC composes characters and replaces compatibility characters with their
+	// plain equivalents, so "ﬁ" becomes "fi" and full-width "Ａ" becomes "A".
+	NormalizeNFKC Normalization = "nfkc"
+)
+
+// Normalize returns text in the given normalization form.
+func Normalize(text string, form Normalization) string {
+	switch form {
+	case NormalizeNFC:
+		return norm.NFC.String(text)
+	case NormalizeNFKC:
+		return norm.NFKC.String(text)
+	default:
+		return text
+	}
+}
+
+// FoldAccents removes accents and other combining marks from text, so "café" becomes
+// "cafe". Letters that are not written with a combining mark, such as "ø", are kept.
+func FoldAccents(text string) string {
+	decomposed := norm.NFD.String(text)
+	folded := strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, decomposed)
+	return norm.NFC.String(folded)
+}
+
+// emoji lists the code points used in emoji, including their modifiers, variation
+// selectors, and tags.
+var emoji = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x203C, Hi: 0x203C, Stride: 1},  // ‼
+		{Lo: 0x2049, Hi: 0x2049, Stride: 1},  // ⁉
+		{Lo: 0x20E3, Hi: 0x20E3, Stride: 1},  // Combining enclosing keycap
+		{Lo: 0x2194, Hi: 0x2199, Stride: 1},  // ↔ to ↙
+		{Lo: 0x21A9, Hi: 0x21AA, Stride: 1},  // ↩ ↪
+		{Lo: 0x231A, Hi: 0x231B, Stride: 1},  // ⌚ ⌛
+		{Lo: 0x2328, Hi: 0x2328, Stride: 1},  // ⌨
+		{Lo: 0x23CF, Hi: 0x23CF, Stride: 1},  // ⏏
+		{Lo: 0x23E9, Hi: 0x23F3, Stride: 1},  // ⏩ to ⏳
+		{Lo: 0x23F8, Hi: 0x23FA, Stride: 1},  // ⏸ to ⏺
+		{Lo: 0x24C2, Hi: 0x24C2, Stride: 1},  // Ⓜ
+		{Lo: 0x25AA, Hi: 0x25AB, Stride: 1},  // ▪ ▫
+		{Lo: 0x25B6, Hi: 0x25C0, Stride: 10}, // ▶ ◀
+		{Lo: 0x25FB, Hi: 0x25FE, Stride: 1},  // ◻ to ◾
+		{Lo: 0x2600, Hi: 0x27BF, Stride: 1},  // Miscellaneous symbols and dingbats, such as ☀ and ✅
+		{Lo: 0x2934, Hi: 0x2935, Stride: 1},  // ⤴ ⤵
+		{Lo: 0x2B05, Hi: 0x2B07, Stride: 1},  // ⬅ to ⬇
+		{Lo: 0x2B1B, Hi: 0x2B1C, Stride: 1},  // ⬛ ⬜
+		{Lo: 0x2B50, Hi: 0x2B55, Stride: 5},  // ⭐ ⭕
+		{Lo: 0x3030, Hi: 0x3030, Stride: 1},  // 〰
+		{Lo: 0x303D, Hi: 0x303D, Stride: 1},  // 〽
+		{Lo: 0x3297, Hi: 0x3299, Stride: 2},  // ㊗ ㊙
+		{Lo: 0xFE0E, Hi: 0xFE0F, Stride: 1},  // Variation selectors
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x1F000, Hi: 0x1FAFF, Stride: 1}, // Pictographs, emoticons, flags, and modifiers
+		{Lo: 0xE0020, Hi: 0xE007F, Stride: 1}, // Tags, as in subdivision flags
+	},
+}
+
+// zeroWidthJoiner joins emoji into sequences, such as families.
+const zeroWidthJoiner = '‍'
+
+// StripEmoji removes emoji from text, along with the joiners between them. Joiners in
+// other text, which some scripts need, are kept.
+func StripEmoji(text string) string {
+	runes := []rune(text)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.Is(emoji, r) {
+			continue
+		}
+		if r == zeroWidthJoiner && ((i > 0 && unicode.Is(emoji, runes[i-1])) || (i+1 < len(runes) && unicode.Is(emoji, runes[i+1]))) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
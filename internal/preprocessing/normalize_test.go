@@ -0,0 +1,37 @@
+package preprocessing
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	decomposed := "cafe\u0301"
+	if got := Normalize(decomposed, NormalizeNFC); got != "caf\u00e9" {
+		t.Errorf("Expected café, got %q", got)
+	}
+	if got := Normalize("ﬁne Ａ1", NormalizeNFKC); got != "fine A1" {
+		t.Errorf("Expected fine A1, got %q", got)
+	}
+	if got := Normalize(decomposed, NormalizeNone); got != decomposed {
+		t.Errorf("Expected text unchanged, got %q", got)
+	}
+}
+
+func TestFoldAccents(t *testing.T) {
+	if got := FoldAccents("Crème brûlée, Ångström, smørrebrød"); got != "Creme brulee, Angstrom, smørrebrød" {
+		t.Errorf("Expected accents folded, got %q", got)
+	}
+}
+
+func TestStripEmoji(t *testing.T) {
+	tests := map[string]string{
+		"Great job 👍🏽!":             "Great job !",
+		"Family 👨‍👩‍👧 trip ✈️":      "Family  trip ",
+		"Flags 🇫🇷 and 🏴󠁧󠁢󠁳󠁣󠁴󠁿 done": "Flags  and  done",
+		"Plain → text ©":            "Plain → text ©",
+		"می‌خواهم":                  "می‌خواهم", // Joiners in other scripts are kept
+	}
+	for text, want := range tests {
+		if got := StripEmoji(text); got != want {
+			t.Errorf("Expected %q, got %q", want, got)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package preprocessing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// PIIType is a kind of personally identifiable information.
+type PIIType string
+
+const (
+	PIIEmail      PIIType = "email"
+	PIIPhone      PIIType = "phone"
+	PIICreditCard PIIType = "credit_card"
+	PIIIPAddress  PIIType = "ip_address"
+)
+
+// piiTypes lists the types in the order their matches take precedence when they overlap.
+var piiTypes = []PIIType{PIIEmail, PIICreditCard, PIIIPAddress, PIIPhone}
+
+// RedactionStrategy selects what replaces detected information.
+type RedactionStrategy string
+
+const (
+	// RedactPlaceholder replaces a value with its type, such as "[EMAIL]".
+	RedactPlaceholder RedactionStrategy = "placeholder"
+
+	// RedactMask replaces letters and digits with "*", keeping separators, the last
+	// four digits of card and phone numbers, and the domain of emails.
+	RedactMask RedactionStrategy = "mask"
+
+	// RedactHash replaces a value with its type and a hash, such as "[EMAIL:3f2a9c1b7d04]",
+	// so repeated values can still be correlated.
+	RedactHash RedactionStrategy = "hash"
+
+	// RedactRemove removes a value.
+	RedactRemove RedactionStrategy = "remove"
+)
+
+// RedactorOptions configures a Redactor.
+type RedactorOptions struct {
+	// Types lists the kinds of information to detect.
+	// Default: all of PIIEmail, PIIPhone, PIICreditCard, and PIIIPAddress
+	Types []PIIType
+
+	// Strategy selects what replaces detected information.
+	// Default: RedactPlaceholder
+	Strategy RedactionStrategy
+
+	// Strategies overrides Strategy for some types. Optional.
+	Strategies map[PIIType]RedactionStrategy
+
+	// HashKey keys the hashes of RedactHash, so values cannot be recovered by hashing
+	// guesses. Optional, but recommended with RedactHash.
+	HashKey []byte
+}
+
+// DefaultRedactorOptions returns the default redactor options.
+func DefaultRedactorOptions() RedactorOptions {
+	return RedactorOptions{
+		Types:    []PIIType{PIIEmail, PIIPhone, PIICreditCard, PIIIPAddress},
+		Strategy: RedactPlaceholder,
+	}
+}
+
+// Redaction records one value that was redacted, without the value itself.
+type Redaction struct {
+	// Type is the kind of information redacted.
+	Type PIIType `json:"type"`
+
+	// Start and End are the byte offsets of the value in the original text.
+	Start int `json:"start"`
+	End   int `json:"end"`
+
+	// Replacement is the text that replaced the value.
+	Replacement string `json:"replacement"`
+}
+
+// RedactionReport describes what was redacted from a text.
+type RedactionReport struct {
+	// Redactions lists the values redacted, in the order they appeared.
+	Redactions []Redaction `json:"redactions"`
+
+	// Counts is the number of values redacted by type.
+	Counts map[PIIType]int `json:"counts"`
+}
+
+// Redactor detects and masks personally identifiable information, such as before
+// prompts are sent to models or written to caches and logs. Matches are validated, so
+// card numbers must pass the Luhn checksum and IP addresses must parse.
+type Redactor struct {
+	options RedactorOptions
+	types   map[PIIType]struct{}
+}
+
+// NewRedactor creates a Redactor with the given options. Zero values fall back to the
+// defaults. It returns an error for an unknown type or strategy.
+func NewRedactor(options RedactorOptions) (*Redactor, error) {
+	defaults := DefaultRedactorOptions()
+	if len(options.Types) == 0 {
+		options.Types = defaults.Types
+	}
+	if options.Strategy == "" {
+		options.Strategy = defaults.Strategy
+	}
+
+	r := &Redactor{options: options, types: make(map[PIIType]struct{})}
+	for _, piiType := range options.Types {
+		if _, ok := detectors[piiType]; !ok {
+			return nil, fmt.Errorf("unsupported PII type: %s", piiType)
+		}
+		r.types[piiType] = struct{}{}
+	}
+	strategies := []RedactionStrategy{options.Strategy}
+	for _, strategy := range options.Strategies {
+		strategies = append(strategies, strategy)
+	}
+	for _, strategy := range strategies {
+		switch strategy {
+		case RedactPlaceholder, RedactMask, RedactHash, RedactRemove:
+		default:
+			return nil, fmt.Errorf("unsupported redaction strategy: %s", strategy)
+		}
+	}
+	return r, nil
+}
+
+// Redact returns text with the detected information replaced, and a report of what was
+// replaced.
+func (r *Redactor) Redact(text string) (string, RedactionReport) {
+	report := RedactionReport{Counts: make(map[PIIType]int)}
+
+	// Take matches in order of precedence, skipping those overlapping one already taken
+	var matches []Redaction
+	for _, piiType := range piiTypes {
+		if _, ok := r.types[piiType]; !ok {
+			continue
+		}
+		detector := detectors[piiType]
+		for _, loc := range detector.pattern.FindAllStringIndex(text, -1) {
+			if !detector.valid(text[loc[0]:loc[1]]) || overlaps(matches, loc[0], loc[1]) {
+				continue
+			}
+			matches = append(matches, Redaction{Type: piiType, Start: loc[0], End: loc[1]})
+		}
+	}
+	if len(matches) == 0 {
+		return text, report
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Start < matches[j].Start })
+
+	var b strings.Builder
+	last := 0
+	for _, match := range matches {
+		match.Replacement = r.replace(match.Type, text[match.Start:match.End])
+		b.WriteString(text[last:match.Start])
+		b.WriteString(match.Replacement)
+		last = match.End
+		report.Redactions = append(report.Redactions, match)
+		report.Counts[match.Type]++
+	}
+	b.WriteString(text[last:])
+	return b.String(), report
+}
+
+// replace returns the replacement of a value.
+func (r *Redactor) replace(piiType PIIType, value string) string {
+	strategy := r.options.Strategy
+	if override, ok := r.options.Strategies[piiType]; ok {
+		strategy = override
+	}
+	label := strings.ToUpper(string(piiType))
+
+	switch strategy {
+	case RedactMask:
+		return maskValue(piiType, value)
+	case RedactHash:
+		mac := hmac.New(sha256.New, r.options.HashKey)
+		mac.Write([]byte(value))
+		return "[" + label + ":" + hex.EncodeToString(mac.Sum(nil))[:12] + "]"
+	case RedactRemove:
+		return ""
+	default:
+		return "[" + label + "]"
+	}
+}
+
+// maskValue replaces the letters and digits of a value with "*", keeping the last four
+// digits of card and phone numbers and the domain of emails.
+func maskValue(piiType PIIType, value string) string {
+	keepFrom := len(value)
+	switch piiType {
+	case PIIEmail:
+		keepFrom = strings.LastIndexByte(value, '@')
+	case PIICreditCard, PIIPhone:
+		digits := 0
+		for i := len(value) - 1; i >= 0 && digits < 4; i-- {
+			if value[i] >= '0' && value[i] <= '9' {
+				digits++
+				keepFrom = i
+			}
+		}
+	}
+
+	masked := []rune(value[:keepFrom])
+	for i, c := range masked {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			masked[i] = '*'
+		}
+	}
+	return string(masked) + value[keepFrom:]
+}
+
+// overlaps reports whether any match overlaps the range from start to end.
+func overlaps(matches []Redaction, start, end int) bool {
+	for _, match := range matches {
+		if start < match.End && match.Start < end {
+			return true
+		}
+	}
+	return false
+}
+
+// detector finds candidates with a pattern and validates them.
+type detector struct {
+	pattern *regexp.Regexp
+	valid   func(value string) bool
+}
+
+// detectors are the detectors of each type.
+var detectors = map[PIIType]detector{
+	PIIEmail: {
+		pattern: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`),
+		valid: func(value string) bool {
+			local := value[:strings.IndexByte(value, '@')]
+			return !strings.HasPrefix(local, ".") && !strings.HasSuffix(local, ".") && !strings.Contains(local, "..")
+		},
+	},
+	PIICreditCard: {
+		pattern: regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`),
+		valid:   func(value string) bool { return luhnValid(digitsOf(value)) },
+	},
+	PIIIPAddress: {
+		pattern: regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b|(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f]{0,4}`),
+		valid: func(value string) bool {
+			addr, err := netip.ParseAddr(value)
+			return err == nil && (addr.Is4() || strings.Count(value, ":") >= 2)
+		},
+	},
+	PIIPhone: {
+		pattern: regexp.MustCompile(`(?:\+\d{1,3}[ .\-]?)?(?:\(\d{1,4}\)[ .\-]?)?\d{2,4}(?:[ .\-]?\d{2,4}){1,4}`),
+		valid:   phoneValid,
+	},
+}
+
+// datePattern matches ISO dates, which phone numbers are not.
+var datePattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+// phoneValid reports whether a candidate looks like a phone number: 7 to 15 digits,
+// written with a country or area code, in three or more groups, as two groups joined by
+// a hyphen, or as 10 or more digits in one group. Dates and decimals are not.
+func phoneValid(value string) bool {
+	digits := len(digitsOf(value))
+	if digits < 7 || digits > 15 || datePattern.MatchString(value) {
+		return false
+	}
+	if strings.ContainsAny(value, "+(") {
+		return true
+	}
+	groups := len(strings.FieldsFunc(value, func(r rune) bool { return r == ' ' || r == '.' || r == '-' }))
+	switch groups {
+	case 1:
+		return digits >= 10
+	case 2:
+		return strings.Contains(value, "-")
+	default:
+		return true
+	}
+}
+
+// digitsOf returns the digits in value.
+func digitsOf(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, value)
+}
+
+// luhnValid reports whether digits pass the Luhn checksum used by card numbers.
+func luhnValid(digits string) bool {
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,72 @@
+package preprocessing
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	redactor, err := NewRedactor(DefaultRedactorOptions())
+	if err != nil {
+		t.Fatalf("Failed to create redactor: %v", err)
+	}
+	text := "Mail jane.doe@example.co.uk or call +1 (555) 123-4567. Card 4111 1111 1111 1111, from 192.168.1.20 and 2001:db8::1."
+	got, report := redactor.Redact(text)
+	want := "Mail [EMAIL] or call [PHONE]. Card [CREDIT_CARD], from [IP_ADDRESS] and [IP_ADDRESS]."
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	counts := map[PIIType]int{PIIEmail: 1, PIIPhone: 1, PIICreditCard: 1, PIIIPAddress: 2}
+	if !reflect.DeepEqual(report.Counts, counts) {
+		t.Errorf("Expected counts %v, got %v", counts, report.Counts)
+	}
+	first := report.Redactions[0]
+	if first.Type != PIIEmail || text[first.Start:first.End] != "jane.doe@example.co.uk" || first.Replacement != "[EMAIL]" {
+		t.Errorf("Expected the email redaction first, got %+v", first)
+	}
+}
+
+func TestRedactValidation(t *testing.T) {
+	redactor, _ := NewRedactor(RedactorOptions{})
+	// Failed checksums, impossible addresses, dates, decimals, and short numbers are kept
+	text := "Order 4111 1111 1111 1112 on 2024-01-15 cost 3.14159265, version 1.2.3.4000, room 1234, time 12:30:45."
+	if got, report := redactor.Redact(text); got != text || len(report.Redactions) != 0 {
+		t.Errorf("Expected nothing redacted, got %q", got)
+	}
+}
+
+func TestRedactStrategies(t *testing.T) {
+	redactor, err := NewRedactor(RedactorOptions{
+		Types:    []PIIType{PIIEmail, PIICreditCard, PIIPhone},
+		Strategy: RedactMask,
+		Strategies: map[PIIType]RedactionStrategy{
+			PIIPhone: RedactRemove,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create redactor: %v", err)
+	}
+	got, _ := redactor.Redact("bob@example.com paid with 5500-0000-0000-0004, call 555-123-4567")
+	want := "***@example.com paid with ****-****-****-0004, call "
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	redactor, _ = NewRedactor(RedactorOptions{Strategy: RedactHash, HashKey: []byte("secret")})
+	first, _ := redactor.Redact("from alice@example.com")
+	second, _ := redactor.Redact("to alice@example.com")
+	if !strings.HasPrefix(first, "from [EMAIL:") || strings.TrimPrefix(first, "from ") != strings.TrimPrefix(second, "to ") {
+		t.Errorf("Expected the same hash for the same email, got %q and %q", first, second)
+	}
+}
+
+func TestNewRedactorErrors(t *testing.T) {
+	if _, err := NewRedactor(RedactorOptions{Types: []PIIType{"ssn"}}); err == nil {
+		t.Error("Expected an error for an unknown type")
+	}
+	if _, err := NewRedactor(RedactorOptions{Strategies: map[PIIType]RedactionStrategy{PIIEmail: "encrypt"}}); err == nil {
+		t.Error("Expected an error for an unknown strategy")
+	}
+}
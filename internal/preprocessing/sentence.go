@@ -0,0 +1,157 @@
+package preprocessing
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// abbreviations lists lower-case abbreviations whose trailing period does not end a sentence.
+var abbreviations = map[string]struct{}{
+	"mr": {}, "mrs": {}, "ms": {}, "dr": {}, "prof": {}, "sr": {}, "jr": {}, "st": {},
+	"mt": {}, "vs": {}, "etc": {}, "e.g": {}, "i.e": {}, "cf": {}, "al": {}, "approx": {},
+	"inc": {}, "ltd": {}, "co": {}, "corp": {}, "no": {}, "fig": {}, "vol": {}, "ch": {},
+	"jan": {}, "feb": {}, "mar": {}, "apr": {}, "jun": {}, "jul": {}, "aug": {}, "sep": {},
+	"sept": {}, "oct": {}, "nov": {}, "dec": {}, "u.s": {}, "u.k": {},
+}
+
+// SplitSentences splits text into sentences, trimmed of surrounding whitespace.
+// Sentences end at ".", "!", "?", or "…" followed by whitespace, including any closing
+// quotes or brackets, and at blank lines. Terminators followed by a lower-case word,
+// and periods after common abbreviations and initials, do not end a sentence.
+func SplitSentences(text string) []string {
+	var sentences []string
+	for _, span := range sentenceSpans(text) {
+		sentences = append(sentences, text[span[0]:span[1]])
+	}
+	return sentences
+}
+
+// sentenceSpans returns the byte offsets of the sentences in text, excluding the
+// whitespace between them.
+func sentenceSpans(text string) [][2]int {
+	var spans [][2]int
+	start := -1
+	emit := func(end int) {
+		if start >= 0 {
+			spans = append(spans, [2]int{start, end})
+			start = -1
+		}
+	}
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if unicode.IsSpace(r) {
+			// A blank line ends a paragraph, and with it the sentence
+			if r == '\n' && start >= 0 {
+				if j := skipSpaces(text, i+size, false); j < len(text) && text[j] == '\n' {
+					emit(trimRightSpace(text, i))
+				}
+			}
+			i += size
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+		i += size
+
+		if r != '.' && r != '!' && r != '?' && r != '…' {
+			continue
+		}
+		// Take repeated terminators and closing quotes or brackets with the sentence
+		end := i
+		for end < len(text) {
+			next, n := utf8.DecodeRuneInString(text[end:])
+			if next != '.' && next != '!' && next != '?' && next != '…' && !isClosing(next) {
+				break
+			}
+			end += n
+		}
+		if end < len(text) {
+			next, _ := utf8.DecodeRuneInString(text[end:])
+			if !unicode.IsSpace(next) {
+				i = end
+				continue
+			}
+		}
+		if !endsSentence(text, start, i-size, end) {
+			i = end
+			continue
+		}
+		emit(end)
+		i = end
+	}
+	emit(trimRightSpace(text, len(text)))
+	return spans
+}
+
+// endsSentence reports whether the terminator at dot, in the sentence starting at start
+// and taking up to end, ends the sentence.
+func endsSentence(text string, start, dot, end int) bool {
+	// A lower-case word after the terminator continues the sentence
+	next := skipSpaces(text, end, true)
+	if next < len(text) {
+		r, _ := utf8.DecodeRuneInString(text[next:])
+		if unicode.IsLower(r) {
+			return false
+		}
+	}
+	if text[dot] != '.' {
+		return true
+	}
+
+	// The word before the period
+	wordStart := dot
+	for wordStart > start {
+		r, n := utf8.DecodeLastRuneInString(text[:wordStart])
+		if unicode.IsSpace(r) || r == '(' || r == '"' || r == '“' {
+			break
+		}
+		wordStart -= n
+	}
+	word := strings.ToLower(text[wordStart:dot])
+	if _, ok := abbreviations[word]; ok {
+		return false
+	}
+	if utf8.RuneCountInString(word) == 1 {
+		if r, _ := utf8.DecodeRuneInString(word); unicode.IsLetter(r) {
+			return false // An initial, as in "J. R. R. Tolkien"
+		}
+	}
+	return true
+}
+
+// isClosing reports whether r closes a quotation or parenthetical.
+func isClosing(r rune) bool {
+	switch r {
+	case '"', '\'', '”', '’', ')', ']', '}', '»':
+		return true
+	}
+	return false
+}
+
+// skipSpaces returns the offset of the first rune at or after i that is not a space,
+// stopping at line breaks unless newlines is true.
+func skipSpaces(text string, i int, newlines bool) int {
+	for i < len(text) {
+		r, n := utf8.DecodeRuneInString(text[i:])
+		if !unicode.IsSpace(r) || (r == '\n' && !newlines) {
+			break
+		}
+		i += n
+	}
+	return i
+}
+
+// trimRightSpace returns the offset just past the last rune before end that is not a space.
+func trimRightSpace(text string, end int) int {
+	for end > 0 {
+		r, n := utf8.DecodeLastRuneInString(text[:end])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		end -= n
+	}
+	return end
+}
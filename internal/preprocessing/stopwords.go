@@ -0,0 +1,74 @@
+package preprocessing
+
+import (
+	"sort"
+	"strings"
+)
+
+// stopWordLists are the built-in stop words by ISO 639-1 language code, in lower case.
+var stopWordLists = map[string]string{
+	"en": `a about above after again against all am an and any are as at be because been
+		before being below between both but by can could did do does doing down during each
+		few for from further had has have having he her here hers herself him himself his how
+		i if in into is it its itself just me more most my myself no nor not now of off on
+		once only or other our ours ourselves out over own same she should so some such than
+		that the their theirs them themselves then there these they this those through to too
+		under until up very was we were what when where which while who whom why will with
+		would you your yours yourself yourselves`,
+	"es": `a al algo algunas algunos ante antes como con contra cual cuando de del desde
+		donde durante e el ella ellas ellos en entre era eran es esa esas ese eso esos esta
+		estaba estado estas este esto estos fue fueron ha han hasta hay la las le les lo los
+		más me mi mis mucho muy nada ni no nos nosotros o os otra otro para pero poco por
+		porque que quien se sea ser si sido sin sobre son su sus también tan te tiene tienen
+		todo todos tu tus un una uno unos y ya yo`,
+	"de": `aber alle allem allen aller alles als also am an ander andere auch auf aus bei
+		bin bis bist da damit dann das dass dein deine dem den der des dich die dies diese
+		dieser dieses dir doch dort du durch ein eine einem einen einer eines er es etwas
+		euch euer für hab habe haben hat hatte hier hin hinter ich ihm ihn ihnen ihr ihre im
+		in ist ja jede jedem jeden jeder jedes jetzt kann kein keine können man mein meine
+		mich mir mit muss nach nicht nichts noch nun nur ob oder ohne sehr sein seine sich
+		sie sind so solche soll sondern sonst über um und uns unser unter viel vom von vor
+		war waren warum was weil welche wenn wer werde werden wie wieder will wir wird wo
+		wollen zu zum zur zwar zwischen`,
+	"fr": `a ai au aux avec avoir c ce ceci cela ces cet cette comme d dans de des donc du
+		elle elles en est et été être eu il ils j je l la le les leur leurs lui m ma mais me
+		même mes moi mon n ne ni nos notre nous on ont ou où par pas peu plus pour qu que qui
+		s sa sans se ses si son sont sur ta te tes toi ton tous tout très tu un une vos
+		votre vous y`,
+	"it": `a ad al alla alle allo agli ai anche avere c che chi ci come con contro cui da
+		dal dalla dalle dei del della delle dello di dove e è ed era erano essere gli ha
+		hanno ho i il in io la le lei li lo loro lui ma me mi mia mie miei mio ne negli nei
+		nel nella nelle no noi non nostro o per perché più quale quando quella quelle quelli
+		quello questa queste questi questo se sei si sia siamo sono su sua sue sui sul sulla
+		suo suoi ti tra tu tua tuo tutti tutto un una uno voi vostro`,
+	"pt": `a ao aos as até com como da das de dela dele deles do dos e é ela elas ele eles
+		em entre era eram essa essas esse esses esta estas este estes eu foi foram há isso
+		isto já lhe lhes mais mas me mesmo meu meus minha minhas muito na nas nem no nos nós
+		nossa nosso num numa o os ou para pela pelas pelo pelos por qual quando que quem se
+		sem ser seu seus só sua suas também te tem tu um uma você vocês`,
+	"nl": `aan al als bij dan dat de der deze die dit doch door dus een en er ge geen had
+		heb hebben heeft hem het hier hij hoe hun ik in is ja je kan kon maar me meer men met
+		mij mijn na naar niet niets nog nu of om omdat ons ook op over reeds te tegen toch
+		toen tot u uit uw van veel voor want waren was wat we wel werd wie wij wil worden zal
+		ze zei zelf zich zij zijn zo zonder zou`,
+}
+
+// StopWords returns the built-in stop words of a language by its ISO 639-1 code, such
+// as "en", in lower case. It returns false for languages without a built-in list.
+func StopWords(language string) ([]string, bool) {
+	list, ok := stopWordLists[strings.ToLower(language)]
+	if !ok {
+		return nil, false
+	}
+	return strings.Fields(list), true
+}
+
+// StopWordLanguages returns the codes of the languages with built-in stop words, sorted.
+func StopWordLanguages() []string {
+	languages := make([]string, 0, len(stopWordLists))
+	for language := range stopWordLists {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
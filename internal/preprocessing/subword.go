@@ -0,0 +1,431 @@
+package preprocessing
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SubwordModel splits text into the subword units of a vocabulary.
+type SubwordModel interface {
+	// Tokenize splits text into tokens from the model's vocabulary.
+	Tokenize(text string) []string
+}
+
+// WordPiece is a WordPiece model, as used by BERT, loaded from a vocab.txt file.
+// Text is split on whitespace and punctuation, and each word into the longest pieces
+// found in the vocabulary, with continuations prefixed by "##". Words that cannot be
+// split become "[UNK]". The model does not change case, so use the Tokenizer's
+// Lowercase option with uncased vocabularies.
+type WordPiece struct {
+	vocab map[string]int
+}
+
+// WordPiece constants
+const (
+	WordPieceUnknown      = "[UNK]"
+	WordPieceContinuation = "##"
+
+	// wordPieceMaxChars is the longest word split into pieces; longer words are unknown.
+	wordPieceMaxChars = 100
+)
+
+// LoadWordPiece reads a WordPiece vocabulary with one token per line, whose IDs are
+// their line numbers counting from 0.
+func LoadWordPiece(r io.Reader) (*WordPiece, error) {
+	vocab := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	for id := 0; scanner.Scan(); id++ {
+		token := strings.TrimRight(scanner.Text(), "\r")
+		if _, exists := vocab[token]; !exists {
+			vocab[token] = id
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read vocabulary: %w", err)
+	}
+	if len(vocab) == 0 {
+		return nil, fmt.Errorf("vocabulary is empty")
+	}
+	return &WordPiece{vocab: vocab}, nil
+}
+
+// LoadWordPieceFile reads a WordPiece vocabulary from a vocab.txt file.
+func LoadWordPieceFile(path string) (*WordPiece, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vocabulary: %w", err)
+	}
+	defer file.Close()
+	return LoadWordPiece(file)
+}
+
+// Tokenize splits text into WordPiece tokens.
+func (wp *WordPiece) Tokenize(text string) []string {
+	var tokens []string
+	for _, word := range splitBasic(text) {
+		tokens = append(tokens, wp.pieces(word)...)
+	}
+	return tokens
+}
+
+// ID returns the vocabulary ID of a token.
+func (wp *WordPiece) ID(token string) (int, bool) {
+	id, ok := wp.vocab[token]
+	return id, ok
+}
+
+// pieces splits a word greedily into the longest tokens in the vocabulary.
+func (wp *WordPiece) pieces(word string) []string {
+	runes := []rune(word)
+	if len(runes) > wordPieceMaxChars {
+		return []string{WordPieceUnknown}
+	}
+
+	var pieces []string
+	for start := 0; start < len(runes); {
+		end := len(runes)
+		piece := ""
+		for ; end > start; end-- {
+			candidate := string(runes[start:end])
+			if start > 0 {
+				candidate = WordPieceContinuation + candidate
+			}
+			if _, ok := wp.vocab[candidate]; ok {
+				piece = candidate
+				break
+			}
+		}
+		if piece == "" {
+			return []string{WordPieceUnknown}
+		}
+		pieces = append(pieces, piece)
+		start = end
+	}
+	return pieces
+}
+
+// splitBasic splits text on whitespace, and makes each punctuation mark and CJK
+// character a word of its own, as BERT's basic tokenizer does.
+func splitBasic(text string) []string {
+	var words []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			flush()
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.Is(unicode.Han, r):
+			flush()
+			words = append(words, string(r))
+		case unicode.IsControl(r) || r == utf8.RuneError:
+		default:
+			word.WriteRune(r)
+		}
+	}
+	flush()
+	return words
+}
+
+// BPE is a byte-pair encoding model. Text is split into words, and adjacent symbols of
+// each word are merged in the order the merges list them.
+//
+// Models loaded with LoadBPE are byte-level, as used by GPT-2 and Llama 3: words are
+// split the way GPT-2 splits them and their bytes are mapped to printable characters.
+// Models loaded with LoadTokenizerJSON may instead be SentencePiece models, as used by
+// Llama 2 and Mistral, which mark spaces with "▁" and spell out characters missing
+// from the vocabulary as byte tokens such as "<0x0A>".
+type BPE struct {
+	vocab map[string]int
+	ranks map[[2]string]int
+
+	metaspace    bool   // SentencePiece rather than byte-level
+	byteFallback bool   // Spell out unknown symbols as byte tokens
+	unknown      string // Token for unknown symbols without byte fallback
+
+	mu    sync.RWMutex
+	cache map[string][]string
+}
+
+// bpeCacheSize is the number of merged words a BPE remembers before starting over.
+const bpeCacheSize = 10000
+
+// LoadBPE reads a byte-level BPE model from a JSON object mapping tokens to IDs and a
+// merges list with one space-separated pair per line, highest priority first.
+func LoadBPE(vocab, merges io.Reader) (*BPE, error) {
+	bpe := &BPE{
+		ranks: make(map[[2]string]int),
+		cache: make(map[string][]string),
+	}
+	if err := json.NewDecoder(vocab).Decode(&bpe.vocab); err != nil {
+		return nil, fmt.Errorf("failed to read vocabulary: %w", err)
+	}
+
+	scanner := bufio.NewScanner(merges)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(line, "#version") || line == "" {
+			continue
+		}
+		if err := bpe.addMerge(line); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read merges: %w", err)
+	}
+	return bpe, nil
+}
+
+// addMerge adds a space-separated pair with the next rank.
+func (b *BPE) addMerge(merge string) error {
+	pair := strings.Split(merge, " ")
+	if len(pair) != 2 {
+		return fmt.Errorf("invalid merge: %q", merge)
+	}
+	b.ranks[[2]string{pair[0], pair[1]}] = len(b.ranks)
+	return nil
+}
+
+// LoadBPEFiles reads a byte-level BPE model from vocab.json and merges.txt files.
+func LoadBPEFiles(vocabPath, mergesPath string) (*BPE, error) {
+	vocab, err := os.Open(vocabPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vocabulary: %w", err)
+	}
+	defer vocab.Close()
+	merges, err := os.Open(mergesPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open merges: %w", err)
+	}
+	defer merges.Close()
+	return LoadBPE(vocab, merges)
+}
+
+// Tokenize splits text into BPE tokens.
+func (b *BPE) Tokenize(text string) []string {
+	if b.metaspace {
+		return b.tokenizeMetaspace(text)
+	}
+	var tokens []string
+	for _, word := range splitGPT2(text) {
+		tokens = append(tokens, b.merge(encodeBytes(word))...)
+	}
+	return tokens
+}
+
+// tokenizeMetaspace splits text into SentencePiece tokens.
+func (b *BPE) tokenizeMetaspace(text string) []string {
+	if text == "" {
+		return nil
+	}
+	text = metaspace + strings.ReplaceAll(text, " ", metaspace)
+
+	var tokens []string
+	for _, word := range splitMetaspace(text) {
+		for _, symbol := range b.merge(word) {
+			switch _, known := b.vocab[symbol]; {
+			case known:
+				tokens = append(tokens, symbol)
+			case b.byteFallback:
+				for i := 0; i < len(symbol); i++ {
+					tokens = append(tokens, fmt.Sprintf("<0x%02X>", symbol[i]))
+				}
+			case b.unknown != "" && (len(tokens) == 0 || tokens[len(tokens)-1] != b.unknown):
+				tokens = append(tokens, b.unknown)
+			}
+		}
+	}
+	return tokens
+}
+
+// ID returns the vocabulary ID of a token.
+func (b *BPE) ID(token string) (int, bool) {
+	id, ok := b.vocab[token]
+	return id, ok
+}
+
+// merge applies the merges to a byte-encoded word, lowest rank first.
+func (b *BPE) merge(word string) []string {
+	b.mu.RLock()
+	cached, ok := b.cache[word]
+	b.mu.RUnlock()
+	if ok {
+		return cached
+	}
+
+	symbols := make([]string, 0, len(word))
+	for _, r := range word {
+		symbols = append(symbols, string(r))
+	}
+	for len(symbols) > 1 {
+		best, bestRank := -1, 0
+		for i := 0; i < len(symbols)-1; i++ {
+			if rank, ok := b.ranks[[2]string{symbols[i], symbols[i+1]}]; ok && (best < 0 || rank < bestRank) {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		// Merge every occurrence of the pair, left to right
+		first, second := symbols[best], symbols[best+1]
+		merged := symbols[:0:0]
+		for i := 0; i < len(symbols); i++ {
+			if i < len(symbols)-1 && symbols[i] == first && symbols[i+1] == second {
+				merged = append(merged, first+second)
+				i++
+			} else {
+				merged = append(merged, symbols[i])
+			}
+		}
+		symbols = merged
+	}
+
+	b.mu.Lock()
+	if len(b.cache) >= bpeCacheSize {
+		b.cache = make(map[string][]string)
+	}
+	b.cache[word] = symbols
+	b.mu.Unlock()
+	return symbols
+}
+
+// byteEncoder maps each byte to a printable character, as GPT-2 does so that merges
+// never involve whitespace or control characters.
+var byteEncoder = func() [256]rune {
+	var encoder [256]rune
+	n := 0
+	for b := 0; b < 256; b++ {
+		if ('!' <= b && b <= '~') || (0xA1 <= b && b <= 0xAC) || (0xAE <= b && b <= 0xFF) {
+			encoder[b] = rune(b)
+		} else {
+			encoder[b] = rune(256 + n)
+			n++
+		}
+	}
+	return encoder
+}()
+
+// encodeBytes maps the bytes of word to their printable characters.
+func encodeBytes(word string) string {
+	var b strings.Builder
+	for i := 0; i < len(word); i++ {
+		b.WriteRune(byteEncoder[word[i]])
+	}
+	return b.String()
+}
+
+// metaspace is the character SentencePiece models use for spaces.
+const metaspace = "▁"
+
+// splitMetaspace splits text before each run of metaspaces, so words start with their
+// leading spaces, as merges never join a word to the spaces after it.
+func splitMetaspace(text string) []string {
+	var words []string
+	start := 0
+	for i := 1; i < len(text); i++ {
+		if strings.HasPrefix(text[i:], metaspace) && !strings.HasSuffix(text[:i], metaspace) {
+			words = append(words, text[start:i])
+			start = i
+		}
+	}
+	return append(words, text[start:])
+}
+
+// gpt2Contractions are the suffixes GPT-2 splits from words.
+var gpt2Contractions = []string{"'s", "'t", "'re", "'ve", "'m", "'ll", "'d"}
+
+// splitGPT2 splits text into words as GPT-2's pattern does: contractions, then runs of
+// letters, digits, or other characters, each with an optional leading space, and runs
+// of whitespace. A run of whitespace before a word leaves its last space to the word.
+func splitGPT2(text string) []string {
+	var words []string
+	for i := 0; i < len(text); {
+		// Contractions
+		matched := false
+		for _, suffix := range gpt2Contractions {
+			if strings.HasPrefix(text[i:], suffix) {
+				words = append(words, suffix)
+				i += len(suffix)
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+
+		// Letters, digits, or other characters, with an optional leading space
+		j := i
+		if text[j] == ' ' {
+			j++
+		}
+		if j < len(text) {
+			r, _ := utf8.DecodeRuneInString(text[j:])
+			if class := runeClass(r); class != classSpace {
+				j = scanClass(text, j, class)
+				words = append(words, text[i:j])
+				i = j
+				continue
+			}
+		}
+
+		// Whitespace, leaving the last space to the word that follows
+		j = scanClass(text, i, classSpace)
+		if j < len(text) {
+			_, n := utf8.DecodeLastRuneInString(text[i:j])
+			if j-n > i {
+				j -= n
+			}
+		}
+		words = append(words, text[i:j])
+		i = j
+	}
+	return words
+}
+
+// Character classes of splitGPT2
+const (
+	classLetter = iota
+	classNumber
+	classSpace
+	classOther
+)
+
+// runeClass returns the character class of r.
+func runeClass(r rune) int {
+	switch {
+	case unicode.IsLetter(r):
+		return classLetter
+	case unicode.IsNumber(r):
+		return classNumber
+	case unicode.IsSpace(r):
+		return classSpace
+	default:
+		return classOther
+	}
+}
+
+// scanClass returns the offset just past the run of class characters starting at i.
+func scanClass(text string, i, class int) int {
+	for i < len(text) {
+		r, n := utf8.DecodeRuneInString(text[i:])
+		if runeClass(r) != class {
+			break
+		}
+		i += n
+	}
+	return i
+}
@@ -0,0 +1,70 @@
+package preprocessing
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWordPiece(t *testing.T) {
+	vocab := "[PAD]\n[UNK]\nthe\nllama\n##s\nun\n##want\n##ed\n,\n!\n"
+	wp, err := LoadWordPiece(strings.NewReader(vocab))
+	if err != nil {
+		t.Fatalf("Failed to load vocabulary: %v", err)
+	}
+
+	tokenizer, err := NewTokenizerWithOptions(TokenizerOptions{Mode: ModeSubword, Subword: wp, Lowercase: true})
+	if err != nil {
+		t.Fatalf("Failed to create tokenizer: %v", err)
+	}
+	got := tokenizer.Tokenize("The unwanted llamas, zebras!")
+	want := []string{"the", "un", "##want", "##ed", "llama", "##s", ",", "[UNK]", "!"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	if id, ok := wp.ID("##want"); !ok || id != 6 {
+		t.Errorf("Expected ID 6, got %d, %v", id, ok)
+	}
+	if _, err := LoadWordPiece(strings.NewReader("")); err == nil {
+		t.Error("Expected an error for an empty vocabulary")
+	}
+}
+
+func TestBPE(t *testing.T) {
+	dir := t.TempDir()
+	vocabPath := filepath.Join(dir, "vocab.json")
+	mergesPath := filepath.Join(dir, "merges.txt")
+	vocab := `{"h": 0, "e": 1, "l": 2, "o": 3, "Ġ": 4, "w": 5, "r": 6, "d": 7, "!": 8, "'": 9, "s": 10,
+		"he": 11, "ll": 12, "hell": 13, "hello": 14, "Ġw": 15, "or": 16, "Ġwor": 17, "Ġworld": 18, "'s": 19}`
+	merges := "#version: 0.2\nh e\nl l\nhe ll\nhell o\nĠ w\no r\nĠw or\nĠwor l\nĠworl d\n' s\n"
+	os.WriteFile(vocabPath, []byte(vocab), 0o644)
+	os.WriteFile(mergesPath, []byte(merges), 0o644)
+
+	bpe, err := LoadBPEFiles(vocabPath, mergesPath)
+	if err != nil {
+		t.Fatalf("Failed to load model: %v", err)
+	}
+	got := bpe.Tokenize("hello world's!")
+	want := []string{"hello", "Ġworld", "'s", "!"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if id, ok := bpe.ID("Ġworld"); !ok || id != 18 {
+		t.Errorf("Expected ID 18, got %d, %v", id, ok)
+	}
+
+	if _, err := LoadBPE(strings.NewReader(vocab), strings.NewReader("a b c\n")); err == nil {
+		t.Error("Expected an error for an invalid merge")
+	}
+}
+
+func TestSplitGPT2(t *testing.T) {
+	got := splitGPT2("I'm here  now\n\nok 42!?")
+	want := []string{"I", "'m", " here", " ", " now", "\n", "\n", "ok", " 42", "!?"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,141 @@
+package preprocessing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// tokenizerFile is the subset of a Hugging Face tokenizer.json file read by LoadTokenizerJSON.
+type tokenizerFile struct {
+	PreTokenizer *struct {
+		Type          string `json:"type"`
+		PreTokenizers []struct {
+			Type string `json:"type"`
+		} `json:"pretokenizers"`
+	} `json:"pre_tokenizer"`
+	Model struct {
+		Type         string            `json:"type"`
+		Vocab        map[string]int    `json:"vocab"`
+		Merges       []json.RawMessage `json:"merges"`
+		UnkToken     *string           `json:"unk_token"`
+		ByteFallback bool              `json:"byte_fallback"`
+	} `json:"model"`
+}
+
+// byteLevel reports whether the file's pre-tokenizer maps bytes to characters, as in
+// GPT-2 and Llama 3, rather than marking spaces as SentencePiece does.
+func (f *tokenizerFile) byteLevel() bool {
+	if f.PreTokenizer == nil {
+		return false
+	}
+	if f.PreTokenizer.Type == "ByteLevel" {
+		return true
+	}
+	for _, preTokenizer := range f.PreTokenizer.PreTokenizers {
+		if preTokenizer.Type == "ByteLevel" {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadTokenizerJSON reads a BPE model from a Hugging Face tokenizer.json file, as
+// published with Llama and Mistral models. Byte-level models, such as Llama 3, split
+// words the way GPT-2 does, which can differ from the model's own pattern for runs of
+// digits, so their counts are close rather than exact. Other models are read as
+// SentencePiece models, such as Llama 2 and Mistral.
+func LoadTokenizerJSON(r io.Reader) (*BPE, error) {
+	var file tokenizerFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to read tokenizer: %w", err)
+	}
+	if file.Model.Type != "BPE" {
+		return nil, fmt.Errorf("unsupported tokenizer model: %s", file.Model.Type)
+	}
+
+	bpe := &BPE{
+		vocab:        file.Model.Vocab,
+		ranks:        make(map[[2]string]int),
+		metaspace:    !file.byteLevel(),
+		byteFallback: file.Model.ByteFallback,
+		cache:        make(map[string][]string),
+	}
+	if file.Model.UnkToken != nil {
+		bpe.unknown = *file.Model.UnkToken
+	}
+
+	// Merges are "a b" strings, or ["a", "b"] pairs in newer files
+	for _, raw := range file.Model.Merges {
+		var merge string
+		if err := json.Unmarshal(raw, &merge); err == nil {
+			if err := bpe.addMerge(merge); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var pair []string
+		if err := json.Unmarshal(raw, &pair); err != nil || len(pair) != 2 {
+			return nil, fmt.Errorf("invalid merge: %s", raw)
+		}
+		bpe.ranks[[2]string{pair[0], pair[1]}] = len(bpe.ranks)
+	}
+	return bpe, nil
+}
+
+// LoadTokenizerJSONFile reads a BPE model from a Hugging Face tokenizer.json file.
+func LoadTokenizerJSONFile(path string) (*BPE, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tokenizer: %w", err)
+	}
+	defer file.Close()
+	return LoadTokenizerJSON(file)
+}
+
+// TokenCounter counts the tokens text takes up in a model's context window, using the
+// model's own vocabulary. Special tokens the model adds around a prompt, such as the
+// beginning-of-sequence token, are not counted.
+type TokenCounter struct {
+	model SubwordModel
+}
+
+// NewTokenCounter creates a TokenCounter for the given model, usually one loaded with
+// LoadTokenizerJSONFile from the tokenizer.json published with it.
+func NewTokenCounter(model SubwordModel) *TokenCounter {
+	return &TokenCounter{model: model}
+}
+
+// Count returns the number of tokens in text.
+func (tc *TokenCounter) Count(text string) int {
+	return len(tc.model.Tokenize(text))
+}
+
+// TruncateToTokens returns the start of text, cut between characters, that fits in n
+// tokens. Text that already fits is returned unchanged.
+func (tc *TokenCounter) TruncateToTokens(text string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if tc.Count(text) <= n {
+		return text
+	}
+
+	// Search the character boundaries for the last prefix that fits
+	offsets := make([]int, 0, len(text)+1)
+	for i := range text {
+		offsets = append(offsets, i)
+	}
+	offsets = append(offsets, len(text))
+	fits, exceeds := 0, len(offsets)-1
+	for exceeds-fits > 1 {
+		mid := (fits + exceeds) / 2
+		if tc.Count(text[:offsets[mid]]) <= n {
+			fits = mid
+		} else {
+			exceeds = mid
+		}
+	}
+	return text[:offsets[fits]]
+}
@@ -0,0 +1,91 @@
+package preprocessing
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sentencePieceJSON is a tokenizer.json in the style of Llama 2 and Mistral.
+const sentencePieceJSON = `{
+	"normalizer": {"type": "Sequence", "normalizers": [{"type": "Prepend", "prepend": "▁"}, {"type": "Replace"}]},
+	"pre_tokenizer": null,
+	"model": {
+		"type": "BPE",
+		"unk_token": "<unk>",
+		"byte_fallback": true,
+		"vocab": {"<unk>": 0, "<0x0A>": 1, "<0x21>": 2, "▁": 3, "h": 4, "e": 5, "l": 6, "o": 7, "w": 8, "r": 9, "d": 10,
+			"▁h": 11, "▁he": 12, "ll": 13, "▁hell": 14, "▁hello": 15, "▁w": 16, "or": 17, "▁wor": 18, "ld": 19, "▁world": 20},
+		"merges": ["▁ h", "▁h e", "l l", "▁he ll", "▁hell o", "▁ w", "o r", "▁w or", "l d", "▁wor ld"]
+	}
+}`
+
+// byteLevelJSON is a tokenizer.json in the style of Llama 3.
+const byteLevelJSON = `{
+	"pre_tokenizer": {"type": "Sequence", "pretokenizers": [{"type": "Split"}, {"type": "ByteLevel"}]},
+	"model": {
+		"type": "BPE",
+		"vocab": {"h": 0, "i": 1, "Ġ": 2, "t": 3, "e": 4, "r": 5, "hi": 6, "Ġt": 7, "Ġth": 8, "Ġthe": 9, "Ġther": 10, "Ġthere": 11, "Ċ": 12},
+		"merges": [["h", "i"], ["Ġ", "t"], ["Ġt", "h"], ["Ġth", "e"], ["Ġthe", "r"], ["Ġther", "e"]]
+	}
+}`
+
+func TestLoadTokenizerJSON(t *testing.T) {
+	sentencePiece, err := LoadTokenizerJSON(strings.NewReader(sentencePieceJSON))
+	if err != nil {
+		t.Fatalf("Failed to load tokenizer: %v", err)
+	}
+	got := sentencePiece.Tokenize("hello world!\n")
+	want := []string{"▁hello", "▁world", "<0x21>", "<0x0A>"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	byteLevel, err := LoadTokenizerJSON(strings.NewReader(byteLevelJSON))
+	if err != nil {
+		t.Fatalf("Failed to load tokenizer: %v", err)
+	}
+	got = byteLevel.Tokenize("hi there\n")
+	want = []string{"hi", "Ġthere", "Ċ"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	if _, err := LoadTokenizerJSON(strings.NewReader(`{"model": {"type": "Unigram"}}`)); err == nil {
+		t.Error("Expected an error for an unsupported model")
+	}
+}
+
+func TestTokenCounter(t *testing.T) {
+	model, err := LoadTokenizerJSON(strings.NewReader(sentencePieceJSON))
+	if err != nil {
+		t.Fatalf("Failed to load tokenizer: %v", err)
+	}
+	counter := NewTokenCounter(model)
+
+	if n := counter.Count("hello world hello"); n != 3 {
+		t.Errorf("Expected 3 tokens, got %d", n)
+	}
+	if n := counter.Count(""); n != 0 {
+		t.Errorf("Expected 0 tokens, got %d", n)
+	}
+
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{n: 3, want: "hello world hello"},
+		{n: 2, want: "hello w"},
+		{n: 1, want: "hello"},
+		{n: 0, want: ""},
+	}
+	for _, test := range tests {
+		got := counter.TruncateToTokens("hello world hello", test.n)
+		if got != test.want {
+			t.Errorf("Expected %q for %d tokens, got %q", test.want, test.n, got)
+		}
+		if counter.Count(got) > test.n {
+			t.Errorf("Expected at most %d tokens, got %d", test.n, counter.Count(got))
+		}
+	}
+}
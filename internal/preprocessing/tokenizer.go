@@ -1,90 +1,292 @@
-package preprocessing
-
-import (
-	"regexp"
-	"strings"
-	"unicode"
-)
-
-// Tokenizer defines the methods for tokenizing and preprocessing text
-type Tokenizer struct {
-	removePunctuation bool
-	lowercase         bool
-	removeStopWords   bool
-	stopWords         map[string]struct{}
-}
-
-// NewTokenizer initializes a Tokenizer with customizable options
-func NewTokenizer(removePunctuation, lowercase, removeStopWords bool, stopWords []string) *Tokenizer {
-	stopWordsMap := make(map[string]struct{})
-	for _, word := range stopWords {
-		stopWordsMap[word] = struct{}{}
-	}
-	return &Tokenizer{
-		removePunctuation: removePunctuation,
-		lowercase:         lowercase,
-		removeStopWords:   removeStopWords,
-		stopWords:         stopWordsMap,
-	}
-}
-
-// Tokenize splits the text into tokens based on whitespace
-func (t *Tokenizer) Tokenize(text string) []string {
-	if t.lowercase {
-		text = strings.ToLower(text)
-	}
-	if t.removePunctuation {
-		text = removePunctuation(text)
-	}
-
-	tokens := strings.Fields(text)
-
-	if t.removeStopWords {
-		tokens = t.filterStopWords(tokens)
-	}
-
-	return tokens
-}
-
-// filterStopWords removes common stop words from tokens
-func (t *Tokenizer) filterStopWords(tokens []string) []string {
-	var filtered []string
-	for _, token := range tokens {
-		if _, found := t.stopWords[token]; !found {
-			filtered = append(filtered, token)
-		}
-	}
-	return filtered
-}
-
-// removePunctuation removes punctuation from the input text
-func removePunctuation(text string) string {
-	return strings.Map(func(r rune) rune {
-		if unicode.IsPunct(r) {
-			return -1
-		}
-		return r
-	}, text)
-}
-
-// NGram generates n-grams from tokens
-func (t *Tokenizer) NGram(tokens []string, n int) [][]string {
-	var ngrams [][]string
-	for i := 0; i <= len(tokens)-n; i++ {
-		ngrams = append(ngrams, tokens[i:i+n])
-	}
-	return ngrams
-}
-
-// Advanced Preprocessing (optional): Removes URLs, digits, or other specific patterns
-func (t *Tokenizer) CleanText(text string) string {
-	// Remove URLs
-	reURL := regexp.MustCompile(`http[s]?://\S+`)
-	text = reURL.ReplaceAllString(text, "")
-
-	// Remove digits
-	reDigits := regexp.MustCompile(`\d+`)
-	text = reDigits.ReplaceAllString(text, "")
-
-	return strings.TrimSpace(text)
-}
+package preprocessing
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// TokenizeMode selects how a Tokenizer splits text into tokens.
+type TokenizeMode string
+
+const (
+	// ModeWhitespace splits text on whitespace, leaving punctuation attached to words.
+	ModeWhitespace TokenizeMode = "whitespace"
+
+	// ModeWords splits text into words and punctuation marks. Contractions, possessives,
+	// hyphenated words, and decimal numbers stay whole, so "don't" is one token and
+	// "end." is two.
+	ModeWords TokenizeMode = "words"
+
+	// ModeSubword splits text into the subword units of a vocabulary, such as those of
+	// a WordPiece or BPE model, which approximates how language models count tokens.
+	ModeSubword TokenizeMode = "subword"
+)
+
+// TokenizerOptions configures a Tokenizer.
+type TokenizerOptions struct {
+	// Mode selects how text is split into tokens.
+	// Default: ModeWhitespace
+	Mode TokenizeMode
+
+	// RemovePunctuation drops punctuation. In ModeWords, apostrophes and hyphens inside
+	// words are kept.
+	// Default: false
+	RemovePunctuation bool
+
+	// Lowercase converts text to lower case before it is split.
+	// Default: false
+	Lowercase bool
+
+	// RemoveStopWords drops the tokens listed in StopWords and the built-in stop words
+	// of StopWordLanguages. It does not apply in ModeSubword, whose tokens are not words.
+	// Default: false
+	RemoveStopWords bool
+
+	// StopWords lists the tokens dropped by RemoveStopWords. Optional.
+	StopWords []string
+
+	// StopWordLanguages adds the built-in stop words of these languages, by ISO 639-1
+	// code, to StopWords. StopWordLanguages returns the supported codes. Optional.
+	StopWordLanguages []string
+
+	// Normalization is the Unicode normalization form text is converted to before it is
+	// split. Optional.
+	Normalization Normalization
+
+	// FoldAccents removes accents from text before it is split, so "café" and "cafe"
+	// are the same token.
+	// Default: false
+	FoldAccents bool
+
+	// StripEmoji removes emoji from text before it is split.
+	// Default: false
+	StripEmoji bool
+
+	// Subword splits text into subword units in ModeSubword, for example a WordPiece
+	// or BPE model loaded from its vocabulary files. Required for ModeSubword.
+	Subword SubwordModel
+}
+
+// DefaultTokenizerOptions returns the default tokenizer options.
+func DefaultTokenizerOptions() TokenizerOptions {
+	return TokenizerOptions{
+		Mode: ModeWhitespace,
+	}
+}
+
+// Tokenizer defines the methods for tokenizing and preprocessing text
+type Tokenizer struct {
+	mode              TokenizeMode
+	subword           SubwordModel
+	removePunctuation bool
+	lowercase         bool
+	removeStopWords   bool
+	stopWords         map[string]struct{}
+	normalization     Normalization
+	foldAccents       bool
+	stripEmoji        bool
+}
+
+// NewTokenizer initializes a Tokenizer with customizable options
+func NewTokenizer(removePunctuation, lowercase, removeStopWords bool, stopWords []string) *Tokenizer {
+	t, _ := NewTokenizerWithOptions(TokenizerOptions{
+		RemovePunctuation: removePunctuation,
+		Lowercase:         lowercase,
+		RemoveStopWords:   removeStopWords,
+		StopWords:         stopWords,
+	})
+	return t
+}
+
+// NewTokenizerWithOptions initializes a Tokenizer with the given options. Zero values
+// fall back to the defaults. It returns an error for an unknown mode or normalization
+// form, ModeSubword without a Subword model, or a stop word language without a
+// built-in list.
+func NewTokenizerWithOptions(options TokenizerOptions) (*Tokenizer, error) {
+	if options.Mode == "" {
+		options.Mode = DefaultTokenizerOptions().Mode
+	}
+	switch options.Mode {
+	case ModeWhitespace, ModeWords:
+	case ModeSubword:
+		if options.Subword == nil {
+			return nil, fmt.Errorf("subword mode requires a subword model")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported tokenize mode: %s", options.Mode)
+	}
+	switch options.Normalization {
+	case NormalizeNone, NormalizeNFC, NormalizeNFKC:
+	default:
+		return nil, fmt.Errorf("unsupported normalization: %s", options.Normalization)
+	}
+
+	t := &Tokenizer{
+		mode:              options.Mode,
+		subword:           options.Subword,
+		removePunctuation: options.RemovePunctuation,
+		lowercase:         options.Lowercase,
+		removeStopWords:   options.RemoveStopWords,
+		stopWords:         make(map[string]struct{}),
+		normalization:     options.Normalization,
+		foldAccents:       options.FoldAccents,
+		stripEmoji:        options.StripEmoji,
+	}
+
+	stopWords := options.StopWords
+	for _, language := range options.StopWordLanguages {
+		words, ok := StopWords(language)
+		if !ok {
+			return nil, fmt.Errorf("no built-in stop words for language: %s", language)
+		}
+		stopWords = append(stopWords, words...)
+	}
+	// Stop words are prepared like the text, so "Über" still matches "über"
+	for _, word := range stopWords {
+		t.stopWords[t.normalize(word)] = struct{}{}
+	}
+	return t, nil
+}
+
+// normalize applies the tokenizer's normalization, accent folding, emoji stripping, and
+// lowercasing to text.
+func (t *Tokenizer) normalize(text string) string {
+	text = Normalize(text, t.normalization)
+	if t.foldAccents {
+		text = FoldAccents(text)
+	}
+	if t.stripEmoji {
+		text = StripEmoji(text)
+	}
+	if t.lowercase {
+		text = strings.ToLower(text)
+	}
+	return text
+}
+
+// Tokenize splits the text into tokens according to the tokenizer's mode
+func (t *Tokenizer) Tokenize(text string) []string {
+	text = t.normalize(text)
+
+	var tokens []string
+	switch t.mode {
+	case ModeWords:
+		tokens = splitWords(text)
+		if t.removePunctuation {
+			tokens = filterPunctuation(tokens)
+		}
+	case ModeSubword:
+		if t.removePunctuation {
+			text = removePunctuation(text)
+		}
+		return t.subword.Tokenize(text)
+	default:
+		if t.removePunctuation {
+			text = removePunctuation(text)
+		}
+		tokens = strings.Fields(text)
+	}
+
+	if t.removeStopWords {
+		tokens = t.filterStopWords(tokens)
+	}
+
+	return tokens
+}
+
+// filterStopWords removes common stop words from tokens
+func (t *Tokenizer) filterStopWords(tokens []string) []string {
+	var filtered []string
+	for _, token := range tokens {
+		if _, found := t.stopWords[token]; !found {
+			filtered = append(filtered, token)
+		}
+	}
+	return filtered
+}
+
+// removePunctuation removes punctuation from the input text
+func removePunctuation(text string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// wordPattern matches words, keeping apostrophes, hyphens, and decimal separators
+// between letters or digits, and single punctuation marks or symbols.
+var wordPattern = regexp.MustCompile(`[\p{L}\p{M}\p{N}]+(?:['’\-.,][\p{L}\p{M}\p{N}]+)*|[^\s\p{L}\p{M}\p{N}]`)
+
+// splitWords splits text into words and punctuation marks.
+func splitWords(text string) []string {
+	var tokens []string
+	for _, token := range wordPattern.FindAllString(text, -1) {
+		if strings.ContainsAny(token, ".,") {
+			tokens = append(tokens, splitSeparators(token)...)
+		} else {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// splitSeparators splits a token at the periods and commas that do not join digits.
+func splitSeparators(token string) []string {
+	var tokens []string
+	start := 0
+	runes := []rune(token)
+	for i, r := range runes {
+		if r != '.' && r != ',' {
+			continue
+		}
+		if i > 0 && i < len(runes)-1 && unicode.IsDigit(runes[i-1]) && unicode.IsDigit(runes[i+1]) {
+			continue
+		}
+		if i > start {
+			tokens = append(tokens, string(runes[start:i]))
+		}
+		tokens = append(tokens, string(r))
+		start = i + 1
+	}
+	if start < len(runes) {
+		tokens = append(tokens, string(runes[start:]))
+	}
+	return tokens
+}
+
+// filterPunctuation drops tokens made of punctuation and symbols only.
+func filterPunctuation(tokens []string) []string {
+	var filtered []string
+	for _, token := range tokens {
+		if strings.IndexFunc(token, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }) >= 0 {
+			filtered = append(filtered, token)
+		}
+	}
+	return filtered
+}
+
+// NGram generates n-grams from tokens
+func (t *Tokenizer) NGram(tokens []string, n int) [][]string {
+	var ngrams [][]string
+	for i := 0; i <= len(tokens)-n; i++ {
+		ngrams = append(ngrams, tokens[i:i+n])
+	}
+	return ngrams
+}
+
+// Advanced Preprocessing (optional): Removes URLs, digits, or other specific patterns
+func (t *Tokenizer) CleanText(text string) string {
+	// Remove URLs
+	reURL := regexp.MustCompile(`http[s]?://\S+`)
+	text = reURL.ReplaceAllString(text, "")
+
+	// Remove digits
+	reDigits := regexp.MustCompile(`\d+`)
+	text = reDigits.ReplaceAllString(text, "")
+
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,124 @@
+package preprocessing
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenizeWhitespace(t *testing.T) {
+	tokenizer := NewTokenizer(true, true, true, []string{"the"})
+	got := tokenizer.Tokenize("The llama's wool, unspun.")
+	want := []string{"llamas", "wool", "unspun"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestTokenizeWords(t *testing.T) {
+	tokenizer, err := NewTokenizerWithOptions(TokenizerOptions{Mode: ModeWords})
+	if err != nil {
+		t.Fatalf("Failed to create tokenizer: %v", err)
+	}
+	got := tokenizer.Tokenize(`Don't stop—it's a well-known fact: 3.14 isn't 1,000 (e.g. "pi").`)
+	want := []string{"Don't", "stop", "—", "it's", "a", "well-known", "fact", ":", "3.14", "isn't", "1,000", "(", "e", ".", "g", ".", `"`, "pi", `"`, ")", "."}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	tokenizer, _ = NewTokenizerWithOptions(TokenizerOptions{
+		Mode:              ModeWords,
+		RemovePunctuation: true,
+		Lowercase:         true,
+		RemoveStopWords:   true,
+		StopWords:         []string{"a"},
+	})
+	got = tokenizer.Tokenize("It’s a llama’s world, isn't it?")
+	want = []string{"it’s", "llama’s", "world", "isn't", "it"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestTokenizeNormalization(t *testing.T) {
+	tokenizer, err := NewTokenizerWithOptions(TokenizerOptions{
+		Mode:              ModeWords,
+		Lowercase:         true,
+		RemoveStopWords:   true,
+		StopWordLanguages: []string{"en", "DE"},
+		Normalization:     NormalizeNFKC,
+		FoldAccents:       true,
+		StripEmoji:        true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create tokenizer: %v", err)
+	}
+	got := tokenizer.Tokenize("Über den Café 🎉 and the ﬁeld")
+	want := []string{"cafe", "field"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestStopWords(t *testing.T) {
+	for _, language := range StopWordLanguages() {
+		words, ok := StopWords(language)
+		if !ok || len(words) < 50 {
+			t.Errorf("Expected a built-in list for %s, got %d words", language, len(words))
+		}
+	}
+	if _, ok := StopWords("xx"); ok {
+		t.Error("Expected no list for an unknown language")
+	}
+}
+
+func TestNewTokenizerWithOptionsErrors(t *testing.T) {
+	if _, err := NewTokenizerWithOptions(TokenizerOptions{StopWordLanguages: []string{"xx"}}); err == nil {
+		t.Error("Expected an error for a language without stop words")
+	}
+	if _, err := NewTokenizerWithOptions(TokenizerOptions{Normalization: "nfd"}); err == nil {
+		t.Error("Expected an error for an unknown normalization")
+	}
+	if _, err := NewTokenizerWithOptions(TokenizerOptions{Mode: "characters"}); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+	if _, err := NewTokenizerWithOptions(TokenizerOptions{Mode: ModeSubword}); err == nil {
+		t.Error("Expected an error for subword mode without a model")
+	}
+}
+
+func TestSplitSentences(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{
+			text: "Hello there. How are you? I'm fine!",
+			want: []string{"Hello there.", "How are you?", "I'm fine!"},
+		},
+		{
+			text: "Dr. Smith met J. R. R. Tolkien, i.e. the author, at 3.30 p.m. today. It rained.",
+			want: []string{"Dr. Smith met J. R. R. Tolkien, i.e. the author, at 3.30 p.m. today.", "It rained."},
+		},
+		{
+			text: `She said "Stop." Then she left... Or did she?!`,
+			want: []string{`She said "Stop."`, "Then she left...", "Or did she?!"},
+		},
+		{
+			text: "A heading\n\nA paragraph without a period\nthat wraps. Done",
+			want: []string{"A heading", "A paragraph without a period\nthat wraps.", "Done"},
+		},
+		{
+			text: `Dr. Smith arrived. "Welcome!" she said. Wow… Really?`,
+			want: []string{"Dr. Smith arrived.", `"Welcome!" she said.`, "Wow…", "Really?"},
+		},
+		{
+			text: "  ",
+			want: nil,
+		},
+	}
+	for _, test := range tests {
+		if got := SplitSentences(test.text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Expected %q, got %q", test.want, got)
+		}
+	}
+}
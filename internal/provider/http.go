@@ -0,0 +1,147 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/h2co32/gollama/internal/models"
+	"github.com/h2co32/gollama/pkg/retry"
+)
+
+// httpAPI sends JSON requests to an HTTP API.
+type httpAPI struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// newHTTPAPI creates an httpAPI from provider options.
+func newHTTPAPI(options Options) *httpAPI {
+	return &httpAPI{
+		baseURL:    strings.TrimRight(options.BaseURL, "/"),
+		apiKey:     options.APIKey,
+		httpClient: options.HTTPClient,
+	}
+}
+
+// do sends a request with an optional JSON body and decodes the JSON response into out.
+func (a *httpAPI) do(ctx context.Context, method, path string, body, out interface{}) error {
+	res, err := a.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// events sends a POST request and calls fn with the data of each server-sent event in
+// the response, until the stream ends or sends "[DONE]". An event carrying an "error"
+// field ends the stream with that error.
+func (a *httpAPI) events(ctx context.Context, path string, body interface{}, fn func([]byte) error) error {
+	res, err := a.send(ctx, http.MethodPost, path, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := bytes.CutPrefix(scanner.Bytes(), []byte("data:"))
+		if !ok {
+			continue
+		}
+		data = bytes.TrimSpace(data)
+		if string(data) == "[DONE]" {
+			return nil
+		}
+		if message := errorMessage(data); message != "" {
+			return &models.APIError{StatusCode: res.StatusCode, Message: message}
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read stream: %w", err)
+	}
+	return nil
+}
+
+// send issues a request and returns the response if its status is successful, or else
+// a *models.APIError.
+func (a *httpAPI) send(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, a.baseURL+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if a.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+a.apiKey)
+	}
+
+	res, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to %s failed: %w", path, err)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		defer res.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(res.Body, 64*1024))
+
+		apiErr := &models.APIError{StatusCode: res.StatusCode, Message: http.StatusText(res.StatusCode)}
+		if delay, ok := retry.ParseRetryAfter(res.Header.Get("Retry-After")); ok {
+			apiErr.RetryAfter = delay
+		}
+		if message := errorMessage(data); message != "" {
+			apiErr.Message = message
+		} else if len(bytes.TrimSpace(data)) > 0 {
+			apiErr.Message = string(bytes.TrimSpace(data))
+		}
+		return nil, apiErr
+	}
+	return res, nil
+}
+
+// errorMessage returns the message of an error response body, which OpenAI-compatible
+// servers send as {"error": {"message": "..."}} and others as {"error": "..."}.
+func errorMessage(data []byte) string {
+	var body struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(data, &body) != nil || len(body.Error) == 0 {
+		return ""
+	}
+	var message string
+	if json.Unmarshal(body.Error, &message) == nil {
+		return message
+	}
+	var detail struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body.Error, &detail) == nil {
+		return detail.Message
+	}
+	return ""
+}
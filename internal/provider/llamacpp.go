@@ -0,0 +1,142 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/h2co32/gollama/internal/models"
+)
+
+// LlamaCppProvider serves the model of a llama.cpp server (llama-server). Chat,
+// embeddings, and model listing use the server's OpenAI-compatible API under /v1, so
+// the model's chat template is applied. Raw generate requests use the native
+// /completion endpoint, which sends the prompt to the model as is.
+type LlamaCppProvider struct {
+	*OpenAIProvider
+	api *httpAPI
+}
+
+// NewLlamaCppProvider creates a Provider for the llama.cpp server at options.BaseURL,
+// without the /v1 path. Zero option values fall back to the defaults.
+func NewLlamaCppProvider(options Options) *LlamaCppProvider {
+	defaults := DefaultOptions(TypeLlamaCpp)
+	if options.BaseURL == "" {
+		options.BaseURL = defaults.BaseURL
+	}
+	if options.HTTPClient == nil {
+		options.HTTPClient = defaults.HTTPClient
+	}
+	api := newHTTPAPI(options)
+
+	compatible := options
+	compatible.BaseURL = api.baseURL + "/v1"
+	return &LlamaCppProvider{OpenAIProvider: NewOpenAIProvider(compatible), api: api}
+}
+
+// completionRequest is the request body of llama.cpp's /completion endpoint.
+type completionRequest struct {
+	Prompt      string          `json:"prompt"`
+	Stream      bool            `json:"stream"`
+	NPredict    *int            `json:"n_predict,omitempty"`
+	Temperature *float64        `json:"temperature,omitempty"`
+	TopK        *int            `json:"top_k,omitempty"`
+	TopP        *float64        `json:"top_p,omitempty"`
+	Stop        []string        `json:"stop,omitempty"`
+	Seed        *int            `json:"seed,omitempty"`
+	JSONSchema  json.RawMessage `json:"json_schema,omitempty"`
+}
+
+// completionResponse is a response, or a chunk of a streamed response, from llama.cpp's
+// /completion endpoint.
+type completionResponse struct {
+	Content         string `json:"content"`
+	Model           string `json:"model"`
+	Stop            bool   `json:"stop"`
+	StopType        string `json:"stop_type"` // "eos", "word", or "limit"
+	StoppedLimit    bool   `json:"stopped_limit"`
+	TokensEvaluated int    `json:"tokens_evaluated"`
+	TokensPredicted int    `json:"tokens_predicted"`
+	Timings         struct {
+		PromptMS    float64 `json:"prompt_ms"`
+		PredictedMS float64 `json:"predicted_ms"`
+	} `json:"timings"`
+}
+
+// Generate sends a prompt to the model. Raw requests are completed as is with the
+// /completion endpoint; others are sent as chat completions with the system prompt
+// and the prompt as messages.
+func (p *LlamaCppProvider) Generate(ctx context.Context, req models.GenerateRequest, fn models.GenerateResponseFunc) error {
+	if !req.Raw {
+		return p.OpenAIProvider.Generate(ctx, req, fn)
+	}
+
+	start := time.Now()
+	completion := completionRequest{
+		Prompt: req.Prompt,
+		Stream: req.Stream == nil || *req.Stream,
+	}
+	if req.Format == "json" {
+		completion.JSONSchema = json.RawMessage("{}")
+	}
+	for key, value := range req.Options {
+		switch key {
+		case "num_predict":
+			completion.NPredict = intOption(value)
+		case "temperature":
+			completion.Temperature = floatOption(value)
+		case "top_k":
+			completion.TopK = intOption(value)
+		case "top_p":
+			completion.TopP = floatOption(value)
+		case "stop":
+			completion.Stop = stringsOption(value)
+		case "seed":
+			completion.Seed = intOption(value)
+		}
+	}
+
+	respond := func(res completionResponse) error {
+		generated := models.GenerateResponse{
+			Model:     res.Model,
+			CreatedAt: time.Now(),
+			Response:  res.Content,
+			Done:      res.Stop,
+		}
+		if generated.Model == "" {
+			generated.Model = req.Model
+		}
+		if res.Stop {
+			generated.DoneReason = "stop"
+			if res.StoppedLimit || res.StopType == "limit" {
+				generated.DoneReason = "length"
+			}
+			generated.Metrics = models.Metrics{
+				TotalDuration:      time.Since(start),
+				PromptEvalCount:    res.TokensEvaluated,
+				PromptEvalDuration: time.Duration(res.Timings.PromptMS * float64(time.Millisecond)),
+				EvalCount:          res.TokensPredicted,
+				EvalDuration:       time.Duration(res.Timings.PredictedMS * float64(time.Millisecond)),
+			}
+		}
+		return fn(generated)
+	}
+
+	if !completion.Stream {
+		var res completionResponse
+		if err := p.api.do(ctx, http.MethodPost, "/completion", completion, &res); err != nil {
+			return err
+		}
+		res.Stop = true
+		return respond(res)
+	}
+	return p.api.events(ctx, "/completion", completion, func(data []byte) error {
+		var res completionResponse
+		if err := json.Unmarshal(data, &res); err != nil {
+			return fmt.Errorf("failed to unmarshal completion chunk: %w", err)
+		}
+		return respond(res)
+	})
+}
@@ -0,0 +1,41 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/h2co32/gollama/internal/models"
+)
+
+// OllamaProvider serves models from an Ollama server. Requests pass through unchanged.
+type OllamaProvider struct {
+	client *models.OllamaClient
+}
+
+// NewOllamaProvider creates a Provider backed by client.
+func NewOllamaProvider(client *models.OllamaClient) *OllamaProvider {
+	return &OllamaProvider{client: client}
+}
+
+// Generate sends a prompt to a model with the Generate API.
+func (p *OllamaProvider) Generate(ctx context.Context, req models.GenerateRequest, fn models.GenerateResponseFunc) error {
+	return p.client.Generate(ctx, req, fn)
+}
+
+// Chat sends a chat conversation to a model with the Chat API.
+func (p *OllamaProvider) Chat(ctx context.Context, req models.ChatRequest, fn models.ChatResponseFunc) error {
+	return p.client.Chat(ctx, req, fn)
+}
+
+// Embed returns an embedding of each input with the Embed API.
+func (p *OllamaProvider) Embed(ctx context.Context, req models.EmbedRequest) (*models.EmbedResponse, error) {
+	return p.client.Embed(ctx, req)
+}
+
+// ListModels returns the models available on the Ollama server.
+func (p *OllamaProvider) ListModels(ctx context.Context) ([]models.ListModelResponse, error) {
+	res, err := p.client.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return res.Models, nil
+}
@@ -0,0 +1,301 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/h2co32/gollama/internal/models"
+	"github.com/h2co32/gollama/pkg/openai"
+)
+
+// OpenAIProvider serves models from a server implementing the OpenAI Chat Completions,
+// Embeddings, and Models APIs, such as OpenAI, vLLM, or LiteLLM.
+//
+// Ollama model options are translated to their OpenAI equivalents: temperature, top_p,
+// num_predict, stop, seed, presence_penalty, and frequency_penalty. The "json" format
+// requests a JSON object response. Generate requests are sent as chat completions with
+// the system prompt and the prompt as messages.
+type OpenAIProvider struct {
+	api *httpAPI
+}
+
+// NewOpenAIProvider creates a Provider for the OpenAI-compatible server at
+// options.BaseURL. Zero option values fall back to the defaults.
+func NewOpenAIProvider(options Options) *OpenAIProvider {
+	defaults := DefaultOptions(TypeOpenAI)
+	if options.BaseURL == "" {
+		options.BaseURL = defaults.BaseURL
+	}
+	if options.HTTPClient == nil {
+		options.HTTPClient = defaults.HTTPClient
+	}
+	return &OpenAIProvider{api: newHTTPAPI(options)}
+}
+
+// Generate sends a prompt to a model as a chat completion.
+func (p *OpenAIProvider) Generate(ctx context.Context, req models.GenerateRequest, fn models.GenerateResponseFunc) error {
+	var messages []models.Message
+	if req.System != "" {
+		messages = append(messages, models.Message{Role: openai.RoleSystem, Content: req.System})
+	}
+	messages = append(messages, models.Message{Role: openai.RoleUser, Content: req.Prompt})
+
+	return p.Chat(ctx, models.ChatRequest{
+		Model:     req.Model,
+		Messages:  messages,
+		Stream:    req.Stream,
+		Format:    req.Format,
+		KeepAlive: req.KeepAlive,
+		Options:   req.Options,
+	}, func(res models.ChatResponse) error {
+		return fn(models.GenerateResponse{
+			Model:      res.Model,
+			CreatedAt:  res.CreatedAt,
+			Response:   res.Message.Content,
+			Done:       res.Done,
+			DoneReason: res.DoneReason,
+			Metrics:    res.Metrics,
+		})
+	})
+}
+
+// Chat sends a chat conversation to a model as a chat completion.
+func (p *OpenAIProvider) Chat(ctx context.Context, req models.ChatRequest, fn models.ChatResponseFunc) error {
+	start := time.Now()
+	completion := toCompletionRequest(req)
+
+	if !completion.Stream {
+		var res openai.ChatCompletionResponse
+		if err := p.api.do(ctx, http.MethodPost, "/chat/completions", completion, &res); err != nil {
+			return err
+		}
+		chat := models.ChatResponse{
+			Model:      res.Model,
+			CreatedAt:  unixTime(res.Created),
+			Message:    models.Message{Role: openai.RoleAssistant},
+			Done:       true,
+			DoneReason: "stop",
+			Metrics:    usageMetrics(res.Usage, time.Since(start)),
+		}
+		if len(res.Choices) > 0 {
+			chat.Message.Content = res.Choices[0].Message.Content
+			chat.DoneReason = doneReason(res.Choices[0].FinishReason)
+		}
+		return fn(chat)
+	}
+
+	// The finish reason and usage arrive on the last chunks, and are reported on a
+	// final done chunk like Ollama's
+	final := models.ChatResponse{
+		Model:      req.Model,
+		Message:    models.Message{Role: openai.RoleAssistant},
+		Done:       true,
+		DoneReason: "stop",
+	}
+	err := p.api.events(ctx, "/chat/completions", completion, func(data []byte) error {
+		var chunk openai.ChatCompletionChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("failed to unmarshal chat completion chunk: %w", err)
+		}
+		if chunk.Model != "" {
+			final.Model = chunk.Model
+		}
+		final.CreatedAt = unixTime(chunk.Created)
+		if chunk.Usage != nil {
+			final.Metrics = usageMetrics(*chunk.Usage, 0)
+		}
+		if len(chunk.Choices) == 0 {
+			return nil
+		}
+		choice := chunk.Choices[0]
+		if choice.FinishReason != "" {
+			final.DoneReason = doneReason(choice.FinishReason)
+		}
+		if choice.Delta.Content == "" {
+			return nil
+		}
+		return fn(models.ChatResponse{
+			Model:     final.Model,
+			CreatedAt: final.CreatedAt,
+			Message:   models.Message{Role: openai.RoleAssistant, Content: choice.Delta.Content},
+		})
+	})
+	if err != nil {
+		return err
+	}
+	final.TotalDuration = time.Since(start)
+	return fn(final)
+}
+
+// Embed returns an embedding of each input with the Embeddings API.
+func (p *OpenAIProvider) Embed(ctx context.Context, req models.EmbedRequest) (*models.EmbedResponse, error) {
+	var res openai.EmbeddingResponse
+	if err := p.api.do(ctx, http.MethodPost, "/embeddings", openai.EmbeddingRequest{Model: req.Model, Input: req.Input}, &res); err != nil {
+		return nil, err
+	}
+	if len(res.Data) != len(req.Input) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(req.Input), len(res.Data))
+	}
+
+	embeddings := make([][]float32, len(res.Data))
+	for _, data := range res.Data {
+		if data.Index < 0 || data.Index >= len(embeddings) {
+			return nil, fmt.Errorf("embedding index %d out of range", data.Index)
+		}
+		embedding := make([]float32, len(data.Embedding))
+		for i, v := range data.Embedding {
+			embedding[i] = float32(v)
+		}
+		embeddings[data.Index] = embedding
+	}
+	return &models.EmbedResponse{
+		Model:           res.Model,
+		Embeddings:      embeddings,
+		PromptEvalCount: res.Usage.PromptTokens,
+	}, nil
+}
+
+// ListModels returns the models listed by the Models API.
+func (p *OpenAIProvider) ListModels(ctx context.Context) ([]models.ListModelResponse, error) {
+	var res struct {
+		Data []struct {
+			ID      string `json:"id"`
+			Created int64  `json:"created"`
+		} `json:"data"`
+	}
+	if err := p.api.do(ctx, http.MethodGet, "/models", nil, &res); err != nil {
+		return nil, err
+	}
+
+	list := make([]models.ListModelResponse, len(res.Data))
+	for i, model := range res.Data {
+		list[i] = models.ListModelResponse{Name: model.ID, Model: model.ID}
+		if model.Created > 0 {
+			list[i].ModifiedAt = time.Unix(model.Created, 0)
+		}
+	}
+	return list, nil
+}
+
+// toCompletionRequest translates an Ollama chat request into a chat completion request.
+func toCompletionRequest(req models.ChatRequest) openai.ChatCompletionRequest {
+	completion := openai.ChatCompletionRequest{
+		Model:    req.Model,
+		Messages: make([]openai.ChatCompletionMessage, len(req.Messages)),
+		Stream:   req.Stream == nil || *req.Stream,
+	}
+	for i, message := range req.Messages {
+		completion.Messages[i] = openai.ChatCompletionMessage{Role: message.Role, Content: message.Content}
+	}
+	if completion.Stream {
+		completion.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	}
+	if req.Format == "json" {
+		completion.ResponseFormat = &openai.ResponseFormat{Type: "json_object"}
+	}
+
+	for key, value := range req.Options {
+		switch key {
+		case "temperature":
+			completion.Temperature = floatOption(value)
+		case "top_p":
+			completion.TopP = floatOption(value)
+		case "presence_penalty":
+			completion.PresencePenalty = floatOption(value)
+		case "frequency_penalty":
+			completion.FrequencyPenalty = floatOption(value)
+		case "num_predict":
+			if n := intOption(value); n != nil && *n > 0 {
+				completion.MaxTokens = *n
+			}
+		case "seed":
+			completion.Seed = intOption(value)
+		case "stop":
+			completion.Stop = stringsOption(value)
+		}
+	}
+	return completion
+}
+
+// doneReason maps an OpenAI finish reason to an Ollama done reason.
+func doneReason(finishReason string) string {
+	if finishReason == openai.FinishReasonLength {
+		return "length"
+	}
+	return "stop"
+}
+
+// usageMetrics converts token usage to generation metrics.
+func usageMetrics(usage openai.Usage, total time.Duration) models.Metrics {
+	return models.Metrics{
+		TotalDuration:   total,
+		PromptEvalCount: usage.PromptTokens,
+		EvalCount:       usage.CompletionTokens,
+	}
+}
+
+// unixTime converts a Unix timestamp to a time.Time, using the current time if it is
+// zero.
+func unixTime(seconds int64) time.Time {
+	if seconds == 0 {
+		return time.Now()
+	}
+	return time.Unix(seconds, 0)
+}
+
+// floatOption converts a numeric model option to a float64, or nil if it is not a
+// number.
+func floatOption(value interface{}) *float64 {
+	var f float64
+	switch v := value.(type) {
+	case float64:
+		f = v
+	case float32:
+		f = float64(v)
+	case int:
+		f = float64(v)
+	case int64:
+		f = float64(v)
+	case json.Number:
+		parsed, err := v.Float64()
+		if err != nil {
+			return nil
+		}
+		f = parsed
+	default:
+		return nil
+	}
+	return &f
+}
+
+// intOption converts a numeric model option to an int, or nil if it is not a number.
+func intOption(value interface{}) *int {
+	f := floatOption(value)
+	if f == nil {
+		return nil
+	}
+	n := int(*f)
+	return &n
+}
+
+// stringsOption converts a string or list of strings model option to a []string.
+func stringsOption(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		strs := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				strs = append(strs, s)
+			}
+		}
+		return strs
+	}
+	return nil
+}
@@ -0,0 +1,476 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/h2co32/gollama/internal/metrics"
+	"github.com/h2co32/gollama/internal/models"
+)
+
+// ErrFirstResponseTimeout is returned by a Failover for a provider that did not start
+// responding within the timeout.
+var ErrFirstResponseTimeout = errors.New("provider did not respond in time")
+
+// Target is a named provider taking part in a routing policy. The name labels the
+// policy's metrics.
+type Target struct {
+	Name     string
+	Provider Provider
+
+	// Weight is the share of requests a Split sends to the provider, relative to the
+	// weights of its other providers. Ignored by other policies.
+	Weight int
+}
+
+// policyMetrics records the requests a routing policy sends to its providers, if it
+// has a metrics provider.
+type policyMetrics struct {
+	policy  string
+	metrics *metrics.MetricsProvider
+}
+
+// track records a request to the provider named name that started at start and ended
+// with err.
+func (m policyMetrics) track(name string, start time.Time, err error) {
+	if m.metrics != nil {
+		m.metrics.TrackProviderRequest(m.policy, name, time.Since(start), err != nil)
+	}
+}
+
+// FailoverOptions configures a Failover.
+type FailoverOptions struct {
+	// Name identifies the policy in metrics.
+	// Default: "failover"
+	Name string
+
+	// Providers are tried in order until one succeeds. Required.
+	Providers []Target
+
+	// Timeout is how long a provider has to send its first response, or its whole
+	// response to an embed or list request, before the next provider is tried. A
+	// response that has started streaming is not limited. Negative disables it.
+	// Default: 30 seconds
+	Timeout time.Duration
+
+	// Metrics records the requests sent to each provider and the failovers. Optional.
+	Metrics *metrics.MetricsProvider
+}
+
+// DefaultFailoverOptions returns the default failover options.
+func DefaultFailoverOptions() FailoverOptions {
+	return FailoverOptions{
+		Name:    "failover",
+		Timeout: 30 * time.Second,
+	}
+}
+
+// Failover is a Provider that sends each request to its first provider, and to the
+// next one when a provider fails or times out before responding. Once a provider has
+// started streaming a response, its errors are returned rather than retried elsewhere.
+// Client errors are returned as well, except 404 Not Found, 408 Request Timeout, and
+// 429 Too Many Requests, which another provider may not return.
+type Failover struct {
+	options FailoverOptions
+	metrics policyMetrics
+}
+
+// NewFailover creates a Failover. Zero option values fall back to the defaults.
+func NewFailover(options FailoverOptions) (*Failover, error) {
+	if len(options.Providers) == 0 {
+		return nil, errors.New("failover requires at least one provider")
+	}
+	defaults := DefaultFailoverOptions()
+	if options.Name == "" {
+		options.Name = defaults.Name
+	}
+	if options.Timeout == 0 {
+		options.Timeout = defaults.Timeout
+	}
+	return &Failover{
+		options: options,
+		metrics: policyMetrics{policy: options.Name, metrics: options.Metrics},
+	}, nil
+}
+
+// Generate sends a prompt to the first provider that serves it.
+func (f *Failover) Generate(ctx context.Context, req models.GenerateRequest, fn models.GenerateResponseFunc) error {
+	return f.run(ctx, func(ctx context.Context, p Provider, respond func() error) error {
+		return p.Generate(ctx, req, func(res models.GenerateResponse) error {
+			if err := respond(); err != nil {
+				return err
+			}
+			return fn(res)
+		})
+	})
+}
+
+// Chat sends a chat conversation to the first provider that serves it.
+func (f *Failover) Chat(ctx context.Context, req models.ChatRequest, fn models.ChatResponseFunc) error {
+	return f.run(ctx, func(ctx context.Context, p Provider, respond func() error) error {
+		return p.Chat(ctx, req, func(res models.ChatResponse) error {
+			if err := respond(); err != nil {
+				return err
+			}
+			return fn(res)
+		})
+	})
+}
+
+// Embed sends inputs to the first provider that serves them.
+func (f *Failover) Embed(ctx context.Context, req models.EmbedRequest) (*models.EmbedResponse, error) {
+	var res *models.EmbedResponse
+	err := f.run(ctx, func(ctx context.Context, p Provider, _ func() error) error {
+		var err error
+		res, err = p.Embed(ctx, req)
+		return err
+	})
+	return res, err
+}
+
+// ListModels returns the models of the first provider that lists them.
+func (f *Failover) ListModels(ctx context.Context) ([]models.ListModelResponse, error) {
+	var list []models.ListModelResponse
+	err := f.run(ctx, func(ctx context.Context, p Provider, _ func() error) error {
+		var err error
+		list, err = p.ListModels(ctx)
+		return err
+	})
+	return list, err
+}
+
+// run calls call with each provider in turn until one succeeds or fails in a way
+// another provider would not change. call must call respond before passing a response
+// on, and return its error if it fails.
+func (f *Failover) run(ctx context.Context, call func(ctx context.Context, p Provider, respond func() error) error) error {
+	var errs []error
+	for i, target := range f.options.Providers {
+		start := time.Now()
+		responded, err := f.attempt(ctx, target.Provider, call)
+		f.metrics.track(target.Name, start, err)
+		if err == nil || responded || ctx.Err() != nil || !failoverable(err) {
+			return err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", target.Name, err))
+		if i < len(f.options.Providers)-1 && f.options.Metrics != nil {
+			f.options.Metrics.TrackProviderFailover(f.options.Name, target.Name)
+		}
+	}
+	return fmt.Errorf("all providers failed: %w", errors.Join(errs...))
+}
+
+// attempt calls call with p, cancelling it if p does not respond within the timeout. It
+// reports whether p responded.
+func (f *Failover) attempt(ctx context.Context, p Provider, call func(ctx context.Context, p Provider, respond func() error) error) (bool, error) {
+	const (
+		waiting = iota
+		responded
+		timedOut
+	)
+	var state atomic.Int32
+
+	attemptCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if f.options.Timeout > 0 {
+		timer := time.AfterFunc(f.options.Timeout, func() {
+			if state.CompareAndSwap(waiting, timedOut) {
+				cancel()
+			}
+		})
+		defer timer.Stop()
+	}
+
+	err := call(attemptCtx, p, func() error {
+		if state.CompareAndSwap(waiting, responded) || state.Load() == responded {
+			return nil
+		}
+		return ErrFirstResponseTimeout
+	})
+	switch {
+	case err == nil || state.Load() == responded:
+		return state.Load() == responded, err
+	case state.Load() == timedOut:
+		return false, fmt.Errorf("%w after %s", ErrFirstResponseTimeout, f.options.Timeout)
+	}
+	return false, err
+}
+
+// failoverable reports whether another provider may serve a request that failed with
+// err: anything but a client error other than 404 Not Found, 408 Request Timeout, or
+// 429 Too Many Requests.
+func failoverable(err error) bool {
+	var apiErr *models.APIError
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	switch apiErr.StatusCode {
+	case http.StatusNotFound, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return apiErr.StatusCode < 400 || apiErr.StatusCode >= 500
+}
+
+// SplitOptions configures a Split.
+type SplitOptions struct {
+	// Name identifies the policy in metrics.
+	// Default: "split"
+	Name string
+
+	// Providers are the providers requests are split between, by Weight. At least one
+	// must have a positive weight. Required.
+	Providers []Target
+
+	// Metrics records the requests sent to each provider. Optional.
+	Metrics *metrics.MetricsProvider
+}
+
+// DefaultSplitOptions returns the default split options.
+func DefaultSplitOptions() SplitOptions {
+	return SplitOptions{Name: "split"}
+}
+
+// Split is a Provider that sends each request to one of its providers at random, in
+// proportion to their weights, for example to compare models or backends on a share
+// of the traffic. It is safe for concurrent use.
+type Split struct {
+	targets []Target
+	total   int
+	metrics policyMetrics
+}
+
+// NewSplit creates a Split. Zero option values fall back to the defaults.
+func NewSplit(options SplitOptions) (*Split, error) {
+	if options.Name == "" {
+		options.Name = DefaultSplitOptions().Name
+	}
+	s := &Split{metrics: policyMetrics{policy: options.Name, metrics: options.Metrics}}
+	for _, target := range options.Providers {
+		if target.Weight < 0 {
+			return nil, fmt.Errorf("weight of provider %s is negative", target.Name)
+		}
+		if target.Weight > 0 {
+			s.targets = append(s.targets, target)
+			s.total += target.Weight
+		}
+	}
+	if s.total == 0 {
+		return nil, errors.New("split requires a provider with a positive weight")
+	}
+	return s, nil
+}
+
+// pick returns a provider at random, in proportion to the weights.
+func (s *Split) pick() Target {
+	n := rand.Intn(s.total)
+	for _, target := range s.targets {
+		if n < target.Weight {
+			return target
+		}
+		n -= target.Weight
+	}
+	return s.targets[len(s.targets)-1]
+}
+
+// Generate sends a prompt to a provider picked at random.
+func (s *Split) Generate(ctx context.Context, req models.GenerateRequest, fn models.GenerateResponseFunc) error {
+	target, start := s.pick(), time.Now()
+	err := target.Provider.Generate(ctx, req, fn)
+	s.metrics.track(target.Name, start, err)
+	return err
+}
+
+// Chat sends a chat conversation to a provider picked at random.
+func (s *Split) Chat(ctx context.Context, req models.ChatRequest, fn models.ChatResponseFunc) error {
+	target, start := s.pick(), time.Now()
+	err := target.Provider.Chat(ctx, req, fn)
+	s.metrics.track(target.Name, start, err)
+	return err
+}
+
+// Embed sends inputs to a provider picked at random. Embeddings of different models
+// are not comparable, so a split between them suits generation rather than embedding.
+func (s *Split) Embed(ctx context.Context, req models.EmbedRequest) (*models.EmbedResponse, error) {
+	target, start := s.pick(), time.Now()
+	res, err := target.Provider.Embed(ctx, req)
+	s.metrics.track(target.Name, start, err)
+	return res, err
+}
+
+// ListModels returns the models of every provider, without duplicate names.
+func (s *Split) ListModels(ctx context.Context) ([]models.ListModelResponse, error) {
+	var list []models.ListModelResponse
+	seen := make(map[string]bool)
+	for _, target := range s.targets {
+		providerModels, err := target.Provider.ListModels(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list models of provider %s: %w", target.Name, err)
+		}
+		for _, model := range providerModels {
+			if !seen[model.Name] {
+				seen[model.Name] = true
+				list = append(list, model)
+			}
+		}
+	}
+	return list, nil
+}
+
+// ShadowOptions configures a Shadow.
+type ShadowOptions struct {
+	// Name identifies the policy in metrics.
+	// Default: "shadow"
+	Name string
+
+	// Primary serves the requests. Required.
+	Primary Target
+
+	// Shadow receives a copy of the requests, and its responses are discarded.
+	// Required.
+	Shadow Target
+
+	// SampleRate is the fraction of requests mirrored to Shadow, between 0 and 1.
+	// Default: 1
+	SampleRate float64
+
+	// Timeout limits each mirrored request.
+	// Default: 1 minute
+	Timeout time.Duration
+
+	// MaxInFlight is the number of mirrored requests that may run at once. Requests
+	// arriving while that many run are served but not mirrored, so a slow shadow
+	// cannot pile up work.
+	// Default: 16
+	MaxInFlight int
+
+	// Metrics records the requests sent to both providers and the requests not
+	// mirrored for MaxInFlight. Optional.
+	Metrics *metrics.MetricsProvider
+}
+
+// DefaultShadowOptions returns the default shadow options.
+func DefaultShadowOptions() ShadowOptions {
+	return ShadowOptions{
+		Name:        "shadow",
+		SampleRate:  1,
+		Timeout:     time.Minute,
+		MaxInFlight: 16,
+	}
+}
+
+// Shadow is a Provider that serves requests from its primary provider and mirrors them
+// to a shadow provider in the background, for example to try a new backend on real
+// traffic without affecting responses. Listing models only uses the primary provider.
+type Shadow struct {
+	options  ShadowOptions
+	metrics  policyMetrics
+	inFlight chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewShadow creates a Shadow. Zero option values fall back to the defaults.
+func NewShadow(options ShadowOptions) (*Shadow, error) {
+	if options.Primary.Provider == nil || options.Shadow.Provider == nil {
+		return nil, errors.New("shadow requires a primary and a shadow provider")
+	}
+	if options.SampleRate < 0 || options.SampleRate > 1 {
+		return nil, fmt.Errorf("sample rate %v is not between 0 and 1", options.SampleRate)
+	}
+	defaults := DefaultShadowOptions()
+	if options.Name == "" {
+		options.Name = defaults.Name
+	}
+	if options.SampleRate == 0 {
+		options.SampleRate = defaults.SampleRate
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = defaults.Timeout
+	}
+	if options.MaxInFlight <= 0 {
+		options.MaxInFlight = defaults.MaxInFlight
+	}
+	return &Shadow{
+		options:  options,
+		metrics:  policyMetrics{policy: options.Name, metrics: options.Metrics},
+		inFlight: make(chan struct{}, options.MaxInFlight),
+	}, nil
+}
+
+// Generate sends a prompt to the primary provider, and a copy to the shadow provider.
+func (s *Shadow) Generate(ctx context.Context, req models.GenerateRequest, fn models.GenerateResponseFunc) error {
+	s.mirror(ctx, func(ctx context.Context, p Provider) error {
+		return p.Generate(ctx, req, func(models.GenerateResponse) error { return nil })
+	})
+	start := time.Now()
+	err := s.options.Primary.Provider.Generate(ctx, req, fn)
+	s.metrics.track(s.options.Primary.Name, start, err)
+	return err
+}
+
+// Chat sends a chat conversation to the primary provider, and a copy to the shadow
+// provider.
+func (s *Shadow) Chat(ctx context.Context, req models.ChatRequest, fn models.ChatResponseFunc) error {
+	s.mirror(ctx, func(ctx context.Context, p Provider) error {
+		return p.Chat(ctx, req, func(models.ChatResponse) error { return nil })
+	})
+	start := time.Now()
+	err := s.options.Primary.Provider.Chat(ctx, req, fn)
+	s.metrics.track(s.options.Primary.Name, start, err)
+	return err
+}
+
+// Embed sends inputs to the primary provider, and a copy to the shadow provider.
+func (s *Shadow) Embed(ctx context.Context, req models.EmbedRequest) (*models.EmbedResponse, error) {
+	s.mirror(ctx, func(ctx context.Context, p Provider) error {
+		_, err := p.Embed(ctx, req)
+		return err
+	})
+	start := time.Now()
+	res, err := s.options.Primary.Provider.Embed(ctx, req)
+	s.metrics.track(s.options.Primary.Name, start, err)
+	return res, err
+}
+
+// ListModels returns the models of the primary provider.
+func (s *Shadow) ListModels(ctx context.Context) ([]models.ListModelResponse, error) {
+	return s.options.Primary.Provider.ListModels(ctx)
+}
+
+// Wait blocks until the mirrored requests in flight have finished.
+func (s *Shadow) Wait() {
+	s.wg.Wait()
+}
+
+// mirror calls call with the shadow provider in the background, if the request is
+// sampled and fewer than MaxInFlight mirrored requests are running. The mirrored
+// request outlives ctx, up to the timeout, so it is not cut short when the primary
+// response is done.
+func (s *Shadow) mirror(ctx context.Context, call func(ctx context.Context, p Provider) error) {
+	if s.options.SampleRate < 1 && rand.Float64() >= s.options.SampleRate {
+		return
+	}
+	select {
+	case s.inFlight <- struct{}{}:
+	default:
+		if s.options.Metrics != nil {
+			s.options.Metrics.TrackShadowDrop(s.options.Name)
+		}
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() { <-s.inFlight }()
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.options.Timeout)
+		defer cancel()
+		start := time.Now()
+		s.metrics.track(s.options.Shadow.Name, start, call(ctx, s.options.Shadow.Provider))
+	}()
+}
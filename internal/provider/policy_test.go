@@ -0,0 +1,247 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/h2co32/gollama/config"
+	"github.com/h2co32/gollama/internal/metrics"
+	"github.com/h2co32/gollama/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// chatReply sends a chat request to p and returns the content of the reply.
+func chatReply(p Provider) (string, error) {
+	var reply string
+	err := p.Chat(context.Background(), models.ChatRequest{Model: "llama3"}, func(res models.ChatResponse) error {
+		reply = res.Message.Content
+		return nil
+	})
+	return reply, err
+}
+
+// counterValue returns the sum of the counters named name in registry whose labels
+// include labels.
+func counterValue(t *testing.T, registry *prometheus.Registry, name string, labels map[string]string) float64 {
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	var sum float64
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	next:
+		for _, metric := range family.GetMetric() {
+			for _, pair := range metric.GetLabel() {
+				if value, ok := labels[pair.GetName()]; ok && value != pair.GetValue() {
+					continue next
+				}
+			}
+			sum += metric.GetCounter().GetValue()
+		}
+	}
+	return sum
+}
+
+func TestFailover(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	mp, err := metrics.NewMetricsProviderWithOptions(metrics.MetricsOptions{Registry: registry})
+	if err != nil {
+		t.Fatalf("Failed to create metrics provider: %v", err)
+	}
+
+	down := &fakeProvider{name: "down", err: errors.New("connection refused")}
+	slow := &fakeProvider{name: "slow", delay: time.Second}
+	backup := &fakeProvider{name: "backup"}
+	failover, err := NewFailover(FailoverOptions{
+		Name:      "resilient",
+		Providers: []Target{{Name: "down", Provider: down}, {Name: "slow", Provider: slow}, {Name: "backup", Provider: backup}},
+		Timeout:   50 * time.Millisecond,
+		Metrics:   mp,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create failover: %v", err)
+	}
+
+	reply, err := chatReply(failover)
+	if err != nil || reply != "backup" {
+		t.Errorf("Expected the backup provider to reply, got %q, %v", reply, err)
+	}
+	if down.calls.Load() != 1 || slow.calls.Load() != 1 {
+		t.Errorf("Expected one attempt per provider, got %d and %d", down.calls.Load(), slow.calls.Load())
+	}
+	if n := counterValue(t, registry, "provider_failovers_total", map[string]string{"policy": "resilient"}); n != 2 {
+		t.Errorf("Expected 2 failovers, got %v", n)
+	}
+	if n := counterValue(t, registry, "provider_requests_total", map[string]string{"provider": "backup", "status": "success"}); n != 1 {
+		t.Errorf("Expected 1 successful request to the backup provider, got %v", n)
+	}
+
+	res, err := failover.Embed(context.Background(), models.EmbedRequest{Model: "llama3"})
+	if err != nil || res.Model != "backup" {
+		t.Errorf("Expected the backup provider to embed, got %+v, %v", res, err)
+	}
+
+	// Client errors would fail the same way elsewhere, so they are returned as is
+	invalid := &fakeProvider{name: "invalid", err: &models.APIError{StatusCode: http.StatusBadRequest, Message: "invalid request"}}
+	failover, _ = NewFailover(FailoverOptions{Providers: []Target{{Name: "invalid", Provider: invalid}, {Name: "backup", Provider: backup}}})
+	var apiErr *models.APIError
+	if _, err := chatReply(failover); !errors.As(err, &apiErr) || backup.calls.Load() != 2 {
+		t.Errorf("Expected the client error without failing over, got %v", err)
+	}
+
+	// Unless another provider may not return them
+	limited := &fakeProvider{name: "limited", err: &models.APIError{StatusCode: http.StatusTooManyRequests}}
+	failover, _ = NewFailover(FailoverOptions{Providers: []Target{{Name: "limited", Provider: limited}, {Name: "backup", Provider: backup}}})
+	if reply, err := chatReply(failover); err != nil || reply != "backup" {
+		t.Errorf("Expected rate limiting to fail over, got %q, %v", reply, err)
+	}
+
+	failover, _ = NewFailover(FailoverOptions{
+		Providers: []Target{{Name: "down", Provider: down}, {Name: "slow", Provider: slow}},
+		Timeout:   20 * time.Millisecond,
+	})
+	if _, err := chatReply(failover); !errors.Is(err, ErrFirstResponseTimeout) {
+		t.Errorf("Expected the errors of every provider, got %v", err)
+	}
+	if _, err := NewFailover(FailoverOptions{}); err == nil {
+		t.Error("Expected error for a failover without providers")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	a, b, unused := &fakeProvider{name: "a"}, &fakeProvider{name: "b"}, &fakeProvider{name: "unused"}
+	split, err := NewSplit(SplitOptions{Providers: []Target{
+		{Name: "a", Provider: a, Weight: 75},
+		{Name: "b", Provider: b, Weight: 25},
+		{Name: "unused", Provider: unused},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to create split: %v", err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		if _, err := chatReply(split); err != nil {
+			t.Fatalf("Chat failed: %v", err)
+		}
+	}
+	if n := a.calls.Load(); n < 650 || n > 850 {
+		t.Errorf("Expected about 750 requests to a, got %d", n)
+	}
+	if a.calls.Load()+b.calls.Load() != 1000 || unused.calls.Load() != 0 {
+		t.Errorf("Expected every request to a or b, got %d, %d, and %d", a.calls.Load(), b.calls.Load(), unused.calls.Load())
+	}
+
+	if _, err := NewSplit(SplitOptions{Providers: []Target{{Name: "a", Provider: a}}}); err == nil {
+		t.Error("Expected error for a split without weights")
+	}
+	if _, err := NewSplit(SplitOptions{Providers: []Target{{Name: "a", Provider: a, Weight: -1}}}); err == nil {
+		t.Error("Expected error for a negative weight")
+	}
+}
+
+func TestShadow(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	mp, err := metrics.NewMetricsProviderWithOptions(metrics.MetricsOptions{Registry: registry})
+	if err != nil {
+		t.Fatalf("Failed to create metrics provider: %v", err)
+	}
+
+	primary := &fakeProvider{name: "primary"}
+	candidate := &fakeProvider{name: "candidate", err: errors.New("model not loaded")}
+	shadow, err := NewShadow(ShadowOptions{
+		Name:    "mirrored",
+		Primary: Target{Name: "primary", Provider: primary},
+		Shadow:  Target{Name: "candidate", Provider: candidate},
+		Metrics: mp,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create shadow: %v", err)
+	}
+
+	// The shadow's failures do not affect the response
+	reply, err := chatReply(shadow)
+	if err != nil || reply != "primary" {
+		t.Errorf("Expected the primary provider to reply, got %q, %v", reply, err)
+	}
+	shadow.Wait()
+	if candidate.calls.Load() != 1 {
+		t.Errorf("Expected the request to be mirrored, got %d requests", candidate.calls.Load())
+	}
+	if n := counterValue(t, registry, "provider_requests_total", map[string]string{"provider": "candidate", "status": "error"}); n != 1 {
+		t.Errorf("Expected 1 failed mirrored request, got %v", n)
+	}
+
+	// Mirrored requests beyond MaxInFlight are dropped
+	slow := &fakeProvider{name: "slow", delay: 100 * time.Millisecond}
+	shadow, _ = NewShadow(ShadowOptions{
+		Name:        "limited",
+		Primary:     Target{Name: "primary", Provider: primary},
+		Shadow:      Target{Name: "slow", Provider: slow},
+		MaxInFlight: 1,
+		Metrics:     mp,
+	})
+	for i := 0; i < 3; i++ {
+		chatReply(shadow)
+	}
+	shadow.Wait()
+	if slow.calls.Load() != 1 {
+		t.Errorf("Expected 1 mirrored request, got %d", slow.calls.Load())
+	}
+	if n := counterValue(t, registry, "provider_shadow_dropped_total", map[string]string{"policy": "limited"}); n != 2 {
+		t.Errorf("Expected 2 dropped requests, got %v", n)
+	}
+
+	if _, err := NewShadow(ShadowOptions{Primary: Target{Provider: primary}}); err == nil {
+		t.Error("Expected error for a shadow without a shadow provider")
+	}
+}
+
+func TestFromConfigPolicies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{
+		"providers": {
+			"down": {"type": "llamacpp", "url": "http://127.0.0.1:1"},
+			"backup": {"type": "llamacpp", "url": "http://127.0.0.1:2"},
+			"resilient": {"type": "failover", "providers": ["down", "backup"], "timeout": "5s"},
+			"compare": {"type": "split", "weights": {"resilient": 1, "backup": 1}},
+			"mirrored": {"type": "shadow", "primary": "compare", "shadow": "down", "sample_rate": 0.5}
+		},
+		"models": {"llama3": {"provider": "mirrored"}}
+	}`), 0644)
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	router, err := FromConfig(context.Background(), cfg, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+	p, name, err := router.Route("llama3")
+	if err != nil || name != "mirrored" {
+		t.Fatalf("Expected llama3 to route to mirrored, got %s, %v", name, err)
+	}
+	shadow, ok := p.(*Shadow)
+	if !ok {
+		t.Fatalf("Expected a shadow policy, got %T", p)
+	}
+	if shadow.options.SampleRate != 0.5 || shadow.options.Primary.Name != "compare" {
+		t.Errorf("Unexpected shadow options %+v", shadow.options)
+	}
+	split, ok := shadow.options.Primary.Provider.(*Split)
+	if !ok || len(split.targets) != 2 {
+		t.Fatalf("Expected a split between 2 providers, got %#v", shadow.options.Primary.Provider)
+	}
+	// Each provider is created once, whichever policies refer to it
+	if failover, ok := split.targets[1].Provider.(*Failover); !ok || failover.options.Providers[1].Provider != split.targets[0].Provider {
+		t.Errorf("Expected the failover to share the backup provider of the split, got %#v", split.targets[1].Provider)
+	}
+}
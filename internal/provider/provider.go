@@ -0,0 +1,130 @@
+// Package provider abstracts the model backends gollama can serve requests from, so one
+// gateway can front Ollama, OpenAI-compatible HTTP servers, and llama.cpp servers.
+//
+// Every Provider speaks the Ollama API types of the models package: requests are
+// translated to the backend's API and responses back, so callers handle one shape of
+// request, stream, and error regardless of where a model runs. A Router picks the
+// provider of each request by model name.
+//
+// Example usage:
+//
+//	router, err := provider.NewRouter(provider.RouterOptions{
+//		Providers: map[string]provider.Provider{
+//			"local":  provider.NewOllamaProvider(models.NewOllamaClient()),
+//			"openai": openaiProvider,
+//		},
+//		Models:  map[string]string{"gpt-4o-mini": "openai"},
+//		Default: "local",
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	err = router.Chat(ctx, models.ChatRequest{Model: "gpt-4o-mini", Messages: messages},
+//		func(res models.ChatResponse) error {
+//			fmt.Print(res.Message.Content)
+//			return nil
+//		})
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/h2co32/gollama/internal/models"
+)
+
+// Provider types.
+const (
+	TypeOllama   = "ollama"
+	TypeOpenAI   = "openai"
+	TypeLlamaCpp = "llamacpp"
+)
+
+// Provider generates, chats, and embeds with the models of a backend.
+type Provider interface {
+	// Generate sends a prompt to a model. fn is called for every streamed chunk, or
+	// once with the whole response when req.Stream is false.
+	Generate(ctx context.Context, req models.GenerateRequest, fn models.GenerateResponseFunc) error
+
+	// Chat sends a chat conversation to a model. fn is called for every streamed chunk,
+	// or once with the whole response when req.Stream is false.
+	Chat(ctx context.Context, req models.ChatRequest, fn models.ChatResponseFunc) error
+
+	// Embed returns an embedding of each input.
+	Embed(ctx context.Context, req models.EmbedRequest) (*models.EmbedResponse, error)
+
+	// ListModels returns the models the backend serves.
+	ListModels(ctx context.Context) ([]models.ListModelResponse, error)
+}
+
+// Options configures a Provider created by New.
+type Options struct {
+	// Type is the kind of backend: TypeOllama, TypeOpenAI, or TypeLlamaCpp.
+	Type string
+
+	// BaseURL is the address of the backend's API. For OpenAI-compatible servers it
+	// includes the version path, as in "https://api.openai.com/v1".
+	// Default: DefaultOllamaURL, DefaultOpenAIURL, or DefaultLlamaCppURL by type
+	BaseURL string
+
+	// APIKey is sent as a bearer token to OpenAI-compatible and llama.cpp servers.
+	// Optional.
+	APIKey string
+
+	// HTTPClient sends the requests to the backend.
+	// Default: a client with a 5 minute timeout
+	HTTPClient *http.Client
+}
+
+// Default base URLs by provider type.
+const (
+	DefaultOllamaURL   = models.DefaultOllamaURL
+	DefaultOpenAIURL   = "https://api.openai.com/v1"
+	DefaultLlamaCppURL = "http://localhost:8080"
+)
+
+// DefaultOptions returns the default provider options for a provider type.
+func DefaultOptions(providerType string) Options {
+	options := Options{
+		Type:       providerType,
+		HTTPClient: &http.Client{Timeout: 5 * time.Minute},
+	}
+	switch providerType {
+	case TypeOllama:
+		options.BaseURL = DefaultOllamaURL
+	case TypeOpenAI:
+		options.BaseURL = DefaultOpenAIURL
+	case TypeLlamaCpp:
+		options.BaseURL = DefaultLlamaCppURL
+	}
+	return options
+}
+
+// New creates a Provider of options.Type. Zero option values fall back to the defaults
+// of the type.
+func New(options Options) (Provider, error) {
+	defaults := DefaultOptions(options.Type)
+	if options.BaseURL == "" {
+		options.BaseURL = defaults.BaseURL
+	}
+	if options.HTTPClient == nil {
+		options.HTTPClient = defaults.HTTPClient
+	}
+
+	switch options.Type {
+	case TypeOllama:
+		return NewOllamaProvider(models.NewOllamaClientWithOptions(models.OllamaClientOptions{
+			BaseURL:    options.BaseURL,
+			HTTPClient: options.HTTPClient,
+		})), nil
+	case TypeOpenAI:
+		return NewOpenAIProvider(options), nil
+	case TypeLlamaCpp:
+		return NewLlamaCppProvider(options), nil
+	default:
+		return nil, fmt.Errorf("unsupported provider type: %s", options.Type)
+	}
+}
@@ -0,0 +1,348 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/h2co32/gollama/config"
+	"github.com/h2co32/gollama/internal/models"
+	"github.com/h2co32/gollama/pkg/openai"
+	"github.com/h2co32/gollama/pkg/secrets"
+)
+
+// newOpenAIServer starts a fake OpenAI-compatible server under /v1.
+func newOpenAIServer(t *testing.T, requests *[]openai.ChatCompletionRequest) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error": {"message": "invalid api key", "type": "invalid_request_error"}}`)
+			return
+		}
+		var req openai.ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if requests != nil {
+			*requests = append(*requests, req)
+		}
+		if !req.Stream {
+			json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+				Model:   req.Model,
+				Created: 1700000000,
+				Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Role: "assistant", Content: "Hello!"}, FinishReason: "length"}},
+				Usage:   openai.Usage{PromptTokens: 5, CompletionTokens: 2},
+			})
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"model\":\"gpt\",\"choices\":[{\"delta\":{\"role\":\"assistant\",\"content\":\"Hel\"}}]}\n\n")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprint(w, "data: {\"model\":\"gpt\",\"choices\":[{\"delta\":{\"content\":\"lo\"},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: {\"model\":\"gpt\",\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":2,\"total_tokens\":7}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+	mux.HandleFunc("/v1/embeddings", func(w http.ResponseWriter, r *http.Request) {
+		var req openai.EmbeddingRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		res := openai.EmbeddingResponse{Model: req.Model, Usage: openai.Usage{PromptTokens: 3}}
+		// Out of order, to check the embeddings are placed by index
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			res.Data = append(res.Data, openai.Embedding{Embedding: []float64{float64(i), 0.5}, Index: i})
+		}
+		json.NewEncoder(w).Encode(res)
+	})
+	mux.HandleFunc("/v1/models", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"object":"list","data":[{"id":"gpt-4o-mini","created":1700000000},{"id":"text-embedding-3-small"}]}`)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOpenAIProviderChat(t *testing.T) {
+	var requests []openai.ChatCompletionRequest
+	server := newOpenAIServer(t, &requests)
+	p := NewOpenAIProvider(Options{BaseURL: server.URL + "/v1", APIKey: "secret"})
+
+	var chunks []models.ChatResponse
+	err := p.Chat(context.Background(), models.ChatRequest{
+		Model:    "gpt",
+		Messages: []models.Message{{Role: "user", Content: "Hi"}},
+		Format:   "json",
+		Options:  map[string]interface{}{"temperature": 0.2, "num_predict": 64, "stop": []interface{}{"\n"}, "seed": 7},
+	}, func(res models.ChatResponse) error {
+		chunks = append(chunks, res)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to chat: %v", err)
+	}
+
+	if len(chunks) != 3 || chunks[0].Message.Content != "Hel" || chunks[1].Message.Content != "lo" {
+		t.Fatalf("Unexpected chunks %+v", chunks)
+	}
+	final := chunks[2]
+	if !final.Done || final.DoneReason != "stop" || final.PromptEvalCount != 5 || final.EvalCount != 2 {
+		t.Errorf("Unexpected final chunk %+v", final)
+	}
+
+	req := requests[0]
+	if !req.Stream || req.StreamOptions == nil || !req.StreamOptions.IncludeUsage {
+		t.Errorf("Expected a streamed request with usage, got %+v", req)
+	}
+	if *req.Temperature != 0.2 || req.MaxTokens != 64 || *req.Seed != 7 || fmt.Sprint(req.Stop) != "[\n]" {
+		t.Errorf("Unexpected options %+v", req)
+	}
+	if req.ResponseFormat == nil || req.ResponseFormat.Type != "json_object" {
+		t.Errorf("Expected a JSON object response format, got %+v", req.ResponseFormat)
+	}
+
+	// Generate is sent as a chat completion, here without streaming
+	var generated models.GenerateResponse
+	err = p.Generate(context.Background(), models.GenerateRequest{Model: "gpt", System: "Be brief.", Prompt: "Hi", Stream: new(bool)}, func(res models.GenerateResponse) error {
+		generated = res
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate: %v", err)
+	}
+	if generated.Response != "Hello!" || generated.DoneReason != "length" || generated.EvalCount != 2 {
+		t.Errorf("Unexpected generate response %+v", generated)
+	}
+	if messages := requests[1].Messages; len(messages) != 2 || messages[0].Role != "system" || messages[1].Content != "Hi" {
+		t.Errorf("Unexpected generate messages %+v", messages)
+	}
+}
+
+func TestOpenAIProviderEmbedListAndErrors(t *testing.T) {
+	server := newOpenAIServer(t, nil)
+	p := NewOpenAIProvider(Options{BaseURL: server.URL + "/v1", APIKey: "secret"})
+	ctx := context.Background()
+
+	res, err := p.Embed(ctx, models.EmbedRequest{Model: "embed", Input: []string{"a", "b"}})
+	if err != nil {
+		t.Fatalf("Failed to embed: %v", err)
+	}
+	if len(res.Embeddings) != 2 || res.Embeddings[1][0] != 1 || res.PromptEvalCount != 3 {
+		t.Errorf("Unexpected embeddings %+v", res)
+	}
+
+	list, err := p.ListModels(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list models: %v", err)
+	}
+	if len(list) != 2 || list[0].Name != "gpt-4o-mini" || list[0].ModifiedAt.Unix() != 1700000000 {
+		t.Errorf("Unexpected models %+v", list)
+	}
+
+	unauthorized := NewOpenAIProvider(Options{BaseURL: server.URL + "/v1"})
+	err = unauthorized.Chat(ctx, models.ChatRequest{Model: "gpt"}, func(models.ChatResponse) error { return nil })
+	var apiErr *models.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Message != "invalid api key" {
+		t.Errorf("Expected a 401 API error, got %v", err)
+	}
+}
+
+func TestLlamaCppProviderRawGenerate(t *testing.T) {
+	var completion completionRequest
+	mux := http.NewServeMux()
+	mux.HandleFunc("/completion", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&completion)
+		fmt.Fprint(w, "data: {\"content\":\"Once\",\"stop\":false}\n\n")
+		fmt.Fprint(w, "data: {\"content\":\" upon\",\"stop\":false}\n\n")
+		fmt.Fprint(w, "data: {\"content\":\"\",\"stop\":true,\"stop_type\":\"limit\",\"tokens_evaluated\":4,\"tokens_predicted\":2,\"timings\":{\"prompt_ms\":10,\"predicted_ms\":20}}\n\n")
+	})
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: "templated"}}}})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	p := NewLlamaCppProvider(Options{BaseURL: server.URL})
+	var text string
+	var final models.GenerateResponse
+	err := p.Generate(context.Background(), models.GenerateRequest{
+		Model:   "local",
+		Prompt:  "<s>Tell a story",
+		Raw:     true,
+		Options: map[string]interface{}{"num_predict": 2, "top_k": 40},
+	}, func(res models.GenerateResponse) error {
+		text += res.Response
+		if res.Done {
+			final = res
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate: %v", err)
+	}
+	if text != "Once upon" || final.DoneReason != "length" || final.EvalCount != 2 || final.EvalDuration.Milliseconds() != 20 {
+		t.Errorf("Unexpected response %q, %+v", text, final)
+	}
+	if completion.Prompt != "<s>Tell a story" || *completion.NPredict != 2 || *completion.TopK != 40 || !completion.Stream {
+		t.Errorf("Unexpected completion request %+v", completion)
+	}
+
+	// Requests that are not raw use the chat template
+	err = p.Generate(context.Background(), models.GenerateRequest{Model: "local", Prompt: "Hi", Stream: new(bool)}, func(res models.GenerateResponse) error {
+		text = res.Response
+		return nil
+	})
+	if err != nil || text != "templated" {
+		t.Errorf("Expected the chat completion reply, got %q, %v", text, err)
+	}
+}
+
+// fakeProvider serves a fixed list of models and replies with its name, after delay,
+// or fails with err. It counts the requests it receives.
+type fakeProvider struct {
+	name   string
+	models []string
+	err    error
+	delay  time.Duration
+	calls  atomic.Int32
+}
+
+// serve counts a request and waits for the delay, returning the error to reply with.
+func (p *fakeProvider) serve(ctx context.Context) error {
+	p.calls.Add(1)
+	select {
+	case <-time.After(p.delay):
+		return p.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *fakeProvider) Generate(ctx context.Context, req models.GenerateRequest, fn models.GenerateResponseFunc) error {
+	if err := p.serve(ctx); err != nil {
+		return err
+	}
+	return fn(models.GenerateResponse{Model: req.Model, Response: p.name, Done: true})
+}
+
+func (p *fakeProvider) Chat(ctx context.Context, req models.ChatRequest, fn models.ChatResponseFunc) error {
+	if err := p.serve(ctx); err != nil {
+		return err
+	}
+	return fn(models.ChatResponse{Model: req.Model, Message: models.Message{Role: "assistant", Content: p.name}, Done: true})
+}
+
+func (p *fakeProvider) Embed(ctx context.Context, req models.EmbedRequest) (*models.EmbedResponse, error) {
+	if err := p.serve(ctx); err != nil {
+		return nil, err
+	}
+	return &models.EmbedResponse{Model: p.name}, nil
+}
+
+func (p *fakeProvider) ListModels(ctx context.Context) ([]models.ListModelResponse, error) {
+	list := make([]models.ListModelResponse, len(p.models))
+	for i, name := range p.models {
+		list[i] = models.ListModelResponse{Name: name}
+	}
+	return list, nil
+}
+
+func TestRouter(t *testing.T) {
+	router, err := NewRouter(RouterOptions{
+		Providers: map[string]Provider{
+			"local":  &fakeProvider{name: "local", models: []string{"llama3:8b", "mistral"}},
+			"remote": &fakeProvider{name: "remote", models: []string{"gpt-4o", "mistral"}},
+		},
+		Models:  map[string]string{"gpt-4o": "remote", "llama3": "local", "llama3:70b": "remote"},
+		Default: "local",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+
+	tests := map[string]string{
+		"gpt-4o":     "remote",
+		"llama3:8b":  "local",
+		"llama3:70b": "remote",
+		"mistral":    "local",
+	}
+	for model, expected := range tests {
+		if _, name, err := router.Route(model); err != nil || name != expected {
+			t.Errorf("Expected %s to route to %s, got %s, %v", model, expected, name, err)
+		}
+	}
+
+	var reply string
+	router.Chat(context.Background(), models.ChatRequest{Model: "gpt-4o"}, func(res models.ChatResponse) error {
+		reply = res.Message.Content
+		return nil
+	})
+	if reply != "remote" {
+		t.Errorf("Expected the remote provider to reply, got %q", reply)
+	}
+
+	list, err := router.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to list models: %v", err)
+	}
+	var names []string
+	for _, model := range list {
+		names = append(names, model.Name)
+	}
+	if fmt.Sprint(names) != "[llama3:8b mistral gpt-4o]" {
+		t.Errorf("Expected each model once, from the provider it routes to, got %v", names)
+	}
+
+	strict, _ := NewRouter(RouterOptions{Providers: map[string]Provider{"local": &fakeProvider{}}})
+	if _, _, err := strict.Route("llama3"); !errors.Is(err, ErrNoProvider) {
+		t.Errorf("Expected ErrNoProvider without a default, got %v", err)
+	}
+	if _, err := NewRouter(RouterOptions{Providers: map[string]Provider{"local": &fakeProvider{}}, Models: map[string]string{"x": "missing"}}); err == nil {
+		t.Error("Expected error for a model assigned to a missing provider")
+	}
+	if _, err := New(Options{Type: "bedrock"}); err == nil {
+		t.Error("Expected error for an unsupported provider type")
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	server := newOpenAIServer(t, nil)
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{
+		"secrets": {"openai": "static:key"},
+		"providers": {
+			"local": {"type": "llamacpp", "url": "http://localhost:8080"},
+			"openai": {"type": "openai", "url": "`+server.URL+`/v1", "api_key": "openai"}
+		},
+		"models": {"gpt": {"provider": "openai"}},
+		"default_provider": "local"
+	}`), 0644)
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	provider := secrets.SchemeProvider{"static": secrets.StaticProvider{"key": "secret"}}
+	router, err := FromConfig(context.Background(), cfg, provider, nil)
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+	if _, name, _ := router.Route("llama3"); name != "local" {
+		t.Errorf("Expected llama3 to route to local, got %s", name)
+	}
+
+	// The API key was resolved, so the fake server accepts the request
+	var reply string
+	err = router.Chat(context.Background(), models.ChatRequest{Model: "gpt", Stream: new(bool)}, func(res models.ChatResponse) error {
+		reply = res.Message.Content
+		return nil
+	})
+	if err != nil || !strings.HasPrefix(reply, "Hello") {
+		t.Errorf("Expected a reply from the OpenAI provider, got %q, %v", reply, err)
+	}
+}
@@ -0,0 +1,223 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/h2co32/gollama/config"
+	"github.com/h2co32/gollama/internal/metrics"
+	"github.com/h2co32/gollama/internal/models"
+	"github.com/h2co32/gollama/pkg/secrets"
+)
+
+// ErrNoProvider is returned for models that no provider is assigned to when the router
+// has no default provider.
+var ErrNoProvider = errors.New("no provider for model")
+
+// RouterOptions configures a Router.
+type RouterOptions struct {
+	// Providers are the providers by name. Required.
+	Providers map[string]Provider
+
+	// Models assigns models to providers by name. A model name without a tag, such as
+	// "llama3", also covers its tagged names, such as "llama3:8b". Optional.
+	Models map[string]string
+
+	// Default is the name of the provider serving models not in Models. Optional. By
+	// default those models fail with ErrNoProvider.
+	Default string
+}
+
+// Router is a Provider that sends each request to the provider of its model. It is safe
+// for concurrent use.
+type Router struct {
+	providers map[string]Provider
+	models    map[string]string
+	fallback  string
+}
+
+// NewRouter creates a Router. It returns an error if a model or the default refers to a
+// provider that is missing.
+func NewRouter(options RouterOptions) (*Router, error) {
+	if len(options.Providers) == 0 {
+		return nil, errors.New("at least one provider is required")
+	}
+	if _, ok := options.Providers[options.Default]; options.Default != "" && !ok {
+		return nil, fmt.Errorf("default provider %s not found", options.Default)
+	}
+	modelProviders := make(map[string]string, len(options.Models))
+	for model, name := range options.Models {
+		if _, ok := options.Providers[name]; !ok {
+			return nil, fmt.Errorf("provider %s of model %s not found", name, model)
+		}
+		modelProviders[model] = name
+	}
+
+	providers := make(map[string]Provider, len(options.Providers))
+	for name, p := range options.Providers {
+		providers[name] = p
+	}
+	return &Router{providers: providers, models: modelProviders, fallback: options.Default}, nil
+}
+
+// FromConfig creates a Router from the providers, per-model provider assignments, and
+// default provider of cfg's active profile. API keys are resolved with secretProvider.
+// Routing policies record their requests with metricsProvider, if it is not nil, under
+// their provider names.
+func FromConfig(ctx context.Context, cfg *config.Config, secretProvider secrets.SecretProvider, metricsProvider *metrics.MetricsProvider) (*Router, error) {
+	configs := cfg.Providers()
+	providers := make(map[string]Provider, len(configs))
+
+	// Policies are built after the providers they refer to, which Load checked exist
+	// and do not lead back to them
+	var build func(name string) (Provider, error)
+	target := func(name string, weight int) (Target, error) {
+		p, err := build(name)
+		return Target{Name: name, Provider: p, Weight: weight}, err
+	}
+	build = func(name string) (Provider, error) {
+		if p, ok := providers[name]; ok {
+			return p, nil
+		}
+		pc, ok := configs[name]
+		if !ok {
+			return nil, fmt.Errorf("provider %s not found", name)
+		}
+
+		var p Provider
+		var err error
+		switch pc.Type {
+		case config.ProviderTypeFailover:
+			options := FailoverOptions{Name: name, Timeout: pc.Timeout, Metrics: metricsProvider}
+			for _, ref := range pc.Providers {
+				t, err := target(ref, 0)
+				if err != nil {
+					return nil, err
+				}
+				options.Providers = append(options.Providers, t)
+			}
+			p, err = NewFailover(options)
+		case config.ProviderTypeSplit:
+			options := SplitOptions{Name: name, Metrics: metricsProvider}
+			refs := make([]string, 0, len(pc.Weights))
+			for ref := range pc.Weights {
+				refs = append(refs, ref)
+			}
+			sort.Strings(refs)
+			for _, ref := range refs {
+				t, err := target(ref, pc.Weights[ref])
+				if err != nil {
+					return nil, err
+				}
+				options.Providers = append(options.Providers, t)
+			}
+			p, err = NewSplit(options)
+		case config.ProviderTypeShadow:
+			options := ShadowOptions{Name: name, SampleRate: pc.SampleRate, Metrics: metricsProvider}
+			if options.Primary, err = target(pc.Primary, 0); err != nil {
+				return nil, err
+			}
+			if options.Shadow, err = target(pc.Shadow, 0); err != nil {
+				return nil, err
+			}
+			p, err = NewShadow(options)
+		default:
+			options := Options{Type: pc.Type, BaseURL: pc.URL}
+			if pc.APIKey != "" {
+				key, err := cfg.Secret(ctx, secretProvider, pc.APIKey)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read API key of provider %s: %w", name, err)
+				}
+				options.APIKey = key
+			}
+			p, err = New(options)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("provider %s: %w", name, err)
+		}
+		providers[name] = p
+		return p, nil
+	}
+	for name := range configs {
+		if _, err := build(name); err != nil {
+			return nil, err
+		}
+	}
+	return NewRouter(RouterOptions{
+		Providers: providers,
+		Models:    cfg.ModelProviders(),
+		Default:   cfg.DefaultProvider(),
+	})
+}
+
+// Route returns the provider of model and its name. An assignment of the exact model
+// name takes precedence over one of the name without its tag.
+func (r *Router) Route(model string) (Provider, string, error) {
+	name, ok := r.models[model]
+	if !ok {
+		if i := strings.LastIndex(model, ":"); i >= 0 {
+			name, ok = r.models[model[:i]]
+		}
+	}
+	if !ok {
+		name = r.fallback
+	}
+	if name == "" {
+		return nil, "", fmt.Errorf("%w %s", ErrNoProvider, model)
+	}
+	return r.providers[name], name, nil
+}
+
+// Generate sends a prompt to the provider of req.Model.
+func (r *Router) Generate(ctx context.Context, req models.GenerateRequest, fn models.GenerateResponseFunc) error {
+	p, _, err := r.Route(req.Model)
+	if err != nil {
+		return err
+	}
+	return p.Generate(ctx, req, fn)
+}
+
+// Chat sends a chat conversation to the provider of req.Model.
+func (r *Router) Chat(ctx context.Context, req models.ChatRequest, fn models.ChatResponseFunc) error {
+	p, _, err := r.Route(req.Model)
+	if err != nil {
+		return err
+	}
+	return p.Chat(ctx, req, fn)
+}
+
+// Embed sends inputs to the provider of req.Model.
+func (r *Router) Embed(ctx context.Context, req models.EmbedRequest) (*models.EmbedResponse, error) {
+	p, _, err := r.Route(req.Model)
+	if err != nil {
+		return nil, err
+	}
+	return p.Embed(ctx, req)
+}
+
+// ListModels returns the models of every provider that routes them to it, in order of
+// provider name.
+func (r *Router) ListModels(ctx context.Context) ([]models.ListModelResponse, error) {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var list []models.ListModelResponse
+	for _, name := range names {
+		providerModels, err := r.providers[name].ListModels(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list models of provider %s: %w", name, err)
+		}
+		for _, model := range providerModels {
+			if _, routed, err := r.Route(model.Name); err == nil && routed == name {
+				list = append(list, model)
+			}
+		}
+	}
+	return list, nil
+}
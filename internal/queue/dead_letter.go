@@ -0,0 +1,171 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrNotDeadLettered is returned by Redrive when no job with the given ID is in the
+// dead-letter queue.
+var ErrNotDeadLettered = errors.New("job not in dead-letter queue")
+
+// DeadLetter is a job that failed after exhausting its retries. Jobs that were
+// canceled, by CancelJob or by Stop, are not dead-lettered.
+type DeadLetter struct {
+	// ID is the job's ID.
+	ID int
+
+	// Priority is the job's class.
+	Priority Priority
+
+	// Type is the job's type.
+	Type string
+
+	// Payload is the job's data if it was added to a TypedJobQueue, or nil.
+	Payload any
+
+	// Err is the error of the job's last attempt.
+	Err error
+
+	// Attempts is the number of times the job was run.
+	Attempts int
+
+	// FailedAt is when the last attempt failed.
+	FailedAt time.Time
+
+	job Job
+}
+
+// deadLetterRecord is the exported form of a DeadLetter.
+type deadLetterRecord struct {
+	ID       int       `json:"id"`
+	Priority string    `json:"priority"`
+	Type     string    `json:"type"`
+	Error    string    `json:"error"`
+	Attempts int       `json:"attempts"`
+	FailedAt time.Time `json:"failed_at"`
+	Payload  any       `json:"payload,omitempty"`
+}
+
+// deadLetter adds a failed job to the dead-letter queue, replacing an earlier entry
+// with the same ID and dropping the oldest entry if the queue is full.
+func (jq *JobQueue) deadLetter(job Job, attempts int, err error) {
+	if jq.options.MaxDeadLetters < 0 {
+		return
+	}
+
+	jq.deadLetterMutex.Lock()
+	defer jq.deadLetterMutex.Unlock()
+
+	if i := jq.findDeadLetter(job.ID); i >= 0 {
+		jq.deadLetters = append(jq.deadLetters[:i], jq.deadLetters[i+1:]...)
+	}
+	if len(jq.deadLetters) >= jq.options.MaxDeadLetters {
+		jq.deadLetters = append(jq.deadLetters[:0], jq.deadLetters[len(jq.deadLetters)-jq.options.MaxDeadLetters+1:]...)
+	}
+	jq.deadLetters = append(jq.deadLetters, DeadLetter{
+		ID:       job.ID,
+		Priority: job.Priority,
+		Type:     job.Type,
+		Payload:  job.Payload,
+		Err:      err,
+		Attempts: attempts,
+		FailedAt: time.Now(),
+		job:      job,
+	})
+}
+
+// findDeadLetter returns the index of the dead-lettered job with the given ID, or -1.
+// This method is not thread-safe and should be called with the dead-letter mutex locked.
+func (jq *JobQueue) findDeadLetter(id int) int {
+	for i, letter := range jq.deadLetters {
+		if letter.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// DeadLetters returns the jobs in the dead-letter queue, oldest first
+func (jq *JobQueue) DeadLetters() []DeadLetter {
+	jq.deadLetterMutex.Lock()
+	defer jq.deadLetterMutex.Unlock()
+
+	letters := make([]DeadLetter, len(jq.deadLetters))
+	copy(letters, jq.deadLetters)
+	return letters
+}
+
+// Redrive removes the job with the given ID from the dead-letter queue and adds it to
+// the queue again with its original task and options, linked to the span that first
+// added it. It returns ErrNotDeadLettered if there is no such job, and ErrStopped,
+// leaving the job dead-lettered, once Stop has been called.
+func (jq *JobQueue) Redrive(id int) (*Handle, error) {
+	jq.deadLetterMutex.Lock()
+	defer jq.deadLetterMutex.Unlock()
+
+	i := jq.findDeadLetter(id)
+	if i < 0 {
+		return nil, ErrNotDeadLettered
+	}
+	job := jq.deadLetters[i].job
+	ctx := trace.ContextWithSpanContext(context.Background(), job.link)
+	handle, err := jq.addJob(ctx, job.ID, job.Task, job.Payload, JobOptions{
+		Retries:    job.Retries,
+		Priority:   job.Priority,
+		Timeout:    job.Timeout,
+		OnComplete: job.onComplete,
+		Type:       job.Type,
+	})
+	if err != nil {
+		return nil, err
+	}
+	jq.deadLetters = append(jq.deadLetters[:i], jq.deadLetters[i+1:]...)
+	return handle, nil
+}
+
+// RedriveAll adds every job in the dead-letter queue to the queue again, oldest first,
+// and returns their handles. It stops at the first error.
+func (jq *JobQueue) RedriveAll() ([]*Handle, error) {
+	var handles []*Handle
+	for _, letter := range jq.DeadLetters() {
+		handle, err := jq.Redrive(letter.ID)
+		if errors.Is(err, ErrNotDeadLettered) {
+			// Redriven concurrently
+			continue
+		}
+		if err != nil {
+			return handles, err
+		}
+		handles = append(handles, handle)
+	}
+	return handles, nil
+}
+
+// ExportDeadLetters writes the jobs in the dead-letter queue to w as JSON lines, oldest
+// first, with their ID, priority, type, error, attempts, failure time, and payload if
+// they have one. TypedJobQueue.Restore reads this format.
+func (jq *JobQueue) ExportDeadLetters(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	for _, letter := range jq.DeadLetters() {
+		record := deadLetterRecord{
+			ID:       letter.ID,
+			Priority: letter.Priority.String(),
+			Type:     letter.Type,
+			Error:    letter.Err.Error(),
+			Attempts: letter.Attempts,
+			FailedAt: letter.FailedAt,
+			Payload:  letter.Payload,
+		}
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("failed to export dead letter %d: %w", letter.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fastRetry returns options that retry without a noticeable backoff
+func fastRetry() Options {
+	options := DefaultOptions()
+	options.Retry.InitialBackoff = time.Millisecond
+	options.Retry.MaxBackoff = time.Millisecond
+	return options
+}
+
+func TestDeadLetters(t *testing.T) {
+	jq := NewJobQueueWithOptions(fastRetry())
+	jq.StartWorkers()
+
+	failure := errors.New("model not found")
+	jq.AddJobWithOptions(1, func(ctx context.Context) error { return failure }, JobOptions{Retries: 3, Priority: PriorityHigh})
+	jq.AddJob(2, func(ctx context.Context) error { return nil }, 1)
+	jq.AddJob(3, func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() }, 1)
+	time.Sleep(10 * time.Millisecond)
+	jq.CancelJob(3)
+	jq.Wait()
+
+	// Only the job that exhausted its retries is dead-lettered
+	letters := jq.DeadLetters()
+	if len(letters) != 1 {
+		t.Fatalf("Expected 1 dead letter, got %d", len(letters))
+	}
+	letter := letters[0]
+	if letter.ID != 1 || letter.Priority != PriorityHigh {
+		t.Errorf("Expected job 1 with high priority, got job %d with %s priority", letter.ID, letter.Priority)
+	}
+	if letter.Err != failure {
+		t.Errorf("Expected %v, got %v", failure, letter.Err)
+	}
+	if letter.Attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", letter.Attempts)
+	}
+	if letter.FailedAt.IsZero() {
+		t.Error("Expected the failure time to be set")
+	}
+}
+
+func TestDeadLettersLimit(t *testing.T) {
+	options := fastRetry()
+	options.MaxDeadLetters = 2
+	jq := NewJobQueueWithOptions(options)
+	jq.StartWorkers()
+
+	for i := 1; i <= 3; i++ {
+		jq.AddJob(i, func(ctx context.Context) error { return errors.New("failed") }, 1)
+		jq.Wait()
+	}
+
+	letters := jq.DeadLetters()
+	if len(letters) != 2 {
+		t.Fatalf("Expected 2 dead letters, got %d", len(letters))
+	}
+	if letters[0].ID != 2 || letters[1].ID != 3 {
+		t.Errorf("Expected the oldest dead letter to be dropped, got jobs %d and %d", letters[0].ID, letters[1].ID)
+	}
+
+	// A negative limit disables the dead-letter queue
+	options.MaxDeadLetters = -1
+	jq = NewJobQueueWithOptions(options)
+	jq.StartWorkers()
+	jq.AddJob(1, func(ctx context.Context) error { return errors.New("failed") }, 1)
+	jq.Wait()
+	if len(jq.DeadLetters()) != 0 {
+		t.Errorf("Expected no dead letters, got %d", len(jq.DeadLetters()))
+	}
+}
+
+func TestRedrive(t *testing.T) {
+	jq := NewJobQueueWithOptions(fastRetry())
+	jq.StartWorkers()
+
+	healthy := false
+	completed := 0
+	task := func(ctx context.Context) error {
+		if !healthy {
+			return errors.New("backend down")
+		}
+		return nil
+	}
+	onComplete := func(id int, err error) { completed++ }
+	jq.AddJobWithOptions(1, task, JobOptions{Retries: 2, OnComplete: onComplete})
+	jq.AddJobWithOptions(2, task, JobOptions{Retries: 2, OnComplete: onComplete})
+	jq.Wait()
+
+	if _, err := jq.Redrive(3); err != ErrNotDeadLettered {
+		t.Errorf("Expected ErrNotDeadLettered, got %v", err)
+	}
+
+	healthy = true
+	handle, err := jq.Redrive(1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := handle.Wait(context.Background()); err != nil {
+		t.Errorf("Expected the redriven job to succeed, got %v", err)
+	}
+	jq.Wait()
+	if completed != 3 {
+		t.Errorf("Expected the redriven job to keep its callback, got %d calls", completed)
+	}
+
+	handles, err := jq.RedriveAll()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(handles) != 1 || handles[0].ID() != 2 {
+		t.Fatalf("Expected job 2 to be redriven, got %d handles", len(handles))
+	}
+	jq.Wait()
+	if len(jq.DeadLetters()) != 0 {
+		t.Errorf("Expected the dead-letter queue to be empty, got %d", len(jq.DeadLetters()))
+	}
+	if err := jq.Results()[2]; err != nil {
+		t.Errorf("Expected job 2 to succeed, got %v", err)
+	}
+
+	// Redriving a stopped queue leaves the job dead-lettered
+	healthy = false
+	jq.AddJob(4, task, 1)
+	jq.Stop(context.Background())
+	if _, err := jq.Redrive(4); err != ErrStopped {
+		t.Errorf("Expected ErrStopped, got %v", err)
+	}
+	if len(jq.DeadLetters()) != 1 {
+		t.Errorf("Expected job 4 to stay dead-lettered, got %d dead letters", len(jq.DeadLetters()))
+	}
+}
+
+func TestExportDeadLetters(t *testing.T) {
+	jq := NewJobQueueWithOptions(fastRetry())
+	jq.StartWorkers()
+	jq.AddJobWithOptions(1, func(ctx context.Context) error { return errors.New("out of memory") }, JobOptions{Priority: PriorityLow})
+	jq.AddJob(2, func(ctx context.Context) error { return errors.New("timeout") }, 2)
+	jq.Wait()
+
+	var buf bytes.Buffer
+	if err := jq.ExportDeadLetters(&buf); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	decoder := json.NewDecoder(&buf)
+	var records []deadLetterRecord
+	for decoder.More() {
+		var record deadLetterRecord
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatalf("Expected valid JSON lines, got %v", err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	byID := map[int]deadLetterRecord{records[0].ID: records[0], records[1].ID: records[1]}
+	if r := byID[1]; r.Priority != "low" || r.Error != "out of memory" || r.Attempts != 1 {
+		t.Errorf("Unexpected record for job 1: %+v", r)
+	}
+	if r := byID[2]; r.Priority != "normal" || r.Error != "timeout" || r.Attempts != 2 {
+		t.Errorf("Unexpected record for job 2: %+v", r)
+	}
+}
@@ -1,85 +1,761 @@
-package queue
-
-import (
-	"fmt"
-	"sync"
-	"time"
-)
-
-// Job represents a unit of work to be processed by the job queue
-type Job struct {
-	ID      int
-	Task    func() error
-	Retries int
-}
-
-// JobQueue manages background job processing with a worker pool and rate limiting
-type JobQueue struct {
-	jobs         chan Job
-	workerCount  int
-	rateLimit    time.Duration
-	wg           sync.WaitGroup
-	results      map[int]error
-	resultsMutex sync.Mutex
-}
-
-// NewJobQueue initializes a new JobQueue with the specified number of workers and rate limit
-func NewJobQueue(workerCount int, rateLimit time.Duration) *JobQueue {
-	return &JobQueue{
-		jobs:        make(chan Job),
-		workerCount: workerCount,
-		rateLimit:   rateLimit,
-		results:     make(map[int]error),
-	}
-}
-
-// StartWorkers starts the worker pool to process jobs asynchronously
-func (jq *JobQueue) StartWorkers() {
-	for i := 0; i < jq.workerCount; i++ {
-		go jq.worker(i)
-	}
-}
-
-// worker is a function that processes jobs from the queue with rate limiting
-func (jq *JobQueue) worker(workerID int) {
-	for job := range jq.jobs {
-		fmt.Printf("Worker %d processing job %d\n", workerID, job.ID)
-
-		retryCount := job.Retries
-		var err error
-		for attempt := 1; attempt <= retryCount; attempt++ {
-			err = job.Task()
-			if err == nil {
-				break
-			}
-			fmt.Printf("Job %d failed (attempt %d/%d): %v\n", job.ID, attempt, retryCount, err)
-			time.Sleep(500 * time.Millisecond) // Backoff between retries
-		}
-
-		jq.resultsMutex.Lock()
-		jq.results[job.ID] = err
-		jq.resultsMutex.Unlock()
-
-		time.Sleep(jq.rateLimit) // Rate limiting
-		jq.wg.Done()
-	}
-}
-
-// AddJob adds a job to the job queue for processing
-func (jq *JobQueue) AddJob(id int, task func() error, retries int) {
-	jq.wg.Add(1)
-	jq.jobs <- Job{ID: id, Task: task, Retries: retries}
-}
-
-// Wait blocks until all jobs have been processed
-func (jq *JobQueue) Wait() {
-	jq.wg.Wait()
-	close(jq.jobs)
-}
-
-// GetResults returns the job results after all jobs are processed
-func (jq *JobQueue) GetResults() map[int]error {
-	jq.resultsMutex.Lock()
-	defer jq.resultsMutex.Unlock()
-	return jq.results
-}
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/h2co32/gollama/internal/metrics"
+	"github.com/h2co32/gollama/pkg/logging"
+	"github.com/h2co32/gollama/pkg/retry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation name of the default tracer.
+const tracerName = "github.com/h2co32/gollama/internal/queue"
+
+// defaultJobType is the type of jobs added without one.
+const defaultJobType = "default"
+
+// Priority is the class of a job. Workers take jobs of a higher class first.
+type Priority int
+
+// Priority classes, from lowest to highest. The zero value is PriorityNormal.
+const (
+	// PriorityLow is for background work such as batch embeddings
+	PriorityLow Priority = iota - 1
+
+	// PriorityNormal is the default class
+	PriorityNormal
+
+	// PriorityHigh is for latency-sensitive work such as interactive requests
+	PriorityHigh
+
+	numPriorities = int(PriorityHigh-PriorityLow) + 1
+)
+
+// String returns the name of the priority, such as "high".
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	default:
+		return fmt.Sprintf("priority(%d)", int(p))
+	}
+}
+
+// index returns the position of the priority in per-priority arrays.
+func (p Priority) index() int {
+	return int(p - PriorityLow)
+}
+
+// ErrStopped is returned when adding a job to a queue that has been stopped.
+var ErrStopped = errors.New("job queue stopped")
+
+// Job represents a unit of work to be processed by the job queue
+type Job struct {
+	ID       int
+	Task     func(ctx context.Context) error
+	Retries  int
+	Priority Priority
+	Timeout  time.Duration
+	Type     string
+	Payload  any // Data of a job added to a TypedJobQueue, or nil
+
+	enqueued   time.Time
+	ctx        context.Context // Canceled by CancelJob and by Stop once its context is done
+	cancel     context.CancelFunc
+	handle     *Handle
+	onComplete func(id int, err error)
+	link       trace.SpanContext // Span of the request that added the job
+}
+
+// Handle is the future result of a job added to a JobQueue.
+type Handle struct {
+	id   int
+	done chan struct{}
+	err  error
+}
+
+// ID returns the job's ID.
+func (h *Handle) ID() int {
+	return h.id
+}
+
+// Done returns a channel that is closed when the job has finished, whether it
+// succeeded, failed, or was canceled.
+func (h *Handle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Err returns the job's error once it has finished. It returns nil while the job is
+// queued or running, and after it succeeded.
+func (h *Handle) Err() error {
+	select {
+	case <-h.done:
+		return h.err
+	default:
+		return nil
+	}
+}
+
+// Wait blocks until the job has finished and returns its error, or returns ctx's
+// error if ctx is done first. The job is not canceled.
+func (h *Handle) Wait(ctx context.Context) error {
+	select {
+	case <-h.done:
+		return h.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// JobOptions configures a job added with AddJobWithOptions
+type JobOptions struct {
+	// Retries is the number of attempts made before the job fails.
+	// Default: 1
+	Retries int
+
+	// Priority is the job's class.
+	// Default: PriorityNormal
+	Priority Priority
+
+	// Timeout bounds the job's run, including its retries, from when a worker takes
+	// it. The job's context is canceled when it expires. Zero means no timeout.
+	// Default: 0
+	Timeout time.Duration
+
+	// OnComplete is called with the job's ID and error when it finishes, whether it
+	// succeeded, failed, or was canceled. It runs on the goroutine that finished the
+	// job, usually a worker, before Wait and Stop return. Optional.
+	OnComplete func(id int, err error)
+
+	// Type groups jobs in the metrics and spans, for example "embedding" or
+	// "generate". Keep the number of types small, since each is a metric label.
+	// Default: "default"
+	Type string
+}
+
+// Options configures a JobQueue
+type Options struct {
+	// Workers is the number of workers that take jobs of every priority.
+	// Default: 1
+	Workers int
+
+	// RateLimit is how long each worker pauses after a job.
+	// Default: 0
+	RateLimit time.Duration
+
+	// DedicatedWorkers adds workers that only take jobs of one priority, for example
+	// to keep capacity free for interactive jobs while batch jobs fill the queue.
+	// Optional.
+	DedicatedWorkers map[Priority]int
+
+	// MaxWait is how long a job can be passed over by jobs of higher priority before
+	// it is handed out first anyway, so lower priorities are never starved.
+	// Default: 5 seconds
+	MaxWait time.Duration
+
+	// Retry configures the backoff between a job's attempts. MaxAttempts is ignored in
+	// favor of each job's Retries, and zero durations fall back to the defaults.
+	// Default: 500ms doubling up to 30 seconds, with jitter
+	Retry retry.Options
+
+	// MaxDeadLetters is how many jobs that exhausted their retries are kept in the
+	// dead-letter queue. The oldest are dropped when it is full. A negative value
+	// disables the dead-letter queue.
+	// Default: 1000
+	MaxDeadLetters int
+
+	// Name identifies the queue in the metrics and spans.
+	// Default: "default"
+	Name string
+
+	// Metrics records the queue depth, wait and run times, retries, and failures by
+	// job type. Optional.
+	Metrics *metrics.MetricsProvider
+
+	// Tracer starts a span for each job's run, linked to the span of the request
+	// that added the job with AddJobWithContext.
+	// Default: a tracer from the global OpenTelemetry tracer provider
+	Tracer trace.Tracer
+}
+
+// DefaultOptions returns the default job queue options
+func DefaultOptions() Options {
+	return Options{
+		Workers: 1,
+		MaxWait: 5 * time.Second,
+		Retry: retry.Options{
+			InitialBackoff: 500 * time.Millisecond,
+			MaxBackoff:     30 * time.Second,
+			Jitter:         true,
+		},
+		MaxDeadLetters: 1000,
+		Name:           "default",
+		Tracer:         otel.Tracer(tracerName),
+	}
+}
+
+// PriorityStats counts the jobs of one priority
+type PriorityStats struct {
+	// Queued is the number of jobs waiting for a worker.
+	Queued int
+
+	// Running is the number of jobs being processed.
+	Running int
+
+	// Succeeded and Failed count the finished jobs.
+	Succeeded int
+	Failed    int
+
+	// TotalWait is the time the jobs handed to workers spent queued.
+	TotalWait time.Duration
+}
+
+// JobQueue manages background job processing with a worker pool and rate limiting.
+// Jobs of a higher priority are handed to workers first, and jobs of the same
+// priority in the order they were added.
+type JobQueue struct {
+	jobs         chan Job
+	dedicated    [numPriorities]chan Job // Jobs for the workers of a single priority
+	workerCount  int
+	rateLimit    time.Duration
+	options      Options
+	results      map[int]error
+	resultsMutex sync.Mutex
+	ctx          context.Context
+	cancel       context.CancelFunc
+
+	queueMutex  sync.Mutex
+	queues      [numPriorities][]Job
+	stats       [numPriorities]PriorityStats
+	dispatching bool
+	wake        chan struct{}
+	offered     *Job                       // Job the dispatcher is offering to workers
+	active      map[int]context.CancelFunc // Cancels each unfinished job, by ID
+	stopped     bool
+	stopOnce    sync.Once
+	unfinished  int           // Jobs added that have not finished
+	idle        chan struct{} // Closed when the last unfinished job finishes
+
+	deadLetters     []DeadLetter // Oldest first
+	deadLetterMutex sync.Mutex
+}
+
+// NewJobQueue initializes a new JobQueue with the specified number of workers and rate limit
+func NewJobQueue(workerCount int, rateLimit time.Duration) *JobQueue {
+	options := DefaultOptions()
+	options.Workers = workerCount
+	options.RateLimit = rateLimit
+	return NewJobQueueWithOptions(options)
+}
+
+// NewJobQueueWithOptions initializes a new JobQueue with the given options.
+// Zero option values fall back to the defaults.
+func NewJobQueueWithOptions(options Options) *JobQueue {
+	defaults := DefaultOptions()
+	if options.Workers <= 0 {
+		options.Workers = defaults.Workers
+	}
+	if options.MaxWait <= 0 {
+		options.MaxWait = defaults.MaxWait
+	}
+	if options.Retry.InitialBackoff <= 0 {
+		options.Retry.InitialBackoff = defaults.Retry.InitialBackoff
+	}
+	if options.Retry.MaxBackoff <= 0 {
+		options.Retry.MaxBackoff = defaults.Retry.MaxBackoff
+	}
+	if options.MaxDeadLetters == 0 {
+		options.MaxDeadLetters = defaults.MaxDeadLetters
+	}
+	if options.Name == "" {
+		options.Name = defaults.Name
+	}
+	if options.Tracer == nil {
+		options.Tracer = defaults.Tracer
+	}
+
+	jq := &JobQueue{
+		jobs:        make(chan Job),
+		workerCount: options.Workers,
+		rateLimit:   options.RateLimit,
+		options:     options,
+		results:     make(map[int]error),
+		wake:        make(chan struct{}, 1),
+		active:      make(map[int]context.CancelFunc),
+	}
+	jq.ctx, jq.cancel = context.WithCancel(context.Background())
+	for priority, count := range options.DedicatedWorkers {
+		if count > 0 {
+			jq.dedicated[clampPriority(priority).index()] = make(chan Job)
+		}
+	}
+	return jq
+}
+
+// StartWorkers starts the worker pool to process jobs asynchronously
+func (jq *JobQueue) StartWorkers() {
+	for i := 0; i < jq.workerCount; i++ {
+		go jq.worker(i, jq.jobs)
+	}
+	id := jq.workerCount
+	for priority, count := range jq.options.DedicatedWorkers {
+		for i := 0; i < count; i++ {
+			go jq.worker(id, jq.dedicated[clampPriority(priority).index()])
+			id++
+		}
+	}
+}
+
+// worker is a function that processes jobs from the queue with rate limiting
+func (jq *JobQueue) worker(workerID int, jobs <-chan Job) {
+	for job := range jobs {
+		jq.queueMutex.Lock()
+		jq.stats[job.Priority.index()].Running++
+		jq.stats[job.Priority.index()].TotalWait += time.Since(job.enqueued)
+		jq.queueMutex.Unlock()
+		if jq.options.Metrics != nil {
+			jq.options.Metrics.AddJobQueueDepth(jq.options.Name, job.Type, -1)
+			jq.options.Metrics.TrackJobStart(jq.options.Name, job.Type, time.Since(job.enqueued))
+		}
+
+		logging.Default().Debug("processing job", "queue", jq.options.Name, "worker", workerID, "job", job.ID, "type", job.Type)
+		start := time.Now()
+		attempts, err := jq.run(job)
+
+		jq.queueMutex.Lock()
+		jq.stats[job.Priority.index()].Running--
+		jq.queueMutex.Unlock()
+
+		// Failed rather than canceled
+		failed := err != nil && job.ctx.Err() == nil
+		if jq.options.Metrics != nil {
+			jq.options.Metrics.TrackJobFinish(jq.options.Name, job.Type, time.Since(start), failed)
+		}
+		if failed {
+			jq.deadLetter(job, attempts, err)
+		}
+		jq.finish(job, err)
+
+		time.Sleep(jq.rateLimit) // Rate limiting
+	}
+}
+
+// run attempts a job until it succeeds, runs out of retries, or its context is done,
+// backing off between attempts as configured by Options.Retry. The attempts share a
+// span, which the task's context carries. It returns the number of attempts made and
+// the last error.
+func (jq *JobQueue) run(job Job) (attempts int, err error) {
+	spanOptions := []trace.SpanStartOption{
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("queue.name", jq.options.Name),
+			attribute.Int("job.id", job.ID),
+			attribute.String("job.type", job.Type),
+			attribute.String("job.priority", job.Priority.String()),
+		),
+	}
+	if job.link.IsValid() {
+		spanOptions = append(spanOptions, trace.WithLinks(trace.Link{SpanContext: job.link}))
+	}
+	ctx, span := jq.options.Tracer.Start(job.ctx, "queue.job", spanOptions...)
+	defer func() {
+		span.SetAttributes(attribute.Int("job.attempts", attempts))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	if job.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, job.Timeout)
+		defer cancel()
+	}
+
+	opts := jq.options.Retry
+	opts.MaxAttempts = job.Retries
+	opts.OnRetry = func(attempt int, err error) {
+		logging.Default().WarnContext(ctx, "job failed, retrying", "queue", jq.options.Name, "job", job.ID, "attempt", attempt, "max_attempts", job.Retries, "error", err)
+		if jq.options.Metrics != nil {
+			jq.options.Metrics.TrackJobRetry(jq.options.Name, job.Type)
+		}
+		if jq.options.Retry.OnRetry != nil {
+			jq.options.Retry.OnRetry(attempt, err)
+		}
+	}
+
+	var last error
+	err = retry.DoWithContext(ctx, opts, func(ctx context.Context) error {
+		attempts++
+		last = job.Task(ctx)
+		return last
+	})
+	if err == nil {
+		return attempts, nil
+	}
+	// Report the task's own error rather than the retry package's wrapping of it
+	if last == nil {
+		return attempts, ctx.Err()
+	}
+	// A job canceled or timed out between attempts reports that, so callers can tell
+	// it from a job whose task failed
+	if ctxErr := ctx.Err(); ctxErr != nil && errors.Is(err, ctxErr) {
+		return attempts, fmt.Errorf("%w while waiting to retry: %w", ctxErr, last)
+	}
+	return attempts, last
+}
+
+// finish records the result of a job that was run or canceled, completes its handle,
+// and calls its OnComplete callback
+func (jq *JobQueue) finish(job Job, err error) {
+	job.cancel()
+
+	jq.resultsMutex.Lock()
+	jq.results[job.ID] = err
+	jq.resultsMutex.Unlock()
+
+	jq.queueMutex.Lock()
+	stats := &jq.stats[job.Priority.index()]
+	if err != nil {
+		stats.Failed++
+	} else {
+		stats.Succeeded++
+	}
+	delete(jq.active, job.ID)
+	jq.queueMutex.Unlock()
+
+	job.handle.err = err
+	close(job.handle.done)
+	if job.onComplete != nil {
+		job.onComplete(job.ID, err)
+	}
+
+	jq.queueMutex.Lock()
+	jq.unfinished--
+	if jq.unfinished == 0 {
+		close(jq.idle)
+		jq.idle = nil
+	}
+	jq.queueMutex.Unlock()
+}
+
+// AddJob adds a job with normal priority to the job queue for processing
+func (jq *JobQueue) AddJob(id int, task func(ctx context.Context) error, retries int) (*Handle, error) {
+	return jq.AddJobWithOptions(id, task, JobOptions{Retries: retries})
+}
+
+// AddJobWithOptions adds a job to the job queue for processing. It returns without
+// waiting for a worker, with a handle that completes when the job finishes. The task's
+// context is canceled by CancelJob, by Stop once its context is done, and when the
+// job's timeout expires. It returns ErrStopped once Stop has been called.
+func (jq *JobQueue) AddJobWithOptions(id int, task func(ctx context.Context) error, options JobOptions) (*Handle, error) {
+	return jq.AddJobWithContext(context.Background(), id, task, options)
+}
+
+// AddJobWithContext is AddJobWithOptions for a job added while handling a request.
+// The span of the job's run is linked to the span in ctx. Canceling ctx does not
+// cancel the job.
+func (jq *JobQueue) AddJobWithContext(ctx context.Context, id int, task func(ctx context.Context) error, options JobOptions) (*Handle, error) {
+	return jq.addJob(ctx, id, task, nil, options)
+}
+
+// addJob adds a job, with the payload it was created from if it has one.
+func (jq *JobQueue) addJob(ctx context.Context, id int, task func(ctx context.Context) error, payload any, options JobOptions) (*Handle, error) {
+	if options.Retries <= 0 {
+		options.Retries = 1
+	}
+	job := Job{
+		ID:         id,
+		Task:       task,
+		Retries:    options.Retries,
+		Priority:   clampPriority(options.Priority),
+		Timeout:    options.Timeout,
+		Type:       options.Type,
+		Payload:    payload,
+		enqueued:   time.Now(),
+		handle:     &Handle{id: id, done: make(chan struct{})},
+		onComplete: options.OnComplete,
+		link:       trace.SpanContextFromContext(ctx),
+	}
+	if job.Type == "" {
+		job.Type = defaultJobType
+	}
+
+	jq.queueMutex.Lock()
+	defer jq.queueMutex.Unlock()
+	if jq.stopped {
+		return nil, ErrStopped
+	}
+	job.ctx, job.cancel = context.WithCancel(jq.ctx)
+	if jq.unfinished == 0 {
+		jq.idle = make(chan struct{})
+	}
+	jq.unfinished++
+	jq.active[job.ID] = job.cancel
+	jq.queues[job.Priority.index()] = append(jq.queues[job.Priority.index()], job)
+	if jq.options.Metrics != nil {
+		jq.options.Metrics.AddJobQueueDepth(jq.options.Name, job.Type, 1)
+	}
+	if !jq.dispatching {
+		jq.dispatching = true
+		go jq.dispatch()
+	} else {
+		jq.signal()
+	}
+	return job.handle, nil
+}
+
+// CancelJob cancels the unfinished job with the given ID and reports whether there
+// was one. A queued job fails with context.Canceled without running, and a running
+// job's context is canceled so it can return early.
+func (jq *JobQueue) CancelJob(id int) bool {
+	jq.queueMutex.Lock()
+	cancel, ok := jq.active[id]
+	if !ok {
+		jq.queueMutex.Unlock()
+		return false
+	}
+	cancel()
+	removed := jq.dequeue(func(job Job) bool { return job.ID == id })
+	jq.queueMutex.Unlock()
+
+	for _, job := range removed {
+		jq.finish(job, context.Canceled)
+	}
+	return true
+}
+
+// Stop stops the queue from accepting jobs and waits for the queued and running jobs
+// to finish. If ctx is done first, the remaining jobs are canceled: running jobs'
+// contexts are canceled, queued jobs fail with context.Canceled, and Stop returns
+// ctx's error once no job is running. The workers exit when Stop returns. Calling
+// Stop again waits for the jobs in the same way.
+func (jq *JobQueue) Stop(ctx context.Context) error {
+	jq.queueMutex.Lock()
+	jq.stopped = true
+	jq.queueMutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		jq.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		jq.abort()
+		<-done
+	}
+
+	jq.stopOnce.Do(func() {
+		close(jq.jobs)
+		for _, jobs := range jq.dedicated {
+			if jobs != nil {
+				close(jobs)
+			}
+		}
+	})
+	return err
+}
+
+// abort cancels every unfinished job
+func (jq *JobQueue) abort() {
+	jq.queueMutex.Lock()
+	jq.cancel()
+	removed := jq.dequeue(func(Job) bool { return true })
+	jq.queueMutex.Unlock()
+
+	for _, job := range removed {
+		jq.finish(job, context.Canceled)
+	}
+}
+
+// dequeue removes and returns the queued jobs for which match returns true.
+// This method is not thread-safe and should be called with the queue mutex locked.
+func (jq *JobQueue) dequeue(match func(Job) bool) []Job {
+	var removed []Job
+	for p, queue := range jq.queues {
+		var kept []Job
+		for _, job := range queue {
+			if match(job) {
+				removed = append(removed, job)
+				if jq.options.Metrics != nil {
+					jq.options.Metrics.AddJobQueueDepth(jq.options.Name, job.Type, -1)
+				}
+			} else {
+				kept = append(kept, job)
+			}
+		}
+		jq.queues[p] = kept
+	}
+	if len(removed) > 0 {
+		jq.signal()
+	}
+	return removed
+}
+
+// dispatch hands queued jobs to workers in priority order until the queues are empty.
+// The choice is made again whenever a job is added or the oldest job reaches MaxWait,
+// so a job added while every worker is busy can still go first.
+func (jq *JobQueue) dispatch() {
+	for {
+		jq.queueMutex.Lock()
+		job, ok := jq.next(time.Now())
+		if !ok {
+			jq.dispatching = false
+			jq.queueMutex.Unlock()
+			return
+		}
+		recheck := jq.options.MaxWait - time.Since(jq.oldest())
+
+		// Take the job off its queue while offering it, so CancelJob and Stop
+		// cannot finish it a second time. Canceled jobs are skipped by the worker.
+		p := job.Priority.index()
+		jq.queues[p] = jq.queues[p][1:]
+		jq.offered = &job
+		jq.queueMutex.Unlock()
+
+		timer := time.NewTimer(max(recheck, time.Millisecond))
+		handed := true
+		select {
+		case jq.jobs <- job:
+		case jq.dedicated[p] <- job:
+		case <-jq.wake:
+			handed = false
+		case <-timer.C:
+			handed = false
+		}
+		timer.Stop()
+
+		jq.queueMutex.Lock()
+		if !handed {
+			jq.queues[p] = append([]Job{job}, jq.queues[p]...)
+		}
+		jq.offered = nil
+		jq.queueMutex.Unlock()
+	}
+}
+
+// next returns the job to hand out first: the longest-waiting job that has exceeded
+// MaxWait, or else the oldest job of the highest non-empty priority.
+// This method is not thread-safe and should be called with the queue mutex locked.
+func (jq *JobQueue) next(now time.Time) (Job, bool) {
+	var starved *Job
+	for p := range jq.queues {
+		queue := jq.queues[p]
+		if len(queue) == 0 || now.Sub(queue[0].enqueued) < jq.options.MaxWait {
+			continue
+		}
+		if starved == nil || queue[0].enqueued.Before(starved.enqueued) {
+			starved = &queue[0]
+		}
+	}
+	if starved != nil {
+		return *starved, true
+	}
+
+	for p := numPriorities - 1; p >= 0; p-- {
+		if len(jq.queues[p]) > 0 {
+			return jq.queues[p][0], true
+		}
+	}
+	return Job{}, false
+}
+
+// oldest returns when the longest-waiting queued job was added.
+// This method is not thread-safe and should be called with the queue mutex locked.
+func (jq *JobQueue) oldest() time.Time {
+	var oldest time.Time
+	for _, queue := range jq.queues {
+		if len(queue) > 0 && (oldest.IsZero() || queue[0].enqueued.Before(oldest)) {
+			oldest = queue[0].enqueued
+		}
+	}
+	return oldest
+}
+
+// signal wakes the dispatcher so it re-evaluates the queues.
+func (jq *JobQueue) signal() {
+	select {
+	case jq.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Pending returns the number of jobs that have been added and not finished, including
+// jobs waiting for a worker
+func (jq *JobQueue) Pending() int {
+	jq.queueMutex.Lock()
+	defer jq.queueMutex.Unlock()
+	return jq.unfinished
+}
+
+// Stats returns the counts of jobs by priority
+func (jq *JobQueue) Stats() map[Priority]PriorityStats {
+	jq.queueMutex.Lock()
+	defer jq.queueMutex.Unlock()
+
+	stats := make(map[Priority]PriorityStats, numPriorities)
+	for p := range jq.stats {
+		s := jq.stats[p]
+		s.Queued = len(jq.queues[p])
+		if jq.offered != nil && jq.offered.Priority.index() == p {
+			s.Queued++
+		}
+		stats[Priority(p)+PriorityLow] = s
+	}
+	return stats
+}
+
+// Wait blocks until no job is queued or running. The queue keeps accepting jobs, so
+// Wait can be called again for the next batch.
+func (jq *JobQueue) Wait() {
+	jq.queueMutex.Lock()
+	idle := jq.idle
+	jq.queueMutex.Unlock()
+
+	if idle != nil {
+		<-idle
+	}
+}
+
+// Results returns a copy of the errors of the finished jobs, by ID. It is safe to call
+// while jobs are running; jobs that have not finished are not included.
+func (jq *JobQueue) Results() map[int]error {
+	jq.resultsMutex.Lock()
+	defer jq.resultsMutex.Unlock()
+
+	results := make(map[int]error, len(jq.results))
+	for id, err := range jq.results {
+		results[id] = err
+	}
+	return results
+}
+
+// clampPriority maps out-of-range priorities to the nearest class.
+func clampPriority(priority Priority) Priority {
+	if priority < PriorityLow {
+		return PriorityLow
+	}
+	if priority > PriorityHigh {
+		return PriorityHigh
+	}
+	return priority
+}
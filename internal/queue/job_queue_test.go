@@ -1,10 +1,18 @@
 package queue
 
 import (
+	"context"
 	"errors"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/h2co32/gollama/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestNewJobQueue(t *testing.T) {
@@ -46,7 +54,7 @@ func TestJobQueueProcessing(t *testing.T) {
 
 	for i := 0; i < jobCount; i++ {
 		jobID := i
-		jq.AddJob(jobID, func() error {
+		jq.AddJob(jobID, func(ctx context.Context) error {
 			// Simulate work
 			time.Sleep(20 * time.Millisecond)
 			resultsMutex.Lock()
@@ -67,7 +75,7 @@ func TestJobQueueProcessing(t *testing.T) {
 	}
 
 	// Check the results map
-	results := jq.GetResults()
+	results := jq.Results()
 	if len(results) != jobCount {
 		t.Errorf("Expected %d results, got %d", jobCount, len(results))
 	}
@@ -88,7 +96,7 @@ func TestJobQueueRetries(t *testing.T) {
 	var attemptCount int
 	expectedError := errors.New("first attempt error")
 
-	jq.AddJob(1, func() error {
+	jq.AddJob(1, func(ctx context.Context) error {
 		attemptCount++
 		if attemptCount == 1 {
 			return expectedError
@@ -104,7 +112,7 @@ func TestJobQueueRetries(t *testing.T) {
 		t.Errorf("Expected 2 attempts, got %d", attemptCount)
 	}
 
-	results := jq.GetResults()
+	results := jq.Results()
 	if results[1] != nil {
 		t.Errorf("Expected job to eventually succeed, got error: %v", results[1])
 	}
@@ -117,7 +125,7 @@ func TestJobQueueRetries(t *testing.T) {
 	maxRetries := 3
 	attemptCount = 0
 
-	jq.AddJob(1, func() error {
+	jq.AddJob(1, func(ctx context.Context) error {
 		attemptCount++
 		return persistentError
 	}, maxRetries)
@@ -130,7 +138,7 @@ func TestJobQueueRetries(t *testing.T) {
 		t.Errorf("Expected %d attempts, got %d", maxRetries, attemptCount)
 	}
 
-	results = jq.GetResults()
+	results = jq.Results()
 	if results[1] != persistentError {
 		t.Errorf("Expected job to fail with error %v, got %v", persistentError, results[1])
 	}
@@ -153,7 +161,7 @@ func TestJobQueueConcurrency(t *testing.T) {
 	wg.Add(jobCount)
 
 	for i := 0; i < jobCount; i++ {
-		jq.AddJob(i, func() error {
+		jq.AddJob(i, func(ctx context.Context) error {
 			defer wg.Done()
 			
 			jobsMutex.Lock()
@@ -174,17 +182,11 @@ func TestJobQueueConcurrency(t *testing.T) {
 		}, 1)
 	}
 
-	// Wait for all jobs to complete
-	go func() {
-		wg.Wait()
-		// Close the jobs channel after all jobs are done
-		close(jq.jobs)
-	}()
-
-	// Set a timeout for the test
+	// Wait for all jobs to complete, with a timeout
 	done := make(chan struct{})
 	go func() {
-		jq.wg.Wait()
+		wg.Wait()
+		jq.Wait()
 		close(done)
 	}()
 
@@ -220,7 +222,7 @@ func TestJobQueueRateLimit(t *testing.T) {
 
 	for i := 0; i < jobCount; i++ {
 		jobID := i
-		jq.AddJob(jobID, func() error {
+		jq.AddJob(jobID, func(ctx context.Context) error {
 			completionTimes[jobID] = time.Now()
 			return nil
 		}, 1)
@@ -238,8 +240,8 @@ func TestJobQueueRateLimit(t *testing.T) {
 	}
 }
 
-func TestGetResults(t *testing.T) {
-	// Test that GetResults returns the correct results
+func TestResults(t *testing.T) {
+	// Test that Results returns the correct results
 	jq := NewJobQueue(1, 10*time.Millisecond)
 	jq.StartWorkers()
 
@@ -247,14 +249,14 @@ func TestGetResults(t *testing.T) {
 	successErr := error(nil)
 	failureErr := errors.New("job failed")
 
-	jq.AddJob(1, func() error { return successErr }, 1)
-	jq.AddJob(2, func() error { return failureErr }, 1)
+	jq.AddJob(1, func(ctx context.Context) error { return successErr }, 1)
+	jq.AddJob(2, func(ctx context.Context) error { return failureErr }, 1)
 
 	// Wait for all jobs to complete
 	jq.Wait()
 
 	// Get the results
-	results := jq.GetResults()
+	results := jq.Results()
 
 	// Verify the results
 	if results[1] != successErr {
@@ -264,4 +266,542 @@ func TestGetResults(t *testing.T) {
 	if results[2] != failureErr {
 		t.Errorf("Expected job 2 result to be %v, got %v", failureErr, results[2])
 	}
+
+	// The results are a copy, safe to read while jobs run
+	results[3] = failureErr
+	if _, ok := jq.Results()[3]; ok {
+		t.Error("Expected changes to the returned map not to affect the queue")
+	}
+}
+
+func TestResultsWhileRunning(t *testing.T) {
+	jq := NewJobQueue(4, 0)
+	jq.StartWorkers()
+
+	for i := 0; i < 50; i++ {
+		jq.AddJob(i, func(ctx context.Context) error { return nil }, 1)
+	}
+	for jq.Pending() > 0 {
+		for range jq.Results() {
+		}
+	}
+	jq.Wait()
+	if len(jq.Results()) != 50 {
+		t.Errorf("Expected 50 results, got %d", len(jq.Results()))
+	}
+}
+
+func TestJobHandle(t *testing.T) {
+	jq := NewJobQueue(1, 0)
+	jq.StartWorkers()
+
+	release := make(chan struct{})
+	failure := errors.New("job failed")
+	handle, err := jq.AddJob(1, func(ctx context.Context) error { <-release; return failure }, 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if handle.ID() != 1 {
+		t.Errorf("Expected handle ID 1, got %d", handle.ID())
+	}
+
+	select {
+	case <-handle.Done():
+		t.Fatal("Expected the handle not to be done while the job runs")
+	default:
+	}
+	if handle.Err() != nil {
+		t.Errorf("Expected no error while the job runs, got %v", handle.Err())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := handle.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	close(release)
+	if err := handle.Wait(context.Background()); err != failure {
+		t.Errorf("Expected %v, got %v", failure, err)
+	}
+	<-handle.Done()
+	if handle.Err() != failure {
+		t.Errorf("Expected %v, got %v", failure, handle.Err())
+	}
+
+	// A canceled job's handle completes with context.Canceled
+	handle, _ = jq.AddJob(2, func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() }, 1)
+	jq.CancelJob(2)
+	if err := handle.Wait(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestOnComplete(t *testing.T) {
+	jq := NewJobQueue(2, 0)
+	jq.StartWorkers()
+
+	var mu sync.Mutex
+	completed := make(map[int]error)
+	onComplete := func(id int, err error) {
+		mu.Lock()
+		completed[id] = err
+		mu.Unlock()
+	}
+
+	failure := errors.New("job failed")
+	jq.AddJobWithOptions(1, func(ctx context.Context) error { return nil }, JobOptions{OnComplete: onComplete})
+	jq.AddJobWithOptions(2, func(ctx context.Context) error { return failure }, JobOptions{OnComplete: onComplete})
+	jq.AddJob(3, func(ctx context.Context) error { return nil }, 1)
+
+	// Callbacks have run by the time Wait returns
+	jq.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(completed) != 2 {
+		t.Fatalf("Expected 2 callbacks, got %d", len(completed))
+	}
+	if completed[1] != nil {
+		t.Errorf("Expected job 1 to succeed, got %v", completed[1])
+	}
+	if completed[2] != failure {
+		t.Errorf("Expected %v, got %v", failure, completed[2])
+	}
+}
+
+func TestPending(t *testing.T) {
+	jq := NewJobQueue(1, 0)
+	jq.StartWorkers()
+
+	release := make(chan struct{})
+	jq.AddJob(1, func(ctx context.Context) error { <-release; return nil }, 1)
+	go jq.AddJob(2, func(ctx context.Context) error { return nil }, 1)
+
+	// Both the running job and the one waiting for the worker are pending
+	for i := 0; i < 100 && jq.Pending() != 2; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if jq.Pending() != 2 {
+		t.Errorf("Expected 2 pending jobs, got %d", jq.Pending())
+	}
+
+	close(release)
+	jq.Wait()
+	if jq.Pending() != 0 {
+		t.Errorf("Expected 0 pending jobs, got %d", jq.Pending())
+	}
+}
+
+func TestJobQueuePriority(t *testing.T) {
+	jq := NewJobQueueWithOptions(Options{Workers: 1, MaxWait: time.Minute})
+	jq.StartWorkers()
+
+	// Hold the only worker while the other jobs are queued
+	release := make(chan struct{})
+	jq.AddJob(0, func(ctx context.Context) error { <-release; return nil }, 1)
+	time.Sleep(20 * time.Millisecond)
+
+	var order []int
+	var mu sync.Mutex
+	record := func(id int) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			order = append(order, id)
+			mu.Unlock()
+			return nil
+		}
+	}
+	jq.AddJobWithOptions(1, record(1), JobOptions{Priority: PriorityLow})
+	jq.AddJobWithOptions(2, record(2), JobOptions{Priority: PriorityNormal})
+	jq.AddJobWithOptions(3, record(3), JobOptions{Priority: PriorityHigh})
+	jq.AddJobWithOptions(4, record(4), JobOptions{Priority: PriorityHigh})
+
+	if stats := jq.Stats(); stats[PriorityHigh].Queued != 2 || stats[PriorityNormal].Running != 1 {
+		t.Errorf("Expected 2 queued high jobs and 1 running normal job, got %+v", stats)
+	}
+
+	close(release)
+	jq.Wait()
+
+	expected := []int{3, 4, 2, 1}
+	for i := range expected {
+		if i >= len(order) || order[i] != expected[i] {
+			t.Fatalf("Expected jobs to run in order %v, got %v", expected, order)
+		}
+	}
+
+	stats := jq.Stats()
+	if stats[PriorityHigh].Succeeded != 2 || stats[PriorityLow].Succeeded != 1 || stats[PriorityNormal].Succeeded != 2 {
+		t.Errorf("Expected succeeded counts by priority, got %+v", stats)
+	}
+	if stats[PriorityLow].TotalWait <= 0 {
+		t.Errorf("Expected the low priority job to have waited, got %v", stats[PriorityLow].TotalWait)
+	}
+}
+
+func TestJobQueueStarvation(t *testing.T) {
+	jq := NewJobQueueWithOptions(Options{Workers: 1, MaxWait: 30 * time.Millisecond})
+	jq.StartWorkers()
+
+	release := make(chan struct{})
+	jq.AddJob(0, func(ctx context.Context) error { <-release; return nil }, 1)
+	time.Sleep(20 * time.Millisecond)
+
+	var order []int
+	var mu sync.Mutex
+	for i, priority := range []Priority{PriorityLow, PriorityHigh} {
+		id := i + 1
+		jq.AddJobWithOptions(id, func(ctx context.Context) error {
+			mu.Lock()
+			order = append(order, id)
+			mu.Unlock()
+			return nil
+		}, JobOptions{Priority: priority})
+	}
+
+	// Once both jobs have waited longer than MaxWait, the older one goes first
+	time.Sleep(60 * time.Millisecond)
+	close(release)
+	jq.Wait()
+
+	if len(order) != 2 || order[0] != 1 {
+		t.Errorf("Expected the starved low priority job to run first, got %v", order)
+	}
+}
+
+func TestJobQueueDedicatedWorkers(t *testing.T) {
+	jq := NewJobQueueWithOptions(Options{
+		Workers:          1,
+		DedicatedWorkers: map[Priority]int{PriorityHigh: 1},
+	})
+	jq.StartWorkers()
+
+	// A batch job occupies the shared worker
+	release := make(chan struct{})
+	jq.AddJobWithOptions(1, func(ctx context.Context) error { <-release; return nil }, JobOptions{Priority: PriorityLow})
+	time.Sleep(20 * time.Millisecond)
+
+	done := make(chan struct{})
+	jq.AddJobWithOptions(2, func(ctx context.Context) error { close(done); return nil }, JobOptions{Priority: PriorityHigh})
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Expected the dedicated worker to run the high priority job")
+	}
+
+	close(release)
+	jq.Wait()
+}
+
+func TestJobTimeout(t *testing.T) {
+	jq := NewJobQueue(1, 0)
+	jq.StartWorkers()
+
+	attempts := 0
+	jq.AddJobWithOptions(1, func(ctx context.Context) error {
+		attempts++
+		<-ctx.Done()
+		return ctx.Err()
+	}, JobOptions{Retries: 3, Timeout: 50 * time.Millisecond})
+	jq.Wait()
+
+	if err := jq.Results()[1]; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the job to time out, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("Expected no retries after the timeout, got %d attempts", attempts)
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	jq := NewJobQueue(1, 0)
+	jq.StartWorkers()
+
+	started := make(chan struct{})
+	jq.AddJob(1, func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}, 1)
+	ran := false
+	jq.AddJob(2, func(ctx context.Context) error { ran = true; return nil }, 1)
+	<-started
+
+	// Cancel the queued job, then the running one
+	if !jq.CancelJob(2) || !jq.CancelJob(1) {
+		t.Error("Expected both jobs to be found")
+	}
+	jq.Wait()
+
+	results := jq.Results()
+	if !errors.Is(results[1], context.Canceled) || !errors.Is(results[2], context.Canceled) {
+		t.Errorf("Expected both jobs to be canceled, got %v", results)
+	}
+	if ran {
+		t.Error("Expected the queued job not to run")
+	}
+	if jq.CancelJob(1) {
+		t.Error("Expected a finished job not to be found")
+	}
+}
+
+func TestStop(t *testing.T) {
+	// Stopping waits for every queued job
+	jq := NewJobQueue(1, 0)
+	jq.StartWorkers()
+	var mu sync.Mutex
+	count := 0
+	for i := 0; i < 3; i++ {
+		jq.AddJob(i, func(ctx context.Context) error {
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			count++
+			mu.Unlock()
+			return nil
+		}, 1)
+	}
+	if err := jq.Stop(context.Background()); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 jobs to run, got %d", count)
+	}
+
+	if _, err := jq.AddJob(3, func(ctx context.Context) error { return nil }, 1); err != ErrStopped {
+		t.Errorf("Expected ErrStopped for a job added after Stop, got %v", err)
+	}
+	if err := jq.Stop(context.Background()); err != nil {
+		t.Errorf("Expected stopping again to succeed, got %v", err)
+	}
+
+	// Once the context is done, the running job is canceled and the queued ones dropped
+	jq = NewJobQueue(1, 0)
+	jq.StartWorkers()
+	started := make(chan struct{})
+	jq.AddJob(1, func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}, 1)
+	jq.AddJob(2, func(ctx context.Context) error { return nil }, 1)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := jq.Stop(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	for id, err := range jq.Results() {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected job %d to be canceled, got %v", id, err)
+		}
+	}
+}
+
+func TestWaitIsReusable(t *testing.T) {
+	jq := NewJobQueue(2, 0)
+	jq.StartWorkers()
+
+	for batch := 0; batch < 3; batch++ {
+		for i := 0; i < 3; i++ {
+			if _, err := jq.AddJob(batch*3+i, func(ctx context.Context) error { return nil }, 1); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+		}
+		jq.Wait()
+		if jq.Pending() != 0 {
+			t.Errorf("Expected no pending jobs after batch %d, got %d", batch, jq.Pending())
+		}
+	}
+	if len(jq.Results()) != 9 {
+		t.Errorf("Expected 9 results, got %d", len(jq.Results()))
+	}
+
+	// Wait returns right away with nothing queued
+	jq.Wait()
+}
+
+func TestCancelJobDuringBackoff(t *testing.T) {
+	options := DefaultOptions()
+	options.Retry.InitialBackoff = time.Minute
+	options.Retry.Jitter = false
+	jq := NewJobQueueWithOptions(options)
+	jq.StartWorkers()
+
+	failed := make(chan struct{})
+	taskErr := errors.New("failed")
+	jq.AddJob(1, func(ctx context.Context) error {
+		close(failed)
+		return taskErr
+	}, 3)
+	<-failed
+
+	// Cancel the job while it waits to be retried
+	time.Sleep(10 * time.Millisecond)
+	if !jq.CancelJob(1) {
+		t.Fatal("Expected the job to be found")
+	}
+	jq.Wait()
+
+	err := jq.Results()[1]
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the job to be canceled, got %v", err)
+	}
+	if !errors.Is(err, taskErr) {
+		t.Errorf("Expected the last task error to be kept, got %v", err)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	options := DefaultOptions()
+	options.Retry.InitialBackoff = 20 * time.Millisecond
+	options.Retry.Jitter = false
+	jq := NewJobQueueWithOptions(options)
+	jq.StartWorkers()
+
+	var attempts []time.Time
+	jq.AddJob(1, func(ctx context.Context) error {
+		attempts = append(attempts, time.Now())
+		return errors.New("failed")
+	}, 3)
+	jq.Wait()
+
+	if len(attempts) != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", len(attempts))
+	}
+	// The backoff doubles after each attempt
+	if gap := attempts[1].Sub(attempts[0]); gap < 20*time.Millisecond {
+		t.Errorf("Expected at least 20ms before the second attempt, got %v", gap)
+	}
+	if gap := attempts[2].Sub(attempts[1]); gap < 40*time.Millisecond {
+		t.Errorf("Expected at least 40ms before the third attempt, got %v", gap)
+	}
+}
+
+// metricValue returns the value of the counter or gauge, or the sample count of the
+// histogram, with the given name and labels, or -1 if there is none.
+func metricValue(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if value, ok := labels[label.GetName()]; ok && value != label.GetValue() {
+					continue metrics
+				}
+			}
+			switch {
+			case metric.GetCounter() != nil:
+				return metric.GetCounter().GetValue()
+			case metric.GetHistogram() != nil:
+				return float64(metric.GetHistogram().GetSampleCount())
+			default:
+				return metric.GetGauge().GetValue()
+			}
+		}
+	}
+	return -1
+}
+
+func TestJobQueueMetrics(t *testing.T) {
+	options := DefaultOptions()
+	options.Name = "embeddings"
+	options.Retry.InitialBackoff = time.Millisecond
+	options.Metrics = metrics.NewMetricsProvider()
+	jq := NewJobQueueWithOptions(options)
+
+	release := make(chan struct{})
+	jq.AddJobWithOptions(1, func(ctx context.Context) error { <-release; return nil }, JobOptions{Type: "embed"})
+	jq.AddJobWithOptions(2, func(ctx context.Context) error { return errors.New("failed") }, JobOptions{Type: "embed", Retries: 3})
+	jq.AddJob(3, func(ctx context.Context) error { return nil }, 1)
+
+	embed := map[string]string{"queue": "embeddings", "type": "embed"}
+	if depth := metricValue(t, "job_queue_depth", embed); depth != 2 {
+		t.Errorf("Expected a queue depth of 2, got %v", depth)
+	}
+
+	jq.StartWorkers()
+	close(release)
+	jq.Wait()
+
+	if depth := metricValue(t, "job_queue_depth", embed); depth != 0 {
+		t.Errorf("Expected a queue depth of 0, got %v", depth)
+	}
+	if waits := metricValue(t, "job_wait_seconds", embed); waits != 2 {
+		t.Errorf("Expected 2 wait times, got %v", waits)
+	}
+	if runs := metricValue(t, "job_duration_seconds", embed); runs != 2 {
+		t.Errorf("Expected 2 run times, got %v", runs)
+	}
+	if retries := metricValue(t, "job_retries_total", embed); retries != 2 {
+		t.Errorf("Expected 2 retries, got %v", retries)
+	}
+	if failures := metricValue(t, "job_failures_total", embed); failures != 1 {
+		t.Errorf("Expected 1 failure, got %v", failures)
+	}
+	if runs := metricValue(t, "job_duration_seconds", map[string]string{"queue": "embeddings", "type": "default"}); runs != 1 {
+		t.Errorf("Expected 1 run of a job without a type, got %v", runs)
+	}
+}
+
+func TestJobQueueTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer provider.Shutdown(context.Background())
+
+	options := DefaultOptions()
+	options.Name = "generate"
+	options.Tracer = provider.Tracer("test")
+	jq := NewJobQueueWithOptions(options)
+	jq.StartWorkers()
+
+	// The request that adds the job
+	ctx, request := provider.Tracer("test").Start(context.Background(), "request")
+	var taskSpan trace.SpanContext
+	jq.AddJobWithContext(ctx, 1, func(ctx context.Context) error {
+		taskSpan = trace.SpanContextFromContext(ctx)
+		return errors.New("failed")
+	}, JobOptions{Type: "chat"})
+	request.End()
+	jq.Wait()
+
+	var job sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "queue.job" {
+			job = span
+		}
+	}
+	if job == nil {
+		t.Fatal("Expected a span for the job")
+	}
+	if job.SpanContext().SpanID() != taskSpan.SpanID() {
+		t.Error("Expected the task's context to carry the job's span")
+	}
+	if job.Parent().IsValid() {
+		t.Error("Expected the job's span to start a new trace")
+	}
+	if links := job.Links(); len(links) != 1 || links[0].SpanContext.SpanID() != request.SpanContext().SpanID() {
+		t.Errorf("Expected the job's span to link to the request's span, got %d links", len(job.Links()))
+	}
+	if job.Status().Code != codes.Error {
+		t.Errorf("Expected an error status, got %v", job.Status().Code)
+	}
+
+	attributes := make(map[string]string)
+	for _, attribute := range job.Attributes() {
+		attributes[string(attribute.Key)] = attribute.Value.Emit()
+	}
+	for key, expected := range map[string]string{"queue.name": "generate", "job.id": "1", "job.type": "chat", "job.attempts": "1"} {
+		if attributes[key] != expected {
+			t.Errorf("Expected attribute %s to be %q, got %q", key, expected, attributes[key])
+		}
+	}
 }
@@ -0,0 +1,85 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// TypedJobQueue runs jobs described by payloads of type T, such as an embedding
+// request, with a single handler rather than a closure per job. Since payloads are
+// plain data, dead-lettered jobs can be exported with their payloads and restored in
+// another process. The embedded JobQueue starts, waits for, and stops the workers.
+type TypedJobQueue[T any] struct {
+	*JobQueue
+	handler func(ctx context.Context, payload T) error
+}
+
+// NewTypedJobQueue initializes a TypedJobQueue that runs each payload with handler.
+// Zero option values fall back to the defaults.
+func NewTypedJobQueue[T any](options Options, handler func(ctx context.Context, payload T) error) *TypedJobQueue[T] {
+	return &TypedJobQueue[T]{
+		JobQueue: NewJobQueueWithOptions(options),
+		handler:  handler,
+	}
+}
+
+// Enqueue adds a job that runs the handler with payload. The span of the job's run is
+// linked to the span in ctx, as with AddJobWithContext. It returns ErrStopped once
+// Stop has been called.
+func (q *TypedJobQueue[T]) Enqueue(ctx context.Context, id int, payload T, options JobOptions) (*Handle, error) {
+	task := func(ctx context.Context) error {
+		return q.handler(ctx, payload)
+	}
+	return q.addJob(ctx, id, task, payload, options)
+}
+
+// Restore enqueues the jobs read from r, in the format written by ExportDeadLetters,
+// decoding each payload into T. The jobs keep their ID, priority, and type, and take
+// the rest of their options from options. It returns the number of jobs added, and
+// stops at the first record that cannot be decoded or added.
+func (q *TypedJobQueue[T]) Restore(r io.Reader, options JobOptions) (int, error) {
+	decoder := json.NewDecoder(r)
+	added := 0
+	for decoder.More() {
+		var record struct {
+			deadLetterRecord
+			Payload json.RawMessage `json:"payload"`
+		}
+		if err := decoder.Decode(&record); err != nil {
+			return added, fmt.Errorf("failed to decode job: %w", err)
+		}
+		if len(record.Payload) == 0 {
+			return added, fmt.Errorf("failed to restore job %d: no payload", record.ID)
+		}
+		var payload T
+		if err := json.Unmarshal(record.Payload, &payload); err != nil {
+			return added, fmt.Errorf("failed to decode payload of job %d: %w", record.ID, err)
+		}
+		priority, err := parsePriority(record.Priority)
+		if err != nil {
+			return added, fmt.Errorf("failed to restore job %d: %w", record.ID, err)
+		}
+
+		jobOptions := options
+		jobOptions.Priority = priority
+		jobOptions.Type = record.Type
+		if _, err := q.Enqueue(context.Background(), record.ID, payload, jobOptions); err != nil {
+			return added, fmt.Errorf("failed to restore job %d: %w", record.ID, err)
+		}
+		added++
+	}
+	return added, nil
+}
+
+// parsePriority returns the priority with the given name, as returned by
+// Priority.String.
+func parsePriority(name string) (Priority, error) {
+	for p := PriorityLow; p <= PriorityHigh; p++ {
+		if p.String() == name {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown priority: %s", name)
+}
@@ -0,0 +1,101 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// embeddingRequest is a payload as a producer would enqueue it
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+func TestTypedJobQueue(t *testing.T) {
+	var mu sync.Mutex
+	var handled []embeddingRequest
+	q := NewTypedJobQueue(DefaultOptions(), func(ctx context.Context, request embeddingRequest) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, request)
+		return nil
+	})
+	q.StartWorkers()
+
+	handle, err := q.Enqueue(context.Background(), 1, embeddingRequest{Model: "nomic-embed-text", Input: []string{"hello"}}, JobOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := handle.Wait(context.Background()); err != nil {
+		t.Errorf("Expected the job to succeed, got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(handled) != 1 || handled[0].Model != "nomic-embed-text" || handled[0].Input[0] != "hello" {
+		t.Errorf("Expected the handler to receive the payload, got %+v", handled)
+	}
+}
+
+func TestTypedJobQueueRestore(t *testing.T) {
+	options := DefaultOptions()
+	options.Retry.InitialBackoff = time.Millisecond
+
+	// A process whose backend is down dead-letters its jobs
+	failing := NewTypedJobQueue(options, func(ctx context.Context, request embeddingRequest) error {
+		return errors.New("backend down")
+	})
+	failing.StartWorkers()
+	failing.Enqueue(context.Background(), 1, embeddingRequest{Model: "a"}, JobOptions{Priority: PriorityHigh, Type: "embed"})
+	failing.Enqueue(context.Background(), 2, embeddingRequest{Model: "b"}, JobOptions{Type: "embed"})
+	failing.Wait()
+
+	if letters := failing.DeadLetters(); len(letters) != 2 || letters[0].Payload.(embeddingRequest).Model != "a" {
+		t.Fatalf("Expected the dead letters to keep their payloads, got %+v", letters)
+	}
+	var exported bytes.Buffer
+	if err := failing.ExportDeadLetters(&exported); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Another process restores them
+	var mu sync.Mutex
+	handled := make(map[string]bool)
+	q := NewTypedJobQueue(DefaultOptions(), func(ctx context.Context, request embeddingRequest) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled[request.Model] = true
+		return nil
+	})
+	added, err := q.Restore(&exported, JobOptions{Retries: 2})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if added != 2 {
+		t.Errorf("Expected 2 jobs to be restored, got %d", added)
+	}
+	if stats := q.Stats(); stats[PriorityHigh].Queued != 1 || stats[PriorityNormal].Queued != 1 {
+		t.Errorf("Expected the jobs to keep their priorities, got %+v", stats)
+	}
+
+	q.StartWorkers()
+	q.Wait()
+	mu.Lock()
+	defer mu.Unlock()
+	if !handled["a"] || !handled["b"] {
+		t.Errorf("Expected both payloads to be handled, got %v", handled)
+	}
+
+	// Jobs without a payload cannot be restored
+	if _, err := q.Restore(strings.NewReader(`{"id":3,"priority":"normal"}`), JobOptions{}); err == nil {
+		t.Error("Expected an error for a job without a payload")
+	}
+	if _, err := q.Restore(strings.NewReader(`{"id":3,"priority":"urgent","payload":{}}`), JobOptions{}); err == nil {
+		t.Error("Expected an error for an unknown priority")
+	}
+}
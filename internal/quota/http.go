@@ -0,0 +1,63 @@
+package quota
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/h2co32/gollama/pkg/middleware"
+)
+
+// HeaderPrefix starts the names of the quota response headers. Each quota is reported
+// by three headers, named after it, such as X-Quota-Daily-Tokens-Limit:
+//
+//   - -Limit is the requests or tokens allowed in the period
+//   - -Remaining is the requests or tokens left in the period
+//   - -Reset is the number of seconds until the quota resets
+const HeaderPrefix = "X-Quota-"
+
+// SetHeaders reports the decision's quotas in h.
+func (d Decision) SetHeaders(h http.Header) {
+	now := time.Now()
+	for _, status := range d.Quotas {
+		name := HeaderPrefix + headerName(status.Name)
+		h.Set(name+"-Limit", strconv.FormatInt(status.Limit, 10))
+		h.Set(name+"-Remaining", strconv.FormatInt(status.Remaining, 10))
+		h.Set(name+"-Reset", strconv.Itoa(ceilSeconds(status.Reset.Sub(now))))
+	}
+}
+
+// WriteRejection responds to a request the decision rejected with 429 Too Many Requests,
+// a Retry-After header of the seconds until the exceeded quota resets, and a JSON body
+// of the form {"error": "daily token quota exceeded", "quota": "daily_tokens", "limit":
+// 200000, "used": 200512, "reset": "2026-03-02T00:00:00Z", "retry_after": 3600}. Call
+// SetHeaders first to report the other quotas as well.
+func (d Decision) WriteRejection(w http.ResponseWriter) {
+	exceeded := d.Exceeded
+	retryAfter := ceilSeconds(time.Until(exceeded.Reset))
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	middleware.JSONResponse(w, http.StatusTooManyRequests, map[string]interface{}{
+		"error":       strings.TrimSuffix(strings.ReplaceAll(exceeded.Name, "_", " "), "s") + " quota exceeded",
+		"quota":       exceeded.Name,
+		"limit":       exceeded.Limit,
+		"used":        exceeded.Used,
+		"reset":       exceeded.Reset.Format(time.RFC3339),
+		"retry_after": retryAfter,
+	})
+}
+
+// headerName returns a quota name in header case, such as Daily-Tokens.
+func headerName(quota string) string {
+	words := strings.Split(quota, "_")
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, "-")
+}
+
+// ceilSeconds rounds a duration up to whole seconds, and negative durations to zero.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(max(d, 0).Seconds()))
+}
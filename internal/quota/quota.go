@@ -0,0 +1,275 @@
+// Package quota enforces daily and monthly request and token quotas by tenant, such as
+// the client an API key belongs to.
+//
+// An Engine admits a tenant's request while the tenant is within each of its quotas,
+// counting it against the request quotas, and counts the tokens of the response
+// against the token quotas once they are known. Since tokens are only known after the
+// response, the request that reaches a token quota can go over it; the requests after
+// it are rejected until the quota resets. Quotas reset at the start of each UTC day
+// and month.
+//
+// Counts are kept in a Store. A RedisStore shares them between every gateway replica
+// using the same Redis, so a tenant's quota holds across replicas; a MemoryStore keeps
+// them in the process.
+//
+// Example usage:
+//
+//	engine := quota.NewEngine(quota.Options{
+//		Store:   quota.NewRedisStore(redis.NewClient(&redis.Options{Addr: "localhost:6379"}), "gollama:quota"),
+//		Default: quota.Limits{DailyRequests: 1000},
+//		Tenants: map[string]quota.Limits{"acme": {DailyTokens: 200000, MonthlyTokens: 5000000}},
+//	})
+//
+//	decision, err := engine.Allow(ctx, "acme")
+//	if err != nil {
+//		return err
+//	}
+//	if !decision.Allowed {
+//		return fmt.Errorf("%s quota exceeded", decision.Exceeded.Name)
+//	}
+//	tokens := serve(ctx)
+//	engine.AddTokens(ctx, "acme", tokens)
+package quota
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/h2co32/gollama/internal/metrics"
+)
+
+// Quota names, as reported by Status and metrics.
+const (
+	DailyRequests   = "daily_requests"
+	DailyTokens     = "daily_tokens"
+	MonthlyRequests = "monthly_requests"
+	MonthlyTokens   = "monthly_tokens"
+)
+
+// expiryGrace keeps the counts of a period for a while after it ends, so replicas whose
+// clocks lag behind still find them.
+const expiryGrace = 24 * time.Hour
+
+// Limits are a tenant's quotas per UTC day and per UTC calendar month. Zero fields are
+// unlimited.
+type Limits struct {
+	DailyRequests   int64
+	DailyTokens     int64
+	MonthlyRequests int64
+	MonthlyTokens   int64
+}
+
+// Status is the state of one of a tenant's quotas.
+type Status struct {
+	Name      string    // Quota name, such as DailyTokens
+	Limit     int64     // Requests or tokens allowed in the period
+	Used      int64     // Requests or tokens counted in the period, including the admitted request
+	Remaining int64     // Limit minus Used, or zero once the quota is used up
+	Reset     time.Time // Start of the next period, when the quota resets
+}
+
+// Decision is the outcome of checking a tenant's quotas for a request.
+type Decision struct {
+	// Allowed reports whether the request is within every quota.
+	Allowed bool
+
+	// Quotas are the tenant's limited quotas, counting the request if it was allowed.
+	Quotas []Status
+
+	// Exceeded is the quota that rejected the request, or nil if it was allowed.
+	Exceeded *Status
+}
+
+// Options configures an Engine.
+type Options struct {
+	// Store keeps the counts of requests and tokens.
+	// Default: a new MemoryStore
+	Store Store
+
+	// Default are the limits of tenants without their own in Tenants. Optional. By
+	// default those tenants are unlimited.
+	Default Limits
+
+	// Tenants are the limits of tenants by name, replacing Default. Optional.
+	Tenants map[string]Limits
+
+	// Metrics counts the requests rejected for exceeding each quota. Optional.
+	Metrics *metrics.MetricsProvider
+}
+
+// DefaultOptions returns the default engine options.
+func DefaultOptions() Options {
+	return Options{Store: NewMemoryStore()}
+}
+
+// Engine enforces the quotas of each tenant. It is safe for concurrent use.
+type Engine struct {
+	options Options
+	mu      sync.RWMutex // Guards the limits in options
+}
+
+// NewEngine creates an Engine with the given options. A nil Store falls back to the
+// default.
+func NewEngine(options Options) *Engine {
+	if options.Store == nil {
+		options.Store = DefaultOptions().Store
+	}
+	return &Engine{options: options}
+}
+
+// Limits returns the limits of tenant.
+func (e *Engine) Limits(tenant string) Limits {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if limits, ok := e.options.Tenants[tenant]; ok {
+		return limits
+	}
+	return e.options.Default
+}
+
+// SetLimits replaces the default limits and the limits of tenants, such as after the
+// config file they came from is reloaded. Counts so far are kept, so lowering a limit
+// below a tenant's count rejects its next request.
+func (e *Engine) SetLimits(defaultLimits Limits, tenants map[string]Limits) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.options.Default = defaultLimits
+	e.options.Tenants = tenants
+}
+
+// period is a day or month in which a tenant's requests and tokens are counted.
+type period struct {
+	key           string
+	requestsQuota string
+	tokensQuota   string
+	requests      int64 // Request limit, or zero if unlimited
+	tokens        int64 // Token limit, or zero if unlimited
+	reset         time.Time
+}
+
+// periods returns the periods of tenant at now that have a limit.
+func (e *Engine) periods(tenant string, now time.Time) []period {
+	limits := e.Limits(tenant)
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	var periods []period
+	if limits.DailyRequests > 0 || limits.DailyTokens > 0 {
+		periods = append(periods, period{
+			key:           tenant + ":day:" + day.Format("2006-01-02"),
+			requestsQuota: DailyRequests,
+			tokensQuota:   DailyTokens,
+			requests:      limits.DailyRequests,
+			tokens:        limits.DailyTokens,
+			reset:         day.AddDate(0, 0, 1),
+		})
+	}
+	if limits.MonthlyRequests > 0 || limits.MonthlyTokens > 0 {
+		periods = append(periods, period{
+			key:           tenant + ":month:" + month.Format("2006-01"),
+			requestsQuota: MonthlyRequests,
+			tokensQuota:   MonthlyTokens,
+			requests:      limits.MonthlyRequests,
+			tokens:        limits.MonthlyTokens,
+			reset:         month.AddDate(0, 1, 0),
+		})
+	}
+	return periods
+}
+
+// Allow checks whether a request of tenant is within its quotas, and counts it against
+// the request quotas if it is. A tenant is within a request quota until it has used up
+// the limit, and within a token quota while it has tokens left.
+func (e *Engine) Allow(ctx context.Context, tenant string) (Decision, error) {
+	return e.allow(ctx, tenant, time.Now())
+}
+
+// allow is Allow at now.
+func (e *Engine) allow(ctx context.Context, tenant string, now time.Time) (Decision, error) {
+	periods := e.periods(tenant, now)
+	if len(periods) == 0 {
+		return Decision{Allowed: true}, nil
+	}
+
+	// Count the request first, so concurrent requests cannot all take the last one
+	increments := make([]Increment, len(periods))
+	for i, p := range periods {
+		increments[i] = Increment{Key: p.key, Requests: 1, Expires: p.reset.Add(expiryGrace)}
+	}
+	counts, err := e.options.Store.Add(ctx, increments)
+	if err != nil {
+		return Decision{}, fmt.Errorf("failed to count request: %w", err)
+	}
+
+	decision := Decision{Allowed: true}
+	exceeded := -1
+	for i, p := range periods {
+		if p.requests > 0 {
+			if counts[i].Requests > p.requests && exceeded < 0 {
+				exceeded = len(decision.Quotas)
+			}
+			decision.Quotas = append(decision.Quotas, newStatus(p.requestsQuota, p.requests, counts[i].Requests, p.reset))
+		}
+		if p.tokens > 0 {
+			if counts[i].Tokens >= p.tokens && exceeded < 0 {
+				exceeded = len(decision.Quotas)
+			}
+			decision.Quotas = append(decision.Quotas, newStatus(p.tokensQuota, p.tokens, counts[i].Tokens, p.reset))
+		}
+	}
+	if exceeded < 0 {
+		return decision, nil
+	}
+
+	// Uncount the rejected request. If that fails, it stays counted, which errs on the
+	// side of the quota.
+	for i := range increments {
+		increments[i].Requests = -1
+	}
+	e.options.Store.Add(ctx, increments)
+	for i, status := range decision.Quotas {
+		if status.Name == DailyRequests || status.Name == MonthlyRequests {
+			decision.Quotas[i] = newStatus(status.Name, status.Limit, status.Used-1, status.Reset)
+		}
+	}
+
+	decision.Allowed = false
+	decision.Exceeded = &decision.Quotas[exceeded]
+	if e.options.Metrics != nil {
+		e.options.Metrics.TrackQuotaRejection(tenant, decision.Exceeded.Name)
+	}
+	return decision, nil
+}
+
+// newStatus returns the status of a quota with used requests or tokens counted.
+func newStatus(name string, limit, used int64, reset time.Time) Status {
+	return Status{Name: name, Limit: limit, Used: used, Remaining: max(limit-used, 0), Reset: reset}
+}
+
+// AddTokens counts tokens used by a request of tenant against its token quotas.
+func (e *Engine) AddTokens(ctx context.Context, tenant string, tokens int) error {
+	return e.addTokens(ctx, tenant, tokens, time.Now())
+}
+
+// addTokens is AddTokens at now.
+func (e *Engine) addTokens(ctx context.Context, tenant string, tokens int, now time.Time) error {
+	if tokens <= 0 {
+		return nil
+	}
+	var increments []Increment
+	for _, p := range e.periods(tenant, now) {
+		if p.tokens > 0 {
+			increments = append(increments, Increment{Key: p.key, Tokens: int64(tokens), Expires: p.reset.Add(expiryGrace)})
+		}
+	}
+	if len(increments) == 0 {
+		return nil
+	}
+	if _, err := e.options.Store.Add(ctx, increments); err != nil {
+		return fmt.Errorf("failed to count tokens: %w", err)
+	}
+	return nil
+}
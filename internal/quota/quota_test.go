@@ -0,0 +1,158 @@
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestEngine(t *testing.T) {
+	engine := NewEngine(Options{
+		Default: Limits{DailyRequests: 2},
+		Tenants: map[string]Limits{"acme": {DailyTokens: 100, MonthlyRequests: 3}},
+	})
+	ctx := context.Background()
+	// Counts expire in real time, so the test days are next year
+	day := time.Date(time.Now().Year()+1, 3, 31, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		if decision, err := engine.allow(ctx, "globex", day); err != nil || !decision.Allowed {
+			t.Fatalf("Expected request %d to be allowed, got %+v, %v", i, decision, err)
+		}
+	}
+	decision, err := engine.allow(ctx, "globex", day)
+	if err != nil || decision.Allowed || decision.Exceeded.Name != DailyRequests {
+		t.Fatalf("Expected the daily request quota to be exceeded, got %+v, %v", decision, err)
+	}
+	// The rejected request is not counted
+	if status := decision.Exceeded; status.Used != 2 || status.Remaining != 0 || !status.Reset.Equal(day.Add(12*time.Hour)) {
+		t.Errorf("Unexpected status %+v", status)
+	}
+	if decision, _ := engine.allow(ctx, "globex", day.Add(12*time.Hour)); !decision.Allowed {
+		t.Error("Expected the quota to reset the next day")
+	}
+
+	// Token quotas allow requests while tokens are left
+	decision, _ = engine.allow(ctx, "acme", day)
+	if len(decision.Quotas) != 2 || decision.Quotas[0].Name != DailyTokens || decision.Quotas[0].Remaining != 100 || decision.Quotas[1].Remaining != 2 {
+		t.Errorf("Unexpected quotas %+v", decision.Quotas)
+	}
+	engine.addTokens(ctx, "acme", 150, day)
+	decision, _ = engine.allow(ctx, "acme", day)
+	if decision.Allowed || decision.Exceeded.Name != DailyTokens || decision.Exceeded.Used != 150 {
+		t.Errorf("Expected the daily token quota to be exceeded, got %+v", decision)
+	}
+	if decision.Quotas[1].Used != 1 {
+		t.Errorf("Expected the rejected request not to count against the monthly quota, got %+v", decision.Quotas[1])
+	}
+
+	// The monthly quota carries over days, and resets with the month
+	engine.allow(ctx, "acme", day.Add(-24*time.Hour))
+	engine.allow(ctx, "acme", day.Add(-24*time.Hour))
+	if decision, _ := engine.allow(ctx, "acme", day.Add(-48*time.Hour)); decision.Allowed || decision.Exceeded.Name != MonthlyRequests {
+		t.Errorf("Expected the monthly request quota to be exceeded, got %+v", decision)
+	}
+	if decision, _ := engine.allow(ctx, "acme", day.Add(12*time.Hour)); !decision.Allowed || decision.Quotas[1].Reset.Month() != time.May {
+		t.Errorf("Expected the quota to reset in April, got %+v", decision)
+	}
+
+	// New limits apply to the counts so far
+	engine.SetLimits(Limits{DailyRequests: 3}, nil)
+	if decision, _ := engine.allow(ctx, "acme", day); !decision.Allowed || decision.Quotas[0].Name != DailyRequests || decision.Quotas[0].Used != 2 {
+		t.Errorf("Expected acme to have the new default limits, got %+v", decision)
+	}
+	if decision, _ := engine.allow(ctx, "globex", day); !decision.Allowed || decision.Quotas[0].Remaining != 0 {
+		t.Errorf("Expected globex to get the last of its raised daily requests, got %+v", decision)
+	}
+
+	unlimited := NewEngine(Options{})
+	if decision, _ := unlimited.Allow(ctx, "acme"); !decision.Allowed || len(decision.Quotas) != 0 {
+		t.Errorf("Expected an unlimited tenant to be allowed, got %+v", decision)
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	defer s.Close()
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	defer client.Close()
+
+	// Engines sharing a Redis share the counts, as replicas of a gateway do
+	limits := Options{Default: Limits{DailyRequests: 3, DailyTokens: 1000}}
+	limits.Store = NewRedisStore(client, "quota")
+	a := NewEngine(limits)
+	limits.Store = NewRedisStore(client, "quota")
+	b := NewEngine(limits)
+
+	ctx := context.Background()
+	day := time.Date(time.Now().Year()+1, 3, 1, 12, 0, 0, 0, time.UTC)
+	a.allow(ctx, "acme", day)
+	b.addTokens(ctx, "acme", 400, day)
+	decision, err := b.allow(ctx, "acme", day)
+	if err != nil || !decision.Allowed {
+		t.Fatalf("Expected the request to be allowed, got %+v, %v", decision, err)
+	}
+	if decision.Quotas[0].Used != 2 || decision.Quotas[1].Used != 400 {
+		t.Errorf("Expected the counts of both engines, got %+v", decision.Quotas)
+	}
+
+	key := "quota:acme:day:" + day.Format("2006-01-02")
+	if s.HGet(key, "requests") != "2" || s.HGet(key, "tokens") != "400" {
+		t.Errorf("Unexpected counts in Redis: %s requests, %s tokens", s.HGet(key, "requests"), s.HGet(key, "tokens"))
+	}
+	if ttl := s.TTL(key); ttl <= 0 {
+		t.Errorf("Expected the counts to expire, got TTL %v", ttl)
+	}
+
+	s.Close()
+	if _, err := a.Allow(ctx, "acme"); err == nil {
+		t.Error("Expected error when Redis is down")
+	}
+}
+
+func TestDecisionResponse(t *testing.T) {
+	engine := NewEngine(Options{Default: Limits{DailyTokens: 10, MonthlyRequests: 5}})
+	ctx := context.Background()
+	engine.AddTokens(ctx, "acme", 12)
+	decision, err := engine.Allow(ctx, "acme")
+	if err != nil || decision.Allowed {
+		t.Fatalf("Expected the request to be rejected, got %+v, %v", decision, err)
+	}
+
+	rec := httptest.NewRecorder()
+	decision.SetHeaders(rec.Header())
+	decision.WriteRejection(rec)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429, got %d", rec.Code)
+	}
+	headers := map[string]string{
+		"X-Quota-Daily-Tokens-Limit":         "10",
+		"X-Quota-Daily-Tokens-Remaining":     "0",
+		"X-Quota-Monthly-Requests-Remaining": "5",
+	}
+	for name, value := range headers {
+		if got := rec.Header().Get(name); got != value {
+			t.Errorf("Expected %s to be %s, got %s", name, value, got)
+		}
+	}
+	if reset := rec.Header().Get("X-Quota-Daily-Tokens-Reset"); reset == "" || reset != rec.Header().Get("Retry-After") {
+		t.Errorf("Expected Retry-After to be the reset of the exceeded quota, got %s and %s", rec.Header().Get("Retry-After"), reset)
+	}
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode rejection: %v", err)
+	}
+	if body["error"] != "daily token quota exceeded" || body["limit"] != float64(10) || body["used"] != float64(12) {
+		t.Errorf("Unexpected rejection %v", body)
+	}
+}
@@ -0,0 +1,125 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Counts are the requests and tokens counted in a period.
+type Counts struct {
+	Requests int64
+	Tokens   int64
+}
+
+// Increment adds to the counts under Key.
+type Increment struct {
+	Key      string
+	Requests int64
+	Tokens   int64
+
+	// Expires is when the counts under Key are removed, starting over from zero.
+	Expires time.Time
+}
+
+// Store keeps the counts of an Engine.
+type Store interface {
+	// Add applies the increments and returns the resulting counts, in the same order.
+	Add(ctx context.Context, increments []Increment) ([]Counts, error)
+}
+
+// MemoryStore keeps counts in memory, for a single gateway. It is safe for concurrent
+// use.
+type MemoryStore struct {
+	mu        sync.Mutex
+	counts    map[string]*memoryCounts
+	lastSweep time.Time
+}
+
+// memoryCounts are the counts under a key and when they expire.
+type memoryCounts struct {
+	Counts
+	expires time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counts: make(map[string]*memoryCounts), lastSweep: time.Now()}
+}
+
+// Add applies the increments and returns the resulting counts.
+func (s *MemoryStore) Add(ctx context.Context, increments []Increment) ([]Counts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) >= time.Hour {
+		for key, c := range s.counts {
+			if !now.Before(c.expires) {
+				delete(s.counts, key)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	result := make([]Counts, len(increments))
+	for i, inc := range increments {
+		c, ok := s.counts[inc.Key]
+		if !ok || !now.Before(c.expires) {
+			c = &memoryCounts{}
+			s.counts[inc.Key] = c
+		}
+		c.Requests += inc.Requests
+		c.Tokens += inc.Tokens
+		c.expires = inc.Expires
+		result[i] = c.Counts
+	}
+	return result, nil
+}
+
+// RedisStore keeps counts in Redis, so every gateway using the same Redis shares them.
+// Each key is a hash of requests and tokens, incremented atomically by Redis.
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisStore creates a RedisStore on client, a single Redis server, Sentinel
+// deployment, or Redis Cluster. Keys are prefixed with namespace and a colon, unless
+// namespace is empty.
+func NewRedisStore(client redis.UniversalClient, namespace string) *RedisStore {
+	s := &RedisStore{client: client}
+	if namespace != "" {
+		s.prefix = namespace + ":"
+	}
+	return s
+}
+
+// Add applies the increments in a single pipelined round trip and returns the resulting
+// counts.
+func (s *RedisStore) Add(ctx context.Context, increments []Increment) ([]Counts, error) {
+	requests := make([]*redis.IntCmd, len(increments))
+	tokens := make([]*redis.IntCmd, len(increments))
+	// Pipelined per key rather than a script, as a cluster rejects scripts across slots
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, inc := range increments {
+			key := s.prefix + inc.Key
+			requests[i] = pipe.HIncrBy(ctx, key, "requests", inc.Requests)
+			tokens[i] = pipe.HIncrBy(ctx, key, "tokens", inc.Tokens)
+			pipe.ExpireAt(ctx, key, inc.Expires)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update quota counts: %w", err)
+	}
+
+	result := make([]Counts, len(increments))
+	for i := range increments {
+		result[i] = Counts{Requests: requests[i].Val(), Tokens: tokens[i].Val()}
+	}
+	return result, nil
+}
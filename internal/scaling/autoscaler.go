@@ -1,108 +1,478 @@
-package autoscaler
-
-import (
-	"fmt"
-	"runtime"
-	"sync"
-	"time"
-)
-
-// WorkerFunc represents the function that each worker will execute
-type WorkerFunc func() error
-
-// AutoScaler manages a worker pool that scales based on system load
-type AutoScaler struct {
-	workerPool        chan struct{}
-	minWorkers        int
-	maxWorkers        int
-	cpuThreshold      float64
-	scaleUpInterval   time.Duration
-	scaleDownInterval time.Duration
-	wg                sync.WaitGroup
-	stopChan          chan struct{}
-}
-
-// NewAutoScaler initializes a new AutoScaler with the specified parameters
-func NewAutoScaler(minWorkers, maxWorkers int, cpuThreshold float64, scaleUpInterval, scaleDownInterval time.Duration) *AutoScaler {
-	as := &AutoScaler{
-		workerPool:        make(chan struct{}, maxWorkers),
-		minWorkers:        minWorkers,
-		maxWorkers:        maxWorkers,
-		cpuThreshold:      cpuThreshold,
-		scaleUpInterval:   scaleUpInterval,
-		scaleDownInterval: scaleDownInterval,
-		stopChan:          make(chan struct{}),
-	}
-
-	for i := 0; i < minWorkers; i++ {
-		as.workerPool <- struct{}{}
-	}
-
-	return as
-}
-
-// Start begins monitoring system load and scaling workers accordingly
-func (as *AutoScaler) Start() {
-	go as.monitorLoad()
-}
-
-// monitorLoad periodically checks CPU usage and scales workers up or down
-func (as *AutoScaler) monitorLoad() {
-	for {
-		select {
-		case <-as.stopChan:
-			return
-		default:
-			cpuUsage := getCPUUsage()
-			currentWorkers := len(as.workerPool)
-
-			if cpuUsage > as.cpuThreshold && currentWorkers < as.maxWorkers {
-				as.scaleUp()
-			} else if cpuUsage < as.cpuThreshold && currentWorkers > as.minWorkers {
-				as.scaleDown()
-			}
-
-			time.Sleep(2 * time.Second)
-		}
-	}
-}
-
-// scaleUp adds workers up to the maximum limit
-func (as *AutoScaler) scaleUp() {
-	as.wg.Add(1)
-	defer as.wg.Done()
-
-	select {
-	case as.workerPool <- struct{}{}:
-		fmt.Println("Scaled up, current workers:", len(as.workerPool))
-	case <-time.After(as.scaleUpInterval):
-		fmt.Println("Scale-up timed out")
-	}
-}
-
-// scaleDown removes a worker down to the minimum limit
-func (as *AutoScaler) scaleDown() {
-	as.wg.Add(1)
-	defer as.wg.Done()
-
-	select {
-	case <-as.workerPool:
-		fmt.Println("Scaled down, current workers:", len(as.workerPool))
-	case <-time.After(as.scaleDownInterval):
-		fmt.Println("Scale-down timed out")
-	}
-}
-
-// Stop stops the autoscaler
-func (as *AutoScaler) Stop() {
-	close(as.stopChan)
-	as.wg.Wait()
-}
-
-// getCPUUsage simulates CPU usage checking (customize for real usage)
-func getCPUUsage() float64 {
-	// Simulate CPU usage (in production, replace with real monitoring)
-	cpuUsage := float64(runtime.NumGoroutine()) / float64(runtime.NumCPU())
-	fmt.Printf("CPU Usage: %.2f\n", cpuUsage*100)
-	return cpuUsage
-}
+package autoscaler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/h2co32/gollama/internal/metrics"
+	"github.com/h2co32/gollama/pkg/logging"
+)
+
+// WorkerFunc represents the function that each worker will execute
+type WorkerFunc func() error
+
+var (
+	// ErrNoWorkers is returned by Submit with AdmissionReject when every worker is busy.
+	ErrNoWorkers = errors.New("no idle workers")
+
+	// ErrStopped is returned by Submit once the autoscaler has been stopped.
+	ErrStopped = errors.New("autoscaler stopped")
+)
+
+// Admission controls what Submit does when every worker is busy
+type Admission int
+
+const (
+	// AdmissionBlock waits for a worker to become idle or the pool to grow.
+	AdmissionBlock Admission = iota
+
+	// AdmissionReject returns ErrNoWorkers right away.
+	AdmissionReject
+)
+
+// Options configures an AutoScaler
+type Options struct {
+	// MinWorkers is the smallest size of the worker pool.
+	// Default: 1
+	MinWorkers int
+
+	// MaxWorkers is the largest size of the worker pool.
+	// Default: the number of CPUs
+	MaxWorkers int
+
+	// CPUThreshold is the CPU usage above which the default policy adds a worker and
+	// below which it removes one. Ignored when Policy is set.
+	// Default: 0.75
+	CPUThreshold float64
+
+	// ScaleUpInterval is how long adding a worker, or scaling up Target, may block
+	// before it is abandoned.
+	// Default: 2 seconds
+	ScaleUpInterval time.Duration
+
+	// ScaleDownInterval is how long removing a worker, or scaling down Target, may
+	// block before it is abandoned.
+	// Default: 2 seconds
+	ScaleDownInterval time.Duration
+
+	// CheckInterval is how often the signals are read and the policy applied.
+	// Default: 2 seconds
+	CheckInterval time.Duration
+
+	// Source provides the host metrics passed to Policy as SignalCPU, SignalMemory,
+	// and SignalLoadAverage. If a reading fails, they are left out of that check.
+	// Default: NewProcSource(), which reads /proc on Linux
+	Source MetricsSource
+
+	// Signals are further measures of load passed to Policy, keyed by name, such as
+	// QueueDepthSignal under SignalQueueDepth. Optional.
+	Signals map[string]Signal
+
+	// Policy decides the number of workers from the signals.
+	// Default: ThresholdPolicy with CPUThreshold for SignalCPU
+	Policy Policy
+
+	// Admission controls what Submit does when every worker is busy.
+	// Default: AdmissionBlock
+	Admission Admission
+
+	// OnScaleUp is called after the pool grows. Hooks are called from the
+	// autoscaler's goroutine and should return quickly. Optional.
+	OnScaleUp func(event ScaleEvent)
+
+	// OnScaleDown is called after the pool shrinks. Optional.
+	OnScaleDown func(event ScaleEvent)
+
+	// OnAtMax is called on every check where the policy asks for more than
+	// MaxWorkers and the pool is already at its maximum. Optional.
+	OnAtMax func(event ScaleEvent)
+
+	// OnError is called when the host metrics cannot be read, or Target cannot be
+	// read or resized. Optional. Without it, the errors are logged to the default
+	// logger of pkg/logging.
+	OnError func(err error)
+
+	// Target is resized instead of the in-process worker pool, for example a
+	// KubernetesTarget or WebhookTarget, making the autoscaler a controller for
+	// external replicas. Its current size is read on every check, and failures to
+	// read or resize it are reported to OnError. Submit still uses the worker pool,
+	// which stays at MinWorkers. Optional.
+	Target ScaleTarget
+
+	// Name identifies the autoscaler in the metrics.
+	// Default: "default"
+	Name string
+
+	// Metrics records the pool size, the desired size, and the reason for the last
+	// decision. Optional.
+	Metrics *metrics.MetricsProvider
+}
+
+// DefaultOptions returns the default autoscaler options
+func DefaultOptions() Options {
+	return Options{
+		MinWorkers:        1,
+		MaxWorkers:        runtime.NumCPU(),
+		CPUThreshold:      0.75,
+		ScaleUpInterval:   2 * time.Second,
+		ScaleDownInterval: 2 * time.Second,
+		CheckInterval:     2 * time.Second,
+		Name:              "default",
+	}
+}
+
+// AutoScaler manages a worker pool that scales based on system load
+type AutoScaler struct {
+	workerPool        chan struct{}
+	minWorkers        int
+	maxWorkers        int
+	cpuThreshold      float64
+	scaleUpInterval   time.Duration
+	scaleDownInterval time.Duration
+	checkInterval     time.Duration
+	source            MetricsSource
+	signals           map[string]Signal
+	policy            Policy
+	admission         Admission
+	onScaleUp         func(ScaleEvent)
+	onScaleDown       func(ScaleEvent)
+	onAtMax           func(ScaleEvent)
+	onError           func(error)
+	target            ScaleTarget
+	name              string
+	metrics           *metrics.MetricsProvider
+	wg                sync.WaitGroup
+	stopChan          chan struct{}
+	stopOnce          sync.Once
+	ctx               context.Context // Canceled by Stop, for requests to the target
+	cancel            context.CancelFunc
+
+	mu       sync.Mutex
+	inFlight int           // Functions running in Submit
+	changed  chan struct{} // Closed when a worker becomes idle or the pool grows
+}
+
+// NewAutoScaler initializes a new AutoScaler with the specified parameters
+func NewAutoScaler(minWorkers, maxWorkers int, cpuThreshold float64, scaleUpInterval, scaleDownInterval time.Duration) *AutoScaler {
+	options := DefaultOptions()
+	options.MinWorkers = minWorkers
+	options.MaxWorkers = maxWorkers
+	options.CPUThreshold = cpuThreshold
+	options.ScaleUpInterval = scaleUpInterval
+	options.ScaleDownInterval = scaleDownInterval
+	return NewAutoScalerWithOptions(options)
+}
+
+// NewAutoScalerWithOptions initializes a new AutoScaler with the given options.
+// Zero option values fall back to the defaults.
+func NewAutoScalerWithOptions(options Options) *AutoScaler {
+	defaults := DefaultOptions()
+	if options.MinWorkers <= 0 {
+		options.MinWorkers = defaults.MinWorkers
+	}
+	if options.MaxWorkers <= 0 {
+		options.MaxWorkers = max(defaults.MaxWorkers, options.MinWorkers)
+	}
+	if options.CPUThreshold <= 0 {
+		options.CPUThreshold = defaults.CPUThreshold
+	}
+	if options.ScaleUpInterval <= 0 {
+		options.ScaleUpInterval = defaults.ScaleUpInterval
+	}
+	if options.ScaleDownInterval <= 0 {
+		options.ScaleDownInterval = defaults.ScaleDownInterval
+	}
+	if options.CheckInterval <= 0 {
+		options.CheckInterval = defaults.CheckInterval
+	}
+	if options.Name == "" {
+		options.Name = defaults.Name
+	}
+	if options.Source == nil {
+		options.Source = NewProcSource()
+	}
+	if options.Policy == nil {
+		options.Policy = ThresholdPolicy(map[string]float64{SignalCPU: options.CPUThreshold})
+	}
+
+	as := &AutoScaler{
+		workerPool:        make(chan struct{}, options.MaxWorkers),
+		minWorkers:        options.MinWorkers,
+		maxWorkers:        options.MaxWorkers,
+		cpuThreshold:      options.CPUThreshold,
+		scaleUpInterval:   options.ScaleUpInterval,
+		scaleDownInterval: options.ScaleDownInterval,
+		checkInterval:     options.CheckInterval,
+		source:            options.Source,
+		signals:           options.Signals,
+		policy:            options.Policy,
+		admission:         options.Admission,
+		onScaleUp:         options.OnScaleUp,
+		onScaleDown:       options.OnScaleDown,
+		onAtMax:           options.OnAtMax,
+		onError:           options.OnError,
+		target:            options.Target,
+		name:              options.Name,
+		metrics:           options.Metrics,
+		stopChan:          make(chan struct{}),
+		changed:           make(chan struct{}),
+	}
+
+	as.ctx, as.cancel = context.WithCancel(context.Background())
+
+	for i := 0; i < options.MinWorkers; i++ {
+		as.workerPool <- struct{}{}
+	}
+
+	return as
+}
+
+// Submit runs fn on one of the pool's workers and returns its error. Each worker runs
+// one function at a time, so at most Workers functions run at once. When every worker
+// is busy, Submit waits for one to become idle or, with AdmissionReject, returns
+// ErrNoWorkers. It returns ctx's error if ctx is done first, and ErrStopped once the
+// autoscaler has been stopped.
+func (as *AutoScaler) Submit(ctx context.Context, fn WorkerFunc) error {
+	if err := as.acquire(ctx); err != nil {
+		return err
+	}
+	defer as.release()
+	return fn()
+}
+
+// acquire waits for an idle worker and marks it busy
+func (as *AutoScaler) acquire(ctx context.Context) error {
+	for {
+		select {
+		case <-as.stopChan:
+			return ErrStopped
+		default:
+		}
+
+		as.mu.Lock()
+		if as.inFlight < len(as.workerPool) {
+			as.inFlight++
+			as.mu.Unlock()
+			return nil
+		}
+		changed := as.changed
+		as.mu.Unlock()
+
+		if as.admission == AdmissionReject {
+			return ErrNoWorkers
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-as.stopChan:
+			return ErrStopped
+		}
+	}
+}
+
+// release marks a worker idle
+func (as *AutoScaler) release() {
+	as.mu.Lock()
+	as.inFlight--
+	as.mu.Unlock()
+	as.notify()
+}
+
+// notify wakes the callers of Submit waiting for a worker
+func (as *AutoScaler) notify() {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	close(as.changed)
+	as.changed = make(chan struct{})
+}
+
+// InFlight returns the number of functions running in Submit
+func (as *AutoScaler) InFlight() int {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	return as.inFlight
+}
+
+// Workers returns the current size of the worker pool
+func (as *AutoScaler) Workers() int {
+	return len(as.workerPool)
+}
+
+// Start begins monitoring system load and scaling workers accordingly
+func (as *AutoScaler) Start() {
+	go as.monitorLoad()
+}
+
+// monitorLoad periodically reads the signals and scales workers up or down to the
+// number the policy asks for
+func (as *AutoScaler) monitorLoad() {
+	for {
+		select {
+		case <-as.stopChan:
+			return
+		default:
+			as.check()
+
+			select {
+			case <-as.stopChan:
+				return
+			case <-time.After(as.checkInterval):
+			}
+		}
+	}
+}
+
+// check reads the signals once, moves the pool toward the desired size, and reports
+// the decision
+func (as *AutoScaler) check() {
+	load := make(map[string]float64, len(as.signals)+3)
+	if host, err := as.source.Read(); err != nil {
+		as.reportError(err)
+	} else {
+		load[SignalCPU] = host.CPU
+		load[SignalMemory] = host.Memory
+		load[SignalLoadAverage] = host.LoadAverage
+	}
+	for name, signal := range as.signals {
+		load[name] = signal()
+	}
+
+	from, err := as.size()
+	if err != nil {
+		as.reportError(err)
+		return
+	}
+	desired := max(as.policy(from, load), 0)
+	event := ScaleEvent{
+		From:    from,
+		To:      as.resize(from, min(max(desired, as.minWorkers), as.maxWorkers)),
+		Desired: desired,
+		Load:    load,
+	}
+	switch {
+	case event.To > from:
+		event.Reason = ReasonScaleUp
+		logging.Default().Info("scaling up", "autoscaler", as.name, "from", from, "to", event.To, "desired", desired)
+		if as.onScaleUp != nil {
+			as.onScaleUp(event)
+		}
+	case event.To < from:
+		event.Reason = ReasonScaleDown
+		logging.Default().Info("scaling down", "autoscaler", as.name, "from", from, "to", event.To, "desired", desired)
+		if as.onScaleDown != nil {
+			as.onScaleDown(event)
+		}
+	case desired > as.maxWorkers:
+		event.Reason = ReasonAtMax
+	case desired < as.minWorkers:
+		event.Reason = ReasonAtMin
+	default:
+		event.Reason = ReasonSteady
+	}
+	if desired > as.maxWorkers && event.To == as.maxWorkers && as.onAtMax != nil {
+		as.onAtMax(event)
+	}
+	if as.metrics != nil {
+		as.metrics.SetAutoscalerState(as.name, event.To, desired, event.Reason)
+	}
+}
+
+// reportError passes err to OnError, or logs it if OnError is not set
+func (as *AutoScaler) reportError(err error) {
+	if as.onError != nil {
+		as.onError(err)
+		return
+	}
+	logging.Default().Warn("autoscaler check failed", "autoscaler", as.name, "error", err)
+}
+
+// size returns the current number of workers, or of the target's replicas
+func (as *AutoScaler) size() (int, error) {
+	if as.target == nil {
+		return len(as.workerPool), nil
+	}
+	ctx, cancel := context.WithTimeout(as.ctx, as.checkInterval)
+	defer cancel()
+	current, err := as.target.Current(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read scale target size: %w", err)
+	}
+	return current, nil
+}
+
+// resize moves the worker pool, or the target, from its current size toward size and
+// returns the size it reached
+func (as *AutoScaler) resize(from, size int) int {
+	if as.target == nil {
+		for n := from; n < size; n++ {
+			if !as.scaleUp() {
+				break
+			}
+		}
+		for n := from; n > size; n-- {
+			if !as.scaleDown() {
+				break
+			}
+		}
+		return len(as.workerPool)
+	}
+
+	if size == from {
+		return from
+	}
+	timeout := as.scaleUpInterval
+	if size < from {
+		timeout = as.scaleDownInterval
+	}
+	ctx, cancel := context.WithTimeout(as.ctx, timeout)
+	defer cancel()
+	if err := as.target.Scale(ctx, size); err != nil {
+		as.reportError(fmt.Errorf("failed to scale target to %d: %w", size, err))
+		return from
+	}
+	return size
+}
+
+// scaleUp adds a worker up to the maximum limit and reports whether it did
+func (as *AutoScaler) scaleUp() bool {
+	as.wg.Add(1)
+	defer as.wg.Done()
+
+	select {
+	case as.workerPool <- struct{}{}:
+		as.notify()
+		return true
+	case <-time.After(as.scaleUpInterval):
+		return false
+	}
+}
+
+// scaleDown removes a worker down to the minimum limit and reports whether it did.
+// Functions already running in Submit finish, and new ones start once fewer are in
+// flight than there are workers.
+func (as *AutoScaler) scaleDown() bool {
+	as.wg.Add(1)
+	defer as.wg.Done()
+
+	select {
+	case <-as.workerPool:
+		return true
+	case <-time.After(as.scaleDownInterval):
+		return false
+	}
+}
+
+// Stop stops the autoscaler. Functions already running in Submit are not interrupted,
+// and later calls to Submit return ErrStopped.
+func (as *AutoScaler) Stop() {
+	as.stopOnce.Do(func() {
+		close(as.stopChan)
+		as.cancel()
+	})
+	as.wg.Wait()
+}
@@ -1,102 +1,31 @@
 package autoscaler
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
 	"sync"
 	"testing"
 	"time"
-)
-
-// TestAutoScaler is a modified version of AutoScaler for testing
-type TestAutoScaler struct {
-	workerPool        chan struct{}
-	minWorkers        int
-	maxWorkers        int
-	cpuThreshold      float64
-	scaleUpInterval   time.Duration
-	scaleDownInterval time.Duration
-	wg                sync.WaitGroup
-	stopChan          chan struct{}
-	cpuUsageFunc      func() float64 // Custom CPU usage function for testing
-}
-
-// NewTestAutoScaler creates a test autoscaler with a custom CPU usage function
-func NewTestAutoScaler(minWorkers, maxWorkers int, cpuThreshold float64, 
-	scaleUpInterval, scaleDownInterval time.Duration, cpuUsageFunc func() float64) *TestAutoScaler {
-	
-	as := &TestAutoScaler{
-		workerPool:        make(chan struct{}, maxWorkers),
-		minWorkers:        minWorkers,
-		maxWorkers:        maxWorkers,
-		cpuThreshold:      cpuThreshold,
-		scaleUpInterval:   scaleUpInterval,
-		scaleDownInterval: scaleDownInterval,
-		stopChan:          make(chan struct{}),
-		cpuUsageFunc:      cpuUsageFunc,
-	}
-
-	for i := 0; i < minWorkers; i++ {
-		as.workerPool <- struct{}{}
-	}
-
-	return as
-}
 
-// Start begins monitoring system load and scaling workers accordingly
-func (as *TestAutoScaler) Start() {
-	go as.monitorLoad()
-}
-
-// monitorLoad periodically checks CPU usage and scales workers up or down
-func (as *TestAutoScaler) monitorLoad() {
-	for {
-		select {
-		case <-as.stopChan:
-			return
-		default:
-			cpuUsage := as.cpuUsageFunc() // Use the custom function
-			currentWorkers := len(as.workerPool)
-
-			if cpuUsage > as.cpuThreshold && currentWorkers < as.maxWorkers {
-				as.scaleUp()
-			} else if cpuUsage < as.cpuThreshold && currentWorkers > as.minWorkers {
-				as.scaleDown()
-			}
-
-			time.Sleep(100 * time.Millisecond) // Shorter sleep for testing
-		}
-	}
-}
-
-// scaleUp adds workers up to the maximum limit
-func (as *TestAutoScaler) scaleUp() {
-	as.wg.Add(1)
-	defer as.wg.Done()
-
-	select {
-	case as.workerPool <- struct{}{}:
-		// Scaled up successfully
-	case <-time.After(as.scaleUpInterval):
-		// Scale-up timed out
-	}
-}
-
-// scaleDown removes a worker down to the minimum limit
-func (as *TestAutoScaler) scaleDown() {
-	as.wg.Add(1)
-	defer as.wg.Done()
+	"github.com/h2co32/gollama/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
 
-	select {
-	case <-as.workerPool:
-		// Scaled down successfully
-	case <-time.After(as.scaleDownInterval):
-		// Scale-down timed out
-	}
+// fakeSource is a MetricsSource returning fixed readings
+type fakeSource struct {
+	mu      sync.Mutex
+	metrics HostMetrics
+	err     error
 }
 
-// Stop stops the autoscaler
-func (as *TestAutoScaler) Stop() {
-	close(as.stopChan)
-	as.wg.Wait()
+func (s *fakeSource) Read() (HostMetrics, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.metrics, s.err
 }
 
 func TestNewAutoScaler(t *testing.T) {
@@ -244,12 +173,18 @@ func TestStartStop(t *testing.T) {
 func TestMonitorLoad(t *testing.T) {
 	minWorkers := 2
 	maxWorkers := 5
-	cpuThreshold := 0.7
-	
+	options := Options{
+		MinWorkers:        minWorkers,
+		MaxWorkers:        maxWorkers,
+		CPUThreshold:      0.7,
+		ScaleUpInterval:   100 * time.Millisecond,
+		ScaleDownInterval: 100 * time.Millisecond,
+		CheckInterval:     100 * time.Millisecond,
+	}
+
 	// Test with high CPU usage (above threshold)
-	highCPUUsage := func() float64 { return 0.9 }
-	as := NewTestAutoScaler(minWorkers, maxWorkers, cpuThreshold, 
-		100*time.Millisecond, 100*time.Millisecond, highCPUUsage)
+	options.Source = &fakeSource{metrics: HostMetrics{CPU: 0.9}}
+	as := NewAutoScalerWithOptions(options)
 
 	// Start the autoscaler
 	as.Start()
@@ -267,9 +202,8 @@ func TestMonitorLoad(t *testing.T) {
 	}
 
 	// Test with low CPU usage (below threshold)
-	lowCPUUsage := func() float64 { return 0.5 }
-	as = NewTestAutoScaler(minWorkers, maxWorkers, cpuThreshold, 
-		100*time.Millisecond, 100*time.Millisecond, lowCPUUsage)
+	options.Source = &fakeSource{metrics: HostMetrics{CPU: 0.5}}
+	as = NewAutoScalerWithOptions(options)
 
 	// Fill the worker pool to max
 	for len(as.workerPool) < maxWorkers {
@@ -292,6 +226,67 @@ func TestMonitorLoad(t *testing.T) {
 	}
 }
 
+func TestSourceError(t *testing.T) {
+	source := &fakeSource{err: errors.New("unavailable")}
+	as := NewAutoScalerWithOptions(Options{MinWorkers: 1, MaxWorkers: 3, Source: source})
+
+	// Without readings the default policy holds the pool steady
+	as.check()
+	if len(as.workerPool) != 1 {
+		t.Errorf("Expected 1 worker, got %d", len(as.workerPool))
+	}
+
+	source.mu.Lock()
+	source.metrics, source.err = HostMetrics{CPU: 0.9}, nil
+	source.mu.Unlock()
+	as.check()
+	if len(as.workerPool) != 2 {
+		t.Errorf("Expected 2 workers, got %d", len(as.workerPool))
+	}
+}
+
+func TestProcSource(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("stat", "cpu  100 0 100 700 100 0 0 0 0 0\ncpu0 100 0 100 700 100 0 0 0 0 0\n")
+	write("meminfo", "MemTotal:       1000 kB\nMemFree:         100 kB\nMemAvailable:    250 kB\n")
+	write("loadavg", fmt.Sprintf("%d 0.50 0.25 1/100 1234\n", 2*runtime.NumCPU()))
+
+	source := &ProcSource{root: root}
+	metrics, err := source.Read()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if metrics.CPU != 0.2 {
+		t.Errorf("Expected CPU usage since boot of 0.2, got %v", metrics.CPU)
+	}
+	if metrics.Memory != 0.75 {
+		t.Errorf("Expected memory usage of 0.75, got %v", metrics.Memory)
+	}
+	if metrics.LoadAverage != 2 {
+		t.Errorf("Expected load average per CPU of 2, got %v", metrics.LoadAverage)
+	}
+
+	// Later readings cover the time since the previous one: 150 busy of 200
+	write("stat", "cpu  200 0 150 725 125 0 0 0 0 0\n")
+	metrics, err = source.Read()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if metrics.CPU != 0.75 {
+		t.Errorf("Expected CPU usage of 0.75, got %v", metrics.CPU)
+	}
+
+	os.Remove(filepath.Join(root, "loadavg"))
+	if _, err := source.Read(); err == nil {
+		t.Error("Expected an error when /proc/loadavg is missing")
+	}
+}
+
 func TestConcurrentScaling(t *testing.T) {
 	minWorkers := 2
 	maxWorkers := 10
@@ -327,3 +322,265 @@ func TestConcurrentScaling(t *testing.T) {
 		t.Errorf("Expected worker count to be between %d and %d, got %d", minWorkers, maxWorkers, workers)
 	}
 }
+
+func TestThresholdPolicy(t *testing.T) {
+	policy := ThresholdPolicy(map[string]float64{SignalCPU: 0.7, SignalInFlight: 10})
+
+	tests := []struct {
+		load     map[string]float64
+		expected int
+	}{
+		{map[string]float64{SignalCPU: 0.9, SignalInFlight: 2}, 4},  // Any signal above scales up
+		{map[string]float64{SignalCPU: 0.5, SignalInFlight: 2}, 2},  // All signals below scale down
+		{map[string]float64{SignalCPU: 0.5, SignalInFlight: 10}, 3}, // At a threshold holds
+		{map[string]float64{SignalQueueDepth: 100}, 3},              // Signals without a threshold are ignored
+	}
+	for _, tt := range tests {
+		if got := policy(3, tt.load); got != tt.expected {
+			t.Errorf("Expected %d workers for %v, got %d", tt.expected, tt.load, got)
+		}
+	}
+}
+
+func TestTargetPolicy(t *testing.T) {
+	policy := TargetPolicy(map[string]float64{SignalQueueDepth: 4, SignalInFlight: 2})
+
+	if got := policy(1, map[string]float64{SignalQueueDepth: 9, SignalInFlight: 2}); got != 3 {
+		t.Errorf("Expected 3 workers, got %d", got)
+	}
+	if got := policy(5, map[string]float64{SignalQueueDepth: 0, SignalInFlight: 7}); got != 4 {
+		t.Errorf("Expected 4 workers, got %d", got)
+	}
+}
+
+func TestScalingOnSignals(t *testing.T) {
+	var mu sync.Mutex
+	depth := 0.0
+	setDepth := func(d float64) {
+		mu.Lock()
+		depth = d
+		mu.Unlock()
+	}
+
+	as := NewAutoScalerWithOptions(Options{
+		MinWorkers:        1,
+		MaxWorkers:        5,
+		ScaleUpInterval:   100 * time.Millisecond,
+		ScaleDownInterval: 100 * time.Millisecond,
+		CheckInterval:     10 * time.Millisecond,
+		Source:            &fakeSource{},
+		Signals: map[string]Signal{
+			SignalQueueDepth: func() float64 {
+				mu.Lock()
+				defer mu.Unlock()
+				return depth
+			},
+		},
+		Policy: TargetPolicy(map[string]float64{SignalQueueDepth: 2}),
+	})
+
+	setDepth(6)
+	as.check()
+	if len(as.workerPool) != 3 {
+		t.Errorf("Expected 3 workers for 6 queued jobs, got %d", len(as.workerPool))
+	}
+
+	// The policy is clamped to the maximum
+	setDepth(100)
+	as.check()
+	if len(as.workerPool) != 5 {
+		t.Errorf("Expected the maximum of 5 workers, got %d", len(as.workerPool))
+	}
+
+	// And to the minimum, by the background loop
+	setDepth(0)
+	as.Start()
+	time.Sleep(100 * time.Millisecond)
+	as.Stop()
+	if len(as.workerPool) != 1 {
+		t.Errorf("Expected the minimum of 1 worker, got %d", len(as.workerPool))
+	}
+}
+
+func TestSubmit(t *testing.T) {
+	as := NewAutoScalerWithOptions(Options{MinWorkers: 2, MaxWorkers: 3, Source: &fakeSource{}})
+	defer as.Stop()
+
+	jobErr := errors.New("job failed")
+	if err := as.Submit(context.Background(), func() error { return jobErr }); err != jobErr {
+		t.Errorf("Expected the function's error, got %v", err)
+	}
+
+	// Only as many functions run at once as there are workers
+	release := make(chan struct{})
+	var running, maxRunning int
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			as.Submit(context.Background(), func() error {
+				mu.Lock()
+				running++
+				maxRunning = max(maxRunning, running)
+				mu.Unlock()
+				<-release
+				mu.Lock()
+				running--
+				mu.Unlock()
+				return nil
+			})
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if as.InFlight() != 2 {
+		t.Errorf("Expected 2 functions in flight, got %d", as.InFlight())
+	}
+
+	// Growing the pool admits a waiting function
+	as.scaleUp()
+	time.Sleep(50 * time.Millisecond)
+	if as.InFlight() != 3 {
+		t.Errorf("Expected 3 functions in flight after scaling up, got %d", as.InFlight())
+	}
+
+	close(release)
+	wg.Wait()
+	if maxRunning != 3 {
+		t.Errorf("Expected at most 3 functions to run at once, got %d", maxRunning)
+	}
+	if as.InFlight() != 0 {
+		t.Errorf("Expected no functions in flight, got %d", as.InFlight())
+	}
+}
+
+func TestSubmitAdmission(t *testing.T) {
+	as := NewAutoScalerWithOptions(Options{MinWorkers: 1, MaxWorkers: 1, Source: &fakeSource{}, Admission: AdmissionReject})
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go as.Submit(context.Background(), func() error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+
+	if err := as.Submit(context.Background(), func() error { return nil }); err != ErrNoWorkers {
+		t.Errorf("Expected ErrNoWorkers, got %v", err)
+	}
+
+	// Blocking admission gives up when the context is done
+	as.admission = AdmissionBlock
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := as.Submit(ctx, func() error { return nil }); err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	// Or when the autoscaler stops
+	errs := make(chan error)
+	go func() { errs <- as.Submit(context.Background(), func() error { return nil }) }()
+	time.Sleep(20 * time.Millisecond)
+	as.Stop()
+	if err := <-errs; err != ErrStopped {
+		t.Errorf("Expected ErrStopped, got %v", err)
+	}
+	close(release)
+}
+
+// gaugeValue returns the value of the gauge with the given name and labels, or -1 if
+// it has not been set
+func gaugeValue(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if value, ok := labels[label.GetName()]; ok && value != label.GetValue() {
+					continue metrics
+				}
+			}
+			return metric.GetGauge().GetValue()
+		}
+	}
+	return -1
+}
+
+func TestScaleEvents(t *testing.T) {
+	source := &fakeSource{}
+	setCPU := func(cpu float64, err error) {
+		source.mu.Lock()
+		source.metrics, source.err = HostMetrics{CPU: cpu}, err
+		source.mu.Unlock()
+	}
+
+	var events []ScaleEvent
+	var errs []error
+	record := func(event ScaleEvent) { events = append(events, event) }
+	as := NewAutoScalerWithOptions(Options{
+		MinWorkers:  1,
+		MaxWorkers:  2,
+		Source:      source,
+		Name:        "events",
+		Metrics:     metrics.NewMetricsProvider(),
+		OnScaleUp:   record,
+		OnScaleDown: record,
+		OnAtMax:     record,
+		OnError:     func(err error) { errs = append(errs, err) },
+	})
+
+	setCPU(0.9, nil)
+	as.check() // Scales up to the maximum
+	as.check() // Asks for more at the maximum
+	setCPU(0.1, nil)
+	as.check() // Scales down
+
+	expected := []struct {
+		from, to int
+		reason   string
+	}{
+		{1, 2, ReasonScaleUp},
+		{2, 2, ReasonAtMax},
+		{2, 1, ReasonScaleDown},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, got %+v", len(expected), events)
+	}
+	for i, e := range expected {
+		if events[i].From != e.from || events[i].To != e.to || events[i].Reason != e.reason {
+			t.Errorf("Expected event %d to be %d -> %d (%s), got %+v", i, e.from, e.to, e.reason, events[i])
+		}
+		if events[i].Load[SignalCPU] == 0 {
+			t.Errorf("Expected event %d to include the load, got %+v", i, events[i].Load)
+		}
+	}
+
+	if got := gaugeValue(t, "autoscaler_workers", map[string]string{"autoscaler": "events"}); got != 1 {
+		t.Errorf("Expected autoscaler_workers to be 1, got %v", got)
+	}
+	if got := gaugeValue(t, "autoscaler_desired_workers", map[string]string{"autoscaler": "events"}); got != 1 {
+		t.Errorf("Expected autoscaler_desired_workers to be 1, got %v", got)
+	}
+	if got := gaugeValue(t, "autoscaler_decision", map[string]string{"autoscaler": "events", "reason": ReasonScaleDown}); got != 1 {
+		t.Errorf("Expected the last decision to be scale_down, got %v", got)
+	}
+	if got := gaugeValue(t, "autoscaler_decision", map[string]string{"autoscaler": "events", "reason": ReasonAtMax}); got != 0 {
+		t.Errorf("Expected earlier decisions to be 0, got %v", got)
+	}
+
+	setCPU(0, errors.New("unavailable"))
+	as.check()
+	if len(errs) != 1 {
+		t.Errorf("Expected the read error to be reported, got %v", errs)
+	}
+}
@@ -0,0 +1,30 @@
+package autoscaler
+
+// Reasons for a scaling decision, reported in ScaleEvent and the autoscaler_decision
+// metric.
+const (
+	ReasonScaleUp   = "scale_up"   // The pool grew
+	ReasonScaleDown = "scale_down" // The pool shrank
+	ReasonAtMax     = "at_max"     // The policy asked for more than MaxWorkers
+	ReasonAtMin     = "at_min"     // The policy asked for fewer than MinWorkers
+	ReasonSteady    = "steady"     // The policy asked for the current size
+)
+
+// ScaleEvent describes a scaling decision, passed to the hooks in Options.
+type ScaleEvent struct {
+	// From is the number of workers before the decision.
+	From int
+
+	// To is the number of workers after it.
+	To int
+
+	// Desired is the number of workers the policy asked for, before it was clamped to
+	// MinWorkers and MaxWorkers.
+	Desired int
+
+	// Reason is one of the Reason constants.
+	Reason string
+
+	// Load is the reading of every signal the decision was based on.
+	Load map[string]float64
+}
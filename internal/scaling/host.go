@@ -0,0 +1,153 @@
+package autoscaler
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// HostMetrics is a reading of the host's load.
+type HostMetrics struct {
+	// CPU is the fraction of CPU time spent busy since the previous reading, between
+	// 0 and 1.
+	CPU float64
+
+	// Memory is the fraction of memory in use, between 0 and 1.
+	Memory float64
+
+	// LoadAverage is the one-minute load average divided by the number of CPUs, so 1
+	// means every CPU has a runnable task on average.
+	LoadAverage float64
+}
+
+// MetricsSource reads the host's load. Tests inject their own to control the
+// readings.
+type MetricsSource interface {
+	Read() (HostMetrics, error)
+}
+
+// ProcSource reads host metrics from the /proc filesystem on Linux. On other systems,
+// Read returns an error.
+type ProcSource struct {
+	root string // Mount point of procfs
+
+	mu        sync.Mutex
+	prevBusy  uint64 // CPU time counters from the previous reading
+	prevTotal uint64
+}
+
+// NewProcSource creates a ProcSource reading from /proc.
+func NewProcSource() *ProcSource {
+	return &ProcSource{root: "/proc"}
+}
+
+// Read implements MetricsSource. The first CPU reading covers the time since boot.
+func (s *ProcSource) Read() (HostMetrics, error) {
+	busy, total, err := s.readCPU()
+	if err != nil {
+		return HostMetrics{}, err
+	}
+	memory, err := s.readMemory()
+	if err != nil {
+		return HostMetrics{}, err
+	}
+	load, err := s.readLoadAverage()
+	if err != nil {
+		return HostMetrics{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var cpu float64
+	if total > s.prevTotal {
+		cpu = float64(busy-s.prevBusy) / float64(total-s.prevTotal)
+	}
+	s.prevBusy, s.prevTotal = busy, total
+
+	return HostMetrics{
+		CPU:         cpu,
+		Memory:      memory,
+		LoadAverage: load / float64(runtime.NumCPU()),
+	}, nil
+}
+
+// readCPU returns the busy and total CPU time counters from /proc/stat.
+func (s *ProcSource) readCPU() (busy, total uint64, err error) {
+	data, err := os.ReadFile(filepath.Join(s.root, "stat"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read CPU usage: %w", err)
+	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return 0, 0, fmt.Errorf("failed to parse CPU usage: unexpected line %q", line)
+	}
+
+	// user nice system idle iowait irq softirq steal; guest time is already in user
+	var idle uint64
+	for i, field := range fields[1:min(len(fields), 9)] {
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to parse CPU usage: %w", err)
+		}
+		total += value
+		if i == 3 || i == 4 {
+			idle += value
+		}
+	}
+	return total - idle, total, nil
+}
+
+// readMemory returns the fraction of memory in use from /proc/meminfo.
+func (s *ProcSource) readMemory() (float64, error) {
+	file, err := os.Open(filepath.Join(s.root, "meminfo"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read memory usage: %w", err)
+	}
+	defer file.Close()
+
+	values := make(map[string]float64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || (name != "MemTotal" && name != "MemAvailable") {
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(rest), " kB"), 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse memory usage: %w", err)
+		}
+		values[name] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read memory usage: %w", err)
+	}
+
+	memTotal, available := values["MemTotal"], values["MemAvailable"]
+	if memTotal == 0 {
+		return 0, fmt.Errorf("failed to parse memory usage: MemTotal missing")
+	}
+	return 1 - available/memTotal, nil
+}
+
+// readLoadAverage returns the one-minute load average from /proc/loadavg.
+func (s *ProcSource) readLoadAverage() (float64, error) {
+	data, err := os.ReadFile(filepath.Join(s.root, "loadavg"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read load average: %w", err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("failed to parse load average: empty file")
+	}
+	load, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse load average: %w", err)
+	}
+	return load, nil
+}
@@ -0,0 +1,88 @@
+package autoscaler
+
+import (
+	"math"
+
+	"github.com/h2co32/gollama/internal/metrics"
+	"github.com/h2co32/gollama/internal/queue"
+)
+
+// Names of the built-in signals.
+const (
+	SignalCPU         = "cpu"
+	SignalMemory      = "memory"
+	SignalLoadAverage = "load_average"
+	SignalQueueDepth  = "queue_depth"
+	SignalInFlight    = "in_flight"
+	SignalLatencyP95  = "latency_p95"
+)
+
+// Signal reports a measure of load, such as the number of queued jobs. It is read once
+// per check and must be safe to call from the autoscaler's goroutine.
+type Signal func() float64
+
+// Policy returns the desired number of workers given the current number and the
+// latest reading of every signal, keyed by signal name. The result is clamped to the
+// autoscaler's minimum and maximum.
+type Policy func(workers int, load map[string]float64) int
+
+// QueueDepthSignal reports the number of jobs pending in q.
+func QueueDepthSignal(q *queue.JobQueue) Signal {
+	return func() float64 { return float64(q.Pending()) }
+}
+
+// InFlightSignal reports the number of requests in flight tracked by mp.
+func InFlightSignal(mp *metrics.MetricsProvider) Signal {
+	return func() float64 { return float64(mp.InFlight()) }
+}
+
+// LatencyP95Signal reports the p95 latency of the requests tracked by mp in the last
+// minute, in seconds.
+func LatencyP95Signal(mp *metrics.MetricsProvider) Signal {
+	return func() float64 { return mp.LatencyQuantile(0.95).Seconds() }
+}
+
+// ThresholdPolicy adds a worker when any signal is above its threshold, and removes
+// one when every signal is below its threshold. Signals without a threshold are
+// ignored.
+func ThresholdPolicy(thresholds map[string]float64) Policy {
+	return func(workers int, load map[string]float64) int {
+		above, below, read := false, true, false
+		for name, threshold := range thresholds {
+			value, ok := load[name]
+			if !ok {
+				continue
+			}
+			read = true
+			if value > threshold {
+				above = true
+			}
+			if value >= threshold {
+				below = false
+			}
+		}
+		switch {
+		case above:
+			return workers + 1
+		case below && read:
+			return workers - 1
+		default:
+			return workers
+		}
+	}
+}
+
+// TargetPolicy sizes the pool so that no signal exceeds its target per worker. For
+// example, a target of 4 for SignalQueueDepth asks for one worker per 4 pending jobs.
+// Signals without a target are ignored.
+func TargetPolicy(targets map[string]float64) Policy {
+	return func(workers int, load map[string]float64) int {
+		desired := 0
+		for name, target := range targets {
+			if value, ok := load[name]; ok && target > 0 {
+				desired = max(desired, int(math.Ceil(value/target)))
+			}
+		}
+		return desired
+	}
+}
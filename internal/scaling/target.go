@@ -0,0 +1,250 @@
+package autoscaler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/h2co32/gollama/internal/security"
+)
+
+// ScaleTarget is something other than the in-process worker pool that the autoscaler
+// resizes, such as a Kubernetes Deployment of Ollama servers.
+type ScaleTarget interface {
+	// Current returns the number of replicas the target has.
+	Current(ctx context.Context) (int, error)
+
+	// Scale sets the number of replicas the target should have.
+	Scale(ctx context.Context, replicas int) error
+}
+
+// WebhookTarget scales by sending a POST request with a JSON body such as
+// {"replicas": 3} to a URL, for example a script that calls Nomad's job scaling API.
+type WebhookTarget struct {
+	// URL receives the requests.
+	URL string
+
+	// Headers are added to every request, for example for authentication. Optional.
+	Headers map[string]string
+
+	// Client sends the requests.
+	// Default: http.DefaultClient
+	Client *http.Client
+
+	mu       sync.Mutex
+	replicas int
+}
+
+// NewWebhookTarget creates a WebhookTarget for url whose current number of replicas
+// starts at initial.
+func NewWebhookTarget(url string, initial int) *WebhookTarget {
+	return &WebhookTarget{URL: url, replicas: initial}
+}
+
+// Current implements ScaleTarget. The webhook is not asked, so this is the number of
+// replicas last set successfully, or the initial number.
+func (t *WebhookTarget) Current(ctx context.Context) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.replicas, nil
+}
+
+// Scale implements ScaleTarget. Any 2xx response counts as success.
+func (t *WebhookTarget) Scale(ctx context.Context, replicas int) error {
+	body, err := json.Marshal(map[string]int{"replicas": replicas})
+	if err != nil {
+		return fmt.Errorf("failed to encode scale request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create scale request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range t.Headers {
+		req.Header.Set(name, value)
+	}
+
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call scale webhook: %w", err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("scale webhook returned status %d", res.StatusCode)
+	}
+
+	t.mu.Lock()
+	t.replicas = replicas
+	t.mu.Unlock()
+	return nil
+}
+
+// KubernetesOptions configures a KubernetesTarget. The defaults use the service
+// account of the pod gollama runs in, which needs permission to get and patch the
+// deployments/scale subresource.
+type KubernetesOptions struct {
+	// Deployment is the name of the Deployment to scale. Required.
+	Deployment string
+
+	// Namespace is the Deployment's namespace.
+	// Default: the pod's namespace
+	Namespace string
+
+	// APIServer is the URL of the Kubernetes API server.
+	// Default: https://$KUBERNETES_SERVICE_HOST:$KUBERNETES_SERVICE_PORT
+	APIServer string
+
+	// TokenFile holds the bearer token sent to the API server. It is read for every
+	// request, so rotated tokens take effect.
+	// Default: /var/run/secrets/kubernetes.io/serviceaccount/token
+	TokenFile string
+
+	// CAFile is the PEM bundle used to verify the API server.
+	// Default: /var/run/secrets/kubernetes.io/serviceaccount/ca.crt
+	CAFile string
+
+	// Client sends the requests. If set, CAFile is ignored. Optional.
+	Client *http.Client
+}
+
+// serviceAccountDir holds the credentials Kubernetes mounts into every pod.
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// DefaultKubernetesOptions returns the default Kubernetes options for the pod's
+// service account.
+func DefaultKubernetesOptions() KubernetesOptions {
+	options := KubernetesOptions{
+		TokenFile: serviceAccountDir + "/token",
+		CAFile:    serviceAccountDir + "/ca.crt",
+	}
+	if host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT"); host != "" && port != "" {
+		options.APIServer = "https://" + net.JoinHostPort(host, port)
+	}
+	if namespace, err := os.ReadFile(serviceAccountDir + "/namespace"); err == nil {
+		options.Namespace = strings.TrimSpace(string(namespace))
+	}
+	return options
+}
+
+// KubernetesTarget scales a Kubernetes Deployment through its scale subresource.
+type KubernetesTarget struct {
+	url       string // URL of the Deployment's scale subresource
+	tokenFile string
+	client    *http.Client
+}
+
+// NewKubernetesTarget creates a KubernetesTarget for the Deployment in options.
+// Zero option values fall back to the defaults.
+func NewKubernetesTarget(options KubernetesOptions) (*KubernetesTarget, error) {
+	defaults := DefaultKubernetesOptions()
+	if options.Deployment == "" {
+		return nil, fmt.Errorf("a deployment name is required")
+	}
+	if options.Namespace == "" {
+		options.Namespace = defaults.Namespace
+	}
+	if options.APIServer == "" {
+		options.APIServer = defaults.APIServer
+	}
+	if options.TokenFile == "" {
+		options.TokenFile = defaults.TokenFile
+	}
+	if options.CAFile == "" {
+		options.CAFile = defaults.CAFile
+	}
+	if options.Namespace == "" || options.APIServer == "" {
+		return nil, fmt.Errorf("the namespace and API server are required outside a Kubernetes pod")
+	}
+
+	client := options.Client
+	if client == nil {
+		tlsOptions := security.DefaultTLSOptions()
+		tlsOptions.CAFile = options.CAFile
+		tlsConfig, err := security.NewClientTLSConfig(tlsOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Kubernetes CA: %w", err)
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		client = &http.Client{Transport: transport}
+	}
+
+	return &KubernetesTarget{
+		url: fmt.Sprintf("%s/apis/apps/v1/namespaces/%s/deployments/%s/scale",
+			strings.TrimSuffix(options.APIServer, "/"), options.Namespace, options.Deployment),
+		tokenFile: options.TokenFile,
+		client:    client,
+	}, nil
+}
+
+// kubernetesScale is the part of the autoscaling/v1 Scale object the target uses.
+type kubernetesScale struct {
+	Spec struct {
+		Replicas int `json:"replicas"`
+	} `json:"spec"`
+}
+
+// Current implements ScaleTarget, returning the Deployment's desired replicas.
+func (t *KubernetesTarget) Current(ctx context.Context) (int, error) {
+	var scale kubernetesScale
+	if err := t.do(ctx, http.MethodGet, nil, &scale); err != nil {
+		return 0, err
+	}
+	return scale.Spec.Replicas, nil
+}
+
+// Scale implements ScaleTarget.
+func (t *KubernetesTarget) Scale(ctx context.Context, replicas int) error {
+	patch := fmt.Sprintf(`{"spec":{"replicas":%d}}`, replicas)
+	return t.do(ctx, http.MethodPatch, strings.NewReader(patch), nil)
+}
+
+// do sends a request to the scale subresource and decodes the response into out, if
+// it is not nil.
+func (t *KubernetesTarget) do(ctx context.Context, method string, body io.Reader, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, t.url, body)
+	if err != nil {
+		return fmt.Errorf("failed to create Kubernetes request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if t.tokenFile != "" {
+		token, err := os.ReadFile(t.tokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read Kubernetes token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	res, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Kubernetes API server: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("Kubernetes API server returned status %d: %s", res.StatusCode, strings.TrimSpace(string(message)))
+	}
+	if out == nil {
+		io.Copy(io.Discard, res.Body)
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode Kubernetes scale: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package autoscaler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeTarget is a ScaleTarget holding its size in memory
+type fakeTarget struct {
+	replicas int
+	err      error
+}
+
+func (t *fakeTarget) Current(ctx context.Context) (int, error) {
+	return t.replicas, nil
+}
+
+func (t *fakeTarget) Scale(ctx context.Context, replicas int) error {
+	if t.err != nil {
+		return t.err
+	}
+	t.replicas = replicas
+	return nil
+}
+
+func TestScaleTarget(t *testing.T) {
+	target := &fakeTarget{replicas: 1}
+	var events []ScaleEvent
+	var errs []error
+	as := NewAutoScalerWithOptions(Options{
+		MinWorkers: 1,
+		MaxWorkers: 10,
+		Source:     &fakeSource{},
+		Signals:    map[string]Signal{SignalQueueDepth: func() float64 { return 12 }},
+		Policy:     TargetPolicy(map[string]float64{SignalQueueDepth: 4}),
+		Target:     target,
+		OnScaleUp:  func(event ScaleEvent) { events = append(events, event) },
+		OnError:    func(err error) { errs = append(errs, err) },
+	})
+	defer as.Stop()
+
+	as.check()
+	if target.replicas != 3 {
+		t.Errorf("Expected the target to be scaled to 3 replicas, got %d", target.replicas)
+	}
+	if len(events) != 1 || events[0].From != 1 || events[0].To != 3 {
+		t.Errorf("Expected a scale up event from 1 to 3, got %+v", events)
+	}
+	if len(as.workerPool) != 1 {
+		t.Errorf("Expected the worker pool to stay at 1, got %d", len(as.workerPool))
+	}
+
+	// A failed resize is reported and leaves the size unchanged
+	target.replicas = 2
+	target.err = errors.New("forbidden")
+	as.check()
+	if len(errs) != 1 || !errors.Is(errs[0], target.err) {
+		t.Errorf("Expected the scale error to be reported, got %v", errs)
+	}
+	if len(events) != 1 {
+		t.Errorf("Expected no event for a failed resize, got %+v", events)
+	}
+}
+
+func TestWebhookTarget(t *testing.T) {
+	status := http.StatusOK
+	var received map[string]int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Expected an authorized POST, got %s with %q", r.Method, r.Header.Get("Authorization"))
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	target := NewWebhookTarget(server.URL, 2)
+	target.Headers = map[string]string{"Authorization": "Bearer secret"}
+	if current, _ := target.Current(context.Background()); current != 2 {
+		t.Errorf("Expected the initial size of 2, got %d", current)
+	}
+
+	if err := target.Scale(context.Background(), 4); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if received["replicas"] != 4 {
+		t.Errorf("Expected the webhook to receive 4 replicas, got %v", received)
+	}
+	if current, _ := target.Current(context.Background()); current != 4 {
+		t.Errorf("Expected the size to be 4, got %d", current)
+	}
+
+	status = http.StatusInternalServerError
+	if err := target.Scale(context.Background(), 5); err == nil {
+		t.Error("Expected an error for a failed webhook")
+	}
+	if current, _ := target.Current(context.Background()); current != 4 {
+		t.Errorf("Expected the size to stay 4, got %d", current)
+	}
+}
+
+func TestKubernetesTarget(t *testing.T) {
+	replicas := 2
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apis/apps/v1/namespaces/llm/deployments/ollama/scale" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("Expected the service account token, got %q", r.Header.Get("Authorization"))
+		}
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPatch:
+			if r.Header.Get("Content-Type") != "application/merge-patch+json" {
+				t.Errorf("Expected a merge patch, got %q", r.Header.Get("Content-Type"))
+			}
+			var patch kubernetesScale
+			json.NewDecoder(r.Body).Decode(&patch)
+			replicas = patch.Spec.Replicas
+		default:
+			t.Errorf("Unexpected method %s", r.Method)
+		}
+		fmt.Fprintf(w, `{"kind":"Scale","spec":{"replicas":%d},"status":{"replicas":1}}`, replicas)
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(tokenFile, []byte("token\n"), 0o600)
+
+	if _, err := NewKubernetesTarget(KubernetesOptions{Namespace: "llm", APIServer: server.URL}); err == nil {
+		t.Error("Expected an error without a deployment")
+	}
+
+	target, err := NewKubernetesTarget(KubernetesOptions{
+		Deployment: "ollama",
+		Namespace:  "llm",
+		APIServer:  server.URL,
+		TokenFile:  tokenFile,
+		Client:     server.Client(),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if current, err := target.Current(context.Background()); err != nil || current != 2 {
+		t.Errorf("Expected 2 replicas, got %d (%v)", current, err)
+	}
+	if err := target.Scale(context.Background(), 5); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if current, _ := target.Current(context.Background()); current != 5 {
+		t.Errorf("Expected 5 replicas after scaling, got %d", current)
+	}
+}
@@ -0,0 +1,210 @@
+package security
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA is a throwaway certificate authority for issuing test certificates.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate CA key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue writes a certificate and key for commonName, signed by the CA, to dir.
+func (ca *testCA) issue(t *testing.T, dir, name, commonName string, serial int64) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return certFile, keyFile
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+// startTLSServer starts a server that reports the client certificate's common name.
+func startTLSServer(t *testing.T, config *tls.Config) *httptest.Server {
+	t.Helper()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+		}
+	}))
+	server.TLS = config
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNewServerTLSConfigRequiresFiles(t *testing.T) {
+	if _, err := NewServerTLSConfig(TLSOptions{}); err == nil {
+		t.Error("Expected error without certificate and key files")
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile := newTestCA(t).issue(t, dir, "server", "server", 2)
+	if _, err := NewServerTLSConfig(TLSOptions{CertFile: certFile, KeyFile: keyFile, RequireClientCert: true}); err == nil {
+		t.Error("Expected error when requiring client certificates without a CA file")
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caFile := filepath.Join(dir, "ca.crt")
+	writeFile(t, caFile, ca.pem)
+	serverCert, serverKey := ca.issue(t, dir, "server", "server", 2)
+	clientCert, clientKey := ca.issue(t, dir, "client", "client-1", 3)
+
+	serverConfig, err := NewServerTLSConfig(TLSOptions{
+		CertFile:          serverCert,
+		KeyFile:           serverKey,
+		CAFile:            caFile,
+		RequireClientCert: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server TLS config: %v", err)
+	}
+	server := startTLSServer(t, serverConfig)
+
+	// A client presenting a certificate signed by the CA is accepted
+	clientConfig, err := NewClientTLSConfig(TLSOptions{CertFile: clientCert, KeyFile: clientKey, CAFile: caFile})
+	if err != nil {
+		t.Fatalf("Failed to create client TLS config: %v", err)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected request with client certificate to succeed, got %v", err)
+	}
+	body := make([]byte, 64)
+	n, _ := resp.Body.Read(body)
+	resp.Body.Close()
+	if string(body[:n]) != "client-1" {
+		t.Errorf("Expected server to see client-1, got %q", body[:n])
+	}
+
+	// A client without a certificate is rejected
+	noCertConfig, err := NewClientTLSConfig(TLSOptions{CAFile: caFile})
+	if err != nil {
+		t.Fatalf("Failed to create client TLS config: %v", err)
+	}
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: noCertConfig}}
+	if resp, err := client.Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Error("Expected request without a client certificate to fail")
+	}
+}
+
+func TestServerCertificateRotation(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caFile := filepath.Join(dir, "ca.crt")
+	writeFile(t, caFile, ca.pem)
+	certFile, keyFile := ca.issue(t, dir, "server", "server-old", 2)
+
+	serverConfig, err := NewServerTLSConfig(TLSOptions{
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		ReloadInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server TLS config: %v", err)
+	}
+	server := startTLSServer(t, serverConfig)
+
+	clientConfig, err := NewClientTLSConfig(TLSOptions{CAFile: caFile})
+	if err != nil {
+		t.Fatalf("Failed to create client TLS config: %v", err)
+	}
+	serverName := func() string {
+		// Use a new connection each time so the handshake sees the current certificate
+		conn, err := tls.Dial("tcp", server.Listener.Addr().String(), clientConfig)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+	}
+
+	if name := serverName(); name != "server-old" {
+		t.Fatalf("Expected server-old, got %s", name)
+	}
+
+	// Rotate the certificate in place; make sure the modification time moves
+	time.Sleep(20 * time.Millisecond)
+	ca.issue(t, dir, "server", "server-new", 4)
+	future := time.Now().Add(time.Second)
+	os.Chtimes(certFile, future, future)
+	os.Chtimes(keyFile, future, future)
+	time.Sleep(20 * time.Millisecond)
+
+	if name := serverName(); name != "server-new" {
+		t.Errorf("Expected rotated certificate server-new, got %s", name)
+	}
+}
@@ -0,0 +1,72 @@
+package usage
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/h2co32/gollama/pkg/middleware"
+)
+
+// Response is the body of Handler's responses.
+type Response struct {
+	Usage []Aggregate `json:"usage"`
+	Total Aggregate   `json:"total"`
+}
+
+// Handler returns an HTTP handler that reports usage as a JSON Response, for chargeback.
+// The aggregates can be selected with the tenant and model query parameters, and the
+// from and to parameters, as dates in DayFormat. The group_by parameter, a
+// comma-separated list of tenant, model, and day, sums them by those fields; for
+// example, group_by=tenant with from and to spanning a month reports each tenant's
+// monthly usage.
+func (t *Tracker) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			middleware.JSONResponse(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+
+		query := r.URL.Query()
+		filter := Filter{Tenant: query.Get("tenant"), Model: query.Get("model")}
+		for _, param := range []struct {
+			name string
+			day  *time.Time
+		}{{"from", &filter.From}, {"to", &filter.To}} {
+			value := query.Get(param.name)
+			if value == "" {
+				continue
+			}
+			day, err := time.Parse(DayFormat, value)
+			if err != nil {
+				middleware.JSONResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid " + param.name + " date: " + value})
+				return
+			}
+			*param.day = day
+		}
+
+		aggregates := t.Query(filter)
+		if groupBy := query.Get("group_by"); groupBy != "" {
+			fields := strings.Split(groupBy, ",")
+			for _, field := range fields {
+				switch field {
+				case GroupTenant, GroupModel, GroupDay:
+				default:
+					middleware.JSONResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid group_by field: " + field})
+					return
+				}
+			}
+			aggregates = Group(aggregates, fields...)
+		}
+
+		res := Response{Usage: aggregates}
+		if res.Usage == nil {
+			res.Usage = []Aggregate{}
+		}
+		for _, a := range aggregates {
+			res.Total.add(a)
+		}
+		middleware.JSONResponse(w, http.StatusOK, res)
+	})
+}
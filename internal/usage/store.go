@@ -0,0 +1,79 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Store persists the aggregates of a Tracker.
+type Store interface {
+	// Load returns the saved aggregates, or none if nothing was saved yet.
+	Load(ctx context.Context) ([]Aggregate, error)
+
+	// Save replaces the saved aggregates.
+	Save(ctx context.Context, aggregates []Aggregate) error
+}
+
+// FileStore saves aggregates to a JSON file. Each save atomically replaces the file, so
+// it holds either the previous or the new aggregates if the process dies while saving.
+type FileStore struct {
+	path string
+}
+
+// NewFileStore creates a FileStore saving to path. The file's directory must exist.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// Load reads the aggregates from the file, or returns none if it does not exist.
+func (s *FileStore) Load(ctx context.Context) ([]Aggregate, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var aggregates []Aggregate
+	if err := json.Unmarshal(data, &aggregates); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", s.path, err)
+	}
+	return aggregates, nil
+}
+
+// Save writes the aggregates to a temporary file next to the file and renames it over
+// the file.
+func (s *FileStore) Save(ctx context.Context, aggregates []Aggregate) error {
+	data, err := json.MarshalIndent(aggregates, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(s.path), "."+filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tempPath := file.Name()
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tempPath, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tempPath, s.path)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return nil
+}
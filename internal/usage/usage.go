@@ -0,0 +1,387 @@
+// Package usage accounts for the tokens, latency, and cost of model requests by tenant,
+// such as the client an API key belongs to, for chargeback.
+//
+// A Tracker adds each Record to an Aggregate per tenant, model, and UTC day, pricing
+// its tokens at the model's Rate when it is recorded, so later rate changes do not
+// rewrite past charges. Aggregates are saved to a Store periodically and when the
+// Tracker is closed, and loaded when it is created, so they survive restarts. Query and
+// Handler report them, and a metrics provider exports them to Prometheus as they are
+// recorded.
+//
+// Example usage:
+//
+//	tracker, err := usage.NewTracker(usage.Options{
+//		Store: usage.NewFileStore("/var/lib/gollama/usage.json"),
+//		Rates: map[string]usage.Rate{"gpt-4o-mini": {Prompt: 0.15, Completion: 0.6}},
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer tracker.Close()
+//
+//	tracker.Record(usage.Record{
+//		Tenant:           "acme",
+//		Model:            "gpt-4o-mini",
+//		PromptTokens:     1200,
+//		CompletionTokens: 300,
+//		Latency:          800 * time.Millisecond,
+//	})
+//
+//	// Usage of acme this month, summed per model
+//	monthly := usage.Group(tracker.Query(usage.Filter{Tenant: "acme", From: firstOfMonth}), usage.GroupModel)
+package usage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/h2co32/gollama/internal/metrics"
+	"github.com/h2co32/gollama/pkg/logging"
+)
+
+// DayFormat is the layout of Aggregate.Day.
+const DayFormat = "2006-01-02"
+
+// Rate is the price of a model's tokens, per million tokens, in whatever currency usage
+// is billed in.
+type Rate struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+}
+
+// Cost returns the price of promptTokens and completionTokens at the rate.
+func (r Rate) Cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*r.Prompt + float64(completionTokens)*r.Completion) / 1e6
+}
+
+// Record is a completed model request to account for.
+type Record struct {
+	Tenant           string
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	Latency          time.Duration
+
+	// Time is when the request completed, which determines the day it is accounted
+	// to.
+	// Default: the time it is recorded
+	Time time.Time
+}
+
+// Aggregate sums the usage of a tenant's requests for a model on a day. Aggregates
+// combined by Group leave the fields they were combined over empty.
+type Aggregate struct {
+	Tenant           string  `json:"tenant,omitempty"`
+	Model            string  `json:"model,omitempty"`
+	Day              string  `json:"day,omitempty"` // UTC date, in DayFormat
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	LatencySeconds   float64 `json:"latency_seconds"` // Total, divide by Requests for the average
+	Cost             float64 `json:"cost"`
+}
+
+// add adds the usage of other to a.
+func (a *Aggregate) add(other Aggregate) {
+	a.Requests += other.Requests
+	a.PromptTokens += other.PromptTokens
+	a.CompletionTokens += other.CompletionTokens
+	a.LatencySeconds += other.LatencySeconds
+	a.Cost += other.Cost
+}
+
+// key identifies an Aggregate.
+type key struct {
+	tenant, model, day string
+}
+
+// Options configures a Tracker.
+type Options struct {
+	// Store persists the aggregates. Optional. By default they are kept in memory and
+	// lost on restart.
+	Store Store
+
+	// FlushInterval is how often changed aggregates are saved to Store. Negative
+	// disables periodic saving, leaving it to Flush and Close.
+	// Default: 1 minute
+	FlushInterval time.Duration
+
+	// Rates are the token prices of models by name. A model name without a tag, such
+	// as "llama3", also covers its tagged names, such as "llama3:8b". Optional.
+	Rates map[string]Rate
+
+	// DefaultRate prices the tokens of models not in Rates. Optional. By default they
+	// are free.
+	DefaultRate Rate
+
+	// Metrics exports the recorded requests, tokens, and cost. Optional.
+	Metrics *metrics.MetricsProvider
+}
+
+// DefaultOptions returns the default tracker options.
+func DefaultOptions() Options {
+	return Options{FlushInterval: time.Minute}
+}
+
+// Tracker accounts for model requests by tenant, model, and day. It is safe for
+// concurrent use.
+type Tracker struct {
+	options Options
+	ratesMu sync.RWMutex // Guards the rates in options
+
+	mu         sync.Mutex
+	aggregates map[key]*Aggregate
+	dirty      bool
+	flushMu    sync.Mutex // Keeps an older snapshot from being saved over a newer one
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewTracker creates a Tracker, loading the aggregates saved to options.Store. Zero
+// option values fall back to the defaults.
+func NewTracker(options Options) (*Tracker, error) {
+	if options.FlushInterval == 0 {
+		options.FlushInterval = DefaultOptions().FlushInterval
+	}
+
+	t := &Tracker{
+		options:    options,
+		aggregates: make(map[key]*Aggregate),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if options.Store != nil {
+		saved, err := options.Store.Load(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to load usage: %w", err)
+		}
+		for _, a := range saved {
+			t.aggregates[key{a.Tenant, a.Model, a.Day}] = &a
+		}
+	}
+
+	if options.Store != nil && options.FlushInterval > 0 {
+		go t.flushPeriodically()
+	} else {
+		close(t.done)
+	}
+	return t, nil
+}
+
+// Rate returns the token prices of model.
+func (t *Tracker) Rate(model string) Rate {
+	t.ratesMu.RLock()
+	defer t.ratesMu.RUnlock()
+	if rate, ok := t.options.Rates[model]; ok {
+		return rate
+	}
+	if i := strings.LastIndex(model, ":"); i >= 0 {
+		if rate, ok := t.options.Rates[model[:i]]; ok {
+			return rate
+		}
+	}
+	return t.options.DefaultRate
+}
+
+// SetRates replaces the token prices of models, such as after the config file they came
+// from is reloaded. Requests already recorded keep the cost they were recorded with.
+func (t *Tracker) SetRates(rates map[string]Rate) {
+	t.ratesMu.Lock()
+	defer t.ratesMu.Unlock()
+	t.options.Rates = rates
+}
+
+// Record accounts for a request and returns its cost.
+func (t *Tracker) Record(record Record) float64 {
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	cost := t.Rate(record.Model).Cost(record.PromptTokens, record.CompletionTokens)
+
+	k := key{record.Tenant, record.Model, record.Time.UTC().Format(DayFormat)}
+	t.mu.Lock()
+	a, ok := t.aggregates[k]
+	if !ok {
+		a = &Aggregate{Tenant: k.tenant, Model: k.model, Day: k.day}
+		t.aggregates[k] = a
+	}
+	a.add(Aggregate{
+		Requests:         1,
+		PromptTokens:     int64(record.PromptTokens),
+		CompletionTokens: int64(record.CompletionTokens),
+		LatencySeconds:   record.Latency.Seconds(),
+		Cost:             cost,
+	})
+	t.dirty = true
+	t.mu.Unlock()
+
+	if t.options.Metrics != nil {
+		t.options.Metrics.TrackUsage(record.Tenant, record.Model, record.PromptTokens, record.CompletionTokens, cost)
+	}
+	return cost
+}
+
+// Filter selects aggregates in Query. Zero fields match every aggregate.
+type Filter struct {
+	Tenant string
+	Model  string
+
+	// From and To are the first and last days to include, by their UTC dates.
+	From time.Time
+	To   time.Time
+}
+
+// Query returns the aggregates matching filter, ordered by day, tenant, and model.
+func (t *Tracker) Query(filter Filter) []Aggregate {
+	var from, to string
+	if !filter.From.IsZero() {
+		from = filter.From.UTC().Format(DayFormat)
+	}
+	if !filter.To.IsZero() {
+		to = filter.To.UTC().Format(DayFormat)
+	}
+
+	t.mu.Lock()
+	var result []Aggregate
+	for k, a := range t.aggregates {
+		if (filter.Tenant != "" && k.tenant != filter.Tenant) ||
+			(filter.Model != "" && k.model != filter.Model) ||
+			(from != "" && k.day < from) ||
+			(to != "" && k.day > to) {
+			continue
+		}
+		result = append(result, *a)
+	}
+	t.mu.Unlock()
+
+	sortAggregates(result)
+	return result
+}
+
+// Fields of an Aggregate that Group can keep.
+const (
+	GroupTenant = "tenant"
+	GroupModel  = "model"
+	GroupDay    = "day"
+)
+
+// Group sums aggregates that have the same values of fields, such as GroupTenant for the
+// usage of each tenant, leaving the other fields empty. Without fields it returns the
+// total of all aggregates, or nothing if there are none. The result is ordered by day,
+// tenant, and model.
+func Group(aggregates []Aggregate, fields ...string) []Aggregate {
+	var keep key
+	for _, field := range fields {
+		switch field {
+		case GroupTenant:
+			keep.tenant = field
+		case GroupModel:
+			keep.model = field
+		case GroupDay:
+			keep.day = field
+		}
+	}
+
+	groups := make(map[key]*Aggregate)
+	for _, a := range aggregates {
+		var k key
+		if keep.tenant != "" {
+			k.tenant = a.Tenant
+		}
+		if keep.model != "" {
+			k.model = a.Model
+		}
+		if keep.day != "" {
+			k.day = a.Day
+		}
+		group, ok := groups[k]
+		if !ok {
+			group = &Aggregate{Tenant: k.tenant, Model: k.model, Day: k.day}
+			groups[k] = group
+		}
+		group.add(a)
+	}
+
+	result := make([]Aggregate, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, *group)
+	}
+	sortAggregates(result)
+	return result
+}
+
+// sortAggregates orders aggregates by day, tenant, and model.
+func sortAggregates(aggregates []Aggregate) {
+	sort.Slice(aggregates, func(i, j int) bool {
+		a, b := aggregates[i], aggregates[j]
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.Tenant != b.Tenant {
+			return a.Tenant < b.Tenant
+		}
+		return a.Model < b.Model
+	})
+}
+
+// Flush saves the aggregates to the store if they changed since they were last saved.
+func (t *Tracker) Flush(ctx context.Context) error {
+	if t.options.Store == nil {
+		return nil
+	}
+	t.flushMu.Lock()
+	defer t.flushMu.Unlock()
+
+	t.mu.Lock()
+	if !t.dirty {
+		t.mu.Unlock()
+		return nil
+	}
+	snapshot := make([]Aggregate, 0, len(t.aggregates))
+	for _, a := range t.aggregates {
+		snapshot = append(snapshot, *a)
+	}
+	t.dirty = false
+	t.mu.Unlock()
+
+	sortAggregates(snapshot)
+	if err := t.options.Store.Save(ctx, snapshot); err != nil {
+		// Try again on the next flush
+		t.mu.Lock()
+		t.dirty = true
+		t.mu.Unlock()
+		return fmt.Errorf("failed to save usage: %w", err)
+	}
+	return nil
+}
+
+// Close stops saving the aggregates periodically and saves them one last time.
+func (t *Tracker) Close() error {
+	t.closeOnce.Do(func() { close(t.stop) })
+	<-t.done
+	return t.Flush(context.Background())
+}
+
+// flushPeriodically saves the aggregates every FlushInterval until the Tracker is
+// closed.
+func (t *Tracker) flushPeriodically() {
+	defer close(t.done)
+	ticker := time.NewTicker(t.options.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := t.Flush(context.Background()); err != nil {
+				logging.Default().Error("failed to flush usage", "error", err)
+			}
+		case <-t.stop:
+			return
+		}
+	}
+}
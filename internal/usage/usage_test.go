@@ -0,0 +1,156 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTrackerRecord(t *testing.T) {
+	tracker, err := NewTracker(Options{
+		Rates:       map[string]Rate{"llama3": {Prompt: 1, Completion: 2}, "llama3:70b": {Prompt: 10, Completion: 20}},
+		DefaultRate: Rate{Prompt: 0.5},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create tracker: %v", err)
+	}
+
+	day := time.Date(2026, 3, 1, 23, 30, 0, 0, time.UTC)
+	tests := []struct {
+		record Record
+		cost   float64
+	}{
+		{Record{Tenant: "acme", Model: "llama3:8b", PromptTokens: 1e6, CompletionTokens: 1e6, Latency: time.Second, Time: day}, 3},
+		{Record{Tenant: "acme", Model: "llama3:8b", PromptTokens: 1e6, Latency: 3 * time.Second, Time: day}, 1},
+		{Record{Tenant: "acme", Model: "llama3:70b", PromptTokens: 1e5, Time: day}, 1},
+		{Record{Tenant: "globex", Model: "mistral", PromptTokens: 2e6, CompletionTokens: 1e6, Time: day.Add(time.Hour)}, 1},
+	}
+	for _, test := range tests {
+		if cost := tracker.Record(test.record); cost != test.cost {
+			t.Errorf("Expected a cost of %v for %s, got %v", test.cost, test.record.Model, cost)
+		}
+	}
+
+	all := tracker.Query(Filter{})
+	if len(all) != 3 {
+		t.Fatalf("Expected 3 aggregates, got %+v", all)
+	}
+	expected := Aggregate{Tenant: "acme", Model: "llama3:8b", Day: "2026-03-01", Requests: 2, PromptTokens: 2e6, CompletionTokens: 1e6, LatencySeconds: 4, Cost: 4}
+	if all[1] != expected {
+		t.Errorf("Expected %+v, got %+v", expected, all[1])
+	}
+	// Days are UTC dates, so an hour later is the next day
+	if all[2].Tenant != "globex" || all[2].Day != "2026-03-02" {
+		t.Errorf("Expected globex's usage on the next day last, got %+v", all[2])
+	}
+
+	if acme := tracker.Query(Filter{Tenant: "acme", Model: "llama3:70b"}); len(acme) != 1 || acme[0].PromptTokens != 1e5 {
+		t.Errorf("Unexpected usage of acme with llama3:70b %+v", acme)
+	}
+	if march2 := tracker.Query(Filter{From: day.Add(time.Hour)}); len(march2) != 1 || march2[0].Tenant != "globex" {
+		t.Errorf("Expected only the usage from March 2, got %+v", march2)
+	}
+	if march1 := tracker.Query(Filter{To: day}); len(march1) != 2 {
+		t.Errorf("Expected only the usage until March 1, got %+v", march1)
+	}
+
+	// New rates apply to later requests only
+	tracker.SetRates(map[string]Rate{"mistral": {Prompt: 1}})
+	if cost := tracker.Record(Record{Tenant: "globex", Model: "mistral", PromptTokens: 1e6, Time: day.Add(time.Hour)}); cost != 1 {
+		t.Errorf("Expected the new rate to apply, got a cost of %v", cost)
+	}
+	if llama := tracker.Rate("llama3:8b"); llama != (Rate{Prompt: 0.5}) {
+		t.Errorf("Expected llama3 to fall back to the default rate, got %+v", llama)
+	}
+	all = tracker.Query(Filter{})
+
+	byTenant := Group(all, GroupTenant)
+	if len(byTenant) != 2 || byTenant[0].Tenant != "acme" || byTenant[0].Model != "" || byTenant[0].Requests != 3 || byTenant[0].Cost != 5 {
+		t.Errorf("Unexpected usage by tenant %+v", byTenant)
+	}
+	if total := Group(all); len(total) != 1 || total[0].Requests != 5 || total[0].Cost != 7 {
+		t.Errorf("Unexpected total %+v", total)
+	}
+}
+
+func TestTrackerStore(t *testing.T) {
+	store := NewFileStore(filepath.Join(t.TempDir(), "usage.json"))
+	tracker, err := NewTracker(Options{Store: store, FlushInterval: -1})
+	if err != nil {
+		t.Fatalf("Failed to create tracker: %v", err)
+	}
+	tracker.Record(Record{Tenant: "acme", Model: "llama3", PromptTokens: 100})
+	if saved, _ := store.Load(context.Background()); len(saved) != 0 {
+		t.Errorf("Expected nothing saved before a flush, got %+v", saved)
+	}
+	if err := tracker.Close(); err != nil {
+		t.Fatalf("Failed to close tracker: %v", err)
+	}
+
+	// A new tracker continues from the saved aggregates
+	tracker, err = NewTracker(Options{Store: store, FlushInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create tracker: %v", err)
+	}
+	defer tracker.Close()
+	tracker.Record(Record{Tenant: "acme", Model: "llama3", PromptTokens: 50})
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		saved, err := store.Load(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to load usage: %v", err)
+		}
+		if len(saved) == 1 && saved[0].Requests == 2 && saved[0].PromptTokens == 150 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the periodic flush to save 2 requests, got %+v", saved)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	tracker, _ := NewTracker(Options{})
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, tenant := range []string{"acme", "acme", "globex"} {
+		for i := 0; i < 2; i++ {
+			tracker.Record(Record{Tenant: tenant, Model: "llama3", CompletionTokens: 10, Time: day.AddDate(0, 0, i)})
+		}
+	}
+
+	get := func(target string) (*httptest.ResponseRecorder, Response) {
+		rec := httptest.NewRecorder()
+		tracker.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		var res Response
+		json.NewDecoder(rec.Body).Decode(&res)
+		return rec, res
+	}
+
+	rec, res := get("/usage?tenant=acme&from=2026-03-02&group_by=tenant,model")
+	if rec.Code != http.StatusOK || len(res.Usage) != 1 || res.Usage[0].Day != "" || res.Usage[0].Requests != 2 {
+		t.Errorf("Unexpected response %d %+v", rec.Code, res)
+	}
+	if _, res := get("/usage?group_by=day"); len(res.Usage) != 2 || res.Total.Requests != 6 || res.Total.CompletionTokens != 60 {
+		t.Errorf("Unexpected usage by day %+v", res)
+	}
+	if _, res := get("/usage?tenant=initech"); res.Usage == nil || len(res.Usage) != 0 {
+		t.Errorf("Expected an empty list for a tenant without usage, got %+v", res.Usage)
+	}
+
+	for _, target := range []string{"/usage?from=yesterday", "/usage?group_by=week"} {
+		if rec, _ := get(target); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", target, rec.Code)
+		}
+	}
+	rec = httptest.NewRecorder()
+	tracker.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/usage", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", rec.Code)
+	}
+}
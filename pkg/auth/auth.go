@@ -17,10 +17,17 @@
 //
 //	// Validate an HMAC signature
 //	isValid := auth.ValidateHMAC("your-hmac-key", "data-to-sign", signature)
+//
+// Tokens signed with RSA or ECDSA keys (RS256, ES256) can be validated against a
+// PEM public key or a JWKS endpoint, such as one published by an identity provider:
+//
+//	keySet := auth.NewJWKS(auth.JWKSOptions{URL: "https://idp.example.com/.well-known/jwks.json"})
+//	claims, err := auth.ValidateJWTWithKeySet(keySet, token)
 package auth
 
 import (
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
@@ -45,6 +52,10 @@ type JWTOptions struct {
 	// Audience is the token audience claim.
 	// Optional.
 	Audience string
+
+	// KeyID is set as the "kid" header so validators can pick the right key.
+	// Optional.
+	KeyID string
 }
 
 // DefaultJWTOptions returns the default JWT options.
@@ -65,7 +76,11 @@ func GenerateJWTWithOptions(secretKey string, claims map[string]interface{}, opt
 		return "", fmt.Errorf("secret key cannot be empty")
 	}
 
-	// Create a new token
+	return signJWT(jwt.SigningMethodHS256, []byte(secretKey), claims, options)
+}
+
+// signJWT builds the standard and custom claims and signs them with key.
+func signJWT(method jwt.SigningMethod, key interface{}, claims map[string]interface{}, options JWTOptions) (string, error) {
 	tokenClaims := jwt.MapClaims{}
 
 	// Add standard claims
@@ -73,6 +88,13 @@ func GenerateJWTWithOptions(secretKey string, claims map[string]interface{}, opt
 	tokenClaims["iat"] = now.Unix()
 	tokenClaims["exp"] = now.Add(options.ExpiresIn).Unix()
 
+	// A unique token ID lets the token be revoked before it expires
+	jti, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+	tokenClaims["jti"] = jti
+
 	if options.Issuer != "" {
 		tokenClaims["iss"] = options.Issuer
 	}
@@ -87,10 +109,13 @@ func GenerateJWTWithOptions(secretKey string, claims map[string]interface{}, opt
 	}
 
 	// Create the token with claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, tokenClaims)
+	token := jwt.NewWithClaims(method, tokenClaims)
+	if options.KeyID != "" {
+		token.Header["kid"] = options.KeyID
+	}
 
-	// Sign the token with the secret key
-	tokenString, err := token.SignedString([]byte(secretKey))
+	// Sign the token with the key
+	tokenString, err := token.SignedString(key)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
@@ -98,21 +123,34 @@ func GenerateJWTWithOptions(secretKey string, claims map[string]interface{}, opt
 	return tokenString, nil
 }
 
+// newTokenID returns a random token ID for the "jti" claim.
+func newTokenID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate token ID: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
 // ValidateJWT validates a JWT token and returns its claims if valid.
 func ValidateJWT(secretKey string, tokenString string) (jwt.MapClaims, error) {
 	if secretKey == "" {
 		return nil, fmt.Errorf("secret key cannot be empty")
 	}
 
-	// Parse the token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+	return parseJWT(tokenString, func(token *jwt.Token) (interface{}, error) {
 		// Validate the signing method
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return []byte(secretKey), nil
 	})
+}
 
+// parseJWT parses and validates a token using keyFunc to select the verification key.
+func parseJWT(tokenString string, keyFunc jwt.Keyfunc) (jwt.MapClaims, error) {
+	// Parse the token
+	token, err := jwt.Parse(tokenString, keyFunc)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
 	}
@@ -128,6 +166,11 @@ func ValidateJWT(secretKey string, tokenString string) (jwt.MapClaims, error) {
 		return nil, fmt.Errorf("failed to extract claims")
 	}
 
+	// Reject tokens revoked before they expired
+	if err := checkRevoked(claims); err != nil {
+		return nil, err
+	}
+
 	return claims, nil
 }
 
@@ -0,0 +1,262 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// JWKSOptions configures a JWKS key set.
+type JWKSOptions struct {
+	// URL is the JWKS endpoint, such as https://issuer/.well-known/jwks.json.
+	URL string
+
+	// HTTPClient is used to fetch the key set.
+	// Default: a client with a 10 second timeout
+	HTTPClient *http.Client
+
+	// RefreshInterval is how long a fetched key set is used before it is fetched again.
+	// Default: 1 hour
+	RefreshInterval time.Duration
+
+	// MinRefreshInterval is the minimum time between fetches triggered by tokens with
+	// an unknown key ID. It stops forged tokens from hammering the endpoint while still
+	// picking up rotated keys promptly.
+	// Default: 1 minute
+	MinRefreshInterval time.Duration
+}
+
+// DefaultJWKSOptions returns the default JWKS options.
+func DefaultJWKSOptions() JWKSOptions {
+	return JWKSOptions{
+		HTTPClient:         &http.Client{Timeout: 10 * time.Second},
+		RefreshInterval:    time.Hour,
+		MinRefreshInterval: time.Minute,
+	}
+}
+
+// JWKS is a JSON Web Key Set fetched from a URL and cached. The set is fetched again
+// when it gets older than RefreshInterval, or when a token names a key ID that is not
+// in the set, so keys rotated by the identity provider are picked up automatically.
+type JWKS struct {
+	options   JWKSOptions
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+	mu        sync.Mutex
+}
+
+// jsonWebKey is a single key of a JWKS document.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// NewJWKS creates a key set for the given options. Keys are fetched on first use.
+func NewJWKS(options JWKSOptions) *JWKS {
+	defaults := DefaultJWKSOptions()
+	if options.HTTPClient == nil {
+		options.HTTPClient = defaults.HTTPClient
+	}
+	if options.RefreshInterval <= 0 {
+		options.RefreshInterval = defaults.RefreshInterval
+	}
+	if options.MinRefreshInterval <= 0 {
+		options.MinRefreshInterval = defaults.MinRefreshInterval
+	}
+
+	return &JWKS{options: options}
+}
+
+// Key returns the public key with the given key ID, fetching the key set if it is
+// stale or does not contain the key.
+func (ks *JWKS) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	since := time.Since(ks.fetchedAt)
+	_, known := ks.keys[kid]
+	if ks.keys == nil || since >= ks.options.RefreshInterval || (!known && since >= ks.options.MinRefreshInterval) {
+		if err := ks.refresh(ctx); err != nil {
+			// Keep serving the cached keys if the endpoint is briefly unavailable
+			if ks.keys == nil {
+				return nil, err
+			}
+		}
+	}
+
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("key %q not found in key set", kid)
+	}
+	return key, nil
+}
+
+// Refresh fetches the key set now.
+func (ks *JWKS) Refresh(ctx context.Context) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	return ks.refresh(ctx)
+}
+
+// Keyfunc looks up the verification key for a token by its "kid" header.
+// It can be passed to jwt.Parse directly.
+func (ks *JWKS) Keyfunc(token *jwt.Token) (interface{}, error) {
+	return ks.keyfuncWithContext(context.Background())(token)
+}
+
+// keyfuncWithContext returns a jwt.Keyfunc that fetches the key set with ctx if needed.
+func (ks *JWKS) keyfuncWithContext(ctx context.Context) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := ks.Key(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkSigningMethod(token, key); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+}
+
+// refresh fetches and parses the key set.
+// This method is not thread-safe and should be called with the lock held.
+func (ks *JWKS) refresh(ctx context.Context) error {
+	// Record the attempt so failures are also rate limited by MinRefreshInterval
+	ks.fetchedAt = time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ks.options.URL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+	res, err := ks.options.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: server returned %d", res.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, 1024*1024))
+	if err != nil {
+		return fmt.Errorf("failed to read JWKS: %w", err)
+	}
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return err
+	}
+
+	ks.keys = keys
+	return nil
+}
+
+// ParseJWKS parses a JWKS document into public keys by key ID. Keys that are not
+// RSA or EC signing keys are skipped.
+func ParseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		var key crypto.PublicKey
+		var err error
+		switch jwk.Kty {
+		case "RSA":
+			key, err = jwk.rsaPublicKey()
+		case "EC":
+			key, err = jwk.ecdsaPublicKey()
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+// rsaPublicKey decodes the modulus and exponent of an RSA key.
+func (jwk jsonWebKey) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode modulus: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode exponent: %w", err)
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("invalid RSA key parameters")
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
+
+// ecdsaPublicKey decodes the curve and coordinates of an EC key.
+func (jwk jsonWebKey) ecdsaPublicKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch jwk.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve: %s", jwk.Crv)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode x coordinate: %w", err)
+	}
+	y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode y coordinate: %w", err)
+	}
+
+	key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	if !curve.IsOnCurve(key.X, key.Y) {
+		return nil, fmt.Errorf("point is not on curve %s", jwk.Crv)
+	}
+	return key, nil
+}
+
+// ValidateJWTWithKeySet validates a JWT token against the key named by its "kid"
+// header in the key set and returns its claims if valid.
+func ValidateJWTWithKeySet(keySet *JWKS, tokenString string) (jwt.MapClaims, error) {
+	if keySet == nil {
+		return nil, fmt.Errorf("key set cannot be nil")
+	}
+	return parseJWT(tokenString, keySet.Keyfunc)
+}
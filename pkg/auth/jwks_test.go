@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// jwkForECKey encodes a P-256 public key as a JWK.
+func jwkForECKey(kid string, key *ecdsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "EC",
+		"kid": kid,
+		"use": "sig",
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+func TestJWKSValidationAndRotation(t *testing.T) {
+	oldKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	var mu sync.Mutex
+	published := []map[string]string{jwkForECKey("old", &oldKey.PublicKey)}
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetches++
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": published})
+	}))
+	defer server.Close()
+
+	keySet := NewJWKS(JWKSOptions{URL: server.URL, MinRefreshInterval: 20 * time.Millisecond})
+
+	sign := func(kid string, key *ecdsa.PrivateKey) string {
+		options := DefaultJWTOptions()
+		options.KeyID = kid
+		token, err := GenerateJWTWithKey(key, map[string]interface{}{"sub": kid}, options)
+		if err != nil {
+			t.Fatalf("GenerateJWTWithKey failed: %v", err)
+		}
+		return token
+	}
+
+	claims, err := ValidateJWTWithKeySet(keySet, sign("old", oldKey))
+	if err != nil {
+		t.Fatalf("ValidateJWTWithKeySet failed: %v", err)
+	}
+	if claims["sub"] != "old" {
+		t.Errorf("Expected sub claim 'old', got %v", claims["sub"])
+	}
+
+	// The identity provider rotates to a new key
+	mu.Lock()
+	published = append(published, jwkForECKey("new", &newKey.PublicKey))
+	mu.Unlock()
+
+	// Unknown key IDs do not trigger a fetch within MinRefreshInterval
+	if _, err := ValidateJWTWithKeySet(keySet, sign("new", newKey)); err == nil {
+		t.Error("Expected the new key to be unknown before MinRefreshInterval passes")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, err := ValidateJWTWithKeySet(keySet, sign("new", newKey)); err != nil {
+		t.Errorf("Expected the rotated key to be fetched, got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if fetches != 2 {
+		t.Errorf("Expected 2 fetches, got %d", fetches)
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	data, _ := json.Marshal(map[string]interface{}{"keys": []interface{}{
+		jwkForECKey("sig", &key.PublicKey),
+		map[string]string{"kty": "RSA", "kid": "enc", "use": "enc"},
+		map[string]string{"kty": "oct", "kid": "symmetric"},
+	}})
+
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		t.Fatalf("ParseJWKS failed: %v", err)
+	}
+	if len(keys) != 1 || !key.PublicKey.Equal(keys["sig"]) {
+		t.Errorf("Expected only the signing key, got %v", keys)
+	}
+
+	bad := jwkForECKey("bad", &key.PublicKey)
+	bad["y"] = bad["x"]
+	data, _ = json.Marshal(map[string]interface{}{"keys": []interface{}{bad}})
+	if _, err := ParseJWKS(data); err == nil {
+		t.Error("Expected error for a point that is not on the curve")
+	}
+}
@@ -0,0 +1,270 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// SigningKey is a shared HS256 secret held in a KeyRing.
+type SigningKey struct {
+	// ID is sent in the "kid" header of tokens signed with the key.
+	ID string
+
+	// Secret is the HMAC secret.
+	Secret []byte
+
+	// NotBefore is when the key starts signing tokens. Keys can be added ahead of
+	// time so every instance knows a key before any of them signs with it.
+	// Zero means immediately.
+	NotBefore time.Time
+
+	// ExpiresAt is when the key stops validating tokens. Zero means never.
+	ExpiresAt time.Time
+}
+
+// active reports whether the key validates tokens at now.
+func (k SigningKey) active(now time.Time) bool {
+	return k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt)
+}
+
+// KeyRingOptions configures a KeyRing.
+type KeyRingOptions struct {
+	// RotationInterval is how often StartRotation generates a new signing key.
+	// Default: 24 hours
+	RotationInterval time.Duration
+
+	// GracePeriod is how long a key keeps validating tokens after Rotate replaces it.
+	// It should be at least the lifetime of the tokens it signed.
+	// Default: 24 hours
+	GracePeriod time.Duration
+}
+
+// DefaultKeyRingOptions returns the default key ring options.
+func DefaultKeyRingOptions() KeyRingOptions {
+	return KeyRingOptions{
+		RotationInterval: 24 * time.Hour,
+		GracePeriod:      24 * time.Hour,
+	}
+}
+
+// KeyRing holds several HS256 keys so the signing key can be rotated without
+// invalidating tokens signed by its predecessors. Tokens are signed with the newest
+// key whose NotBefore has passed, and validated against the key named by their "kid"
+// header as long as that key has not expired.
+type KeyRing struct {
+	options KeyRingOptions
+	keys    map[string]SigningKey
+	mu      sync.RWMutex
+}
+
+// NewKeyRing creates an empty KeyRing with the given options.
+// Zero values fall back to the defaults.
+func NewKeyRing(options KeyRingOptions) *KeyRing {
+	defaults := DefaultKeyRingOptions()
+	if options.RotationInterval <= 0 {
+		options.RotationInterval = defaults.RotationInterval
+	}
+	if options.GracePeriod <= 0 {
+		options.GracePeriod = defaults.GracePeriod
+	}
+
+	return &KeyRing{
+		options: options,
+		keys:    make(map[string]SigningKey),
+	}
+}
+
+// Add adds a key to the ring, replacing any key with the same ID.
+func (kr *KeyRing) Add(key SigningKey) error {
+	if key.ID == "" {
+		return fmt.Errorf("key ID cannot be empty")
+	}
+	if len(key.Secret) == 0 {
+		return fmt.Errorf("key secret cannot be empty")
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	kr.keys[key.ID] = key
+	return nil
+}
+
+// Remove removes the key with the given ID. Tokens signed with it stop validating.
+func (kr *KeyRing) Remove(id string) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	delete(kr.keys, id)
+}
+
+// Keys returns the keys in the ring, oldest NotBefore first.
+func (kr *KeyRing) Keys() []SigningKey {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	keys := make([]SigningKey, 0, len(kr.keys))
+	for _, key := range kr.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].NotBefore.Equal(keys[j].NotBefore) {
+			return keys[i].ID < keys[j].ID
+		}
+		return keys[i].NotBefore.Before(keys[j].NotBefore)
+	})
+	return keys
+}
+
+// SigningKey returns the key new tokens are signed with: the active key with the
+// latest NotBefore that has passed.
+func (kr *KeyRing) SigningKey() (SigningKey, error) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	return kr.signingKey(time.Now())
+}
+
+// signingKey returns the signing key at now.
+// This method is not thread-safe and should be called with the lock held.
+func (kr *KeyRing) signingKey(now time.Time) (SigningKey, error) {
+	var current SigningKey
+	found := false
+	for _, key := range kr.keys {
+		if key.NotBefore.After(now) || !key.active(now) {
+			continue
+		}
+		if !found || key.NotBefore.After(current.NotBefore) || (key.NotBefore.Equal(current.NotBefore) && key.ID > current.ID) {
+			current, found = key, true
+		}
+	}
+	if !found {
+		return SigningKey{}, fmt.Errorf("key ring has no active signing key")
+	}
+	return current, nil
+}
+
+// Sign creates a JWT with the provided claims, signed with the current signing key
+// and naming it in the "kid" header. options.KeyID is ignored.
+func (kr *KeyRing) Sign(claims map[string]interface{}, options JWTOptions) (string, error) {
+	key, err := kr.SigningKey()
+	if err != nil {
+		return "", err
+	}
+
+	options.KeyID = key.ID
+	return signJWT(jwt.SigningMethodHS256, key.Secret, claims, options)
+}
+
+// Validate validates a JWT signed by any active key in the ring and returns its
+// claims if valid. Tokens without a "kid" header are checked against every active key.
+func (kr *KeyRing) Validate(tokenString string) (jwt.MapClaims, error) {
+	kid, err := tokenKeyID(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	kr.mu.RLock()
+	now := time.Now()
+	var candidates []SigningKey
+	if kid != "" {
+		if key, ok := kr.keys[kid]; ok && key.active(now) {
+			candidates = append(candidates, key)
+		}
+	} else {
+		for _, key := range kr.keys {
+			if key.active(now) {
+				candidates = append(candidates, key)
+			}
+		}
+	}
+	kr.mu.RUnlock()
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no active key for key ID %q", kid)
+	}
+
+	for _, key := range candidates {
+		claims, err := ValidateJWT(string(key.Secret), tokenString)
+		if err == nil || len(candidates) == 1 {
+			return claims, err
+		}
+	}
+	return nil, fmt.Errorf("token does not match any active key")
+}
+
+// Rotate generates a random key, makes it the signing key, and schedules the previous
+// signing key to expire after the grace period. It returns the new key.
+func (kr *KeyRing) Rotate() (SigningKey, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return SigningKey{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+	id, err := newTokenID()
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	now := time.Now()
+	if previous, err := kr.signingKey(now); err == nil {
+		expiresAt := now.Add(kr.options.GracePeriod)
+		if previous.ExpiresAt.IsZero() || previous.ExpiresAt.After(expiresAt) {
+			previous.ExpiresAt = expiresAt
+			kr.keys[previous.ID] = previous
+		}
+	}
+
+	// Drop keys that have expired so the ring does not grow without bound
+	for keyID, key := range kr.keys {
+		if !key.active(now) {
+			delete(kr.keys, keyID)
+		}
+	}
+
+	key := SigningKey{ID: id[:16], Secret: secret, NotBefore: now}
+	kr.keys[key.ID] = key
+	return key, nil
+}
+
+// StartRotation calls Rotate every RotationInterval until ctx is canceled. If the ring
+// has no signing key, one is generated first. Rotation errors are passed to onError,
+// which may be nil.
+func (kr *KeyRing) StartRotation(ctx context.Context, onError func(error)) error {
+	if _, err := kr.SigningKey(); err != nil {
+		if _, err := kr.Rotate(); err != nil {
+			return err
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(kr.options.RotationInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := kr.Rotate(); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// tokenKeyID returns the "kid" header of a token without verifying it.
+func tokenKeyID(tokenString string) (string, error) {
+	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return "", fmt.Errorf("failed to parse token: %w", err)
+	}
+	kid, _ := token.Header["kid"].(string)
+	return kid, nil
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeyRingSignAndValidate(t *testing.T) {
+	ring := NewKeyRing(KeyRingOptions{})
+	if _, err := ring.Sign(nil, DefaultJWTOptions()); err == nil {
+		t.Error("Expected error signing with an empty key ring")
+	}
+
+	ring.Add(SigningKey{ID: "2024", Secret: []byte("old-secret"), NotBefore: time.Now().Add(-48 * time.Hour)})
+	ring.Add(SigningKey{ID: "2025", Secret: []byte("new-secret"), NotBefore: time.Now().Add(-time.Hour)})
+	ring.Add(SigningKey{ID: "2026", Secret: []byte("future-secret"), NotBefore: time.Now().Add(time.Hour)})
+
+	// The newest key that has started signs new tokens
+	key, err := ring.SigningKey()
+	if err != nil {
+		t.Fatalf("SigningKey failed: %v", err)
+	}
+	if key.ID != "2025" {
+		t.Errorf("Expected signing key 2025, got %s", key.ID)
+	}
+
+	token, err := ring.Sign(map[string]interface{}{"sub": "user-1"}, DefaultJWTOptions())
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if kid, _ := tokenKeyID(token); kid != "2025" {
+		t.Errorf("Expected kid header 2025, got %q", kid)
+	}
+	if claims, err := ring.Validate(token); err != nil || claims["sub"] != "user-1" {
+		t.Errorf("Expected token to validate, got %v", err)
+	}
+
+	// Tokens signed by an older key and by a key without a kid still validate
+	options := DefaultJWTOptions()
+	options.KeyID = "2024"
+	oldToken, _ := GenerateJWTWithOptions("old-secret", nil, options)
+	if _, err := ring.Validate(oldToken); err != nil {
+		t.Errorf("Expected token signed by the older key to validate, got %v", err)
+	}
+	legacyToken, _ := GenerateJWT("old-secret", nil)
+	if _, err := ring.Validate(legacyToken); err != nil {
+		t.Errorf("Expected token without a kid to validate, got %v", err)
+	}
+
+	// A token naming one key but signed with another is rejected
+	options.KeyID = "2025"
+	mismatched, _ := GenerateJWTWithOptions("old-secret", nil, options)
+	if _, err := ring.Validate(mismatched); err == nil {
+		t.Error("Expected error for a token signed with a different key than its kid")
+	}
+
+	// Removed keys stop validating
+	ring.Remove("2024")
+	if _, err := ring.Validate(oldToken); err == nil {
+		t.Error("Expected error for a token signed by a removed key")
+	}
+	unknown, _ := GenerateJWT("unknown-secret", nil)
+	if _, err := ring.Validate(unknown); err == nil {
+		t.Error("Expected error for a token signed by an unknown key")
+	}
+}
+
+func TestKeyRingRotate(t *testing.T) {
+	ring := NewKeyRing(KeyRingOptions{GracePeriod: 50 * time.Millisecond})
+
+	first, err := ring.Rotate()
+	if err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	token, _ := ring.Sign(nil, DefaultJWTOptions())
+
+	second, err := ring.Rotate()
+	if err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	if second.ID == first.ID {
+		t.Fatal("Expected Rotate to generate a new key ID")
+	}
+	if key, _ := ring.SigningKey(); key.ID != second.ID {
+		t.Errorf("Expected the rotated key to sign, got %s", key.ID)
+	}
+
+	// The previous key validates during the grace period only
+	if _, err := ring.Validate(token); err != nil {
+		t.Errorf("Expected token to validate during the grace period, got %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, err := ring.Validate(token); err == nil {
+		t.Error("Expected token signed by the previous key to fail after the grace period")
+	}
+
+	// Expired keys are dropped at the next rotation
+	ring.Rotate()
+	if n := len(ring.Keys()); n != 2 {
+		t.Errorf("Expected 2 keys after dropping the expired one, got %d", n)
+	}
+}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// GenerateJWTWithKey creates a new JWT token signed with an RSA or ECDSA private key.
+// The algorithm follows from the key: RS256 for RSA keys, and ES256, ES384, or ES512
+// for P-256, P-384, or P-521 ECDSA keys.
+func GenerateJWTWithKey(privateKey crypto.PrivateKey, claims map[string]interface{}, options JWTOptions) (string, error) {
+	method, err := signingMethodFor(privateKey)
+	if err != nil {
+		return "", err
+	}
+	return signJWT(method, privateKey, claims, options)
+}
+
+// ValidateJWTWithKey validates a JWT token signed with the private key matching
+// publicKey and returns its claims if valid. Only RSA and ECDSA algorithms are
+// accepted, so a token cannot pass by claiming to be HMAC-signed with the public key.
+func ValidateJWTWithKey(publicKey crypto.PublicKey, tokenString string) (jwt.MapClaims, error) {
+	if publicKey == nil {
+		return nil, fmt.Errorf("public key cannot be nil")
+	}
+
+	return parseJWT(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if err := checkSigningMethod(token, publicKey); err != nil {
+			return nil, err
+		}
+		return publicKey, nil
+	})
+}
+
+// signingMethodFor returns the signing method for an RSA or ECDSA private key.
+func signingMethodFor(privateKey crypto.PrivateKey) (jwt.SigningMethod, error) {
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PrivateKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return jwt.SigningMethodES256, nil
+		case elliptic.P384():
+			return jwt.SigningMethodES384, nil
+		case elliptic.P521():
+			return jwt.SigningMethodES512, nil
+		}
+		return nil, fmt.Errorf("unsupported ECDSA curve: %s", key.Curve.Params().Name)
+	default:
+		return nil, fmt.Errorf("unsupported private key type: %T", privateKey)
+	}
+}
+
+// checkSigningMethod verifies that the token's algorithm matches the type of publicKey.
+func checkSigningMethod(token *jwt.Token, publicKey crypto.PublicKey) error {
+	switch publicKey.(type) {
+	case *rsa.PublicKey:
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); ok {
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		if _, ok := token.Method.(*jwt.SigningMethodECDSA); ok {
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported public key type: %T", publicKey)
+	}
+	return fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+}
+
+// ParsePrivateKeyPEM parses an RSA or ECDSA private key from PEM data in PKCS #1,
+// SEC 1 (EC PRIVATE KEY), or PKCS #8 form.
+func ParsePrivateKeyPEM(data []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RSA private key: %w", err)
+		}
+		return key, nil
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse EC private key: %w", err)
+		}
+		return key, nil
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		switch key.(type) {
+		case *rsa.PrivateKey, *ecdsa.PrivateKey:
+			return key, nil
+		}
+		return nil, fmt.Errorf("unsupported private key type: %T", key)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type: %s", block.Type)
+	}
+}
+
+// ParsePublicKeyPEM parses an RSA or ECDSA public key from PEM data in PKIX
+// (PUBLIC KEY) or PKCS #1 form, or from a certificate.
+func ParsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	var key crypto.PublicKey
+	switch block.Type {
+	case "PUBLIC KEY":
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key: %w", err)
+		}
+		key = parsed
+	case "RSA PUBLIC KEY":
+		parsed, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RSA public key: %w", err)
+		}
+		key = parsed
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		key = cert.PublicKey
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type: %s", block.Type)
+	}
+
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported public key type: %T", key)
+}
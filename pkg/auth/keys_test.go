@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+)
+
+func TestGenerateAndValidateJWTWithKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ECDSA key: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		privateKey interface{}
+		publicKey  interface{}
+		alg        string
+	}{
+		{"RS256", rsaKey, &rsaKey.PublicKey, "RS256"},
+		{"ES256", ecKey, &ecKey.PublicKey, "ES256"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := DefaultJWTOptions()
+			options.KeyID = "key-1"
+			token, err := GenerateJWTWithKey(tt.privateKey, map[string]interface{}{"user_id": "alice"}, options)
+			if err != nil {
+				t.Fatalf("GenerateJWTWithKey failed: %v", err)
+			}
+
+			claims, err := ValidateJWTWithKey(tt.publicKey, token)
+			if err != nil {
+				t.Fatalf("ValidateJWTWithKey failed: %v", err)
+			}
+			if claims["user_id"] != "alice" {
+				t.Errorf("Expected user_id claim 'alice', got %v", claims["user_id"])
+			}
+		})
+	}
+
+	// A token signed with one key type does not validate with the other
+	token, _ := GenerateJWTWithKey(rsaKey, nil, DefaultJWTOptions())
+	if _, err := ValidateJWTWithKey(&ecKey.PublicKey, token); err == nil {
+		t.Error("Expected an RS256 token to fail validation with an ECDSA key")
+	}
+
+	// HMAC tokens are rejected, even if signed with the public key bytes
+	hmacToken, _ := GenerateJWT("secret", nil)
+	if _, err := ValidateJWTWithKey(&rsaKey.PublicKey, hmacToken); err == nil {
+		t.Error("Expected an HS256 token to fail validation with an RSA key")
+	}
+
+	if _, err := GenerateJWTWithKey("not a key", nil, DefaultJWTOptions()); err == nil {
+		t.Error("Expected error for an unsupported private key type")
+	}
+}
+
+func TestParseKeyPEM(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ECDSA key: %v", err)
+	}
+
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(ecKey)
+	sec1, _ := x509.MarshalECPrivateKey(ecKey)
+	pkix, _ := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+
+	for _, block := range []*pem.Block{{Type: "PRIVATE KEY", Bytes: pkcs8}, {Type: "EC PRIVATE KEY", Bytes: sec1}} {
+		key, err := ParsePrivateKeyPEM(pem.EncodeToMemory(block))
+		if err != nil {
+			t.Errorf("Failed to parse %s: %v", block.Type, err)
+			continue
+		}
+		if !ecKey.Equal(key) {
+			t.Errorf("Parsed %s does not match the original key", block.Type)
+		}
+	}
+
+	publicKey, err := ParsePublicKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix}))
+	if err != nil {
+		t.Fatalf("Failed to parse public key: %v", err)
+	}
+	if !ecKey.PublicKey.Equal(publicKey) {
+		t.Error("Parsed public key does not match the original key")
+	}
+
+	if _, err := ParsePrivateKeyPEM([]byte("not pem")); err == nil {
+		t.Error("Expected error for data without a PEM block")
+	}
+	if _, err := ParsePublicKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})); err == nil {
+		t.Error("Expected error for a private key passed as a public key")
+	}
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// NonceStore remembers the nonces of signed requests so each can be used only once.
+type NonceStore interface {
+	// Use records the nonce for ttl and reports whether it had not been seen before.
+	Use(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+// MemoryNonceStore is a NonceStore that keeps nonces in memory.
+type MemoryNonceStore struct {
+	seen      map[string]time.Time
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+// NewMemoryNonceStore creates an empty in-memory nonce store.
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{
+		seen:      make(map[string]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+// Use records the nonce for ttl and reports whether it had not been seen before.
+func (s *MemoryNonceStore) Use(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) >= time.Minute {
+		s.sweep(now)
+	}
+	if expiresAt, ok := s.seen[nonce]; ok && now.Before(expiresAt) {
+		return false, nil
+	}
+	s.seen[nonce] = now.Add(ttl)
+	return true, nil
+}
+
+// sweep removes nonces whose time to live has passed.
+// This method is not thread-safe and should be called with the lock held.
+func (s *MemoryNonceStore) sweep(now time.Time) {
+	for nonce, expiresAt := range s.seen {
+		if !now.Before(expiresAt) {
+			delete(s.seen, nonce)
+		}
+	}
+	s.lastSweep = now
+}
+
+// RedisNonceStore is a NonceStore backed by Redis, so a request replayed against
+// a different instance is still rejected.
+type RedisNonceStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisNonceStore creates a nonce store that keeps nonces in Redis under keys
+// starting with prefix, such as "gollama:nonce:".
+func NewRedisNonceStore(client *redis.Client, prefix string) *RedisNonceStore {
+	return &RedisNonceStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// Use records the nonce for ttl and reports whether it had not been seen before.
+func (s *RedisNonceStore) Use(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	ok, err := s.client.SetNX(ctx, s.prefix+nonce, 1, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to store nonce: %w", err)
+	}
+	return ok, nil
+}
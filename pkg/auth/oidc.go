@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// OIDCOptions configures an OIDCValidator.
+type OIDCOptions struct {
+	// IssuerURL is the OpenID Connect issuer, such as https://accounts.example.com.
+	// Its discovery document is fetched from IssuerURL/.well-known/openid-configuration.
+	IssuerURL string
+
+	// Audience is the expected "aud" claim, usually the client ID registered with
+	// the identity provider. Optional; when empty the audience is not checked.
+	Audience string
+
+	// HTTPClient is used for discovery and to fetch the issuer's key set.
+	// Default: a client with a 10 second timeout
+	HTTPClient *http.Client
+
+	// RefreshInterval is how long the issuer's key set is cached before it is fetched again.
+	// Default: 1 hour
+	RefreshInterval time.Duration
+}
+
+// DefaultOIDCOptions returns the default OIDC options.
+func DefaultOIDCOptions() OIDCOptions {
+	return OIDCOptions{
+		HTTPClient:      &http.Client{Timeout: 10 * time.Second},
+		RefreshInterval: time.Hour,
+	}
+}
+
+// OIDCValidator validates ID and access tokens issued by an OpenID Connect provider.
+// The provider's signing keys are located through discovery and cached in a JWKS,
+// so key rotation at the provider is picked up automatically.
+type OIDCValidator struct {
+	options OIDCOptions
+	issuer  string
+	keySet  *JWKS
+}
+
+// oidcDiscovery holds the fields of an OpenID Connect discovery document that are used.
+type oidcDiscovery struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// NewOIDCValidator performs OpenID Connect discovery against options.IssuerURL and
+// returns a validator for the issuer's tokens. Zero values fall back to the defaults.
+func NewOIDCValidator(ctx context.Context, options OIDCOptions) (*OIDCValidator, error) {
+	if options.IssuerURL == "" {
+		return nil, fmt.Errorf("issuer URL cannot be empty")
+	}
+	defaults := DefaultOIDCOptions()
+	if options.HTTPClient == nil {
+		options.HTTPClient = defaults.HTTPClient
+	}
+	if options.RefreshInterval <= 0 {
+		options.RefreshInterval = defaults.RefreshInterval
+	}
+
+	issuer := strings.TrimSuffix(options.IssuerURL, "/")
+	discovery, err := discoverOIDC(ctx, options.HTTPClient, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	// The provider must identify as the issuer it was discovered from, otherwise
+	// tokens from another issuer could be accepted
+	if strings.TrimSuffix(discovery.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("discovered issuer %q does not match %q", discovery.Issuer, options.IssuerURL)
+	}
+	if discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery document for %s has no jwks_uri", options.IssuerURL)
+	}
+
+	return &OIDCValidator{
+		options: options,
+		issuer:  discovery.Issuer,
+		keySet: NewJWKS(JWKSOptions{
+			URL:             discovery.JWKSURI,
+			HTTPClient:      options.HTTPClient,
+			RefreshInterval: options.RefreshInterval,
+		}),
+	}, nil
+}
+
+// discoverOIDC fetches the issuer's discovery document.
+func discoverOIDC(ctx context.Context, client *http.Client, issuer string) (*oidcDiscovery, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery request: %w", err)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch discovery document: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch discovery document: server returned %d", res.StatusCode)
+	}
+
+	var discovery oidcDiscovery
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1024*1024)).Decode(&discovery); err != nil {
+		return nil, fmt.Errorf("failed to parse discovery document: %w", err)
+	}
+	return &discovery, nil
+}
+
+// Issuer returns the issuer identifier from the discovery document.
+func (v *OIDCValidator) Issuer() string {
+	return v.issuer
+}
+
+// KeySet returns the issuer's cached key set.
+func (v *OIDCValidator) KeySet() *JWKS {
+	return v.keySet
+}
+
+// Validate verifies a token's signature against the issuer's keys and checks that it
+// was issued by the issuer, is meant for the configured audience, and has not expired.
+// It returns the token's claims if valid.
+func (v *OIDCValidator) Validate(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	claims, err := parseJWT(tokenString, v.keySet.keyfuncWithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if !claims.VerifyIssuer(v.issuer, true) {
+		return nil, fmt.Errorf("token issuer %v does not match %s", claims["iss"], v.issuer)
+	}
+	if v.options.Audience != "" && !claims.VerifyAudience(v.options.Audience, true) {
+		return nil, fmt.Errorf("token audience does not include %s", v.options.Audience)
+	}
+	// jwt.Parse only checks "exp" when present; OIDC tokens must always expire
+	if _, ok := claims["exp"]; !ok {
+		return nil, fmt.Errorf("token has no expiration")
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestOIDCProvider starts a provider that publishes key under kid "key-1".
+func newTestOIDCProvider(t *testing.T, key *ecdsa.PrivateKey, issuer func(url string) string) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{
+				"issuer":   issuer(server.URL),
+				"jwks_uri": server.URL + "/keys",
+			})
+		case "/keys":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"keys": []map[string]string{jwkForECKey("key-1", &key.PublicKey)},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOIDCValidator(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	server := newTestOIDCProvider(t, key, func(url string) string { return url })
+
+	validator, err := NewOIDCValidator(context.Background(), OIDCOptions{IssuerURL: server.URL + "/", Audience: "gollama"})
+	if err != nil {
+		t.Fatalf("NewOIDCValidator failed: %v", err)
+	}
+	if validator.Issuer() != server.URL {
+		t.Errorf("Expected issuer %s, got %s", server.URL, validator.Issuer())
+	}
+
+	sign := func(issuer, audience string) string {
+		options := DefaultJWTOptions()
+		options.KeyID = "key-1"
+		options.Issuer = issuer
+		options.Audience = audience
+		token, err := GenerateJWTWithKey(key, map[string]interface{}{"sub": "user-1"}, options)
+		if err != nil {
+			t.Fatalf("GenerateJWTWithKey failed: %v", err)
+		}
+		return token
+	}
+
+	claims, err := validator.Validate(context.Background(), sign(server.URL, "gollama"))
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if claims["sub"] != "user-1" {
+		t.Errorf("Expected sub claim 'user-1', got %v", claims["sub"])
+	}
+
+	if _, err := validator.Validate(context.Background(), sign("https://other.example.com", "gollama")); err == nil {
+		t.Error("Expected error for a token from another issuer")
+	}
+	if _, err := validator.Validate(context.Background(), sign(server.URL, "other-client")); err == nil {
+		t.Error("Expected error for a token meant for another audience")
+	}
+
+	// A token signed by a key the provider does not publish is rejected
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	options := DefaultJWTOptions()
+	options.KeyID = "key-1"
+	options.Issuer = server.URL
+	options.Audience = "gollama"
+	forged, _ := GenerateJWTWithKey(otherKey, map[string]interface{}{"sub": "user-1"}, options)
+	if _, err := validator.Validate(context.Background(), forged); err == nil {
+		t.Error("Expected error for a token with an invalid signature")
+	}
+}
+
+func TestOIDCValidatorIssuerMismatch(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	server := newTestOIDCProvider(t, key, func(string) string { return "https://attacker.example.com" })
+
+	if _, err := NewOIDCValidator(context.Background(), OIDCOptions{IssuerURL: server.URL}); err == nil {
+		t.Error("Expected error when the discovered issuer does not match")
+	}
+	if _, err := NewOIDCValidator(context.Background(), OIDCOptions{}); err == nil {
+		t.Error("Expected error for an empty issuer URL")
+	}
+}
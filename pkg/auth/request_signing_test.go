@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerifyRequest(t *testing.T) {
+	options := DefaultRequestSigningOptions()
+	options.SignedHeaders = []string{"Content-Type"}
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/api/generate?stream=false", strings.NewReader(`{"model":"llama3"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if err := SignRequest("secret", req, options); err != nil {
+			t.Fatalf("SignRequest failed: %v", err)
+		}
+		return req
+	}
+
+	req := newRequest()
+	if err := VerifyRequest("secret", req, options); err != nil {
+		t.Fatalf("VerifyRequest failed: %v", err)
+	}
+
+	// The body is still readable after signing and verification
+	body, _ := io.ReadAll(req.Body)
+	if string(body) != `{"model":"llama3"}` {
+		t.Errorf("Expected body to be preserved, got %q", body)
+	}
+
+	// Replaying the same request is rejected
+	req.Body = io.NopCloser(strings.NewReader(`{"model":"llama3"}`))
+	if err := VerifyRequest("secret", req, options); err == nil {
+		t.Error("Expected a replayed request to be rejected")
+	}
+
+	tamper := map[string]func(req *http.Request){
+		"method":       func(req *http.Request) { req.Method = http.MethodPut },
+		"path":         func(req *http.Request) { req.URL.Path = "/api/chat" },
+		"query":        func(req *http.Request) { req.URL.RawQuery = "stream=true" },
+		"header":       func(req *http.Request) { req.Header.Set("Content-Type", "text/plain") },
+		"body":         func(req *http.Request) { req.Body = io.NopCloser(strings.NewReader(`{"model":"other"}`)) },
+		"nonce":        func(req *http.Request) { req.Header.Set(NonceHeaderKey, "other") },
+		"secret":       func(req *http.Request) { req.Header.Set(SignatureHeaderKey, GenerateHMAC("wrong", "data")) },
+		"no nonce":     func(req *http.Request) { req.Header.Del(NonceHeaderKey) },
+		"no signature": func(req *http.Request) { req.Header.Del(SignatureHeaderKey) },
+	}
+	for name, modify := range tamper {
+		t.Run(name, func(t *testing.T) {
+			req := newRequest()
+			modify(req)
+			if err := VerifyRequest("secret", req, options); err == nil {
+				t.Error("Expected a modified request to be rejected")
+			}
+		})
+	}
+}
+
+func TestVerifyRequestClockSkew(t *testing.T) {
+	options := DefaultRequestSigningOptions()
+	options.MaxSkew = time.Minute
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tags", nil)
+	req.Header.Set(TimestampHeaderKey, strconv.FormatInt(time.Now().Add(-2*time.Minute).Unix(), 10))
+	req.Header.Set(NonceHeaderKey, "nonce")
+	canonical, _ := CanonicalRequest(req, nil)
+	req.Header.Set(SignatureHeaderKey, GenerateHMAC("secret", canonical))
+
+	if err := VerifyRequest("secret", req, options); err == nil {
+		t.Error("Expected a request outside the clock skew to be rejected")
+	}
+
+	if err := VerifyRequest("secret", req, RequestSigningOptions{}); err == nil {
+		t.Error("Expected error without a nonce store")
+	}
+}
+
+func TestMemoryNonceStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryNonceStore()
+
+	if fresh, _ := store.Use(ctx, "a", 20*time.Millisecond); !fresh {
+		t.Error("Expected first use of a nonce to be fresh")
+	}
+	if fresh, _ := store.Use(ctx, "a", 20*time.Millisecond); fresh {
+		t.Error("Expected second use of a nonce to be rejected")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if fresh, _ := store.Use(ctx, "a", 20*time.Millisecond); !fresh {
+		t.Error("Expected a nonce to be usable again after its time to live")
+	}
+}
@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// ErrTokenRevoked is returned when validating a token whose ID has been revoked.
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// TokenStore records revoked token IDs (the "jti" claim) until the tokens expire.
+type TokenStore interface {
+	// Revoke marks the token ID as revoked until expiresAt.
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+
+	// IsRevoked reports whether the token ID has been revoked.
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+var (
+	tokenStore   TokenStore = NewMemoryTokenStore()
+	tokenStoreMu sync.RWMutex
+)
+
+// SetTokenStore replaces the store that RevokeToken writes to and token validation
+// checks. The default is an in-memory store, which is not shared between processes;
+// use a RedisTokenStore when several instances validate the same tokens.
+func SetTokenStore(store TokenStore) {
+	tokenStoreMu.Lock()
+	defer tokenStoreMu.Unlock()
+	tokenStore = store
+}
+
+// currentTokenStore returns the store set by SetTokenStore.
+func currentTokenStore() TokenStore {
+	tokenStoreMu.RLock()
+	defer tokenStoreMu.RUnlock()
+	return tokenStore
+}
+
+// RevokeToken revokes the token with the given ID until expiresAt, after which the
+// token is rejected anyway. Validation through ValidateJWT, ValidateJWTWithKey, and
+// ValidateJWTWithKeySet fails with ErrTokenRevoked from then on.
+func RevokeToken(jti string, expiresAt time.Time) error {
+	if jti == "" {
+		return fmt.Errorf("token ID cannot be empty")
+	}
+	if err := currentTokenStore().Revoke(context.Background(), jti, expiresAt); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+// RevokeClaims revokes the token the claims were taken from, using its "jti" and
+// "exp" claims. It is typically called on logout with the claims from the request context.
+func RevokeClaims(claims jwt.MapClaims) error {
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return fmt.Errorf("token has no jti claim")
+	}
+
+	// Tokens without an expiry are kept revoked for a day past the longest default lifetime
+	expiresAt := time.Now().Add(24 * time.Hour)
+	if exp, ok := claims["exp"].(float64); ok {
+		expiresAt = time.Unix(int64(exp), 0)
+	}
+	return RevokeToken(jti, expiresAt)
+}
+
+// checkRevoked returns ErrTokenRevoked if the claims' token ID has been revoked.
+func checkRevoked(claims jwt.MapClaims) error {
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return nil
+	}
+
+	revoked, err := currentTokenStore().IsRevoked(context.Background(), jti)
+	if err != nil {
+		return fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// MemoryTokenStore is a TokenStore that keeps revoked token IDs in memory.
+type MemoryTokenStore struct {
+	revoked   map[string]time.Time
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+// NewMemoryTokenStore creates an empty in-memory token store.
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{
+		revoked:   make(map[string]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+// Revoke marks the token ID as revoked until expiresAt.
+func (s *MemoryTokenStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) >= time.Minute {
+		s.sweep(now)
+	}
+	if expiresAt.After(now) {
+		s.revoked[jti] = expiresAt
+	}
+	return nil
+}
+
+// IsRevoked reports whether the token ID has been revoked and has not yet expired.
+func (s *MemoryTokenStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt, ok := s.revoked[jti]
+	return ok && time.Now().Before(expiresAt), nil
+}
+
+// sweep removes entries for tokens that have expired.
+// This method is not thread-safe and should be called with the lock held.
+func (s *MemoryTokenStore) sweep(now time.Time) {
+	for jti, expiresAt := range s.revoked {
+		if !now.Before(expiresAt) {
+			delete(s.revoked, jti)
+		}
+	}
+	s.lastSweep = now
+}
+
+// RedisTokenStore is a TokenStore backed by Redis, so revocations are shared by
+// every instance. Entries expire with the tokens they revoke.
+type RedisTokenStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisTokenStore creates a token store that keeps revoked token IDs in Redis
+// under keys starting with prefix, such as "gollama:revoked:".
+func NewRedisTokenStore(client *redis.Client, prefix string) *RedisTokenStore {
+	return &RedisTokenStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// Revoke marks the token ID as revoked until expiresAt.
+func (s *RedisTokenStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	if err := s.client.Set(ctx, s.prefix+jti, 1, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to store revoked token: %w", err)
+	}
+	return nil
+}
+
+// IsRevoked reports whether the token ID has been revoked.
+func (s *RedisTokenStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	n, err := s.client.Exists(ctx, s.prefix+jti).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to look up revoked token: %w", err)
+	}
+	return n > 0, nil
+}
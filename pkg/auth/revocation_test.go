@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// useMemoryTokenStore installs a fresh in-memory token store for the test.
+func useMemoryTokenStore(t *testing.T) *MemoryTokenStore {
+	store := NewMemoryTokenStore()
+	SetTokenStore(store)
+	t.Cleanup(func() { SetTokenStore(NewMemoryTokenStore()) })
+	return store
+}
+
+func TestRevokeToken(t *testing.T) {
+	useMemoryTokenStore(t)
+
+	token, err := GenerateJWT("secret", map[string]interface{}{"user_id": 123})
+	if err != nil {
+		t.Fatalf("GenerateJWT failed: %v", err)
+	}
+	claims, err := ValidateJWT("secret", token)
+	if err != nil {
+		t.Fatalf("ValidateJWT failed: %v", err)
+	}
+	if jti, _ := claims["jti"].(string); jti == "" {
+		t.Fatal("Expected generated token to have a jti claim")
+	}
+
+	// Revoking on logout invalidates the token before it expires
+	if err := RevokeClaims(claims); err != nil {
+		t.Fatalf("RevokeClaims failed: %v", err)
+	}
+	if _, err := ValidateJWT("secret", token); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Expected ErrTokenRevoked, got %v", err)
+	}
+
+	// Other tokens are unaffected
+	other, _ := GenerateJWT("secret", map[string]interface{}{"user_id": 123})
+	if _, err := ValidateJWT("secret", other); err != nil {
+		t.Errorf("Expected another token to stay valid, got %v", err)
+	}
+
+	if err := RevokeToken("", time.Now().Add(time.Hour)); err == nil {
+		t.Error("Expected error revoking an empty token ID")
+	}
+	if err := RevokeClaims(map[string]interface{}{"user_id": 123}); err == nil {
+		t.Error("Expected error revoking claims without a jti")
+	}
+}
+
+func TestMemoryTokenStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryTokenStore()
+
+	store.Revoke(ctx, "active", time.Now().Add(time.Hour))
+	store.Revoke(ctx, "short", time.Now().Add(20*time.Millisecond))
+	store.Revoke(ctx, "expired", time.Now().Add(-time.Second))
+
+	if revoked, _ := store.IsRevoked(ctx, "active"); !revoked {
+		t.Error("Expected 'active' to be revoked")
+	}
+	if revoked, _ := store.IsRevoked(ctx, "expired"); revoked {
+		t.Error("Expected an already expired token not to be stored")
+	}
+	if revoked, _ := store.IsRevoked(ctx, "unknown"); revoked {
+		t.Error("Expected an unknown token not to be revoked")
+	}
+
+	// Entries lapse once the token would have expired anyway
+	time.Sleep(30 * time.Millisecond)
+	if revoked, _ := store.IsRevoked(ctx, "short"); revoked {
+		t.Error("Expected 'short' to lapse after its expiry")
+	}
+
+	store.sweep(time.Now())
+	if len(store.revoked) != 1 {
+		t.Errorf("Expected sweep to leave 1 entry, got %d", len(store.revoked))
+	}
+}
@@ -0,0 +1,362 @@
+// Package conversation keeps the message history of chat sessions within a model's
+// context window.
+//
+// A Manager stores each session's messages in a cache.Cache, so sessions can live in
+// memory, on disk, or in Redis and expire after a period without messages. When a
+// session grows past its token budget, the oldest messages are dropped or, with a
+// Summarizer, folded into a running summary. System messages are always kept.
+//
+// Example usage:
+//
+//	manager := conversation.NewManager(conversation.Options{
+//		MaxTokens:  4096,
+//		TTL:        time.Hour,
+//		Summarizer: conversation.NewOllamaSummarizer(client, "llama3"),
+//	})
+//
+//	err := manager.Append(ctx, sessionID, prompt.Message{Role: prompt.RoleUser, Content: question})
+//
+//	// The messages to send to the model
+//	messages, err := manager.Messages(ctx, sessionID)
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/h2co32/gollama/internal/cache"
+	"github.com/h2co32/gollama/internal/preprocessing"
+	"github.com/h2co32/gollama/pkg/logging"
+	"github.com/h2co32/gollama/pkg/prompt"
+)
+
+// Version represents the current package version following semantic versioning.
+const Version = "1.0.0"
+
+// ErrSessionNotFound is returned for sessions that do not exist or have expired.
+var ErrSessionNotFound = errors.New("session not found")
+
+// messageOverhead approximates the tokens a chat template adds around each message.
+const messageOverhead = 4
+
+// Summarizer condenses the messages dropped from a session into a summary.
+type Summarizer interface {
+	// Summarize returns a summary of the earlier summary, which may be empty, followed
+	// by messages.
+	Summarize(ctx context.Context, summary string, messages []prompt.Message) (string, error)
+}
+
+// SummarizerFunc adapts a function to a Summarizer.
+type SummarizerFunc func(ctx context.Context, summary string, messages []prompt.Message) (string, error)
+
+// Summarize calls f.
+func (f SummarizerFunc) Summarize(ctx context.Context, summary string, messages []prompt.Message) (string, error) {
+	return f(ctx, summary, messages)
+}
+
+// Session is the stored state of a conversation.
+type Session struct {
+	// ID identifies the session.
+	ID string `json:"id"`
+
+	// Messages are the messages kept, oldest first.
+	Messages []prompt.Message `json:"messages"`
+
+	// Summary condenses the messages that no longer fit. Empty without a Summarizer.
+	Summary string `json:"summary,omitempty"`
+
+	// Dropped is the number of messages dropped or summarized.
+	Dropped int `json:"dropped,omitempty"`
+
+	// CreatedAt is when the session's first message was added.
+	CreatedAt time.Time `json:"created_at"`
+
+	// UpdatedAt is when the session's last message was added.
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Options configures a Manager.
+type Options struct {
+	// Cache stores the sessions: a cache.MemoryCache, cache.DiskCache, or the Bytes view
+	// of a cache.DistributedCache for sessions shared between instances.
+	// Default: a new cache.MemoryCache
+	Cache cache.Cache
+
+	// TTL is how long a session is kept after its last message.
+	// Default: 24 hours
+	TTL time.Duration
+
+	// KeyPrefix starts the cache key of every session.
+	// Default: "conversation:"
+	KeyPrefix string
+
+	// MaxTokens is the token budget of a session's messages and summary. The newest
+	// message is kept even if it alone exceeds the budget.
+	// Default: 4096
+	MaxTokens int
+
+	// Counter counts tokens with a model's vocabulary. Optional. By default each word
+	// and punctuation mark counts as one token. Either way, each message costs 4 more
+	// tokens for the chat template around it.
+	Counter *preprocessing.TokenCounter
+
+	// Summarizer condenses messages that no longer fit into a summary. If it fails, the
+	// messages are dropped and a warning is logged. Optional. By default the oldest
+	// messages are dropped.
+	Summarizer Summarizer
+
+	// SummaryPrefix introduces the summary in the system message Messages adds for it.
+	// Default: "Summary of the earlier conversation: "
+	SummaryPrefix string
+}
+
+// DefaultOptions returns the default manager options.
+func DefaultOptions() Options {
+	return Options{
+		TTL:           24 * time.Hour,
+		KeyPrefix:     "conversation:",
+		MaxTokens:     4096,
+		SummaryPrefix: "Summary of the earlier conversation: ",
+	}
+}
+
+// Manager keeps the message history of chat sessions within a token budget. It is safe
+// for concurrent use. Changes to a session are serialized within a Manager, but not
+// between Managers sharing a distributed cache.
+type Manager struct {
+	options Options
+
+	mu    sync.Mutex
+	locks map[string]*sessionLock
+}
+
+// sessionLock serializes changes to a session.
+type sessionLock struct {
+	sync.Mutex
+	refs int
+}
+
+// NewManager creates a Manager with the given options. Zero values fall back to the
+// defaults.
+func NewManager(options Options) *Manager {
+	defaults := DefaultOptions()
+	if options.Cache == nil {
+		options.Cache = cache.NewMemoryCache(cache.MemoryCacheOptions{})
+	}
+	if options.TTL <= 0 {
+		options.TTL = defaults.TTL
+	}
+	if options.KeyPrefix == "" {
+		options.KeyPrefix = defaults.KeyPrefix
+	}
+	if options.MaxTokens <= 0 {
+		options.MaxTokens = defaults.MaxTokens
+	}
+	if options.SummaryPrefix == "" {
+		options.SummaryPrefix = defaults.SummaryPrefix
+	}
+	return &Manager{options: options, locks: make(map[string]*sessionLock)}
+}
+
+// Append adds messages to a session, creating it if needed, and drops or summarizes the
+// oldest messages that no longer fit the token budget.
+func (m *Manager) Append(ctx context.Context, sessionID string, messages ...prompt.Message) error {
+	if sessionID == "" {
+		return errors.New("session ID is required")
+	}
+	unlock := m.lock(sessionID)
+	defer unlock()
+
+	session, err := m.load(sessionID)
+	if errors.Is(err, ErrSessionNotFound) {
+		session = &Session{ID: sessionID, CreatedAt: time.Now()}
+	} else if err != nil {
+		return err
+	}
+
+	session.Messages = append(session.Messages, messages...)
+	session.UpdatedAt = time.Now()
+	m.fit(ctx, session)
+	return m.save(session)
+}
+
+// Messages returns the messages to send to a model for a session: its system messages,
+// a system message with the summary if there is one, and the other messages kept. A
+// session that does not exist has no messages.
+func (m *Manager) Messages(ctx context.Context, sessionID string) ([]prompt.Message, error) {
+	session, err := m.load(sessionID)
+	if errors.Is(err, ErrSessionNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m.window(session), nil
+}
+
+// Get returns a session, or ErrSessionNotFound.
+func (m *Manager) Get(ctx context.Context, sessionID string) (*Session, error) {
+	return m.load(sessionID)
+}
+
+// Delete removes a session. Deleting a missing session is not an error.
+func (m *Manager) Delete(ctx context.Context, sessionID string) error {
+	unlock := m.lock(sessionID)
+	defer unlock()
+
+	if err := m.options.Cache.Delete(m.key(sessionID)); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// Tokens returns the number of tokens the messages of a session take, including the
+// summary.
+func (m *Manager) Tokens(ctx context.Context, sessionID string) (int, error) {
+	session, err := m.load(sessionID)
+	if errors.Is(err, ErrSessionNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return m.tokens(session), nil
+}
+
+// window returns the messages of a session with its summary after the system messages.
+func (m *Manager) window(session *Session) []prompt.Message {
+	messages := make([]prompt.Message, 0, len(session.Messages)+1)
+	summarized := session.Summary == ""
+	for _, message := range session.Messages {
+		if !summarized && message.Role != prompt.RoleSystem {
+			messages = append(messages, m.summaryMessage(session.Summary))
+			summarized = true
+		}
+		messages = append(messages, message)
+	}
+	if !summarized {
+		messages = append(messages, m.summaryMessage(session.Summary))
+	}
+	return messages
+}
+
+// summaryMessage returns the system message holding a summary.
+func (m *Manager) summaryMessage(summary string) prompt.Message {
+	return prompt.Message{Role: prompt.RoleSystem, Content: m.options.SummaryPrefix + summary}
+}
+
+// fit drops or summarizes the oldest messages until the session fits its budget.
+func (m *Manager) fit(ctx context.Context, session *Session) {
+	for m.tokens(session) > m.options.MaxTokens {
+		removed := m.evict(session)
+		if len(removed) == 0 {
+			return
+		}
+		session.Dropped += len(removed)
+		if m.options.Summarizer == nil {
+			return
+		}
+
+		summary, err := m.options.Summarizer.Summarize(ctx, session.Summary, removed)
+		if err != nil {
+			logging.Default().WarnContext(ctx, "failed to summarize conversation, dropping messages", "session", session.ID, "messages", len(removed), "error", err)
+			return
+		}
+		// The new summary may not fit, in which case more messages are folded into it
+		session.Summary = summary
+	}
+}
+
+// evict removes the oldest messages other than system messages and the newest message
+// until the session fits its budget, and returns them.
+func (m *Manager) evict(session *Session) []prompt.Message {
+	var removed []prompt.Message
+	for m.tokens(session) > m.options.MaxTokens {
+		i := 0
+		for i < len(session.Messages)-1 && session.Messages[i].Role == prompt.RoleSystem {
+			i++
+		}
+		if i >= len(session.Messages)-1 {
+			break
+		}
+		removed = append(removed, session.Messages[i])
+		session.Messages = append(session.Messages[:i:i], session.Messages[i+1:]...)
+	}
+	return removed
+}
+
+// tokens returns the number of tokens of a session's messages and summary.
+func (m *Manager) tokens(session *Session) int {
+	total := 0
+	for _, message := range session.Messages {
+		total += messageOverhead + m.count(message.Content)
+	}
+	if session.Summary != "" {
+		total += messageOverhead + m.count(m.options.SummaryPrefix+session.Summary)
+	}
+	return total
+}
+
+// tokenPattern matches the words and punctuation marks counted as tokens by default.
+var tokenPattern = regexp.MustCompile(`[\p{L}\p{N}_]+|[^\p{L}\p{N}_\s]`)
+
+// count returns the number of tokens in text.
+func (m *Manager) count(text string) int {
+	if m.options.Counter != nil {
+		return m.options.Counter.Count(text)
+	}
+	return len(tokenPattern.FindAllStringIndex(text, -1))
+}
+
+// key returns the cache key of a session.
+func (m *Manager) key(sessionID string) string {
+	return m.options.KeyPrefix + sessionID
+}
+
+// load reads a session from the cache.
+func (m *Manager) load(sessionID string) (*Session, error) {
+	var session Session
+	found, err := cache.GetValue(m.options.Cache, cache.JSONCodec{}, m.key(sessionID), &session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	if !found {
+		return nil, ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+// save writes a session to the cache, restarting its TTL.
+func (m *Manager) save(session *Session) error {
+	if err := cache.SetValue(m.options.Cache, cache.JSONCodec{}, m.key(session.ID), session, m.options.TTL); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// lock locks a session and returns the function unlocking it.
+func (m *Manager) lock(sessionID string) func() {
+	m.mu.Lock()
+	l, ok := m.locks[sessionID]
+	if !ok {
+		l = &sessionLock{}
+		m.locks[sessionID] = l
+	}
+	l.refs++
+	m.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		m.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(m.locks, sessionID)
+		}
+		m.mu.Unlock()
+	}
+}
@@ -0,0 +1,240 @@
+package conversation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/h2co32/gollama/internal/cache"
+	"github.com/h2co32/gollama/internal/models"
+	"github.com/h2co32/gollama/pkg/logging"
+	"github.com/h2co32/gollama/pkg/prompt"
+)
+
+func user(content string) prompt.Message {
+	return prompt.Message{Role: prompt.RoleUser, Content: content}
+}
+
+func assistant(content string) prompt.Message {
+	return prompt.Message{Role: prompt.RoleAssistant, Content: content}
+}
+
+func contents(messages []prompt.Message) []string {
+	result := make([]string, len(messages))
+	for i, message := range messages {
+		result[i] = message.Content
+	}
+	return result
+}
+
+func TestManagerAppendAndMessages(t *testing.T) {
+	ctx := context.Background()
+	manager := NewManager(Options{})
+
+	manager.Append(ctx, "s1", prompt.Message{Role: prompt.RoleSystem, Content: "Be brief."}, user("Hi"))
+	manager.Append(ctx, "s1", assistant("Hello!"))
+	manager.Append(ctx, "s2", user("Other session"))
+
+	messages, err := manager.Messages(ctx, "s1")
+	if err != nil {
+		t.Fatalf("Failed to get messages: %v", err)
+	}
+	expected := []string{"Be brief.", "Hi", "Hello!"}
+	if got := contents(messages); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	session, err := manager.Get(ctx, "s1")
+	if err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	if session.CreatedAt.IsZero() || session.UpdatedAt.Before(session.CreatedAt) {
+		t.Errorf("Unexpected timestamps %v and %v", session.CreatedAt, session.UpdatedAt)
+	}
+
+	if messages, _ := manager.Messages(ctx, "missing"); messages != nil {
+		t.Errorf("Expected no messages for a missing session, got %v", messages)
+	}
+	if _, err := manager.Get(ctx, "missing"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+	if err := manager.Append(ctx, "", user("x")); err == nil {
+		t.Error("Expected error for an empty session ID")
+	}
+}
+
+func TestManagerDropsOldestMessages(t *testing.T) {
+	ctx := context.Background()
+	// Each message below costs 4 tokens of overhead and 2 words
+	manager := NewManager(Options{MaxTokens: 20})
+
+	manager.Append(ctx, "s", prompt.Message{Role: prompt.RoleSystem, Content: "system prompt"})
+	for i := 1; i <= 4; i++ {
+		manager.Append(ctx, "s", user(fmt.Sprintf("message %d", i)))
+	}
+
+	messages, _ := manager.Messages(ctx, "s")
+	expected := []string{"system prompt", "message 3", "message 4"}
+	if got := contents(messages); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	if tokens, _ := manager.Tokens(ctx, "s"); tokens != 18 {
+		t.Errorf("Expected 18 tokens, got %d", tokens)
+	}
+	if session, _ := manager.Get(ctx, "s"); session.Dropped != 2 {
+		t.Errorf("Expected 2 dropped messages, got %d", session.Dropped)
+	}
+}
+
+func TestManagerKeepsNewestMessage(t *testing.T) {
+	ctx := context.Background()
+	manager := NewManager(Options{MaxTokens: 5})
+
+	manager.Append(ctx, "s", user("short"), user(strings.Repeat("long ", 20)))
+	messages, _ := manager.Messages(ctx, "s")
+	if len(messages) != 1 || !strings.HasPrefix(messages[0].Content, "long") {
+		t.Errorf("Expected only the newest message, got %v", contents(messages))
+	}
+}
+
+func TestManagerSummarizes(t *testing.T) {
+	ctx := context.Background()
+	// The summary costs 8 tokens, so folding in the first message does not make room
+	// for the fifth
+	var calls [][]string
+	summarizer := SummarizerFunc(func(ctx context.Context, summary string, messages []prompt.Message) (string, error) {
+		calls = append(calls, contents(messages))
+		return fmt.Sprintf("%d earlier", len(calls)), nil
+	})
+	manager := NewManager(Options{MaxTokens: 30, Summarizer: summarizer, SummaryPrefix: "Summary: "})
+
+	manager.Append(ctx, "s", prompt.Message{Role: prompt.RoleSystem, Content: "system"})
+	for i := 1; i <= 5; i++ {
+		manager.Append(ctx, "s", user(fmt.Sprintf("message %d", i)))
+	}
+
+	messages, _ := manager.Messages(ctx, "s")
+	expected := []string{"system", "Summary: 2 earlier", "message 4", "message 5"}
+	if got := contents(messages); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	if messages[1].Role != prompt.RoleSystem {
+		t.Errorf("Expected the summary in a system message, got %s", messages[1].Role)
+	}
+	if len(calls) != 2 || fmt.Sprint(calls[0]) != "[message 1]" || fmt.Sprint(calls[1]) != "[message 2 message 3]" {
+		t.Errorf("Unexpected summarized messages %v", calls)
+	}
+	if tokens, _ := manager.Tokens(ctx, "s"); tokens > 30 {
+		t.Errorf("Expected at most 30 tokens, got %d", tokens)
+	}
+}
+
+func TestManagerSummarizerFailureDrops(t *testing.T) {
+	var logs bytes.Buffer
+	previous := logging.Default()
+	logging.SetDefault(logging.New(logging.Options{Format: logging.FormatJSON, Sink: &logs}))
+	defer logging.SetDefault(previous)
+
+	ctx := context.Background()
+	summarizer := SummarizerFunc(func(ctx context.Context, summary string, messages []prompt.Message) (string, error) {
+		return "", errors.New("model unavailable")
+	})
+	manager := NewManager(Options{MaxTokens: 10, Summarizer: summarizer})
+
+	if err := manager.Append(ctx, "s", user("one two"), user("three four")); err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+	messages, _ := manager.Messages(ctx, "s")
+	if got := contents(messages); fmt.Sprint(got) != "[three four]" {
+		t.Errorf("Expected [three four], got %v", got)
+	}
+	if !strings.Contains(logs.String(), "failed to summarize conversation") {
+		t.Errorf("Expected a warning, got %q", logs.String())
+	}
+}
+
+func TestManagerTTL(t *testing.T) {
+	ctx := context.Background()
+	manager := NewManager(Options{TTL: 50 * time.Millisecond})
+
+	manager.Append(ctx, "s", user("hello"))
+	time.Sleep(100 * time.Millisecond)
+	if _, err := manager.Get(ctx, "s"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected the session to expire, got %v", err)
+	}
+}
+
+func TestManagerDiskPersistence(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	disk, err := cache.NewDiskCache(dir)
+	if err != nil {
+		t.Fatalf("Failed to create disk cache: %v", err)
+	}
+	NewManager(Options{Cache: disk}).Append(ctx, "s", user("persisted"))
+
+	reopened, _ := cache.NewDiskCache(dir)
+	manager := NewManager(Options{Cache: reopened})
+	messages, err := manager.Messages(ctx, "s")
+	if err != nil || fmt.Sprint(contents(messages)) != "[persisted]" {
+		t.Errorf("Expected persisted message, got %v, %v", contents(messages), err)
+	}
+
+	manager.Delete(ctx, "s")
+	if _, err := manager.Get(ctx, "s"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound after delete, got %v", err)
+	}
+}
+
+func TestManagerConcurrentAppends(t *testing.T) {
+	ctx := context.Background()
+	manager := NewManager(Options{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			manager.Append(ctx, "s", user(fmt.Sprint(i)))
+		}(i)
+	}
+	wg.Wait()
+
+	session, _ := manager.Get(ctx, "s")
+	if len(session.Messages) != 50 {
+		t.Errorf("Expected 50 messages, got %d", len(session.Messages))
+	}
+	if len(manager.locks) != 0 {
+		t.Errorf("Expected session locks to be released, got %d", len(manager.locks))
+	}
+}
+
+func TestOllamaSummarizer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req models.ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		transcript := req.Messages[len(req.Messages)-1].Content
+		if !strings.Contains(transcript, "Earlier summary: before") || !strings.Contains(transcript, "user: hi") {
+			t.Errorf("Unexpected transcript %q", transcript)
+		}
+		json.NewEncoder(w).Encode(models.ChatResponse{Message: models.Message{Role: prompt.RoleAssistant, Content: " The user said hi. "}, Done: true})
+	}))
+	defer server.Close()
+
+	client := models.NewOllamaClientWithOptions(models.OllamaClientOptions{BaseURL: server.URL, ModelDir: t.TempDir()})
+	summary, err := NewOllamaSummarizer(client, "llama3").Summarize(context.Background(), "before", []prompt.Message{user("hi")})
+	if err != nil {
+		t.Fatalf("Failed to summarize: %v", err)
+	}
+	if summary != "The user said hi." {
+		t.Errorf("Expected %q, got %q", "The user said hi.", summary)
+	}
+}
@@ -0,0 +1,55 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/h2co32/gollama/internal/models"
+	"github.com/h2co32/gollama/pkg/prompt"
+)
+
+// summaryInstructions is the system prompt of OllamaSummarizer.
+const summaryInstructions = "You summarize conversations between a user and an assistant. " +
+	"Write a concise summary that keeps the facts, decisions, names, and open questions " +
+	"needed to continue the conversation. Reply with the summary only."
+
+// OllamaSummarizer summarizes conversations with a chat model served by Ollama.
+type OllamaSummarizer struct {
+	client *models.OllamaClient
+	model  string
+}
+
+// NewOllamaSummarizer creates a Summarizer that summarizes with model, such as "llama3".
+func NewOllamaSummarizer(client *models.OllamaClient, model string) *OllamaSummarizer {
+	return &OllamaSummarizer{client: client, model: model}
+}
+
+// Summarize returns a summary of the earlier summary followed by messages.
+func (s *OllamaSummarizer) Summarize(ctx context.Context, summary string, messages []prompt.Message) (string, error) {
+	var transcript strings.Builder
+	if summary != "" {
+		fmt.Fprintf(&transcript, "Earlier summary: %s\n\n", summary)
+	}
+	for _, message := range messages {
+		fmt.Fprintf(&transcript, "%s: %s\n", message.Role, message.Content)
+	}
+
+	req := models.ChatRequest{
+		Model: s.model,
+		Messages: []models.Message{
+			{Role: prompt.RoleSystem, Content: summaryInstructions},
+			{Role: prompt.RoleUser, Content: transcript.String()},
+		},
+		Stream: new(bool),
+	}
+	var reply strings.Builder
+	err := s.client.Chat(ctx, req, func(res models.ChatResponse) error {
+		reply.WriteString(res.Message.Content)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize messages: %w", err)
+	}
+	return strings.TrimSpace(reply.String()), nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/http/httptest"
 	"time"
 
 	"go.opentelemetry.io/otel/attribute"
@@ -71,23 +72,12 @@ func ObservabilityHTTPExample() {
 	}
 	defer tp.Shutdown(context.Background())
 
-	// Create a traced HTTP handler
-	http.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
-		// Extract the context from the request (in a real app, you would use
-		// OpenTelemetry's HTTP middleware to propagate the context)
+	// Create a handler. The middleware below starts a span for each request,
+	// continuing the caller's trace, and records the method, route, and status.
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /hello", func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		
-		// Create a span for this handler
-		ctx, span := tp.StartSpan(ctx, "hello-handler")
-		defer span.End()
-		
-		// Add request information to the span
-		observability.AddSpanAttributes(ctx,
-			attribute.String("http.method", r.Method),
-			attribute.String("http.path", r.URL.Path),
-			attribute.String("http.user_agent", r.UserAgent()),
-		)
-		
 		// Simulate a database query
 		err := observability.WithSpanTimed(ctx, "database-query", func(ctx context.Context) error {
 			// Simulate database work
@@ -110,9 +100,19 @@ func ObservabilityHTTPExample() {
 	})
 
 	// In a real application, you would start the server:
-	// log.Fatal(http.ListenAndServe(":8080", nil))
+	// log.Fatal(http.ListenAndServe(":8080", observability.HTTPMiddleware(mux)))
+	server := httptest.NewServer(observability.HTTPMiddleware(mux))
+	defer server.Close()
+
+	// Clients pass their trace on to the services they call with a traced transport
+	client := &http.Client{Transport: observability.HTTPTransport(nil)}
+	res, err := client.Get(server.URL + "/hello")
+	if err != nil {
+		log.Fatalf("Failed to call the server: %v", err)
+	}
+	res.Body.Close()
 	
-	fmt.Println("HTTP tracing example setup completed")
+	fmt.Println("HTTP tracing example completed with status", res.Status)
 	fmt.Println("In a real application, the server would be running at http://localhost:8080/hello")
 }
 
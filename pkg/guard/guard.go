@@ -0,0 +1,233 @@
+// Package guard screens prompts with rule-based checks before they reach a model, such
+// as attempts to override the model's instructions, deny-listed content, oversized
+// prompts, and links to hosts that are not allowed.
+//
+// Checks return a Result listing every Violation found, so callers can block, log, or
+// only count them. Middleware applies the checks to the prompts in Ollama and
+// OpenAI-compatible API requests and rejects requests that violate them.
+//
+// Rules are heuristics: they catch common injection phrasing and known-bad content
+// cheaply, but determined attackers can word around them, so they complement rather
+// than replace isolating what a model is allowed to do.
+//
+// Example usage:
+//
+//	g, err := guard.New(guard.Options{
+//		MaxPromptLength: 8000,
+//		DenyPatterns:    []string{`(?i)\bDROP\s+TABLE\b`},
+//		AllowedURLHosts: []string{"example.com"},
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	if result := g.Check(prompt); !result.Allowed() {
+//		return fmt.Errorf("prompt rejected: %s", result.Violations[0].Message)
+//	}
+//
+//	http.Handle("/api/", g.Middleware(proxy))
+package guard
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Version represents the current package version following semantic versioning.
+const Version = "1.0.0"
+
+// Rule identifies the check a violation failed.
+type Rule string
+
+const (
+	// RuleMaxLength is violated by prompts longer than Options.MaxPromptLength.
+	RuleMaxLength Rule = "max_length"
+
+	// RuleDenyPattern is violated by prompts matching one of Options.DenyPatterns.
+	RuleDenyPattern Rule = "deny_pattern"
+
+	// RuleRoleOverride is violated by prompts trying to override the model's
+	// instructions or role, matching one of Options.RoleOverridePatterns.
+	RuleRoleOverride Rule = "role_override"
+
+	// RuleURL is violated by links to hosts missing from Options.AllowedURLHosts.
+	RuleURL Rule = "url_not_allowed"
+)
+
+// DefaultRoleOverridePatterns match common phrasings of prompt injection: instructions
+// to ignore earlier instructions, to reveal the system prompt, to switch to an
+// unrestricted persona, and chat template markers that start a new system turn.
+var DefaultRoleOverridePatterns = []string{
+	`(?i)\b(ignore|disregard|forget|override)\s+(all\s+|any\s+|the\s+|your\s+)*(previous|prior|above|earlier|preceding|original|system)\s+(instructions|prompts?|rules|directions|messages|context)`,
+	`(?i)\b(reveal|show|print|repeat|output|leak)\s+(me\s+)?(your|the)\s+(system\s+prompt|initial\s+instructions|hidden\s+instructions|instructions\s+above)`,
+	`(?i)\byou\s+are\s+now\s+(in\s+)?(DAN|developer\s+mode|jailbroken|unrestricted|unfiltered|an?\s+unrestricted)`,
+	`(?i)\bpretend\s+(that\s+)?you\s+(have\s+no|are\s+not\s+bound\s+by|don'?t\s+have)\s+(restrictions|rules|guidelines|filters)`,
+	`<\|im_start\|>\s*system|<<SYS>>|\[/?INST\]|<\|start_header_id\|>\s*system`,
+}
+
+// maxMatchLength is the most characters of the offending text kept in a violation.
+const maxMatchLength = 100
+
+// Options configures a Guard.
+type Options struct {
+	// MaxPromptLength is the most characters allowed across the text of a prompt.
+	// Optional. By default prompts of any length are allowed.
+	MaxPromptLength int
+
+	// DenyPatterns are regular expressions prompts must not match, such as known-bad
+	// content or secrets. Use the (?i) flag to match regardless of case. Optional.
+	DenyPatterns []string
+
+	// RoleOverridePatterns are regular expressions matching attempts to override the
+	// model's instructions. A non-nil empty slice disables the check.
+	// Default: DefaultRoleOverridePatterns
+	RoleOverridePatterns []string
+
+	// AllowedURLHosts lists the hosts links in prompts may point to, including their
+	// subdomains, such as "example.com" for "docs.example.com". Optional. By default
+	// links are not checked.
+	AllowedURLHosts []string
+
+	// OnViolation is called with each request Middleware rejects, for example to log
+	// or count them. Optional.
+	OnViolation func(r *http.Request, result Result)
+}
+
+// DefaultOptions returns the default guard options.
+func DefaultOptions() Options {
+	return Options{
+		RoleOverridePatterns: DefaultRoleOverridePatterns,
+	}
+}
+
+// Violation is a check a prompt failed.
+type Violation struct {
+	// Rule is the check that failed.
+	Rule Rule `json:"rule"`
+
+	// Message describes the violation.
+	Message string `json:"message"`
+
+	// Match is the offending text, truncated to 100 characters. Empty for RuleMaxLength.
+	Match string `json:"match,omitempty"`
+}
+
+// Result is the outcome of checking a prompt.
+type Result struct {
+	// Violations lists every check the prompt failed.
+	Violations []Violation `json:"violations"`
+}
+
+// Allowed reports whether the prompt passed every check.
+func (r Result) Allowed() bool {
+	return len(r.Violations) == 0
+}
+
+// Guard checks prompts against a set of rules. It is safe for concurrent use.
+type Guard struct {
+	options      Options
+	deny         []*regexp.Regexp
+	roleOverride []*regexp.Regexp
+}
+
+// New creates a Guard with the given options. It returns an error if a pattern does not
+// compile.
+func New(options Options) (*Guard, error) {
+	if options.RoleOverridePatterns == nil {
+		options.RoleOverridePatterns = DefaultOptions().RoleOverridePatterns
+	}
+
+	g := &Guard{options: options}
+	var err error
+	if g.deny, err = compile(options.DenyPatterns); err != nil {
+		return nil, err
+	}
+	if g.roleOverride, err = compile(options.RoleOverridePatterns); err != nil {
+		return nil, err
+	}
+	g.options.AllowedURLHosts = make([]string, len(options.AllowedURLHosts))
+	for i, host := range options.AllowedURLHosts {
+		g.options.AllowedURLHosts[i] = strings.ToLower(strings.TrimPrefix(host, "."))
+	}
+	return g, nil
+}
+
+// compile compiles a list of patterns.
+func compile(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile pattern %q: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// Check checks the parts of a prompt, such as the messages of a chat, and returns every
+// violation found. The length limit applies to all parts together.
+func (g *Guard) Check(parts ...string) Result {
+	var result Result
+
+	if g.options.MaxPromptLength > 0 {
+		length := 0
+		for _, part := range parts {
+			length += utf8.RuneCountInString(part)
+		}
+		if length > g.options.MaxPromptLength {
+			result.add(RuleMaxLength, fmt.Sprintf("prompt is %d characters, over the limit of %d", length, g.options.MaxPromptLength), "")
+		}
+	}
+
+	for _, part := range parts {
+		for _, re := range g.roleOverride {
+			if match := re.FindString(part); match != "" {
+				result.add(RuleRoleOverride, "prompt tries to override the model's instructions", match)
+			}
+		}
+		for _, re := range g.deny {
+			if match := re.FindString(part); match != "" {
+				result.add(RuleDenyPattern, "prompt matches a denied pattern", match)
+			}
+		}
+		if len(g.options.AllowedURLHosts) > 0 {
+			for _, link := range urlPattern.FindAllString(part, -1) {
+				if !g.allowedURL(link) {
+					result.add(RuleURL, "prompt links to a host that is not allowed", link)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// urlPattern matches http and https links.
+var urlPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"'()\[\]{}]+`)
+
+// allowedURL reports whether a link points to an allowed host or one of its subdomains.
+func (g *Guard) allowedURL(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range g.options.AllowedURLHosts {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// add records a violation.
+func (r *Result) add(rule Rule, message, match string) {
+	if utf8.RuneCountInString(match) > maxMatchLength {
+		match = string([]rune(match)[:maxMatchLength])
+	}
+	r.Violations = append(r.Violations, Violation{Rule: rule, Message: message, Match: match})
+}
@@ -0,0 +1,102 @@
+package guard
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/h2co32/gollama/pkg/logging"
+	"github.com/h2co32/gollama/pkg/middleware"
+)
+
+// Middleware checks the prompt of each request with a JSON body before passing it on.
+// The prompt is the text of the "prompt", "system", "suffix", and "input" fields and
+// of the "content" of each of the "messages", covering the Ollama generate, chat, and
+// embed APIs and their OpenAI-compatible counterparts. Requests without such a body
+// pass through.
+//
+// Requests that fail a check are rejected with 400 Bad Request and a JSON body of the
+// form {"error": "request blocked by guard", "violations": [...]}.
+func (g *Guard) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			if !middleware.WriteLimitError(w, err) {
+				middleware.JSONResponse(w, http.StatusBadRequest, map[string]string{"error": "failed to read request body"})
+			}
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		var request map[string]any
+		if json.Unmarshal(body, &request) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		result := g.Check(promptText(request)...)
+		if result.Allowed() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rules := make([]string, 0, len(result.Violations))
+		for _, violation := range result.Violations {
+			rules = append(rules, string(violation.Rule))
+		}
+		logging.Default().WarnContext(r.Context(), "request blocked by guard", "path", r.URL.Path, "rules", rules)
+		if g.options.OnViolation != nil {
+			g.options.OnViolation(r, result)
+		}
+		middleware.JSONResponse(w, http.StatusBadRequest, map[string]any{
+			"error":      "request blocked by guard",
+			"violations": result.Violations,
+		})
+	})
+}
+
+// promptFields are the request fields holding prompt text.
+var promptFields = []string{"prompt", "system", "suffix", "input"}
+
+// promptText returns the prompt text of a decoded API request.
+func promptText(request map[string]any) []string {
+	var parts []string
+	for _, field := range promptFields {
+		parts = appendText(parts, request[field])
+	}
+	if messages, ok := request["messages"].([]any); ok {
+		for _, message := range messages {
+			if message, ok := message.(map[string]any); ok {
+				parts = appendText(parts, message["content"])
+			}
+		}
+	}
+	return parts
+}
+
+// appendText appends the text of a field that is a string, a list of strings, or a list
+// of content parts with "text" fields, as in OpenAI multimodal messages.
+func appendText(parts []string, value any) []string {
+	switch value := value.(type) {
+	case string:
+		return append(parts, value)
+	case []any:
+		for _, item := range value {
+			switch item := item.(type) {
+			case string:
+				parts = append(parts, item)
+			case map[string]any:
+				if text, ok := item["text"].(string); ok {
+					parts = append(parts, text)
+				}
+			}
+		}
+	}
+	return parts
+}
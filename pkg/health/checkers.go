@@ -0,0 +1,80 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Redis checks that a Redis server, Sentinel deployment, or cluster answers a PING.
+func Redis(client redis.UniversalClient) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		if err := client.Ping(ctx).Err(); err != nil {
+			return fmt.Errorf("redis is unreachable: %w", err)
+		}
+		return nil
+	})
+}
+
+// HTTP checks that a GET of url, such as the root of an Ollama instance, succeeds with
+// a 2xx status. A nil client uses http.DefaultClient.
+func HTTP(client *http.Client, url string) Checker {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return CheckerFunc(func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to reach %s: %w", url, err)
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("%s responded with status %d", url, resp.StatusCode)
+		}
+		return nil
+	})
+}
+
+// DirWritable checks that a file can be created in dir, such as a model directory.
+func DirWritable(dir string) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		f, err := os.CreateTemp(dir, ".health-*")
+		if err != nil {
+			return fmt.Errorf("directory %s is not writable: %w", dir, err)
+		}
+		name := f.Name()
+		_, err = f.Write([]byte("ok"))
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		os.Remove(name)
+		if err != nil {
+			return fmt.Errorf("directory %s is not writable: %w", dir, err)
+		}
+		return nil
+	})
+}
+
+// DiskSpace checks that the file system holding path has at least minFree bytes
+// available to unprivileged users.
+func DiskSpace(path string, minFree uint64) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		free, err := freeSpace(path)
+		if err != nil {
+			return fmt.Errorf("failed to read free disk space of %s: %w", path, err)
+		}
+		if free < minFree {
+			return fmt.Errorf("%d bytes free on %s, below the minimum of %d", free, path, minFree)
+		}
+		return nil
+	})
+}
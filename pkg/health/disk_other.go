@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd
+
+package health
+
+import "errors"
+
+// freeSpace is not supported on this platform, so DiskSpace checks fail there.
+func freeSpace(path string) (uint64, error) {
+	return 0, errors.New("free disk space is not supported on this platform")
+}
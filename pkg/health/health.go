@@ -0,0 +1,217 @@
+// Package health serves liveness and readiness endpoints that aggregate the checks of a
+// service's dependencies, such as Redis, backend Ollama instances, and local storage.
+//
+// Liveness, served at /healthz by convention, reports whether the process should be
+// restarted, so it only runs the checks added with AddLivenessCheck, usually none.
+// Readiness, served at /readyz, reports whether the process should receive traffic,
+// and runs the checks added with AddReadinessCheck. Both respond with 200 OK when
+// every check passes and 503 Service Unavailable otherwise, with the status of each
+// check in the body:
+//
+//	{"status": "fail", "checks": {"redis": {"status": "ok", "duration_ms": 0.4},
+//	  "backends": {"status": "fail", "error": "no healthy servers", "duration_ms": 0.1}}}
+//
+// Example usage:
+//
+//	h := health.New(health.DefaultOptions())
+//	h.AddReadinessCheck("redis", health.Redis(client))
+//	h.AddReadinessCheck("models", health.DirWritable("./models"))
+//	h.AddReadinessCheck("disk", health.DiskSpace("./models", 10<<30))
+//
+//	mux.Handle("/healthz", h.LivenessHandler())
+//	mux.Handle("/readyz", h.ReadinessHandler())
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/h2co32/gollama/pkg/middleware"
+)
+
+// Version represents the current package version following semantic versioning.
+const Version = "1.0.0"
+
+// Statuses of a check and of a Report.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Checker checks a dependency, returning an error if it is unavailable.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to a Checker.
+type CheckerFunc func(ctx context.Context) error
+
+// Check calls f.
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Options configures a Health.
+type Options struct {
+	// Timeout bounds each check. A check still running when it expires fails.
+	// Default: 5 seconds
+	Timeout time.Duration
+}
+
+// DefaultOptions returns the default health options.
+func DefaultOptions() Options {
+	return Options{Timeout: 5 * time.Second}
+}
+
+// CheckResult is the outcome of a check.
+type CheckResult struct {
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// Report is the outcome of a set of checks. Status is StatusOK when every check passed.
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// namedChecker is a check added to a Health.
+type namedChecker struct {
+	name    string
+	checker Checker
+}
+
+// Health runs liveness and readiness checks. It is safe for concurrent use.
+type Health struct {
+	options   Options
+	mu        sync.RWMutex
+	liveness  []namedChecker
+	readiness []namedChecker
+}
+
+// New creates a Health without checks. A non-positive Timeout falls back to the default.
+func New(options Options) *Health {
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultOptions().Timeout
+	}
+	return &Health{options: options}
+}
+
+// AddLivenessCheck adds a check that must pass for the process to be considered alive.
+// Only add checks of the process itself: a failing liveness check gets it restarted,
+// which does not help when a dependency is down.
+func (h *Health) AddLivenessCheck(name string, checker Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.liveness = append(h.liveness, namedChecker{name: name, checker: checker})
+}
+
+// AddReadinessCheck adds a check that must pass for the process to receive traffic.
+func (h *Health) AddReadinessCheck(name string, checker Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.readiness = append(h.readiness, namedChecker{name: name, checker: checker})
+}
+
+// Liveness runs the liveness checks.
+func (h *Health) Liveness(ctx context.Context) Report {
+	h.mu.RLock()
+	checks := h.liveness
+	h.mu.RUnlock()
+	return h.run(ctx, checks)
+}
+
+// Readiness runs the readiness checks.
+func (h *Health) Readiness(ctx context.Context) Report {
+	h.mu.RLock()
+	checks := h.readiness
+	h.mu.RUnlock()
+	return h.run(ctx, checks)
+}
+
+// run runs checks concurrently, each bounded by the timeout.
+func (h *Health) run(ctx context.Context, checks []namedChecker) Report {
+	report := Report{Status: StatusOK}
+	if len(checks) == 0 {
+		return report
+	}
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = h.check(ctx, c.checker)
+		}()
+	}
+	wg.Wait()
+
+	report.Checks = make(map[string]CheckResult, len(checks))
+	for i, c := range checks {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+// check runs a single checker, failing it if it outlasts the timeout or panics.
+func (h *Health) check(ctx context.Context, checker Checker) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, h.options.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("check panicked: %v", r)
+			}
+		}()
+		done <- checker.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// Checkers that ignore the context are abandoned rather than waited for
+		err = fmt.Errorf("check timed out: %w", ctx.Err())
+	}
+
+	result := CheckResult{Status: StatusOK, DurationMs: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// LivenessHandler serves the liveness report.
+func (h *Health) LivenessHandler() http.Handler {
+	return reportHandler(h.Liveness)
+}
+
+// ReadinessHandler serves the readiness report.
+func (h *Health) ReadinessHandler() http.Handler {
+	return reportHandler(h.Readiness)
+}
+
+// reportHandler serves the report of run, with 503 Service Unavailable if it failed.
+func reportHandler(run func(ctx context.Context) Report) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := run(r.Context())
+		status := http.StatusOK
+		if report.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		middleware.JSONResponse(w, status, report)
+	})
+}
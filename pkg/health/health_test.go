@@ -0,0 +1,115 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestHealth(t *testing.T) {
+	h := New(Options{Timeout: 50 * time.Millisecond})
+	h.AddReadinessCheck("ok", CheckerFunc(func(ctx context.Context) error { return nil }))
+
+	rec := httptest.NewRecorder()
+	h.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+
+	h.AddReadinessCheck("down", CheckerFunc(func(ctx context.Context) error { return errors.New("backend down") }))
+	h.AddReadinessCheck("slow", CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	h.AddReadinessCheck("panics", CheckerFunc(func(ctx context.Context) error { panic("boom") }))
+
+	rec = httptest.NewRecorder()
+	h.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", rec.Code)
+	}
+	var report Report
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	if report.Status != StatusFail || len(report.Checks) != 4 {
+		t.Fatalf("Unexpected report %+v", report)
+	}
+	if report.Checks["ok"].Status != StatusOK || report.Checks["down"].Error != "backend down" {
+		t.Errorf("Unexpected checks %+v", report.Checks)
+	}
+	for _, name := range []string{"slow", "panics"} {
+		if report.Checks[name].Status != StatusFail {
+			t.Errorf("Expected check %s to fail, got %+v", name, report.Checks[name])
+		}
+	}
+
+	// Liveness does not run the readiness checks
+	rec = httptest.NewRecorder()
+	h.LivenessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected liveness status 200, got %d", rec.Code)
+	}
+	h.AddLivenessCheck("deadlock", CheckerFunc(func(ctx context.Context) error { return errors.New("stuck") }))
+	if report := h.Liveness(context.Background()); report.Status != StatusFail {
+		t.Errorf("Expected liveness to fail, got %+v", report)
+	}
+}
+
+func TestCheckers(t *testing.T) {
+	ctx := context.Background()
+
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	defer client.Close()
+	if err := Redis(client).Check(ctx); err != nil {
+		t.Errorf("Expected Redis to be reachable, got %v", err)
+	}
+	s.Close()
+	if err := Redis(client).Check(ctx); err == nil {
+		t.Error("Expected error when Redis is down")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	if err := HTTP(nil, server.URL+"/").Check(ctx); err != nil {
+		t.Errorf("Expected the backend to be available, got %v", err)
+	}
+	if err := HTTP(server.Client(), server.URL+"/broken").Check(ctx); err == nil {
+		t.Error("Expected error for a 500 response")
+	}
+
+	dir := t.TempDir()
+	if err := DirWritable(dir).Check(ctx); err != nil {
+		t.Errorf("Expected the directory to be writable, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Expected the check to clean up, found %d files", len(entries))
+	}
+	if err := DirWritable(filepath.Join(dir, "missing")).Check(ctx); err == nil {
+		t.Error("Expected error for a missing directory")
+	}
+
+	if err := DiskSpace(dir, 1).Check(ctx); err != nil {
+		t.Errorf("Expected free disk space, got %v", err)
+	}
+	if err := DiskSpace(dir, 1<<62).Check(ctx); err == nil {
+		t.Error("Expected error below the minimum free space")
+	}
+}
@@ -0,0 +1,172 @@
+// Package logging provides structured, leveled logging built on log/slog.
+//
+// Loggers write records with structured fields to a pluggable sink, as text or JSON,
+// and add the trace and span IDs of the span in the context passed to the *Context
+// methods, so log lines can be correlated with traces. The gollama packages log
+// through the global default logger, which applications can replace with SetDefault.
+//
+// Example usage:
+//
+//	// Log JSON at debug level and above
+//	logging.SetDefault(logging.New(logging.Options{
+//		Level:  logging.LevelDebug,
+//		Format: logging.FormatJSON,
+//	}))
+//
+//	// Log with structured fields
+//	logging.Default().Info("model loaded", "model", "llama3", "bytes", 4<<30)
+//
+//	// Correlate with the trace in ctx
+//	logging.Default().WarnContext(ctx, "backend slow", "backend", "gpu-1:11434")
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Levels of log records, from the most to the least verbose.
+const (
+	LevelDebug = slog.LevelDebug
+	LevelInfo  = slog.LevelInfo
+	LevelWarn  = slog.LevelWarn
+	LevelError = slog.LevelError
+)
+
+// Format is the encoding of log records written to a sink.
+type Format string
+
+const (
+	// FormatText writes records as key=value pairs.
+	FormatText Format = "text"
+
+	// FormatJSON writes records as JSON objects, one per line.
+	FormatJSON Format = "json"
+)
+
+// Options configures a logger created by New.
+type Options struct {
+	// Level is the minimum level of the records logged. A *slog.LevelVar changes it
+	// while the logger is in use.
+	// Default: LevelInfo
+	Level slog.Leveler
+
+	// Format is the encoding of the records written to Sink.
+	// Default: FormatText
+	Format Format
+
+	// Sink is where records are written.
+	// Default: os.Stderr
+	Sink io.Writer
+
+	// Handler handles the records instead of a handler writing Format to Sink, for
+	// example to send them to a log collector. Records below Level are still dropped,
+	// and trace IDs are still added. Optional.
+	Handler slog.Handler
+
+	// AddSource adds the file and line of the call that logged each record.
+	// Default: false
+	AddSource bool
+}
+
+// DefaultOptions returns the default logger options.
+func DefaultOptions() Options {
+	return Options{
+		Level:  LevelInfo,
+		Format: FormatText,
+		Sink:   os.Stderr,
+	}
+}
+
+// New creates a logger with the given options. Zero values fall back to the defaults.
+func New(options Options) *slog.Logger {
+	defaults := DefaultOptions()
+	if options.Level == nil {
+		options.Level = defaults.Level
+	}
+	if options.Format == "" {
+		options.Format = defaults.Format
+	}
+	if options.Sink == nil {
+		options.Sink = defaults.Sink
+	}
+
+	handler := options.Handler
+	if handler == nil {
+		handlerOptions := &slog.HandlerOptions{Level: options.Level, AddSource: options.AddSource}
+		if options.Format == FormatJSON {
+			handler = slog.NewJSONHandler(options.Sink, handlerOptions)
+		} else {
+			handler = slog.NewTextHandler(options.Sink, handlerOptions)
+		}
+	}
+	return slog.New(&traceHandler{Handler: handler, level: options.Level})
+}
+
+// defaultLogger is the logger returned by Default.
+var defaultLogger atomic.Pointer[slog.Logger]
+
+func init() {
+	defaultLogger.Store(New(DefaultOptions()))
+}
+
+// Default returns the global default logger, which the gollama packages log through.
+// Unless replaced with SetDefault, it writes text to os.Stderr at LevelInfo and above.
+func Default() *slog.Logger {
+	return defaultLogger.Load()
+}
+
+// SetDefault replaces the global default logger. A nil logger discards every record.
+// It does not change slog's own default logger.
+func SetDefault(logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.New(discardHandler{})
+	}
+	defaultLogger.Store(logger)
+}
+
+// traceHandler adds the trace and span IDs of the span in a record's context to the
+// record, and drops records below its level.
+type traceHandler struct {
+	slog.Handler
+	level slog.Leveler
+}
+
+// Enabled reports whether records at level are logged.
+func (h *traceHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() && h.Handler.Enabled(ctx, level)
+}
+
+// Handle adds the trace and span IDs, if any, and passes the record on.
+func (h *traceHandler) Handle(ctx context.Context, record slog.Record) error {
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", span.TraceID().String()),
+			slog.String("span_id", span.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs returns a handler that adds attrs to every record.
+func (h *traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &traceHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+// WithGroup returns a handler that puts the attributes of every record in a group.
+func (h *traceHandler) WithGroup(name string) slog.Handler {
+	return &traceHandler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
+
+// discardHandler drops every record.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Options{Format: FormatJSON, Sink: &buf})
+
+	logger.Debug("dropped")
+	logger.Info("model loaded", "model", "llama3", "bytes", 42)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected one JSON record, got %q: %v", buf.String(), err)
+	}
+	if record["level"] != "INFO" || record["msg"] != "model loaded" || record["model"] != "llama3" || record["bytes"] != float64(42) {
+		t.Errorf("Expected the record to have its level, message, and fields, got %v", record)
+	}
+	if _, ok := record["trace_id"]; ok {
+		t.Error("Expected no trace ID without a span")
+	}
+}
+
+func TestNewLevel(t *testing.T) {
+	var buf bytes.Buffer
+	level := new(slog.LevelVar)
+	level.Set(LevelWarn)
+	logger := New(Options{Level: level, Sink: &buf})
+
+	logger.Info("dropped")
+	if buf.Len() != 0 {
+		t.Errorf("Expected info records to be dropped at warn level, got %q", buf.String())
+	}
+
+	level.Set(LevelDebug)
+	logger.Debug("kept")
+	if !strings.Contains(buf.String(), "msg=kept") {
+		t.Errorf("Expected debug records to be kept once the level is lowered, got %q", buf.String())
+	}
+}
+
+func TestTraceCorrelation(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Options{Format: FormatJSON, Sink: &buf}).With("component", "queue")
+
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "request")
+	defer span.End()
+	logger.InfoContext(ctx, "job started")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected one JSON record, got %q: %v", buf.String(), err)
+	}
+	if record["trace_id"] != span.SpanContext().TraceID().String() || record["span_id"] != span.SpanContext().SpanID().String() {
+		t.Errorf("Expected the record to carry the span's IDs, got %v", record)
+	}
+	if record["component"] != "queue" {
+		t.Errorf("Expected the record to keep the logger's fields, got %v", record)
+	}
+}
+
+func TestCustomHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Options{
+		Level:   LevelWarn,
+		Handler: slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: LevelDebug}),
+	})
+
+	logger.Info("dropped")
+	logger.Warn("kept")
+	if strings.Contains(buf.String(), "dropped") || !strings.Contains(buf.String(), "kept") {
+		t.Errorf("Expected the handler to receive records at the logger's level, got %q", buf.String())
+	}
+}
+
+func TestSetDefault(t *testing.T) {
+	previous := Default()
+	defer SetDefault(previous)
+
+	var buf bytes.Buffer
+	SetDefault(New(Options{Sink: &buf}))
+	Default().Info("hello")
+	if !strings.Contains(buf.String(), "msg=hello") {
+		t.Errorf("Expected the default logger to be replaced, got %q", buf.String())
+	}
+
+	SetDefault(nil)
+	Default().Error("discarded")
+	if strings.Contains(buf.String(), "discarded") {
+		t.Error("Expected a nil default logger to discard records")
+	}
+}
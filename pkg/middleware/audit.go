@@ -0,0 +1,196 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/h2co32/gollama/pkg/logging"
+)
+
+// AuditEvent records the outcome of authenticating one request.
+type AuditEvent struct {
+	// Time is when the request was authenticated.
+	Time time.Time `json:"time"`
+
+	// Success reports whether the request was accepted.
+	Success bool `json:"success"`
+
+	// Principal is the "sub" claim of the accepted request: the JWT or OIDC subject,
+	// the API key's client name, or the client certificate's common name. It is empty
+	// for rejected requests and HMAC authentication, which has no principal.
+	Principal string `json:"principal,omitempty"`
+
+	// AuthType is the authentication type that accepted the request, or the types
+	// tried, separated by commas, if none did.
+	AuthType string `json:"auth_type"`
+
+	// Method is the request method.
+	Method string `json:"method"`
+
+	// Route is the pattern of the http.ServeMux route that matched the request if the
+	// middleware is registered under one, or else the request path.
+	Route string `json:"route"`
+
+	// IP is the client's IP address, taken from the connection's remote address.
+	IP string `json:"ip"`
+
+	// Reason is why the request was rejected. It is empty for accepted requests.
+	Reason string `json:"reason,omitempty"`
+}
+
+// AuditSink receives an AuditEvent for every request the AuthMiddleware authenticates.
+// Record is called synchronously before the request is handled or rejected, and may be
+// called concurrently.
+type AuditSink interface {
+	Record(ctx context.Context, event AuditEvent) error
+}
+
+// AuditFunc adapts a function to an AuditSink.
+type AuditFunc func(ctx context.Context, event AuditEvent) error
+
+// Record calls f.
+func (f AuditFunc) Record(ctx context.Context, event AuditEvent) error {
+	return f(ctx, event)
+}
+
+// JSONLinesAuditSink writes each event to a writer as a JSON object on its own line.
+type JSONLinesAuditSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONLinesAuditSink creates a sink that writes events to w.
+func NewJSONLinesAuditSink(w io.Writer) *JSONLinesAuditSink {
+	return &JSONLinesAuditSink{w: w}
+}
+
+// Record writes the event as one line.
+func (s *JSONLinesAuditSink) Record(ctx context.Context, event AuditEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit event: %w", err)
+	}
+	line = append(line, '\n')
+
+	// One write per event keeps lines whole when several requests finish at once
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.w.Write(line); err != nil {
+		return fmt.Errorf("failed to write audit event: %w", err)
+	}
+	return nil
+}
+
+// FileAuditSink appends events to a file as JSON lines.
+type FileAuditSink struct {
+	*JSONLinesAuditSink
+	file *os.File
+}
+
+// NewFileAuditSink opens the file at path for appending, creating it readable only by
+// its owner if needed, and returns a sink that writes events to it.
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &FileAuditSink{JSONLinesAuditSink: NewJSONLinesAuditSink(file), file: file}, nil
+}
+
+// Close closes the file.
+func (s *FileAuditSink) Close() error {
+	return s.file.Close()
+}
+
+// HTTPAuditSinkOptions configures an HTTPAuditSink.
+type HTTPAuditSinkOptions struct {
+	// URL is the endpoint each event is POSTed to as a JSON object.
+	URL string
+
+	// Headers are added to every request, for example to authenticate with the
+	// collector. Optional.
+	Headers http.Header
+
+	// Client sends the requests.
+	// Default: an http.Client with a 5 second timeout
+	Client *http.Client
+}
+
+// HTTPAuditSink POSTs each event to an HTTP endpoint, such as a log collector.
+type HTTPAuditSink struct {
+	options HTTPAuditSinkOptions
+}
+
+// NewHTTPAuditSink creates a sink that sends events to options.URL.
+func NewHTTPAuditSink(options HTTPAuditSinkOptions) *HTTPAuditSink {
+	if options.Client == nil {
+		options.Client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &HTTPAuditSink{options: options}
+}
+
+// Record sends the event. Responses other than 2xx are errors. The request is not
+// canceled with ctx, so events of requests whose client went away are still sent.
+func (s *HTTPAuditSink) Record(ctx context.Context, event AuditEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(context.WithoutCancel(ctx), http.MethodPost, s.options.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create audit request: %w", err)
+	}
+	for name, values := range s.options.Headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := s.options.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send audit event: %w", err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("failed to send audit event: unexpected status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// audit records the outcome of authenticating r with the configured sink, if any.
+// Failures to record are logged rather than failing the request.
+func (am *AuthMiddleware) audit(r *http.Request, authTypes []string, err error) {
+	if am.options.AuditSink == nil {
+		return
+	}
+
+	event := AuditEvent{
+		Time:     time.Now().UTC(),
+		Success:  err == nil,
+		AuthType: strings.Join(authTypes, ","),
+		Method:   r.Method,
+		Route:    r.Pattern,
+		IP:       KeyByIP(r),
+	}
+	if event.Route == "" {
+		event.Route = r.URL.Path
+	}
+	if err != nil {
+		event.Reason = err.Error()
+	} else if claims, ok := GetUserFromContext(r.Context()); ok {
+		event.Principal, _ = claims["sub"].(string)
+	}
+
+	if err := am.options.AuditSink.Record(r.Context(), event); err != nil {
+		logging.Default().WarnContext(r.Context(), "failed to record audit event", "error", err)
+	}
+}
@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingSink collects the events it receives.
+type recordingSink struct {
+	mu     sync.Mutex
+	events []AuditEvent
+}
+
+func (s *recordingSink) Record(ctx context.Context, event AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+func TestAuthMiddlewareAudit(t *testing.T) {
+	sink := &recordingSink{}
+	am := NewAuthMiddleware(AuthOptions{
+		AuthTypes: []string{AuthTypeJWT, AuthTypeAPIKey},
+		JWTSecret: "jwt-secret",
+		APIKeys:   map[string]string{"key-1": "batch-client"},
+		AuditSink: sink,
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /models/{name}/generate", am.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	req := httptest.NewRequest(http.MethodPost, "/models/llama3/generate", nil)
+	req.RemoteAddr = "10.0.0.7:52100"
+	req.Header.Set(APIKeyHeaderKey, "key-1")
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodPost, "/models/llama3/generate", nil)
+	req.RemoteAddr = "10.0.0.8:52100"
+	req.Header.Set(APIKeyHeaderKey, "wrong-key")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401, got %d", rr.Code)
+	}
+
+	if len(sink.events) != 2 {
+		t.Fatalf("Expected 2 audit events, got %d", len(sink.events))
+	}
+	accepted, rejected := sink.events[0], sink.events[1]
+
+	if !accepted.Success || accepted.Principal != "batch-client" || accepted.AuthType != AuthTypeAPIKey || accepted.Reason != "" {
+		t.Errorf("Expected an accepted event for batch-client by API key, got %+v", accepted)
+	}
+	if accepted.Method != http.MethodPost || accepted.Route != "POST /models/{name}/generate" || accepted.IP != "10.0.0.7" {
+		t.Errorf("Expected the event to record the method, route, and IP, got %+v", accepted)
+	}
+	if accepted.Time.IsZero() {
+		t.Error("Expected the event to record its time")
+	}
+
+	if rejected.Success || rejected.Principal != "" || rejected.IP != "10.0.0.8" {
+		t.Errorf("Expected a rejected event without a principal, got %+v", rejected)
+	}
+	if rejected.AuthType != "jwt,apikey" {
+		t.Errorf("Expected the rejected event to list the types tried, got %s", rejected.AuthType)
+	}
+	if !strings.Contains(rejected.Reason, "invalid API key") {
+		t.Errorf("Expected the reason to explain the rejection, got %s", rejected.Reason)
+	}
+}
+
+func TestAuthMiddlewareAuditSinkError(t *testing.T) {
+	am := NewAuthMiddleware(AuthOptions{
+		AuthType: AuthTypeAPIKey,
+		APIKeys:  map[string]string{"key-1": "client"},
+		AuditSink: AuditFunc(func(ctx context.Context, event AuditEvent) error {
+			return errors.New("sink unavailable")
+		}),
+	})
+
+	called := false
+	handler := am.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+	req := httptest.NewRequest(http.MethodGet, "/models", nil)
+	req.Header.Set(APIKeyHeaderKey, "key-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !called {
+		t.Error("Expected a failing audit sink not to reject the request")
+	}
+}
+
+func TestFileAuditSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := NewFileAuditSink(path)
+	if err != nil {
+		t.Fatalf("Failed to create sink: %v", err)
+	}
+
+	events := []AuditEvent{
+		{Success: true, Principal: "alice", AuthType: AuthTypeJWT, Route: "/models"},
+		{Success: false, AuthType: AuthTypeJWT, Route: "/models", Reason: "invalid JWT token"},
+	}
+	for _, event := range events {
+		if err := sink.Record(context.Background(), event); err != nil {
+			t.Fatalf("Failed to record event: %v", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Failed to close sink: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read audit log: %v", err)
+	}
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	if len(lines) != len(events) {
+		t.Fatalf("Expected %d lines, got %d", len(events), len(lines))
+	}
+	for i, line := range lines {
+		var event AuditEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			t.Fatalf("Expected line %d to be JSON, got %q: %v", i, line, err)
+		}
+		if event.Principal != events[i].Principal || event.Reason != events[i].Reason {
+			t.Errorf("Expected line %d to be %+v, got %+v", i, events[i], event)
+		}
+	}
+
+	// Reopening appends
+	sink, err = NewFileAuditSink(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen sink: %v", err)
+	}
+	sink.Record(context.Background(), events[0])
+	sink.Close()
+	data, _ = os.ReadFile(path)
+	if n := bytes.Count(data, []byte("\n")); n != 3 {
+		t.Errorf("Expected 3 lines after reopening, got %d", n)
+	}
+}
+
+func TestHTTPAuditSink(t *testing.T) {
+	var received AuditEvent
+	var token string
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := NewHTTPAuditSink(HTTPAuditSinkOptions{
+		URL:     server.URL,
+		Headers: http.Header{"Authorization": {"Bearer collector-token"}},
+	})
+
+	// A canceled request context does not stop the event
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sink.Record(ctx, AuditEvent{Success: true, Principal: "alice"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if received.Principal != "alice" || token != "Bearer collector-token" {
+		t.Errorf("Expected the event and headers to be sent, got %+v with %q", received, token)
+	}
+
+	status = http.StatusInternalServerError
+	if err := sink.Record(context.Background(), AuditEvent{}); err == nil {
+		t.Error("Expected an error for a failed response")
+	}
+}
@@ -1,189 +1,442 @@
-// Package middleware provides HTTP middleware components for authentication and other cross-cutting concerns.
-//
-// This package contains middleware that can be used with standard Go HTTP servers
-// to add authentication, logging, rate limiting, and other functionality to HTTP handlers.
-//
-// Example usage:
-//
-//	// Create a new auth middleware for JWT authentication
-//	authMiddleware := middleware.NewAuthMiddleware(middleware.AuthOptions{
-//		AuthType:   middleware.AuthTypeJWT,
-//		JWTSecret:  "your-jwt-secret",
-//		HMACSecret: "your-hmac-secret",
-//	})
-//
-//	// Use the middleware with an HTTP handler
-//	http.Handle("/protected", authMiddleware.Middleware(http.HandlerFunc(protectedHandler)))
-package middleware
-
-import (
-	"bytes"
-	"context"
-	"encoding/json"
-	"fmt"
-	"io"
-	"log"
-	"net/http"
-
-	"github.com/h2co32/gollama/pkg/auth"
-
-	"github.com/golang-jwt/jwt/v4"
-)
-
-// Version represents the current package version following semantic versioning.
-const Version = "1.0.0"
-
-// Context keys for adding authentication data to the request context
-type contextKey string
-
-const (
-	// UserContextKey is the context key for storing user information
-	UserContextKey contextKey = "user"
-	
-	// AuthHeaderKey is the HTTP header key for the Authorization header
-	AuthHeaderKey string = "Authorization"
-	
-	// HMACHeaderKey is the HTTP header key for the HMAC signature
-	HMACHeaderKey string = "X-Signature"
-)
-
-// Authentication types
-const (
-	// AuthTypeJWT specifies JWT token authentication
-	AuthTypeJWT = "jwt"
-	
-	// AuthTypeHMAC specifies HMAC signature authentication
-	AuthTypeHMAC = "hmac"
-)
-
-// AuthOptions configures the AuthMiddleware.
-type AuthOptions struct {
-	// AuthType specifies the authentication type (jwt or hmac)
-	AuthType string
-	
-	// JWTSecret is the secret key for JWT token validation
-	JWTSecret string
-	
-	// HMACSecret is the secret key for HMAC signature validation
-	HMACSecret string
-	
-	// ErrorHandler is an optional custom error handler
-	ErrorHandler func(w http.ResponseWriter, err error)
-}
-
-// AuthMiddleware manages JWT and HMAC authentication for protected routes.
-type AuthMiddleware struct {
-	options AuthOptions
-}
-
-// NewAuthMiddleware initializes an AuthMiddleware with specified options.
-func NewAuthMiddleware(options AuthOptions) *AuthMiddleware {
-	return &AuthMiddleware{
-		options: options,
-	}
-}
-
-// Middleware intercepts HTTP requests and validates authentication headers.
-func (am *AuthMiddleware) Middleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var err error
-
-		switch am.options.AuthType {
-		case AuthTypeJWT:
-			err = am.handleJWTAuth(w, r)
-		case AuthTypeHMAC:
-			err = am.handleHMACAuth(w, r)
-		default:
-			err = fmt.Errorf("unsupported authentication method: %s", am.options.AuthType)
-		}
-
-		if err != nil {
-			am.handleError(w, err)
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	})
-}
-
-// handleError processes authentication errors.
-func (am *AuthMiddleware) handleError(w http.ResponseWriter, err error) {
-	if am.options.ErrorHandler != nil {
-		am.options.ErrorHandler(w, err)
-		return
-	}
-
-	// Default error handling
-	log.Printf("Authentication error: %v", err)
-	JSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
-}
-
-// handleJWTAuth verifies JWT tokens and adds user claims to the request context.
-func (am *AuthMiddleware) handleJWTAuth(w http.ResponseWriter, r *http.Request) error {
-	tokenString, err := auth.ExtractBearerToken(r.Header.Get(AuthHeaderKey))
-	if err != nil {
-		return fmt.Errorf("missing or invalid authorization header: %w", err)
-	}
-
-	claims, err := auth.ValidateJWT(am.options.JWTSecret, tokenString)
-	if err != nil {
-		return fmt.Errorf("invalid JWT token: %w", err)
-	}
-
-	// Add JWT claims to the request context for downstream use
-	ctx := context.WithValue(r.Context(), UserContextKey, claims)
-	*r = *r.WithContext(ctx)
-	return nil
-}
-
-// handleHMACAuth verifies HMAC signatures for request validation.
-func (am *AuthMiddleware) handleHMACAuth(w http.ResponseWriter, r *http.Request) error {
-	signature := r.Header.Get(HMACHeaderKey)
-	if signature == "" {
-		return fmt.Errorf("missing HMAC signature")
-	}
-
-	bodyBytes, err := getRequestBody(r)
-	if err != nil {
-		return fmt.Errorf("failed to read request body: %w", err)
-	}
-
-	if !auth.ValidateHMAC(am.options.HMACSecret, string(bodyBytes), signature) {
-		return fmt.Errorf("invalid HMAC signature")
-	}
-	return nil
-}
-
-
-// getRequestBody reads the request body for HMAC validation.
-func getRequestBody(r *http.Request) ([]byte, error) {
-	if r.Body == nil {
-		return nil, fmt.Errorf("request body is empty")
-	}
-
-	// Read the body and reset it so it can be read by other handlers
-	bodyBytes, err := io.ReadAll(r.Body)
-	if err != nil {
-		return nil, err
-	}
-	r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-	return bodyBytes, nil
-}
-
-// GetUserFromContext retrieves JWT claims from the request context.
-func GetUserFromContext(ctx context.Context) (jwt.MapClaims, bool) {
-	claims, ok := ctx.Value(UserContextKey).(jwt.MapClaims)
-	return claims, ok
-}
-
-// JSONResponse sends a JSON response with the specified status code and data.
-func JSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	
-	if data != nil {
-		if err := json.NewEncoder(w).Encode(data); err != nil {
-			log.Printf("Error encoding JSON response: %v", err)
-		}
-	}
-}
+// Package middleware provides HTTP middleware components for authentication and other cross-cutting concerns.
+//
+// This package contains middleware that can be used with standard Go HTTP servers
+// to add authentication, logging, rate limiting, and other functionality to HTTP handlers.
+//
+// Example usage:
+//
+//	// Create a new auth middleware for JWT authentication
+//	authMiddleware := middleware.NewAuthMiddleware(middleware.AuthOptions{
+//		AuthType:   middleware.AuthTypeJWT,
+//		JWTSecret:  "your-jwt-secret",
+//		HMACSecret: "your-hmac-secret",
+//	})
+//
+//	// Use the middleware with an HTTP handler
+//	http.Handle("/protected", authMiddleware.Middleware(http.HandlerFunc(protectedHandler)))
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/h2co32/gollama/pkg/auth"
+	"github.com/h2co32/gollama/pkg/logging"
+	"github.com/h2co32/gollama/pkg/secrets"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Version represents the current package version following semantic versioning.
+const Version = "1.0.0"
+
+// Context keys for adding authentication data to the request context
+type contextKey string
+
+const (
+	// UserContextKey is the context key for storing user information
+	UserContextKey contextKey = "user"
+	
+	// AuthHeaderKey is the HTTP header key for the Authorization header
+	AuthHeaderKey string = "Authorization"
+	
+	// HMACHeaderKey is the HTTP header key for the HMAC signature
+	HMACHeaderKey string = "X-Signature"
+
+	// APIKeyHeaderKey is the HTTP header key for the API key
+	APIKeyHeaderKey string = "X-API-Key"
+
+	// AuthTypeContextKey is the context key for the authentication type that succeeded
+	AuthTypeContextKey contextKey = "auth_type"
+
+	// ClientIdentityContextKey is the context key for the verified client certificate identity
+	ClientIdentityContextKey contextKey = "client_identity"
+)
+
+// Authentication types
+const (
+	// AuthTypeJWT specifies JWT token authentication
+	AuthTypeJWT = "jwt"
+	
+	// AuthTypeHMAC specifies HMAC signature authentication
+	AuthTypeHMAC = "hmac"
+
+	// AuthTypeAPIKey specifies static API key authentication
+	AuthTypeAPIKey = "apikey"
+
+	// AuthTypeMTLS specifies client certificate authentication over mutual TLS
+	AuthTypeMTLS = "mtls"
+
+	// AuthTypeOIDC specifies bearer token authentication against an OpenID Connect issuer
+	AuthTypeOIDC = "oidc"
+)
+
+// AuthOptions configures the AuthMiddleware.
+type AuthOptions struct {
+	// AuthType specifies the authentication type (jwt, hmac, apikey, mtls, or oidc)
+	AuthType string
+
+	// AuthTypes lists authentication types to try in order, so one endpoint can serve
+	// several kinds of clients. The first type that succeeds is recorded in the request
+	// context (see GetAuthTypeFromContext). It takes precedence over AuthType.
+	AuthTypes []string
+
+	// APIKeys maps accepted API keys to the name of the client they belong to.
+	// The name is available as the "sub" claim from GetUserFromContext.
+	APIKeys map[string]string
+
+	// MTLSAllowedNames restricts mTLS authentication to client certificates whose
+	// common name or a subject alternative name (DNS, email, or URI) is in the list.
+	// Optional. By default any certificate verified by the server's TLS config is accepted.
+	MTLSAllowedNames []string
+	
+	// JWTSecret is the secret key for JWT token validation
+	JWTSecret string
+
+	// JWTKeyRing validates HS256 tokens against the key named by their "kid" header,
+	// so secrets can be rotated without rejecting tokens signed by the previous key.
+	// It takes precedence over JWTSecret.
+	JWTKeyRing *auth.KeyRing
+
+	// JWTPublicKey validates RS256/ES256 tokens instead of JWTSecret when set.
+	// See auth.ParsePublicKeyPEM.
+	JWTPublicKey crypto.PublicKey
+
+	// JWTKeySet validates RS256/ES256 tokens against a JWKS endpoint, such as one
+	// published by an identity provider. It takes precedence over JWTPublicKey and JWTSecret.
+	JWTKeySet *auth.JWKS
+
+	// OIDCValidator validates bearer tokens for the oidc auth type, checking the
+	// issuer, audience, and signature against the provider's discovered keys.
+	OIDCValidator *auth.OIDCValidator
+	
+	// HMACSecret is the secret key for HMAC signature validation
+	HMACSecret string
+
+	// HMACRequestSigning, when set, requires HMAC requests to be signed with
+	// auth.SignRequest, which covers the method, path, query, chosen headers, and body
+	// along with a timestamp and nonce, so captured requests cannot be replayed.
+	// When nil, the signature covers only the body. A memory nonce store is used if
+	// none is given.
+	HMACRequestSigning *auth.RequestSigningOptions
+
+	// SecretProvider supplies the JWT and HMAC secrets when JWTSecret or HMACSecret is
+	// empty, so they need not be held in flags or config files. Secrets are read on each
+	// request; wrap remote stores with secrets.NewCachedProvider.
+	SecretProvider secrets.SecretProvider
+
+	// JWTSecretName and HMACSecretName are the names looked up in SecretProvider.
+	JWTSecretName  string
+	HMACSecretName string
+	
+	// ErrorHandler is an optional custom error handler
+	ErrorHandler func(w http.ResponseWriter, err error)
+
+	// AuditSink records the outcome of every authentication, with the principal, auth
+	// type, route, client IP, and rejection reason, for example to a FileAuditSink or
+	// HTTPAuditSink. Optional.
+	AuditSink AuditSink
+}
+
+// AuthMiddleware manages JWT, HMAC, API key, mTLS, and OIDC authentication for protected routes.
+type AuthMiddleware struct {
+	options AuthOptions
+}
+
+// NewAuthMiddleware initializes an AuthMiddleware with specified options.
+func NewAuthMiddleware(options AuthOptions) *AuthMiddleware {
+	if options.HMACRequestSigning != nil && options.HMACRequestSigning.NonceStore == nil {
+		signing := *options.HMACRequestSigning
+		signing.NonceStore = auth.NewMemoryNonceStore()
+		options.HMACRequestSigning = &signing
+	}
+
+	return &AuthMiddleware{
+		options: options,
+	}
+}
+
+// Middleware intercepts HTTP requests and validates authentication headers.
+func (am *AuthMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authTypes := am.options.AuthTypes
+		if len(authTypes) == 0 {
+			authTypes = []string{am.options.AuthType}
+		}
+
+		var errs []error
+		for _, authType := range authTypes {
+			if err := am.authenticate(authType, w, r); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			am.audit(r, []string{authType}, nil)
+			ctx := context.WithValue(r.Context(), AuthTypeContextKey, authType)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		err := errs[0]
+		if len(errs) > 1 {
+			err = fmt.Errorf("all authentication methods failed: %w", errors.Join(errs...))
+		}
+		am.audit(r, authTypes, err)
+		am.handleError(w, err)
+	})
+}
+
+// authenticate validates the request with a single authentication type.
+func (am *AuthMiddleware) authenticate(authType string, w http.ResponseWriter, r *http.Request) error {
+	switch authType {
+	case AuthTypeJWT:
+		return am.handleJWTAuth(w, r)
+	case AuthTypeHMAC:
+		return am.handleHMACAuth(w, r)
+	case AuthTypeAPIKey:
+		return am.handleAPIKeyAuth(w, r)
+	case AuthTypeMTLS:
+		return am.handleMTLSAuth(w, r)
+	case AuthTypeOIDC:
+		return am.handleOIDCAuth(w, r)
+	default:
+		return fmt.Errorf("unsupported authentication method: %s", authType)
+	}
+}
+
+// handleError processes authentication errors.
+func (am *AuthMiddleware) handleError(w http.ResponseWriter, err error) {
+	if am.options.ErrorHandler != nil {
+		am.options.ErrorHandler(w, err)
+		return
+	}
+
+	// Default error handling
+	logging.Default().Warn("authentication failed", "error", err)
+	JSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+}
+
+// handleJWTAuth verifies JWT tokens and adds user claims to the request context.
+func (am *AuthMiddleware) handleJWTAuth(w http.ResponseWriter, r *http.Request) error {
+	tokenString, err := auth.ExtractBearerToken(r.Header.Get(AuthHeaderKey))
+	if err != nil {
+		return fmt.Errorf("missing or invalid authorization header: %w", err)
+	}
+
+	var claims jwt.MapClaims
+	switch {
+	case am.options.JWTKeySet != nil:
+		claims, err = auth.ValidateJWTWithKeySet(am.options.JWTKeySet, tokenString)
+	case am.options.JWTPublicKey != nil:
+		claims, err = auth.ValidateJWTWithKey(am.options.JWTPublicKey, tokenString)
+	case am.options.JWTKeyRing != nil:
+		claims, err = am.options.JWTKeyRing.Validate(tokenString)
+	default:
+		var secret string
+		if secret, err = am.secret(r.Context(), am.options.JWTSecret, am.options.JWTSecretName); err != nil {
+			return err
+		}
+		claims, err = auth.ValidateJWT(secret, tokenString)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid JWT token: %w", err)
+	}
+
+	// Add JWT claims to the request context for downstream use
+	ctx := context.WithValue(r.Context(), UserContextKey, claims)
+	*r = *r.WithContext(ctx)
+	return nil
+}
+
+// handleOIDCAuth validates bearer tokens issued by the configured OpenID Connect provider.
+func (am *AuthMiddleware) handleOIDCAuth(w http.ResponseWriter, r *http.Request) error {
+	if am.options.OIDCValidator == nil {
+		return fmt.Errorf("OIDC validator is not configured")
+	}
+
+	tokenString, err := auth.ExtractBearerToken(r.Header.Get(AuthHeaderKey))
+	if err != nil {
+		return fmt.Errorf("missing or invalid authorization header: %w", err)
+	}
+
+	claims, err := am.options.OIDCValidator.Validate(r.Context(), tokenString)
+	if err != nil {
+		return fmt.Errorf("invalid OIDC token: %w", err)
+	}
+
+	ctx := context.WithValue(r.Context(), UserContextKey, claims)
+	*r = *r.WithContext(ctx)
+	return nil
+}
+
+// handleHMACAuth verifies HMAC signatures for request validation.
+func (am *AuthMiddleware) handleHMACAuth(w http.ResponseWriter, r *http.Request) error {
+	secret, err := am.secret(r.Context(), am.options.HMACSecret, am.options.HMACSecretName)
+	if err != nil {
+		return err
+	}
+
+	if am.options.HMACRequestSigning != nil {
+		return auth.VerifyRequest(secret, r, *am.options.HMACRequestSigning)
+	}
+
+	signature := r.Header.Get(HMACHeaderKey)
+	if signature == "" {
+		return fmt.Errorf("missing HMAC signature")
+	}
+
+	bodyBytes, err := getRequestBody(r)
+	if err != nil {
+		return fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	if !auth.ValidateHMAC(secret, string(bodyBytes), signature) {
+		return fmt.Errorf("invalid HMAC signature")
+	}
+	return nil
+}
+
+// secret returns value if set, or otherwise the secret called name from the secret provider.
+func (am *AuthMiddleware) secret(ctx context.Context, value, name string) (string, error) {
+	if value != "" || am.options.SecretProvider == nil || name == "" {
+		return value, nil
+	}
+
+	secret, err := am.options.SecretProvider.GetSecret(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret %s: %w", name, err)
+	}
+	return secret, nil
+}
+
+// handleAPIKeyAuth checks the API key header against the configured keys and adds
+// the client name to the request context.
+func (am *AuthMiddleware) handleAPIKeyAuth(w http.ResponseWriter, r *http.Request) error {
+	key := r.Header.Get(APIKeyHeaderKey)
+	if key == "" {
+		return fmt.Errorf("missing API key")
+	}
+
+	// Compare against every key in constant time so timing does not reveal valid prefixes
+	var client string
+	for candidate, name := range am.options.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(key)) == 1 {
+			client = name
+		}
+	}
+	if client == "" {
+		return fmt.Errorf("invalid API key")
+	}
+
+	ctx := context.WithValue(r.Context(), UserContextKey, jwt.MapClaims{"sub": client})
+	*r = *r.WithContext(ctx)
+	return nil
+}
+
+// ClientIdentity describes the verified client certificate of an mTLS request.
+type ClientIdentity struct {
+	Subject        string
+	CommonName     string
+	DNSNames       []string
+	EmailAddresses []string
+	URIs           []string
+}
+
+// names returns the common name and every subject alternative name.
+func (ci ClientIdentity) names() []string {
+	names := append([]string{ci.CommonName}, ci.DNSNames...)
+	names = append(names, ci.EmailAddresses...)
+	return append(names, ci.URIs...)
+}
+
+// handleMTLSAuth accepts requests whose TLS connection presented a client certificate
+// that the server verified, and adds its identity to the request context.
+func (am *AuthMiddleware) handleMTLSAuth(w http.ResponseWriter, r *http.Request) error {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return fmt.Errorf("no verified client certificate")
+	}
+
+	cert := r.TLS.VerifiedChains[0][0]
+	identity := ClientIdentity{
+		Subject:        cert.Subject.String(),
+		CommonName:     cert.Subject.CommonName,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+	}
+	for _, uri := range cert.URIs {
+		identity.URIs = append(identity.URIs, uri.String())
+	}
+
+	if len(am.options.MTLSAllowedNames) > 0 && !containsAny(am.options.MTLSAllowedNames, identity.names()) {
+		return fmt.Errorf("client certificate %s is not allowed", identity.Subject)
+	}
+
+	ctx := context.WithValue(r.Context(), ClientIdentityContextKey, identity)
+	ctx = context.WithValue(ctx, UserContextKey, jwt.MapClaims{"sub": identity.CommonName})
+	*r = *r.WithContext(ctx)
+	return nil
+}
+
+// containsAny reports whether any of values is in list.
+func containsAny(list, values []string) bool {
+	for _, value := range values {
+		for _, item := range list {
+			if value != "" && value == item {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getRequestBody reads the request body for HMAC validation.
+func getRequestBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, fmt.Errorf("request body is empty")
+	}
+
+	// Read the body and reset it so it can be read by other handlers
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+	return bodyBytes, nil
+}
+
+// GetAuthTypeFromContext retrieves the authentication type that accepted the request.
+func GetAuthTypeFromContext(ctx context.Context) (string, bool) {
+	authType, ok := ctx.Value(AuthTypeContextKey).(string)
+	return authType, ok
+}
+
+// GetClientIdentityFromContext retrieves the client certificate identity of an mTLS request.
+func GetClientIdentityFromContext(ctx context.Context) (ClientIdentity, bool) {
+	identity, ok := ctx.Value(ClientIdentityContextKey).(ClientIdentity)
+	return identity, ok
+}
+
+// GetUserFromContext retrieves JWT claims from the request context.
+func GetUserFromContext(ctx context.Context) (jwt.MapClaims, bool) {
+	claims, ok := ctx.Value(UserContextKey).(jwt.MapClaims)
+	return claims, ok
+}
+
+// JSONResponse sends a JSON response with the specified status code and data.
+func JSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	
+	if data != nil {
+		if err := json.NewEncoder(w).Encode(data); err != nil {
+			logging.Default().Error("failed to encode JSON response", "error", err)
+		}
+	}
+}
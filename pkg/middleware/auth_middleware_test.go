@@ -2,12 +2,22 @@ package middleware
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/h2co32/gollama/pkg/auth"
+	"github.com/h2co32/gollama/pkg/secrets"
 	"github.com/golang-jwt/jwt/v4"
 )
 
@@ -122,6 +132,165 @@ func TestJWTAuthMiddleware(t *testing.T) {
 	}
 }
 
+func TestJWTAuthMiddlewareWithPublicKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	middleware := NewAuthMiddleware(AuthOptions{
+		AuthType:     AuthTypeJWT,
+		JWTPublicKey: &key.PublicKey,
+	})
+	protectedHandler := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	token, err := auth.GenerateJWTWithKey(key, map[string]interface{}{"user_id": 123}, auth.DefaultJWTOptions())
+	if err != nil {
+		t.Fatalf("Failed to generate JWT token: %v", err)
+	}
+	req := httptest.NewRequest("GET", "/protected", nil)
+	req.Header.Set(AuthHeaderKey, "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	protectedHandler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, recorder.Code)
+	}
+
+	// A token signed with a shared secret is rejected
+	token, _ = auth.GenerateJWT("jwt-secret", nil)
+	req = httptest.NewRequest("GET", "/protected", nil)
+	req.Header.Set(AuthHeaderKey, "Bearer "+token)
+	recorder = httptest.NewRecorder()
+	protectedHandler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d for an HS256 token, got %d", http.StatusUnauthorized, recorder.Code)
+	}
+}
+
+func TestOIDCAuthMiddleware(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	var provider *httptest.Server
+	provider = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/.well-known/openid-configuration" {
+			json.NewEncoder(w).Encode(map[string]string{"issuer": provider.URL, "jwks_uri": provider.URL + "/keys"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "EC",
+			"kid": "key-1",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		}}})
+	}))
+	defer provider.Close()
+
+	validator, err := auth.NewOIDCValidator(context.Background(), auth.OIDCOptions{IssuerURL: provider.URL, Audience: "gollama"})
+	if err != nil {
+		t.Fatalf("Failed to create OIDC validator: %v", err)
+	}
+	middleware := NewAuthMiddleware(AuthOptions{AuthType: AuthTypeOIDC, OIDCValidator: validator})
+	protectedHandler := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(issuer string) int {
+		options := auth.DefaultJWTOptions()
+		options.KeyID = "key-1"
+		options.Issuer = issuer
+		options.Audience = "gollama"
+		token, err := auth.GenerateJWTWithKey(key, map[string]interface{}{"sub": "user-1"}, options)
+		if err != nil {
+			t.Fatalf("Failed to generate JWT token: %v", err)
+		}
+		req := httptest.NewRequest("GET", "/protected", nil)
+		req.Header.Set(AuthHeaderKey, "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		protectedHandler.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	if code := serve(provider.URL); code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, code)
+	}
+	if code := serve("https://other.example.com"); code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d for a token from another issuer, got %d", http.StatusUnauthorized, code)
+	}
+}
+
+func TestJWTAuthMiddlewareWithKeyRing(t *testing.T) {
+	ring := auth.NewKeyRing(auth.KeyRingOptions{})
+	if _, err := ring.Rotate(); err != nil {
+		t.Fatalf("Failed to rotate key ring: %v", err)
+	}
+	middleware := NewAuthMiddleware(AuthOptions{AuthType: AuthTypeJWT, JWTKeyRing: ring})
+	protectedHandler := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	token, err := ring.Sign(map[string]interface{}{"sub": "user-1"}, auth.DefaultJWTOptions())
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+
+	// Tokens signed before a rotation are still accepted
+	ring.Rotate()
+	req := httptest.NewRequest("GET", "/protected", nil)
+	req.Header.Set(AuthHeaderKey, "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	protectedHandler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, recorder.Code)
+	}
+}
+
+func TestAuthMiddlewareSecretProvider(t *testing.T) {
+	provider := secrets.StaticProvider{"jwt": "jwt-secret", "hmac": "hmac-secret"}
+	middleware := NewAuthMiddleware(AuthOptions{
+		AuthTypes:      []string{AuthTypeJWT, AuthTypeHMAC},
+		SecretProvider: provider,
+		JWTSecretName:  "jwt",
+		HMACSecretName: "hmac",
+	})
+	protectedHandler := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	token, _ := auth.GenerateJWT("jwt-secret", nil)
+	req := httptest.NewRequest("GET", "/protected", nil)
+	req.Header.Set(AuthHeaderKey, "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	protectedHandler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status code %d for a JWT signed with the provided secret, got %d", http.StatusOK, recorder.Code)
+	}
+
+	payload := `{"data":"test"}`
+	req = httptest.NewRequest("POST", "/protected", strings.NewReader(payload))
+	req.Header.Set(HMACHeaderKey, auth.GenerateHMAC("hmac-secret", payload))
+	recorder = httptest.NewRecorder()
+	protectedHandler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status code %d for an HMAC signed with the provided secret, got %d", http.StatusOK, recorder.Code)
+	}
+
+	// Rotating the secret in the provider takes effect immediately
+	provider["jwt"] = "rotated-secret"
+	req = httptest.NewRequest("GET", "/protected", nil)
+	req.Header.Set(AuthHeaderKey, "Bearer "+token)
+	recorder = httptest.NewRecorder()
+	protectedHandler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d after rotating the secret, got %d", http.StatusUnauthorized, recorder.Code)
+	}
+}
+
 func TestHMACAuthMiddleware(t *testing.T) {
 	// Create an HMAC auth middleware
 	options := AuthOptions{
@@ -170,6 +339,139 @@ func TestHMACAuthMiddleware(t *testing.T) {
 	}
 }
 
+func TestHMACAuthMiddlewareRequestSigning(t *testing.T) {
+	middleware := NewAuthMiddleware(AuthOptions{
+		AuthType:           AuthTypeHMAC,
+		HMACSecret:         "hmac-secret",
+		HMACRequestSigning: &auth.RequestSigningOptions{},
+	})
+	protectedHandler := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("POST", "/protected", strings.NewReader(`{"data":"test"}`))
+	if err := auth.SignRequest("hmac-secret", req, auth.RequestSigningOptions{}); err != nil {
+		t.Fatalf("Failed to sign request: %v", err)
+	}
+	recorder := httptest.NewRecorder()
+	protectedHandler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, recorder.Code)
+	}
+
+	// Replaying the captured request fails
+	req.Body = io.NopCloser(strings.NewReader(`{"data":"test"}`))
+	recorder = httptest.NewRecorder()
+	protectedHandler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d for a replayed request, got %d", http.StatusUnauthorized, recorder.Code)
+	}
+
+	// A body-only signature is not enough
+	body := `{"data":"test"}`
+	req = httptest.NewRequest("POST", "/protected", strings.NewReader(body))
+	req.Header.Set(HMACHeaderKey, auth.GenerateHMAC("hmac-secret", body))
+	recorder = httptest.NewRecorder()
+	protectedHandler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d for a body-only signature, got %d", http.StatusUnauthorized, recorder.Code)
+	}
+}
+
+func TestMultiAuthMiddleware(t *testing.T) {
+	middleware := NewAuthMiddleware(AuthOptions{
+		AuthTypes:  []string{AuthTypeJWT, AuthTypeAPIKey, AuthTypeHMAC},
+		JWTSecret:  "jwt-secret",
+		HMACSecret: "hmac-secret",
+		APIKeys:    map[string]string{"key-123": "batch-worker"},
+	})
+	protectedHandler := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authType, _ := GetAuthTypeFromContext(r.Context())
+		claims, _ := GetUserFromContext(r.Context())
+		json.NewEncoder(w).Encode(map[string]interface{}{"auth_type": authType, "sub": claims["sub"]})
+	}))
+
+	serve := func(req *http.Request) (int, map[string]interface{}) {
+		recorder := httptest.NewRecorder()
+		protectedHandler.ServeHTTP(recorder, req)
+		var body map[string]interface{}
+		json.NewDecoder(recorder.Body).Decode(&body)
+		return recorder.Code, body
+	}
+
+	token, _ := auth.GenerateJWT("jwt-secret", map[string]interface{}{"sub": "alice"})
+	req := httptest.NewRequest("GET", "/protected", nil)
+	req.Header.Set(AuthHeaderKey, "Bearer "+token)
+	if code, body := serve(req); code != http.StatusOK || body["auth_type"] != AuthTypeJWT || body["sub"] != "alice" {
+		t.Errorf("Expected JWT authentication as alice, got %d %v", code, body)
+	}
+
+	req = httptest.NewRequest("GET", "/protected", nil)
+	req.Header.Set(APIKeyHeaderKey, "key-123")
+	if code, body := serve(req); code != http.StatusOK || body["auth_type"] != AuthTypeAPIKey || body["sub"] != "batch-worker" {
+		t.Errorf("Expected API key authentication as batch-worker, got %d %v", code, body)
+	}
+
+	payload := `{"data":"test"}`
+	req = httptest.NewRequest("POST", "/protected", strings.NewReader(payload))
+	req.Header.Set(HMACHeaderKey, auth.GenerateHMAC("hmac-secret", payload))
+	if code, body := serve(req); code != http.StatusOK || body["auth_type"] != AuthTypeHMAC {
+		t.Errorf("Expected HMAC authentication, got %d %v", code, body)
+	}
+
+	req = httptest.NewRequest("GET", "/protected", nil)
+	req.Header.Set(APIKeyHeaderKey, "wrong-key")
+	if code, _ := serve(req); code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d when every method fails, got %d", http.StatusUnauthorized, code)
+	}
+}
+
+func TestMTLSAuthMiddleware(t *testing.T) {
+	middleware := NewAuthMiddleware(AuthOptions{
+		AuthType:         AuthTypeMTLS,
+		MTLSAllowedNames: []string{"worker.internal"},
+	})
+
+	var identity ClientIdentity
+	protectedHandler := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ = GetClientIdentityFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	withCert := func(cert *x509.Certificate) *http.Request {
+		req := httptest.NewRequest("GET", "/protected", nil)
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		return req
+	}
+
+	// A verified certificate with an allowed SAN is accepted
+	recorder := httptest.NewRecorder()
+	protectedHandler.ServeHTTP(recorder, withCert(&x509.Certificate{
+		Subject:  pkix.Name{CommonName: "worker-1"},
+		DNSNames: []string{"worker.internal"},
+	}))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, recorder.Code)
+	}
+	if identity.CommonName != "worker-1" {
+		t.Errorf("Expected common name worker-1, got %q", identity.CommonName)
+	}
+
+	// A verified certificate that is not in the allow list is rejected
+	recorder = httptest.NewRecorder()
+	protectedHandler.ServeHTTP(recorder, withCert(&x509.Certificate{Subject: pkix.Name{CommonName: "other"}}))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d for a disallowed certificate, got %d", http.StatusUnauthorized, recorder.Code)
+	}
+
+	// A request without a verified certificate is rejected
+	recorder = httptest.NewRecorder()
+	protectedHandler.ServeHTTP(recorder, httptest.NewRequest("GET", "/protected", nil))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d without a client certificate, got %d", http.StatusUnauthorized, recorder.Code)
+	}
+}
+
 func TestUnsupportedAuthType(t *testing.T) {
 	// Create a middleware with an unsupported auth type
 	options := AuthOptions{
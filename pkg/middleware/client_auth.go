@@ -0,0 +1,227 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/h2co32/gollama/pkg/auth"
+)
+
+// JWTClientAuth is a client-side Middleware that adds a bearer JWT signed with a shared
+// secret to outgoing requests. The token is reused until it is close to expiring.
+type JWTClientAuth struct {
+	secret    string
+	claims    map[string]interface{}
+	options   auth.JWTOptions
+	token     string
+	expiresAt time.Time
+	mu        sync.Mutex
+}
+
+// NewJWTClientAuth creates a JWTClientAuth that signs tokens carrying claims with secret.
+// A zero options.ExpiresIn falls back to the default.
+func NewJWTClientAuth(secret string, claims map[string]interface{}, options auth.JWTOptions) *JWTClientAuth {
+	if options.ExpiresIn <= 0 {
+		options.ExpiresIn = auth.DefaultJWTOptions().ExpiresIn
+	}
+
+	return &JWTClientAuth{
+		secret:  secret,
+		claims:  claims,
+		options: options,
+	}
+}
+
+// ProcessRequest sets the Authorization header.
+func (c *JWTClientAuth) ProcessRequest(req *http.Request) (*http.Request, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Sign a new token once most of the current one's lifetime has passed
+	if c.token == "" || time.Until(c.expiresAt) < c.options.ExpiresIn/10 {
+		token, err := auth.GenerateJWTWithOptions(c.secret, c.claims, c.options)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate JWT: %w", err)
+		}
+		c.token = token
+		c.expiresAt = time.Now().Add(c.options.ExpiresIn)
+	}
+
+	req.Header.Set(AuthHeaderKey, "Bearer "+c.token)
+	return req, nil
+}
+
+// ProcessResponse returns the response unchanged.
+func (c *JWTClientAuth) ProcessResponse(res *http.Response) (*http.Response, error) {
+	return res, nil
+}
+
+// HMACClientAuth is a client-side Middleware that signs outgoing requests with a
+// shared secret, in the form AuthMiddleware expects.
+type HMACClientAuth struct {
+	secret  string
+	signing *auth.RequestSigningOptions
+}
+
+// NewHMACClientAuth creates an HMACClientAuth. If signing is nil, only the request body
+// is signed; otherwise the canonical request is signed with auth.SignRequest.
+func NewHMACClientAuth(secret string, signing *auth.RequestSigningOptions) *HMACClientAuth {
+	return &HMACClientAuth{secret: secret, signing: signing}
+}
+
+// ProcessRequest sets the signature headers.
+func (c *HMACClientAuth) ProcessRequest(req *http.Request) (*http.Request, error) {
+	if c.signing != nil {
+		if err := auth.SignRequest(c.secret, req, *c.signing); err != nil {
+			return nil, fmt.Errorf("failed to sign request: %w", err)
+		}
+		return req, nil
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = getRequestBody(req); err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+	}
+	req.Header.Set(HMACHeaderKey, auth.GenerateHMAC(c.secret, string(body)))
+	return req, nil
+}
+
+// ProcessResponse returns the response unchanged.
+func (c *HMACClientAuth) ProcessResponse(res *http.Response) (*http.Response, error) {
+	return res, nil
+}
+
+// OAuthOptions configures an OAuthClientAuth.
+type OAuthOptions struct {
+	// TokenURL is the authorization server's token endpoint.
+	TokenURL string
+
+	// ClientID and ClientSecret identify the client to the authorization server.
+	ClientID     string
+	ClientSecret string
+
+	// Scopes are the scopes requested for the access token. Optional.
+	Scopes []string
+
+	// HTTPClient is used to request tokens.
+	// Default: a client with a 10 second timeout
+	HTTPClient *http.Client
+
+	// ExpiryDelta is how long before its expiry a token is refreshed, so that it does
+	// not expire while a request is in flight.
+	// Default: 30 seconds
+	ExpiryDelta time.Duration
+}
+
+// DefaultOAuthOptions returns the default OAuth options.
+func DefaultOAuthOptions() OAuthOptions {
+	return OAuthOptions{
+		HTTPClient:  &http.Client{Timeout: 10 * time.Second},
+		ExpiryDelta: 30 * time.Second,
+	}
+}
+
+// OAuthClientAuth is a client-side Middleware that adds an OAuth 2.0 access token,
+// obtained with the client credentials grant, to outgoing requests. The token is
+// cached and refreshed before it expires. Concurrent requests share a single refresh.
+type OAuthClientAuth struct {
+	options   OAuthOptions
+	token     string
+	expiresAt time.Time
+	mu        sync.Mutex
+}
+
+// NewOAuthClientAuth creates an OAuthClientAuth with the given options.
+// Zero values fall back to the defaults.
+func NewOAuthClientAuth(options OAuthOptions) *OAuthClientAuth {
+	defaults := DefaultOAuthOptions()
+	if options.HTTPClient == nil {
+		options.HTTPClient = defaults.HTTPClient
+	}
+	if options.ExpiryDelta <= 0 {
+		options.ExpiryDelta = defaults.ExpiryDelta
+	}
+
+	return &OAuthClientAuth{options: options}
+}
+
+// ProcessRequest sets the Authorization header, fetching a new token if needed.
+func (c *OAuthClientAuth) ProcessRequest(req *http.Request) (*http.Request, error) {
+	// Hold the lock across the fetch so concurrent requests wait for one refresh
+	// instead of each requesting a token
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token == "" || (!c.expiresAt.IsZero() && time.Until(c.expiresAt) < c.options.ExpiryDelta) {
+		if err := c.refresh(req); err != nil {
+			return nil, err
+		}
+	}
+
+	req.Header.Set(AuthHeaderKey, "Bearer "+c.token)
+	return req, nil
+}
+
+// ProcessResponse drops the cached token when the server rejects it, so the next
+// request fetches a new one.
+func (c *OAuthClientAuth) ProcessResponse(res *http.Response) (*http.Response, error) {
+	if res.StatusCode == http.StatusUnauthorized {
+		c.mu.Lock()
+		c.token = ""
+		c.mu.Unlock()
+	}
+	return res, nil
+}
+
+// refresh requests a new access token from the token endpoint.
+// This method is not thread-safe and should be called with the lock held.
+func (c *OAuthClientAuth) refresh(req *http.Request) error {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.options.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.options.Scopes, " "))
+	}
+
+	tokenReq, err := http.NewRequestWithContext(req.Context(), http.MethodPost, c.options.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create token request: %w", err)
+	}
+	tokenReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	tokenReq.SetBasicAuth(url.QueryEscape(c.options.ClientID), url.QueryEscape(c.options.ClientSecret))
+
+	res, err := c.options.HTTPClient.Do(tokenReq)
+	if err != nil {
+		return fmt.Errorf("failed to fetch access token: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch access token: server returned %d", res.StatusCode)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1024*1024)).Decode(&body); err != nil {
+		return fmt.Errorf("failed to parse token response: %w", err)
+	}
+	if body.AccessToken == "" {
+		return fmt.Errorf("token response has no access_token")
+	}
+
+	c.token = body.AccessToken
+	c.expiresAt = time.Time{}
+	if body.ExpiresIn > 0 {
+		c.expiresAt = time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)
+	}
+	return nil
+}
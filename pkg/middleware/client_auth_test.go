@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/h2co32/gollama/pkg/auth"
+)
+
+// newAuthenticatedServer starts a server protected by an AuthMiddleware with options.
+func newAuthenticatedServer(t *testing.T, options AuthOptions) *httptest.Server {
+	t.Helper()
+	handler := NewAuthMiddleware(options).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClientAuthAgainstAuthMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  AuthOptions
+		clientMW Middleware
+	}{
+		{
+			name:     "jwt",
+			options:  AuthOptions{AuthType: AuthTypeJWT, JWTSecret: "jwt-secret"},
+			clientMW: NewJWTClientAuth("jwt-secret", map[string]interface{}{"sub": "client"}, auth.DefaultJWTOptions()),
+		},
+		{
+			name:     "hmac body",
+			options:  AuthOptions{AuthType: AuthTypeHMAC, HMACSecret: "hmac-secret"},
+			clientMW: NewHMACClientAuth("hmac-secret", nil),
+		},
+		{
+			name: "hmac request signing",
+			options: AuthOptions{
+				AuthType:           AuthTypeHMAC,
+				HMACSecret:         "hmac-secret",
+				HMACRequestSigning: &auth.RequestSigningOptions{},
+			},
+			clientMW: NewHMACClientAuth("hmac-secret", &auth.RequestSigningOptions{}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newAuthenticatedServer(t, tt.options)
+			client := &http.Client{Transport: &Transport{Middleware: []Middleware{tt.clientMW}}}
+
+			res, err := client.Post(server.URL, "application/json", strings.NewReader(`{"data":"test"}`))
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			res.Body.Close()
+			if res.StatusCode != http.StatusOK {
+				t.Errorf("Expected status code %d, got %d", http.StatusOK, res.StatusCode)
+			}
+		})
+	}
+}
+
+func TestOAuthClientAuth(t *testing.T) {
+	var fetches int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if r.FormValue("grant_type") != "client_credentials" || id != "client" || secret != "secret" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		n := atomic.AddInt32(&fetches, 1)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "token-" + string(rune('0'+n)),
+			"expires_in":   3600,
+		})
+	}))
+	defer tokenServer.Close()
+
+	// The API accepts only the first token, so the client must refresh after a 401
+	var revoked atomic.Bool
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if revoked.Load() && r.Header.Get(AuthHeaderKey) == "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer apiServer.Close()
+
+	oauth := NewOAuthClientAuth(OAuthOptions{TokenURL: tokenServer.URL, ClientID: "client", ClientSecret: "secret"})
+	client := &http.Client{Transport: &Transport{Middleware: []Middleware{oauth}}}
+
+	// Concurrent requests share one token fetch
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := client.Get(apiServer.URL)
+			if err != nil {
+				t.Errorf("Request failed: %v", err)
+				return
+			}
+			res.Body.Close()
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("Expected 1 token fetch, got %d", n)
+	}
+
+	// A rejected token is dropped and replaced on the next request
+	revoked.Store(true)
+	res, _ := client.Get(apiServer.URL)
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected status code %d for the revoked token, got %d", http.StatusUnauthorized, res.StatusCode)
+	}
+	res, _ = client.Get(apiServer.URL)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %d after refreshing the token, got %d", http.StatusOK, res.StatusCode)
+	}
+	if n := atomic.LoadInt32(&fetches); n != 2 {
+		t.Errorf("Expected 2 token fetches, got %d", n)
+	}
+}
@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// RouteLimit sets separate limits for requests whose path starts with Path.
+type RouteLimit struct {
+	// Path is the URL path prefix the limits apply to. The longest matching prefix wins.
+	Path string
+
+	// MaxBodyBytes is the largest request body accepted. A negative value disables
+	// the limit.
+	// Default: LimitOptions.MaxBodyBytes
+	MaxBodyBytes int64
+
+	// Timeout bounds the time to handle a request. A negative value disables it.
+	// Default: LimitOptions.Timeout
+	Timeout time.Duration
+}
+
+// LimitOptions configures the LimitMiddleware.
+type LimitOptions struct {
+	// MaxBodyBytes is the largest request body accepted on routes without their own
+	// limit. A negative value disables the limit.
+	// Default: 10MB
+	MaxBodyBytes int64
+
+	// Timeout bounds the time to handle a request on routes without their own timeout,
+	// including streaming the response. Optional. By default requests have no timeout.
+	Timeout time.Duration
+
+	// Routes sets per-route limits that replace the defaults, for example a longer
+	// timeout for streamed generations or a larger body for embedding batches.
+	Routes []RouteLimit
+}
+
+// DefaultLimitOptions returns the default options for a LimitMiddleware.
+func DefaultLimitOptions() LimitOptions {
+	return LimitOptions{
+		MaxBodyBytes: 10 << 20,
+	}
+}
+
+// LimitMiddleware bounds the request body size and handling time of each request,
+// protecting servers from oversized payloads, such as giant prompts, and from requests
+// that never finish.
+type LimitMiddleware struct {
+	options LimitOptions
+}
+
+// NewLimitMiddleware initializes a LimitMiddleware with specified options. A zero
+// MaxBodyBytes falls back to the default.
+func NewLimitMiddleware(options LimitOptions) *LimitMiddleware {
+	if options.MaxBodyBytes == 0 {
+		options.MaxBodyBytes = DefaultLimitOptions().MaxBodyBytes
+	}
+	return &LimitMiddleware{options: options}
+}
+
+// Middleware enforces the limits of the request's route.
+//
+// Requests whose Content-Length is over the body limit are rejected with 413 Request
+// Entity Too Large and a JSON body of the form {"error": "request body too large",
+// "max_bytes": 10485760}. Other bodies fail to read past the limit with an
+// *http.MaxBytesError, and get the same response if the handler returns without
+// responding.
+//
+// The timeout is applied to the request's context, so handlers must honor it. If the
+// handler returns without responding once it has expired, the client gets 504 Gateway
+// Timeout and {"error": "request timed out"}. Handlers that respond themselves can use
+// WriteLimitError to follow the same contract.
+func (lm *LimitMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		maxBytes, timeout := lm.limitsFor(r.URL.Path)
+
+		var body *limitedBody
+		if maxBytes > 0 {
+			if r.ContentLength > maxBytes {
+				writeBodyTooLarge(w, maxBytes)
+				return
+			}
+			if r.Body != nil && r.Body != http.NoBody {
+				body = &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, maxBytes)}
+				r.Body = body
+			}
+		}
+		if timeout > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+
+		sw := NewStatusRecorder(w)
+		next.ServeHTTP(sw, r)
+		if sw.Started() {
+			return
+		}
+		switch {
+		case body != nil && body.exceeded.Load() != nil:
+			writeBodyTooLarge(w, body.exceeded.Load().Limit)
+		case errors.Is(r.Context().Err(), context.DeadlineExceeded):
+			writeTimeout(w)
+		}
+	})
+}
+
+// limitsFor returns the body limit and timeout for the route with the longest prefix
+// of path, or the defaults if no route matches.
+func (lm *LimitMiddleware) limitsFor(path string) (int64, time.Duration) {
+	maxBytes, timeout := lm.options.MaxBodyBytes, lm.options.Timeout
+	best := -1
+	for i, route := range lm.options.Routes {
+		if strings.HasPrefix(path, route.Path) && (best < 0 || len(route.Path) > len(lm.options.Routes[best].Path)) {
+			best = i
+		}
+	}
+	if best >= 0 {
+		if route := lm.options.Routes[best]; route.MaxBodyBytes != 0 {
+			maxBytes = route.MaxBodyBytes
+		}
+		if route := lm.options.Routes[best]; route.Timeout != 0 {
+			timeout = route.Timeout
+		}
+	}
+	return maxBytes, timeout
+}
+
+// WriteLimitError responds with the error contract of LimitMiddleware if err reports
+// that a request exceeded its body limit (413) or its deadline (504), and reports
+// whether it did. Proxies and other handlers that turn errors into responses call it
+// first so clients see the same errors whichever layer noticed the limit.
+func WriteLimitError(w http.ResponseWriter, err error) bool {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		writeBodyTooLarge(w, tooLarge.Limit)
+	case errors.Is(err, context.DeadlineExceeded):
+		writeTimeout(w)
+	default:
+		return false
+	}
+	return true
+}
+
+// writeBodyTooLarge responds with 413 Request Entity Too Large.
+func writeBodyTooLarge(w http.ResponseWriter, limit int64) {
+	w.Header().Set("Connection", "close")
+	JSONResponse(w, http.StatusRequestEntityTooLarge, map[string]interface{}{
+		"error":     "request body too large",
+		"max_bytes": limit,
+	})
+}
+
+// writeTimeout responds with 504 Gateway Timeout.
+func writeTimeout(w http.ResponseWriter) {
+	JSONResponse(w, http.StatusGatewayTimeout, map[string]string{"error": "request timed out"})
+}
+
+// limitedBody records whether reading a body failed because it exceeded its limit.
+// Transports may read the body from another goroutine, so the error is kept atomically.
+type limitedBody struct {
+	io.ReadCloser
+	exceeded atomic.Pointer[http.MaxBytesError]
+}
+
+// Read records the limit error, if any.
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		b.exceeded.Store(tooLarge)
+	}
+	return n, err
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimitMiddlewareBodySize(t *testing.T) {
+	lm := NewLimitMiddleware(LimitOptions{
+		MaxBodyBytes: 8,
+		Routes:       []RouteLimit{{Path: "/api/embed", MaxBodyBytes: 64}},
+	})
+	handler := lm.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		chunked    bool
+		wantStatus int
+	}{
+		{"within limit", "/api/generate", "12345678", false, http.StatusNoContent},
+		{"over limit", "/api/generate", "123456789", false, http.StatusRequestEntityTooLarge},
+		{"over limit without length", "/api/generate", "123456789", true, http.StatusRequestEntityTooLarge},
+		{"route limit", "/api/embed", strings.Repeat("x", 64), false, http.StatusNoContent},
+		{"over route limit", "/api/embed", strings.Repeat("x", 65), true, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if tt.wantStatus == http.StatusRequestEntityTooLarge && !strings.Contains(rr.Body.String(), `"error":"request body too large"`) {
+				t.Errorf("Expected a JSON error body, got %q", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestLimitMiddlewareTimeout(t *testing.T) {
+	lm := NewLimitMiddleware(LimitOptions{
+		Timeout: 20 * time.Millisecond,
+		Routes:  []RouteLimit{{Path: "/api/pull", Timeout: -1}},
+	})
+	handler := lm.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		<-r.Context().Done()
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/generate", nil))
+	if rr.Code != http.StatusGatewayTimeout || !strings.Contains(rr.Body.String(), `"error":"request timed out"`) {
+		t.Errorf("Expected status 504 with a JSON error body, got %d: %q", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/pull", nil))
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Expected the route to have no timeout, got status %d", rr.Code)
+	}
+}
+
+func TestWriteLimitError(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if !WriteLimitError(rr, fmt.Errorf("failed to read request body: %w", &http.MaxBytesError{Limit: 42})) {
+		t.Fatal("Expected a body limit error to be written")
+	}
+	if rr.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rr.Body.String(), `"max_bytes":42`) {
+		t.Errorf("Expected status 413 with the limit, got %d: %q", rr.Code, rr.Body.String())
+	}
+
+	if WriteLimitError(httptest.NewRecorder(), errors.New("connection refused")) {
+		t.Error("Expected other errors to be left to the caller")
+	}
+}
@@ -1,9 +1,51 @@
-package middleware
-
-import "net/http"
-
-// Middleware interface for request/response lifecycle handling
-type Middleware interface {
-	ProcessRequest(req *http.Request) (*http.Request, error)
-	ProcessResponse(res *http.Response) (*http.Response, error)
-}
+package middleware
+
+import "net/http"
+
+// Middleware interface for request/response lifecycle handling
+type Middleware interface {
+	ProcessRequest(req *http.Request) (*http.Request, error)
+	ProcessResponse(res *http.Response) (*http.Response, error)
+}
+
+// Transport is an http.RoundTripper that passes each outgoing request through a chain
+// of Middleware before sending it, and each response back through the chain in reverse.
+// It lets an http.Client sign or authenticate every request it makes.
+type Transport struct {
+	// Base is the transport that sends the request.
+	// Default: http.DefaultTransport
+	Base http.RoundTripper
+
+	// Middleware is applied to requests in order and to responses in reverse order.
+	Middleware []Middleware
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	// A RoundTripper must not modify the caller's request
+	req = req.Clone(req.Context())
+
+	var err error
+	for _, m := range t.Middleware {
+		if req, err = m.ProcessRequest(req); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(t.Middleware) - 1; i >= 0; i-- {
+		if res, err = t.Middleware[i].ProcessResponse(res); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,213 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/h2co32/gollama/pkg/logging"
+	"github.com/h2co32/gollama/pkg/ratelimiter"
+)
+
+// Rate limit response headers
+const (
+	// RateLimitLimitHeader reports the burst capacity of the client's bucket
+	RateLimitLimitHeader = "X-RateLimit-Limit"
+
+	// RateLimitRemainingHeader reports the number of requests left in the client's bucket
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+
+	// RateLimitResetHeader reports the number of seconds until the client's bucket is full again
+	RateLimitResetHeader = "X-RateLimit-Reset"
+)
+
+// RouteRateLimit sets a separate limit for requests whose path starts with Path.
+type RouteRateLimit struct {
+	// Path is the URL path prefix the limit applies to. The longest matching prefix wins.
+	Path string
+
+	// Rate is the number of requests allowed per Interval for each key.
+	Rate float64
+
+	// Interval is the time interval for rate calculation.
+	// Default: 1 second
+	Interval time.Duration
+
+	// Capacity is the burst capacity for each key.
+	// Default: Rate
+	Capacity float64
+}
+
+// RateLimitOptions configures the RateLimitMiddleware.
+type RateLimitOptions struct {
+	// Rate is the number of requests allowed per Interval for each key on routes
+	// without their own limit.
+	Rate float64
+
+	// Interval is the time interval for rate calculation.
+	// Default: 1 second
+	Interval time.Duration
+
+	// Capacity is the burst capacity for each key.
+	// Default: Rate
+	Capacity float64
+
+	// Routes sets per-route limits that replace the default limit.
+	Routes []RouteRateLimit
+
+	// KeyFunc returns the key requests are limited by, such as an API key or client IP.
+	// Default: KeyByIP
+	KeyFunc func(r *http.Request) string
+
+	// IdleTimeout and MaxKeys bound the per-key state kept for each limit.
+	// See ratelimiter.KeyedOptions for defaults.
+	IdleTimeout time.Duration
+	MaxKeys     int
+
+	// ErrorHandler is an optional custom handler for rejected requests. The rate limit
+	// headers, including Retry-After, are set before it is called.
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, retryAfter time.Duration)
+}
+
+// DefaultRateLimitOptions returns the default options for a RateLimitMiddleware.
+func DefaultRateLimitOptions() RateLimitOptions {
+	return RateLimitOptions{
+		Rate:     10,
+		Interval: time.Second,
+		Capacity: 10,
+		KeyFunc:  KeyByIP,
+	}
+}
+
+// RateLimitMiddleware limits the request rate of each client, optionally with
+// different limits for different routes.
+type RateLimitMiddleware struct {
+	options  RateLimitOptions
+	limiter  *ratelimiter.KeyedLimiter
+	routes   []RouteRateLimit
+	limiters []*ratelimiter.KeyedLimiter // Parallel to routes
+}
+
+// NewRateLimitMiddleware initializes a RateLimitMiddleware with specified options.
+func NewRateLimitMiddleware(options RateLimitOptions) *RateLimitMiddleware {
+	if options.KeyFunc == nil {
+		options.KeyFunc = KeyByIP
+	}
+
+	rlm := &RateLimitMiddleware{
+		options: options,
+		limiter: ratelimiter.NewKeyedLimiter(ratelimiter.KeyedOptions{
+			Rate:        options.Rate,
+			Interval:    options.Interval,
+			Capacity:    options.Capacity,
+			IdleTimeout: options.IdleTimeout,
+			MaxKeys:     options.MaxKeys,
+		}),
+	}
+	for _, route := range options.Routes {
+		rlm.routes = append(rlm.routes, route)
+		rlm.limiters = append(rlm.limiters, ratelimiter.NewKeyedLimiter(ratelimiter.KeyedOptions{
+			Rate:        route.Rate,
+			Interval:    route.Interval,
+			Capacity:    route.Capacity,
+			IdleTimeout: options.IdleTimeout,
+			MaxKeys:     options.MaxKeys,
+		}))
+	}
+	return rlm
+}
+
+// Middleware rejects requests over the client's limit with 429 Too Many Requests.
+// Every response carries the X-RateLimit-* headers, and rejections also carry Retry-After.
+func (rlm *RateLimitMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limiter := rlm.limiterFor(r.URL.Path).Limiter(rlm.options.KeyFunc(r))
+
+		// Reserve a token and give it back if the request would have to wait for it
+		reservation := limiter.Reserve()
+		retryAfter := reservation.Delay()
+		if retryAfter > 0 {
+			reservation.Cancel()
+		}
+		setRateLimitHeaders(w, limiter)
+
+		if retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(retryAfter)))
+			rlm.handleError(w, r, retryAfter)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limiterFor returns the keyed limiter for the route with the longest prefix of path,
+// or the default limiter if no route matches.
+func (rlm *RateLimitMiddleware) limiterFor(path string) *ratelimiter.KeyedLimiter {
+	best := -1
+	for i, route := range rlm.routes {
+		if strings.HasPrefix(path, route.Path) && (best < 0 || len(route.Path) > len(rlm.routes[best].Path)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return rlm.limiter
+	}
+	return rlm.limiters[best]
+}
+
+// handleError responds to a request that is over its limit.
+func (rlm *RateLimitMiddleware) handleError(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	if rlm.options.ErrorHandler != nil {
+		rlm.options.ErrorHandler(w, r, retryAfter)
+		return
+	}
+
+	// Default error handling
+	logging.Default().WarnContext(r.Context(), "rate limit exceeded", "method", r.Method, "path", r.URL.Path)
+	JSONResponse(w, http.StatusTooManyRequests, map[string]interface{}{
+		"error":       "rate limit exceeded",
+		"retry_after": ceilSeconds(retryAfter),
+	})
+}
+
+// setRateLimitHeaders reports the state of the client's bucket.
+func setRateLimitHeaders(w http.ResponseWriter, limiter *ratelimiter.RateLimiter) {
+	available := limiter.Available()
+	remaining := int(math.Max(0, math.Floor(available)))
+	reset := time.Duration((limiter.Capacity() - available) / limiter.Rate() * float64(limiter.Interval()))
+
+	w.Header().Set(RateLimitLimitHeader, strconv.Itoa(int(limiter.Capacity())))
+	w.Header().Set(RateLimitRemainingHeader, strconv.Itoa(remaining))
+	w.Header().Set(RateLimitResetHeader, strconv.Itoa(ceilSeconds(reset)))
+}
+
+// ceilSeconds rounds a duration up to whole seconds.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// KeyByIP limits requests by the client's IP address, taken from the connection's
+// remote address. Forwarding headers are ignored because clients can set them freely;
+// use KeyByHeader behind a trusted proxy that sets one.
+func KeyByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// KeyByHeader returns a key function that limits requests by the value of the named
+// header, such as an API key header. Requests without the header are limited by IP.
+func KeyByHeader(name string) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		if value := r.Header.Get(name); value != "" {
+			return name + ":" + value
+		}
+		return KeyByIP(r)
+	}
+}
@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	rlm := NewRateLimitMiddleware(RateLimitOptions{Rate: 2, Interval: time.Minute})
+	handler := rlm.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/generate", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i, remaining := range []string{"1", "0"} {
+		rec := request("10.0.0.1:1234")
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected status code %d for request %d, got %d", http.StatusOK, i+1, rec.Code)
+		}
+		if got := rec.Header().Get(RateLimitLimitHeader); got != "2" {
+			t.Errorf("Expected %s to be 2, got %s", RateLimitLimitHeader, got)
+		}
+		if got := rec.Header().Get(RateLimitRemainingHeader); got != remaining {
+			t.Errorf("Expected %s to be %s, got %s", RateLimitRemainingHeader, remaining, got)
+		}
+	}
+
+	rec := request("10.0.0.1:5678")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status code %d, got %d", http.StatusTooManyRequests, rec.Code)
+	}
+	// One token takes 30 seconds at 2 per minute
+	if got := rec.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Expected Retry-After to be 30, got %s", got)
+	}
+	if got := rec.Header().Get(RateLimitResetHeader); got != "60" {
+		t.Errorf("Expected %s to be 60, got %s", RateLimitResetHeader, got)
+	}
+	var body map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response body: %v", err)
+	}
+	if body["error"] != "rate limit exceeded" || body["retry_after"] != float64(30) {
+		t.Errorf("Unexpected response body: %v", body)
+	}
+
+	// Other clients have their own limit
+	if rec := request("10.0.0.2:1234"); rec.Code != http.StatusOK {
+		t.Errorf("Expected status code %d for another client, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestRateLimitMiddlewareRoutesAndKeys(t *testing.T) {
+	rlm := NewRateLimitMiddleware(RateLimitOptions{
+		Rate:     5,
+		Interval: time.Minute,
+		Routes: []RouteRateLimit{
+			{Path: "/api/", Rate: 2, Interval: time.Minute},
+			{Path: "/api/embed", Rate: 1, Interval: time.Minute},
+		},
+		KeyFunc: KeyByHeader("X-API-Key"),
+	})
+	handler := rlm.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(path, apiKey string) int {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set("X-API-Key", apiKey)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// The longest matching route wins
+	if code := request("/api/embed", "a"); code != http.StatusOK {
+		t.Errorf("Expected first embed request to succeed, got %d", code)
+	}
+	if code := request("/api/embed", "a"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected second embed request to be limited, got %d", code)
+	}
+
+	// Routes are limited independently of each other
+	for i := 0; i < 2; i++ {
+		if code := request("/api/chat", "a"); code != http.StatusOK {
+			t.Errorf("Expected chat request %d to succeed, got %d", i+1, code)
+		}
+	}
+	if code := request("/api/chat", "a"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected third chat request to be limited, got %d", code)
+	}
+
+	// Keys are limited independently of each other
+	if code := request("/api/chat", "b"); code != http.StatusOK {
+		t.Errorf("Expected request with another API key to succeed, got %d", code)
+	}
+
+	// Paths outside every route use the default limit
+	if code := request("/health", "a"); code != http.StatusOK {
+		t.Errorf("Expected request outside the routes to succeed, got %d", code)
+	}
+}
+
+func TestRateLimitMiddlewareErrorHandler(t *testing.T) {
+	var gotRetryAfter time.Duration
+	rlm := NewRateLimitMiddleware(RateLimitOptions{
+		Rate:     1,
+		Interval: time.Second,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+			gotRetryAfter = retryAfter
+			w.WriteHeader(http.StatusServiceUnavailable)
+		},
+	})
+	handler := rlm.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if i == 1 && rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected custom error handler status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+		}
+	}
+	if gotRetryAfter <= 0 || gotRetryAfter > time.Second {
+		t.Errorf("Expected retry after between 0 and 1s, got %v", gotRetryAfter)
+	}
+}
+
+func TestKeyFuncs(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+
+	if key := KeyByIP(req); key != "192.0.2.1" {
+		t.Errorf("Expected KeyByIP to return 192.0.2.1, got %s", key)
+	}
+	if key := KeyByHeader("X-API-Key")(req); key != "192.0.2.1" {
+		t.Errorf("Expected KeyByHeader to fall back to the IP, got %s", key)
+	}
+	req.Header.Set("X-API-Key", "secret")
+	if key := KeyByHeader("X-API-Key")(req); key != "X-API-Key:secret" {
+		t.Errorf("Expected KeyByHeader to use the header, got %s", key)
+	}
+}
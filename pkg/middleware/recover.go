@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/h2co32/gollama/pkg/logging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrorMetrics counts errors by endpoint and type. *metrics.MetricsProvider implements it.
+type ErrorMetrics interface {
+	TrackError(endpoint, errorType string)
+}
+
+// RecoverOptions configures the RecoverMiddleware.
+type RecoverOptions struct {
+	// Metrics counts every recovered panic as an error of type "panic" for the request
+	// path. Optional.
+	Metrics ErrorMetrics
+
+	// ErrorHandler is an optional custom handler for requests whose handler panicked.
+	// It is not called if the handler had already started the response.
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, recovered any)
+}
+
+// RecoverMiddleware recovers panics in HTTP handlers, so one failing request does not
+// take down the connection without a response.
+type RecoverMiddleware struct {
+	options RecoverOptions
+}
+
+// NewRecoverMiddleware initializes a RecoverMiddleware with specified options.
+func NewRecoverMiddleware(options RecoverOptions) *RecoverMiddleware {
+	return &RecoverMiddleware{options: options}
+}
+
+// Middleware recovers panics in next. Each panic is logged with its stack trace,
+// recorded as an error on the span in the request's context, and counted in Metrics,
+// and the client gets 500 Internal Server Error with a JSON body of the form
+// {"error": "internal server error"} unless the response had already started.
+// Panics with http.ErrAbortHandler are passed on, since they abort the response on purpose.
+func (rm *RecoverMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := NewStatusRecorder(w)
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(recovered)
+			}
+			rm.report(r, recovered, debug.Stack())
+
+			if sw.Started() {
+				return
+			}
+			if rm.options.ErrorHandler != nil {
+				rm.options.ErrorHandler(w, r, recovered)
+				return
+			}
+			JSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "internal server error"})
+		}()
+
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// report logs, traces, and counts a recovered panic.
+func (rm *RecoverMiddleware) report(r *http.Request, recovered any, stack []byte) {
+	ctx := r.Context()
+	logging.Default().ErrorContext(ctx, "panic recovered",
+		"panic", recovered,
+		"method", r.Method,
+		"path", r.URL.Path,
+		"stack", string(stack),
+	)
+
+	span := trace.SpanFromContext(ctx)
+	err := fmt.Errorf("panic: %v", recovered)
+	span.RecordError(err, trace.WithAttributes(attribute.String("exception.stacktrace", string(stack))))
+	span.SetStatus(codes.Error, err.Error())
+
+	if rm.options.Metrics != nil {
+		rm.options.Metrics.TrackError(r.URL.Path, "panic")
+	}
+}
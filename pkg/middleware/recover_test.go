@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/h2co32/gollama/pkg/logging"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// errorCounter counts the errors it is given by type.
+type errorCounter map[string]int
+
+func (c errorCounter) TrackError(endpoint, errorType string) {
+	c[endpoint+" "+errorType]++
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	var logs bytes.Buffer
+	previous := logging.Default()
+	logging.SetDefault(logging.New(logging.Options{Format: logging.FormatJSON, Sink: &logs}))
+	defer logging.SetDefault(previous)
+
+	recorder := tracetest.NewSpanRecorder()
+	ctx, span := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test").Start(context.Background(), "request")
+
+	counter := errorCounter{}
+	rm := NewRecoverMiddleware(RecoverOptions{Metrics: counter})
+	handler := rm.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("nil model")
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/generate", nil).WithContext(ctx))
+	span.End()
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", rr.Code)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body["error"] != "internal server error" {
+		t.Errorf("Expected a JSON error body, got %q", rr.Body.String())
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+		t.Fatalf("Expected one JSON log record, got %q: %v", logs.String(), err)
+	}
+	if record["panic"] != "nil model" || record["path"] != "/api/generate" {
+		t.Errorf("Expected the log record to describe the panic, got %v", record)
+	}
+	if stack, _ := record["stack"].(string); !strings.Contains(stack, "TestRecoverMiddleware") {
+		t.Errorf("Expected the log record to have the stack trace, got %q", stack)
+	}
+	if record["trace_id"] != span.SpanContext().TraceID().String() {
+		t.Errorf("Expected the log record to carry the trace ID, got %v", record["trace_id"])
+	}
+
+	ended := recorder.Ended()
+	if len(ended) != 1 || ended[0].Status().Code != codes.Error || len(ended[0].Events()) != 1 {
+		t.Fatalf("Expected the span to record the panic as an error, got %v", ended)
+	}
+
+	if counter["/api/generate panic"] != 1 {
+		t.Errorf("Expected the panic to be counted, got %v", counter)
+	}
+}
+
+func TestRecoverMiddlewareStartedResponse(t *testing.T) {
+	previous := logging.Default()
+	logging.SetDefault(nil)
+	defer logging.SetDefault(previous)
+
+	called := false
+	rm := NewRecoverMiddleware(RecoverOptions{
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, recovered any) { called = true },
+	})
+	handler := rm.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "partial")
+		panic("stream broke")
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if called || rr.Body.String() != "partial" {
+		t.Errorf("Expected a started response to be left alone, got %q", rr.Body.String())
+	}
+}
+
+func TestRecoverMiddlewareAbortHandler(t *testing.T) {
+	rm := NewRecoverMiddleware(RecoverOptions{})
+	handler := rm.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if recovered := recover(); recovered != http.ErrAbortHandler {
+			t.Errorf("Expected http.ErrAbortHandler to be passed on, got %v", recovered)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
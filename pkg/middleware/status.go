@@ -0,0 +1,61 @@
+package middleware
+
+import "net/http"
+
+// StatusRecorder wraps a ResponseWriter to record the status code and body size a
+// handler writes, for middleware that logs, measures, or traces responses, or that
+// must know whether the response has started.
+type StatusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+// NewStatusRecorder returns a StatusRecorder that writes to w.
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+	return &StatusRecorder{ResponseWriter: w}
+}
+
+// WriteHeader records the status code before writing it. Informational (1xx) status
+// codes are written but not recorded, since the final status code follows them.
+func (sr *StatusRecorder) WriteHeader(status int) {
+	if sr.status == 0 && status >= http.StatusOK {
+		sr.status = status
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the implicit 200 OK status if no status code was written, and counts
+// the bytes written.
+func (sr *StatusRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	n, err := sr.ResponseWriter.Write(b)
+	sr.size += int64(n)
+	return n, err
+}
+
+// Unwrap returns the underlying ResponseWriter so http.ResponseController can flush streamed responses.
+func (sr *StatusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// Status returns the status code written, or 200 OK, which net/http sends for a
+// handler that writes nothing.
+func (sr *StatusRecorder) Status() int {
+	if sr.status == 0 {
+		return http.StatusOK
+	}
+	return sr.status
+}
+
+// Started reports whether a final status code or any of the body has been written.
+func (sr *StatusRecorder) Started() bool {
+	return sr.status != 0
+}
+
+// Size returns the number of body bytes written.
+func (sr *StatusRecorder) Size() int64 {
+	return sr.size
+}
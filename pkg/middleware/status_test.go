@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatusRecorder(t *testing.T) {
+	w := httptest.NewRecorder()
+	sr := NewStatusRecorder(w)
+	if sr.Started() || sr.Status() != http.StatusOK || sr.Size() != 0 {
+		t.Errorf("Expected an unstarted 200 OK response, got started %v, status %d, size %d", sr.Started(), sr.Status(), sr.Size())
+	}
+
+	sr.WriteHeader(http.StatusTeapot)
+	sr.WriteHeader(http.StatusInternalServerError)
+	sr.Write([]byte("short and stout"))
+	if !sr.Started() || sr.Status() != http.StatusTeapot || sr.Size() != 15 {
+		t.Errorf("Expected a started 418 response of 15 bytes, got started %v, status %d, size %d", sr.Started(), sr.Status(), sr.Size())
+	}
+	if w.Code != http.StatusTeapot || w.Body.String() != "short and stout" {
+		t.Errorf("Expected the response to be written through, got %d %q", w.Code, w.Body.String())
+	}
+
+	// Informational status codes are not the final status
+	sr = NewStatusRecorder(httptest.NewRecorder())
+	sr.WriteHeader(http.StatusEarlyHints)
+	if sr.Started() {
+		t.Error("Expected 103 Early Hints not to start the response")
+	}
+
+	// Writing the body alone sends 200 OK
+	sr = NewStatusRecorder(httptest.NewRecorder())
+	sr.Write([]byte("ok"))
+	if !sr.Started() || sr.Status() != http.StatusOK {
+		t.Errorf("Expected a started 200 OK response, got started %v, status %d", sr.Started(), sr.Status())
+	}
+
+	if err := http.NewResponseController(sr).Flush(); err != nil {
+		t.Errorf("Expected the recorder to unwrap to a flusher, got %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package observability
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/h2co32/gollama/pkg/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// httpTracerName names the tracer of the HTTP middleware and transport.
+const httpTracerName = "github.com/h2co32/gollama/pkg/observability"
+
+// HTTPMiddleware traces every request served by next. It extracts the W3C trace
+// context of the caller from the request headers, so the server span continues the
+// caller's trace, and passes the span to next in the request's context. Spans have the
+// standard HTTP attributes, are named after the pattern of the http.ServeMux that
+// matched the request if next is one, and are marked as errors for 5xx statuses.
+// It uses the global trace provider and propagator, as set by NewTracerProvider.
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		attrs := append(methodAttributes(r.Method),
+			semconv.URLPath(r.URL.Path),
+			semconv.UserAgentOriginal(r.UserAgent()),
+			semconv.NetworkProtocolVersion(strings.TrimPrefix(r.Proto, "HTTP/")),
+		)
+		attrs = append(attrs, hostAttributes(r.Host)...)
+		if r.TLS != nil {
+			attrs = append(attrs, semconv.URLScheme("https"))
+		} else {
+			attrs = append(attrs, semconv.URLScheme("http"))
+		}
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			attrs = append(attrs, semconv.ClientAddress(host))
+		}
+
+		ctx, span := otel.Tracer(httpTracerName, trace.WithInstrumentationVersion(Version)).Start(ctx, spanMethod(r.Method),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attrs...),
+		)
+		defer span.End()
+
+		sw := middleware.NewStatusRecorder(w)
+		r = r.WithContext(ctx)
+		next.ServeHTTP(sw, r)
+
+		// The pattern is only known once the mux has routed the request
+		if r.Pattern != "" {
+			route := r.Pattern
+			if i := strings.IndexByte(route, ' '); i >= 0 {
+				route = route[i+1:]
+			}
+			span.SetName(spanMethod(r.Method) + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(sw.Status()))
+		if sw.Status() >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(sw.Status()))
+		}
+	})
+}
+
+// HTTPTransport returns a RoundTripper that traces every request sent through base,
+// which defaults to http.DefaultTransport if nil. It injects the W3C trace context into
+// the request headers, so the server continues the trace. Spans have the standard HTTP
+// attributes, last until the response body is read or closed, so they cover streamed
+// responses, and are marked as errors for failed requests and 4xx and 5xx statuses.
+// It uses the global trace provider and propagator, as set by NewTracerProvider.
+func HTTPTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &tracingTransport{base: base}
+}
+
+// tracingTransport is the RoundTripper returned by HTTPTransport.
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip sends the request in a client span.
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attrs := append(methodAttributes(req.Method), semconv.URLFull(req.URL.Redacted()))
+	attrs = append(attrs, hostAttributes(req.URL.Host)...)
+
+	ctx, span := otel.Tracer(httpTracerName, trace.WithInstrumentationVersion(Version)).Start(req.Context(), spanMethod(req.Method),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+
+	// A RoundTripper must not modify the request, so the headers go on a copy
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	res, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
+		return nil, err
+	}
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(res.StatusCode))
+	if res.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, http.StatusText(res.StatusCode))
+	}
+	if res.Body == nil || res.Body == http.NoBody {
+		span.End()
+		return res, nil
+	}
+	res.Body = &tracedBody{ReadCloser: res.Body, span: span}
+	return res, nil
+}
+
+// tracedBody ends a client span once the response body is read to the end, fails, or
+// is closed.
+type tracedBody struct {
+	io.ReadCloser
+	span trace.Span
+	once sync.Once
+}
+
+// Read ends the span at the end of the body or on an error.
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.end()
+	} else if err != nil {
+		b.span.RecordError(err)
+		b.span.SetStatus(codes.Error, err.Error())
+		b.end()
+	}
+	return n, err
+}
+
+// Close ends the span and closes the body.
+func (b *tracedBody) Close() error {
+	b.end()
+	return b.ReadCloser.Close()
+}
+
+// end ends the span the first time it is called.
+func (b *tracedBody) end() {
+	b.once.Do(func() { b.span.End() })
+}
+
+// spanMethod returns the method as it appears in span names, mapping nonstandard
+// methods to "HTTP" so clients cannot create span names at will.
+func spanMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "HTTP"
+	}
+}
+
+// methodAttributes returns the attributes describing the request method. Nonstandard
+// methods are recorded as "_OTHER", with the original method alongside.
+func methodAttributes(method string) []attribute.KeyValue {
+	if spanMethod(method) == method {
+		return []attribute.KeyValue{semconv.HTTPRequestMethodKey.String(method)}
+	}
+	return []attribute.KeyValue{semconv.HTTPRequestMethodOther, semconv.HTTPRequestMethodOriginal(method)}
+}
+
+// hostAttributes returns the attributes describing the server of a request, from a
+// host with an optional port.
+func hostAttributes(host string) []attribute.KeyValue {
+	if name, port, err := net.SplitHostPort(host); err == nil {
+		if number, err := strconv.Atoi(port); err == nil {
+			return []attribute.KeyValue{semconv.ServerAddress(name), semconv.ServerPort(number)}
+		}
+	}
+	if host == "" {
+		return nil
+	}
+	return []attribute.KeyValue{semconv.ServerAddress(host)}
+}
@@ -0,0 +1,138 @@
+package observability
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// useSpanRecorder sets a global trace provider that records spans for the duration of the test
+func useSpanRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+	return recorder
+}
+
+// attributeValue returns the value of the span attribute with the given key, or nil
+func attributeValue(span sdktrace.ReadOnlySpan, key attribute.Key) any {
+	for _, attr := range span.Attributes() {
+		if attr.Key == key {
+			return attr.Value.AsInterface()
+		}
+	}
+	return nil
+}
+
+// TestHTTPTracing tests that a trace continues from the client transport to the server middleware
+func TestHTTPTracing(t *testing.T) {
+	recorder := useSpanRecorder(t)
+
+	var handlerSpan trace.SpanContext
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /models/{name}", func(w http.ResponseWriter, r *http.Request) {
+		handlerSpan = trace.SpanContextFromContext(r.Context())
+		io.WriteString(w, "llama3")
+	})
+	mux.HandleFunc("POST /generate", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	server := httptest.NewServer(HTTPMiddleware(mux))
+	defer server.Close()
+
+	client := &http.Client{Transport: HTTPTransport(nil)}
+	res, err := client.Get(server.URL + "/models/llama3")
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	io.ReadAll(res.Body)
+	res.Body.Close()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	serverSpan, clientSpan := spans[0], spans[1]
+	if serverSpan.SpanKind() != trace.SpanKindServer || clientSpan.SpanKind() != trace.SpanKindClient {
+		t.Fatalf("Expected a server and a client span, got %v and %v", serverSpan.SpanKind(), clientSpan.SpanKind())
+	}
+	if serverSpan.Parent().SpanID() != clientSpan.SpanContext().SpanID() || serverSpan.SpanContext().TraceID() != clientSpan.SpanContext().TraceID() {
+		t.Error("Expected the server span to continue the client's trace")
+	}
+	if handlerSpan.SpanID() != serverSpan.SpanContext().SpanID() {
+		t.Error("Expected the handler to receive the server span in its context")
+	}
+
+	if serverSpan.Name() != "GET /models/{name}" {
+		t.Errorf("Expected the server span to be named after the route, got %s", serverSpan.Name())
+	}
+	if route := attributeValue(serverSpan, "http.route"); route != "/models/{name}" {
+		t.Errorf("Expected the route attribute to be /models/{name}, got %v", route)
+	}
+	if path := attributeValue(serverSpan, "url.path"); path != "/models/llama3" {
+		t.Errorf("Expected the path attribute to be /models/llama3, got %v", path)
+	}
+	if clientSpan.Name() != "GET" {
+		t.Errorf("Expected the client span to be named after the method, got %s", clientSpan.Name())
+	}
+	if url := attributeValue(clientSpan, "url.full"); url != server.URL+"/models/llama3" {
+		t.Errorf("Expected the URL attribute to be the request's URL, got %v", url)
+	}
+	for _, span := range spans {
+		if status := attributeValue(span, "http.response.status_code"); status != int64(200) {
+			t.Errorf("Expected the status attribute of %s to be 200, got %v", span.Name(), status)
+		}
+		if span.Status().Code == codes.Error {
+			t.Errorf("Expected %s not to be an error", span.Name())
+		}
+	}
+
+	// Server errors fail both spans
+	res, err = client.Post(server.URL+"/generate", "application/json", nil)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	res.Body.Close()
+	for _, span := range recorder.Ended()[2:] {
+		if span.Status().Code != codes.Error {
+			t.Errorf("Expected %s to be an error", span.Name())
+		}
+	}
+}
+
+// TestHTTPTransportError tests that failed requests end their span with an error
+func TestHTTPTransportError(t *testing.T) {
+	recorder := useSpanRecorder(t)
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	client := &http.Client{Transport: HTTPTransport(nil)}
+	if _, err := client.Get(url); err == nil {
+		t.Fatal("Expected an error for a closed server")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Status().Code != codes.Error {
+		t.Fatalf("Expected 1 failed span, got %v", spans)
+	}
+	if len(spans[0].Events()) == 0 {
+		t.Error("Expected the error to be recorded")
+	}
+}
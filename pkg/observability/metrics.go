@@ -0,0 +1,179 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// MetricExporter selects where a MeterProvider sends its metrics.
+type MetricExporter string
+
+const (
+	// MetricExporterOTLP pushes metrics to an OpenTelemetry collector over OTLP/HTTP.
+	MetricExporterOTLP MetricExporter = "otlp"
+
+	// MetricExporterPrometheus registers metrics in a Prometheus registry, to be
+	// scraped from MeterProvider.Handler.
+	MetricExporterPrometheus MetricExporter = "prometheus"
+)
+
+// MeterProvider wraps the OpenTelemetry MeterProvider with additional functionality.
+// Code instruments once with the Meter, and the MeterOptions.Exporter decides whether
+// the measurements are pushed over OTLP or scraped by Prometheus.
+type MeterProvider struct {
+	provider *sdkmetric.MeterProvider
+	meter    metric.Meter
+	handler  http.Handler
+}
+
+// MeterOptions configures the MeterProvider.
+type MeterOptions struct {
+	// Exporter selects where metrics are sent.
+	// Default: MetricExporterOTLP
+	Exporter MetricExporter
+
+	// ExportInterval is how often metrics are pushed over OTLP. It is ignored by the
+	// Prometheus exporter, which is read whenever it is scraped.
+	// Default: 60s
+	ExportInterval time.Duration
+
+	// Registry is where the Prometheus exporter registers the metrics.
+	// Default: the global registry, prometheus.DefaultRegisterer and
+	// prometheus.DefaultGatherer
+	Registry *prometheus.Registry
+
+	// ServiceNamespace is an optional namespace for the service.
+	ServiceNamespace string
+
+	// ServiceVersion is the version of the service.
+	// Default: "unknown"
+	ServiceVersion string
+
+	// AdditionalAttributes are additional resource attributes to include with all metrics.
+	AdditionalAttributes []attribute.KeyValue
+}
+
+// DefaultMeterOptions returns the default meter options.
+func DefaultMeterOptions() MeterOptions {
+	return MeterOptions{
+		Exporter:       MetricExporterOTLP,
+		ExportInterval: 60 * time.Second,
+		ServiceVersion: "unknown",
+	}
+}
+
+// NewMeterProvider creates a new MeterProvider that pushes metrics over OTLP to the
+// specified endpoint, the URL of the OpenTelemetry collector, e.g., "http://localhost:4318".
+func NewMeterProvider(serviceName, endpoint string) (*MeterProvider, error) {
+	return NewMeterProviderWithOptions(serviceName, endpoint, DefaultMeterOptions())
+}
+
+// NewMeterProviderWithOptions creates a new MeterProvider with custom options. The
+// endpoint is only used by the OTLP exporter, and may be empty for Prometheus.
+// Zero option values fall back to the defaults.
+func NewMeterProviderWithOptions(serviceName, endpoint string, options MeterOptions) (*MeterProvider, error) {
+	defaults := DefaultMeterOptions()
+	if options.Exporter == "" {
+		options.Exporter = defaults.Exporter
+	}
+	if options.ExportInterval <= 0 {
+		options.ExportInterval = defaults.ExportInterval
+	}
+	if options.ServiceVersion == "" {
+		options.ServiceVersion = defaults.ServiceVersion
+	}
+
+	if serviceName == "" {
+		return nil, fmt.Errorf("service name cannot be empty")
+	}
+
+	mp := &MeterProvider{
+		handler: http.NotFoundHandler(),
+	}
+
+	var reader sdkmetric.Reader
+	switch options.Exporter {
+	case MetricExporterOTLP:
+		if endpoint == "" {
+			return nil, fmt.Errorf("endpoint cannot be empty")
+		}
+		exporter, err := otlpmetrichttp.New(context.Background(), otlpEndpointOptions(endpoint)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP metric exporter: %w", err)
+		}
+		reader = sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(options.ExportInterval))
+
+	case MetricExporterPrometheus:
+		var registerer prometheus.Registerer = prometheus.DefaultRegisterer
+		var gatherer prometheus.Gatherer = prometheus.DefaultGatherer
+		if options.Registry != nil {
+			registerer, gatherer = options.Registry, options.Registry
+		}
+		exporter, err := otelprometheus.New(otelprometheus.WithRegisterer(registerer))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Prometheus metric exporter: %w", err)
+		}
+		reader = exporter
+		mp.handler = promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})
+
+	default:
+		return nil, fmt.Errorf("unknown metric exporter: %s", options.Exporter)
+	}
+
+	mp.provider = sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithResource(newResource(serviceName, options.ServiceNamespace, options.ServiceVersion, options.AdditionalAttributes)),
+	)
+
+	// Set the global meter provider
+	otel.SetMeterProvider(mp.provider)
+
+	mp.meter = mp.provider.Meter(serviceName, metric.WithInstrumentationVersion(Version))
+	return mp, nil
+}
+
+// otlpEndpointOptions returns the options that point an OTLP/HTTP metric exporter at
+// endpoint, which is either a URL, or a host and port reached over plain HTTP.
+func otlpEndpointOptions(endpoint string) []otlpmetrichttp.Option {
+	if strings.Contains(endpoint, "://") {
+		return []otlpmetrichttp.Option{otlpmetrichttp.WithEndpointURL(endpoint)}
+	}
+	return []otlpmetrichttp.Option{
+		otlpmetrichttp.WithEndpoint(endpoint),
+		otlpmetrichttp.WithInsecure(), // For development; use TLS in production
+	}
+}
+
+// Meter returns the meter instance, from which counters, histograms, and gauges are created.
+func (mp *MeterProvider) Meter() metric.Meter {
+	return mp.meter
+}
+
+// Handler serves the metrics for Prometheus to scrape. It responds with 404 Not Found
+// unless the exporter is MetricExporterPrometheus.
+func (mp *MeterProvider) Handler() http.Handler {
+	return mp.handler
+}
+
+// ForceFlush pushes the metrics recorded so far over OTLP without waiting for the
+// next export interval.
+func (mp *MeterProvider) ForceFlush(ctx context.Context) error {
+	return mp.provider.ForceFlush(ctx)
+}
+
+// Shutdown shuts down the meter provider, pushing any remaining metrics.
+func (mp *MeterProvider) Shutdown(ctx context.Context) error {
+	return mp.provider.Shutdown(ctx)
+}
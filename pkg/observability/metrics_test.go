@@ -0,0 +1,105 @@
+package observability
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TestMeterProviderPrometheus tests that instruments are scraped from the Handler
+func TestMeterProviderPrometheus(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	mp, err := NewMeterProviderWithOptions("test-service", "", MeterOptions{
+		Exporter: MetricExporterPrometheus,
+		Registry: registry,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer mp.Shutdown(context.Background())
+
+	counter, err := mp.Meter().Int64Counter("embeddings")
+	if err != nil {
+		t.Fatalf("Failed to create counter: %v", err)
+	}
+	counter.Add(context.Background(), 3)
+
+	recorder := httptest.NewRecorder()
+	mp.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(recorder.Body.String(), "embeddings_total{") {
+		t.Errorf("Expected the counter to be scraped, got %s", recorder.Body.String())
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	var value float64
+	for _, family := range families {
+		if family.GetName() == "embeddings_total" {
+			value = family.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	if value != 3 {
+		t.Errorf("Expected the counter to be 3, got %v", value)
+	}
+}
+
+// TestMeterProviderOTLP tests that instruments are pushed to the collector
+func TestMeterProviderOTLP(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		select {
+		case requests <- r:
+		default:
+		}
+	}))
+	defer collector.Close()
+
+	mp, err := NewMeterProvider("test-service", collector.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	histogram, err := mp.Meter().Float64Histogram("latency")
+	if err != nil {
+		t.Fatalf("Failed to create histogram: %v", err)
+	}
+	histogram.Record(context.Background(), 0.25)
+
+	recorder := httptest.NewRecorder()
+	mp.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected the handler to respond with 404 without Prometheus, got %d", recorder.Code)
+	}
+
+	if err := mp.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Expected no error shutting down, got %v", err)
+	}
+	select {
+	case r := <-requests:
+		if r.URL.Path != "/v1/metrics" {
+			t.Errorf("Expected metrics to be pushed to /v1/metrics, got %s", r.URL.Path)
+		}
+	default:
+		t.Error("Expected the metrics to be pushed on shutdown")
+	}
+}
+
+// TestNewMeterProviderErrors tests that invalid configurations are rejected
+func TestNewMeterProviderErrors(t *testing.T) {
+	if _, err := NewMeterProvider("", "localhost:4318"); err == nil {
+		t.Error("Expected an error for an empty service name")
+	}
+	if _, err := NewMeterProvider("test-service", ""); err == nil {
+		t.Error("Expected an error for an OTLP exporter without an endpoint")
+	}
+	if _, err := NewMeterProviderWithOptions("test-service", "", MeterOptions{Exporter: "statsd"}); err == nil {
+		t.Error("Expected an error for an unknown exporter")
+	}
+}
@@ -28,6 +28,9 @@ package observability
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 	"time"
 
 	"go.opentelemetry.io/otel"
@@ -35,19 +38,38 @@ import (
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 // Version represents the current package version following semantic versioning.
 const Version = "1.0.0"
 
+// TraceExporter selects where a TracerProvider sends its spans.
+type TraceExporter string
+
+const (
+	// TraceExporterOTLP sends spans to an OpenTelemetry collector over OTLP/HTTP.
+	// Jaeger receives OTLP directly, on port 4318 by default.
+	TraceExporterOTLP TraceExporter = "otlp"
+
+	// TraceExporterStdout writes each span as JSON as it ends, for local development
+	// without a collector.
+	TraceExporterStdout TraceExporter = "stdout"
+
+	// TraceExporterNone records nothing. Spans are still started and ended, and trace
+	// context is still propagated, but at no cost.
+	TraceExporterNone TraceExporter = "none"
+)
+
 // TracerProvider wraps the OpenTelemetry TracerProvider with additional functionality.
 type TracerProvider struct {
-	provider *sdktrace.TracerProvider
+	provider *sdktrace.TracerProvider // nil with TraceExporterNone
 	tracer   trace.Tracer
 }
 
@@ -66,6 +88,14 @@ type TracerOptions struct {
 
 	// AdditionalAttributes are additional resource attributes to include with all spans.
 	AdditionalAttributes []attribute.KeyValue
+
+	// Exporter selects where spans are sent.
+	// Default: TraceExporterOTLP
+	Exporter TraceExporter
+
+	// Writer is where TraceExporterStdout writes spans.
+	// Default: os.Stdout
+	Writer io.Writer
 }
 
 // DefaultTracerOptions returns the default tracer options.
@@ -73,6 +103,8 @@ func DefaultTracerOptions() TracerOptions {
 	return TracerOptions{
 		SamplingRatio:  1.0,
 		ServiceVersion: "unknown",
+		Exporter:       TraceExporterOTLP,
+		Writer:         os.Stdout,
 	}
 }
 
@@ -82,45 +114,56 @@ func NewTracerProvider(serviceName, endpoint string) (*TracerProvider, error) {
 	return NewTracerProviderWithOptions(serviceName, endpoint, DefaultTracerOptions())
 }
 
-// NewTracerProviderWithOptions creates a new TracerProvider with custom options.
+// NewTracerProviderWithOptions creates a new TracerProvider with custom options. The
+// endpoint is only used by the OTLP exporter, and may be empty for the others, so the
+// same code initializes tracing in every environment.
 func NewTracerProviderWithOptions(serviceName, endpoint string, options TracerOptions) (*TracerProvider, error) {
-	if serviceName == "" {
-		return nil, fmt.Errorf("service name cannot be empty")
+	if options.Exporter == "" {
+		options.Exporter = TraceExporterOTLP
 	}
-
-	if endpoint == "" {
-		return nil, fmt.Errorf("endpoint cannot be empty")
+	if options.Writer == nil {
+		options.Writer = os.Stdout
 	}
 
-	// Configure the OTLP exporter
-	client := otlptracehttp.NewClient(
-		otlptracehttp.WithEndpoint(endpoint),
-		otlptracehttp.WithInsecure(), // For development; use TLS in production
-	)
-
-	exporter, err := otlptrace.New(context.Background(), client)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
-	}
-
-	// Create resource attributes
-	attrs := []attribute.KeyValue{
-		semconv.ServiceNameKey.String(serviceName),
-		semconv.ServiceVersionKey.String(options.ServiceVersion),
+	if serviceName == "" {
+		return nil, fmt.Errorf("service name cannot be empty")
 	}
 
-	if options.ServiceNamespace != "" {
-		attrs = append(attrs, semconv.ServiceNamespaceKey.String(options.ServiceNamespace))
+	// Configure the exporter
+	var processor sdktrace.TracerProviderOption
+	switch options.Exporter {
+	case TraceExporterOTLP:
+		if endpoint == "" {
+			return nil, fmt.Errorf("endpoint cannot be empty")
+		}
+		client := otlptracehttp.NewClient(otlpTraceEndpointOptions(endpoint)...)
+		exporter, err := otlptrace.New(context.Background(), client)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+		}
+		processor = sdktrace.WithBatcher(exporter)
+
+	case TraceExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(options.Writer))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create stdout trace exporter: %w", err)
+		}
+		// Write spans as they end rather than in batches, so they show up immediately
+		processor = sdktrace.WithSyncer(exporter)
+
+	case TraceExporterNone:
+		provider := noop.NewTracerProvider()
+		setGlobalTracing(provider)
+		return &TracerProvider{
+			tracer: provider.Tracer(serviceName, trace.WithInstrumentationVersion(Version)),
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown trace exporter: %s", options.Exporter)
 	}
 
-	// Add additional attributes
-	attrs = append(attrs, options.AdditionalAttributes...)
-
 	// Create a resource
-	res := resource.NewWithAttributes(
-		semconv.SchemaURL,
-		attrs...,
-	)
+	res := newResource(serviceName, options.ServiceNamespace, options.ServiceVersion, options.AdditionalAttributes)
 
 	// Configure the trace provider
 	samplingRatio := options.SamplingRatio
@@ -130,16 +173,11 @@ func NewTracerProviderWithOptions(serviceName, endpoint string, options TracerOp
 
 	tp := sdktrace.NewTracerProvider(
 		sdktrace.WithSampler(sdktrace.TraceIDRatioBased(samplingRatio)),
-		sdktrace.WithBatcher(exporter),
+		processor,
 		sdktrace.WithResource(res),
 	)
 
-	// Set the global trace provider and propagator
-	otel.SetTracerProvider(tp)
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
-		propagation.TraceContext{},
-		propagation.Baggage{},
-	))
+	setGlobalTracing(tp)
 
 	// Create a tracer
 	tracer := tp.Tracer(serviceName, trace.WithInstrumentationVersion(Version))
@@ -150,6 +188,48 @@ func NewTracerProviderWithOptions(serviceName, endpoint string, options TracerOp
 	}, nil
 }
 
+// setGlobalTracing sets the global trace provider, and the propagator of W3C trace
+// context and baggage.
+func setGlobalTracing(tp trace.TracerProvider) {
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+}
+
+// otlpTraceEndpointOptions returns the options that point an OTLP/HTTP trace client
+// at endpoint, which is either a URL, or a host and port reached over plain HTTP.
+func otlpTraceEndpointOptions(endpoint string) []otlptracehttp.Option {
+	if strings.Contains(endpoint, "://") {
+		return []otlptracehttp.Option{otlptracehttp.WithEndpointURL(endpoint)}
+	}
+	return []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(endpoint),
+		otlptracehttp.WithInsecure(), // For development; use TLS in production
+	}
+}
+
+// newResource creates the resource describing the service to the collector.
+func newResource(serviceName, serviceNamespace, serviceVersion string, additional []attribute.KeyValue) *resource.Resource {
+	attrs := []attribute.KeyValue{
+		semconv.ServiceNameKey.String(serviceName),
+		semconv.ServiceVersionKey.String(serviceVersion),
+	}
+
+	if serviceNamespace != "" {
+		attrs = append(attrs, semconv.ServiceNamespaceKey.String(serviceNamespace))
+	}
+
+	// Add additional attributes
+	attrs = append(attrs, additional...)
+
+	return resource.NewWithAttributes(
+		semconv.SchemaURL,
+		attrs...,
+	)
+}
+
 // Tracer returns the tracer instance.
 func (tp *TracerProvider) Tracer() trace.Tracer {
 	return tp.tracer
@@ -157,6 +237,9 @@ func (tp *TracerProvider) Tracer() trace.Tracer {
 
 // Shutdown shuts down the tracer provider, flushing any remaining spans.
 func (tp *TracerProvider) Shutdown(ctx context.Context) error {
+	if tp.provider == nil {
+		return nil
+	}
 	return tp.provider.Shutdown(ctx)
 }
 
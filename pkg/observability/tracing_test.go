@@ -1,7 +1,16 @@
 package observability
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // TestDefaultTracerOptions tests the DefaultTracerOptions function
@@ -23,6 +32,10 @@ func TestDefaultTracerOptions(t *testing.T) {
 	if len(options.AdditionalAttributes) != 0 {
 		t.Errorf("Expected AdditionalAttributes to be empty, got %d attributes", len(options.AdditionalAttributes))
 	}
+
+	if options.Exporter != TraceExporterOTLP {
+		t.Errorf("Expected Exporter to be 'otlp', got '%s'", options.Exporter)
+	}
 }
 
 // TestVersion tests that the Version constant is set
@@ -36,3 +49,160 @@ func TestVersion(t *testing.T) {
 		t.Errorf("Expected Version to follow semantic versioning (x.y.z), got %s", Version)
 	}
 }
+
+// TestTracerProviderStdout tests that the stdout exporter writes spans as they end
+func TestTracerProviderStdout(t *testing.T) {
+	var buf bytes.Buffer
+	tp, err := NewTracerProviderWithOptions("test-service", "", TracerOptions{
+		Exporter: TraceExporterStdout,
+		Writer:   &buf,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer tp.Shutdown(context.Background())
+
+	_, span := tp.StartSpan(context.Background(), "my-operation")
+	span.End()
+
+	if !strings.Contains(buf.String(), `"Name":"my-operation"`) {
+		t.Errorf("Expected the span to be written, got %s", buf.String())
+	}
+}
+
+// TestTracerProviderNone tests that the no-op exporter records nothing
+func TestTracerProviderNone(t *testing.T) {
+	tp, err := NewTracerProviderWithOptions("test-service", "", TracerOptions{Exporter: TraceExporterNone})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	_, span := tp.StartSpan(context.Background(), "my-operation")
+	if span.IsRecording() {
+		t.Error("Expected the span not to be recorded")
+	}
+	span.End()
+
+	if err := tp.Shutdown(context.Background()); err != nil {
+		t.Errorf("Expected no error shutting down, got %v", err)
+	}
+}
+
+// TestNewTracerProviderErrors tests that invalid configurations are rejected
+func TestNewTracerProviderErrors(t *testing.T) {
+	if _, err := NewTracerProvider("test-service", ""); err == nil {
+		t.Error("Expected an error for an OTLP exporter without an endpoint")
+	}
+	if _, err := NewTracerProviderWithOptions("test-service", "", TracerOptions{Exporter: "zipkin"}); err == nil {
+		t.Error("Expected an error for an unknown exporter")
+	}
+}
+
+// TestTracerProviderOTLP tests that spans are sent to the collector
+func TestTracerProviderOTLP(t *testing.T) {
+	requests := make(chan string, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		select {
+		case requests <- r.URL.Path:
+		default:
+		}
+	}))
+	defer collector.Close()
+
+	tp, err := NewTracerProvider("test-service", collector.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	_, span := tp.StartSpan(context.Background(), "my-operation")
+	span.End()
+
+	if err := tp.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Expected no error shutting down, got %v", err)
+	}
+	select {
+	case path := <-requests:
+		if path != "/v1/traces" {
+			t.Errorf("Expected spans to be sent to /v1/traces, got %s", path)
+		}
+	default:
+		t.Error("Expected the spans to be sent on shutdown")
+	}
+}
+
+// TestTracerProviderResource tests that spans describe the service
+func TestTracerProviderResource(t *testing.T) {
+	var buf bytes.Buffer
+	tp, err := NewTracerProviderWithOptions("test-service", "", TracerOptions{
+		Exporter:             TraceExporterStdout,
+		Writer:               &buf,
+		ServiceNamespace:     "gollama",
+		ServiceVersion:       "1.2.3",
+		AdditionalAttributes: []attribute.KeyValue{attribute.String("deployment.environment", "test")},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer tp.Shutdown(context.Background())
+
+	_, span := tp.StartSpan(context.Background(), "my-operation")
+	span.End()
+
+	var exported struct {
+		Resource []struct {
+			Key   string
+			Value struct{ Value any }
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &exported); err != nil {
+		t.Fatalf("Failed to decode span: %v", err)
+	}
+	resource := make(map[string]any)
+	for _, attr := range exported.Resource {
+		resource[attr.Key] = attr.Value.Value
+	}
+	for key, expected := range map[string]string{
+		"service.name":           "test-service",
+		"service.namespace":      "gollama",
+		"service.version":        "1.2.3",
+		"deployment.environment": "test",
+	} {
+		if resource[key] != expected {
+			t.Errorf("Expected resource attribute %s to be %s, got %v", key, expected, resource[key])
+		}
+	}
+}
+
+// TestTracerProviderSamplingRatio tests that spans are sampled at the configured ratio
+func TestTracerProviderSamplingRatio(t *testing.T) {
+	for _, c := range []struct {
+		ratio   float64
+		sampled bool
+	}{
+		{0, true}, // Out of range, so every trace is sampled
+		{1, true},
+		{0.000000001, false},
+	} {
+		options := DefaultTracerOptions()
+		options.Exporter = TraceExporterStdout
+		options.Writer = io.Discard
+		options.SamplingRatio = c.ratio
+		tp, err := NewTracerProviderWithOptions("test-service", "", options)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		sampled := 0
+		for i := 0; i < 10; i++ {
+			_, span := tp.StartSpan(context.Background(), "my-operation")
+			if span.SpanContext().IsSampled() {
+				sampled++
+			}
+			span.End()
+		}
+		tp.Shutdown(context.Background())
+		if (sampled == 10) != c.sampled || (sampled == 0) == c.sampled {
+			t.Errorf("Expected sampling ratio %v to sample all traces: %v, got %d of 10", c.ratio, c.sampled, sampled)
+		}
+	}
+}
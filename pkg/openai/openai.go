@@ -0,0 +1,349 @@
+// Package openai provides an OpenAI-compatible client that runs requests against Ollama.
+//
+// The request and response types mirror the OpenAI Chat Completions and Embeddings APIs,
+// so code written against an OpenAI SDK can switch to locally managed models by swapping
+// the client. Requests are translated to the Ollama API and sent through the gollama
+// Ollama client.
+//
+// Example usage:
+//
+//	client := openai.NewClient(openai.DefaultOptions())
+//
+//	resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+//		Model: "llama3",
+//		Messages: []openai.ChatCompletionMessage{
+//			{Role: openai.RoleSystem, Content: "You are a helpful assistant."},
+//			{Role: openai.RoleUser, Content: "Hello!"},
+//		},
+//	})
+//	fmt.Println(resp.Choices[0].Message.Content)
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/h2co32/gollama/internal/models"
+)
+
+// Version represents the current package version following semantic versioning.
+const Version = "1.0.0"
+
+// Chat message roles.
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Finish reasons reported on completion choices.
+const (
+	FinishReasonStop   = "stop"
+	FinishReasonLength = "length"
+)
+
+// Options configures the Client.
+type Options struct {
+	// BaseURL is the address of the Ollama server.
+	// Default: "http://localhost:11434"
+	BaseURL string
+
+	// HTTPClient is the client used for requests to the Ollama server.
+	// Optional.
+	HTTPClient *http.Client
+
+	// ModelDir is the directory used by the local model manager.
+	// Default: "./models"
+	ModelDir string
+}
+
+// DefaultOptions returns the default client options.
+func DefaultOptions() Options {
+	return Options{
+		BaseURL:  models.DefaultOllamaURL,
+		ModelDir: "./models",
+	}
+}
+
+// Client exposes OpenAI-style methods backed by an Ollama server.
+type Client struct {
+	ollama *models.OllamaClient
+}
+
+// NewClient creates a new Client with the given options.
+func NewClient(options Options) *Client {
+	return &Client{
+		ollama: models.NewOllamaClientWithOptions(models.OllamaClientOptions{
+			BaseURL:    options.BaseURL,
+			HTTPClient: options.HTTPClient,
+			ModelDir:   options.ModelDir,
+		}),
+	}
+}
+
+// ChatCompletionMessage is a message in a chat completion conversation.
+type ChatCompletionMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	Name    string `json:"name,omitempty"`
+}
+
+// ResponseFormat selects the output format of a chat completion.
+type ResponseFormat struct {
+	Type string `json:"type"` // "text" or "json_object"
+}
+
+// ChatCompletionRequest is an OpenAI-style chat completion request.
+type ChatCompletionRequest struct {
+	Model            string                  `json:"model"`
+	Messages         []ChatCompletionMessage `json:"messages"`
+	Temperature      *float64                `json:"temperature,omitempty"`
+	TopP             *float64                `json:"top_p,omitempty"`
+	MaxTokens        int                     `json:"max_tokens,omitempty"`
+	Stop             []string                `json:"stop,omitempty"`
+	Seed             *int                    `json:"seed,omitempty"`
+	PresencePenalty  *float64                `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64                `json:"frequency_penalty,omitempty"`
+	ResponseFormat   *ResponseFormat         `json:"response_format,omitempty"`
+	Stream           bool                    `json:"stream,omitempty"`
+	StreamOptions    *StreamOptions          `json:"stream_options,omitempty"`
+}
+
+// StreamOptions configures a streamed chat completion.
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"` // Send token usage on a final chunk
+}
+
+// Usage reports token counts for a request.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ChatCompletionChoice is one generated reply.
+type ChatCompletionChoice struct {
+	Index        int                   `json:"index"`
+	Message      ChatCompletionMessage `json:"message"`
+	FinishReason string                `json:"finish_reason"`
+}
+
+// ChatCompletionResponse is an OpenAI-style chat completion response.
+type ChatCompletionResponse struct {
+	ID      string                 `json:"id"`
+	Object  string                 `json:"object"`
+	Created int64                  `json:"created"`
+	Model   string                 `json:"model"`
+	Choices []ChatCompletionChoice `json:"choices"`
+	Usage   Usage                  `json:"usage"`
+}
+
+// ChatCompletionDelta is the incremental content of a streamed choice.
+type ChatCompletionDelta struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// ChatCompletionStreamChoice is one choice in a streamed chunk.
+type ChatCompletionStreamChoice struct {
+	Index        int                 `json:"index"`
+	Delta        ChatCompletionDelta `json:"delta"`
+	FinishReason string              `json:"finish_reason,omitempty"`
+}
+
+// ChatCompletionChunk is one chunk of a streamed chat completion.
+type ChatCompletionChunk struct {
+	ID      string                       `json:"id"`
+	Object  string                       `json:"object"`
+	Created int64                        `json:"created"`
+	Model   string                       `json:"model"`
+	Choices []ChatCompletionStreamChoice `json:"choices"`
+	Usage   *Usage                       `json:"usage,omitempty"` // Set on the final chunk
+}
+
+// EmbeddingRequest is an OpenAI-style embedding request.
+// Input holds one or more texts to embed.
+type EmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// Embedding is the embedding vector of one input.
+type Embedding struct {
+	Object    string    `json:"object"`
+	Embedding []float64 `json:"embedding"`
+	Index     int       `json:"index"`
+}
+
+// EmbeddingResponse is an OpenAI-style embedding response.
+type EmbeddingResponse struct {
+	Object string      `json:"object"`
+	Data   []Embedding `json:"data"`
+	Model  string      `json:"model"`
+	Usage  Usage       `json:"usage"`
+}
+
+// CreateChatCompletion generates a reply to a conversation.
+func (c *Client) CreateChatCompletion(ctx context.Context, req ChatCompletionRequest) (*ChatCompletionResponse, error) {
+	chatReq := toChatRequest(req, false)
+
+	var content strings.Builder
+	var final models.ChatResponse
+	err := c.ollama.Chat(ctx, chatReq, func(res models.ChatResponse) error {
+		content.WriteString(res.Message.Content)
+		if res.Done {
+			final = res
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("chat completion failed: %w", err)
+	}
+
+	return &ChatCompletionResponse{
+		ID:      newID("chatcmpl"),
+		Object:  "chat.completion",
+		Created: createdAt(final.CreatedAt),
+		Model:   req.Model,
+		Choices: []ChatCompletionChoice{{
+			Index:        0,
+			Message:      ChatCompletionMessage{Role: RoleAssistant, Content: content.String()},
+			FinishReason: finishReason(final.DoneReason),
+		}},
+		Usage: usage(final.Metrics),
+	}, nil
+}
+
+// CreateChatCompletionStream generates a reply to a conversation and calls fn for every chunk.
+// Returning an error from fn stops the stream and is returned by CreateChatCompletionStream.
+func (c *Client) CreateChatCompletionStream(ctx context.Context, req ChatCompletionRequest, fn func(ChatCompletionChunk) error) error {
+	chatReq := toChatRequest(req, true)
+	id := newID("chatcmpl")
+	first := true
+
+	err := c.ollama.Chat(ctx, chatReq, func(res models.ChatResponse) error {
+		chunk := ChatCompletionChunk{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: createdAt(res.CreatedAt),
+			Model:   req.Model,
+			Choices: []ChatCompletionStreamChoice{{
+				Index: 0,
+				Delta: ChatCompletionDelta{Content: res.Message.Content},
+			}},
+		}
+		if first {
+			chunk.Choices[0].Delta.Role = RoleAssistant
+			first = false
+		}
+		if res.Done {
+			chunk.Choices[0].FinishReason = finishReason(res.DoneReason)
+			u := usage(res.Metrics)
+			chunk.Usage = &u
+		}
+		return fn(chunk)
+	})
+	if err != nil {
+		return fmt.Errorf("chat completion stream failed: %w", err)
+	}
+	return nil
+}
+
+// CreateEmbeddings generates an embedding vector for each input.
+func (c *Client) CreateEmbeddings(ctx context.Context, req EmbeddingRequest) (*EmbeddingResponse, error) {
+	res := &EmbeddingResponse{
+		Object: "list",
+		Model:  req.Model,
+		Data:   make([]Embedding, 0, len(req.Input)),
+	}
+
+	for i, input := range req.Input {
+		emb, err := c.ollama.Embeddings(ctx, models.EmbeddingRequest{Model: req.Model, Prompt: input})
+		if err != nil {
+			return nil, fmt.Errorf("embedding input %d failed: %w", i, err)
+		}
+		res.Data = append(res.Data, Embedding{
+			Object:    "embedding",
+			Embedding: emb.Embedding,
+			Index:     i,
+		})
+	}
+	return res, nil
+}
+
+// toChatRequest translates an OpenAI-style request into an Ollama chat request.
+func toChatRequest(req ChatCompletionRequest, stream bool) models.ChatRequest {
+	messages := make([]models.Message, len(req.Messages))
+	for i, m := range req.Messages {
+		messages[i] = models.Message{Role: m.Role, Content: m.Content}
+	}
+
+	options := make(map[string]interface{})
+	if req.Temperature != nil {
+		options["temperature"] = *req.Temperature
+	}
+	if req.TopP != nil {
+		options["top_p"] = *req.TopP
+	}
+	if req.MaxTokens > 0 {
+		options["num_predict"] = req.MaxTokens
+	}
+	if len(req.Stop) > 0 {
+		options["stop"] = req.Stop
+	}
+	if req.Seed != nil {
+		options["seed"] = *req.Seed
+	}
+	if req.PresencePenalty != nil {
+		options["presence_penalty"] = *req.PresencePenalty
+	}
+	if req.FrequencyPenalty != nil {
+		options["frequency_penalty"] = *req.FrequencyPenalty
+	}
+
+	chatReq := models.ChatRequest{
+		Model:    req.Model,
+		Messages: messages,
+		Stream:   &stream,
+	}
+	if len(options) > 0 {
+		chatReq.Options = options
+	}
+	if req.ResponseFormat != nil && req.ResponseFormat.Type == "json_object" {
+		chatReq.Format = "json"
+	}
+	return chatReq
+}
+
+// finishReason maps an Ollama done reason to an OpenAI finish reason.
+func finishReason(doneReason string) string {
+	if doneReason == "length" {
+		return FinishReasonLength
+	}
+	return FinishReasonStop
+}
+
+// usage converts Ollama generation metrics to token usage.
+func usage(m models.Metrics) Usage {
+	return Usage{
+		PromptTokens:     m.PromptEvalCount,
+		CompletionTokens: m.EvalCount,
+		TotalTokens:      m.PromptEvalCount + m.EvalCount,
+	}
+}
+
+// createdAt returns t as a Unix timestamp, using the current time if t is zero.
+func createdAt(t time.Time) int64 {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return t.Unix()
+}
+
+// newID returns a unique identifier with the given prefix.
+func newID(prefix string) string {
+	return fmt.Sprintf("%s-%d", prefix, time.Now().UnixNano())
+}
@@ -0,0 +1,152 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestClient creates a client pointed at a fake Ollama server using handler.
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return NewClient(Options{BaseURL: server.URL, ModelDir: t.TempDir()})
+}
+
+func TestCreateChatCompletion(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("Expected path /api/chat, got %s", r.URL.Path)
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		if body["stream"] != false {
+			t.Errorf("Expected stream false, got %v", body["stream"])
+		}
+		if body["format"] != "json" {
+			t.Errorf("Expected format 'json', got %v", body["format"])
+		}
+		options, _ := body["options"].(map[string]interface{})
+		if options["temperature"] != 0.2 || options["num_predict"] != float64(64) {
+			t.Errorf("Unexpected options: %v", options)
+		}
+		fmt.Fprint(w, `{"model":"llama3","message":{"role":"assistant","content":"Hi!"},"done":true,"done_reason":"stop","prompt_eval_count":12,"eval_count":3}`)
+	})
+
+	temperature := 0.2
+	resp, err := client.CreateChatCompletion(context.Background(), ChatCompletionRequest{
+		Model:          "llama3",
+		Messages:       []ChatCompletionMessage{{Role: RoleUser, Content: "Hello"}},
+		Temperature:    &temperature,
+		MaxTokens:      64,
+		ResponseFormat: &ResponseFormat{Type: "json_object"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create chat completion: %v", err)
+	}
+
+	if len(resp.Choices) != 1 {
+		t.Fatalf("Expected 1 choice, got %d", len(resp.Choices))
+	}
+	choice := resp.Choices[0]
+	if choice.Message.Role != RoleAssistant || choice.Message.Content != "Hi!" {
+		t.Errorf("Unexpected message: %+v", choice.Message)
+	}
+	if choice.FinishReason != FinishReasonStop {
+		t.Errorf("Expected finish reason %q, got %q", FinishReasonStop, choice.FinishReason)
+	}
+	if resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 3 || resp.Usage.TotalTokens != 15 {
+		t.Errorf("Unexpected usage: %+v", resp.Usage)
+	}
+	if resp.Object != "chat.completion" {
+		t.Errorf("Expected object 'chat.completion', got %q", resp.Object)
+	}
+}
+
+func TestCreateChatCompletionStream(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"Hel"},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"lo"},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true,"done_reason":"length","prompt_eval_count":5,"eval_count":2}`)
+	})
+
+	var chunks []ChatCompletionChunk
+	var content strings.Builder
+	err := client.CreateChatCompletionStream(context.Background(), ChatCompletionRequest{
+		Model:    "llama3",
+		Messages: []ChatCompletionMessage{{Role: RoleUser, Content: "Hello"}},
+	}, func(chunk ChatCompletionChunk) error {
+		chunks = append(chunks, chunk)
+		content.WriteString(chunk.Choices[0].Delta.Content)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to stream chat completion: %v", err)
+	}
+
+	if len(chunks) != 3 {
+		t.Fatalf("Expected 3 chunks, got %d", len(chunks))
+	}
+	if content.String() != "Hello" {
+		t.Errorf("Expected content 'Hello', got %q", content.String())
+	}
+	if chunks[0].Choices[0].Delta.Role != RoleAssistant || chunks[1].Choices[0].Delta.Role != "" {
+		t.Errorf("Expected role only on the first chunk")
+	}
+	last := chunks[2]
+	if last.Choices[0].FinishReason != FinishReasonLength {
+		t.Errorf("Expected finish reason %q, got %q", FinishReasonLength, last.Choices[0].FinishReason)
+	}
+	if last.Usage == nil || last.Usage.TotalTokens != 7 {
+		t.Errorf("Expected usage on the final chunk, got %+v", last.Usage)
+	}
+	if chunks[0].ID != last.ID {
+		t.Errorf("Expected all chunks to share an ID")
+	}
+}
+
+func TestCreateEmbeddings(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		if body["prompt"] == "fail" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"bad input"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"embedding":[%d]}`, len(body["prompt"].(string)))
+	})
+
+	resp, err := client.CreateEmbeddings(context.Background(), EmbeddingRequest{
+		Model: "nomic-embed-text",
+		Input: []string{"a", "bcd"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create embeddings: %v", err)
+	}
+	if len(resp.Data) != 2 {
+		t.Fatalf("Expected 2 embeddings, got %d", len(resp.Data))
+	}
+	for i, want := range []float64{1, 3} {
+		if resp.Data[i].Index != i || resp.Data[i].Embedding[0] != want {
+			t.Errorf("Unexpected embedding %d: %+v", i, resp.Data[i])
+		}
+	}
+
+	_, err = client.CreateEmbeddings(context.Background(), EmbeddingRequest{
+		Model: "nomic-embed-text",
+		Input: []string{"ok", "fail"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "bad input") {
+		t.Errorf("Expected error containing 'bad input', got %v", err)
+	}
+}
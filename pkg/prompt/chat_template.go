@@ -0,0 +1,167 @@
+package prompt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ChatTemplate formats chat messages into the raw prompt layout of a model family.
+// Each role format contains a single %s that is replaced with the message content.
+type ChatTemplate struct {
+	// Name identifies the template, e.g. "llama3" or "chatml".
+	Name string
+
+	// Prefix is written once at the start of the prompt, e.g. a beginning-of-sequence token.
+	Prefix string
+
+	// System, User, and Assistant format messages of the corresponding role.
+	System    string
+	User      string
+	Assistant string
+
+	// SystemInUser is set for families without a system role. The formatted system
+	// prompt is then prepended to the content of the first user message.
+	SystemInUser bool
+
+	// Generation is appended after the last message to cue the assistant's reply.
+	Generation string
+}
+
+// Built-in chat templates for common model families.
+var (
+	// ChatML is used by Qwen, Phi-3, and many fine-tuned models. It is the default.
+	ChatML = ChatTemplate{
+		Name:       "chatml",
+		System:     "<|im_start|>system\n%s<|im_end|>\n",
+		User:       "<|im_start|>user\n%s<|im_end|>\n",
+		Assistant:  "<|im_start|>assistant\n%s<|im_end|>\n",
+		Generation: "<|im_start|>assistant\n",
+	}
+
+	// Llama3 is the Llama 3 instruct format.
+	Llama3 = ChatTemplate{
+		Name:       "llama3",
+		Prefix:     "<|begin_of_text|>",
+		System:     "<|start_header_id|>system<|end_header_id|>\n\n%s<|eot_id|>",
+		User:       "<|start_header_id|>user<|end_header_id|>\n\n%s<|eot_id|>",
+		Assistant:  "<|start_header_id|>assistant<|end_header_id|>\n\n%s<|eot_id|>",
+		Generation: "<|start_header_id|>assistant<|end_header_id|>\n\n",
+	}
+
+	// Llama2 is the Llama 2 chat format.
+	Llama2 = ChatTemplate{
+		Name:         "llama2",
+		System:       "<<SYS>>\n%s\n<</SYS>>\n\n",
+		User:         "<s>[INST] %s [/INST]",
+		Assistant:    " %s </s>",
+		SystemInUser: true,
+	}
+
+	// Mistral is the Mistral and Mixtral instruct format.
+	Mistral = ChatTemplate{
+		Name:         "mistral",
+		Prefix:       "<s>",
+		System:       "%s\n\n",
+		User:         "[INST] %s [/INST]",
+		Assistant:    "%s</s>",
+		SystemInUser: true,
+	}
+
+	// Gemma is the Gemma instruct format.
+	Gemma = ChatTemplate{
+		Name:         "gemma",
+		Prefix:       "<bos>",
+		System:       "%s\n\n",
+		User:         "<start_of_turn>user\n%s<end_of_turn>\n",
+		Assistant:    "<start_of_turn>model\n%s<end_of_turn>\n",
+		SystemInUser: true,
+		Generation:   "<start_of_turn>model\n",
+	}
+)
+
+// chatTemplates maps model family prefixes to chat templates.
+var (
+	chatTemplates = map[string]ChatTemplate{
+		"llama3":  Llama3,
+		"llama2":  Llama2,
+		"mistral": Mistral,
+		"mixtral": Mistral,
+		"gemma":   Gemma,
+		"qwen":    ChatML,
+		"phi3":    ChatML,
+	}
+	chatTemplatesMu sync.RWMutex
+)
+
+// RegisterChatTemplate associates models whose name starts with family with tmpl,
+// replacing any existing association. Family matching is case-insensitive.
+func RegisterChatTemplate(family string, tmpl ChatTemplate) {
+	chatTemplatesMu.Lock()
+	defer chatTemplatesMu.Unlock()
+	chatTemplates[strings.ToLower(family)] = tmpl
+}
+
+// ChatTemplateFor returns the chat template for a model name such as "llama3:8b" or
+// "library/mistral:7b-instruct". The longest registered family prefix of the base name
+// wins; models of unknown families use ChatML.
+func ChatTemplateFor(model string) ChatTemplate {
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.Index(name, ":"); i >= 0 {
+		name = name[:i]
+	}
+
+	chatTemplatesMu.RLock()
+	defer chatTemplatesMu.RUnlock()
+
+	families := make([]string, 0, len(chatTemplates))
+	for family := range chatTemplates {
+		families = append(families, family)
+	}
+	sort.Slice(families, func(i, j int) bool {
+		return len(families[i]) > len(families[j])
+	})
+
+	for _, family := range families {
+		if strings.HasPrefix(name, family) {
+			return chatTemplates[family]
+		}
+	}
+	return ChatML
+}
+
+// Format renders messages into a raw prompt, ending with the generation cue.
+// Messages with unknown roles are formatted as user messages.
+func (ct ChatTemplate) Format(messages []Message) string {
+	var b strings.Builder
+	b.WriteString(ct.Prefix)
+
+	pendingSystem := ""
+	for _, msg := range messages {
+		switch msg.Role {
+		case RoleSystem:
+			if ct.SystemInUser {
+				pendingSystem += fmt.Sprintf(ct.System, msg.Content)
+				continue
+			}
+			fmt.Fprintf(&b, ct.System, msg.Content)
+		case RoleAssistant:
+			fmt.Fprintf(&b, ct.Assistant, msg.Content)
+		default:
+			fmt.Fprintf(&b, ct.User, pendingSystem+msg.Content)
+			pendingSystem = ""
+		}
+	}
+
+	// A system prompt with no following user message still needs to reach the model
+	if pendingSystem != "" {
+		fmt.Fprintf(&b, ct.User, strings.TrimRight(pendingSystem, "\n"))
+	}
+
+	b.WriteString(ct.Generation)
+	return b.String()
+}
@@ -0,0 +1,243 @@
+// Package prompt provides named prompt templates with variable interpolation,
+// few-shot example injection, and per-model chat formatting.
+//
+// Templates use the text/template syntax. Rendering a template produces either a
+// plain string or a list of chat messages, which a ChatTemplate formats into the
+// raw prompt layout a model family expects.
+//
+// Example usage:
+//
+//	registry := prompt.NewRegistry()
+//	registry.MustRegister(prompt.Template{
+//		Name:   "summarize",
+//		System: "You are a concise assistant.",
+//		Text:   "Summarize the following text in {{.Words}} words:\n\n{{.Text}}",
+//		Examples: []prompt.Example{
+//			{Input: "Summarize: The cat sat on the mat all day.", Output: "A cat lounged."},
+//		},
+//	})
+//
+//	// Render chat messages for an API that accepts them
+//	messages, err := registry.Messages("summarize", map[string]interface{}{
+//		"Words": 20,
+//		"Text":  article,
+//	})
+//
+//	// Or format a raw prompt for a specific model family
+//	raw, err := registry.Format("summarize", "llama3:8b", vars)
+package prompt
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+	"text/template"
+)
+
+// Version represents the current package version following semantic versioning.
+const Version = "1.0.0"
+
+// Chat message roles.
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message is a single chat message.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Example is a few-shot example injected before the rendered prompt as a
+// user/assistant exchange.
+type Example struct {
+	Input  string
+	Output string
+}
+
+// Template is a named prompt template.
+type Template struct {
+	// Name identifies the template in a Registry.
+	Name string
+
+	// System is an optional system prompt. It may reference template variables.
+	System string
+
+	// Text is the user prompt in text/template syntax.
+	Text string
+
+	// Examples are few-shot examples placed between the system prompt and the user prompt.
+	Examples []Example
+}
+
+// compiledTemplate holds a Template with its parsed text.
+type compiledTemplate struct {
+	Template
+	system *template.Template
+	text   *template.Template
+}
+
+// Registry stores named templates. It is safe for concurrent use.
+type Registry struct {
+	templates map[string]*compiledTemplate
+	mu        sync.RWMutex
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		templates: make(map[string]*compiledTemplate),
+	}
+}
+
+// Register parses tmpl and adds it to the registry, replacing any template with the same name.
+func (r *Registry) Register(tmpl Template) error {
+	if tmpl.Name == "" {
+		return fmt.Errorf("template name cannot be empty")
+	}
+
+	compiled := &compiledTemplate{Template: tmpl}
+	var err error
+	compiled.text, err = parse(tmpl.Name, tmpl.Text)
+	if err != nil {
+		return err
+	}
+	if tmpl.System != "" {
+		compiled.system, err = parse(tmpl.Name+".system", tmpl.System)
+		if err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.templates[tmpl.Name] = compiled
+	return nil
+}
+
+// MustRegister is like Register but panics if the template cannot be parsed.
+// It simplifies registering templates at package initialization.
+func (r *Registry) MustRegister(tmpl Template) {
+	if err := r.Register(tmpl); err != nil {
+		panic(err)
+	}
+}
+
+// Get returns the template registered under name.
+func (r *Registry) Get(name string) (Template, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	compiled, ok := r.templates[name]
+	if !ok {
+		return Template{}, false
+	}
+	return compiled.Template, true
+}
+
+// Names returns the names of all registered templates in sorted order.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.templates))
+	for name := range r.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render interpolates vars into the text of a template and returns the user prompt.
+// The system prompt and examples are not included; use Messages or Format for those.
+func (r *Registry) Render(name string, vars map[string]interface{}) (string, error) {
+	compiled, err := r.lookup(name)
+	if err != nil {
+		return "", err
+	}
+	return execute(compiled.text, vars)
+}
+
+// Messages renders a template into chat messages: the system prompt, one user and
+// assistant message per few-shot example, and finally the rendered user prompt.
+func (r *Registry) Messages(name string, vars map[string]interface{}) ([]Message, error) {
+	compiled, err := r.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]Message, 0, 2+2*len(compiled.Examples))
+	if compiled.system != nil {
+		system, err := execute(compiled.system, vars)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, Message{Role: RoleSystem, Content: system})
+	}
+	messages = append(messages, ExampleMessages(compiled.Examples)...)
+
+	text, err := execute(compiled.text, vars)
+	if err != nil {
+		return nil, err
+	}
+	messages = append(messages, Message{Role: RoleUser, Content: text})
+	return messages, nil
+}
+
+// Format renders a template into chat messages and formats them with the chat
+// template for model (see ChatTemplateFor), ready to send as a raw prompt.
+func (r *Registry) Format(name, model string, vars map[string]interface{}) (string, error) {
+	messages, err := r.Messages(name, vars)
+	if err != nil {
+		return "", err
+	}
+	return ChatTemplateFor(model).Format(messages), nil
+}
+
+// ExampleMessages converts few-shot examples to alternating user and assistant messages.
+func ExampleMessages(examples []Example) []Message {
+	messages := make([]Message, 0, 2*len(examples))
+	for _, example := range examples {
+		messages = append(messages,
+			Message{Role: RoleUser, Content: example.Input},
+			Message{Role: RoleAssistant, Content: example.Output},
+		)
+	}
+	return messages
+}
+
+// lookup returns the compiled template registered under name.
+func (r *Registry) lookup(name string) (*compiledTemplate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	compiled, ok := r.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("template %s not found", name)
+	}
+	return compiled, nil
+}
+
+// parse compiles template text, failing on references to missing variables at render time.
+func parse(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// execute renders a compiled template with vars.
+func execute(tmpl *template.Template, vars map[string]interface{}) (string, error) {
+	if vars == nil {
+		vars = map[string]interface{}{}
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %w", tmpl.Name(), err)
+	}
+	return buf.String(), nil
+}
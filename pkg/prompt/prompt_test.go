@@ -0,0 +1,133 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistryRender(t *testing.T) {
+	registry := NewRegistry()
+	if err := registry.Register(Template{Name: "greet", Text: "Hello, {{.Name}}!"}); err != nil {
+		t.Fatalf("Failed to register template: %v", err)
+	}
+
+	got, err := registry.Render("greet", map[string]interface{}{"Name": "Ada"})
+	if err != nil {
+		t.Fatalf("Failed to render template: %v", err)
+	}
+	if got != "Hello, Ada!" {
+		t.Errorf("Expected 'Hello, Ada!', got %q", got)
+	}
+
+	if _, err := registry.Render("greet", nil); err == nil {
+		t.Error("Expected error for missing variable, got nil")
+	}
+	if _, err := registry.Render("missing", nil); err == nil {
+		t.Error("Expected error for unknown template, got nil")
+	}
+}
+
+func TestRegistryRegisterErrors(t *testing.T) {
+	registry := NewRegistry()
+
+	if err := registry.Register(Template{Text: "no name"}); err == nil {
+		t.Error("Expected error for empty name, got nil")
+	}
+	if err := registry.Register(Template{Name: "bad", Text: "{{.Unclosed"}); err == nil {
+		t.Error("Expected error for invalid template, got nil")
+	}
+
+	registry.MustRegister(Template{Name: "b", Text: "b"})
+	registry.MustRegister(Template{Name: "a", Text: "a"})
+	if names := registry.Names(); len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Errorf("Expected names [a b], got %v", names)
+	}
+}
+
+func TestRegistryMessages(t *testing.T) {
+	registry := NewRegistry()
+	registry.MustRegister(Template{
+		Name:   "translate",
+		System: "Translate to {{.Lang}}.",
+		Text:   "{{.Text}}",
+		Examples: []Example{
+			{Input: "cat", Output: "chat"},
+			{Input: "dog", Output: "chien"},
+		},
+	})
+
+	messages, err := registry.Messages("translate", map[string]interface{}{"Lang": "French", "Text": "bird"})
+	if err != nil {
+		t.Fatalf("Failed to render messages: %v", err)
+	}
+
+	expected := []Message{
+		{Role: RoleSystem, Content: "Translate to French."},
+		{Role: RoleUser, Content: "cat"},
+		{Role: RoleAssistant, Content: "chat"},
+		{Role: RoleUser, Content: "dog"},
+		{Role: RoleAssistant, Content: "chien"},
+		{Role: RoleUser, Content: "bird"},
+	}
+	if len(messages) != len(expected) {
+		t.Fatalf("Expected %d messages, got %d", len(expected), len(messages))
+	}
+	for i := range expected {
+		if messages[i] != expected[i] {
+			t.Errorf("Expected message %d to be %+v, got %+v", i, expected[i], messages[i])
+		}
+	}
+}
+
+func TestChatTemplateFor(t *testing.T) {
+	tests := map[string]string{
+		"llama3:8b":                 "llama3",
+		"llama3.1:70b-instruct":     "llama3",
+		"library/mistral:7b":        "mistral",
+		"Mixtral:8x7b":              "mistral",
+		"gemma2:9b":                 "gemma",
+		"qwen2.5:7b":                "chatml",
+		"some-unknown-model:latest": "chatml",
+		"registry.local/llama2:13b": "llama2",
+	}
+	for model, want := range tests {
+		if got := ChatTemplateFor(model).Name; got != want {
+			t.Errorf("Expected template %q for %s, got %q", want, model, got)
+		}
+	}
+
+	RegisterChatTemplate("Custom", ChatTemplate{Name: "custom"})
+	if got := ChatTemplateFor("custom-llm:1b").Name; got != "custom" {
+		t.Errorf("Expected registered template 'custom', got %q", got)
+	}
+}
+
+func TestChatTemplateFormat(t *testing.T) {
+	messages := []Message{
+		{Role: RoleSystem, Content: "Be brief."},
+		{Role: RoleUser, Content: "Hi"},
+		{Role: RoleAssistant, Content: "Hello"},
+		{Role: RoleUser, Content: "Bye"},
+	}
+
+	got := ChatML.Format(messages)
+	expected := "<|im_start|>system\nBe brief.<|im_end|>\n" +
+		"<|im_start|>user\nHi<|im_end|>\n" +
+		"<|im_start|>assistant\nHello<|im_end|>\n" +
+		"<|im_start|>user\nBye<|im_end|>\n" +
+		"<|im_start|>assistant\n"
+	if got != expected {
+		t.Errorf("Unexpected ChatML prompt:\n%s", got)
+	}
+
+	// Mistral has no system role, so the system prompt joins the first user message
+	got = Mistral.Format(messages)
+	expected = "<s>[INST] Be brief.\n\nHi [/INST]Hello</s>[INST] Bye [/INST]"
+	if got != expected {
+		t.Errorf("Unexpected Mistral prompt:\n%q", got)
+	}
+
+	if got := Gemma.Format(messages[:1]); !strings.Contains(got, "<start_of_turn>user\nBe brief.<end_of_turn>") {
+		t.Errorf("Expected lone system prompt to be sent as a user turn, got %q", got)
+	}
+}
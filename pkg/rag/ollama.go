@@ -0,0 +1,86 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/h2co32/gollama/internal/models"
+	"github.com/h2co32/gollama/pkg/prompt"
+)
+
+// OllamaEmbedder embeds texts with an embedding model served by Ollama.
+type OllamaEmbedder struct {
+	client *models.OllamaClient
+	model  string
+}
+
+// NewOllamaEmbedder creates an Embedder that embeds texts with model, such as
+// "nomic-embed-text".
+func NewOllamaEmbedder(client *models.OllamaClient, model string) *OllamaEmbedder {
+	return &OllamaEmbedder{client: client, model: model}
+}
+
+// Embed returns an embedding of each text, requesting them one at a time.
+func (e *OllamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		res, err := e.client.Embeddings(ctx, models.EmbeddingRequest{Model: e.model, Prompt: text})
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed text: %w", err)
+		}
+		vector := make([]float32, len(res.Embedding))
+		for j, v := range res.Embedding {
+			vector[j] = float32(v)
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+// cacheScope separates the cached embeddings of different models.
+func (e *OllamaEmbedder) cacheScope() string {
+	return "ollama:" + e.model
+}
+
+// OllamaGenerator generates answers with a chat model served by Ollama.
+type OllamaGenerator struct {
+	client  *models.OllamaClient
+	model   string
+	options map[string]interface{}
+}
+
+// NewOllamaGenerator creates a Generator that answers with model, such as "llama3".
+// options are passed to Ollama as model parameters, such as {"temperature": 0}, and may
+// be nil.
+func NewOllamaGenerator(client *models.OllamaClient, model string, options map[string]interface{}) *OllamaGenerator {
+	return &OllamaGenerator{client: client, model: model, options: options}
+}
+
+// Generate sends the messages to the model and returns its reply.
+func (g *OllamaGenerator) Generate(ctx context.Context, messages []prompt.Message) (string, error) {
+	req := models.ChatRequest{
+		Model:    g.model,
+		Messages: make([]models.Message, len(messages)),
+		Stream:   new(bool),
+		Options:  g.options,
+	}
+	for i, message := range messages {
+		req.Messages[i] = models.Message{Role: message.Role, Content: message.Content}
+	}
+
+	var reply strings.Builder
+	err := g.client.Chat(ctx, req, func(res models.ChatResponse) error {
+		reply.WriteString(res.Message.Content)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate answer: %w", err)
+	}
+	return reply.String(), nil
+}
+
+// cacheScope separates the cached answers of different models and parameters.
+func (g *OllamaGenerator) cacheScope() string {
+	return fmt.Sprintf("ollama:%s:%v", g.model, g.options)
+}
@@ -0,0 +1,520 @@
+// Package rag answers questions from a corpus of documents with retrieval-augmented
+// generation.
+//
+// A Pipeline ingests documents by splitting them into chunks, embedding the chunks, and
+// storing them in a vector store. It answers a question by embedding it, retrieving the
+// most similar chunks, rendering them with the question into a prompt template, and
+// generating a reply. Each stage is traced with OpenTelemetry, and embeddings and
+// answers can be cached so repeated content and questions skip the model.
+//
+// Example usage:
+//
+//	client := models.NewOllamaClient()
+//	pipeline, err := rag.New(rag.Options{
+//		Embedder:  rag.NewOllamaEmbedder(client, "nomic-embed-text"),
+//		Generator: rag.NewOllamaGenerator(client, "llama3", nil),
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	_, err = pipeline.Ingest(ctx, rag.Document{ID: "handbook", Text: handbook})
+//
+//	answer, err := pipeline.Answer(ctx, "How many vacation days do I get?")
+//	fmt.Println(answer.Text)
+//	for _, source := range answer.Sources {
+//		fmt.Println(source.ID, source.Score)
+//	}
+package rag
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/h2co32/gollama/internal/cache"
+	"github.com/h2co32/gollama/internal/preprocessing"
+	"github.com/h2co32/gollama/pkg/prompt"
+	"github.com/h2co32/gollama/pkg/vectorstore"
+)
+
+// Version represents the current package version following semantic versioning.
+const Version = "1.0.0"
+
+// tracerName is the instrumentation name of the default tracer.
+const tracerName = "github.com/h2co32/gollama/pkg/rag"
+
+// DefaultTemplateName is the name of DefaultTemplate.
+const DefaultTemplateName = "rag"
+
+// DefaultTemplate instructs the model to answer from the retrieved context. Templates
+// can use {{.Question}}, {{.Context}}, the retrieved chunks numbered and separated by
+// blank lines, and {{.Sources}}, the retrieved vectorstore.Match values.
+var DefaultTemplate = prompt.Template{
+	Name: DefaultTemplateName,
+	System: "Answer the question using only the context below. " +
+		"Cite the numbers of the passages you use, like [1]. " +
+		"If the context does not contain the answer, say that you don't know.\n\n" +
+		"Context:\n{{.Context}}",
+	Text: "{{.Question}}",
+}
+
+// Embedder embeds texts into vectors.
+type Embedder interface {
+	// Embed returns an embedding of each text, in order.
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Generator generates a reply to chat messages.
+type Generator interface {
+	// Generate returns the model's reply to messages.
+	Generate(ctx context.Context, messages []prompt.Message) (string, error)
+}
+
+// Document is a text to ingest.
+type Document struct {
+	// ID identifies the document. Ingesting a document with an existing ID replaces its
+	// chunks.
+	ID string
+
+	// Text is the content of the document.
+	Text string
+
+	// Metadata is stored with each chunk of the document, for filtering answers. Optional.
+	Metadata map[string]any
+}
+
+// Chunk metadata keys set by Ingest, in addition to the document's metadata.
+const (
+	MetadataDocumentID = "document_id"
+	MetadataChunk      = "chunk"
+	MetadataHeadings   = "headings"
+)
+
+// Answer is the reply to a question.
+type Answer struct {
+	// Text is the generated answer.
+	Text string `json:"text"`
+
+	// Sources are the chunks the answer was generated from, most similar first.
+	Sources []vectorstore.Match `json:"sources"`
+
+	// Messages is the prompt sent to the generator.
+	Messages []prompt.Message `json:"messages"`
+
+	// Cached reports whether the answer came from the cache.
+	Cached bool `json:"cached"`
+}
+
+// Options configures a Pipeline.
+type Options struct {
+	// Embedder embeds chunks and questions. Required.
+	Embedder Embedder
+
+	// Generator generates answers. Required by Answer.
+	Generator Generator
+
+	// Chunker splits documents into chunks.
+	// Default: a chunker with preprocessing.DefaultChunkOptions
+	Chunker *preprocessing.Chunker
+
+	// Store stores the chunks and their embeddings.
+	// Default: an empty in-memory store with cosine similarity
+	Store vectorstore.Store
+
+	// Prompts holds the template answers are rendered with.
+	// Default: a registry holding DefaultTemplate
+	Prompts *prompt.Registry
+
+	// Template is the name of the template in Prompts.
+	// Default: DefaultTemplateName
+	Template string
+
+	// TopK is the number of chunks retrieved for a question.
+	// Default: 4
+	TopK int
+
+	// MinScore excludes chunks less similar to the question. Optional.
+	MinScore *float64
+
+	// Cache stores embeddings and answers, so unchanged chunks are not embedded again
+	// and repeated questions over the same sources skip generation. Retrieval is never
+	// cached, as the store can change. Optional.
+	Cache cache.Cache
+
+	// CacheTTL is how long cached embeddings and answers are kept.
+	// Default: 24 hours
+	CacheTTL time.Duration
+
+	// CachePrefix starts every cache key. Embedders and generators of this package are
+	// cached separately per model; pipelines sharing a cache with other
+	// implementations should use distinct prefixes per model.
+	// Default: "rag:"
+	CachePrefix string
+
+	// Tracer starts a span for each stage.
+	// Default: a tracer from the global OpenTelemetry tracer provider
+	Tracer trace.Tracer
+}
+
+// DefaultOptions returns the default pipeline options.
+func DefaultOptions() Options {
+	return Options{
+		Template:    DefaultTemplateName,
+		TopK:        4,
+		CacheTTL:    24 * time.Hour,
+		CachePrefix: "rag:",
+		Tracer:      otel.Tracer(tracerName),
+	}
+}
+
+// Pipeline ingests documents and answers questions about them. It is safe for
+// concurrent use if its embedder, generator, and store are.
+type Pipeline struct {
+	options Options
+}
+
+// New creates a Pipeline with the given options. Zero values fall back to the defaults.
+// It returns an error if the embedder is missing or the template is not registered.
+func New(options Options) (*Pipeline, error) {
+	defaults := DefaultOptions()
+	if options.Embedder == nil {
+		return nil, errors.New("embedder is required")
+	}
+	if options.Chunker == nil {
+		chunker, err := preprocessing.NewChunker(preprocessing.DefaultChunkOptions())
+		if err != nil {
+			return nil, err
+		}
+		options.Chunker = chunker
+	}
+	if options.Store == nil {
+		store, err := vectorstore.NewMemoryStore(vectorstore.DefaultOptions())
+		if err != nil {
+			return nil, err
+		}
+		options.Store = store
+	}
+	if options.Prompts == nil {
+		options.Prompts = prompt.NewRegistry()
+		if err := options.Prompts.Register(DefaultTemplate); err != nil {
+			return nil, err
+		}
+	}
+	if options.Template == "" {
+		options.Template = defaults.Template
+	}
+	if _, ok := options.Prompts.Get(options.Template); !ok {
+		return nil, fmt.Errorf("template %s not found", options.Template)
+	}
+	if options.TopK <= 0 {
+		options.TopK = defaults.TopK
+	}
+	if options.CacheTTL <= 0 {
+		options.CacheTTL = defaults.CacheTTL
+	}
+	if options.CachePrefix == "" {
+		options.CachePrefix = defaults.CachePrefix
+	}
+	if options.Tracer == nil {
+		options.Tracer = defaults.Tracer
+	}
+	return &Pipeline{options: options}, nil
+}
+
+// Store returns the vector store holding the ingested chunks.
+func (p *Pipeline) Store() vectorstore.Store {
+	return p.options.Store
+}
+
+// Ingest chunks, embeds, and stores documents, replacing the chunks of documents ingested
+// before with the same IDs. It returns the number of chunks stored.
+func (p *Pipeline) Ingest(ctx context.Context, docs ...Document) (chunks int, err error) {
+	ctx, span := p.options.Tracer.Start(ctx, "rag.ingest", trace.WithAttributes(attribute.Int("rag.documents", len(docs))))
+	defer func() {
+		span.SetAttributes(attribute.Int("rag.chunks", chunks))
+		endSpan(span, err)
+	}()
+
+	for _, doc := range docs {
+		if doc.ID == "" {
+			return chunks, errors.New("document ID is required")
+		}
+		n, err := p.ingest(ctx, doc)
+		chunks += n
+		if err != nil {
+			return chunks, fmt.Errorf("failed to ingest document %s: %w", doc.ID, err)
+		}
+	}
+	return chunks, nil
+}
+
+// ingest chunks, embeds, and stores a document.
+func (p *Pipeline) ingest(ctx context.Context, doc Document) (int, error) {
+	_, span := p.options.Tracer.Start(ctx, "rag.chunk", trace.WithAttributes(attribute.String("rag.document_id", doc.ID)))
+	chunks := p.options.Chunker.Chunk(doc.Text)
+	span.SetAttributes(attribute.Int("rag.chunks", len(chunks)))
+	span.End()
+
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = chunk.Text
+	}
+	vectors, err := p.embed(ctx, texts)
+	if err != nil {
+		return 0, err
+	}
+
+	records := make([]vectorstore.Record, len(chunks))
+	for i, chunk := range chunks {
+		metadata := make(map[string]any, len(doc.Metadata)+3)
+		for key, value := range doc.Metadata {
+			metadata[key] = value
+		}
+		metadata[MetadataDocumentID] = doc.ID
+		metadata[MetadataChunk] = chunk.Index
+		if len(chunk.Headings) > 0 {
+			metadata[MetadataHeadings] = strings.Join(chunk.Headings, " > ")
+		}
+		records[i] = vectorstore.Record{
+			ID:       chunkID(doc.ID, chunk.Index),
+			Vector:   vectors[i],
+			Content:  chunk.Text,
+			Metadata: metadata,
+		}
+	}
+
+	ctx, span = p.options.Tracer.Start(ctx, "rag.store", trace.WithAttributes(attribute.String("rag.document_id", doc.ID)))
+	err = p.upsert(ctx, doc.ID, records)
+	endSpan(span, err)
+	return len(records), err
+}
+
+// upsert stores the chunks of a document and deletes chunks left from a longer version
+// of it.
+func (p *Pipeline) upsert(ctx context.Context, docID string, records []vectorstore.Record) error {
+	if len(records) > 0 {
+		if err := p.options.Store.Upsert(ctx, records...); err != nil {
+			return err
+		}
+	}
+	var stale []string
+	for i := len(records); ; i++ {
+		id := chunkID(docID, i)
+		if _, err := p.options.Store.Get(ctx, id); errors.Is(err, vectorstore.ErrNotFound) {
+			break
+		} else if err != nil {
+			return err
+		}
+		stale = append(stale, id)
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	return p.options.Store.Delete(ctx, stale...)
+}
+
+// chunkID returns the record ID of a chunk of a document.
+func chunkID(docID string, index int) string {
+	return docID + "#" + strconv.Itoa(index)
+}
+
+// Answer answers a question from the ingested documents.
+func (p *Pipeline) Answer(ctx context.Context, question string) (*Answer, error) {
+	return p.AnswerWithFilter(ctx, question, nil)
+}
+
+// AnswerWithFilter answers a question from the ingested chunks whose metadata matches
+// filter, such as vectorstore.Filter{rag.MetadataDocumentID: "handbook"}.
+func (p *Pipeline) AnswerWithFilter(ctx context.Context, question string, filter vectorstore.Filter) (answer *Answer, err error) {
+	ctx, span := p.options.Tracer.Start(ctx, "rag.answer")
+	defer func() { endSpan(span, err) }()
+
+	if p.options.Generator == nil {
+		return nil, errors.New("generator is required to answer questions")
+	}
+
+	vectors, err := p.embed(ctx, []string{question})
+	if err != nil {
+		return nil, err
+	}
+
+	retrieveCtx, retrieveSpan := p.options.Tracer.Start(ctx, "rag.retrieve", trace.WithAttributes(attribute.Int("rag.top_k", p.options.TopK)))
+	sources, err := p.options.Store.Query(retrieveCtx, vectors[0], vectorstore.QueryOptions{
+		TopK:     p.options.TopK,
+		Filter:   filter,
+		MinScore: p.options.MinScore,
+	})
+	retrieveSpan.SetAttributes(attribute.Int("rag.sources", len(sources)))
+	endSpan(retrieveSpan, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve context: %w", err)
+	}
+
+	messages, err := p.options.Prompts.Messages(p.options.Template, map[string]interface{}{
+		"Question": question,
+		"Context":  formatContext(sources),
+		"Sources":  sources,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	text, cached, err := p.generate(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Bool("rag.cached", cached))
+	return &Answer{Text: text, Sources: sources, Messages: messages, Cached: cached}, nil
+}
+
+// formatContext numbers the retrieved chunks and separates them with blank lines.
+func formatContext(sources []vectorstore.Match) string {
+	var b strings.Builder
+	for i, source := range sources {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		fmt.Fprintf(&b, "[%d] %s", i+1, source.Content)
+	}
+	return b.String()
+}
+
+// embed embeds texts, reusing cached embeddings.
+func (p *Pipeline) embed(ctx context.Context, texts []string) (vectors [][]float32, err error) {
+	ctx, span := p.options.Tracer.Start(ctx, "rag.embed", trace.WithAttributes(attribute.Int("rag.texts", len(texts))))
+	defer func() { endSpan(span, err) }()
+
+	vectors = make([][]float32, len(texts))
+	keys := make([]string, len(texts))
+	var cached map[string][]byte
+	if p.options.Cache != nil {
+		scope := cacheScope(p.options.Embedder)
+		for i, text := range texts {
+			keys[i] = p.cacheKey("embedding", scope, text)
+		}
+		// Errors only cost a cache miss
+		cached, _ = p.options.Cache.MGet(keys)
+	}
+
+	var missing []string
+	var missingIndexes []int
+	for i, text := range texts {
+		if data, ok := cached[keys[i]]; ok {
+			if vector, ok := decodeVector(data); ok {
+				vectors[i] = vector
+				continue
+			}
+		}
+		missing = append(missing, text)
+		missingIndexes = append(missingIndexes, i)
+	}
+	span.SetAttributes(attribute.Int("rag.cache_hits", len(texts)-len(missing)))
+	if len(missing) == 0 {
+		return vectors, nil
+	}
+
+	embedded, err := p.options.Embedder.Embed(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	if len(embedded) != len(missing) {
+		return nil, fmt.Errorf("embedder returned %d embeddings for %d texts", len(embedded), len(missing))
+	}
+	items := make(map[string][]byte, len(missing))
+	for j, i := range missingIndexes {
+		vectors[i] = embedded[j]
+		if p.options.Cache != nil {
+			items[keys[i]] = encodeVector(embedded[j])
+		}
+	}
+	if p.options.Cache != nil {
+		p.options.Cache.MSet(items, p.options.CacheTTL)
+	}
+	return vectors, nil
+}
+
+// generate returns the reply to messages, and whether it came from the cache.
+func (p *Pipeline) generate(ctx context.Context, messages []prompt.Message) (text string, cached bool, err error) {
+	ctx, span := p.options.Tracer.Start(ctx, "rag.generate")
+	defer func() {
+		span.SetAttributes(attribute.Bool("rag.cached", cached))
+		endSpan(span, err)
+	}()
+
+	var key string
+	if p.options.Cache != nil {
+		data, _ := json.Marshal(messages)
+		key = p.cacheKey("answer", cacheScope(p.options.Generator), string(data))
+		if data, err := p.options.Cache.Get(key); err == nil && data != nil {
+			return string(data), true, nil
+		}
+	}
+
+	text, err = p.options.Generator.Generate(ctx, messages)
+	if err != nil {
+		return "", false, err
+	}
+	if p.options.Cache != nil {
+		p.options.Cache.Set(key, []byte(text), p.options.CacheTTL)
+	}
+	return text, false, nil
+}
+
+// cacheKey returns the cache key of a stage's result for content.
+func (p *Pipeline) cacheKey(stage, scope, content string) string {
+	sum := sha256.Sum256([]byte(scope + "\x00" + content))
+	return p.options.CachePrefix + stage + ":" + hex.EncodeToString(sum[:])
+}
+
+// cacheScope returns what separates the cached results of an embedder or generator: the
+// model for the implementations in this package, or the type otherwise.
+func cacheScope(v interface{}) string {
+	if scoped, ok := v.(interface{ cacheScope() string }); ok {
+		return scoped.cacheScope()
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// encodeVector encodes a vector as little-endian float32s.
+func encodeVector(vector []float32) []byte {
+	data := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(v))
+	}
+	return data
+}
+
+// decodeVector decodes a vector encoded by encodeVector.
+func decodeVector(data []byte) ([]float32, bool) {
+	if len(data) == 0 || len(data)%4 != 0 {
+		return nil, false
+	}
+	vector := make([]float32, len(data)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return vector, true
+}
+
+// endSpan records err on span, if any, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
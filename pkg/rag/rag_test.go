@@ -0,0 +1,275 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/h2co32/gollama/internal/cache"
+	"github.com/h2co32/gollama/internal/models"
+	"github.com/h2co32/gollama/internal/preprocessing"
+	"github.com/h2co32/gollama/pkg/prompt"
+	"github.com/h2co32/gollama/pkg/vectorstore"
+)
+
+// wordEmbedder embeds texts as counts of their words hashed into 32 buckets, so texts
+// sharing words are similar.
+type wordEmbedder struct {
+	mu    sync.Mutex
+	texts []string
+}
+
+func (e *wordEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.mu.Lock()
+	e.texts = append(e.texts, texts...)
+	e.mu.Unlock()
+
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector := make([]float32, 32)
+		for _, word := range strings.Fields(strings.ToLower(text)) {
+			word = strings.Trim(word, ".,?!")
+			h := fnv.New32a()
+			h.Write([]byte(word))
+			vector[h.Sum32()%32]++
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+func (e *wordEmbedder) calls() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.texts)
+}
+
+// echoGenerator replies with the last message and counts its calls.
+type echoGenerator struct {
+	calls    int
+	messages []prompt.Message
+}
+
+func (g *echoGenerator) Generate(ctx context.Context, messages []prompt.Message) (string, error) {
+	g.calls++
+	g.messages = messages
+	return "answer to " + messages[len(messages)-1].Content, nil
+}
+
+func newTestPipeline(t *testing.T, options Options) (*Pipeline, *wordEmbedder, *echoGenerator) {
+	t.Helper()
+	embedder := &wordEmbedder{}
+	generator := &echoGenerator{}
+	options.Embedder = embedder
+	options.Generator = generator
+	if options.Chunker == nil {
+		chunker, err := preprocessing.NewChunker(preprocessing.ChunkOptions{MaxTokens: 8})
+		if err != nil {
+			t.Fatalf("Failed to create chunker: %v", err)
+		}
+		options.Chunker = chunker
+	}
+	pipeline, err := New(options)
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	return pipeline, embedder, generator
+}
+
+var testDocs = []Document{
+	{ID: "llamas", Text: "Llamas live in the Andes. Llamas carry loads for farmers.", Metadata: map[string]any{"topic": "animals"}},
+	{ID: "leave", Text: "Employees get twenty vacation days each year. Unused vacation days expire in March."},
+}
+
+func TestPipelineIngestAndAnswer(t *testing.T) {
+	ctx := context.Background()
+	pipeline, _, generator := newTestPipeline(t, Options{TopK: 2})
+
+	chunks, err := pipeline.Ingest(ctx, testDocs...)
+	if err != nil {
+		t.Fatalf("Failed to ingest: %v", err)
+	}
+	if chunks != 4 || pipeline.Store().Len() != 4 {
+		t.Errorf("Expected 4 chunks, got %d and %d stored", chunks, pipeline.Store().Len())
+	}
+
+	answer, err := pipeline.Answer(ctx, "How many vacation days do employees get?")
+	if err != nil {
+		t.Fatalf("Failed to answer: %v", err)
+	}
+	if answer.Text != "answer to How many vacation days do employees get?" {
+		t.Errorf("Unexpected answer %q", answer.Text)
+	}
+	if len(answer.Sources) != 2 || answer.Sources[0].ID != "leave#0" {
+		t.Fatalf("Expected leave#0 first of 2 sources, got %+v", answer.Sources)
+	}
+	if answer.Sources[0].Metadata[MetadataDocumentID] != "leave" {
+		t.Errorf("Expected document ID metadata, got %v", answer.Sources[0].Metadata)
+	}
+
+	system := generator.messages[0]
+	if system.Role != prompt.RoleSystem || !strings.Contains(system.Content, "[1] Employees get twenty vacation days each year.") {
+		t.Errorf("Expected context in the system prompt, got %q", system.Content)
+	}
+}
+
+func TestPipelineAnswerWithFilter(t *testing.T) {
+	ctx := context.Background()
+	pipeline, _, _ := newTestPipeline(t, Options{})
+	pipeline.Ingest(ctx, testDocs...)
+
+	answer, err := pipeline.AnswerWithFilter(ctx, "vacation days", vectorstore.Filter{"topic": "animals"})
+	if err != nil {
+		t.Fatalf("Failed to answer: %v", err)
+	}
+	for _, source := range answer.Sources {
+		if source.Metadata[MetadataDocumentID] != "llamas" {
+			t.Errorf("Expected only llama sources, got %s", source.ID)
+		}
+	}
+}
+
+func TestPipelineReingestReplacesChunks(t *testing.T) {
+	ctx := context.Background()
+	pipeline, _, _ := newTestPipeline(t, Options{})
+	pipeline.Ingest(ctx, testDocs...)
+
+	if _, err := pipeline.Ingest(ctx, Document{ID: "llamas", Text: "Llamas hum."}); err != nil {
+		t.Fatalf("Failed to ingest: %v", err)
+	}
+	if pipeline.Store().Len() != 3 {
+		t.Errorf("Expected 3 chunks, got %d", pipeline.Store().Len())
+	}
+	if _, err := pipeline.Store().Get(ctx, "llamas#1"); !errors.Is(err, vectorstore.ErrNotFound) {
+		t.Errorf("Expected stale chunk to be deleted, got %v", err)
+	}
+}
+
+func TestPipelineCaching(t *testing.T) {
+	ctx := context.Background()
+	memory := cache.NewMemoryCache(cache.MemoryCacheOptions{})
+	pipeline, embedder, generator := newTestPipeline(t, Options{Cache: memory})
+
+	pipeline.Ingest(ctx, testDocs...)
+	pipeline.Ingest(ctx, testDocs...)
+	if embedder.calls() != 4 {
+		t.Errorf("Expected 4 embedded chunks, got %d", embedder.calls())
+	}
+
+	first, err := pipeline.Answer(ctx, "Where do llamas live?")
+	if err != nil {
+		t.Fatalf("Failed to answer: %v", err)
+	}
+	second, err := pipeline.Answer(ctx, "Where do llamas live?")
+	if err != nil {
+		t.Fatalf("Failed to answer: %v", err)
+	}
+	if first.Cached || !second.Cached || second.Text != first.Text {
+		t.Errorf("Expected the second answer from the cache, got %+v and %+v", first, second)
+	}
+	if generator.calls != 1 || embedder.calls() != 5 {
+		t.Errorf("Expected 1 generation and 5 embeddings, got %d and %d", generator.calls, embedder.calls())
+	}
+
+	// New sources change the prompt, so the answer is generated again
+	pipeline.Ingest(ctx, Document{ID: "more", Text: "Llamas live in Peru."})
+	third, _ := pipeline.Answer(ctx, "Where do llamas live?")
+	if third.Cached || generator.calls != 2 {
+		t.Errorf("Expected a new answer, got cached=%v after %d generations", third.Cached, generator.calls)
+	}
+}
+
+func TestPipelineTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer provider.Shutdown(context.Background())
+
+	ctx := context.Background()
+	pipeline, _, _ := newTestPipeline(t, Options{Tracer: provider.Tracer("test")})
+	pipeline.Ingest(ctx, testDocs[0])
+	pipeline.Answer(ctx, "Where do llamas live?")
+
+	names := make(map[string]int)
+	for _, span := range recorder.Ended() {
+		names[span.Name()]++
+	}
+	expected := map[string]int{
+		"rag.ingest": 1, "rag.chunk": 1, "rag.store": 1, "rag.embed": 2,
+		"rag.answer": 1, "rag.retrieve": 1, "rag.generate": 1,
+	}
+	for name, count := range expected {
+		if names[name] != count {
+			t.Errorf("Expected %d %s spans, got %d", count, name, names[name])
+		}
+	}
+}
+
+func TestNewPipelineValidation(t *testing.T) {
+	if _, err := New(Options{}); err == nil {
+		t.Error("Expected error without an embedder")
+	}
+	if _, err := New(Options{Embedder: &wordEmbedder{}, Template: "missing"}); err == nil {
+		t.Error("Expected error for a missing template")
+	}
+
+	pipeline, err := New(Options{Embedder: &wordEmbedder{}})
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	if _, err := pipeline.Answer(context.Background(), "question"); err == nil {
+		t.Error("Expected error without a generator")
+	}
+}
+
+func TestOllamaEmbedderAndGenerator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/embeddings":
+			var req models.EmbeddingRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			json.NewEncoder(w).Encode(models.EmbeddingResponse{Embedding: []float64{float64(len(req.Prompt)), 1}})
+		case "/api/chat":
+			var req models.ChatRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Stream == nil || *req.Stream {
+				t.Error("Expected a non-streaming request")
+			}
+			json.NewEncoder(w).Encode(models.ChatResponse{
+				Model:   req.Model,
+				Message: models.Message{Role: prompt.RoleAssistant, Content: req.Model + ": " + req.Messages[len(req.Messages)-1].Content},
+				Done:    true,
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := models.NewOllamaClientWithOptions(models.OllamaClientOptions{BaseURL: server.URL, ModelDir: t.TempDir()})
+	ctx := context.Background()
+
+	vectors, err := NewOllamaEmbedder(client, "nomic-embed-text").Embed(ctx, []string{"a", "abc"})
+	if err != nil {
+		t.Fatalf("Failed to embed: %v", err)
+	}
+	if len(vectors) != 2 || vectors[0][0] != 1 || vectors[1][0] != 3 {
+		t.Errorf("Unexpected embeddings %v", vectors)
+	}
+
+	reply, err := NewOllamaGenerator(client, "llama3", nil).Generate(ctx, []prompt.Message{{Role: prompt.RoleUser, Content: "hi"}})
+	if err != nil {
+		t.Fatalf("Failed to generate: %v", err)
+	}
+	if reply != "llama3: hi" {
+		t.Errorf("Expected %q, got %q", "llama3: hi", reply)
+	}
+}
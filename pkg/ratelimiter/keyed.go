@@ -0,0 +1,197 @@
+package ratelimiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// KeyedOptions configures a KeyedLimiter.
+type KeyedOptions struct {
+	// Rate is the number of tokens added to each key's bucket per Interval.
+	Rate float64
+
+	// Interval is the time interval for rate calculation.
+	// Default: 1 second
+	Interval time.Duration
+
+	// Capacity is the burst capacity of each key's bucket.
+	// Default: Rate
+	Capacity float64
+
+	// IdleTimeout is how long a bucket can go unused before it is removed.
+	// A removed bucket starts full when its key is seen again.
+	// Default: 10 minutes
+	IdleTimeout time.Duration
+
+	// MaxKeys caps the number of buckets held at once. When a new key arrives at the cap,
+	// the least recently used bucket is removed to make room.
+	// Default: 10000
+	MaxKeys int
+}
+
+// DefaultKeyedOptions returns the default options for a KeyedLimiter.
+func DefaultKeyedOptions() KeyedOptions {
+	return KeyedOptions{
+		Rate:        10,
+		Interval:    time.Second,
+		Capacity:    10,
+		IdleTimeout: 10 * time.Minute,
+		MaxKeys:     10000,
+	}
+}
+
+// KeyedLimiter maintains an independent token bucket per key, such as an API key,
+// user ID, or model name, so that one busy key cannot use up the tokens of others.
+// Buckets are created on first use and removed once idle.
+type KeyedLimiter struct {
+	options   KeyedOptions
+	buckets   map[string]*keyedBucket
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+// keyedBucket is a key's limiter and the last time it was used.
+type keyedBucket struct {
+	limiter  *RateLimiter
+	lastUsed time.Time
+}
+
+// NewKeyedLimiter creates a KeyedLimiter with the given options.
+// Zero values fall back to the defaults.
+func NewKeyedLimiter(options KeyedOptions) *KeyedLimiter {
+	defaults := DefaultKeyedOptions()
+	if options.Rate <= 0 {
+		options.Rate = defaults.Rate
+	}
+	if options.Interval <= 0 {
+		options.Interval = defaults.Interval
+	}
+	if options.Capacity <= 0 {
+		options.Capacity = options.Rate
+	}
+	if options.IdleTimeout <= 0 {
+		options.IdleTimeout = defaults.IdleTimeout
+	}
+	if options.MaxKeys <= 0 {
+		options.MaxKeys = defaults.MaxKeys
+	}
+
+	return &KeyedLimiter{
+		options:   options,
+		buckets:   make(map[string]*keyedBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow checks if an operation for key is allowed and consumes a token if available.
+func (kl *KeyedLimiter) Allow(key string) bool {
+	return kl.AllowN(key, 1)
+}
+
+// AllowN checks if n operations for key are allowed and consumes n tokens if available.
+func (kl *KeyedLimiter) AllowN(key string, n float64) bool {
+	return kl.Limiter(key).AllowN(n)
+}
+
+// Wait blocks until an operation for key is allowed or the context is canceled.
+func (kl *KeyedLimiter) Wait(ctx context.Context, key string) error {
+	return kl.WaitN(ctx, key, 1)
+}
+
+// WaitN blocks until n operations for key are allowed or the context is canceled.
+func (kl *KeyedLimiter) WaitN(ctx context.Context, key string, n float64) error {
+	return kl.Limiter(key).WaitN(ctx, n)
+}
+
+// Limiter returns the limiter for key, creating it if needed.
+func (kl *KeyedLimiter) Limiter(key string) *RateLimiter {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(kl.lastSweep) >= kl.options.IdleTimeout/2 {
+		kl.sweep(now)
+	}
+
+	bucket, ok := kl.buckets[key]
+	if !ok {
+		if len(kl.buckets) >= kl.options.MaxKeys {
+			kl.evictOldest()
+		}
+		bucket = &keyedBucket{limiter: New(kl.options.Rate, kl.options.Interval, kl.options.Capacity)}
+		kl.buckets[key] = bucket
+	}
+	bucket.lastUsed = now
+	return bucket.limiter
+}
+
+// SetRate changes the rate of every existing bucket and of buckets created later.
+// Non-positive rates are ignored.
+func (kl *KeyedLimiter) SetRate(rate float64) {
+	if rate <= 0 {
+		return
+	}
+
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+
+	kl.options.Rate = rate
+	for _, bucket := range kl.buckets {
+		bucket.limiter.SetRate(rate)
+	}
+}
+
+// SetCapacity changes the capacity of every existing bucket and of buckets created later.
+// Non-positive capacities are ignored.
+func (kl *KeyedLimiter) SetCapacity(capacity float64) {
+	if capacity <= 0 {
+		return
+	}
+
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+
+	kl.options.Capacity = capacity
+	for _, bucket := range kl.buckets {
+		bucket.limiter.SetCapacity(capacity)
+	}
+}
+
+// Remove deletes the bucket for key. The key starts with a full bucket when seen again.
+func (kl *KeyedLimiter) Remove(key string) {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	delete(kl.buckets, key)
+}
+
+// Len returns the number of keys that currently have a bucket.
+func (kl *KeyedLimiter) Len() int {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	return len(kl.buckets)
+}
+
+// sweep removes buckets that have been idle for longer than the idle timeout.
+// This method is not thread-safe and should be called with the mutex locked.
+func (kl *KeyedLimiter) sweep(now time.Time) {
+	for key, bucket := range kl.buckets {
+		if now.Sub(bucket.lastUsed) > kl.options.IdleTimeout {
+			delete(kl.buckets, key)
+		}
+	}
+	kl.lastSweep = now
+}
+
+// evictOldest removes the least recently used bucket.
+// This method is not thread-safe and should be called with the mutex locked.
+func (kl *KeyedLimiter) evictOldest() {
+	var oldestKey string
+	var oldest time.Time
+	for key, bucket := range kl.buckets {
+		if oldestKey == "" || bucket.lastUsed.Before(oldest) {
+			oldestKey, oldest = key, bucket.lastUsed
+		}
+	}
+	delete(kl.buckets, oldestKey)
+}
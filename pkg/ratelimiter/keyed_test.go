@@ -0,0 +1,113 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNewKeyedLimiter(t *testing.T) {
+	kl := NewKeyedLimiter(KeyedOptions{Rate: 5})
+
+	if kl.options.Interval != time.Second {
+		t.Errorf("Expected default interval of 1s, got %v", kl.options.Interval)
+	}
+	if kl.options.Capacity != 5 {
+		t.Errorf("Expected capacity to default to rate (5), got %f", kl.options.Capacity)
+	}
+	if kl.options.MaxKeys != DefaultKeyedOptions().MaxKeys {
+		t.Errorf("Expected default max keys %d, got %d", DefaultKeyedOptions().MaxKeys, kl.options.MaxKeys)
+	}
+	if kl.Len() != 0 {
+		t.Errorf("Expected no buckets before first use, got %d", kl.Len())
+	}
+}
+
+func TestKeyedLimiterIndependentKeys(t *testing.T) {
+	kl := NewKeyedLimiter(KeyedOptions{Rate: 2, Interval: time.Hour})
+
+	// Exhaust tenant-a
+	for i := 0; i < 2; i++ {
+		if !kl.Allow("tenant-a") {
+			t.Errorf("Expected Allow(tenant-a) to return true for token %d", i+1)
+		}
+	}
+	if kl.Allow("tenant-a") {
+		t.Error("Expected Allow(tenant-a) to return false after exhausting its bucket")
+	}
+
+	// tenant-b is unaffected
+	if !kl.AllowN("tenant-b", 2) {
+		t.Error("Expected AllowN(tenant-b, 2) to return true")
+	}
+	if kl.Len() != 2 {
+		t.Errorf("Expected 2 buckets, got %d", kl.Len())
+	}
+
+	// Waiting on an exhausted key times out
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := kl.Wait(ctx, "tenant-a"); err == nil {
+		t.Error("Expected Wait(tenant-a) to return an error when the context expires")
+	}
+
+	// Removing a key gives it a fresh bucket
+	kl.Remove("tenant-a")
+	if !kl.Allow("tenant-a") {
+		t.Error("Expected Allow(tenant-a) to return true after Remove")
+	}
+}
+
+func TestKeyedLimiterIdleExpiry(t *testing.T) {
+	kl := NewKeyedLimiter(KeyedOptions{Rate: 1, Interval: time.Hour, IdleTimeout: 20 * time.Millisecond})
+
+	kl.Allow("idle")
+	time.Sleep(30 * time.Millisecond)
+	kl.Allow("active")
+
+	if kl.Len() != 1 {
+		t.Errorf("Expected idle bucket to be removed, got %d buckets", kl.Len())
+	}
+	if !kl.Allow("idle") {
+		t.Error("Expected an expired key to start with a full bucket")
+	}
+}
+
+func TestKeyedLimiterMaxKeys(t *testing.T) {
+	kl := NewKeyedLimiter(KeyedOptions{Rate: 1, Interval: time.Hour, MaxKeys: 3})
+
+	for i := 0; i < 3; i++ {
+		kl.Allow(fmt.Sprintf("key-%d", i))
+		time.Sleep(time.Millisecond)
+	}
+	// Touch key-0 so key-1 becomes the least recently used
+	kl.Limiter("key-0")
+	kl.Allow("key-3")
+
+	if kl.Len() != 3 {
+		t.Errorf("Expected bucket count to stay at 3, got %d", kl.Len())
+	}
+	if _, ok := kl.buckets["key-1"]; ok {
+		t.Error("Expected least recently used key-1 to be evicted")
+	}
+	if _, ok := kl.buckets["key-0"]; !ok {
+		t.Error("Expected recently used key-0 to be kept")
+	}
+}
+
+func TestKeyedLimiterSetRateAndCapacity(t *testing.T) {
+	kl := NewKeyedLimiter(KeyedOptions{Rate: 1, Interval: time.Hour})
+	existing := kl.Limiter("existing")
+
+	kl.SetRate(50)
+	kl.SetCapacity(5)
+
+	if existing.Rate() != 50 || existing.Capacity() != 5 {
+		t.Errorf("Expected existing bucket to be updated, got rate %f and capacity %f", existing.Rate(), existing.Capacity())
+	}
+	created := kl.Limiter("created")
+	if created.Rate() != 50 || created.Capacity() != 5 {
+		t.Errorf("Expected new bucket to use the new limits, got rate %f and capacity %f", created.Rate(), created.Capacity())
+	}
+}
@@ -0,0 +1,253 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Priority is the class of a request waiting on a PriorityLimiter.
+type Priority int
+
+// Priority classes, from lowest to highest.
+const (
+	// PriorityLow is for background work such as batch embeddings
+	PriorityLow Priority = iota
+
+	// PriorityNormal is the default class
+	PriorityNormal
+
+	// PriorityHigh is for latency-sensitive work such as interactive chat
+	PriorityHigh
+
+	numPriorities = int(PriorityHigh) + 1
+)
+
+// PriorityOptions configures a PriorityLimiter.
+type PriorityOptions struct {
+	// MaxWait is how long a waiter can be passed over by higher-priority waiters before
+	// it is served first anyway, so lower classes are never starved.
+	// Default: 5 seconds
+	MaxWait time.Duration
+}
+
+// DefaultPriorityOptions returns the default options for a PriorityLimiter.
+func DefaultPriorityOptions() PriorityOptions {
+	return PriorityOptions{
+		MaxWait: 5 * time.Second,
+	}
+}
+
+// PriorityLimiter shares a RateLimiter between priority classes. When tokens are
+// scarce, waiters of a higher class are admitted before waiters of a lower class,
+// and waiters of the same class are admitted in arrival order. A waiter that has
+// waited longer than MaxWait is admitted ahead of every class.
+type PriorityLimiter struct {
+	limiter     *RateLimiter
+	options     PriorityOptions
+	queues      [numPriorities][]*priorityWaiter
+	dispatching bool
+	wake        chan struct{}
+	mu          sync.Mutex
+}
+
+// priorityWaiter is a request queued on a PriorityLimiter.
+type priorityWaiter struct {
+	priority Priority
+	tokens   float64
+	enqueued time.Time
+	ready    chan error // Receives the result once the waiter leaves the queue
+}
+
+// NewPriorityLimiter creates a PriorityLimiter that takes its tokens from limiter.
+// The limiter's rate and capacity can still be changed with SetRate and SetCapacity.
+func NewPriorityLimiter(limiter *RateLimiter, options PriorityOptions) *PriorityLimiter {
+	if options.MaxWait <= 0 {
+		options.MaxWait = DefaultPriorityOptions().MaxWait
+	}
+
+	return &PriorityLimiter{
+		limiter: limiter,
+		options: options,
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Allow is shorthand for AllowN(priority, 1).
+func (pl *PriorityLimiter) Allow(priority Priority) bool {
+	return pl.AllowN(priority, 1)
+}
+
+// AllowN consumes n tokens if they are available and no waiter of the same or a
+// higher priority is queued ahead of the caller.
+func (pl *PriorityLimiter) AllowN(priority Priority, n float64) bool {
+	priority = clampPriority(priority)
+
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	for p := int(priority); p < numPriorities; p++ {
+		if len(pl.queues[p]) > 0 {
+			return false
+		}
+	}
+	return pl.limiter.AllowN(n)
+}
+
+// Wait is shorthand for WaitN(ctx, priority, 1).
+func (pl *PriorityLimiter) Wait(ctx context.Context, priority Priority) error {
+	return pl.WaitN(ctx, priority, 1)
+}
+
+// WaitN blocks until n tokens are granted to the caller or the context is canceled.
+// It returns an error if the context was canceled or n exceeds the limiter's capacity.
+func (pl *PriorityLimiter) WaitN(ctx context.Context, priority Priority, n float64) error {
+	// Fast path: nobody is queued, so the caller does not need to take turns
+	if pl.AllowN(PriorityLow, n) {
+		return nil
+	}
+
+	w := &priorityWaiter{
+		priority: clampPriority(priority),
+		tokens:   n,
+		enqueued: time.Now(),
+		ready:    make(chan error, 1),
+	}
+
+	pl.mu.Lock()
+	pl.queues[w.priority] = append(pl.queues[w.priority], w)
+	if !pl.dispatching {
+		pl.dispatching = true
+		go pl.dispatch()
+	} else {
+		pl.signal()
+	}
+	pl.mu.Unlock()
+
+	select {
+	case err := <-w.ready:
+		return err
+	case <-ctx.Done():
+	}
+
+	pl.mu.Lock()
+	if pl.remove(w) {
+		pl.signal()
+		pl.mu.Unlock()
+		return ctx.Err()
+	}
+	pl.mu.Unlock()
+
+	// The waiter was served while the context was being canceled
+	return <-w.ready
+}
+
+// Waiting returns the number of queued waiters of the given priority.
+func (pl *PriorityLimiter) Waiting(priority Priority) int {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	return len(pl.queues[clampPriority(priority)])
+}
+
+// Limiter returns the underlying rate limiter.
+func (pl *PriorityLimiter) Limiter() *RateLimiter {
+	return pl.limiter
+}
+
+// dispatch grants tokens to queued waiters in priority order until the queues are empty.
+func (pl *PriorityLimiter) dispatch() {
+	for {
+		pl.mu.Lock()
+		w := pl.next(time.Now())
+		if w == nil {
+			pl.dispatching = false
+			pl.mu.Unlock()
+			return
+		}
+
+		r := pl.limiter.ReserveN(w.tokens)
+		if !r.OK() {
+			pl.remove(w)
+			w.ready <- fmt.Errorf("cannot wait for %v tokens: exceeds limiter capacity of %v", w.tokens, pl.limiter.Capacity())
+			pl.mu.Unlock()
+			continue
+		}
+		delay := r.Delay()
+		if delay == 0 {
+			pl.remove(w)
+			w.ready <- nil
+			pl.mu.Unlock()
+			continue
+		}
+
+		// Not enough tokens yet. Give them back and choose again once they have
+		// refilled, or sooner if the queues change.
+		r.Cancel()
+		pl.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-pl.wake:
+			timer.Stop()
+		}
+	}
+}
+
+// next returns the waiter to serve first: the longest-waiting waiter that has exceeded
+// MaxWait, or else the oldest waiter of the highest non-empty class.
+// This method is not thread-safe and should be called with the mutex locked.
+func (pl *PriorityLimiter) next(now time.Time) *priorityWaiter {
+	var starved *priorityWaiter
+	for _, queue := range pl.queues {
+		if len(queue) == 0 || now.Sub(queue[0].enqueued) < pl.options.MaxWait {
+			continue
+		}
+		if starved == nil || queue[0].enqueued.Before(starved.enqueued) {
+			starved = queue[0]
+		}
+	}
+	if starved != nil {
+		return starved
+	}
+
+	for p := numPriorities - 1; p >= 0; p-- {
+		if len(pl.queues[p]) > 0 {
+			return pl.queues[p][0]
+		}
+	}
+	return nil
+}
+
+// remove deletes w from its queue and reports whether it was still queued.
+// This method is not thread-safe and should be called with the mutex locked.
+func (pl *PriorityLimiter) remove(w *priorityWaiter) bool {
+	queue := pl.queues[w.priority]
+	for i, queued := range queue {
+		if queued == w {
+			pl.queues[w.priority] = append(queue[:i], queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// signal wakes the dispatcher so it re-evaluates the queues.
+func (pl *PriorityLimiter) signal() {
+	select {
+	case pl.wake <- struct{}{}:
+	default:
+	}
+}
+
+// clampPriority maps out-of-range priorities to the nearest class.
+func clampPriority(priority Priority) Priority {
+	if priority < PriorityLow {
+		return PriorityLow
+	}
+	if priority > PriorityHigh {
+		return PriorityHigh
+	}
+	return priority
+}
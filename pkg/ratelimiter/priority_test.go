@@ -0,0 +1,104 @@
+package ratelimiter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitInOrder starts a waiter for each priority, in order, and returns the order
+// in which they were admitted.
+func waitInOrder(t *testing.T, pl *PriorityLimiter, priorities []Priority) []Priority {
+	var mu sync.Mutex
+	var admitted []Priority
+	var wg sync.WaitGroup
+
+	for _, priority := range priorities {
+		wg.Add(1)
+		go func(priority Priority) {
+			defer wg.Done()
+			if err := pl.Wait(context.Background(), priority); err != nil {
+				t.Errorf("Expected Wait() to return nil, got %v", err)
+				return
+			}
+			mu.Lock()
+			admitted = append(admitted, priority)
+			mu.Unlock()
+		}(priority)
+		// Make sure the waiters queue in the given order
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	wg.Wait()
+	return admitted
+}
+
+func TestPriorityLimiterOrder(t *testing.T) {
+	// One token every 50ms, starting empty
+	rl := New(20, time.Second, 1)
+	rl.Allow()
+	pl := NewPriorityLimiter(rl, DefaultPriorityOptions())
+
+	admitted := waitInOrder(t, pl, []Priority{PriorityLow, PriorityNormal, PriorityHigh, PriorityHigh})
+
+	expected := []Priority{PriorityHigh, PriorityHigh, PriorityNormal, PriorityLow}
+	for i := range expected {
+		if i >= len(admitted) || admitted[i] != expected[i] {
+			t.Fatalf("Expected admission order %v, got %v", expected, admitted)
+		}
+	}
+}
+
+func TestPriorityLimiterStarvation(t *testing.T) {
+	rl := New(20, time.Second, 1)
+	rl.Allow()
+	pl := NewPriorityLimiter(rl, PriorityOptions{MaxWait: 100 * time.Millisecond})
+
+	// The low waiter exceeds MaxWait after about two tokens and must not wait for all highs
+	admitted := waitInOrder(t, pl, []Priority{PriorityLow, PriorityHigh, PriorityHigh, PriorityHigh, PriorityHigh, PriorityHigh})
+
+	for i, priority := range admitted {
+		if priority == PriorityLow {
+			if i == len(admitted)-1 {
+				t.Errorf("Expected the low priority waiter to be admitted before all high priority waiters, got %v", admitted)
+			}
+			return
+		}
+	}
+	t.Errorf("Expected the low priority waiter to be admitted, got %v", admitted)
+}
+
+func TestPriorityLimiterAllow(t *testing.T) {
+	pl := NewPriorityLimiter(New(1, time.Second, 1), DefaultPriorityOptions())
+
+	if !pl.Allow(PriorityLow) {
+		t.Error("Expected Allow() to succeed with no waiters and a token available")
+	}
+
+	// Queue a normal waiter behind the empty bucket
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- pl.Wait(ctx, PriorityNormal) }()
+	for pl.Waiting(PriorityNormal) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The queued waiter keeps lower and equal classes out
+	if pl.Allow(PriorityLow) || pl.Allow(PriorityNormal) {
+		t.Error("Expected Allow() to fail while a waiter of the same or higher priority is queued")
+	}
+
+	// Canceling removes the waiter from the queue
+	cancel()
+	if err := <-done; err != nil && err != context.Canceled {
+		t.Errorf("Expected Wait() to return nil or context.Canceled, got %v", err)
+	}
+	if pl.Waiting(PriorityNormal) != 0 {
+		t.Errorf("Expected no queued waiters after cancel, got %d", pl.Waiting(PriorityNormal))
+	}
+
+	if err := pl.WaitN(context.Background(), PriorityHigh, 2); err == nil {
+		t.Error("Expected WaitN() to fail for more tokens than the capacity")
+	}
+}
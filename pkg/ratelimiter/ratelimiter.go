@@ -25,10 +25,21 @@
 //	} else {
 //		// Timed out waiting for a token
 //	}
+//
+//	// Or reserve a token and schedule the operation for when it is available
+//	r := limiter.Reserve()
+//	time.AfterFunc(r.Delay(), doOperation)
+//
+//	// Limit each API key independently so one client cannot starve the others
+//	perKey := ratelimiter.NewKeyedLimiter(ratelimiter.KeyedOptions{Rate: 5, Interval: time.Second})
+//	if !perKey.Allow(apiKey) {
+//		// This client is over its limit
+//	}
 package ratelimiter
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 )
@@ -96,26 +107,30 @@ func (rl *RateLimiter) Wait(ctx context.Context) error {
 }
 
 // WaitN blocks until n operations are allowed or the context is canceled.
-// It returns nil if n tokens were obtained, or an error if the context was canceled.
+// It returns nil if n tokens were obtained, or an error if the context was canceled
+// or n exceeds the limiter's capacity.
 func (rl *RateLimiter) WaitN(ctx context.Context, n float64) error {
-	// Fast path: check if we can get tokens immediately
-	if rl.AllowN(n) {
+	// Reserve the tokens and sleep until they are ours, rather than polling
+	r := rl.ReserveN(n)
+	if !r.OK() {
+		return fmt.Errorf("cannot wait for %v tokens: exceeds limiter capacity of %v", n, rl.Capacity())
+	}
+
+	delay := r.Delay()
+	if delay == 0 {
 		return nil
 	}
 
-	// Slow path: wait for tokens to become available
-	ticker := time.NewTicker(rl.interval / 10) // Check more frequently than the refill interval
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-ticker.C:
-			if rl.AllowN(n) {
-				return nil
-			}
-		}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		// Give the tokens back so they are not lost to a caller that gave up
+		r.Cancel()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
 }
 
@@ -137,6 +152,7 @@ func (rl *RateLimiter) refill() {
 }
 
 // Available returns the current number of available tokens.
+// The result is negative while outstanding reservations are waiting for tokens.
 func (rl *RateLimiter) Available() float64 {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
@@ -146,10 +162,53 @@ func (rl *RateLimiter) Available() float64 {
 
 // Capacity returns the maximum number of tokens the limiter can hold.
 func (rl *RateLimiter) Capacity() float64 {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
 	return rl.capacity
 }
 
 // Rate returns the rate at which tokens are added to the bucket.
 func (rl *RateLimiter) Rate() float64 {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
 	return rl.rate
 }
+
+// Interval returns the time interval over which Rate tokens are added.
+func (rl *RateLimiter) Interval() time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.interval
+}
+
+// SetRate changes the number of tokens added per interval. Tokens accumulated at the
+// old rate are kept. Non-positive rates are ignored.
+func (rl *RateLimiter) SetRate(rate float64) {
+	if rate <= 0 {
+		return
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	// Settle the tokens earned at the old rate before switching
+	rl.refill()
+	rl.rate = rate
+}
+
+// SetCapacity changes the maximum number of tokens the limiter can hold. Accumulated
+// tokens are kept, up to the new capacity. Non-positive capacities are ignored.
+func (rl *RateLimiter) SetCapacity(capacity float64) {
+	if capacity <= 0 {
+		return
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.refill()
+	rl.capacity = capacity
+	if rl.tokens > rl.capacity {
+		rl.tokens = rl.capacity
+	}
+}
@@ -293,6 +293,57 @@ func TestRate(t *testing.T) {
 	if rl.Rate() != rate {
 		t.Errorf("Expected Rate() to return %f, got %f", rate, rl.Rate())
 	}
+
+	if rl.Interval() != time.Second {
+		t.Errorf("Expected Interval() to return %v, got %v", time.Second, rl.Interval())
+	}
+}
+
+func TestSetRate(t *testing.T) {
+	rl := New(10, time.Second, 10)
+	rl.AllowN(10)
+
+	// Ignored values leave the rate unchanged
+	rl.SetRate(0)
+	if rl.Rate() != 10 {
+		t.Errorf("Expected SetRate(0) to be ignored, got rate %f", rl.Rate())
+	}
+
+	rl.SetRate(100)
+	if rl.Rate() != 100 {
+		t.Errorf("Expected Rate() to return 100, got %f", rl.Rate())
+	}
+
+	// At 100 tokens per second, 5 tokens take about 50ms
+	time.Sleep(60 * time.Millisecond)
+	if !rl.AllowN(5) {
+		t.Errorf("Expected AllowN(5) to succeed at the new rate, got %f tokens", rl.Available())
+	}
+}
+
+func TestSetCapacity(t *testing.T) {
+	rl := New(1, time.Hour, 10)
+	rl.AllowN(4)
+
+	// Raising the capacity keeps the accumulated tokens
+	rl.SetCapacity(20)
+	if rl.Capacity() != 20 {
+		t.Errorf("Expected Capacity() to return 20, got %f", rl.Capacity())
+	}
+	if tokens := rl.Available(); tokens < 5.9 || tokens > 6.1 {
+		t.Errorf("Expected 6 tokens after raising the capacity, got %f", tokens)
+	}
+
+	// Lowering the capacity caps the accumulated tokens
+	rl.SetCapacity(3)
+	if tokens := rl.Available(); tokens != 3 {
+		t.Errorf("Expected 3 tokens after lowering the capacity, got %f", tokens)
+	}
+
+	rl.SetCapacity(-1)
+	if rl.Capacity() != 3 {
+		t.Errorf("Expected SetCapacity(-1) to be ignored, got capacity %f", rl.Capacity())
+	}
 }
 
 func TestConcurrentAccess(t *testing.T) {
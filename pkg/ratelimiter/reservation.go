@@ -0,0 +1,106 @@
+package ratelimiter
+
+import (
+	"math"
+	"time"
+)
+
+// Reservation holds tokens taken from a RateLimiter ahead of time. The caller should
+// wait for Delay before acting, or call Cancel if it decides not to act.
+type Reservation struct {
+	ok        bool
+	limiter   *RateLimiter
+	tokens    float64
+	timeToAct time.Time
+	canceled  bool // Guarded by limiter.mu
+}
+
+// Reserve is shorthand for ReserveN(1).
+func (rl *RateLimiter) Reserve() *Reservation {
+	return rl.ReserveN(1)
+}
+
+// ReserveN takes n tokens from the limiter, borrowing against future refills if not
+// enough are available, and returns a Reservation saying how long the caller must
+// wait before acting. Unlike WaitN it never blocks, so callers can schedule work at
+// the right time instead of polling.
+//
+// If n exceeds the limiter's capacity the request can never be satisfied; the
+// returned Reservation is not OK and no tokens are taken.
+func (rl *RateLimiter) ReserveN(n float64) *Reservation {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if n > rl.capacity {
+		return &Reservation{limiter: rl}
+	}
+
+	rl.refill()
+	rl.tokens -= n
+
+	r := &Reservation{
+		ok:        true,
+		limiter:   rl,
+		tokens:    n,
+		timeToAct: rl.lastRefillTime,
+	}
+	if rl.tokens < 0 {
+		r.timeToAct = r.timeToAct.Add(rl.durationFor(-rl.tokens))
+	}
+	return r
+}
+
+// OK reports whether the limiter can provide the reserved tokens.
+// A reservation that is not OK has no delay and Cancel does nothing.
+func (r *Reservation) OK() bool {
+	return r.ok
+}
+
+// Delay returns how long the caller must wait before acting on the reservation.
+// Zero means the caller may act immediately. If the reservation is not OK,
+// Delay returns math.MaxInt64 (an effectively infinite duration).
+func (r *Reservation) Delay() time.Duration {
+	return r.DelayFrom(time.Now())
+}
+
+// DelayFrom returns how long the caller must wait, measured from now, before acting
+// on the reservation.
+func (r *Reservation) DelayFrom(now time.Time) time.Duration {
+	if !r.ok {
+		return time.Duration(math.MaxInt64)
+	}
+	if delay := r.timeToAct.Sub(now); delay > 0 {
+		return delay
+	}
+	return 0
+}
+
+// Cancel returns the reserved tokens to the limiter so other callers can use them.
+// It does nothing if the reservation is not OK, was already canceled, or its
+// time to act has passed.
+func (r *Reservation) Cancel() {
+	if !r.ok {
+		return
+	}
+
+	rl := r.limiter
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if r.canceled || !time.Now().Before(r.timeToAct) {
+		return
+	}
+	r.canceled = true
+
+	rl.refill()
+	rl.tokens += r.tokens
+	if rl.tokens > rl.capacity {
+		rl.tokens = rl.capacity
+	}
+}
+
+// durationFor returns how long the limiter takes to add the given number of tokens.
+// This method is not thread-safe and should be called with the mutex locked.
+func (rl *RateLimiter) durationFor(tokens float64) time.Duration {
+	return time.Duration(tokens / rl.rate * float64(rl.interval))
+}
@@ -0,0 +1,91 @@
+package ratelimiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReserve(t *testing.T) {
+	// Create a rate limiter with 10 tokens per second
+	rl := New(10, time.Second, 2)
+
+	// Tokens in the bucket are available immediately
+	for i := 0; i < 2; i++ {
+		r := rl.Reserve()
+		if !r.OK() {
+			t.Fatalf("Expected reservation %d to be OK", i+1)
+		}
+		if r.Delay() != 0 {
+			t.Errorf("Expected reservation %d to have no delay, got %v", i+1, r.Delay())
+		}
+	}
+
+	// Further reservations borrow against future refills
+	r := rl.Reserve()
+	if delay := r.Delay(); delay < 90*time.Millisecond || delay > 100*time.Millisecond {
+		t.Errorf("Expected a delay of about 100ms, got %v", delay)
+	}
+	r = rl.ReserveN(2)
+	if delay := r.Delay(); delay < 290*time.Millisecond || delay > 300*time.Millisecond {
+		t.Errorf("Expected a delay of about 300ms, got %v", delay)
+	}
+	if rl.Available() > -2.9 {
+		t.Errorf("Expected about -3 tokens while reservations are outstanding, got %f", rl.Available())
+	}
+
+	// Requests larger than the capacity can never be satisfied
+	r = rl.ReserveN(3)
+	if r.OK() {
+		t.Error("Expected reservation larger than capacity not to be OK")
+	}
+	if r.Delay() <= time.Hour {
+		t.Errorf("Expected an infinite delay for a reservation that is not OK, got %v", r.Delay())
+	}
+}
+
+func TestReservationCancel(t *testing.T) {
+	rl := New(10, time.Second, 2)
+	rl.AllowN(2)
+
+	r := rl.ReserveN(2)
+	r.Cancel()
+	if tokens := rl.Available(); tokens < -0.1 || tokens > 0.1 {
+		t.Errorf("Expected canceled tokens to be returned, got %f available", tokens)
+	}
+
+	// Canceling twice does not return the tokens twice
+	r.Cancel()
+	if tokens := rl.Available(); tokens > 0.1 {
+		t.Errorf("Expected a second Cancel to do nothing, got %f available", tokens)
+	}
+
+	// Canceling after the time to act does nothing
+	r = rl.Reserve()
+	time.Sleep(r.Delay() + 10*time.Millisecond)
+	before := rl.Available()
+	r.Cancel()
+	if rl.Available()-before > 0.5 {
+		t.Errorf("Expected Cancel after the time to act to do nothing, got %f before and %f after", before, rl.Available())
+	}
+}
+
+func TestWaitNCancelReturnsTokens(t *testing.T) {
+	rl := New(10, time.Second, 5)
+	rl.AllowN(5)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := rl.WaitN(ctx, 5); err != context.DeadlineExceeded {
+		t.Errorf("Expected WaitN() to return context.DeadlineExceeded, got %v", err)
+	}
+
+	// The abandoned reservation should not leave the bucket in debt
+	if tokens := rl.Available(); tokens < 0 {
+		t.Errorf("Expected tokens to be returned after WaitN gave up, got %f", tokens)
+	}
+
+	if err := rl.WaitN(context.Background(), 6); err == nil {
+		t.Error("Expected WaitN() to fail for more tokens than the capacity")
+	}
+}
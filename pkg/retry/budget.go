@@ -0,0 +1,139 @@
+package retry
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExhausted is returned when a retry is skipped because the shared retry
+// budget has run out. It wraps the error of the last attempt.
+var ErrBudgetExhausted = errors.New("retry budget exhausted")
+
+// budgetSlots is the number of slots the budget window is divided into.
+// Counts expire one slot at a time as the window slides.
+const budgetSlots = 10
+
+// BudgetOptions configures a Budget.
+type BudgetOptions struct {
+	// Ratio is the number of retries allowed per first attempt within the window.
+	// For example, 0.1 allows one retry for every ten requests.
+	// Default: 0.1
+	Ratio float64
+
+	// MinRetries is the number of retries allowed within the window regardless of
+	// the ratio, so that low-traffic callers can still retry.
+	// Default: 10
+	MinRetries int
+
+	// Window is the time period over which requests and retries are counted.
+	// Default: 10 seconds
+	Window time.Duration
+}
+
+// DefaultBudgetOptions returns the default retry budget options.
+func DefaultBudgetOptions() BudgetOptions {
+	return BudgetOptions{
+		Ratio:      0.1,
+		MinRetries: 10,
+		Window:     10 * time.Second,
+	}
+}
+
+// Budget caps the number of retries relative to the number of requests across every
+// call site that shares it. When a backend is down, every request fails; without a
+// budget each one is retried up to MaxAttempts times, multiplying the load on the
+// backend just when it can least take it. With a budget, retries stop once they
+// exceed the allowed ratio and errors are returned immediately.
+type Budget struct {
+	options BudgetOptions
+	slots   [budgetSlots]budgetSlot
+	mu      sync.Mutex
+}
+
+// budgetSlot counts the requests and retries started in one slice of the window.
+type budgetSlot struct {
+	start    time.Time
+	requests int
+	retries  int
+}
+
+// NewBudget creates a retry budget to share between Options.
+// Zero values fall back to the defaults.
+func NewBudget(options BudgetOptions) *Budget {
+	defaults := DefaultBudgetOptions()
+	if options.Ratio <= 0 {
+		options.Ratio = defaults.Ratio
+	}
+	if options.MinRetries < 0 {
+		options.MinRetries = 0
+	}
+	if options.Window <= 0 {
+		options.Window = defaults.Window
+	}
+
+	return &Budget{options: options}
+}
+
+// Remaining returns the number of retries currently allowed.
+func (b *Budget) Remaining() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	requests, retries := b.totals(time.Now())
+	remaining := b.allowed(requests) - retries
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// recordRequest counts a first attempt.
+func (b *Budget) recordRequest() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.slot(time.Now()).requests++
+}
+
+// tryRetry counts a retry and reports whether the budget allows it.
+func (b *Budget) tryRetry() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	requests, retries := b.totals(now)
+	if retries >= b.allowed(requests) {
+		return false
+	}
+	b.slot(now).retries++
+	return true
+}
+
+// allowed returns the number of retries allowed for the given number of requests.
+func (b *Budget) allowed(requests int) int {
+	return b.options.MinRetries + int(b.options.Ratio*float64(requests))
+}
+
+// slot returns the slot for now, resetting it if it last held an earlier slice of time.
+// This method is not thread-safe and should be called with the lock held.
+func (b *Budget) slot(now time.Time) *budgetSlot {
+	width := max(b.options.Window/budgetSlots, 1)
+	start := now.Truncate(width)
+	s := &b.slots[(start.UnixNano()/int64(width))%budgetSlots]
+	if !s.start.Equal(start) {
+		*s = budgetSlot{start: start}
+	}
+	return s
+}
+
+// totals returns the number of requests and retries within the window ending at now.
+// This method is not thread-safe and should be called with the lock held.
+func (b *Budget) totals(now time.Time) (requests, retries int) {
+	for _, s := range b.slots {
+		if now.Sub(s.start) < b.options.Window {
+			requests += s.requests
+			retries += s.retries
+		}
+	}
+	return requests, retries
+}
@@ -0,0 +1,92 @@
+package retry
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewBudget(t *testing.T) {
+	b := NewBudget(BudgetOptions{})
+
+	defaults := DefaultBudgetOptions()
+	if b.options.Ratio != defaults.Ratio {
+		t.Errorf("Expected default ratio %f, got %f", defaults.Ratio, b.options.Ratio)
+	}
+	if b.options.Window != defaults.Window {
+		t.Errorf("Expected default window %v, got %v", defaults.Window, b.options.Window)
+	}
+	if b.Remaining() != 0 {
+		t.Errorf("Expected no retries with a zero MinRetries and no requests, got %d", b.Remaining())
+	}
+}
+
+func TestBudgetRatio(t *testing.T) {
+	b := NewBudget(BudgetOptions{Ratio: 0.5, MinRetries: 1, Window: time.Minute})
+
+	for i := 0; i < 4; i++ {
+		b.recordRequest()
+	}
+	// 1 + 0.5*4
+	if b.Remaining() != 3 {
+		t.Errorf("Expected 3 retries remaining, got %d", b.Remaining())
+	}
+	for i := 0; i < 3; i++ {
+		if !b.tryRetry() {
+			t.Errorf("Expected retry %d to be allowed", i+1)
+		}
+	}
+	if b.tryRetry() {
+		t.Error("Expected retry to be refused once the budget is exhausted")
+	}
+}
+
+func TestBudgetWindowExpiry(t *testing.T) {
+	b := NewBudget(BudgetOptions{Ratio: 0.1, MinRetries: 1, Window: 50 * time.Millisecond})
+
+	if !b.tryRetry() {
+		t.Fatal("Expected the first retry to be allowed")
+	}
+	if b.tryRetry() {
+		t.Error("Expected the second retry to be refused")
+	}
+
+	// Retries expire with the window
+	time.Sleep(60 * time.Millisecond)
+	if !b.tryRetry() {
+		t.Error("Expected a retry to be allowed after the window passed")
+	}
+}
+
+func TestDo_Budget(t *testing.T) {
+	budget := NewBudget(BudgetOptions{Ratio: 0.1, MinRetries: 2, Window: time.Minute})
+	opts := Options{
+		MaxAttempts:    5,
+		InitialBackoff: time.Millisecond,
+		Budget:         budget,
+	}
+
+	// The first call site uses the whole budget
+	expectedError := errors.New("backend down")
+	attemptCount := 0
+	err := Do(opts, func() error {
+		attemptCount++
+		return expectedError
+	})
+	if attemptCount != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attemptCount)
+	}
+	if !errors.Is(err, ErrBudgetExhausted) || !errors.Is(err, expectedError) {
+		t.Errorf("Expected error to wrap ErrBudgetExhausted and the last error, got %v", err)
+	}
+
+	// Another call site sharing the budget fails fast
+	attemptCount = 0
+	Do(opts, func() error {
+		attemptCount++
+		return expectedError
+	})
+	if attemptCount != 1 {
+		t.Errorf("Expected 1 attempt with an exhausted budget, got %d", attemptCount)
+	}
+}
@@ -16,6 +16,21 @@
 //	err := retry.Do(opts, func() error {
 //		return makeNetworkRequest()
 //	})
+//
+// Errors that will never succeed, such as HTTP 4xx responses, can be returned
+// immediately instead of being retried:
+//
+//	opts.RetryIf = retry.RetryIfHTTPStatus()
+//
+// Servers that say when to come back, through Retry-After, can override the schedule:
+//
+//	opts.BackoffHint = retry.RetryAfterHint
+//
+// Operations that produce a value can use DoValue instead of capturing it in a closure:
+//
+//	resp, err := retry.DoValue(opts, func() (*http.Response, error) {
+//		return http.Get(url)
+//	})
 package retry
 
 import (
@@ -23,6 +38,9 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -55,6 +73,63 @@ type Options struct {
 	// It can be used for logging or other side effects.
 	// Optional.
 	OnRetry func(attempt int, err error)
+
+	// RetryIf reports whether an error is worth retrying. When it returns false the
+	// error is returned immediately, unwrapped, without using the remaining attempts.
+	// Optional. By default every error is retried.
+	RetryIf func(err error) bool
+
+	// Budget limits retries across every call site that shares it. When the budget
+	// is exhausted the error is returned immediately, wrapped in ErrBudgetExhausted.
+	// Optional.
+	Budget *Budget
+
+	// BackoffHint can override the backoff before a retry, for example with the delay
+	// requested by an HTTP Retry-After header. When it returns true, the returned delay
+	// is used as is, without jitter or the MaxBackoff cap; use a context deadline to
+	// bound the total wait. The exponential schedule continues for later attempts.
+	// Optional. See RetryAfterHint.
+	BackoffHint func(err error) (time.Duration, bool)
+}
+
+// StatusCoder is implemented by errors that carry an HTTP status code.
+type StatusCoder interface {
+	HTTPStatus() int
+}
+
+// RetryableHTTPStatuses are the status codes RetryIfHTTPStatus retries when called
+// without arguments: timeouts, rate limiting, and transient server errors.
+var RetryableHTTPStatuses = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryIfHTTPStatus returns a RetryIf predicate that retries errors carrying one of
+// the given HTTP status codes, or one of RetryableHTTPStatuses if none are given.
+// Errors with any other status, such as 400 or 404, are not retried. Errors without
+// a status code (see StatusCoder), such as network failures, are retried.
+func RetryIfHTTPStatus(codes ...int) func(err error) bool {
+	if len(codes) == 0 {
+		codes = RetryableHTTPStatuses
+	}
+
+	return func(err error) bool {
+		var statusErr StatusCoder
+		if !errors.As(err, &statusErr) {
+			return true
+		}
+		status := statusErr.HTTPStatus()
+		for _, code := range codes {
+			if status == code {
+				return true
+			}
+		}
+		return false
+	}
 }
 
 // DefaultOptions returns the default retry options.
@@ -102,6 +177,10 @@ func DoWithContext(ctx context.Context, opts Options, operation func(ctx context
 			// Continue with retry
 		}
 
+		if attempt == 1 && opts.Budget != nil {
+			opts.Budget.recordRequest()
+		}
+
 		err := operation(ctx)
 		if err == nil {
 			return nil
@@ -109,19 +188,33 @@ func DoWithContext(ctx context.Context, opts Options, operation func(ctx context
 
 		lastErr = err
 
+		if opts.RetryIf != nil && !opts.RetryIf(err) {
+			return err
+		}
+
 		if attempt == maxAttempts {
 			return fmt.Errorf("%w: %v", ErrMaxAttemptsReached, lastErr)
 		}
 
+		if opts.Budget != nil && !opts.Budget.tryRetry() {
+			return fmt.Errorf("%w: %w", ErrBudgetExhausted, err)
+		}
+
 		if opts.OnRetry != nil {
 			opts.OnRetry(attempt, err)
 		}
 
 		// Calculate backoff duration
 		nextBackoff := calculateBackoff(backoff, maxBackoff, opts.Jitter)
+		wait := nextBackoff
+		if opts.BackoffHint != nil {
+			if hint, ok := opts.BackoffHint(err); ok && hint >= 0 {
+				wait = hint
+			}
+		}
 
 		// Wait for backoff duration or until context is canceled
-		timer := time.NewTimer(nextBackoff)
+		timer := time.NewTimer(wait)
 		select {
 		case <-ctx.Done():
 			timer.Stop()
@@ -136,6 +229,75 @@ func DoWithContext(ctx context.Context, opts Options, operation func(ctx context
 	return fmt.Errorf("%w: %v", ErrMaxAttemptsReached, lastErr)
 }
 
+// RetryAfterer is implemented by errors that carry a delay requested by the server,
+// such as the Retry-After header of a 429 or 503 response.
+type RetryAfterer interface {
+	RetryDelay() (time.Duration, bool)
+}
+
+// RetryAfterHint is a BackoffHint that waits for the delay carried by errors
+// implementing RetryAfterer, and leaves other errors to the backoff schedule.
+func RetryAfterHint(err error) (time.Duration, bool) {
+	var retryAfter RetryAfterer
+	if !errors.As(err, &retryAfter) {
+		return 0, false
+	}
+	return retryAfter.RetryDelay()
+}
+
+// ParseRetryAfter parses the value of a Retry-After header, which is either a number
+// of seconds or an HTTP date. It returns false if the value is empty or invalid.
+// Dates in the past yield a zero delay.
+func ParseRetryAfter(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if delay := time.Until(date); delay > 0 {
+		return delay, true
+	}
+	return 0, true
+}
+
+// DoValue retries an operation that returns a value, and returns the value of the
+// first successful attempt. On failure it returns the zero value and the error.
+func DoValue[T any](opts Options, operation func() (T, error)) (T, error) {
+	return DoValueWithContext(context.Background(), opts, func(ctx context.Context) (T, error) {
+		return operation()
+	})
+}
+
+// DoValueWithContext is DoValue with context support.
+// The operation can be canceled via the context.
+func DoValueWithContext[T any](ctx context.Context, opts Options, operation func(ctx context.Context) (T, error)) (T, error) {
+	var result T
+	err := DoWithContext(ctx, opts, func(ctx context.Context) error {
+		value, err := operation(ctx)
+		if err != nil {
+			return err
+		}
+		result = value
+		return nil
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return result, nil
+}
+
 // calculateBackoff calculates the next backoff duration with optional jitter.
 func calculateBackoff(currentBackoff, maxBackoff time.Duration, jitter bool) time.Duration {
 	nextBackoff := currentBackoff
@@ -3,6 +3,8 @@ package retry
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
 	"testing"
 	"time"
 )
@@ -239,3 +241,178 @@ func TestAddJitter(t *testing.T) {
 		}
 	}
 }
+
+// statusError is a test error carrying an HTTP status code.
+type statusError int
+
+func (e statusError) Error() string   { return http.StatusText(int(e)) }
+func (e statusError) HTTPStatus() int { return int(e) }
+
+func TestDo_RetryIf(t *testing.T) {
+	opts := Options{
+		MaxAttempts:    5,
+		InitialBackoff: time.Millisecond,
+		RetryIf:        RetryIfHTTPStatus(),
+	}
+
+	// A non-retryable error aborts on the first attempt
+	attemptCount := 0
+	err := Do(opts, func() error {
+		attemptCount++
+		return fmt.Errorf("request failed: %w", statusError(http.StatusBadRequest))
+	})
+	if attemptCount != 1 {
+		t.Errorf("Expected 1 attempt, got %d", attemptCount)
+	}
+	var statusErr statusError
+	if !errors.As(err, &statusErr) || statusErr != http.StatusBadRequest {
+		t.Errorf("Expected the original error to be returned, got %v", err)
+	}
+	if errors.Is(err, ErrMaxAttemptsReached) {
+		t.Error("Expected error not to wrap ErrMaxAttemptsReached")
+	}
+
+	// Retryable errors use every attempt
+	attemptCount = 0
+	err = Do(opts, func() error {
+		attemptCount++
+		return statusError(http.StatusServiceUnavailable)
+	})
+	if attemptCount != 5 {
+		t.Errorf("Expected 5 attempts, got %d", attemptCount)
+	}
+	if !errors.Is(err, ErrMaxAttemptsReached) {
+		t.Errorf("Expected error to wrap ErrMaxAttemptsReached, got %v", err)
+	}
+}
+
+func TestRetryIfHTTPStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		retryIf  func(error) bool
+		err      error
+		expected bool
+	}{
+		{"default retryable", RetryIfHTTPStatus(), statusError(http.StatusTooManyRequests), true},
+		{"default not retryable", RetryIfHTTPStatus(), statusError(http.StatusNotFound), false},
+		{"no status code", RetryIfHTTPStatus(), errors.New("connection refused"), true},
+		{"custom codes", RetryIfHTTPStatus(http.StatusConflict), statusError(http.StatusConflict), true},
+		{"custom codes exclude defaults", RetryIfHTTPStatus(http.StatusConflict), statusError(http.StatusServiceUnavailable), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.retryIf(tt.err); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDoValue(t *testing.T) {
+	opts := Options{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+	}
+
+	attemptCount := 0
+	value, err := DoValue(opts, func() (string, error) {
+		attemptCount++
+		if attemptCount < 2 {
+			return "partial", errors.New("temporary error")
+		}
+		return "result", nil
+	})
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if value != "result" {
+		t.Errorf("Expected value 'result', got %q", value)
+	}
+
+	// Failed attempts do not leak their values
+	value, err = DoValue(opts, func() (string, error) {
+		return "partial", errors.New("persistent error")
+	})
+	if !errors.Is(err, ErrMaxAttemptsReached) {
+		t.Errorf("Expected error to wrap ErrMaxAttemptsReached, got %v", err)
+	}
+	if value != "" {
+		t.Errorf("Expected zero value on failure, got %q", value)
+	}
+}
+
+func TestDoValueWithContext_Cancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	value, err := DoValueWithContext(ctx, DefaultOptions(), func(ctx context.Context) (int, error) {
+		return 42, nil
+	})
+	if err == nil || !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if value != 0 {
+		t.Errorf("Expected zero value on cancellation, got %d", value)
+	}
+}
+
+// retryAfterError is a test error carrying a server-requested delay.
+type retryAfterError time.Duration
+
+func (e retryAfterError) Error() string                     { return "rate limited" }
+func (e retryAfterError) RetryDelay() (time.Duration, bool) { return time.Duration(e), true }
+
+func TestDo_BackoffHint(t *testing.T) {
+	opts := Options{
+		MaxAttempts:    2,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Second,
+		BackoffHint:    RetryAfterHint,
+	}
+
+	// The hint replaces the one second backoff
+	start := time.Now()
+	attemptCount := 0
+	err := Do(opts, func() error {
+		attemptCount++
+		if attemptCount == 1 {
+			return fmt.Errorf("request failed: %w", retryAfterError(20*time.Millisecond))
+		}
+		return nil
+	})
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if elapsed < 20*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("Expected to wait about 20ms as hinted, waited %v", elapsed)
+	}
+
+	// Errors without a hint follow the backoff schedule
+	if _, ok := RetryAfterHint(errors.New("plain error")); ok {
+		t.Error("Expected no hint for an error without a delay")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if delay, ok := ParseRetryAfter("120"); !ok || delay != 2*time.Minute {
+		t.Errorf("Expected 2m, got %v (ok=%v)", delay, ok)
+	}
+
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if delay, ok := ParseRetryAfter(date); !ok || delay < 59*time.Minute || delay > time.Hour {
+		t.Errorf("Expected about 1h for an HTTP date, got %v (ok=%v)", delay, ok)
+	}
+
+	past := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	if delay, ok := ParseRetryAfter(past); !ok || delay != 0 {
+		t.Errorf("Expected zero delay for a past date, got %v (ok=%v)", delay, ok)
+	}
+
+	for _, value := range []string{"", "-1", "soon"} {
+		if _, ok := ParseRetryAfter(value); ok {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AWSOptions configures an AWSSecretsManagerProvider.
+type AWSOptions struct {
+	// Region is the AWS region of the secrets.
+	// Default: the AWS_REGION or AWS_DEFAULT_REGION environment variable
+	Region string
+
+	// AccessKeyID, SecretAccessKey, and SessionToken are the credentials used to
+	// sign requests. SessionToken is only needed for temporary credentials.
+	// Default: the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN
+	// environment variables
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// Endpoint overrides the Secrets Manager endpoint, for example for a VPC endpoint.
+	// Default: https://secretsmanager.<region>.amazonaws.com
+	Endpoint string
+
+	// HTTPClient is used for requests to Secrets Manager.
+	// Default: a client with a 10 second timeout
+	HTTPClient *http.Client
+}
+
+// DefaultAWSOptions returns the default AWS options, reading the region and
+// credentials from the standard AWS environment variables.
+func DefaultAWSOptions() AWSOptions {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	return AWSOptions{
+		Region:          region,
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		HTTPClient:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// AWSSecretsManagerProvider reads secrets from AWS Secrets Manager. Names are secret
+// IDs or ARNs. A name of the form "id#key" treats the secret as a JSON object and
+// returns the field key, matching how Secrets Manager stores key/value secrets.
+type AWSSecretsManagerProvider struct {
+	options AWSOptions
+}
+
+// NewAWSSecretsManagerProvider creates an AWSSecretsManagerProvider with the given
+// options. Zero values fall back to the defaults.
+func NewAWSSecretsManagerProvider(options AWSOptions) *AWSSecretsManagerProvider {
+	defaults := DefaultAWSOptions()
+	if options.Region == "" {
+		options.Region = defaults.Region
+	}
+	if options.AccessKeyID == "" && options.SecretAccessKey == "" {
+		options.AccessKeyID = defaults.AccessKeyID
+		options.SecretAccessKey = defaults.SecretAccessKey
+		options.SessionToken = defaults.SessionToken
+	}
+	if options.Endpoint == "" {
+		options.Endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", options.Region)
+	}
+	if options.HTTPClient == nil {
+		options.HTTPClient = defaults.HTTPClient
+	}
+
+	return &AWSSecretsManagerProvider{options: options}
+}
+
+// GetSecret reads the secret named by "id" or "id#key" from Secrets Manager.
+func (p *AWSSecretsManagerProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	if p.options.Region == "" || p.options.AccessKeyID == "" || p.options.SecretAccessKey == "" {
+		return "", fmt.Errorf("AWS region and credentials are not configured")
+	}
+	id, key, hasKey := strings.Cut(name, "#")
+
+	payload, err := json.Marshal(map[string]string{"SecretId": id})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.options.Endpoint+"/", bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("failed to create Secrets Manager request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	if p.options.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", p.options.SessionToken)
+	}
+	signV4(req, payload, p.options.AccessKeyID, p.options.SecretAccessKey, p.options.Region, "secretsmanager", time.Now())
+
+	res, err := p.options.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret from Secrets Manager: %w", err)
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, 1024*1024))
+	if err != nil {
+		return "", fmt.Errorf("failed to read Secrets Manager response: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		var errBody struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &errBody)
+		if strings.HasSuffix(errBody.Type, "ResourceNotFoundException") {
+			return "", fmt.Errorf("%w: %s", ErrSecretNotFound, id)
+		}
+		return "", fmt.Errorf("failed to read secret from Secrets Manager: server returned %d: %s %s", res.StatusCode, errBody.Type, errBody.Message)
+	}
+
+	var body struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return "", fmt.Errorf("failed to parse Secrets Manager response: %w", err)
+	}
+	if !hasKey {
+		return body.SecretString, nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(body.SecretString), &fields); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object: %w", id, err)
+	}
+	value, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("%w: secret %s has no field %s", ErrSecretNotFound, id, key)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+// signV4 signs req with AWS Signature Version 4, covering the host and every header
+// already set on the request.
+func signV4(req *http.Request, payload []byte, accessKeyID, secretAccessKey, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(payload)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyID, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
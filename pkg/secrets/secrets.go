@@ -0,0 +1,168 @@
+// Package secrets provides a common interface for reading secrets such as JWT and
+// HMAC keys from the environment, files, HashiCorp Vault, or AWS Secrets Manager,
+// so they do not have to be passed on the command line or stored in config files.
+//
+// Example usage:
+//
+//	// Read secrets from files mounted by the orchestrator, caching them for a minute
+//	provider := secrets.NewCachedProvider(secrets.FileProvider{Dir: "/run/secrets"}, time.Minute)
+//	jwtSecret, err := provider.GetSecret(ctx, "jwt")
+//
+//	// Or pick the provider from a reference such as "vault:gollama#jwt"
+//	provider := secrets.SchemeProvider{
+//		"env":   secrets.EnvProvider{},
+//		"file":  secrets.FileProvider{},
+//		"vault": secrets.NewVaultProvider(secrets.VaultOptions{}),
+//		"aws":   secrets.NewAWSSecretsManagerProvider(secrets.AWSOptions{}),
+//	}
+//	jwtSecret, err := provider.GetSecret(ctx, "vault:gollama#jwt")
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Version represents the current package version following semantic versioning.
+const Version = "1.0.0"
+
+// ErrSecretNotFound is returned when a provider has no secret with the requested name.
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretProvider reads secrets by name. Implementations must be safe for concurrent use.
+type SecretProvider interface {
+	GetSecret(ctx context.Context, name string) (string, error)
+}
+
+// EnvProvider reads secrets from environment variables.
+type EnvProvider struct {
+	// Prefix is prepended to the secret name to form the variable name. Optional.
+	Prefix string
+}
+
+// GetSecret returns the value of the environment variable Prefix+name.
+func (p EnvProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(p.Prefix + name)
+	if !ok {
+		return "", fmt.Errorf("%w: environment variable %s is not set", ErrSecretNotFound, p.Prefix+name)
+	}
+	return value, nil
+}
+
+// FileProvider reads each secret from a file, such as the files mounted by Docker
+// or Kubernetes secrets. A single trailing newline is removed.
+type FileProvider struct {
+	// Dir is the directory holding the secret files. When empty, names are paths.
+	Dir string
+}
+
+// GetSecret returns the contents of the file name in Dir.
+func (p FileProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	path := name
+	if p.Dir != "" {
+		// Keep names from escaping the secrets directory
+		if name != filepath.Base(name) {
+			return "", fmt.Errorf("invalid secret name %q", name)
+		}
+		path = filepath.Join(p.Dir, name)
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, path)
+	} else if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+
+	value := strings.TrimSuffix(string(data), "\n")
+	return strings.TrimSuffix(value, "\r"), nil
+}
+
+// StaticProvider serves secrets from a fixed map. It is useful in tests and when
+// secrets have already been loaded by other means.
+type StaticProvider map[string]string
+
+// GetSecret returns the secret stored under name.
+func (p StaticProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	value, ok := p[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+	}
+	return value, nil
+}
+
+// SchemeProvider dispatches references of the form "scheme:name" to the provider
+// registered for the scheme, for example "env:GOLLAMA_JWT_SECRET" or "vault:gollama#jwt".
+type SchemeProvider map[string]SecretProvider
+
+// GetSecret resolves a "scheme:name" reference.
+func (p SchemeProvider) GetSecret(ctx context.Context, ref string) (string, error) {
+	scheme, name, ok := strings.Cut(ref, ":")
+	if !ok {
+		return "", fmt.Errorf("invalid secret reference %q: expected scheme:name", ref)
+	}
+	provider, ok := p[scheme]
+	if !ok {
+		return "", fmt.Errorf("unsupported secret scheme: %s", scheme)
+	}
+	return provider.GetSecret(ctx, name)
+}
+
+// CachedProvider caches the secrets read from another provider, so remote stores are
+// not queried on every use while rotated secrets are still picked up after the TTL.
+// Errors are not cached.
+type CachedProvider struct {
+	provider SecretProvider
+	ttl      time.Duration
+	entries  map[string]cachedSecret
+	mu       sync.Mutex
+}
+
+// cachedSecret is a secret value and when it was read.
+type cachedSecret struct {
+	value     string
+	fetchedAt time.Time
+}
+
+// NewCachedProvider creates a CachedProvider that keeps secrets from provider for ttl.
+func NewCachedProvider(provider SecretProvider, ttl time.Duration) *CachedProvider {
+	return &CachedProvider{
+		provider: provider,
+		ttl:      ttl,
+		entries:  make(map[string]cachedSecret),
+	}
+}
+
+// GetSecret returns the cached secret, reading it from the underlying provider if it
+// is missing or older than the TTL.
+func (p *CachedProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	p.mu.Lock()
+	entry, ok := p.entries[name]
+	p.mu.Unlock()
+	if ok && time.Since(entry.fetchedAt) < p.ttl {
+		return entry.value, nil
+	}
+
+	value, err := p.provider.GetSecret(ctx, name)
+	if err != nil {
+		return "", err
+	}
+
+	p.mu.Lock()
+	p.entries[name] = cachedSecret{value: value, fetchedAt: time.Now()}
+	p.mu.Unlock()
+	return value, nil
+}
+
+// Invalidate drops the cached value of name so the next read fetches it again.
+func (p *CachedProvider) Invalidate(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.entries, name)
+}
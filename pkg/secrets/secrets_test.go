@@ -0,0 +1,113 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEnvProvider(t *testing.T) {
+	t.Setenv("GOLLAMA_TEST_SECRET", "env-value")
+	provider := EnvProvider{Prefix: "GOLLAMA_"}
+
+	value, err := provider.GetSecret(context.Background(), "TEST_SECRET")
+	if err != nil {
+		t.Fatalf("GetSecret failed: %v", err)
+	}
+	if value != "env-value" {
+		t.Errorf("Expected env-value, got %q", value)
+	}
+
+	if _, err := provider.GetSecret(context.Background(), "MISSING"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected ErrSecretNotFound, got %v", err)
+	}
+}
+
+func TestFileProvider(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "jwt"), []byte("file-value\n"), 0600); err != nil {
+		t.Fatalf("Failed to write secret file: %v", err)
+	}
+	provider := FileProvider{Dir: dir}
+
+	value, err := provider.GetSecret(context.Background(), "jwt")
+	if err != nil {
+		t.Fatalf("GetSecret failed: %v", err)
+	}
+	if value != "file-value" {
+		t.Errorf("Expected the trailing newline to be removed, got %q", value)
+	}
+
+	if _, err := provider.GetSecret(context.Background(), "missing"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected ErrSecretNotFound, got %v", err)
+	}
+	if _, err := provider.GetSecret(context.Background(), "../jwt"); err == nil {
+		t.Error("Expected error for a name outside the secrets directory")
+	}
+}
+
+func TestSchemeProvider(t *testing.T) {
+	provider := SchemeProvider{
+		"static": StaticProvider{"jwt": "static-value"},
+	}
+
+	value, err := provider.GetSecret(context.Background(), "static:jwt")
+	if err != nil {
+		t.Fatalf("GetSecret failed: %v", err)
+	}
+	if value != "static-value" {
+		t.Errorf("Expected static-value, got %q", value)
+	}
+
+	if _, err := provider.GetSecret(context.Background(), "jwt"); err == nil {
+		t.Error("Expected error for a reference without a scheme")
+	}
+	if _, err := provider.GetSecret(context.Background(), "vault:jwt"); err == nil {
+		t.Error("Expected error for an unregistered scheme")
+	}
+}
+
+// countingProvider counts reads of an underlying StaticProvider.
+type countingProvider struct {
+	StaticProvider
+	reads int
+}
+
+func (p *countingProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	p.reads++
+	return p.StaticProvider.GetSecret(ctx, name)
+}
+
+func TestCachedProvider(t *testing.T) {
+	underlying := &countingProvider{StaticProvider: StaticProvider{"jwt": "v1"}}
+	provider := NewCachedProvider(underlying, 50*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		if value, _ := provider.GetSecret(context.Background(), "jwt"); value != "v1" {
+			t.Fatalf("Expected v1, got %q", value)
+		}
+	}
+	if underlying.reads != 1 {
+		t.Errorf("Expected 1 read, got %d", underlying.reads)
+	}
+
+	// A rotated secret is picked up once the TTL passes
+	underlying.StaticProvider["jwt"] = "v2"
+	time.Sleep(60 * time.Millisecond)
+	if value, _ := provider.GetSecret(context.Background(), "jwt"); value != "v2" {
+		t.Errorf("Expected v2 after the TTL, got %q", value)
+	}
+
+	// Errors are not cached
+	if _, err := provider.GetSecret(context.Background(), "missing"); err == nil {
+		t.Error("Expected error for a missing secret")
+	}
+	reads := underlying.reads
+	provider.GetSecret(context.Background(), "missing")
+	if underlying.reads != reads+1 {
+		t.Error("Expected a failed read to be retried")
+	}
+}
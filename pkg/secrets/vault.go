@@ -0,0 +1,131 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// VaultOptions configures a VaultProvider.
+type VaultOptions struct {
+	// Address is the Vault server address.
+	// Default: the VAULT_ADDR environment variable
+	Address string
+
+	// Token authenticates to Vault.
+	// Default: the VAULT_TOKEN environment variable
+	Token string
+
+	// Namespace is the Vault Enterprise namespace. Optional.
+	// Default: the VAULT_NAMESPACE environment variable
+	Namespace string
+
+	// Mount is the path where the KV version 2 secrets engine is mounted.
+	// Default: "secret"
+	Mount string
+
+	// HTTPClient is used for requests to Vault.
+	// Default: a client with a 10 second timeout
+	HTTPClient *http.Client
+}
+
+// DefaultVaultOptions returns the default Vault options, reading the address, token,
+// and namespace from the standard Vault environment variables.
+func DefaultVaultOptions() VaultOptions {
+	return VaultOptions{
+		Address:    os.Getenv("VAULT_ADDR"),
+		Token:      os.Getenv("VAULT_TOKEN"),
+		Namespace:  os.Getenv("VAULT_NAMESPACE"),
+		Mount:      "secret",
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// VaultProvider reads secrets from a HashiCorp Vault KV version 2 secrets engine.
+// Names have the form "path#key", where key selects a field of the secret at path.
+// Without "#key" the field named "value" is used.
+type VaultProvider struct {
+	options VaultOptions
+}
+
+// NewVaultProvider creates a VaultProvider with the given options.
+// Zero values fall back to the defaults.
+func NewVaultProvider(options VaultOptions) *VaultProvider {
+	defaults := DefaultVaultOptions()
+	if options.Address == "" {
+		options.Address = defaults.Address
+	}
+	if options.Token == "" {
+		options.Token = defaults.Token
+	}
+	if options.Namespace == "" {
+		options.Namespace = defaults.Namespace
+	}
+	if options.Mount == "" {
+		options.Mount = defaults.Mount
+	}
+	if options.HTTPClient == nil {
+		options.HTTPClient = defaults.HTTPClient
+	}
+	options.Address = strings.TrimRight(options.Address, "/")
+	options.Mount = strings.Trim(options.Mount, "/")
+
+	return &VaultProvider{options: options}
+}
+
+// GetSecret reads the field named by "path#key" from Vault.
+func (p *VaultProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	if p.options.Address == "" {
+		return "", fmt.Errorf("vault address is not configured")
+	}
+	path, key, ok := strings.Cut(name, "#")
+	if !ok {
+		key = "value"
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/data/%s", p.options.Address, p.options.Mount, strings.Trim(path, "/"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", p.options.Token)
+	if p.options.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.options.Namespace)
+	}
+
+	res, err := p.options.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret from vault: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("%w: vault path %s", ErrSecretNotFound, path)
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to read secret from vault: server returned %d", res.StatusCode)
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1024*1024)).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to parse vault response: %w", err)
+	}
+
+	value, ok := body.Data.Data[key]
+	if !ok {
+		return "", fmt.Errorf("%w: vault path %s has no field %s", ErrSecretNotFound, path, key)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
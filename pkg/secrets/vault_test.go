@@ -0,0 +1,55 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVaultProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "test-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/v1/kv/data/gollama" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"jwt":"vault-jwt","value":"vault-default"},"metadata":{"version":3}}}`))
+	}))
+	defer server.Close()
+
+	provider := NewVaultProvider(VaultOptions{Address: server.URL, Token: "test-token", Mount: "kv"})
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"gollama#jwt", "vault-jwt"},
+		{"gollama", "vault-default"},
+	}
+	for _, tt := range tests {
+		value, err := provider.GetSecret(context.Background(), tt.name)
+		if err != nil {
+			t.Fatalf("GetSecret(%q) failed: %v", tt.name, err)
+		}
+		if value != tt.expected {
+			t.Errorf("GetSecret(%q): expected %q, got %q", tt.name, tt.expected, value)
+		}
+	}
+
+	if _, err := provider.GetSecret(context.Background(), "other#jwt"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected ErrSecretNotFound for a missing path, got %v", err)
+	}
+	if _, err := provider.GetSecret(context.Background(), "gollama#hmac"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected ErrSecretNotFound for a missing field, got %v", err)
+	}
+
+	provider = NewVaultProvider(VaultOptions{Address: server.URL, Token: "wrong", Mount: "kv"})
+	if _, err := provider.GetSecret(context.Background(), "gollama#jwt"); err == nil {
+		t.Error("Expected error for a rejected token")
+	}
+}
@@ -0,0 +1,254 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is a Server-Sent Event.
+type Event struct {
+	// ID sets the client's last event ID, which it sends back in the Last-Event-ID
+	// header when it reconnects. Optional.
+	ID string
+
+	// Event is the event type, which browsers dispatch to listeners of that name.
+	// Optional. By default browsers dispatch a "message" event.
+	Event string
+
+	// Data is the payload. Multi-line data is split across data fields and joined
+	// again by the client.
+	Data string
+}
+
+// SSEOptions configures a Server-Sent Events stream.
+type SSEOptions struct {
+	// HeartbeatInterval is how long the stream may be idle before a comment line is
+	// sent to keep proxies from closing the connection. A negative value disables it.
+	// Default: 15 seconds
+	HeartbeatInterval time.Duration
+
+	// BufferSize is the number of events buffered before Send blocks.
+	// Default: 16
+	BufferSize int
+
+	// WriteTimeout is the time allowed to write each event to the client. A client
+	// that reads slower than this is disconnected.
+	// Default: 10 seconds
+	WriteTimeout time.Duration
+
+	// Retry is the reconnection delay advertised to clients. Optional. By default
+	// browsers choose their own.
+	Retry time.Duration
+}
+
+// DefaultSSEOptions returns the default Server-Sent Events options.
+func DefaultSSEOptions() SSEOptions {
+	return SSEOptions{
+		HeartbeatInterval: 15 * time.Second,
+		BufferSize:        16,
+		WriteTimeout:      10 * time.Second,
+	}
+}
+
+// SSE is a Server-Sent Events stream to one client.
+type SSE struct {
+	options SSEOptions
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+
+	events    chan Event
+	closing   chan struct{} // Closed by Close
+	done      chan struct{} // Closed when the writer goroutine exits
+	closeOnce sync.Once
+	err       error // Why the stream ended, set before done is closed
+}
+
+// NewSSE starts a Server-Sent Events response on w and returns a stream that sends
+// events to it until Close is called or r's context is canceled. The handler must call
+// Close before returning. Zero option values fall back to the defaults.
+func NewSSE(w http.ResponseWriter, r *http.Request, options SSEOptions) (*SSE, error) {
+	defaults := DefaultSSEOptions()
+	if options.HeartbeatInterval == 0 {
+		options.HeartbeatInterval = defaults.HeartbeatInterval
+	}
+	if options.BufferSize <= 0 {
+		options.BufferSize = defaults.BufferSize
+	}
+	if options.WriteTimeout <= 0 {
+		options.WriteTimeout = defaults.WriteTimeout
+	}
+
+	s := &SSE{
+		options: options,
+		w:       w,
+		rc:      http.NewResponseController(w),
+		events:  make(chan Event, options.BufferSize),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no") // Keep nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+
+	var preamble string
+	if options.Retry > 0 {
+		preamble = "retry: " + strconv.FormatInt(options.Retry.Milliseconds(), 10) + "\n\n"
+	}
+	if err := s.write(preamble); err != nil {
+		return nil, fmt.Errorf("failed to start event stream: %w", err)
+	}
+
+	go s.run(r.Context())
+	return s, nil
+}
+
+// Send queues an event, blocking while the buffer is full until there is room, ctx is
+// done, or the stream ends. It returns ErrClosed once the stream has ended.
+func (s *SSE) Send(ctx context.Context, event Event) error {
+	select {
+	case <-s.closing:
+		return ErrClosed
+	case <-s.done:
+		return ErrClosed
+	default:
+	}
+
+	select {
+	case s.events <- event:
+		return nil
+	case <-s.closing:
+		return ErrClosed
+	case <-s.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SendJSON queues an event whose data is v encoded as JSON.
+func (s *SSE) SendJSON(ctx context.Context, event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	return s.Send(ctx, Event{Event: event, Data: string(data)})
+}
+
+// Done returns a channel that is closed when the stream has ended.
+func (s *SSE) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns why the stream ended: nil after Close, ErrClosed if the request's context
+// was canceled, or the error writing to the client. It returns nil while the stream is open.
+func (s *SSE) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Close sends the buffered events, ends the stream, and waits for its goroutine to
+// exit. It returns the error that ended the stream, if it ended before Close.
+func (s *SSE) Close() error {
+	s.closeOnce.Do(func() { close(s.closing) })
+	<-s.done
+	return s.err
+}
+
+// run writes events and heartbeats until the stream is closed or fails.
+func (s *SSE) run(ctx context.Context) {
+	defer close(s.done)
+
+	var heartbeat <-chan time.Time
+	if s.options.HeartbeatInterval > 0 {
+		ticker := time.NewTicker(s.options.HeartbeatInterval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case event := <-s.events:
+			if s.err = s.write(formatEvent(event)); s.err != nil {
+				return
+			}
+		case <-heartbeat:
+			if s.err = s.write(": heartbeat\n\n"); s.err != nil {
+				return
+			}
+		case <-s.closing:
+			s.err = s.drain()
+			return
+		case <-ctx.Done():
+			s.err = ErrClosed
+			return
+		}
+	}
+}
+
+// drain writes the events still buffered when the stream is closed.
+func (s *SSE) drain() error {
+	for {
+		select {
+		case event := <-s.events:
+			if err := s.write(formatEvent(event)); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// write writes and flushes text within the write timeout.
+func (s *SSE) write(text string) error {
+	// Not every ResponseWriter supports deadlines, such as httptest.ResponseRecorder
+	if err := s.rc.SetWriteDeadline(time.Now().Add(s.options.WriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return fmt.Errorf("failed to set write deadline: %w", err)
+	}
+	if text != "" {
+		if _, err := s.w.Write([]byte(text)); err != nil {
+			return fmt.Errorf("failed to write event: %w", err)
+		}
+	}
+	if err := s.rc.Flush(); err != nil {
+		return fmt.Errorf("failed to flush event: %w", err)
+	}
+	return nil
+}
+
+// formatEvent encodes an event in the text/event-stream format. Data is written as one
+// data field per line, splitting on \r\n, \r and \n, since clients end a field at any of them.
+func formatEvent(event Event) string {
+	var b strings.Builder
+	if event.ID != "" {
+		b.WriteString("id: " + singleLine(event.ID) + "\n")
+	}
+	if event.Event != "" {
+		b.WriteString("event: " + singleLine(event.Event) + "\n")
+	}
+	data := strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(event.Data)
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// singleLine removes line breaks, which would end a field early.
+func singleLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -0,0 +1,150 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSE(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		events, err := NewSSE(w, r, SSEOptions{Retry: 2 * time.Second})
+		if err != nil {
+			t.Errorf("Failed to start stream: %v", err)
+			return
+		}
+		events.Send(r.Context(), Event{ID: "1", Event: "token", Data: "Hello"})
+		events.Send(r.Context(), Event{Data: "two\nlines"})
+		events.Send(r.Context(), Event{Data: "carriage\rreturns\r\nand\nnewlines"})
+		events.SendJSON(r.Context(), "done", map[string]int{"tokens": 2})
+		if err := events.Close(); err != nil {
+			t.Errorf("Expected no error closing, got %v", err)
+		}
+		if err := events.Send(r.Context(), Event{Data: "late"}); !errors.Is(err, ErrClosed) {
+			t.Errorf("Expected ErrClosed after Close, got %v", err)
+		}
+	}))
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer res.Body.Close()
+	if contentType := res.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Expected Content-Type text/event-stream, got %s", contentType)
+	}
+
+	body, _ := io.ReadAll(res.Body)
+	want := "retry: 2000\n\n" +
+		"id: 1\nevent: token\ndata: Hello\n\n" +
+		"data: two\ndata: lines\n\n" +
+		"data: carriage\ndata: returns\ndata: and\ndata: newlines\n\n" +
+		"event: done\ndata: {\"tokens\":2}\n\n"
+	if string(body) != want {
+		t.Errorf("Expected stream %q, got %q", want, body)
+	}
+}
+
+func TestSSEHeartbeat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		events, err := NewSSE(w, r, SSEOptions{HeartbeatInterval: 10 * time.Millisecond})
+		if err != nil {
+			t.Errorf("Failed to start stream: %v", err)
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+		events.Close()
+	}))
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	if !strings.Contains(string(body), ": heartbeat\n\n") {
+		t.Errorf("Expected heartbeats on an idle stream, got %q", body)
+	}
+}
+
+func TestSSEClientDisconnect(t *testing.T) {
+	ended := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		events, err := NewSSE(w, r, DefaultSSEOptions())
+		if err != nil {
+			t.Errorf("Failed to start stream: %v", err)
+			return
+		}
+		<-events.Done()
+		ended <- events.Close()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	cancel()
+	res.Body.Close()
+
+	select {
+	case err := <-ended:
+		if !errors.Is(err, ErrClosed) {
+			t.Errorf("Expected ErrClosed after the client went away, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the stream to end when the client disconnected")
+	}
+}
+
+// blockingWriter is a ResponseWriter whose writes block until release is closed.
+type blockingWriter struct {
+	*httptest.ResponseRecorder
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(b []byte) (int, error) {
+	<-w.release
+	return w.ResponseRecorder.Write(b)
+}
+
+func TestSSEBackpressure(t *testing.T) {
+	w := &blockingWriter{ResponseRecorder: httptest.NewRecorder(), release: make(chan struct{})}
+	close(w.release) // Let the stream start
+	events, err := NewSSE(w, httptest.NewRequest(http.MethodGet, "/", nil), SSEOptions{BufferSize: 1, HeartbeatInterval: -1})
+	if err != nil {
+		t.Fatalf("Failed to start stream: %v", err)
+	}
+	w.release = make(chan struct{})
+
+	// The first event is taken by the stuck writer and the second fills the buffer
+	for i := 0; i < 2; i++ {
+		if err := events.Send(context.Background(), Event{Data: "token"}); err != nil {
+			t.Fatalf("Expected event %d to be queued, got %v", i, err)
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := events.Send(ctx, Event{Data: "token"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected Send to block while the buffer is full, got %v", err)
+	}
+
+	close(w.release)
+	if err := events.Close(); err != nil {
+		t.Fatalf("Expected no error closing, got %v", err)
+	}
+	if n := strings.Count(w.Body.String(), "data: token"); n != 2 {
+		t.Errorf("Expected the 2 queued events to be written, got %d", n)
+	}
+}
@@ -0,0 +1,39 @@
+// Package stream relays streamed responses, such as generated model tokens, to browsers
+// over Server-Sent Events and WebSockets.
+//
+// Both kinds of stream send messages from a goroutine of their own, so a handler can keep
+// producing while a slow client catches up. Messages wait in a bounded buffer; once it is
+// full, Send blocks, pushing back on the producer instead of growing without limit. A
+// heartbeat keeps idle connections from being dropped by proxies, a client that stops
+// reading is disconnected after a write timeout, and Close sends what is buffered before
+// ending the stream. Streams end by themselves when the request's context is canceled,
+// so a timeout middleware or a server whose BaseContext is canceled on shutdown ends them
+// cleanly. Streams work behind the middleware of pkg/middleware and pkg/observability,
+// whose response writers can be unwrapped to flush and hijack the connection.
+//
+// Example usage:
+//
+//	func generate(w http.ResponseWriter, r *http.Request) {
+//		events, err := stream.NewSSE(w, r, stream.DefaultSSEOptions())
+//		if err != nil {
+//			http.Error(w, err.Error(), http.StatusInternalServerError)
+//			return
+//		}
+//		defer events.Close()
+//
+//		for token := range tokens {
+//			if err := events.Send(r.Context(), stream.Event{Event: "token", Data: token}); err != nil {
+//				return // The client went away
+//			}
+//		}
+//	}
+package stream
+
+import "errors"
+
+// Version represents the current package version following semantic versioning.
+const Version = "1.0.0"
+
+// ErrClosed is returned when sending to or receiving from a stream that has ended,
+// because it was closed, the client disconnected, or the request's context was canceled.
+var ErrClosed = errors.New("stream closed")
@@ -0,0 +1,309 @@
+package stream
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocketOptions configures a WebSocket connection.
+type WebSocketOptions struct {
+	// HeartbeatInterval is the interval between pings sent to the client. A negative
+	// value disables them.
+	// Default: 30 seconds
+	HeartbeatInterval time.Duration
+
+	// PongTimeout is how long the connection may go without a message or pong from the
+	// client before it is considered gone. A negative value disables the timeout.
+	// Default: twice HeartbeatInterval
+	PongTimeout time.Duration
+
+	// BufferSize is the number of outgoing messages buffered before Send blocks, and
+	// of incoming messages buffered before the connection stops reading from the client.
+	// Default: 16
+	BufferSize int
+
+	// WriteTimeout is the time allowed to write each message to the client. A client
+	// that reads slower than this is disconnected.
+	// Default: 10 seconds
+	WriteTimeout time.Duration
+
+	// MaxMessageBytes is the largest message accepted from the client. Larger messages
+	// close the connection.
+	// Default: 1MB
+	MaxMessageBytes int64
+
+	// CheckOrigin reports whether a browser on the request's Origin may connect.
+	// Default: only pages served from the same host
+	CheckOrigin func(r *http.Request) bool
+
+	// Subprotocols lists the subprotocols the server supports, in order of
+	// preference. Optional.
+	Subprotocols []string
+}
+
+// DefaultWebSocketOptions returns the default WebSocket options.
+func DefaultWebSocketOptions() WebSocketOptions {
+	return WebSocketOptions{
+		HeartbeatInterval: 30 * time.Second,
+		PongTimeout:       60 * time.Second,
+		BufferSize:        16,
+		WriteTimeout:      10 * time.Second,
+		MaxMessageBytes:   1 << 20,
+	}
+}
+
+// WebSocket is a WebSocket connection to one client. Messages are sent as text.
+type WebSocket struct {
+	options WebSocketOptions
+	conn    *websocket.Conn
+
+	outgoing  chan []byte
+	incoming  chan []byte
+	closing   chan struct{} // Closed by Close
+	readDone  chan struct{} // Closed when the reader goroutine exits
+	done      chan struct{} // Closed when the writer goroutine exits
+	closeOnce sync.Once
+	err       error // Why the connection ended, set before done is closed
+	readErr   error // Why the reader stopped, set before incoming and readDone are closed
+}
+
+// UpgradeWebSocket upgrades the request to a WebSocket connection that lasts until
+// Close is called, the client disconnects, or r's context is canceled. On failure the
+// client has been sent an error response. The handler must call Close before returning.
+// Zero option values fall back to the defaults.
+func UpgradeWebSocket(w http.ResponseWriter, r *http.Request, options WebSocketOptions) (*WebSocket, error) {
+	defaults := DefaultWebSocketOptions()
+	if options.HeartbeatInterval == 0 {
+		options.HeartbeatInterval = defaults.HeartbeatInterval
+	}
+	if options.PongTimeout == 0 {
+		options.PongTimeout = 2 * options.HeartbeatInterval
+		if options.HeartbeatInterval < 0 {
+			options.PongTimeout = -1
+		}
+	}
+	if options.BufferSize <= 0 {
+		options.BufferSize = defaults.BufferSize
+	}
+	if options.WriteTimeout <= 0 {
+		options.WriteTimeout = defaults.WriteTimeout
+	}
+	if options.MaxMessageBytes <= 0 {
+		options.MaxMessageBytes = defaults.MaxMessageBytes
+	}
+
+	upgrader := websocket.Upgrader{
+		CheckOrigin:  options.CheckOrigin,
+		Subprotocols: options.Subprotocols,
+	}
+	conn, err := upgrader.Upgrade(hijacker{w}, r, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upgrade to WebSocket: %w", err)
+	}
+	conn.SetReadLimit(options.MaxMessageBytes)
+
+	ws := &WebSocket{
+		options:  options,
+		conn:     conn,
+		outgoing: make(chan []byte, options.BufferSize),
+		incoming: make(chan []byte, options.BufferSize),
+		closing:  make(chan struct{}),
+		readDone: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	ws.extendReadDeadline()
+	conn.SetPongHandler(func(string) error {
+		ws.extendReadDeadline()
+		return nil
+	})
+
+	go ws.read()
+	go ws.run(r.Context())
+	return ws, nil
+}
+
+// Send queues a message, blocking while the buffer is full until there is room, ctx is
+// done, or the connection ends. It returns ErrClosed once the connection has ended.
+func (ws *WebSocket) Send(ctx context.Context, message []byte) error {
+	select {
+	case <-ws.closing:
+		return ErrClosed
+	case <-ws.done:
+		return ErrClosed
+	default:
+	}
+
+	select {
+	case ws.outgoing <- message:
+		return nil
+	case <-ws.closing:
+		return ErrClosed
+	case <-ws.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SendJSON queues v encoded as JSON.
+func (ws *WebSocket) SendJSON(ctx context.Context, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+	return ws.Send(ctx, data)
+}
+
+// Receive returns the next message from the client, blocking until one arrives, ctx is
+// done, or the connection ends. Messages not received stay buffered, and once the buffer
+// is full the connection stops reading, so the client is slowed down in turn. After the
+// client closes the connection, Receive returns ErrClosed.
+func (ws *WebSocket) Receive(ctx context.Context) ([]byte, error) {
+	select {
+	case message, ok := <-ws.incoming:
+		if !ok {
+			return nil, ws.readErr
+		}
+		return message, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Subprotocol returns the subprotocol chosen for the connection, if any.
+func (ws *WebSocket) Subprotocol() string {
+	return ws.conn.Subprotocol()
+}
+
+// Done returns a channel that is closed when the connection has ended, including when
+// the client closes it.
+func (ws *WebSocket) Done() <-chan struct{} {
+	return ws.done
+}
+
+// Close sends the buffered messages and a normal closure to the client, closes the
+// connection, and waits for its goroutine to exit. It returns the error that ended the
+// connection, if it ended before Close.
+func (ws *WebSocket) Close() error {
+	ws.closeOnce.Do(func() { close(ws.closing) })
+	<-ws.done
+	return ws.err
+}
+
+// read receives messages until the connection fails or is closed.
+func (ws *WebSocket) read() {
+	defer close(ws.readDone)
+	defer close(ws.incoming)
+	for {
+		_, message, err := ws.conn.ReadMessage()
+		if err != nil {
+			ws.readErr = ErrClosed
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived) && !errors.Is(err, net.ErrClosed) {
+				ws.readErr = fmt.Errorf("failed to read message: %w", err)
+			}
+			return
+		}
+		ws.extendReadDeadline()
+
+		select {
+		case ws.incoming <- message:
+		case <-ws.done:
+			return
+		}
+	}
+}
+
+// run writes messages and pings until the connection is closed or fails, then closes it.
+func (ws *WebSocket) run(ctx context.Context) {
+	defer close(ws.done)
+	defer ws.conn.Close()
+
+	var heartbeat <-chan time.Time
+	if ws.options.HeartbeatInterval > 0 {
+		ticker := time.NewTicker(ws.options.HeartbeatInterval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case message := <-ws.outgoing:
+			if ws.err = ws.write(websocket.TextMessage, message); ws.err != nil {
+				return
+			}
+		case <-heartbeat:
+			if ws.err = ws.write(websocket.PingMessage, nil); ws.err != nil {
+				return
+			}
+		case <-ws.closing:
+			ws.err = ws.drain()
+			ws.closeWith(websocket.CloseNormalClosure, "")
+			return
+		case <-ws.readDone:
+			// The client closed the connection or stopped responding
+			ws.err = ws.readErr
+			ws.closeWith(websocket.CloseNormalClosure, "")
+			return
+		case <-ctx.Done():
+			ws.err = ErrClosed
+			ws.closeWith(websocket.CloseGoingAway, "server shutting down")
+			return
+		}
+	}
+}
+
+// drain writes the messages still buffered when the connection is closed.
+func (ws *WebSocket) drain() error {
+	for {
+		select {
+		case message := <-ws.outgoing:
+			if err := ws.write(websocket.TextMessage, message); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// write writes one message within the write timeout.
+func (ws *WebSocket) write(messageType int, data []byte) error {
+	ws.conn.SetWriteDeadline(time.Now().Add(ws.options.WriteTimeout))
+	if err := ws.conn.WriteMessage(messageType, data); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	return nil
+}
+
+// closeWith sends a close message, ignoring failures since the connection is closed next.
+func (ws *WebSocket) closeWith(code int, reason string) {
+	message := websocket.FormatCloseMessage(code, reason)
+	ws.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(ws.options.WriteTimeout))
+}
+
+// extendReadDeadline gives the client another PongTimeout to respond.
+func (ws *WebSocket) extendReadDeadline() {
+	if ws.options.PongTimeout > 0 {
+		ws.conn.SetReadDeadline(time.Now().Add(ws.options.PongTimeout))
+	}
+}
+
+// hijacker lets the upgrader take over the connection through middleware whose
+// response writers wrap the server's and only expose it with Unwrap.
+type hijacker struct {
+	http.ResponseWriter
+}
+
+// Hijack takes over the connection of the innermost ResponseWriter.
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(h.ResponseWriter).Hijack()
+}
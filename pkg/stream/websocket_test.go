@@ -0,0 +1,180 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/h2co32/gollama/internal/metrics"
+	"github.com/h2co32/gollama/pkg/middleware"
+	"github.com/h2co32/gollama/pkg/observability"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// dial connects a WebSocket client to server.
+func dial(t *testing.T, server *httptest.Server, header http.Header) *websocket.Conn {
+	t.Helper()
+	conn, res, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), header)
+	if err != nil {
+		status := 0
+		if res != nil {
+			status = res.StatusCode
+		}
+		t.Fatalf("Failed to connect (status %d): %v", status, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestWebSocket(t *testing.T) {
+	provider, err := metrics.NewMetricsProviderWithOptions(metrics.MetricsOptions{Registry: prometheus.NewRegistry()})
+	if err != nil {
+		t.Fatalf("Failed to create metrics provider: %v", err)
+	}
+	auth := middleware.NewAuthMiddleware(middleware.AuthOptions{
+		AuthType: middleware.AuthTypeAPIKey,
+		APIKeys:  map[string]string{"key-1": "browser"},
+	})
+
+	// Echo each message back in upper case, behind the usual middleware
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := UpgradeWebSocket(w, r, DefaultWebSocketOptions())
+		if err != nil {
+			t.Errorf("Failed to upgrade: %v", err)
+			return
+		}
+		defer ws.Close()
+		for {
+			message, err := ws.Receive(r.Context())
+			if err != nil {
+				return
+			}
+			if string(message) == "bye" {
+				ws.Send(r.Context(), []byte("BYE"))
+				return
+			}
+			ws.Send(r.Context(), []byte(strings.ToUpper(string(message))))
+		}
+	})
+	stack := observability.HTTPMiddleware(provider.Middleware(middleware.NewRecoverMiddleware(middleware.RecoverOptions{}).Middleware(auth.Middleware(handler))))
+	server := httptest.NewServer(stack)
+	defer server.Close()
+
+	conn := dial(t, server, http.Header{middleware.APIKeyHeaderKey: {"key-1"}})
+	for _, message := range []string{"hello", "llama", "bye"} {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+			t.Fatalf("Failed to send message: %v", err)
+		}
+		_, reply, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to read reply: %v", err)
+		}
+		if string(reply) != strings.ToUpper(message) {
+			t.Errorf("Expected %s, got %s", strings.ToUpper(message), reply)
+		}
+	}
+
+	// Closing on the server sends a normal closure
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("Expected a normal closure, got %v", err)
+	}
+
+	// Unauthenticated upgrades are rejected by the middleware
+	_, res, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err == nil || res == nil || res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected an unauthenticated upgrade to get 401, got %v", err)
+	}
+}
+
+func TestWebSocketHeartbeat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := UpgradeWebSocket(w, r, WebSocketOptions{HeartbeatInterval: 10 * time.Millisecond})
+		if err != nil {
+			t.Errorf("Failed to upgrade: %v", err)
+			return
+		}
+		<-ws.Done()
+		ws.Close()
+	}))
+	defer server.Close()
+
+	conn := dial(t, server, nil)
+	pings := make(chan struct{}, 10)
+	conn.SetPingHandler(func(data string) error {
+		pings <- struct{}{}
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	go conn.ReadMessage() // Control messages are handled while reading
+
+	select {
+	case <-pings:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the server to ping an idle connection")
+	}
+}
+
+func TestWebSocketClientClose(t *testing.T) {
+	ended := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := UpgradeWebSocket(w, r, DefaultWebSocketOptions())
+		if err != nil {
+			t.Errorf("Failed to upgrade: %v", err)
+			return
+		}
+		message, err := ws.Receive(r.Context())
+		if err != nil || string(message) != "last" {
+			t.Errorf("Expected the buffered message before the closure, got %q, %v", message, err)
+		}
+		if _, err := ws.Receive(r.Context()); !errors.Is(err, ErrClosed) {
+			t.Errorf("Expected ErrClosed after the client closed, got %v", err)
+		}
+		<-ws.Done()
+		if err := ws.Send(context.Background(), []byte("late")); !errors.Is(err, ErrClosed) {
+			t.Errorf("Expected ErrClosed sending after the client closed, got %v", err)
+		}
+		ended <- ws.Close()
+	}))
+	defer server.Close()
+
+	conn := dial(t, server, nil)
+	conn.WriteMessage(websocket.TextMessage, []byte("last"))
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+
+	select {
+	case err := <-ended:
+		if !errors.Is(err, ErrClosed) {
+			t.Errorf("Expected ErrClosed from Close, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the connection to end when the client closed it")
+	}
+}
+
+func TestWebSocketContextCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 20*time.Millisecond)
+		defer cancel()
+		ws, err := UpgradeWebSocket(w, r.WithContext(ctx), DefaultWebSocketOptions())
+		if err != nil {
+			t.Errorf("Failed to upgrade: %v", err)
+			return
+		}
+		<-ws.Done()
+		ws.Close()
+	}))
+	defer server.Close()
+
+	conn := dial(t, server, nil)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("Expected a going away closure when the context ends, got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package structured
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/h2co32/gollama/internal/models"
+	"github.com/h2co32/gollama/pkg/prompt"
+)
+
+// OllamaGenerator generates output with a chat model served by Ollama in JSON mode, which
+// constrains the model to reply with valid JSON.
+type OllamaGenerator struct {
+	client  *models.OllamaClient
+	model   string
+	options map[string]interface{}
+}
+
+// NewOllamaGenerator creates a Generator that generates with model, such as "llama3".
+// options are passed to Ollama as model parameters, such as {"temperature": 0}, and may
+// be nil.
+func NewOllamaGenerator(client *models.OllamaClient, model string, options map[string]interface{}) *OllamaGenerator {
+	return &OllamaGenerator{client: client, model: model, options: options}
+}
+
+// Generate sends the messages to the model and returns its reply.
+func (g *OllamaGenerator) Generate(ctx context.Context, messages []prompt.Message) (string, error) {
+	req := models.ChatRequest{
+		Model:    g.model,
+		Messages: make([]models.Message, len(messages)),
+		Stream:   new(bool),
+		Format:   "json",
+		Options:  g.options,
+	}
+	for i, message := range messages {
+		req.Messages[i] = models.Message{Role: message.Role, Content: message.Content}
+	}
+
+	var reply strings.Builder
+	err := g.client.Chat(ctx, req, func(res models.ChatResponse) error {
+		reply.WriteString(res.Message.Content)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate output: %w", err)
+	}
+	return reply.String(), nil
+}
@@ -0,0 +1,277 @@
+package structured
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a JSON schema describing the output to generate. It supports the keywords
+// below, which cover the shapes models are usually asked for; other keywords are
+// ignored when parsing.
+type Schema struct {
+	// Type is one of "object", "array", "string", "number", "integer", "boolean", or
+	// "null". An empty type accepts any value.
+	Type string `json:"type,omitempty"`
+
+	// Description tells the model what the value means.
+	Description string `json:"description,omitempty"`
+
+	// Properties are the schemas of an object's properties.
+	Properties map[string]*Schema `json:"properties,omitempty"`
+
+	// Required lists the properties an object must have.
+	Required []string `json:"required,omitempty"`
+
+	// AdditionalProperties reports whether an object may have properties missing from
+	// Properties. Default: true
+	AdditionalProperties *bool `json:"additionalProperties,omitempty"`
+
+	// Items is the schema of an array's elements.
+	Items *Schema `json:"items,omitempty"`
+
+	// MinItems and MaxItems bound the length of an array.
+	MinItems *int `json:"minItems,omitempty"`
+	MaxItems *int `json:"maxItems,omitempty"`
+
+	// Enum lists the values allowed.
+	Enum []any `json:"enum,omitempty"`
+
+	// Minimum and Maximum bound a number, inclusively.
+	Minimum *float64 `json:"minimum,omitempty"`
+	Maximum *float64 `json:"maximum,omitempty"`
+
+	// MinLength and MaxLength bound the length of a string in characters.
+	MinLength *int `json:"minLength,omitempty"`
+	MaxLength *int `json:"maxLength,omitempty"`
+
+	// Pattern is a regular expression a string must match.
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// ParseSchema parses a JSON schema.
+func ParseSchema(data []byte) (*Schema, error) {
+	var schema Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %w", err)
+	}
+	if err := schema.check("$"); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// String returns the schema as indented JSON, as it is shown to the model.
+func (s *Schema) String() string {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// FieldError is a value that does not match its schema.
+type FieldError struct {
+	// Path locates the value, such as "$.items[0].name".
+	Path string `json:"path"`
+
+	// Message describes the mismatch.
+	Message string `json:"message"`
+}
+
+// ValidationError lists the values that do not match a schema.
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+// Error joins the field errors.
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fieldError := range e.Errors {
+		messages[i] = fmt.Sprintf("%s: %s", fieldError.Path, fieldError.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Validate checks a value decoded from JSON, such as by json.Unmarshal into an any,
+// against the schema. It returns a *ValidationError listing every mismatch, or nil.
+func (s *Schema) Validate(value any) error {
+	var errs []FieldError
+	s.validate("$", value, &errs)
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// ValidateJSON parses data and validates it against the schema.
+func (s *Schema) ValidateJSON(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("invalid JSON: unexpected data after the value")
+	}
+	if err := s.Validate(value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// check reports schemas that cannot be validated against, such as unknown types and
+// invalid patterns.
+func (s *Schema) check(path string) error {
+	if s == nil {
+		return nil
+	}
+	switch s.Type {
+	case "", "object", "array", "string", "number", "integer", "boolean", "null":
+	default:
+		return fmt.Errorf("unsupported schema type at %s: %s", path, s.Type)
+	}
+	if s.Pattern != "" {
+		if _, err := regexp.Compile(s.Pattern); err != nil {
+			return fmt.Errorf("invalid schema pattern at %s: %w", path, err)
+		}
+	}
+	for name, property := range s.Properties {
+		if err := property.check(path + "." + name); err != nil {
+			return err
+		}
+	}
+	return s.Items.check(path + "[]")
+}
+
+// validate appends the mismatches of value and its children to errs.
+func (s *Schema) validate(path string, value any, errs *[]FieldError) {
+	if s == nil {
+		return
+	}
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.Type != "" && !hasType(value, s.Type) {
+		fail("expected %s, got %s", s.Type, typeOf(value))
+		return
+	}
+	if len(s.Enum) > 0 && !inEnum(value, s.Enum) {
+		fail("must be one of %s", enumString(s.Enum))
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					fail("property %q is not allowed", name)
+				}
+				continue
+			}
+			property.validate(path+"."+name, v[name], errs)
+		}
+	case []any:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			fail("must have at least %d items, got %d", *s.MinItems, len(v))
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			fail("must have at most %d items, got %d", *s.MaxItems, len(v))
+		}
+		for i, item := range v {
+			s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, errs)
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.MinLength != nil && length < *s.MinLength {
+			fail("must be at least %d characters, got %d", *s.MinLength, length)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			fail("must be at most %d characters, got %d", *s.MaxLength, length)
+		}
+		if s.Pattern != "" {
+			if re, err := regexp.Compile(s.Pattern); err == nil && !re.MatchString(v) {
+				fail("must match pattern %q", s.Pattern)
+			}
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			fail("must be at least %v, got %v", *s.Minimum, v)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			fail("must be at most %v, got %v", *s.Maximum, v)
+		}
+	}
+}
+
+// hasType reports whether value has the JSON type t.
+func hasType(value any, t string) bool {
+	switch t {
+	case "integer":
+		number, ok := value.(float64)
+		return ok && number == math.Trunc(number)
+	default:
+		return typeOf(value) == t
+	}
+}
+
+// typeOf returns the JSON type of value.
+func typeOf(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// inEnum reports whether value equals one of the enum values. Values are compared as
+// JSON, so the number 1 in a schema built in Go matches 1 decoded from the output.
+func inEnum(value any, enum []any) bool {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range enum {
+		if other, err := json.Marshal(allowed); err == nil && bytes.Equal(encoded, other) {
+			return true
+		}
+	}
+	return false
+}
+
+// enumString formats enum values for error messages.
+func enumString(enum []any) string {
+	data, err := json.Marshal(enum)
+	if err != nil {
+		return fmt.Sprint(enum)
+	}
+	return string(data)
+}
@@ -0,0 +1,256 @@
+// Package structured generates JSON output that matches a schema.
+//
+// A Client adds formatting instructions holding the JSON schema to the prompt, parses
+// and validates the model's reply against the schema, and when the reply is not valid,
+// shows the model its reply and the validation errors and asks it to repair the output,
+// up to MaxRetries times. Valid output is decoded into a Go value.
+//
+// Example usage:
+//
+//	schema, err := structured.ParseSchema([]byte(`{
+//		"type": "object",
+//		"properties": {
+//			"name": {"type": "string"},
+//			"age": {"type": "integer", "minimum": 0}
+//		},
+//		"required": ["name", "age"]
+//	}`))
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	client, err := structured.New(structured.Options{
+//		Generator: structured.NewOllamaGenerator(models.NewOllamaClient(), "llama3", nil),
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	var person struct {
+//		Name string `json:"name"`
+//		Age  int    `json:"age"`
+//	}
+//	_, err = client.Generate(ctx, schema, []prompt.Message{
+//		{Role: prompt.RoleUser, Content: "Extract the person: Ada Lovelace, 36."},
+//	}, &person)
+package structured
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/h2co32/gollama/pkg/prompt"
+)
+
+// Version represents the current package version following semantic versioning.
+const Version = "1.0.0"
+
+// tracerName is the instrumentation name of the default tracer.
+const tracerName = "github.com/h2co32/gollama/pkg/structured"
+
+// ErrInvalidOutput is returned by Generate when no reply matched the schema.
+var ErrInvalidOutput = errors.New("model output does not match the schema")
+
+// DefaultInstructions precede the schema in the formatting instructions added to the
+// prompt.
+const DefaultInstructions = "Reply with only a JSON value that matches the JSON schema below. " +
+	"Do not add any other text or wrap the value in code fences.\n\nJSON schema:\n"
+
+// DefaultRepairInstructions precede the validation errors in the message asking the
+// model to repair its output.
+const DefaultRepairInstructions = "Your reply does not match the JSON schema. " +
+	"Reply again with only the corrected JSON value.\n\nErrors:\n"
+
+// Generator generates a reply to chat messages.
+type Generator interface {
+	// Generate returns the model's reply to messages.
+	Generate(ctx context.Context, messages []prompt.Message) (string, error)
+}
+
+// Options configures a Client.
+type Options struct {
+	// Generator generates the output. Required.
+	Generator Generator
+
+	// MaxRetries is how many times an invalid reply is sent back to be repaired. A
+	// negative value disables repairing.
+	// Default: 2
+	MaxRetries int
+
+	// Instructions precede the schema in the formatting instructions, which are appended
+	// to the leading system message or added as one.
+	// Default: DefaultInstructions
+	Instructions string
+
+	// RepairInstructions precede the validation errors in the repair message.
+	// Default: DefaultRepairInstructions
+	RepairInstructions string
+
+	// Tracer starts a span for each Generate call.
+	// Default: a tracer from the global OpenTelemetry tracer provider
+	Tracer trace.Tracer
+}
+
+// DefaultOptions returns the default client options.
+func DefaultOptions() Options {
+	return Options{
+		MaxRetries:         2,
+		Instructions:       DefaultInstructions,
+		RepairInstructions: DefaultRepairInstructions,
+		Tracer:             otel.Tracer(tracerName),
+	}
+}
+
+// Result is the output of a Generate call.
+type Result struct {
+	// Raw is the JSON text of the valid reply.
+	Raw string `json:"raw"`
+
+	// Value is the reply decoded into maps, slices, and basic values.
+	Value any `json:"value"`
+
+	// Attempts is the number of replies generated, including the valid one.
+	Attempts int `json:"attempts"`
+
+	// Messages is the conversation sent for the last reply, including the formatting
+	// instructions and any repair messages.
+	Messages []prompt.Message `json:"messages"`
+}
+
+// Client generates output that matches JSON schemas. It is safe for concurrent use.
+type Client struct {
+	options Options
+}
+
+// New creates a Client. Zero option values fall back to the defaults.
+func New(options Options) (*Client, error) {
+	if options.Generator == nil {
+		return nil, errors.New("generator is required")
+	}
+	defaults := DefaultOptions()
+	if options.MaxRetries == 0 {
+		options.MaxRetries = defaults.MaxRetries
+	} else if options.MaxRetries < 0 {
+		options.MaxRetries = 0
+	}
+	if options.Instructions == "" {
+		options.Instructions = defaults.Instructions
+	}
+	if options.RepairInstructions == "" {
+		options.RepairInstructions = defaults.RepairInstructions
+	}
+	if options.Tracer == nil {
+		options.Tracer = defaults.Tracer
+	}
+	return &Client{options: options}, nil
+}
+
+// Generate sends messages with formatting instructions for schema to the generator and
+// returns the first reply that matches the schema. If target is not nil, the reply is
+// also decoded into it with json.Unmarshal, and a reply that does not decode counts as
+// invalid. Once the retries are used up, it returns an error wrapping ErrInvalidOutput
+// and the last validation error.
+func (c *Client) Generate(ctx context.Context, schema *Schema, messages []prompt.Message, target any) (result *Result, err error) {
+	if schema == nil {
+		return nil, errors.New("schema is required")
+	}
+	if err := schema.check("$"); err != nil {
+		return nil, err
+	}
+
+	ctx, span := c.options.Tracer.Start(ctx, "structured.generate",
+		trace.WithAttributes(attribute.Int("structured.max_retries", c.options.MaxRetries)),
+	)
+	defer func() {
+		if result != nil {
+			span.SetAttributes(attribute.Int("structured.attempts", result.Attempts))
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	conversation := c.withInstructions(schema, messages)
+	var lastErr error
+	for attempt := 1; attempt <= c.options.MaxRetries+1; attempt++ {
+		reply, err := c.options.Generator.Generate(ctx, conversation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate output: %w", err)
+		}
+
+		raw := extractJSON(reply)
+		value, err := schema.ValidateJSON([]byte(raw))
+		if err == nil && target != nil {
+			if decodeErr := json.Unmarshal([]byte(raw), target); decodeErr != nil {
+				err = fmt.Errorf("failed to decode output: %w", decodeErr)
+			}
+		}
+		if err == nil {
+			return &Result{Raw: raw, Value: value, Attempts: attempt, Messages: conversation}, nil
+		}
+
+		lastErr = err
+		span.AddEvent("invalid output", trace.WithAttributes(
+			attribute.Int("structured.attempt", attempt),
+			attribute.String("structured.error", err.Error()),
+		))
+		conversation = append(conversation[:len(conversation):len(conversation)],
+			prompt.Message{Role: prompt.RoleAssistant, Content: reply},
+			prompt.Message{Role: prompt.RoleUser, Content: c.repairMessage(err)},
+		)
+	}
+	return nil, fmt.Errorf("%w after %d attempts: %w", ErrInvalidOutput, c.options.MaxRetries+1, lastErr)
+}
+
+// withInstructions returns a copy of messages with the formatting instructions for
+// schema appended to the leading system message, or added as one.
+func (c *Client) withInstructions(schema *Schema, messages []prompt.Message) []prompt.Message {
+	instructions := c.options.Instructions + schema.String()
+	result := make([]prompt.Message, 0, len(messages)+1)
+	if len(messages) > 0 && messages[0].Role == prompt.RoleSystem {
+		system := messages[0]
+		system.Content = strings.TrimRight(system.Content, "\n") + "\n\n" + instructions
+		result = append(result, system)
+		return append(result, messages[1:]...)
+	}
+	result = append(result, prompt.Message{Role: prompt.RoleSystem, Content: instructions})
+	return append(result, messages...)
+}
+
+// repairMessage asks the model to fix the errors of its last reply.
+func (c *Client) repairMessage(err error) string {
+	var validation *ValidationError
+	if !errors.As(err, &validation) {
+		return c.options.RepairInstructions + "- " + err.Error()
+	}
+	lines := make([]string, len(validation.Errors))
+	for i, fieldError := range validation.Errors {
+		lines[i] = fmt.Sprintf("- %s: %s", fieldError.Path, fieldError.Message)
+	}
+	return c.options.RepairInstructions + strings.Join(lines, "\n")
+}
+
+// extractJSON returns the JSON value in a reply, removing surrounding whitespace and
+// the Markdown code fence models often add despite the instructions.
+func extractJSON(reply string) string {
+	reply = strings.TrimSpace(reply)
+	if !strings.HasPrefix(reply, "```") {
+		return reply
+	}
+	reply = strings.TrimPrefix(reply, "```")
+	if newline := strings.IndexByte(reply, '\n'); newline >= 0 {
+		reply = reply[newline+1:]
+	}
+	reply = strings.TrimSuffix(strings.TrimSpace(reply), "```")
+	return strings.TrimSpace(reply)
+}
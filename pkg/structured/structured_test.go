@@ -0,0 +1,232 @@
+package structured
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/h2co32/gollama/internal/models"
+	"github.com/h2co32/gollama/pkg/prompt"
+)
+
+const personSchema = `{
+	"type": "object",
+	"properties": {
+		"name": {"type": "string", "minLength": 1},
+		"age": {"type": "integer", "minimum": 0},
+		"role": {"enum": ["admin", "user"]},
+		"tags": {"type": "array", "items": {"type": "string", "pattern": "^[a-z]+$"}, "maxItems": 2}
+	},
+	"required": ["name", "age"],
+	"additionalProperties": false
+}`
+
+type person struct {
+	Name string   `json:"name"`
+	Age  int      `json:"age"`
+	Role string   `json:"role"`
+	Tags []string `json:"tags"`
+}
+
+// scriptedGenerator replies with the next reply of its script and records the
+// conversations it was sent.
+type scriptedGenerator struct {
+	replies []string
+	calls   [][]prompt.Message
+}
+
+func (g *scriptedGenerator) Generate(ctx context.Context, messages []prompt.Message) (string, error) {
+	g.calls = append(g.calls, messages)
+	reply := g.replies[0]
+	if len(g.replies) > 1 {
+		g.replies = g.replies[1:]
+	}
+	return reply, nil
+}
+
+func mustParse(t *testing.T, data string) *Schema {
+	t.Helper()
+	schema, err := ParseSchema([]byte(data))
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+	return schema
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema := mustParse(t, personSchema)
+
+	tests := []struct {
+		input  string
+		errors []string
+	}{
+		{`{"name": "Ada", "age": 36, "role": "admin", "tags": ["math"]}`, nil},
+		{`{"name": "Ada"}`, []string{`$: missing required property "age"`}},
+		{`{"name": "", "age": 36.5}`, []string{"$.age: expected integer, got number", "$.name: must be at least 1 characters, got 0"}},
+		{`{"name": "Ada", "age": -1, "role": "guest"}`, []string{"$.age: must be at least 0, got -1", `$.role: must be one of ["admin","user"]`}},
+		{`{"name": "Ada", "age": 1, "tags": ["a", "B", "c"]}`, []string{"$.tags: must have at most 2 items, got 3", `$.tags[1]: must match pattern "^[a-z]+$"`}},
+		{`{"name": "Ada", "age": 1, "email": "x"}`, []string{`$: property "email" is not allowed`}},
+		{`[1]`, []string{"$: expected object, got array"}},
+	}
+	for _, tt := range tests {
+		_, err := schema.ValidateJSON([]byte(tt.input))
+		if tt.errors == nil {
+			if err != nil {
+				t.Errorf("Expected %s to be valid, got %v", tt.input, err)
+			}
+			continue
+		}
+		var validation *ValidationError
+		if !errors.As(err, &validation) {
+			t.Errorf("Expected ValidationError for %s, got %v", tt.input, err)
+			continue
+		}
+		if got, expected := validation.Error(), strings.Join(tt.errors, "; "); got != expected {
+			t.Errorf("Expected %q for %s, got %q", expected, tt.input, got)
+		}
+	}
+
+	if _, err := schema.ValidateJSON([]byte(`{"name": "Ada"} extra`)); err == nil {
+		t.Error("Expected error for trailing data")
+	}
+}
+
+func TestParseSchemaErrors(t *testing.T) {
+	for _, data := range []string{`{"type": "date"}`, `{"properties": {"a": {"pattern": "("}}}`, `not json`} {
+		if _, err := ParseSchema([]byte(data)); err == nil {
+			t.Errorf("Expected error for schema %s", data)
+		}
+	}
+}
+
+func TestGenerateAddsInstructions(t *testing.T) {
+	generator := &scriptedGenerator{replies: []string{"```json\n{\"name\": \"Ada\", \"age\": 36}\n```"}}
+	client, _ := New(Options{Generator: generator})
+
+	var target person
+	result, err := client.Generate(context.Background(), mustParse(t, personSchema), []prompt.Message{
+		{Role: prompt.RoleSystem, Content: "You extract people."},
+		{Role: prompt.RoleUser, Content: "Ada Lovelace, 36"},
+	}, &target)
+	if err != nil {
+		t.Fatalf("Failed to generate: %v", err)
+	}
+	if target.Name != "Ada" || target.Age != 36 {
+		t.Errorf("Unexpected target %+v", target)
+	}
+	if result.Attempts != 1 || result.Raw != `{"name": "Ada", "age": 36}` {
+		t.Errorf("Unexpected result %+v", result)
+	}
+
+	messages := generator.calls[0]
+	if len(messages) != 2 {
+		t.Fatalf("Expected the instructions in the system message, got %d messages", len(messages))
+	}
+	if !strings.HasPrefix(messages[0].Content, "You extract people.\n\n"+DefaultInstructions) || !strings.Contains(messages[0].Content, `"minLength": 1`) {
+		t.Errorf("Unexpected system message %q", messages[0].Content)
+	}
+}
+
+func TestGenerateRepairs(t *testing.T) {
+	generator := &scriptedGenerator{replies: []string{
+		`{"name": "Ada"}`,
+		`not json`,
+		`{"name": "Ada", "age": 36}`,
+	}}
+	client, _ := New(Options{Generator: generator})
+
+	result, err := client.Generate(context.Background(), mustParse(t, personSchema), []prompt.Message{
+		{Role: prompt.RoleUser, Content: "Ada Lovelace, 36"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate: %v", err)
+	}
+	if result.Attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", result.Attempts)
+	}
+	if value := result.Value.(map[string]any); value["age"] != float64(36) {
+		t.Errorf("Unexpected value %v", value)
+	}
+
+	second := generator.calls[1]
+	if len(second) != 4 || second[2].Content != `{"name": "Ada"}` || second[2].Role != prompt.RoleAssistant {
+		t.Fatalf("Expected the invalid reply to be sent back, got %v", second)
+	}
+	if repair := second[3].Content; !strings.HasPrefix(repair, DefaultRepairInstructions) || !strings.Contains(repair, `- $: missing required property "age"`) {
+		t.Errorf("Unexpected repair message %q", repair)
+	}
+	if len(generator.calls[0]) != 2 {
+		t.Errorf("Expected the first conversation to be unchanged, got %d messages", len(generator.calls[0]))
+	}
+	if len(result.Messages) != 6 {
+		t.Errorf("Expected 6 messages in the last conversation, got %d", len(result.Messages))
+	}
+}
+
+func TestGenerateGivesUp(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	generator := &scriptedGenerator{replies: []string{`{"name": "Ada", "age": "old"}`}}
+	client, _ := New(Options{Generator: generator, MaxRetries: 1, Tracer: provider.Tracer("test")})
+
+	var target person
+	_, err := client.Generate(context.Background(), mustParse(t, personSchema), nil, &target)
+	if !errors.Is(err, ErrInvalidOutput) {
+		t.Fatalf("Expected ErrInvalidOutput, got %v", err)
+	}
+	var validation *ValidationError
+	if !errors.As(err, &validation) || validation.Errors[0].Path != "$.age" {
+		t.Errorf("Expected the last validation error, got %v", err)
+	}
+	if len(generator.calls) != 2 {
+		t.Errorf("Expected 2 attempts, got %d", len(generator.calls))
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Name() != "structured.generate" {
+		t.Fatalf("Expected a structured.generate span, got %d spans", len(spans))
+	}
+	invalid := 0
+	for _, event := range spans[0].Events() {
+		if event.Name == "invalid output" {
+			invalid++
+		}
+	}
+	if invalid != 2 {
+		t.Errorf("Expected 2 invalid output events, got %d", invalid)
+	}
+
+	noRetries, _ := New(Options{Generator: &scriptedGenerator{replies: []string{`{}`}}, MaxRetries: -1})
+	if _, err := noRetries.Generate(context.Background(), mustParse(t, personSchema), nil, nil); err == nil || !strings.Contains(err.Error(), "after 1 attempts") {
+		t.Errorf("Expected a single attempt, got %v", err)
+	}
+}
+
+func TestOllamaGenerator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req models.ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Format != "json" {
+			t.Errorf("Expected JSON mode, got format %q", req.Format)
+		}
+		json.NewEncoder(w).Encode(models.ChatResponse{Message: models.Message{Role: prompt.RoleAssistant, Content: `{"name": "Ada", "age": 36}`}, Done: true})
+	}))
+	defer server.Close()
+
+	client, _ := New(Options{Generator: NewOllamaGenerator(models.NewOllamaClientWithOptions(models.OllamaClientOptions{BaseURL: server.URL, ModelDir: t.TempDir()}), "llama3", nil)})
+	var target person
+	if _, err := client.Generate(context.Background(), mustParse(t, personSchema), nil, &target); err != nil {
+		t.Fatalf("Failed to generate: %v", err)
+	}
+	if target.Name != "Ada" {
+		t.Errorf("Expected Ada, got %q", target.Name)
+	}
+}
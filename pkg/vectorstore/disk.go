@@ -0,0 +1,315 @@
+package vectorstore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ErrClosed is returned by operations on a closed DiskStore.
+var ErrClosed = errors.New("vector store is closed")
+
+// DiskOptions configures a DiskStore.
+type DiskOptions struct {
+	// Metric is the measure of similarity used by queries.
+	// Default: MetricCosine
+	Metric Metric
+
+	// Dimensions is the length of every vector. Optional. By default it is set by the
+	// first record stored.
+	Dimensions int
+
+	// Sync flushes each change to stable storage before it returns, so acknowledged
+	// changes survive a power failure, at the cost of write throughput.
+	// Default: false
+	Sync bool
+
+	// CompactAfter is how many more operations the log may hold than the store has
+	// records before it is rewritten to drop those superseded by later upserts and
+	// deletes. Negative values disable automatic compaction.
+	// Default: 1000
+	CompactAfter int
+}
+
+// DefaultDiskOptions returns the default disk store options.
+func DefaultDiskOptions() DiskOptions {
+	return DiskOptions{
+		Metric:       MetricCosine,
+		CompactAfter: 1000,
+	}
+}
+
+// logEntry is an operation in the log of a DiskStore.
+type logEntry struct {
+	Upsert []Record `json:"upsert,omitempty"`
+	Delete []string `json:"delete,omitempty"`
+}
+
+// DiskStore is a Store that keeps records in memory and persists every change to an
+// append-only log file, which is replayed when the store is opened. Records are stored
+// with their vectors as JSON, so the file is about ten bytes per dimension. It is safe
+// for concurrent use.
+type DiskStore struct {
+	memory  *MemoryStore
+	options DiskOptions
+	path    string
+	file    *os.File
+	entries int
+}
+
+// OpenDiskStore opens the store persisted at path, creating it if it does not exist.
+// Zero values in options fall back to the defaults. A change cut short by a crash is
+// discarded.
+func OpenDiskStore(path string, options DiskOptions) (*DiskStore, error) {
+	defaults := DefaultDiskOptions()
+	if options.Metric == "" {
+		options.Metric = defaults.Metric
+	}
+	if options.CompactAfter == 0 {
+		options.CompactAfter = defaults.CompactAfter
+	}
+
+	memory, err := NewMemoryStore(Options{Metric: options.Metric, Dimensions: options.Dimensions})
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create vector store directory: %w", err)
+	}
+
+	s := &DiskStore{memory: memory, options: options, path: path}
+	torn, err := s.replay()
+	if err != nil {
+		return nil, err
+	}
+	if torn {
+		// Rewrite the log without the partial entry, so later entries are not appended
+		// to it
+		if err := s.compact(); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+
+	s.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vector store: %w", err)
+	}
+	return s, nil
+}
+
+// replay loads the records in the log. It reports whether the log ends with a partial
+// entry, as left by a crash during a write.
+func (s *DiskStore) replay() (bool, error) {
+	file, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to open vector store: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return false, fmt.Errorf("failed to read vector store: %w", err)
+		}
+		if err == io.EOF {
+			// Only complete entries end with a newline
+			return len(bytes.TrimSpace(data)) > 0, nil
+		}
+
+		var entry logEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return false, fmt.Errorf("failed to decode vector store entry on line %d: %w", line, err)
+		}
+		if err := s.memory.validate(entry.Upsert); err != nil {
+			return false, fmt.Errorf("failed to load vector store entry on line %d: %w", line, err)
+		}
+		s.memory.apply(entry.Upsert)
+		s.memory.delete(entry.Delete)
+		s.entries++
+	}
+}
+
+// Upsert adds records, replacing those with the same IDs, and persists them.
+func (s *DiskStore) Upsert(ctx context.Context, records ...Record) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	s.memory.mu.Lock()
+	defer s.memory.mu.Unlock()
+
+	if err := s.memory.validate(records); err != nil {
+		return err
+	}
+	if err := s.append(logEntry{Upsert: records}); err != nil {
+		return err
+	}
+	s.memory.apply(records)
+	return s.maybeCompact()
+}
+
+// Query returns the records most similar to vector, most similar first.
+func (s *DiskStore) Query(ctx context.Context, vector []float32, options QueryOptions) ([]Match, error) {
+	return s.memory.Query(ctx, vector, options)
+}
+
+// Get returns the record with the given ID, or ErrNotFound.
+func (s *DiskStore) Get(ctx context.Context, id string) (Record, error) {
+	return s.memory.Get(ctx, id)
+}
+
+// Delete removes the records with the given IDs and persists their removal. Missing IDs
+// are ignored.
+func (s *DiskStore) Delete(ctx context.Context, ids ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	s.memory.mu.Lock()
+	defer s.memory.mu.Unlock()
+
+	if err := s.append(logEntry{Delete: ids}); err != nil {
+		return err
+	}
+	s.memory.delete(ids)
+	return s.maybeCompact()
+}
+
+// Len returns the number of records.
+func (s *DiskStore) Len() int {
+	return s.memory.Len()
+}
+
+// Compact rewrites the log to hold only the current records.
+func (s *DiskStore) Compact() error {
+	s.memory.mu.Lock()
+	defer s.memory.mu.Unlock()
+
+	if s.file == nil {
+		return ErrClosed
+	}
+	return s.compact()
+}
+
+// Close closes the log file. Later changes return ErrClosed, while queries keep working
+// on the records in memory.
+func (s *DiskStore) Close() error {
+	s.memory.mu.Lock()
+	defer s.memory.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to close vector store: %w", err)
+	}
+	return nil
+}
+
+// append writes an entry to the log. The caller must hold the write lock.
+func (s *DiskStore) append(entry logEntry) error {
+	if s.file == nil {
+		return ErrClosed
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode vector store entry: %w", err)
+	}
+	data = append(data, '\n')
+
+	if _, err := s.file.Write(data); err != nil {
+		return fmt.Errorf("failed to write vector store: %w", err)
+	}
+	if s.options.Sync {
+		if err := s.file.Sync(); err != nil {
+			return fmt.Errorf("failed to write vector store: %w", err)
+		}
+	}
+	s.entries++
+	return nil
+}
+
+// maybeCompact compacts the log once it holds CompactAfter more entries than there are
+// records. The caller must hold the write lock.
+func (s *DiskStore) maybeCompact() error {
+	if s.options.CompactAfter < 0 || s.entries-len(s.memory.records) < s.options.CompactAfter {
+		return nil
+	}
+	return s.compact()
+}
+
+// compact atomically replaces the log with one holding an entry per record, by writing a
+// temporary file next to it and renaming it over the log. The caller must hold the write
+// lock.
+func (s *DiskStore) compact() error {
+	file, err := os.CreateTemp(filepath.Dir(s.path), "."+filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to compact vector store: %w", err)
+	}
+	tempPath := file.Name()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, e := range s.memory.records {
+		if err = encoder.Encode(logEntry{Upsert: []Record{e.record}}); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tempPath, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tempPath, s.path)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to compact vector store: %w", err)
+	}
+	syncDir(filepath.Dir(s.path))
+
+	// Append to the new log from now on
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file, err = os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open vector store: %w", err)
+	}
+	s.entries = len(s.memory.records)
+	return nil
+}
+
+// syncDir persists renames in a directory. Not every platform supports syncing a
+// directory, so failures are ignored.
+func syncDir(directory string) {
+	if dir, err := os.Open(directory); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+}
@@ -0,0 +1,224 @@
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// MemoryStore is a Store that keeps records in memory. It is safe for concurrent use.
+type MemoryStore struct {
+	mu         sync.RWMutex
+	metric     Metric
+	dimensions int
+	records    map[string]entry
+}
+
+// entry is a stored record with the norm of its vector, precomputed for cosine scores.
+type entry struct {
+	record Record
+	norm   float64
+}
+
+// NewMemoryStore creates an empty MemoryStore with the given options. Zero values fall
+// back to the defaults. It returns an error for an unknown metric.
+func NewMemoryStore(options Options) (*MemoryStore, error) {
+	if options.Metric == "" {
+		options.Metric = DefaultOptions().Metric
+	}
+	if err := checkMetric(options.Metric); err != nil {
+		return nil, err
+	}
+	return &MemoryStore{
+		metric:     options.Metric,
+		dimensions: options.Dimensions,
+		records:    make(map[string]entry),
+	}, nil
+}
+
+// Upsert adds records, replacing those with the same IDs. It returns
+// ErrDimensionMismatch, without storing any record, if a vector has the wrong length.
+func (s *MemoryStore) Upsert(ctx context.Context, records ...Record) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.upsert(records)
+}
+
+// upsert adds records. The caller must hold the write lock.
+func (s *MemoryStore) upsert(records []Record) error {
+	if err := s.validate(records); err != nil {
+		return err
+	}
+	s.apply(records)
+	return nil
+}
+
+// validate returns an error if a record lacks an ID or its vector has the wrong length.
+// The caller must hold the lock.
+func (s *MemoryStore) validate(records []Record) error {
+	dimensions := s.dimensions
+	for _, record := range records {
+		if record.ID == "" {
+			return errors.New("record ID is required")
+		}
+		if dimensions == 0 {
+			dimensions = len(record.Vector)
+		}
+		if len(record.Vector) == 0 || len(record.Vector) != dimensions {
+			return fmt.Errorf("%w: record %q has %d dimensions, want %d", ErrDimensionMismatch, record.ID, len(record.Vector), dimensions)
+		}
+	}
+	return nil
+}
+
+// apply stores validated records. The caller must hold the write lock.
+func (s *MemoryStore) apply(records []Record) {
+	if s.dimensions == 0 && len(records) > 0 {
+		s.dimensions = len(records[0].Vector)
+	}
+	for _, record := range records {
+		record = cloneRecord(record)
+		s.records[record.ID] = entry{record: record, norm: norm(record.Vector)}
+	}
+}
+
+// Query returns the records most similar to vector, most similar first. Records with
+// equal scores are ordered by ID.
+func (s *MemoryStore) Query(ctx context.Context, vector []float32, options QueryOptions) ([]Match, error) {
+	if options.TopK <= 0 {
+		options.TopK = DefaultQueryOptions().TopK
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.records) == 0 {
+		return nil, nil
+	}
+	if len(vector) != s.dimensions {
+		return nil, fmt.Errorf("%w: query has %d dimensions, want %d", ErrDimensionMismatch, len(vector), s.dimensions)
+	}
+
+	queryNorm := norm(vector)
+	matches := make([]Match, 0, len(s.records))
+	for _, e := range s.records {
+		if options.Filter != nil && !options.Filter.Matches(e.record.Metadata) {
+			continue
+		}
+		score := s.score(vector, queryNorm, e)
+		if options.MinScore != nil && score < *options.MinScore {
+			continue
+		}
+		matches = append(matches, Match{Record: e.record, Score: score})
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	if len(matches) > options.TopK {
+		matches = matches[:options.TopK]
+	}
+	for i := range matches {
+		matches[i].Record = cloneRecord(matches[i].Record)
+	}
+	return matches, nil
+}
+
+// score returns the similarity of a stored record to the query vector.
+func (s *MemoryStore) score(vector []float32, queryNorm float64, e entry) float64 {
+	switch s.metric {
+	case MetricDot:
+		return dot(vector, e.record.Vector)
+	case MetricL2:
+		var sum float64
+		for i, v := range vector {
+			d := float64(v) - float64(e.record.Vector[i])
+			sum += d * d
+		}
+		return -math.Sqrt(sum)
+	default:
+		if queryNorm == 0 || e.norm == 0 {
+			return 0
+		}
+		return dot(vector, e.record.Vector) / (queryNorm * e.norm)
+	}
+}
+
+// Get returns the record with the given ID, or ErrNotFound.
+func (s *MemoryStore) Get(ctx context.Context, id string) (Record, error) {
+	if err := ctx.Err(); err != nil {
+		return Record{}, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.records[id]
+	if !ok {
+		return Record{}, ErrNotFound
+	}
+	return cloneRecord(e.record), nil
+}
+
+// Delete removes the records with the given IDs. Missing IDs are ignored.
+func (s *MemoryStore) Delete(ctx context.Context, ids ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delete(ids)
+	return nil
+}
+
+// delete removes records. The caller must hold the write lock.
+func (s *MemoryStore) delete(ids []string) {
+	for _, id := range ids {
+		delete(s.records, id)
+	}
+}
+
+// Len returns the number of records.
+func (s *MemoryStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.records)
+}
+
+// dot returns the dot product of two vectors of the same length.
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i, v := range a {
+		sum += float64(v) * float64(b[i])
+	}
+	return sum
+}
+
+// norm returns the Euclidean length of a vector.
+func norm(v []float32) float64 {
+	return math.Sqrt(dot(v, v))
+}
+
+// cloneRecord copies a record, so callers cannot modify stored vectors and metadata.
+func cloneRecord(record Record) Record {
+	record.Vector = append([]float32(nil), record.Vector...)
+	if record.Metadata != nil {
+		metadata := make(map[string]any, len(record.Metadata))
+		for key, value := range record.Metadata {
+			metadata[key] = value
+		}
+		record.Metadata = metadata
+	}
+	return record
+}
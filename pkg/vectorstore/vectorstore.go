@@ -0,0 +1,246 @@
+// Package vectorstore stores embedding vectors with their metadata and finds the ones
+// nearest to a query vector, for retrieval-augmented generation over small corpora
+// without an external vector database.
+//
+// Store is implemented by MemoryStore, which keeps records in memory, and DiskStore,
+// which also persists them to a file so they survive restarts. Both search exhaustively,
+// which is exact and fast enough for tens of thousands of records; larger corpora call
+// for an approximate index in a dedicated database behind the same interface.
+//
+// Example usage:
+//
+//	store, err := vectorstore.NewMemoryStore(vectorstore.Options{Metric: vectorstore.MetricCosine})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	err = store.Upsert(ctx, vectorstore.Record{
+//		ID:       "doc-1#0",
+//		Vector:   embedding,
+//		Content:  chunk,
+//		Metadata: map[string]any{"source": "handbook.md"},
+//	})
+//
+//	matches, err := store.Query(ctx, queryEmbedding, vectorstore.QueryOptions{
+//		TopK:   5,
+//		Filter: vectorstore.Filter{"source": "handbook.md"},
+//	})
+package vectorstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Version represents the current package version following semantic versioning.
+const Version = "1.0.0"
+
+var (
+	// ErrNotFound is returned when a record does not exist.
+	ErrNotFound = errors.New("record not found")
+
+	// ErrDimensionMismatch is returned for vectors whose length differs from the
+	// store's dimensions.
+	ErrDimensionMismatch = errors.New("vector dimension mismatch")
+)
+
+// Metric is the measure of similarity between vectors.
+type Metric string
+
+const (
+	// MetricCosine scores vectors by the cosine of the angle between them, from -1 to 1,
+	// ignoring their lengths. Most embedding models are meant to be compared this way.
+	MetricCosine Metric = "cosine"
+
+	// MetricDot scores vectors by their dot product, which equals the cosine for
+	// normalized vectors and is cheaper to compute.
+	MetricDot Metric = "dot"
+
+	// MetricL2 scores vectors by their Euclidean distance, negated so that higher
+	// scores are nearer, like the other metrics.
+	MetricL2 Metric = "l2"
+)
+
+// Record is a vector stored with its metadata.
+type Record struct {
+	// ID identifies the record. Upserting a record with an existing ID replaces it.
+	ID string `json:"id"`
+
+	// Vector is the embedding.
+	Vector []float32 `json:"vector"`
+
+	// Content is the text the vector embeds, such as a document chunk. Optional.
+	Content string `json:"content,omitempty"`
+
+	// Metadata holds attributes queries can filter on, such as the source document.
+	// Values should be JSON types. Optional.
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// Match is a record found by a query.
+type Match struct {
+	Record
+
+	// Score is the similarity of the record to the query vector. Higher is more similar.
+	Score float64 `json:"score"`
+}
+
+// Filter selects records by metadata. A record matches when, for every key, its
+// metadata value equals the filter value or, if the filter value is a slice, one of its
+// elements. Numbers compare by value regardless of their Go type.
+type Filter map[string]any
+
+// QueryOptions configures a query.
+type QueryOptions struct {
+	// TopK is the most matches returned.
+	// Default: 10
+	TopK int
+
+	// Filter limits the query to records with matching metadata. Optional.
+	Filter Filter
+
+	// MinScore excludes matches scoring lower. Optional.
+	MinScore *float64
+}
+
+// DefaultQueryOptions returns the default query options.
+func DefaultQueryOptions() QueryOptions {
+	return QueryOptions{
+		TopK: 10,
+	}
+}
+
+// Store stores vectors and finds the ones nearest to a query vector.
+type Store interface {
+	// Upsert adds records, replacing those with the same IDs.
+	Upsert(ctx context.Context, records ...Record) error
+
+	// Query returns the records most similar to vector, most similar first.
+	Query(ctx context.Context, vector []float32, options QueryOptions) ([]Match, error)
+
+	// Get returns the record with the given ID, or ErrNotFound.
+	Get(ctx context.Context, id string) (Record, error)
+
+	// Delete removes the records with the given IDs. Missing IDs are ignored.
+	Delete(ctx context.Context, ids ...string) error
+
+	// Len returns the number of records.
+	Len() int
+}
+
+// Options configures a store.
+type Options struct {
+	// Metric is the measure of similarity used by queries.
+	// Default: MetricCosine
+	Metric Metric
+
+	// Dimensions is the length of every vector. Optional. By default it is set by the
+	// first record stored.
+	Dimensions int
+}
+
+// DefaultOptions returns the default store options.
+func DefaultOptions() Options {
+	return Options{
+		Metric: MetricCosine,
+	}
+}
+
+// Matches reports whether metadata matches the filter.
+func (f Filter) Matches(metadata map[string]any) bool {
+	for key, want := range f {
+		got, ok := metadata[key]
+		if !ok {
+			return false
+		}
+		if values, ok := want.([]any); ok {
+			if !containsValue(values, got) {
+				return false
+			}
+			continue
+		}
+		if values, ok := want.([]string); ok {
+			if !containsValue(toAnySlice(values), got) {
+				return false
+			}
+			continue
+		}
+		if !equalValues(want, got) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsValue reports whether values contains value.
+func containsValue(values []any, value any) bool {
+	for _, v := range values {
+		if equalValues(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// toAnySlice converts a slice of strings to a slice of any.
+func toAnySlice(values []string) []any {
+	converted := make([]any, len(values))
+	for i, v := range values {
+		converted[i] = v
+	}
+	return converted
+}
+
+// equalValues compares metadata values, treating numbers of any type as equal when
+// their values are, as metadata read back from JSON holds float64s.
+func equalValues(a, b any) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	switch a.(type) {
+	case string, bool, nil:
+		return a == b
+	}
+	// Compare other values, such as maps, by their JSON encoding
+	x, errA := json.Marshal(a)
+	y, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(x) == string(y)
+}
+
+// toFloat converts a number to float64.
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// checkMetric returns an error for an unknown metric.
+func checkMetric(metric Metric) error {
+	switch metric {
+	case MetricCosine, MetricDot, MetricL2:
+		return nil
+	}
+	return fmt.Errorf("unsupported metric: %s", metric)
+}
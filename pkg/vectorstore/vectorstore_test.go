@@ -0,0 +1,307 @@
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testRecords() []Record {
+	return []Record{
+		{ID: "a", Vector: []float32{1, 0, 0}, Content: "alpha", Metadata: map[string]any{"source": "one.md", "page": 1}},
+		{ID: "b", Vector: []float32{0.9, 0.1, 0}, Content: "beta", Metadata: map[string]any{"source": "two.md", "page": 2}},
+		{ID: "c", Vector: []float32{0, 1, 0}, Content: "gamma", Metadata: map[string]any{"source": "one.md", "page": 3}},
+		{ID: "d", Vector: []float32{0, 0, 2}, Content: "delta"},
+	}
+}
+
+func ids(matches []Match) []string {
+	result := make([]string, len(matches))
+	for i, match := range matches {
+		result[i] = match.ID
+	}
+	return result
+}
+
+func equalIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMemoryStoreQueryMetrics(t *testing.T) {
+	ctx := context.Background()
+	query := []float32{1, 0, 0}
+
+	tests := []struct {
+		metric   Metric
+		expected []string
+		score    float64
+	}{
+		{MetricCosine, []string{"a", "b"}, 1},
+		{MetricDot, []string{"a", "b"}, 1},
+		{MetricL2, []string{"a", "b"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.metric), func(t *testing.T) {
+			store, err := NewMemoryStore(Options{Metric: tt.metric})
+			if err != nil {
+				t.Fatalf("Failed to create store: %v", err)
+			}
+			if err := store.Upsert(ctx, testRecords()...); err != nil {
+				t.Fatalf("Failed to upsert: %v", err)
+			}
+
+			matches, err := store.Query(ctx, query, QueryOptions{TopK: 2})
+			if err != nil {
+				t.Fatalf("Failed to query: %v", err)
+			}
+			if got := ids(matches); !equalIDs(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+			if math.Abs(matches[0].Score-tt.score) > 1e-6 {
+				t.Errorf("Expected score %v, got %v", tt.score, matches[0].Score)
+			}
+			if matches[0].Content != "alpha" {
+				t.Errorf("Expected content alpha, got %q", matches[0].Content)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreDotFavorsLength(t *testing.T) {
+	ctx := context.Background()
+	store, _ := NewMemoryStore(Options{Metric: MetricDot})
+	store.Upsert(ctx, testRecords()...)
+
+	// Cosine ignores length, but the dot product of "d" grows with it
+	matches, _ := store.Query(ctx, []float32{0, 0, 1}, QueryOptions{TopK: 1})
+	if len(matches) != 1 || matches[0].ID != "d" || matches[0].Score != 2 {
+		t.Errorf("Expected d with score 2, got %+v", matches)
+	}
+}
+
+func TestMemoryStoreFilter(t *testing.T) {
+	ctx := context.Background()
+	store, _ := NewMemoryStore(Options{})
+	store.Upsert(ctx, testRecords()...)
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected []string
+	}{
+		{"equal", Filter{"source": "one.md"}, []string{"a", "c"}},
+		{"number", Filter{"page": 2.0}, []string{"b"}},
+		{"in", Filter{"page": []any{1, 3}}, []string{"a", "c"}},
+		{"strings", Filter{"source": []string{"two.md"}}, []string{"b"}},
+		{"all keys", Filter{"source": "one.md", "page": 3}, []string{"c"}},
+		{"missing key", Filter{"author": "x"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := store.Query(ctx, []float32{1, 0, 0}, QueryOptions{Filter: tt.filter})
+			if err != nil {
+				t.Fatalf("Failed to query: %v", err)
+			}
+			if got := ids(matches); !equalIDs(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreMinScore(t *testing.T) {
+	ctx := context.Background()
+	store, _ := NewMemoryStore(Options{})
+	store.Upsert(ctx, testRecords()...)
+
+	minScore := 0.5
+	matches, _ := store.Query(ctx, []float32{1, 0, 0}, QueryOptions{MinScore: &minScore})
+	if got := ids(matches); !equalIDs(got, []string{"a", "b"}) {
+		t.Errorf("Expected [a b], got %v", got)
+	}
+}
+
+func TestMemoryStoreUpsertReplacesAndDelete(t *testing.T) {
+	ctx := context.Background()
+	store, _ := NewMemoryStore(Options{})
+	store.Upsert(ctx, testRecords()...)
+
+	store.Upsert(ctx, Record{ID: "a", Vector: []float32{0, 1, 0}, Content: "replaced"})
+	if store.Len() != 4 {
+		t.Errorf("Expected 4 records, got %d", store.Len())
+	}
+	record, err := store.Get(ctx, "a")
+	if err != nil || record.Content != "replaced" {
+		t.Errorf("Expected replaced record, got %+v, %v", record, err)
+	}
+
+	store.Delete(ctx, "a", "missing")
+	if _, err := store.Get(ctx, "a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if store.Len() != 3 {
+		t.Errorf("Expected 3 records, got %d", store.Len())
+	}
+}
+
+func TestMemoryStoreDimensions(t *testing.T) {
+	ctx := context.Background()
+	store, _ := NewMemoryStore(Options{})
+	store.Upsert(ctx, testRecords()...)
+
+	err := store.Upsert(ctx, Record{ID: "e", Vector: []float32{1, 0}})
+	if !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch, got %v", err)
+	}
+	if _, err := store.Query(ctx, []float32{1}, QueryOptions{}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch, got %v", err)
+	}
+
+	// A batch with a bad record stores nothing
+	fresh, _ := NewMemoryStore(Options{Dimensions: 2})
+	err = fresh.Upsert(ctx, Record{ID: "x", Vector: []float32{1, 0}}, Record{ID: "y", Vector: []float32{1, 0, 0}})
+	if !errors.Is(err, ErrDimensionMismatch) || fresh.Len() != 0 {
+		t.Errorf("Expected ErrDimensionMismatch and no records, got %v and %d records", err, fresh.Len())
+	}
+}
+
+func TestMemoryStoreCopiesRecords(t *testing.T) {
+	ctx := context.Background()
+	store, _ := NewMemoryStore(Options{})
+	record := Record{ID: "a", Vector: []float32{1, 0}, Metadata: map[string]any{"k": "v"}}
+	store.Upsert(ctx, record)
+
+	record.Vector[0] = 0
+	record.Metadata["k"] = "changed"
+	got, _ := store.Get(ctx, "a")
+	if got.Vector[0] != 1 || got.Metadata["k"] != "v" {
+		t.Errorf("Expected stored record to be unchanged, got %+v", got)
+	}
+}
+
+func TestNewMemoryStoreUnknownMetric(t *testing.T) {
+	if _, err := NewMemoryStore(Options{Metric: "manhattan"}); err == nil {
+		t.Error("Expected error for unknown metric")
+	}
+}
+
+func TestDiskStorePersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "store", "vectors.jsonl")
+
+	store, err := OpenDiskStore(path, DiskOptions{Sync: true})
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	store.Upsert(ctx, testRecords()...)
+	store.Upsert(ctx, Record{ID: "b", Vector: []float32{0, 1, 0}, Content: "beta 2"})
+	store.Delete(ctx, "d")
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+	if err := store.Upsert(ctx, testRecords()...); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+
+	reopened, err := OpenDiskStore(path, DiskOptions{})
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+
+	if reopened.Len() != 3 {
+		t.Errorf("Expected 3 records, got %d", reopened.Len())
+	}
+	record, err := reopened.Get(ctx, "b")
+	if err != nil || record.Content != "beta 2" {
+		t.Errorf("Expected updated record, got %+v, %v", record, err)
+	}
+	matches, _ := reopened.Query(ctx, []float32{1, 0, 0}, QueryOptions{TopK: 1, Filter: Filter{"page": 1}})
+	if got := ids(matches); !equalIDs(got, []string{"a"}) {
+		t.Errorf("Expected [a], got %v", got)
+	}
+}
+
+func TestDiskStoreCompacts(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "vectors.jsonl")
+
+	store, _ := OpenDiskStore(path, DiskOptions{CompactAfter: 5})
+	for i := 0; i < 10; i++ {
+		store.Upsert(ctx, Record{ID: "a", Vector: []float32{float32(i), 1}})
+	}
+	store.Close()
+
+	data, _ := os.ReadFile(path)
+	lines := 0
+	for _, b := range data {
+		if b == '\n' {
+			lines++
+		}
+	}
+	if lines > 5 {
+		t.Errorf("Expected compacted log, got %d lines", lines)
+	}
+
+	reopened, _ := OpenDiskStore(path, DiskOptions{})
+	defer reopened.Close()
+	record, _ := reopened.Get(ctx, "a")
+	if record.Vector[0] != 9 {
+		t.Errorf("Expected last upsert, got %v", record.Vector)
+	}
+}
+
+func TestDiskStoreDiscardsTornEntry(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "vectors.jsonl")
+
+	store, _ := OpenDiskStore(path, DiskOptions{})
+	store.Upsert(ctx, testRecords()...)
+	store.Close()
+
+	// Simulate a crash in the middle of writing an entry
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	file.WriteString(`{"upsert":[{"id":"e","vec`)
+	file.Close()
+
+	reopened, err := OpenDiskStore(path, DiskOptions{})
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	if reopened.Len() != 4 {
+		t.Errorf("Expected 4 records, got %d", reopened.Len())
+	}
+	if err := reopened.Upsert(ctx, Record{ID: "e", Vector: []float32{1, 1, 1}}); err != nil {
+		t.Fatalf("Failed to upsert: %v", err)
+	}
+	reopened.Close()
+
+	again, err := OpenDiskStore(path, DiskOptions{})
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer again.Close()
+	if again.Len() != 5 {
+		t.Errorf("Expected 5 records, got %d", again.Len())
+	}
+}
+
+func TestDiskStoreRejectsCorruptEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vectors.jsonl")
+	os.WriteFile(path, []byte("not json\n"), 0644)
+
+	if _, err := OpenDiskStore(path, DiskOptions{}); err == nil {
+		t.Error("Expected error for corrupt log")
+	}
+}